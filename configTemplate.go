@@ -0,0 +1,9 @@
+// Package hldatabricks holds the files at the root of the repository that hldbx writes out.
+package hldatabricks
+
+import _ "embed"
+
+// ConfigTemplate is the example configuration file, which hldbx setup writes out commented.
+//
+//go:embed config_template.yaml
+var ConfigTemplate []byte
@@ -7,5 +7,6 @@ import (
 
 func main() {
 	utils.ConfigureHLUserAgent()
+	utils.ConfigureCorrelationId()
 	cmd.Execute()
 }
@@ -0,0 +1,115 @@
+// Package broker obtains Databricks OAuth tokens without prompting for a token, for operators on machines
+// where the Databricks CLI's browser login isn't available.
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Broker obtains a Databricks OAuth token for a workspace.
+type Broker interface {
+	// Name describes the broker to the user
+	Name() string
+	// Token returns an access token for the Databricks workspace at dbxHost
+	Token(ctx context.Context, dbxHost string) (string, error)
+}
+
+// New returns the credential broker set up in the configuration, or nil if there is none.
+// onDeviceCode is called with the URL to visit and the code to enter, for brokers that need the user to sign in.
+func New(config *utils.Config, onDeviceCode func(verificationUrl string, userCode string)) (Broker, error) {
+	if config.DbxOidcIssuer == "" {
+		return nil, nil
+	}
+	if config.DbxOidcClientId == "" {
+		return nil, errors.New("dbx_oidc_client_id must be provided along with dbx_oidc_issuer")
+	}
+	cachePath, err := utils.TokenCachePath()
+	if err != nil {
+		return nil, err
+	}
+	scopes := config.DbxOidcScopes
+	if scopes == "" {
+		scopes = defaultScopes
+	}
+	return &DeviceFlow{
+		Issuer:       config.DbxOidcIssuer,
+		ClientId:     config.DbxOidcClientId,
+		Scopes:       scopes,
+		CachePath:    cachePath,
+		OnDeviceCode: onDeviceCode,
+	}, nil
+}
+
+// Tokens are refreshed this long before they expire, so they don't expire mid-command
+const expiryMargin = 5 * time.Minute
+
+// tokenCache has the same format as the Databricks CLI's token-cache.json, so either can be read by hldbx.
+type tokenCache struct {
+	Version int                       `json:"version"`
+	Tokens  map[string]cachedDbxToken `json:"tokens"`
+}
+
+type cachedDbxToken struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type,omitempty"`
+	Expiry      time.Time `json:"expiry,omitempty"`
+}
+
+// readCachedToken returns the cached token for the workspace, if there is one that isn't about to expire.
+func readCachedToken(path string, dbxHost string) (string, bool) {
+	cache, err := readTokenCache(path)
+	if err != nil {
+		return "", false
+	}
+	token, ok := cache.Tokens[dbxHost]
+	if !ok || token.AccessToken == "" || time.Until(token.Expiry) < expiryMargin {
+		return "", false
+	}
+	return token.AccessToken, true
+}
+
+// writeCachedToken adds the workspace's token to the cache file, keeping the tokens of other workspaces.
+func writeCachedToken(path string, dbxHost string, token cachedDbxToken) error {
+	cache, err := readTokenCache(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	cache.Version = 1
+	if cache.Tokens == nil {
+		cache.Tokens = map[string]cachedDbxToken{}
+	}
+	cache.Tokens[dbxHost] = token
+	content, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("unable to create directory for token cache %s: %w", path, err)
+	}
+	// Tokens are credentials, so only the owner can read them
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		return fmt.Errorf("unable to write token cache %s: %w", path, err)
+	}
+	return nil
+}
+
+func readTokenCache(path string) (tokenCache, error) {
+	var cache tokenCache
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return cache, err
+	}
+	if err := json.Unmarshal(content, &cache); err != nil {
+		return cache, fmt.Errorf("unable to parse token cache %s: %w", path, err)
+	}
+	return cache, nil
+}
@@ -0,0 +1,208 @@
+package broker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/hl"
+)
+
+// Scopes requested from the identity provider when none are configured
+const defaultScopes = "openid profile email"
+
+// OAuth grant and token types of the device authorization flow (RFC 8628) and token exchange (RFC 8693)
+const (
+	deviceCodeGrantType    = "urn:ietf:params:oauth:grant-type:device_code"
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	jwtTokenType           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// DeviceFlow signs the user in to an OIDC identity provider, such as Okta, with the device authorization flow:
+// the user opens a URL on any device with a browser and enters a code. The identity provider's token is then
+// exchanged for a Databricks OAuth token, using Databricks OAuth token federation, and cached.
+type DeviceFlow struct {
+	Issuer       string // URL of the OIDC issuer, e.g. https://example.okta.com/oauth2/default
+	ClientId     string // ID of the OIDC client registered for hldbx, which must allow the device flow
+	Scopes       string // space-separated scopes to request
+	CachePath    string // path of the token cache
+	OnDeviceCode func(verificationUrl string, userCode string)
+	httpClient   *http.Client
+}
+
+func (d *DeviceFlow) Name() string {
+	return fmt.Sprintf("OIDC device flow with %s", d.Issuer)
+}
+
+// Token returns a cached Databricks token for the workspace, or signs the user in to get a new one.
+func (d *DeviceFlow) Token(ctx context.Context, dbxHost string) (string, error) {
+	if token, ok := readCachedToken(d.CachePath, dbxHost); ok {
+		return token, nil
+	}
+	d.httpClient = &http.Client{Timeout: 30 * time.Second}
+
+	discovery, err := d.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	idpToken, err := d.signIn(ctx, discovery)
+	if err != nil {
+		return "", err
+	}
+	dbxToken, err := d.exchange(ctx, dbxHost, idpToken)
+	if err != nil {
+		return "", err
+	}
+	if err := writeCachedToken(d.CachePath, dbxHost, dbxToken); err != nil {
+		return "", err
+	}
+	return dbxToken.AccessToken, nil
+}
+
+// oidcDiscovery is the part of the OIDC discovery document with the endpoints of the device flow.
+type oidcDiscovery struct {
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+}
+
+// tokenResponse is an OAuth token endpoint response, successful or not.
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IdToken          string `json:"id_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (d *DeviceFlow) discover(ctx context.Context) (oidcDiscovery, error) {
+	var discovery oidcDiscovery
+	discoveryUrl := strings.TrimSuffix(d.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryUrl, nil)
+	if err != nil {
+		return discovery, err
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return discovery, fmt.Errorf("unable to get OIDC configuration from %s: %w", discoveryUrl, err)
+	}
+	defer hl.CloseBody(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return discovery, fmt.Errorf("unable to get OIDC configuration from %s: %s", discoveryUrl, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return discovery, fmt.Errorf("unable to parse OIDC configuration from %s: %w", discoveryUrl, err)
+	}
+	if discovery.DeviceAuthorizationEndpoint == "" {
+		return discovery, fmt.Errorf("the identity provider at %s doesn't support the device authorization flow", d.Issuer)
+	}
+	return discovery, nil
+}
+
+// signIn runs the device authorization flow, and returns the identity provider's ID token for the user.
+func (d *DeviceFlow) signIn(ctx context.Context, discovery oidcDiscovery) (string, error) {
+	var authorization struct {
+		DeviceCode              string `json:"device_code"`
+		UserCode                string `json:"user_code"`
+		VerificationUri         string `json:"verification_uri"`
+		VerificationUriComplete string `json:"verification_uri_complete"`
+		ExpiresIn               int    `json:"expires_in"`
+		Interval                int    `json:"interval"`
+	}
+	status, err := d.postForm(ctx, discovery.DeviceAuthorizationEndpoint,
+		url.Values{"client_id": {d.ClientId}, "scope": {d.Scopes}}, &authorization)
+	if err != nil {
+		return "", fmt.Errorf("unable to start the device authorization flow: %w", err)
+	}
+	if status != http.StatusOK || authorization.DeviceCode == "" {
+		return "", fmt.Errorf("unable to start the device authorization flow: status %d", status)
+	}
+	verificationUrl := authorization.VerificationUriComplete
+	if verificationUrl == "" {
+		verificationUrl = authorization.VerificationUri
+	}
+	d.OnDeviceCode(verificationUrl, authorization.UserCode)
+
+	// Poll until the user signs in, at the interval the identity provider asks for
+	interval := time.Duration(max(authorization.Interval, 5)) * time.Second
+	deadline := time.Now().Add(time.Duration(authorization.ExpiresIn) * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(interval):
+		}
+		var token tokenResponse
+		_, err := d.postForm(ctx, discovery.TokenEndpoint, url.Values{
+			"grant_type":  {deviceCodeGrantType},
+			"device_code": {authorization.DeviceCode},
+			"client_id":   {d.ClientId},
+		}, &token)
+		if err != nil {
+			return "", fmt.Errorf("unable to get a token from %s: %w", discovery.TokenEndpoint, err)
+		}
+		switch token.Error {
+		case "":
+			if token.IdToken != "" {
+				return token.IdToken, nil
+			}
+			return token.AccessToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		default:
+			return "", fmt.Errorf("sign-in failed: %s %s", token.Error, token.ErrorDescription)
+		}
+	}
+	return "", errors.New("sign-in timed out, the device code expired before it was entered")
+}
+
+// exchange exchanges the identity provider's token for a Databricks OAuth token. The Databricks account
+// needs a federation policy that trusts the identity provider.
+func (d *DeviceFlow) exchange(ctx context.Context, dbxHost string, idpToken string) (cachedDbxToken, error) {
+	tokenUrl := strings.TrimSuffix(dbxHost, "/") + "/oidc/v1/token"
+	var token tokenResponse
+	status, err := d.postForm(ctx, tokenUrl, url.Values{
+		"grant_type":         {tokenExchangeGrantType},
+		"subject_token":      {idpToken},
+		"subject_token_type": {jwtTokenType},
+		"scope":              {"all-apis"},
+	}, &token)
+	if err != nil {
+		return cachedDbxToken{}, fmt.Errorf("unable to exchange the identity provider token at %s: %w", tokenUrl, err)
+	}
+	if status != http.StatusOK || token.AccessToken == "" {
+		return cachedDbxToken{}, fmt.Errorf("Databricks rejected the identity provider token (status %d: %s %s), "+
+			"check that the account has a federation policy for %s", status, token.Error, token.ErrorDescription, d.Issuer)
+	}
+	return cachedDbxToken{
+		AccessToken: token.AccessToken,
+		TokenType:   token.TokenType,
+		Expiry:      time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// postForm posts a form, decodes the JSON response into result, and returns the HTTP status.
+// OAuth endpoints return errors as JSON, so the response is decoded whatever the status.
+func (d *DeviceFlow) postForm(ctx context.Context, endpoint string, form url.Values, result any) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer hl.CloseBody(resp.Body)
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return resp.StatusCode, fmt.Errorf("unable to parse response (status %d): %w", resp.StatusCode, err)
+	}
+	return resp.StatusCode, nil
+}
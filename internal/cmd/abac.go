@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var abacWarehouseId string
+
+var abacCmd = &cobra.Command{
+	Use:   "abac",
+	Short: "Blocks models that HiddenLayer found unsafe with Unity Catalog tags and grants",
+	Long: "Tags the models that HiddenLayer found unsafe with the hl_unsafe governed tag, and revokes the grants to " +
+		"use them from everyone but dbx_abac_group. The tag statements run on a SQL warehouse.",
+}
+
+var abacApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Tags the models with detections as unsafe, and blocks them",
+	Long: "Tags the models whose scanned versions have detections as hl_unsafe, and revokes EXECUTE and ALL " +
+		"PRIVILEGES on them from everyone but dbx_abac_group, recording the revoked grants in dbx_workspace_dir. " +
+		"Untags the models whose scanned versions are all safe, and restores their revoked grants. Unity Catalog has " +
+		"no deny, so principals granted EXECUTE on a model's schema or catalog can still use it; they're listed. " +
+		"Re-run it after new scans.",
+	Example: "  hldbx abac apply --warehouse-id 1234567890abcdef",
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		if err := dbx.ValidateAbacGroup(config); err != nil {
+			utils.Fatal(err)
+		}
+		dbxClient := configDbxCreds(config)
+		report, err := dbx.ApplyAbacPolicies(context.Background(), dbxClient, config, abacWarehouseId)
+		if err != nil {
+			utils.Fatalf("Error blocking unsafe models: %v", err)
+		}
+		for _, model := range report.Blocked {
+			fmt.Printf("Blocked model %s for all but %s\n", model, config.DbxAbacGroup)
+		}
+		for _, model := range report.Unblocked {
+			fmt.Printf("Model %s is safe, not blocked\n", model)
+		}
+		for _, model := range slices.Sorted(maps.Keys(report.Inherited)) {
+			slog.Warn("Principals can still use a blocked model through grants on its schema or catalog", "model", model,
+				"principals", strings.Join(report.Inherited[model], ", "))
+		}
+		fmt.Printf("%d model(s) blocked, %d unblocked\n", len(report.Blocked), len(report.Unblocked))
+	},
+}
+
+var abacRevertCmd = &cobra.Command{
+	Use:     "revert",
+	Short:   "Untags the models, and restores their revoked grants",
+	Example: "  hldbx abac revert --warehouse-id 1234567890abcdef",
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		report, err := dbx.RevertAbacPolicies(context.Background(), dbxClient, config, abacWarehouseId)
+		if err != nil {
+			utils.Fatalf("Error unblocking models: %v", err)
+		}
+		fmt.Printf("%d model(s) untagged, and their revoked grants restored\n", len(report.Unblocked))
+	},
+}
+
+func init() {
+	for _, command := range []*cobra.Command{abacApplyCmd, abacRevertCmd} {
+		command.Flags().StringVar(&abacWarehouseId, "warehouse-id", "", "SQL warehouse to run the tag statements on (required)")
+		_ = command.MarkFlagRequired("warehouse-id")
+		abacCmd.AddCommand(command)
+	}
+	rootCmd.AddCommand(abacCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var adviseDays int
+var adviseWarehouseId string
+
+var adviseCmd = &cobra.Command{
+	Use:   "advise",
+	Short: "Recommends a monitoring schedule that fits when models are registered",
+	Long: "Compares when model versions are registered in the monitored schemas with the monitoring job's schedule, " +
+		"and reports how long new versions wait to be scanned, e.g. when most are registered at 9am but scans run " +
+		"at noon. If shifting the schedule would shorten the wait significantly, recommends a better one. With " +
+		"--warehouse-id, registration times come from the system.access.audit table, which also has deleted versions; " +
+		"otherwise they come from the model registry.",
+	Example: "  hldbx advise --days 60 --warehouse-id 1234567890abcdef",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if adviseDays < 1 {
+			utils.Fatal("--days must be at least 1")
+		}
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		if len(config.DbxSchemas) == 0 {
+			utils.Fatal("No schemas to analyze, add dbx_schemas to the configuration file")
+		}
+		report, err := dbx.AdviseScanWindow(context.Background(), dbxClient, config, adviseDays, adviseWarehouseId)
+		if err != nil {
+			utils.Fatalf("Error analyzing the scanning window: %v", err)
+		}
+
+		if outputFormat == outputJson {
+			printJson(report)
+			return
+		}
+		fmt.Printf("Schedule: %s (every %s, UTC)\n", report.Schedule, report.Interval)
+		fmt.Printf("Model versions registered in the last %d day(s), from %s: %d\n", report.Days, report.Source, report.Registrations)
+		if report.Registrations == 0 {
+			fmt.Println("No registrations to analyze")
+			return
+		}
+		peak, share := report.PeakHour()
+		fmt.Printf("Busiest hour: %02d:00-%02d:00 UTC, with %.0f%% of registrations\n", peak, (peak+1)%24, share*100)
+		fmt.Printf("Wait until scanned: mean %s, 90th percentile %s, max %s\n", report.MeanExposure.Round(time.Minute),
+			report.P90Exposure.Round(time.Minute), report.MaxExposure.Round(time.Minute))
+		if report.Recommended == "" {
+			fmt.Println("The schedule fits when models are registered, no change recommended")
+			return
+		}
+		fmt.Printf("Recommended schedule: %s, which would cut the mean wait to %s\n", report.Recommended,
+			report.RecommendedMeanExposure.Round(time.Minute))
+		fmt.Println("To use it, set dbx_polling_quartz_cron in the configuration file and re-run hldbx autoscan")
+	},
+}
+
+func init() {
+	adviseCmd.Flags().IntVar(&adviseDays, "days", 30, "number of days of registrations to analyze")
+	adviseCmd.Flags().StringVar(&adviseWarehouseId, "warehouse-id", "", "SQL warehouse to query the audit system table with")
+	supportJsonOutput(adviseCmd)
+	rootCmd.AddCommand(adviseCmd)
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var applyDryRun bool
+var applyWarehouseId string
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Updates the installed monitoring job to match the configuration file",
+	Long: "Updates the parameters and schedule of the installed monitoring job from the configuration file, in place, " +
+		"so the job keeps its ID, run history, and permissions. The new settings take effect on the job's next run. " +
+		"The monitored schemas are changed with hldbx schemas, and the compute and credentials with hldbx autoscan. " +
+		"With dbx_owner_groups, the monitored schemas are also synced to dbx_schemas and the schemas that the groups " +
+		"own, which are looked up in information_schema through the SQL warehouse of --warehouse-id.",
+	Example: "  hldbx config set dbx_max_active_scan_jobs 20\n  hldbx apply --dry-run\n  hldbx apply\n" +
+		"  hldbx apply --warehouse-id 1234567890abcdef",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		if err := validateSettings(config); err != nil {
+			utils.Fatalf("Invalid configuration: %v", err)
+		}
+		if len(config.DbxOwnerGroups) > 0 && applyWarehouseId == "" {
+			utils.Fatal("Set --warehouse-id to look up the schemas that dbx_owner_groups own")
+		}
+		dbxClient := configDbxCreds(config)
+		ctx := context.Background()
+		schemasChanged := 0
+		if len(config.DbxOwnerGroups) > 0 {
+			schemasChanged = applyOwnedSchemas(ctx, dbxClient, config)
+		}
+		changes, err := dbx.ApplyConfig(ctx, dbxClient, config, applyDryRun)
+		if err != nil {
+			utils.Fatalf("Error applying the configuration: %v", err)
+		}
+		if !applyDryRun {
+			updateManifest(ctx, dbxClient, config, cmd.CommandPath())
+		}
+		if len(changes) == 0 {
+			if schemasChanged == 0 {
+				fmt.Println("The monitoring job already matches the configuration")
+			}
+			return
+		}
+		for _, change := range changes {
+			if change.Added {
+				fmt.Printf("  %s: added as %q\n", change.Name, change.To)
+				continue
+			}
+			fmt.Printf("  %s: %q -> %q\n", change.Name, change.From, change.To)
+		}
+		if applyDryRun {
+			fmt.Printf("%d setting(s) would change, run without --dry-run to apply them\n", len(changes))
+		} else {
+			fmt.Printf("Updated %d setting(s) of the monitoring job\n", len(changes))
+		}
+	},
+}
+
+// applyOwnedSchemas syncs the monitored schemas to dbx_schemas and the schemas that dbx_owner_groups own, and
+// prints the schemas added and removed. Returns how many there were.
+func applyOwnedSchemas(ctx context.Context, dbxClient *databricks.WorkspaceClient, config *utils.Config) int {
+	ownerSync, err := dbx.SyncOwnedSchemas(ctx, dbxClient, config, applyWarehouseId, applyDryRun)
+	for _, schema := range ownerSync.Added {
+		fmt.Printf("  schema %s.%s: added\n", schema.Catalog, schema.Schema)
+	}
+	for _, schema := range ownerSync.Removed {
+		fmt.Printf("  schema %s.%s: removed\n", schema.Catalog, schema.Schema)
+	}
+	if err != nil {
+		utils.Fatalf("Error syncing the schemas of %s: %v", strings.Join(config.DbxOwnerGroups, ", "), err)
+	}
+	changed := len(ownerSync.Added) + len(ownerSync.Removed)
+	switch {
+	case changed == 0:
+		fmt.Printf("The monitored schemas already match dbx_schemas and the %d schema(s) that %s own\n",
+			len(ownerSync.Owned), strings.Join(config.DbxOwnerGroups, ", "))
+	case applyDryRun:
+		fmt.Printf("%d monitored schema(s) would change, run without --dry-run to change them\n", changed)
+	default:
+		fmt.Printf("Changed %d monitored schema(s)\n", changed)
+	}
+	return changed
+}
+
+func init() {
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "only print the settings that would change")
+	applyCmd.Flags().StringVar(&applyWarehouseId, "warehouse-id", "", "SQL warehouse to look up the schemas of dbx_owner_groups with")
+	rootCmd.AddCommand(applyCmd)
+}
@@ -6,52 +6,162 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/url"
 	"os"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/databricks/databricks-sdk-go"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/broker"
 	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
-	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbxapi"
 	"github.com/hiddenlayer-engineering/hl-databricks/internal/hl"
 	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
-	"github.com/reugn/go-quartz/quartz"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
 )
 
 var autoscanCmd = &cobra.Command{
-	Use:   "autoscan",
+	Use:   "autoscan [workspace URL]",
 	Short: "Sets up automated model scanning in Databricks",
 	Long:  "Sets up automated model scanning in DataBricks, using the HiddenLayer Model Scanner.",
+	Example: "  hldbx autoscan https://adb-1234567890123456.7.azuredatabricks.net\n" +
+		"  # with the secrets in HLDBX_DBX_TOKEN and HLDBX_HL_CLIENT_SECRET\n" +
+		"  hldbx autoscan --non-interactive --dbx-host https://adb-1234567890123456.7.azuredatabricks.net \\\n" +
+		"    --cluster-id 0123-456789-abcdefgh --schema main.models --cron \"0 0 */12 * * ?\" \\\n" +
+		"    --hl-region us --hl-client-id \"$HL_CLIENT_ID\" --hl-api-key-name hl_api_key",
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		config := readConfig() // Read the configuration file, if it exists
+		var progress dbx.ProgressFunc
+		if outputFormat == outputJson {
+			progress = jsonProgress()
+		}
+		config := readConfig()                       // Read the configuration file, if it exists
+		args = applyAutoscanFlags(cmd, config, args) // Flags take precedence over the configuration file
+		useDbxHostArg(config, args)                  // The workspace URL argument takes precedence over the configuration file
+		applyAutoscanToken(config)
+		if autoscanSchemasFile != "" {
+			// The schema list takes precedence over the configuration file too
+			config.DbxSchemas = readSchemasFile(autoscanSchemasFile)
+		}
 		// Get Databricks credentials from the user, if needed (not already in the config)
 		dbxClient := configDbxCreds(config)
+		requireUnityCatalog(dbxClient)        // Models are only monitored in Unity Catalog schemas
 		configDbxResources(config, dbxClient) // Get Databricks resources from the user, if needed
 		configHlCreds(config)                 // Get HiddenLayer credentials from the user, if needed
-		dbx.Autoscan(context.Background(), config)
+		validateEgressSettings(config)        // Egress settings are optional and only read from the config file
+		if err := config.Validate(); err != nil {
+			utils.Fatalf("Invalid configuration: %v", err)
+		}
+		if err := dbx.ValidateFindingsSink(config); err != nil {
+			utils.Fatalf("Invalid findings sink settings: %v", err)
+		}
+		if err := dbx.ValidateArtifactSources(config); err != nil {
+			utils.Fatalf("Invalid artifact sources: %v", err)
+		}
+		if err := dbx.ValidateScanTrigger(config); err != nil {
+			utils.Fatalf("Invalid scan trigger settings: %v", err)
+		}
+		if err := dbx.ValidateScanners(config); err != nil {
+			utils.Fatalf("Invalid scanner settings: %v", err)
+		}
+		authenticateScanners(config)
+		if err := dbx.ValidateSecretsGroup(config); err != nil {
+			utils.Fatalf("Invalid secrets group settings: %v", err)
+		}
+		if err := dbx.ValidateScanMetadata(config); err != nil {
+			utils.Fatalf("Invalid scan metadata: %v", err)
+		}
+		if err := dbx.ValidateBudgetPolicy(config); err != nil {
+			utils.Fatalf("Invalid budget policy settings: %v", err)
+		}
+		if err := dbx.ValidateNaming(config); err != nil {
+			utils.Fatalf("Invalid naming settings: %v", err)
+		}
+		if err := dbx.ValidateJobCluster(config); err != nil {
+			utils.Fatalf("Invalid job cluster settings: %v", err)
+		}
+		if err := dbx.ValidateRetention(config); err != nil {
+			utils.Fatalf("Invalid retention settings: %v", err)
+		}
+		dbx.Autoscan(context.Background(), config, cmd.CommandPath(), !autoscanSkipValidation, progress)
+		if autoscanRunNow {
+			runMonitorJobNow(dbxClient, config)
+		}
 	},
 }
 
+var autoscanRunNow bool
+var autoscanSchemasFile string
+var autoscanSkipValidation bool
+
 func init() {
+	autoscanCmd.Flags().BoolVar(&autoscanRunNow, "run-now", false, "run the monitoring job immediately, instead of waiting for its schedule")
+	autoscanCmd.Flags().StringVar(&autoscanSchemasFile, "schemas-file", "",
+		"file listing the schemas to monitor, one <catalog>.<schema> or <catalog>,<schema> per line, or - for stdin")
+	autoscanCmd.Flags().BoolVar(&autoscanSkipValidation, "skip-validation", false,
+		"don't run the validation notebook on the cluster before creating the monitoring job")
+	supportJsonOutput(autoscanCmd)
+	addClusterReadinessFlags(autoscanCmd)
+	addAutoscanSettingFlags(autoscanCmd)
 	rootCmd.AddCommand(autoscanCmd)
 }
 
+// jsonProgress returns what streams progress events to stdout, one JSON object per line for wrapper tools to read.
+func jsonProgress() dbx.ProgressFunc {
+	encoder := json.NewEncoder(jsonOut)
+	return func(event dbx.ProgressEvent) {
+		_ = encoder.Encode(event)
+	}
+}
+
+// runMonitorJobNow waits for the cluster to be running, unless jobs run on serverless compute or job clusters,
+// then triggers an immediate run of the monitoring job.
+func runMonitorJobNow(dbxClient *databricks.WorkspaceClient, config *utils.Config) *dbx.MonitorRun {
+	ctx := context.Background()
+	if config.UsesExistingCluster() {
+		if err := waitForCluster(ctx, dbxClient, config.DbxClusterId); err != nil {
+			utils.Fatalf("Cluster is not ready for an immediate run: %v", err)
+		}
+	}
+	run, err := dbx.RunMonitorJobNow(ctx, dbxClient, config)
+	if err != nil {
+		utils.Fatalf("Error running the monitoring job: %v", err)
+	}
+	fmt.Printf("Started a monitoring job run: %s\n", run.Url)
+	return run
+}
+
 func GetOAuthToken(dbxhost string) string {
 	usersHomeDir, err := os.UserHomeDir()
 	if err != nil {
 		fmt.Println("Error getting user home directory")
 		usersHomeDir = ""
 	}
-	usersDatabrickTokenCache := usersHomeDir + "/.databricks/token-cache.json"
-	tokenCachePath := inputStringValue("Please enter the full path to your Databricks token cache (default: ~/.databricks/token-cache.json)", false, true, usersDatabrickTokenCache)
+	defaultTokenCache := usersHomeDir + "/.databricks/token-cache.json"
+	// A profile's own token cache takes precedence, so operators sharing a machine don't use each other's tokens
+	if profileTokenCache, err := utils.TokenCachePath(); err == nil {
+		if _, err := os.Stat(profileTokenCache); err == nil {
+			defaultTokenCache = profileTokenCache
+		}
+	}
+	var choices []choice
+	for _, path := range tokenCachePaths() {
+		if slices.Contains(tokenCacheHosts(path), dbxhost) {
+			choices = append(choices, choice{value: path, label: path})
+		}
+	}
+	tokenCachePath, ok := inputChoice("the Databricks token cache to sign in with", choices, "Another token cache file", defaultTokenCache)
+	if !ok {
+		tokenCachePath = inputStringValue(fmt.Sprintf("Please enter the full path to your Databricks token cache (default: %s)", defaultTokenCache), false, true, defaultTokenCache)
+	}
 	token := GetOAuthTokenFromFile(tokenCachePath, dbxhost)
 	return token
 }
@@ -83,6 +193,25 @@ func GetOAuthTokenFromFile(path string, dbxHost string) string {
 	return ""
 }
 
+// brokerToken gets a Databricks token from the credential broker in the configuration.
+// Returns "" if that fails, so the user is prompted for credentials instead.
+func brokerToken(config *utils.Config) string {
+	credentialBroker, err := broker.New(config, func(verificationUrl string, userCode string) {
+		fmt.Printf("To sign in to Databricks, open %s on any device and enter the code %s\n", verificationUrl, userCode)
+	})
+	if err != nil {
+		utils.Printf("Error setting up the credential broker: %v\n", err)
+		return ""
+	}
+	token, err := credentialBroker.Token(context.Background(), config.DbxHost)
+	if err != nil {
+		utils.Printf("Unable to get a Databricks token with %s: %v\n", credentialBroker.Name(), err)
+		return ""
+	}
+	fmt.Printf("Using OAuth Token from %s\n", credentialBroker.Name())
+	return token
+}
+
 // configDbxCreds checks if the Databricks credentials were read from the configuration file.
 // If not, then get them from the user and write them into the in-memory config.
 func configDbxCreds(config *utils.Config) *databricks.WorkspaceClient {
@@ -91,15 +220,41 @@ func configDbxCreds(config *utils.Config) *databricks.WorkspaceClient {
 	// If the Databricks host and token are not in the configuration file, get them from the user.
 	// Check that we can authenticate successfully. If not, get new credentials from the user.
 	// Keep going until authentication works.
+	if utils.InDatabricks() && config.DbxHost == "" && config.DbxToken == "" {
+		// Run by the bootstrap notebook, which passes the workspace and the token of its context
+		config.DbxHost, config.DbxToken = os.Getenv(utils.DatabricksHostEnv), os.Getenv(utils.DatabricksTokenEnv)
+	}
+	// A token entered at the prompt can be stored in the OS keyring, and is read from it on later runs
+	fromKeyring, prompted := false, false
 	for {
+		if config.DbxHost != "" && config.DbxToken == "" && config.DbxOidcIssuer != "" {
+			// Sign in through the configured identity provider, without prompting for a token
+			config.DbxToken = brokerToken(config)
+		}
+		if config.DbxHost != "" && config.DbxToken == "" && !fromKeyring {
+			config.DbxToken = keyringToken(config.DbxHost)
+			fromKeyring = config.DbxToken != ""
+		}
+		if config.DbxHost == "" || config.DbxToken == "" {
+			// Offer the workspaces that the Databricks CLI is already signed in to
+			if host, token := chooseDetectedWorkspace(config.DbxHost); token != "" {
+				config.DbxHost, config.DbxToken = host, token
+			}
+		}
 		if config.DbxHost == "" || config.DbxToken == "" {
 			config.DbxHost = inputDbxHost()
 			if config.DbxHost != "" {
+				// Use the token stored in the keyring for the entered workspace, if there is one
+				config.DbxToken = keyringToken(config.DbxHost)
+				fromKeyring = config.DbxToken != ""
+			}
+			if config.DbxHost != "" && config.DbxToken == "" {
 				config.DbxToken = GetOAuthToken(config.DbxHost)
 
 				if config.DbxToken == "" {
 					fmt.Println("No OAuth Token found falling back to PAT")
-					config.DbxToken = inputStringValue("Please enter Databricks personal token or sign in with Databrick's CLI and try again", true, false)
+					config.DbxToken = inputDbxToken(config.DbxHost)
+					prompted = true
 				} else {
 					fmt.Println("Using OAuth Token from file")
 				}
@@ -113,7 +268,8 @@ func configDbxCreds(config *utils.Config) *databricks.WorkspaceClient {
 				config.DbxToken = token
 			} else {
 				fmt.Println("No OAuth Token found falling back to PAT")
-				config.DbxToken = inputStringValue("Please enter Databricks personal token or sign in with Databrick's CLI and try again", true, false)
+				config.DbxToken = inputDbxToken(config.DbxHost)
+				prompted = true
 			}
 		}
 		if config.DbxHost == "" || config.DbxToken == "" {
@@ -123,13 +279,22 @@ func configDbxCreds(config *utils.Config) *databricks.WorkspaceClient {
 			config.DbxToken = ""
 			continue
 		}
+		utils.RegisterConfigSecrets(config)
 		var err error
 		dbxClient, err = dbx.Auth(config.DbxHost, config.DbxToken)
 		if err == nil {
 			fmt.Println("Successfully authenticated to Databricks at " + config.DbxHost)
+			if prompted {
+				offerKeyring(config.DbxHost, config.DbxToken)
+			}
 			break
+		} else if utils.InDatabricks() || nonInteractive {
+			utils.Fatalf("Error authenticating to Databricks: %v", err)
 		} else {
-			fmt.Printf("Error authenticating to Databricks: %v. Please try again.\n", err)
+			if fromKeyring {
+				forgetKeyringToken(config.DbxHost)
+			}
+			utils.Printf("Error authenticating to Databricks: %v. Please try again.\n", err)
 			config.DbxHost = ""
 			config.DbxToken = ""
 		}
@@ -138,15 +303,26 @@ func configDbxCreds(config *utils.Config) *databricks.WorkspaceClient {
 	return dbxClient
 }
 
-func retrieveSchemaFromCommandLine(dbxClient *databricks.WorkspaceClient) utils.CatalogSchemaConfig {
+// retrieveSchemaFromCommandLine prompts for a schema to monitor, offering the detected schemas that aren't chosen
+// yet, until the user enters one that exists. Returns an empty schema if the user presses Enter instead.
+func retrieveSchemaFromCommandLine(dbxClient *databricks.WorkspaceClient, detected []dbx.SchemaModels, chosen []utils.CatalogSchemaConfig) utils.CatalogSchemaConfig {
+	choices := schemaChoices(detected, chosen)
 	for {
 		var config utils.CatalogSchemaConfig
-		config.Catalog = inputStringValue("Catalog in Databricks Unity Catalog", false, false)
-		if config.Catalog == "" {
-			// intentional user exit
-			return utils.CatalogSchemaConfig{}
+		if name, ok := inputChoice("a schema with models to scan", choices, "Another schema", ""); ok {
+			if name == "" {
+				// intentional user exit
+				return utils.CatalogSchemaConfig{}
+			}
+			config.Catalog, config.Schema, _ = strings.Cut(name, ".")
+		} else {
+			config.Catalog = inputStringValue("Catalog in Databricks Unity Catalog", false, false)
+			if config.Catalog == "" {
+				// intentional user exit
+				return utils.CatalogSchemaConfig{}
+			}
+			config.Schema = inputStringValue("Schema with models to scan, within the catalog", false, false)
 		}
-		config.Schema = inputStringValue("Schema with models to scan, within the catalog", false, false)
 
 		configOk := confirmSchema(config, dbxClient)
 		if configOk {
@@ -157,15 +333,19 @@ func retrieveSchemaFromCommandLine(dbxClient *databricks.WorkspaceClient) utils.
 	}
 }
 
-func retrieveClusterFromCommandLine(dbxClient *databricks.WorkspaceClient) string {
+func retrieveClusterFromCommandLine(config *utils.Config, dbxClient *databricks.WorkspaceClient) string {
+	choices := clusterChoices(dbxClient)
 	for {
-		clusterId := inputStringValue("Databricks cluster ID", false, false)
+		clusterId, ok := inputChoice("the Databricks cluster to run the jobs on", choices, "Another cluster ID", "")
+		if !ok {
+			clusterId = inputStringValue("Databricks cluster ID", false, false)
+		}
 		if clusterId == "" {
 			// intentional user exit
 			return ""
 		}
 
-		clusterOk := confirmCluster(clusterId, dbxClient)
+		clusterOk := confirmCluster(config, clusterId, dbxClient)
 		if clusterOk {
 			return clusterId
 		} else {
@@ -175,47 +355,198 @@ func retrieveClusterFromCommandLine(dbxClient *databricks.WorkspaceClient) strin
 }
 
 func confirmSchema(config utils.CatalogSchemaConfig, dbxClient *databricks.WorkspaceClient) bool {
-	if schemaExists := dbx.SchemaExists(dbxClient, config.Catalog, config.Schema); schemaExists {
+	status, err := dbx.CheckSchema(context.Background(), dbxClient, config.Catalog, config.Schema)
+	if err != nil {
+		utils.Fatal(err)
+	}
+	switch status {
+	case dbx.SchemaFound:
 		fmt.Printf("Confirming schema '%s' in catalog '%s' found in Unity Catalog\n", config.Schema, config.Catalog)
 		return true
-	} else {
+	case dbx.SchemaForbidden:
+		return resolveForbiddenSchema(config, dbxClient)
+	default:
 		fmt.Printf("Schema %s in catalog %s not found in Unity Catalog. Please try again.\n", config.Schema, config.Catalog)
 		return false
 	}
 }
 
-func confirmCluster(clusterId string, dbxClient *databricks.WorkspaceClient) bool {
-	if clusterExists := dbx.ClusterExists(dbxClient, clusterId); clusterExists {
-		fmt.Printf("Confirming cluster with ID=%s found in Databricks\n", clusterId)
-		return true
-	} else {
+// resolveForbiddenSchema explains that the Databricks token can't use the schema, then lets the user continue with
+// the schema anyway (the job may run as an identity that has access), re-check it after fixing the grants, or skip it.
+// Returns true if the schema should be kept.
+func resolveForbiddenSchema(config utils.CatalogSchemaConfig, dbxClient *databricks.WorkspaceClient) bool {
+	for {
+		fmt.Printf("The Databricks token does not have permission to use schema '%s' in catalog '%s'.\n", config.Schema, config.Catalog)
+		fmt.Printf("It needs USE CATALOG on %s and USE SCHEMA on %s.%s, e.g. GRANT USE SCHEMA ON SCHEMA %s.%s TO `<user>`\n",
+			config.Catalog, config.Catalog, config.Schema, config.Catalog, config.Schema)
+		choice := inputStringValue("c to continue with this schema anyway, r to re-check after fixing the grants, or s to skip it (default: s)", false, false, "s")
+		switch strings.ToLower(choice) {
+		case "c":
+			return true
+		case "r":
+			status, err := dbx.CheckSchema(context.Background(), dbxClient, config.Catalog, config.Schema)
+			if err != nil {
+				utils.Fatal(err)
+			}
+			switch status {
+			case dbx.SchemaFound:
+				fmt.Printf("Confirming schema '%s' in catalog '%s' found in Unity Catalog\n", config.Schema, config.Catalog)
+				return true
+			case dbx.SchemaMissing:
+				fmt.Printf("Schema %s in catalog %s not found in Unity Catalog.\n", config.Schema, config.Catalog)
+				return false
+			}
+		case "s":
+			return false
+		default:
+			fmt.Println("Invalid choice. Please try again.")
+		}
+	}
+}
+
+// confirmSchemas validates all the schemas concurrently, showing progress as each check finishes,
+// then prints a summary table of valid and invalid schemas.
+func confirmSchemas(schemas []utils.CatalogSchemaConfig, dbxClient *databricks.WorkspaceClient) []dbx.SchemaValidation {
+	fmt.Printf("Validating %d schema(s) in Unity Catalog...\n", len(schemas))
+	results, err := dbx.ValidateSchemas(context.Background(), dbxClient, schemas, func(done int, result dbx.SchemaValidation) {
+		fmt.Printf("[%d/%d] Checked schema '%s' in catalog '%s'\n", done, len(schemas), result.Schema.Schema, result.Schema.Catalog)
+	})
+	if err != nil {
+		utils.Fatalf("Error validating the schemas: %v", err)
+	}
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "CATALOG\tSCHEMA\tSTATUS")
+	for _, result := range results {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", result.Schema.Catalog, result.Schema.Schema, result.Status)
+	}
+	table.Flush()
+
+	counts := map[dbx.SchemaStatus]int{}
+	for _, result := range results {
+		counts[result.Status]++
+	}
+	fmt.Printf("%d schema(s) %s, %d %s, %d %s\n", counts[dbx.SchemaFound], dbx.SchemaFound,
+		counts[dbx.SchemaForbidden], dbx.SchemaForbidden, counts[dbx.SchemaMissing], dbx.SchemaMissing)
+	return results
+}
+
+// keepValidSchemas returns the validated schemas to monitor, without asking: those found, and those the Databricks
+// token isn't allowed to use, since the job may run as an identity that is. Schemas that don't exist are skipped.
+func keepValidSchemas(results []dbx.SchemaValidation) []utils.CatalogSchemaConfig {
+	var schemas []utils.CatalogSchemaConfig
+	for _, result := range results {
+		switch result.Status {
+		case dbx.SchemaFound:
+			schemas = append(schemas, result.Schema)
+		case dbx.SchemaForbidden:
+			slog.Warn("Keeping a schema that the Databricks token lacks USE CATALOG or USE SCHEMA on",
+				"schema", result.Schema.Catalog+"."+result.Schema.Schema)
+			schemas = append(schemas, result.Schema)
+		default:
+			fmt.Printf("Skipping schema %s.%s, which was not found\n", result.Schema.Catalog, result.Schema.Schema)
+		}
+	}
+	if len(schemas) == 0 {
+		utils.Fatal("No schemas to monitor, exiting")
+	}
+	fmt.Printf("Monitoring %d of %d schema(s)\n", len(schemas), len(results))
+	return schemas
+}
+
+// readSchemasFile reads the list of schemas to monitor from a file, or from stdin if the path is "-".
+func readSchemasFile(path string) []utils.CatalogSchemaConfig {
+	file := os.Stdin
+	if path != "-" {
+		var err error
+		if file, err = os.Open(path); err != nil {
+			utils.Fatalf("Unable to open the schema list: %v", err)
+		}
+		defer file.Close()
+	}
+	schemas, err := dbx.ReadSchemaList(file)
+	if err != nil {
+		utils.Fatalf("Invalid schema list %s: %v", path, err)
+	}
+	if len(schemas) == 0 {
+		utils.Fatalf("The schema list %s has no schemas", path)
+	}
+	return schemas
+}
+
+// requireUnityCatalog exits with an explanation if the workspace has no Unity Catalog metastore,
+// rather than letting every schema look missing. If that can't be determined, warn and carry on.
+func requireUnityCatalog(dbxClient *databricks.WorkspaceClient) {
+	enabled, err := dbx.CheckUnityCatalog(dbxClient)
+	if err != nil {
+		slog.Warn("Unable to check whether Unity Catalog is enabled for the workspace", "error", err)
+		return
+	}
+	if !enabled {
+		utils.Fatal("Unity Catalog is not enabled for this workspace: no metastore is assigned to it. " +
+			"HiddenLayer automated scanning monitors models registered in Unity Catalog schemas, and doesn't support " +
+			"the legacy Workspace Model Registry. Ask your Databricks account admin to assign a Unity Catalog metastore " +
+			"to the workspace, register your models in Unity Catalog, and then run hldbx again.")
+	}
+}
+
+// confirmCluster checks that the cluster exists, and carries the tags of dbx_required_cluster_tags unless
+// dbx_cluster_tag_policy is warn, and prints any warnings about it.
+func confirmCluster(config *utils.Config, clusterId string, dbxClient *databricks.WorkspaceClient) bool {
+	cluster := dbx.CheckCluster(dbxClient, clusterId)
+	if !cluster.Exists {
 		fmt.Printf("Cluster %s not found in Databricks. Please try again.\n", clusterId)
 		return false
 	}
+	if missing := dbx.MissingClusterTags(config, cluster.Tags); len(missing) > 0 {
+		if dbx.ClusterTagsEnforced(config) {
+			fmt.Printf("Cluster %s lacks the tags that dbx_required_cluster_tags requires: %s. Please try again.\n",
+				clusterId, strings.Join(missing, ", "))
+			return false
+		}
+		slog.Warn("The cluster lacks the tags that dbx_required_cluster_tags requires", "cluster_id", clusterId,
+			"missing", strings.Join(missing, ", "))
+	}
+	fmt.Printf("Confirming cluster with ID=%s found in Databricks (state: %s, access mode: %s, Unity Catalog: %t)\n",
+		clusterId, cluster.State, cluster.AccessMode(), cluster.UnityCatalogEnabled)
+	for _, warning := range clusterWarnings(config, dbxClient, cluster) {
+		slog.Warn(warning)
+	}
+	return true
 }
 
-func validateCronExpression(expression string) error {
-	// Try to parse the expression
-	_, err := quartz.NewCronTrigger(expression)
+// clusterWarnings returns the warnings about the cluster for the identity that the jobs run as.
+func clusterWarnings(config *utils.Config, dbxClient *databricks.WorkspaceClient, cluster dbx.ClusterStatus) []string {
+	runAs, err := dbx.JobsRunAs(context.Background(), dbxClient, config)
 	if err != nil {
-		return fmt.Errorf("invalid quartz cron expression: %v", err)
+		slog.Warn("Unable to tell which identity the jobs run as", "error", err)
 	}
+	return cluster.Warnings(runAs)
+}
 
-	return nil
+func validateCronExpression(expression string) error {
+	// Parse the expression the way Databricks will, so that the job isn't rejected when it is created
+	return dbx.ValidateQuartzCron(expression)
 }
 
 func configDbxResources(config *utils.Config, dbxClient *databricks.WorkspaceClient) {
 	for {
-		if config.DbxClusterId == "" {
-			clusterId := retrieveClusterFromCommandLine(dbxClient)
+		// Jobs on serverless compute or job clusters have no cluster to choose
+		if !config.UsesExistingCluster() {
+			config.DbxClusterId = ""
+		} else if config.DbxClusterId == "" {
+			clusterId := retrieveClusterFromCommandLine(config, dbxClient)
 			if clusterId == "" {
 				// intentional user exit
-				log.Fatal("No cluster to run monitoring job, exiting")
+				utils.Fatal("No cluster to run monitoring job, exiting")
 			}
 			config.DbxClusterId = clusterId
 		} else {
-			if !confirmCluster(config.DbxClusterId, dbxClient) {
-				fmt.Println("Cluster not found in Databricks, please provide a valid cluster ID")
+			if !confirmCluster(config, config.DbxClusterId, dbxClient) {
+				if nonInteractive {
+					utils.Fatalf("Cluster %s can't run the jobs", config.DbxClusterId)
+				}
+				fmt.Println("Please provide another cluster ID")
 				config.DbxClusterId = ""
 				continue
 			}
@@ -225,11 +556,16 @@ func configDbxResources(config *utils.Config, dbxClient *databricks.WorkspaceCli
 		// Get the Databricks service principal to run the job as.
 		// This is optional, so only prompt if it's not already in the configuration.
 		if config.DbxRunAs == "" {
-			config.DbxRunAs = inputStringValue("Service principal application ID to run the job as (optional)", false, true)
+			runAs, ok := inputChoice("the service principal to run the job as, or press Enter for none",
+				servicePrincipalChoices(dbxClient), "Another application ID", "")
+			if !ok {
+				runAs = inputStringValue("Service principal application ID to run the job as (optional)", false, true)
+			}
+			config.DbxRunAs = runAs
 			// Check that the service principal exists in Databricks. If not, keep asking until it does or a blank value is entered.
 			for config.DbxRunAs != "" {
 				fmt.Println("Checking service principal in Databricks..." + config.DbxRunAs)
-				if servicePrincipalExists := dbxapi.ServicePrincipalExists(config.DbxRunAs, config.DbxHost, config.DbxToken); servicePrincipalExists {
+				if servicePrincipalExists := dbx.ServicePrincipalExists(dbxClient.ServicePrincipals, config.DbxRunAs); servicePrincipalExists {
 					fmt.Printf("Confirming service principal '%s' found in Databricks\n", config.DbxRunAs)
 					break
 				} else {
@@ -238,7 +574,10 @@ func configDbxResources(config *utils.Config, dbxClient *databricks.WorkspaceCli
 				}
 			}
 		} else {
-			if !dbxapi.ServicePrincipalExists(config.DbxRunAs, config.DbxHost, config.DbxToken) {
+			if !dbx.ServicePrincipalExists(dbxClient.ServicePrincipals, config.DbxRunAs) {
+				if nonInteractive {
+					utils.Fatalf("Service principal %s not found in Databricks", config.DbxRunAs)
+				}
 				fmt.Printf("Service principal %s not found in Databricks. Please try again.\n", config.DbxRunAs)
 				config.DbxRunAs = ""
 				continue
@@ -247,27 +586,57 @@ func configDbxResources(config *utils.Config, dbxClient *databricks.WorkspaceCli
 			}
 		}
 
-		for config.DbxMaxActiveScanJobs == "" {
-			config.DbxMaxActiveScanJobs = inputStringValue("Please enter the Max Number of concurrent scan jobs (default: 10)", false, true, "10")
+		if err := config.ValidateMaxActiveScanJobs(); err != nil {
+			if nonInteractive {
+				utils.Fatalf("Invalid dbx_max_active_scan_jobs: %v", err)
+			}
+			utils.Printf("Error validating dbx_max_active_scan_jobs, please enter another: %v\n", err)
+			config.DbxMaxActiveScanJobs = 0
+		}
+		for config.DbxMaxActiveScanJobs == 0 {
+			prompt := fmt.Sprintf("the maximum number of concurrent scan jobs, %d to %d (default: %d)",
+				utils.MinMaxActiveScanJobs, utils.MaxMaxActiveScanJobs, utils.DefaultMaxActiveScanJobs)
+			value := inputStringValue(prompt, false, true, strconv.Itoa(utils.DefaultMaxActiveScanJobs))
+			n, err := strconv.Atoi(value)
+			if err != nil || n < utils.MinMaxActiveScanJobs || n > utils.MaxMaxActiveScanJobs {
+				fmt.Printf("Invalid number of scan jobs %q, please try again\n", value)
+				continue
+			}
+			config.DbxMaxActiveScanJobs = n
 		}
 
+		if config.DbxPollingQuartzCron != "" {
+			// Ask again for a schedule from the configuration file that Databricks would reject
+			if err := validateCronExpression(config.DbxPollingQuartzCron); err != nil {
+				if nonInteractive {
+					utils.Fatalf("Invalid dbx_polling_quartz_cron: %v", err)
+				}
+				utils.Printf("Error validating dbx_polling_quartz_cron, please enter another: %v\n", err)
+				config.DbxPollingQuartzCron = ""
+			}
+		}
 		for config.DbxPollingQuartzCron == "" {
-			fmt.Println("Quartz Expression format: https://www.quartz-scheduler.org/documentation/quartz-2.3.0/tutorials/crontrigger.html")
-			config.DbxPollingQuartzCron = inputStringValue("desired polling interval for the scan job in quartz cron format (default: 0 0 */12 * * ? which is 12hrs)", false, true, "0 0 */12 * * ?")
+			cron, ok := inputChoice("the polling interval of the scan job", cronChoices, "Another Quartz cron expression", cronChoices[0].value)
+			if !ok {
+				fmt.Println("Quartz Expression format: https://www.quartz-scheduler.org/documentation/quartz-2.3.0/tutorials/crontrigger.html")
+				cron = inputStringValue("desired polling interval for the scan job in quartz cron format (default: 0 0 */12 * * ? which is 12hrs)", false, true, "0 0 */12 * * ?")
+			}
+			config.DbxPollingQuartzCron = cron
 			err := validateCronExpression(config.DbxPollingQuartzCron)
 			if err != nil {
-				fmt.Printf("Error validating cron expression, please try again: %v\n", err)
+				utils.Printf("Error validating cron expression, please try again: %v\n", err)
 				config.DbxPollingQuartzCron = ""
 			}
 		}
 
 		if len(config.DbxSchemas) == 0 {
+			detected := detectSchemas(dbxClient)
 			for {
 				fmt.Println("Add a new schema to monitor, or press Enter to finish")
-				schema := retrieveSchemaFromCommandLine(dbxClient)
+				schema := retrieveSchemaFromCommandLine(dbxClient, detected, config.DbxSchemas)
 				if schema == (utils.CatalogSchemaConfig{}) {
 					if len(config.DbxSchemas) == 0 {
-						log.Fatal("No schemas to monitor, exiting")
+						utils.Fatal("No schemas to monitor, exiting")
 					}
 					// intentional user exit
 					break
@@ -278,72 +647,139 @@ func configDbxResources(config *utils.Config, dbxClient *databricks.WorkspaceCli
 			return
 		}
 
-		var validSchemas []utils.CatalogSchemaConfig
-		for _, schema := range config.DbxSchemas {
-			if !confirmSchema(schema, dbxClient) {
-				// Message indicating what the issue will have been printed already, just ask for updated config
-				replacementConfig := retrieveSchemaFromCommandLine(dbxClient)
-				if replacementConfig == (utils.CatalogSchemaConfig{}) {
-					// user wants to skip this schema, remove it
-					continue
-				} else {
-					// replace existing (bad) schema config with new (validated) one
-					validSchemas = append(validSchemas, replacementConfig)
+		if autoscanSchemasFile != "" {
+			// Too many schemas to resolve one at a time, and stdin may hold the list rather than answers
+			config.DbxSchemas = keepValidSchemas(confirmSchemas(config.DbxSchemas, dbxClient))
+			return
+		}
+		if nonInteractive {
+			// Nobody to ask for replacements, so a schema that doesn't exist is an error
+			results := confirmSchemas(config.DbxSchemas, dbxClient)
+			for _, result := range results {
+				if result.Status == dbx.SchemaMissing {
+					utils.Fatalf("Schema %s in catalog %s not found in Unity Catalog", result.Schema.Schema, result.Schema.Catalog)
 				}
-			} else {
-				validSchemas = append(validSchemas, schema)
 			}
+			config.DbxSchemas = keepValidSchemas(results)
+			return
+		}
+		var validSchemas []utils.CatalogSchemaConfig
+		var detected []dbx.SchemaModels
+		for _, result := range confirmSchemas(config.DbxSchemas, dbxClient) {
+			if result.Status == dbx.SchemaFound ||
+				(result.Status == dbx.SchemaForbidden && resolveForbiddenSchema(result.Schema, dbxClient)) {
+				validSchemas = append(validSchemas, result.Schema)
+				continue
+			}
+			// Only prompt for the schemas that failed validation
+			fmt.Printf("Enter a replacement for schema '%s' in catalog '%s', or press Enter to skip it\n", result.Schema.Schema, result.Schema.Catalog)
+			if detected == nil {
+				detected = detectSchemas(dbxClient)
+			}
+			replacementConfig := retrieveSchemaFromCommandLine(dbxClient, detected, config.DbxSchemas)
+			if replacementConfig == (utils.CatalogSchemaConfig{}) {
+				// user wants to skip this schema, remove it
+				continue
+			}
+			// replace existing (bad) schema config with new (validated) one
+			validSchemas = append(validSchemas, replacementConfig)
 		}
 		if len(validSchemas) == 0 {
-			log.Fatal("No schemas to monitor, exiting")
+			utils.Fatal("No schemas to monitor, exiting")
 		}
 		config.DbxSchemas = validSchemas
 		return
 	}
 }
 
-var regions = []string{"US", "EU", "CUSTOM"}
 var databricksSecretNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,128}$`)
 
-func retrieveHLApiUrl() (string, string, string, error) {
-	region := ""
+// retrieveHLRegion prompts for the region of the HiddenLayer API, recommending the one with the lowest latency
+// from this machine, and returns its name, or hl.CustomRegion for URLs that the user enters.
+func retrieveHLRegion(config *utils.Config) string {
+	var names []string
+	for _, region := range hl.Regions {
+		names = append(names, strings.ToUpper(region.Name))
+	}
+	names = append(names, strings.ToUpper(hl.CustomRegion))
+
+	recommended := hl.Regions[0].Name
+	tlsConfig, err := hl.TLSConfig(config.HlTlsMinVersion, config.HlTlsPins)
+	if err != nil {
+		utils.Fatalf("Invalid TLS settings: %v", err)
+	}
+	fmt.Println("Checking the latency of the HiddenLayer regions...")
+	probes := hl.ProbeRegions(tlsConfig)
+	var choices []choice
+	for _, probe := range probes {
+		label := fmt.Sprintf("%s: %s", strings.ToUpper(probe.Region.Name), probe.Latency.Round(time.Millisecond))
+		if probe.Err != nil {
+			label = fmt.Sprintf("%s: unavailable (%v)", strings.ToUpper(probe.Region.Name), probe.Err)
+		}
+		choices = append(choices, choice{value: probe.Region.Name, label: label})
+	}
+	if fastest, ok := hl.FastestRegion(probes); ok {
+		recommended = fastest.Name
+	}
+	choices = append(choices, choice{value: hl.CustomRegion, label: "CUSTOM: the URLs of another HiddenLayer instance"})
+	if region, ok := inputChoice("the region of the HiddenLayer API", choices, "", recommended); ok {
+		return region
+	}
+	for _, c := range choices[:len(probes)] {
+		utils.Printf("  %s\n", c.label)
+	}
+
 	for {
-		region = inputStringValue("Region of HiddenLayer API US/EU/CUSTOM (default: US)", false, false, "US")
-		region = cases.Upper(language.English).String(region)
-		if slices.Contains(regions, region) {
-			break
-		} else {
-			fmt.Println("Invalid region. Please try again.")
-		}
-	}
-	switch region {
-	case "US":
-		return "https://api.us.hiddenlayer.ai", "https://auth.hiddenlayer.ai", "https://console.us.hiddenlayer.ai", nil
-	case "EU":
-		return "https://api.eu.hiddenlayer.ai", "https://auth.eu.hiddenlayer.ai", "https://console.eu.hiddenlayer.ai", nil
-	case "CUSTOM":
-		apiUrl := inputStringValue("HiddenLayer API URL (default: https://api.us.hiddenlayer.ai)", false, false, "https://api.us.hiddenlayer.ai")
-		authUrl := inputStringValue("HiddenLayer Auth URL (default: https://auth.hiddenlayer.ai)", false, false, "https://auth.hiddenlayer.ai")
-		consoleUrl := inputStringValue("HiddenLayer Console URL (default: https://console.us.hiddenlayer.ai)", false, false, "https://console.us.hiddenlayer.ai")
-		return apiUrl, authUrl, consoleUrl, nil
-	default:
-		return "", "", "", fmt.Errorf("invalid region: %s", region)
+		region := inputStringValue(fmt.Sprintf("Region of HiddenLayer API %s (default: %s)", strings.Join(names, "/"),
+			strings.ToUpper(recommended)), false, false, recommended)
+		region = strings.ToLower(region)
+		if _, ok := hl.LookupRegion(region); ok || region == hl.CustomRegion {
+			return region
+		}
+		fmt.Println("Invalid region. Please try again.")
+	}
+}
+
+// applyHlRegion sets the HiddenLayer URLs of the configured SaaS region, unless URLs are configured explicitly.
+// Exit if the region is unknown.
+func applyHlRegion(config *utils.Config) {
+	if config.HlRegion == "" || strings.EqualFold(config.HlRegion, hl.CustomRegion) {
+		return
+	}
+	region, ok := hl.LookupRegion(config.HlRegion)
+	if !ok {
+		utils.Fatalf("Invalid hl_region %q, expected one of us, eu, or custom", config.HlRegion)
+	}
+	if config.HlApiUrl == "" {
+		config.HlApiUrl = region.ApiUrl
+	}
+	if config.HlAuthUrl == "" {
+		config.HlAuthUrl = region.AuthUrl
+	}
+	if config.HlConsoleUrl == "" {
+		config.HlConsoleUrl = region.ConsoleUrl
 	}
 }
 
 func configHlCreds(config *utils.Config) {
 	if config.HlApiUrl == "" {
-		apiUrl, authUrl, consoleUrl, err := retrieveHLApiUrl()
-		if err != nil {
-			log.Fatalf("Error retrieving HiddenLayer API URL: %v", err)
+		if config.HlRegion == "" {
+			config.HlRegion = retrieveHLRegion(config)
+		}
+		if config.HlRegion == hl.CustomRegion {
+			if nonInteractive {
+				utils.Fatal("Set hl_api_url, hl_auth_url, and hl_console_url in the configuration file for the custom region")
+			}
+			config.HlApiUrl = inputStringValue("HiddenLayer API URL (default: https://api.us.hiddenlayer.ai)", false, false, "https://api.us.hiddenlayer.ai")
+			config.HlAuthUrl = inputStringValue("HiddenLayer Auth URL (default: https://auth.hiddenlayer.ai)", false, false, "https://auth.hiddenlayer.ai")
+			config.HlConsoleUrl = inputStringValue("HiddenLayer Console URL (default: https://console.us.hiddenlayer.ai)", false, false, "https://console.us.hiddenlayer.ai")
+		} else {
+			applyHlRegion(config)
 		}
-		config.HlApiUrl = apiUrl
-		config.HlAuthUrl = authUrl
-		config.HlConsoleUrl = consoleUrl
 	}
 	hlApi, err := url.Parse(config.HlApiUrl)
 	if err != nil {
-		log.Fatalf("Error parsing HiddenLayer API URL: %v", err)
+		utils.Fatalf("Error parsing HiddenLayer API URL: %v", err)
 	}
 	// determine if user is configuring for an enterprise scanner i.e. not a hiddenlayer.ai API url
 	enterpriseScanner := !strings.HasSuffix(hlApi.Hostname(), ".hiddenlayer.ai")
@@ -370,6 +806,8 @@ func configHlCreds(config *utils.Config) {
 		}
 	}
 
+	utils.RegisterConfigSecrets(config)
+
 	// console url only needed if using a Saas product
 	if config.HlConsoleUrl == "" && !enterpriseScanner {
 		config.HlConsoleUrl = inputStringValue("HiddenLayer Console URL (default: https://console.us.hiddenlayer.ai", false, false, "https://console.us.hiddenlayer.ai")
@@ -377,18 +815,83 @@ func configHlCreds(config *utils.Config) {
 
 	// Validate the HiddenLayer credentials by authenticating to the HiddenLayer API (if Saas)
 	if !enterpriseScanner {
-		_, err := hl.Auth(config.HlAuthUrl, config.HlClientID, config.HlClientSecret)
+		tlsConfig, err := hl.TLSConfig(config.HlTlsMinVersion, config.HlTlsPins)
+		if err != nil {
+			utils.Fatalf("Invalid TLS settings: %v", err)
+		}
+		_, err = hl.Auth(config.HlAuthUrl, config.HlClientID, config.HlClientSecret, tlsConfig)
 		if err == nil {
 			fmt.Println("Successfully authenticated to HiddenLayer")
 		} else {
-			log.Fatalf("Error authenticating to HiddenLayer: %v", err)
+			utils.Fatalf("Error authenticating to HiddenLayer: %v", err)
 		}
 	}
 }
 
+// authenticateScanners validates the credentials of the alternative scanners that monitored schemas select,
+// by authenticating to them, like configHlCreds does for the default scanner. Exit if one fails.
+func authenticateScanners(config *utils.Config) {
+	tlsConfig, err := hl.TLSConfig(config.HlTlsMinVersion, config.HlTlsPins)
+	if err != nil {
+		utils.Fatalf("Invalid TLS settings: %v", err)
+	}
+	authenticated := map[string]bool{}
+	for _, schema := range config.DbxSchemas {
+		scanner, _ := config.Scanner(schema)
+		if scanner.Name == utils.DefaultScannerName || !scanner.UsesClientCredentials() || authenticated[scanner.Name] {
+			continue
+		}
+		if _, err := hl.Auth(scanner.AuthUrl, scanner.ClientID, scanner.ClientSecret, tlsConfig); err != nil {
+			utils.Fatalf("Error authenticating to scanner %s: %v", scanner.Name, err)
+		}
+		authenticated[scanner.Name] = true
+		fmt.Printf("Successfully authenticated to scanner %s\n", scanner.Name)
+	}
+}
+
+// validateEgressSettings checks the optional proxy, CA bundle, and TLS settings that the scan jobs use to reach
+// the HiddenLayer API. Exit if they are invalid, since the scan jobs would fail.
+func validateEgressSettings(config *utils.Config) {
+	if config.HlHttpsProxy != "" {
+		proxyUrl, err := url.Parse(config.HlHttpsProxy)
+		if err != nil || proxyUrl.Scheme == "" || proxyUrl.Host == "" {
+			utils.Fatalf("Invalid hl_https_proxy %q, expected a URL such as http://proxy.example.com:8080", config.HlHttpsProxy)
+		}
+	}
+	if config.HlCaBundlePath != "" && !strings.HasPrefix(config.HlCaBundlePath, "/Volumes/") {
+		utils.Fatalf("Invalid hl_ca_bundle_path %q, expected a file on a Unity Catalog Volume such as /Volumes/<catalog>/<schema>/<volume>/ca.pem", config.HlCaBundlePath)
+	}
+	// The scan jobs apply the TLS settings too, so check them even when hldbx doesn't connect to HiddenLayer
+	if _, err := hl.TLSConfig(config.HlTlsMinVersion, config.HlTlsPins); err != nil {
+		utils.Fatalf("Invalid TLS settings: %v", err)
+	}
+}
+
+// requireTerminal exits if hldbx runs within Databricks or with --non-interactive, where nobody can answer a prompt
+// for name.
+func requireTerminal(name string) {
+	if nonInteractive {
+		utils.Fatalf("No value for %s, which isn't prompted for with --non-interactive: set it with a flag or in the configuration file", name)
+	}
+	if utils.InDatabricks() {
+		utils.Fatalf("No value for %s, which can't be prompted for within Databricks: set it in the configuration file", name)
+	}
+}
+
 // inputStringValue prompts the user to enter a string value for a given name.
 // If hideIt is true, the input will not be echoed to the terminal.
+// With --non-interactive, or within Databricks, it never prompts: it returns the default value, or an empty value if
+// allowed, and otherwise exits.
 func inputStringValue(name string, hideIt bool, allowEmpty bool, defaultValue ...string) string {
+	if !canChoose() {
+		if len(defaultValue) > 0 {
+			return defaultValue[0]
+		}
+		if allowEmpty {
+			return ""
+		}
+		requireTerminal(name)
+	}
 	var value string
 	for {
 		var prompt string
@@ -404,8 +907,11 @@ func inputStringValue(name string, hideIt bool, allowEmpty bool, defaultValue ..
 		} else {
 			value, err = bufio.NewReader(os.Stdin).ReadString('\n')
 		}
-		if err != nil {
-			fmt.Printf("Error reading %s: %v. Please try again.\n", name, err)
+		if errors.Is(err, io.EOF) && strings.TrimSpace(value) == "" {
+			// Nothing more to read, e.g. stdin held a schema list, so asking again would never end
+			utils.Fatalf("No input for %s, stdin is closed", name)
+		} else if err != nil && !errors.Is(err, io.EOF) {
+			utils.Printf("Error reading %s: %v. Please try again.\n", name, err)
 			continue
 		}
 		value = strings.TrimSpace(value) // Remove leading/trailing whitespace
@@ -424,47 +930,84 @@ func inputStringValue(name string, hideIt bool, allowEmpty bool, defaultValue ..
 	return value
 }
 
+// inputDbxToken prompts the user for a token of the Databricks workspace, of the kinds that its cloud accepts.
+func inputDbxToken(dbxHost string) string {
+	return inputStringValue(fmt.Sprintf("Please enter %s for Databricks, or sign in with the Databricks CLI and try again",
+		dbx.TokenTypes(dbxHost)), true, false)
+}
+
+// inputDbxHost prompts the user for the Databricks workspace URL, until they enter one that is reachable.
 func inputDbxHost() string {
-	var dbxHost string
+	requireTerminal("the Databricks workspace URL")
 	for {
-		fmt.Print("Enter Databricks workspace URL [e.g., https://adb-1234567890123456.7.azuredatabricks.net]: ")
-		_, err := fmt.Scanln(&dbxHost)
-		if err != nil {
-			fmt.Printf("Error reading Databricks workspace URL: %v. Please try again.\n", err)
-			continue
-		}
-		if !strings.HasPrefix(dbxHost, "https://") {
-			fmt.Println("Databricks workspace URL must start with 'https://'. Please try again.")
+		fmt.Print("Enter Databricks workspace URL [e.g., https://adb-1234567890123456.7.azuredatabricks.net or https://dbc-a1b2c3d4-e5f6.cloud.databricks.com]: ")
+		var input string
+		_, err := fmt.Scanln(&input)
+		if errors.Is(err, io.EOF) {
+			utils.Fatal("No input for the Databricks workspace URL, stdin is closed")
+		} else if err != nil {
+			utils.Printf("Error reading Databricks workspace URL: %v. Please try again.\n", err)
 			continue
 		}
-		dbxHost = strings.TrimSuffix(dbxHost, "/") // Remove trailing slash if present
-		if !strings.HasSuffix(dbxHost, "azuredatabricks.net") && !strings.HasSuffix(dbxHost, "databricks.com") {
-			fmt.Println("Databricks workspace URL must end with 'azuredatabricks.net' or 'databricks.com'. Please try again.")
+		dbxHost, err := checkDbxHost(input)
+		if err != nil {
+			fmt.Printf("%v. Please try again.\n", err)
 			continue
 		}
-		dbxHost = strings.TrimSpace(dbxHost)
-		if dbxHost != "" {
-			break
+		return dbxHost
+	}
+}
+
+// checkDbxHost normalizes a Databricks workspace URL, and checks that the workspace is reachable.
+func checkDbxHost(input string) (string, error) {
+	dbxHost, err := dbx.NormalizeHost(input)
+	if err != nil {
+		return "", err
+	}
+	if err := dbx.PingWorkspace(context.Background(), dbxHost); err != nil {
+		if hlconfig.IsPrivateLinkHost(dbxHost) {
+			return "", fmt.Errorf("%w; %s is a Private Link hostname, which only resolves from networks connected to "+
+				"the workspace, so run hldbx from one, e.g. over the VPN", err, dbxHost)
 		}
+		return "", err
+	}
+	return dbxHost, nil
+}
 
+// useDbxHostArg sets the Databricks workspace URL from a command-line argument, overriding the configuration file.
+// The configured token is dropped if it's for another workspace, unless it names a token cache file.
+func useDbxHostArg(config *utils.Config, args []string) {
+	if len(args) == 0 || sandboxMode {
+		return
+	}
+	dbxHost, err := checkDbxHost(args[0])
+	if err != nil {
+		utils.Fatal(err)
+	}
+	if dbxHost != config.DbxHost {
+		if stats, err := os.Stat(config.DbxToken); err != nil || stats.IsDir() {
+			config.DbxToken = ""
+		}
+		config.DbxHost = dbxHost
 	}
-	return dbxHost
 }
 
-// readConfig reads the configuration file and returns a Config object.
-// If the configuration file is not found, that's OK, return an empty Config.
-// If the configuration file is found but invalid, print an error and exit.
+// readConfig reads the configuration file, with the settings that HLDBX_ environment variables override, and returns
+// a Config object. The configuration file is optional: without one, only the environment's settings are set.
+// If the configuration file is invalid, print an error and exit.
+// In the sandbox, return the configuration of the sandbox workspace instead.
 func readConfig() *utils.Config {
+	if sandboxMode {
+		return sandboxConfig()
+	}
 	config, err := utils.InitConfig()
 	if err != nil {
-		var configNotFound *utils.ConfigNotFound
-		// The config file is optional so OK if it's missing
-		if errors.As(err, &configNotFound) {
-			config = &utils.Config{} // Return an empty Config
-		} else {
-			fmt.Printf("Error reading the configuration file: %v\n", err)
-			os.Exit(1)
-		}
+		utils.Printf("Error reading the configuration file: %v\n", err)
+		os.Exit(1)
+	}
+	applyHlRegion(config)
+	if err := utils.ConfigureUserAgentSuffix(config); err != nil {
+		utils.Fatalf("Invalid configuration: %v", err)
 	}
 
 	return config
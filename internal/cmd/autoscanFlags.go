@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/hl"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+	"github.com/spf13/cobra"
+)
+
+// nonInteractive is set by --non-interactive: values that would be prompted for must come from flags or the
+// configuration file. Optional values and those with defaults take their defaults, and anything else fails fast.
+var nonInteractive bool
+
+// Settings of autoscan that flags set, overriding the configuration file, to run it from CI or provisioning scripts
+var (
+	autoscanDbxHost           string
+	autoscanDbxToken          string
+	autoscanClusterId         string
+	autoscanSchemas           []string
+	autoscanCron              string
+	autoscanRunAs             string
+	autoscanMaxActiveScanJobs int
+	autoscanServerless        bool
+	autoscanHlRegion          string
+	autoscanHlClientId        string
+	autoscanHlClientSecret    string
+	autoscanHlApiKeyName      string
+)
+
+// addAutoscanSettingFlags adds the flags that set what autoscan otherwise prompts for.
+func addAutoscanSettingFlags(command *cobra.Command) {
+	command.Flags().BoolVar(&nonInteractive, "non-interactive", false,
+		"never prompt: fail if a required value isn't set by a flag or the configuration file, and use the defaults of optional ones")
+	addSettingFlags(command)
+	command.MarkFlagsMutuallyExclusive("schema", "schemas-file")
+}
+
+// addSettingFlags adds the flags of the settings that autoscan prompts for, which autoscanFlagSource reads.
+func addSettingFlags(command *cobra.Command) {
+	flags := command.Flags()
+	flags.StringVar(&autoscanDbxHost, "dbx-host", "", "Databricks workspace URL, like the workspace URL argument")
+	flags.StringVar(&autoscanDbxToken, "dbx-token", "", "Databricks token, or the path to a Databricks CLI token cache; "+
+		"other processes can see the values of flags, so prefer $HLDBX_DBX_TOKEN")
+	flags.StringVar(&autoscanClusterId, "cluster-id", "", "ID of the cluster that the jobs run on")
+	flags.StringArrayVar(&autoscanSchemas, "schema", nil, "schema to monitor, as <catalog>.<schema>; repeat it for several")
+	flags.StringVar(&autoscanCron, "cron", "", "schedule of the monitoring job, in Quartz cron format")
+	flags.StringVar(&autoscanRunAs, "run-as", "", "application ID of the service principal that the jobs run as")
+	flags.IntVar(&autoscanMaxActiveScanJobs, "max-active-scan-jobs", 0, "maximum number of scan jobs that run at once")
+	flags.BoolVar(&autoscanServerless, "serverless", false, "run the jobs on serverless compute, instead of a cluster")
+	flags.StringVar(&autoscanHlRegion, "hl-region", "", "region of the HiddenLayer API: us, eu, or custom")
+	flags.StringVar(&autoscanHlClientId, "hl-client-id", "", "HiddenLayer client ID")
+	flags.StringVar(&autoscanHlClientSecret, "hl-client-secret", "", "HiddenLayer client secret; "+
+		"other processes can see the values of flags, so prefer $HLDBX_HL_CLIENT_SECRET")
+	flags.StringVar(&autoscanHlApiKeyName, "hl-api-key-name", "", "name of the Databricks secret that stores the HiddenLayer credentials")
+	_ = command.RegisterFlagCompletionFunc("cluster-id", completeClusterIds)
+	_ = command.RegisterFlagCompletionFunc("schema", completeSchemas)
+	command.MarkFlagsMutuallyExclusive("serverless", "cluster-id")
+}
+
+// applyAutoscanFlags sets the settings of the flags that were given in the configuration, over those of the
+// configuration file and the environment, and returns the workspace URL arguments, including --dbx-host, for
+// useDbxHostArg. The token is applied by applyAutoscanToken, since a new workspace URL drops the configured one.
+func applyAutoscanFlags(cmd *cobra.Command, config *utils.Config, args []string) []string {
+	if autoscanDbxHost != "" {
+		if len(args) > 0 {
+			utils.Fatal("Give the workspace URL as an argument or with --dbx-host, not both")
+		}
+		args = []string{autoscanDbxHost}
+	}
+	if err := config.Apply(autoscanFlagSource(cmd)); err != nil {
+		utils.Fatalf("Invalid flags: %v", err)
+	}
+	if cmd.Flags().Changed("hl-region") && !strings.EqualFold(autoscanHlRegion, hl.CustomRegion) {
+		applyHlRegion(config)
+	}
+	utils.RegisterConfigSecrets(config)
+	return args
+}
+
+// autoscanFlagSource returns the settings of the flags that were given.
+func autoscanFlagSource(cmd *cobra.Command) hlconfig.Source {
+	source := hlconfig.Source{}
+	if len(autoscanSchemas) > 0 {
+		var schemas []any
+		for _, name := range autoscanSchemas {
+			schema, err := dbx.ParseSchemaName(name)
+			if err != nil {
+				utils.Fatalf("Invalid --schema: %v", err)
+			}
+			schemas = append(schemas, map[string]any{"dbx_catalog": schema.Catalog, "dbx_schema": schema.Schema})
+		}
+		source["dbx_schemas"] = schemas
+	}
+	flags := cmd.Flags()
+	for _, flag := range settingFlags() {
+		if flags.Changed(flag.name) {
+			source[flag.key] = flag.value
+		}
+	}
+	if flags.Changed("hl-region") && !strings.EqualFold(autoscanHlRegion, hl.CustomRegion) {
+		// The URLs of the region replace those of the configuration file
+		source["hl_api_url"], source["hl_auth_url"], source["hl_console_url"] = "", "", ""
+	}
+	return source
+}
+
+// settingFlag is a flag that sets a single setting.
+type settingFlag struct {
+	name  string
+	key   string
+	value any
+}
+
+// settingFlags returns the flags of addSettingFlags that set a single setting, with their values. --schema sets
+// dbx_schemas, and --dbx-host and --dbx-token are applied as the workspace URL argument and its token.
+func settingFlags() []settingFlag {
+	return []settingFlag{
+		{"cluster-id", "dbx_cluster_id", autoscanClusterId},
+		{"serverless", "dbx_serverless", autoscanServerless},
+		{"cron", "dbx_polling_quartz_cron", autoscanCron},
+		{"run-as", "dbx_run_as", autoscanRunAs},
+		{"max-active-scan-jobs", "dbx_max_active_scan_jobs", autoscanMaxActiveScanJobs},
+		{"hl-region", "hl_region", autoscanHlRegion},
+		{"hl-client-id", "hl_client_id", autoscanHlClientId},
+		{"hl-client-secret", "hl_client_secret", autoscanHlClientSecret},
+		{"hl-api-key-name", "hl_api_key_name", autoscanHlApiKeyName},
+	}
+}
+
+// applyAutoscanToken sets the Databricks token of --dbx-token, once the workspace URL is set.
+func applyAutoscanToken(config *utils.Config) {
+	if autoscanDbxToken != "" {
+		config.DbxToken = autoscanDbxToken
+		utils.RegisterConfigSecrets(config)
+	}
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var backfillWorkers int
+var backfillRestart bool
+var backfillFromResults bool
+var backfillDryRun bool
+
+var backfillCmd = &cobra.Command{
+	Use:   "backfill",
+	Short: "Scans the existing model versions that haven't been scanned",
+	Long: "Scans every version of every model in the monitored schemas that hasn't been scanned, not just the " +
+		"versions that the monitoring job picks, running several scans at once. Progress is checkpointed in the " +
+		"profile's state directory, so an interrupted backfill resumes where it stopped. Press Ctrl+C to stop.\n\n" +
+		"With --from-results, nothing is scanned. Instead, the model versions that haven't been scanned are tagged " +
+		"with the result of their latest HiddenLayer scan, if they have one, e.g. from another integration. " +
+		"Scans are looked up by model name and version.",
+	Example: "  hldbx backfill --workers 20\n  hldbx backfill --from-results --dry-run",
+	Run: func(cmd *cobra.Command, args []string) {
+		if backfillDryRun && !backfillFromResults {
+			utils.Fatal("--dry-run only applies with --from-results")
+		}
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		if len(config.DbxSchemas) == 0 {
+			utils.Fatal("No schemas to backfill, add dbx_schemas to the configuration file")
+		}
+		if backfillFromResults {
+			importScanResults(dbxClient, config)
+			return
+		}
+		workers := backfillWorkers
+		if workers == 0 {
+			workers = config.MaxActiveScanJobs()
+		}
+		checkpoint, err := dbx.LoadBackfillCheckpoint()
+		if err != nil {
+			utils.Fatal(err)
+		}
+		if backfillRestart {
+			checkpoint.Reset()
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if config.UsesExistingCluster() {
+			if err := waitForCluster(ctx, dbxClient, config.DbxClusterId); err != nil {
+				utils.Fatalf("Cluster is not ready for the backfill: %v", err)
+			}
+		}
+		fmt.Printf("Backfilling %d schema(s) with %d worker(s). Press Ctrl+C to stop, and re-run to resume.\n",
+			len(config.DbxSchemas), workers)
+		progress, err := dbx.Backfill(ctx, dbxClient, config, workers, checkpoint, printBackfillProgress)
+		if progress.Finished() > 0 {
+			fmt.Println()
+		}
+		if err != nil {
+			utils.Fatalf("Error backfilling: %v", err)
+		}
+		if progress.Resumed > 0 {
+			fmt.Printf("Resumed after %d model version(s) finished by earlier backfills\n", progress.Resumed)
+		}
+		if progress.Registering > 0 {
+			fmt.Printf("Left %d model version(s) that are still being registered for the next backfill\n",
+				progress.Registering)
+		}
+		if progress.External > 0 {
+			fmt.Printf("Skipped %d model version(s) registered from outside this workspace, see dbx_skip_external_versions\n",
+				progress.External)
+		}
+		fmt.Printf("Scanned %d model version(s), skipped %d already scanned, %d failed, in %s\n", progress.Scanned,
+			progress.Skipped, progress.Failed, progress.Elapsed.Round(time.Second))
+		if ctx.Err() != nil {
+			fmt.Printf("Interrupted with %d model version(s) left, re-run hldbx backfill to resume\n",
+				progress.Total-progress.Finished())
+		} else if progress.Failed > 0 {
+			fmt.Println("Re-run hldbx backfill to retry the failed model versions")
+		}
+	},
+}
+
+// printBackfillProgress prints the backfill's progress and estimated time remaining on one updating line,
+// and each failure on a line of its own.
+func printBackfillProgress(progress dbx.BackfillProgress) {
+	if progress.LastErr != nil {
+		utils.Printf("\rScan of %s failed: %v\n", progress.Last, progress.LastErr)
+	}
+	status := fmt.Sprintf("ETA %s", progress.Remaining.Round(time.Second))
+	if progress.RateLimited {
+		status = "rate-limited by Databricks, slowing down"
+	}
+	fmt.Printf("\rBackfill: %d/%d model version(s), %d failed, %s   ", progress.Finished(), progress.Total,
+		progress.Failed, status)
+}
+
+// importScanResults tags the model versions that haven't been scanned with their existing HiddenLayer results.
+func importScanResults(dbxClient *databricks.WorkspaceClient, config *utils.Config) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	fmt.Printf("Looking up HiddenLayer results of the model versions in %d schema(s)\n", len(config.DbxSchemas))
+	progress, err := dbx.ImportScanResults(ctx, dbxClient, config, backfillDryRun, func(progress dbx.ImportProgress) {
+		if progress.LastErr != nil {
+			utils.Printf("\rImport of %s failed: %v\n", progress.Last, progress.LastErr)
+		}
+		fmt.Printf("\rImport: %d/%d model version(s), %d found, %d failed   ", progress.Finished(), progress.Total,
+			progress.Imported, progress.Failed)
+	})
+	if progress.Finished() > 0 {
+		fmt.Println()
+	}
+	if err != nil && ctx.Err() == nil {
+		utils.Fatalf("Error importing scan results: %v", err)
+	}
+	imported := "Imported"
+	if backfillDryRun {
+		imported = "Would import"
+	}
+	fmt.Printf("%s the results of %d model version(s), skipped %d already scanned, %d have no HiddenLayer results, %d failed\n",
+		imported, progress.Imported, progress.Skipped, progress.NotFound, progress.Failed)
+	if progress.NotFound > 0 && !backfillDryRun {
+		fmt.Println("Run hldbx backfill to scan the model versions without results")
+	}
+}
+
+func init() {
+	backfillCmd.Flags().IntVar(&backfillWorkers, "workers", 0,
+		"number of model versions to scan at once (default: dbx_max_active_scan_jobs)")
+	backfillCmd.Flags().BoolVar(&backfillRestart, "restart", false, "ignore the checkpoint of earlier backfills and start over")
+	backfillCmd.Flags().BoolVar(&backfillFromResults, "from-results", false,
+		"tag the model versions with their existing HiddenLayer results instead of scanning them")
+	backfillCmd.Flags().BoolVar(&backfillDryRun, "dry-run", false, "with --from-results, only count the results that would be imported")
+	addClusterReadinessFlags(backfillCmd)
+	rootCmd.AddCommand(backfillCmd)
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/mock"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+	"github.com/spf13/cobra"
+)
+
+var benchSchemas int
+var benchModels int
+var benchVersions int
+var benchLatency time.Duration
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measures discovery performance against a simulated model registry",
+	Long: "Starts a mock Databricks server that simulates a Unity Catalog model registry of the given size, and " +
+		"measures how long model version discovery takes, how many API calls it makes, and how large the generated " +
+		"monitoring job settings are. For developers, to catch performance regressions on large registries.",
+	Example: "  hldbx bench --models 2000 --versions 20 --latency 5ms",
+	Hidden:  true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if benchSchemas < 1 || benchModels < 0 || benchVersions < 1 {
+			utils.Fatal("--schemas and --versions must be at least 1, and --models at least 0")
+		}
+		registry := mock.Registry{
+			ModelsPerSchema:  benchModels,
+			VersionsPerModel: benchVersions,
+			Aliases:          []string{"prod"},
+			DetectionEvery:   10,
+			Latency:          benchLatency,
+		}
+		for i := range benchSchemas {
+			registry.Schemas = append(registry.Schemas, utils.CatalogSchemaConfig{Catalog: "bench", Schema: fmt.Sprintf("schema_%d", i)})
+		}
+		server := mock.NewServer(registry)
+		defer server.Close()
+
+		dbxClient, err := dbx.Auth(server.URL, "bench")
+		if err != nil {
+			utils.Fatalf("Error connecting to the mock Databricks server: %v", err)
+		}
+		config := &utils.Config{
+			WorkspaceConfig:  hlconfig.WorkspaceConfig{DbxHost: server.URL},
+			ScanPolicyConfig: hlconfig.ScanPolicyConfig{DbxSchemas: registry.Schemas},
+			ComputeConfig:    hlconfig.ComputeConfig{DbxClusterId: "bench"},
+			JobsConfig:       hlconfig.JobsConfig{DbxPollingQuartzCron: "0 0 * * * ?", DbxMaxActiveScanJobs: 10},
+		}
+		results, err := dbx.Bench(context.Background(), dbxClient, config, server.Requests)
+		if err != nil {
+			utils.Fatalf("Error running benchmark: %v", err)
+		}
+
+		if outputFormat == outputJson {
+			printJson(results)
+			return
+		}
+		fmt.Printf("Simulated registry: %d schema(s) x %d model(s) x %d version(s), %s latency per call\n",
+			benchSchemas, benchModels, benchVersions, benchLatency)
+		fmt.Printf("%-26s %12s %10s %8s %10s\n", "PHASE", "DURATION", "API CALLS", "ITEMS", "BYTES")
+		for _, result := range results {
+			fmt.Printf("%-26s %12s %10d %8d %10d\n", result.Phase, result.Duration.Round(time.Microsecond),
+				result.Requests, result.Items, result.Bytes)
+		}
+	},
+}
+
+func init() {
+	benchCmd.Flags().IntVar(&benchSchemas, "schemas", 1, "number of simulated schemas")
+	benchCmd.Flags().IntVar(&benchModels, "models", 1000, "number of simulated models in each schema")
+	benchCmd.Flags().IntVar(&benchVersions, "versions", 10, "number of simulated versions of each model")
+	benchCmd.Flags().DurationVar(&benchLatency, "latency", 0, "simulated latency of each Databricks API call")
+	supportJsonOutput(benchCmd)
+	rootCmd.AddCommand(benchCmd)
+}
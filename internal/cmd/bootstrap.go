@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var bootstrapCmd = &cobra.Command{
+	Use:   "bootstrap [directory]",
+	Short: "Writes the notebook that runs hldbx from within Databricks",
+	Long: "Writes the hldbx_bootstrap notebook to a directory, by default the current one, for operators whose " +
+		"machines can't reach the Databricks workspace. Import it into the workspace, upload a Linux build of hldbx " +
+		"and the configuration file to a Unity Catalog Volume, and run the notebook, e.g. as a one-time job. hldbx then " +
+		"runs in Databricks, authenticated with the token of the notebook's context, as the identity that runs it.",
+	Example: "  hldbx bootstrap\n  hldbx bootstrap ./bootstrap",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		path, err := dbx.WriteBootstrapNotebook(dir)
+		if err != nil {
+			utils.Fatalf("Error writing the bootstrap notebook: %v", err)
+		}
+		fmt.Printf("Wrote %s. To run hldbx from within Databricks:\n", path)
+		fmt.Println("1. Import it into the workspace, e.g. with the workspace browser's Import.")
+		fmt.Println("2. Upload a Linux build of hldbx, and the configuration file without dbx_host and dbx_token, to a Unity Catalog Volume.")
+		fmt.Println("3. Store the HiddenLayer credentials as <client_id>:<client_secret> in a secret, unless the configuration file has them.")
+		fmt.Println("4. Run the notebook with hldbx_binary, config_file, and hl_credentials_secret set to their paths and <scope>/<key>,")
+		fmt.Println("   and args set to the hldbx command line, e.g. autoscan --run-now.")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(bootstrapCmd)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var chownRunAs string
+var chownForce bool
+
+var chownCmd = &cobra.Command{
+	Use:   "chown",
+	Short: "Makes the installed jobs run as, and be owned by, a service principal",
+	Long: "Changes the identity that the installed jobs run as, and their owner, to a service principal, e.g. " +
+		"after the user who installed them left the company, without recreating the jobs, which keep their IDs and " +
+		"run history. It first checks that the service principal can do what the jobs do: attach to the clusters " +
+		"they run on, read the HiddenLayer credentials in the secrets scopes of the monitored schemas, and read the " +
+		"model versions of the monitored schemas, with USE CATALOG, USE SCHEMA, and EXECUTE, tag them, with APPLY " +
+		"TAG or MANAGE, and write the state and model map tables. Grants to the groups it belongs to count. If a check fails, nothing is changed unless --force is given. dbx_run_as is set in the " +
+		"configuration file, so that autoscan keeps the change. The install audit log records the change.",
+	Example: "  hldbx chown --run-as 01234567-89ab-cdef-0123-456789abcdef",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		ctx := context.Background()
+		checks, err := dbx.CheckRunAsPermissions(ctx, dbxClient, config, chownRunAs)
+		if err != nil {
+			utils.Fatalf("Error checking the permissions of service principal %s: %v", chownRunAs, err)
+		}
+		failed := 0
+		for _, check := range checks {
+			if !check.Ok {
+				failed++
+			}
+			if outputFormat == outputJson {
+				continue
+			}
+			if check.Ok {
+				fmt.Printf("OK: %s: %s\n", check.Name, check.Message)
+				continue
+			}
+			fmt.Printf("FAIL: %s: %s\n", check.Name, check.Message)
+			fmt.Printf("  Fix: %s\n", check.Remediation)
+		}
+		if failed > 0 && !chownForce {
+			utils.Fatalf("%d check(s) failed, nothing was changed. Fix them and re-run, or re-run with --force to "+
+				"change the jobs anyway", failed)
+		}
+
+		chowned, err := dbx.ChangeJobsRunAs(ctx, dbxClient, config, chownRunAs)
+		if outputFormat != outputJson {
+			for _, job := range chowned {
+				if !job.Changed {
+					fmt.Printf("Job %s (ID %d) already runs as, and is owned by, %s\n", job.Name, job.JobId, chownRunAs)
+					continue
+				}
+				fmt.Printf("Job %s (ID %d) now runs as, and is owned by, %s, instead of running as %s and being "+
+					"owned by %s\n", job.Name, job.JobId, chownRunAs, dashIfEmpty(job.PreviousRunAs), dashIfEmpty(job.PreviousOwner))
+			}
+		}
+		if err != nil {
+			utils.Fatalf("Error changing the identity of the installed jobs: %v", err)
+		}
+		if len(chowned) == 0 {
+			utils.Fatal("No installed jobs, run hldbx autoscan to create them")
+		}
+
+		if config.DbxRunAs != chownRunAs {
+			if _, err := utils.SetConfigValue("dbx_run_as", chownRunAs, validateSettings); err != nil {
+				utils.Fatalf("Error setting dbx_run_as: %v", err)
+			}
+			if outputFormat != outputJson {
+				path, _ := utils.ConfigFilePath()
+				fmt.Printf("Set dbx_run_as in %s\n", path)
+			}
+		}
+		if outputFormat == outputJson {
+			printJson(struct {
+				RunAs  string            `json:"run_as"`
+				Checks []dbx.DoctorCheck `json:"checks"`
+				Jobs   []dbx.ChownedJob  `json:"jobs"`
+			}{chownRunAs, checks, chowned})
+		}
+	},
+}
+
+func init() {
+	chownCmd.Flags().StringVar(&chownRunAs, "run-as", "", "application ID of the service principal to run the jobs as")
+	chownCmd.Flags().BoolVar(&chownForce, "force", false, "change the jobs even if the service principal's permission checks fail")
+	_ = chownCmd.MarkFlagRequired("run-as")
+	supportJsonOutput(chownCmd)
+	rootCmd.AddCommand(chownCmd)
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/compute"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/spf13/cobra"
+)
+
+// Flags of the commands that trigger immediate job runs, which need the cluster to be running
+var startCluster bool
+var clusterTimeout time.Duration
+
+// addClusterReadinessFlags adds the flags that control waiting for the cluster before an immediate run.
+func addClusterReadinessFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&startCluster, "start-cluster", false, "start the cluster if it is terminated")
+	cmd.Flags().DurationVar(&clusterTimeout, "cluster-timeout", 20*time.Minute, "how long to wait for the cluster to be running")
+}
+
+// waitForCluster waits for the cluster to be running, printing its state on one updating line.
+func waitForCluster(ctx context.Context, dbxClient *databricks.WorkspaceClient, clusterId string) error {
+	lastState := compute.State("")
+	err := dbx.WaitForCluster(ctx, dbxClient, clusterId, startCluster, clusterTimeout, func(state compute.State, elapsed time.Duration) {
+		if state == compute.StateRunning && lastState == "" {
+			return // Already running, nothing to report
+		}
+		lastState = state
+		fmt.Printf("\rWaiting for cluster %s: %s (%s)   ", clusterId, state, elapsed.Round(time.Second))
+	})
+	if lastState != "" {
+		fmt.Println()
+	}
+	return err
+}
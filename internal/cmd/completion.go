@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/catalog"
+	"github.com/databricks/databricks-sdk-go/service/compute"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// Shell completion scripts come from cobra's completion command, hldbx completion <shell>. The functions here
+// complete flag values from the workspace of the configuration file, when its credentials need no prompting.
+
+// completionDbxClient returns a client of the configured workspace, or nil if it can't authenticate without
+// prompting. The shell reads the completions from stdout, so what reading the configuration and authenticating
+// would print is discarded.
+func completionDbxClient() *databricks.WorkspaceClient {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return nil
+	}
+	defer devNull.Close()
+	stdout, logOutput := os.Stdout, log.Writer()
+	output, level := utils.Logging()
+	os.Stdout = devNull
+	log.SetOutput(io.Discard)
+	utils.ConfigureLogging(io.Discard, level)
+	defer func() {
+		os.Stdout = stdout
+		log.SetOutput(logOutput)
+		utils.ConfigureLogging(output, level)
+	}()
+
+	var config *utils.Config
+	if sandboxMode {
+		config = sandboxConfig()
+	} else if config, err = utils.InitConfig(); err != nil {
+		return nil
+	}
+	if utils.InDatabricks() && config.DbxHost == "" && config.DbxToken == "" {
+		config.DbxHost, config.DbxToken = os.Getenv(utils.DatabricksHostEnv), os.Getenv(utils.DatabricksTokenEnv)
+	}
+	if config.DbxHost != "" && config.DbxToken == "" {
+		config.DbxToken = keyringToken(config.DbxHost)
+	}
+	if config.DbxHost == "" || config.DbxToken == "" {
+		return nil
+	}
+	client, err := dbx.Auth(config.DbxHost, config.DbxToken)
+	if err != nil {
+		return nil
+	}
+	return client
+}
+
+// completeSchemas completes <catalog>.<schema> values: the catalogs of the workspace, then the schemas of the catalog
+// that was typed.
+func completeSchemas(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	client := completionDbxClient()
+	if client == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	ctx := context.Background()
+	catalogName, _, found := strings.Cut(toComplete, ".")
+	if !found {
+		catalogs, err := client.Catalogs.ListAll(ctx, catalog.ListCatalogsRequest{})
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		var completions []string
+		for _, c := range catalogs {
+			completions = append(completions, c.Name+".")
+		}
+		// Completing a catalog leaves the cursor after its dot, to complete the schema next
+		return completions, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	}
+	schemas, err := client.Schemas.ListAll(ctx, catalog.ListSchemasRequest{CatalogName: catalogName})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var completions []string
+	for _, schema := range schemas {
+		completions = append(completions, schema.FullName)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeClusterIds completes cluster IDs, described by the clusters' names.
+func completeClusterIds(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	client := completionDbxClient()
+	if client == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	clusters, err := client.Clusters.ListAll(context.Background(), compute.ListClustersRequest{})
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var completions []string
+	for _, cluster := range clusters {
+		completions = append(completions, cluster.ClusterId+"\t"+cluster.ClusterName)
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeConfigProfiles completes the names of the profiles of the configuration file, that of --config if given.
+func completeConfigProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	utils.SetConfigFile(configFile)
+	path, err := utils.ConfigFilePath()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	file, err := utils.ReadConfigFile(path)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return file.ProfileNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeOutputFormats completes the formats of --output.
+func completeOutputFormats(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return []string{outputText, outputJson}, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Changes and checks configuration files",
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Sets a setting in the configuration file",
+	Long: "Sets a setting in the selected profile's configuration file, after checking that the resulting " +
+		"configuration is valid. Comments and other settings in the file are kept. Run hldbx apply afterwards to " +
+		"update the installed monitoring job, without re-running autoscan.\n\nSettings: " +
+		strings.Join(utils.SettingKeys(), ", "),
+	Example: "  hldbx config set dbx_max_active_scan_jobs 20\n  hldbx apply",
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if sandboxMode {
+			utils.Fatal("hldbx config set changes the configuration file, which the sandbox doesn't use")
+		}
+		key, value := args[0], args[1]
+		if _, err := utils.SetConfigValue(key, value, validateSettings); err != nil {
+			utils.Fatalf("Error setting %s: %v", key, err)
+		}
+		path, _ := utils.ConfigFilePath()
+		fmt.Printf("Set %s in %s\n", key, path)
+		if slices.Contains(appliedSettings, key) {
+			fmt.Println("Run hldbx apply to update the installed monitoring job")
+		}
+	},
+}
+
+// Settings that hldbx apply updates in the installed monitoring job and manifest; the others take effect on the next
+// autoscan
+var appliedSettings = []string{
+	"dbx_max_active_scan_jobs", "dbx_polling_quartz_cron", "dbx_serving_guardrail", "dbx_scan_comments",
+	"dbx_scan_trigger", "dbx_discovery_source", "dbx_skip_external_versions", "dbx_findings_sink", "dbx_serverless",
+	"dbx_budget_policy_id", "hl_api_key_name", "hl_api_url", "hl_auth_url", "hl_console_url", "hl_https_proxy", "hl_no_proxy", "hl_ca_bundle_path",
+	"hl_tls_min_version", "hl_region", "hl_scan_origin", "hl_outage_policy", "hl_circuit_breaker_threshold", "dbx_monitor_job_name", "dbx_job_description",
+	"user_agent_suffix", "dbx_coordination_table", "owner_contact",
+}
+
+// validateSettings checks the settings that can be validated without reaching Databricks or HiddenLayer.
+func validateSettings(config *utils.Config) error {
+	if config.DbxPollingQuartzCron != "" {
+		if err := dbx.ValidateQuartzCron(config.DbxPollingQuartzCron); err != nil {
+			return err
+		}
+	}
+	validators := []func(*utils.Config) error{
+		(*utils.Config).Validate, dbx.ValidateFindingsSink, dbx.ValidateArtifactSources, dbx.ValidateScanTrigger,
+		dbx.ValidateSecretsGroup, dbx.ValidateScanMetadata, dbx.ValidateBudgetPolicy, dbx.ValidateAbacGroup,
+		dbx.ValidateScanners, dbx.ValidateNaming, dbx.ValidateJobCluster, utils.ValidateUserAgentSuffix,
+		dbx.ValidateOwnerGroups, dbx.ValidateCoordinationTable, dbx.ValidateVerifyJob, dbx.ValidateClusterTags,
+		dbx.ValidateResultsShare, dbx.ValidateOnDemandJob, dbx.ValidateRetention, dbx.ValidateCloudNodeType,
+	}
+	for _, validate := range validators {
+		if err := validate(config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	configCmd.AddCommand(configSetCmd)
+	rootCmd.AddCommand(configCmd)
+}
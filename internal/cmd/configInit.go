@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	hldatabricks "github.com/hiddenlayer-engineering/hl-databricks"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/hl"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Prompts for the settings of autoscan and writes them to the configuration file",
+	Long: "Walks through the prompts of autoscan without deploying anything: the Databricks workspace and " +
+		"credentials, the cluster, the service principal to run the jobs as, the polling schedule, the schemas to " +
+		"monitor, and the HiddenLayer region and credentials, each checked against Databricks and HiddenLayer as " +
+		"autoscan checks them. Then writes them to the selected profile's configuration file, creating it like hldbx " +
+		"setup if needed, or, with --config-profile, to that profile of the file, adding it if needed, so that " +
+		"hldbx autoscan --non-interactive later deploys from it unattended. Settings already in the file aren't " +
+		"prompted for again, and its comments and other settings are kept. The Databricks token isn't written: " +
+		"autoscan signs in again with the Databricks CLI's token cache, the OS keyring, or HLDBX_DBX_TOKEN. The " +
+		"HiddenLayer client secret is written to the file, which only you can access.",
+	Example: "  hldbx config init\n  hldbx config lint ~/.hl/hldbx.yaml\n  hldbx autoscan --non-interactive",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if sandboxMode {
+			utils.Fatal("hldbx config init writes the configuration file, which the sandbox doesn't use")
+		}
+		requireTerminal("the settings")
+		result, err := utils.Setup(hldatabricks.ConfigTemplate)
+		if err != nil {
+			utils.Fatalf("Error setting up hldbx: %v", err)
+		}
+		if result.ConfigCreated {
+			fmt.Printf("Created the configuration file %s\n", result.ConfigPath)
+		} else {
+			fmt.Printf("Completing the configuration file %s, its settings aren't prompted for again\n", result.ConfigPath)
+		}
+		if added, err := utils.AddConfigProfile(); err != nil {
+			utils.Fatalf("Error adding the profile to %s: %v", result.ConfigPath, err)
+		} else if added {
+			fmt.Printf("Added profile %s to %s\n", configProfile, result.ConfigPath)
+		}
+
+		config := readConfig()
+		before := *config
+		dbxClient := configDbxCreds(config)
+		requireUnityCatalog(dbxClient)
+		configDbxResources(config, dbxClient)
+		configHlCreds(config)
+		validateEgressSettings(config)
+		if err := validateSettings(config); err != nil {
+			utils.Fatalf("Invalid configuration: %v", err)
+		}
+
+		values := configInitValues(&before, config)
+		if len(values) == 0 {
+			fmt.Printf("%s already holds every setting that autoscan prompts for\n", result.ConfigPath)
+			return
+		}
+		if _, err := utils.SetConfigValues(values, validateSettings); err != nil {
+			utils.Fatalf("Error writing %s: %v", result.ConfigPath, err)
+		}
+		var keys []string
+		for _, value := range values {
+			keys = append(keys, value.Key)
+		}
+		fmt.Printf("Wrote %s to %s\n", strings.Join(keys, ", "), result.ConfigPath)
+		fmt.Println("Next, run hldbx autoscan --non-interactive to deploy from it, e.g. in CI")
+	},
+}
+
+// configInitValues returns the settings that the prompts filled in or changed, in the order they're prompted for.
+// Values that were already set, in the file or by HLDBX_ environment variables, aren't returned, so that secrets
+// in the environment stay out of the file. Neither is the Databricks token, nor the HiddenLayer URLs of a region.
+func configInitValues(before, after *utils.Config) []utils.ConfigValue {
+	var values []utils.ConfigValue
+	add := func(key string, changed bool, value any) {
+		if changed {
+			values = append(values, utils.ConfigValue{Key: key, Value: value})
+		}
+	}
+	add("dbx_host", after.DbxHost != before.DbxHost, after.DbxHost)
+	add("dbx_cluster_id", after.DbxClusterId != before.DbxClusterId, after.DbxClusterId)
+	add("dbx_run_as", after.DbxRunAs != before.DbxRunAs, after.DbxRunAs)
+	add("dbx_max_active_scan_jobs", after.DbxMaxActiveScanJobs != before.DbxMaxActiveScanJobs, after.DbxMaxActiveScanJobs)
+	add("dbx_polling_quartz_cron", after.DbxPollingQuartzCron != before.DbxPollingQuartzCron, after.DbxPollingQuartzCron)
+	var schemas []map[string]string
+	for _, schema := range after.DbxSchemas {
+		entry := map[string]string{"dbx_catalog": schema.Catalog, "dbx_schema": schema.Schema}
+		if schema.Scanner != "" {
+			entry["scanner"] = schema.Scanner
+		}
+		schemas = append(schemas, entry)
+	}
+	add("dbx_schemas", !slices.Equal(after.DbxSchemas, before.DbxSchemas), schemas)
+	add("hl_region", after.HlRegion != before.HlRegion, after.HlRegion)
+	// The URLs of a SaaS region follow from hl_region
+	customUrls := after.HlRegion == "" || strings.EqualFold(after.HlRegion, hl.CustomRegion)
+	add("hl_api_url", customUrls && after.HlApiUrl != before.HlApiUrl, after.HlApiUrl)
+	add("hl_auth_url", customUrls && after.HlAuthUrl != before.HlAuthUrl, after.HlAuthUrl)
+	add("hl_console_url", customUrls && after.HlConsoleUrl != before.HlConsoleUrl, after.HlConsoleUrl)
+	add("hl_client_id", after.HlClientID != before.HlClientID, after.HlClientID)
+	add("hl_client_secret", after.HlClientSecret != before.HlClientSecret, after.HlClientSecret)
+	add("hl_api_key_name", after.HlApiKeyName != before.HlApiKeyName, after.HlApiKeyName)
+	return values
+}
+
+func init() {
+	configCmd.AddCommand(configInitCmd)
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/hl"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+	"github.com/spf13/cobra"
+)
+
+var configLintStrict bool
+
+var configLintCmd = &cobra.Command{
+	Use:   "lint <file>...",
+	Short: "Checks configuration files offline, e.g. in a pre-commit hook or CI",
+	Long: "Checks configuration files without reaching Databricks or HiddenLayer, so that a configuration kept in git " +
+		"is checked before it's deployed: the YAML syntax, settings that hldbx doesn't know, e.g. misspelled ones, " +
+		"the types of the values, the Quartz cron schedules, the URLs, the region, the TLS settings, and the settings " +
+		"that autoscan validates, such as the scan trigger, the outage and cluster tag policies, and the scanners. " +
+		"It warns about secrets in the files, and about settings that autoscan would prompt for. Unlike hldbx " +
+		"validate, it doesn't check that the workspace's schemas, cluster, and service principal exist. " +
+		"Environment variables don't override the files' settings. Exits with an error if a file has a problem, " +
+		"or with --strict, a warning.",
+	Example: "  hldbx config lint hldbx.yaml\n  hldbx config lint --strict deploy/*.yaml",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		failed := 0
+		for _, path := range args {
+			problems, warnings := lintConfigFile(path)
+			for _, problem := range problems {
+				fmt.Printf("%s: FAIL: %s\n", path, problem)
+			}
+			for _, warning := range warnings {
+				fmt.Printf("%s: WARN: %s\n", path, warning)
+			}
+			if len(problems) > 0 || configLintStrict && len(warnings) > 0 {
+				failed++
+			} else {
+				fmt.Printf("%s: ok\n", path)
+			}
+		}
+		if failed > 0 {
+			utils.Fatalf("%d of %d file(s) failed", failed, len(args))
+		}
+	},
+}
+
+// lintConfigFile checks a configuration file offline, and returns its problems, and what autoscan accepts but
+// warrants a warning.
+func lintConfigFile(path string) ([]string, []string) {
+	in, err := os.Open(path)
+	if err != nil {
+		return []string{err.Error()}, nil
+	}
+	defer in.Close()
+	file, err := hlconfig.ReadFile(in)
+	if err != nil {
+		return []string{fmt.Sprintf("invalid YAML: %v", err)}, nil
+	}
+	var problems, warnings []string
+	for _, key := range hlconfig.UnknownKeys(file) {
+		problems = append(problems, fmt.Sprintf("unknown setting %s", key))
+	}
+	// The settings at the top of the file, and each profile merged over them, are checked as the configurations
+	// they make up
+	profiles := file.ProfileNames()
+	for _, name := range append([]string{""}, profiles...) {
+		settings, err := file.Profile(name)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		prefix := ""
+		if name != "" {
+			prefix = "profile " + name + ": "
+		}
+		// The settings at the top of a file with profiles may leave those that autoscan prompts for to the profiles
+		profileProblems, profileWarnings := lintConfigSettings(settings, name == "" && len(profiles) > 0)
+		for _, problem := range profileProblems {
+			problems = append(problems, prefix+problem)
+		}
+		for _, warning := range profileWarnings {
+			warnings = append(warnings, prefix+warning)
+		}
+	}
+	return problems, warnings
+}
+
+// lintConfigSettings checks the settings of a configuration, and returns its problems, and what autoscan accepts but
+// warrants a warning. With partial, settings that autoscan would prompt for aren't warned about.
+func lintConfigSettings(file hlconfig.Source, partial bool) ([]string, []string) {
+	var problems, warnings []string
+	config, err := hlconfig.Load(file)
+	if err != nil {
+		// Decoding lists each value that doesn't fit its setting on a line of its own, starting with *
+		for _, line := range strings.Split(err.Error(), "\n") {
+			if value, ok := strings.CutPrefix(line, "* "); ok {
+				problems = append(problems, "invalid value: "+value)
+			}
+		}
+		if len(problems) == 0 {
+			problems = append(problems, fmt.Sprintf("invalid value: %v", err))
+		}
+		return problems, nil
+	}
+
+	if config.HlRegion != "" && !strings.EqualFold(config.HlRegion, hl.CustomRegion) {
+		if region, ok := hl.LookupRegion(config.HlRegion); !ok {
+			problems = append(problems, fmt.Sprintf("invalid hl_region %q, expected one of us, eu, or custom", config.HlRegion))
+		} else if config.HlApiUrl == "" {
+			config.HlApiUrl, config.HlAuthUrl, config.HlConsoleUrl = region.ApiUrl, region.AuthUrl, region.ConsoleUrl
+		}
+	}
+	problems = append(problems, lintConfigUrls(config)...)
+	if _, err := hl.TLSConfig(config.HlTlsMinVersion, config.HlTlsPins); err != nil {
+		problems = append(problems, fmt.Sprintf("invalid TLS settings: %v", err))
+	}
+	// The validators exit on API URLs that don't parse, which lintConfigUrls reports
+	apiUrls := []string{config.HlApiUrl}
+	for _, scanner := range config.HlScanners {
+		apiUrls = append(apiUrls, scanner.ApiUrl)
+	}
+	if !slices.ContainsFunc(apiUrls, func(apiUrl string) bool {
+		_, err := url.Parse(apiUrl)
+		return err != nil
+	}) {
+		if err := validateSettings(config); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if config.DbxVerifyJob && config.DbxVerifyQuartzCron != "" {
+		if err := validateCronExpression(config.DbxVerifyQuartzCron); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid dbx_verify_quartz_cron: %v", err))
+		}
+	}
+
+	if slices.ContainsFunc(config.SecretValues(), func(secret string) bool {
+		return secret != "" && secret != hlconfig.RedactedValue
+	}) {
+		warnings = append(warnings, fmt.Sprintf("the file holds secrets, keep them out of git by setting them with %s "+
+			"environment variables, e.g. %sHL_CLIENT_SECRET, instead", hlconfig.EnvPrefix, hlconfig.EnvPrefix))
+	}
+	for setting, missing := range map[string]bool{
+		"dbx_host":                 config.DbxHost == "",
+		"dbx_schemas":              len(config.DbxSchemas) == 0,
+		"hl_region, or hl_api_url": config.HlApiUrl == "" && config.HlRegion == "",
+	} {
+		if missing && !partial {
+			warnings = append(warnings, fmt.Sprintf("%s is not set, autoscan prompts for it, or fails with --non-interactive", setting))
+		}
+	}
+	slices.Sort(warnings)
+	return problems, warnings
+}
+
+// lintConfigUrls checks the URL settings: the workspace URL as autoscan normalizes it, and the HiddenLayer endpoints
+// and proxy as the scan jobs use them.
+func lintConfigUrls(config *utils.Config) []string {
+	var problems []string
+	if config.DbxHost != "" {
+		if _, err := dbx.NormalizeHost(config.DbxHost); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid dbx_host: %v", err))
+		}
+	}
+	for _, setting := range []struct{ key, value string }{
+		{"hl_api_url", config.HlApiUrl}, {"hl_auth_url", config.HlAuthUrl}, {"hl_console_url", config.HlConsoleUrl},
+		{"hl_https_proxy", config.HlHttpsProxy},
+	} {
+		if setting.value == "" {
+			continue
+		}
+		if parsed, err := url.Parse(setting.value); err != nil || parsed.Scheme != "http" && parsed.Scheme != "https" ||
+			parsed.Host == "" {
+			problems = append(problems, fmt.Sprintf("invalid %s %q, expected an http:// or https:// URL", setting.key,
+				setting.value))
+		}
+	}
+	// The other URLs of the scanners are checked by dbx.ValidateScanners
+	for i, scanner := range config.HlScanners {
+		if _, err := url.Parse(scanner.ApiUrl); err != nil {
+			problems = append(problems, fmt.Sprintf("invalid hl_scanners[%d].api_url %q, expected an https:// URL", i,
+				scanner.ApiUrl))
+		}
+	}
+	if config.HlCaBundlePath != "" && !strings.HasPrefix(config.HlCaBundlePath, "/Volumes/") {
+		problems = append(problems, fmt.Sprintf("invalid hl_ca_bundle_path %q, expected a file on a Unity Catalog "+
+			"Volume such as /Volumes/<catalog>/<schema>/<volume>/ca.pem", config.HlCaBundlePath))
+	}
+	return problems
+}
+
+func init() {
+	configLintCmd.Flags().BoolVar(&configLintStrict, "strict", false, "fail on warnings too")
+	configCmd.AddCommand(configLintCmd)
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+	"github.com/spf13/cobra"
+)
+
+var configProfilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "Lists the profiles of the configuration file",
+	Long: "Lists the profiles in the profiles section of the configuration file, e.g. one per deployment, with the " +
+		"Databricks workspace, the HiddenLayer API, and the number of monitored schemas of each, after merging its " +
+		"settings over those at the top of the file. Select one with --config-profile, or HLDBX_CONFIG_PROFILE. The " +
+		"selected profile is marked with *. Environment variables aren't applied.",
+	Example: "  hldbx config profiles\n  hldbx --config-profile staging autoscan",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if sandboxMode {
+			utils.Fatal("hldbx config profiles lists the profiles of the configuration file, which the sandbox doesn't use")
+		}
+		path, err := utils.ConfigFilePath()
+		if err != nil {
+			utils.Fatal(err)
+		}
+		selected, err := utils.ConfigProfile()
+		if err != nil {
+			utils.Fatal(err)
+		}
+		file, err := utils.ReadConfigFile(path)
+		if err != nil {
+			utils.Fatal(err)
+		}
+		var profiles []configProfileSummary
+		for _, name := range file.ProfileNames() {
+			settings, err := file.Profile(name)
+			if err != nil {
+				utils.Fatalf("Invalid configuration file %s: %v", path, err)
+			}
+			config, err := hlconfig.Load(settings)
+			if err != nil {
+				utils.Fatalf("Invalid profile %s in %s: %v", name, path, err)
+			}
+			profile := configProfileSummary{Name: name, Selected: name == selected, DbxHost: config.DbxHost,
+				HlApiUrl: config.HlApiUrl, Schemas: len(config.DbxSchemas)}
+			if profile.HlApiUrl == "" && config.HlRegion != "" {
+				profile.HlApiUrl = "hl_region " + config.HlRegion
+			}
+			profiles = append(profiles, profile)
+		}
+
+		if outputFormat == outputJson {
+			if profiles == nil {
+				profiles = []configProfileSummary{}
+			}
+			printJson(profiles)
+			return
+		}
+		if len(profiles) == 0 {
+			fmt.Printf("%s has no profiles, add them to its %s section\n", path, hlconfig.ProfilesKey)
+			return
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "\tPROFILE\tDATABRICKS WORKSPACE\tHIDDENLAYER API\tSCHEMAS")
+		for _, profile := range profiles {
+			marker := ""
+			if profile.Selected {
+				marker = "*"
+			}
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%d\n", marker, profile.Name, dashIfEmpty(profile.DbxHost),
+				dashIfEmpty(profile.HlApiUrl), profile.Schemas)
+		}
+		_ = table.Flush()
+	},
+}
+
+// configProfileSummary is a profile of the configuration file, as hldbx config profiles lists it.
+type configProfileSummary struct {
+	Name     string `json:"name"`
+	Selected bool   `json:"selected"`
+	DbxHost  string `json:"dbx_host,omitempty"`
+	HlApiUrl string `json:"hl_api_url,omitempty"`
+	Schemas  int    `json:"schemas"`
+}
+
+func init() {
+	supportJsonOutput(configProfilesCmd)
+	configCmd.AddCommand(configProfilesCmd)
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Prints the effective configuration, with secrets redacted",
+	Long: "Prints the settings that hldbx would use: those of the selected profile's configuration file, with those " +
+		"of its profile of --config-profile merged over them, if given, overridden by HLDBX_ environment variables, " +
+		"then by the flags given, which are those of autoscan, and the HiddenLayer URLs of hl_region. Each setting is " +
+		"annotated with where it comes from. Secrets, such as dbx_token, hl_client_secret, dbx_findings_sink_key, the " +
+		"client secrets of hl_scanners, and the password of hl_https_proxy, are replaced with " +
+		hlconfig.RedactedValue + ", so that the output can be shared when debugging. Settings that aren't shown " +
+		"take their defaults. Nothing is checked against Databricks or HiddenLayer; run hldbx validate for that.",
+	Example: "  hldbx config show\n  hldbx config show --cluster-id 0123-456789-abcdefgh --schema main.models\n" +
+		"  hldbx config show -o json",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if sandboxMode {
+			utils.Fatal("hldbx config show shows the configuration file, which the sandbox doesn't use")
+		}
+		path, err := utils.ConfigFilePath()
+		if err != nil {
+			utils.Fatal(err)
+		}
+		file, env, err := utils.ConfigSources()
+		if err != nil {
+			utils.Printf("Error reading the configuration file: %v\n", err)
+			os.Exit(1)
+		}
+		flags := configShowFlagSource(cmd)
+		config, err := hlconfig.Load(file, env, flags)
+		if err != nil {
+			utils.Fatalf("Invalid configuration: %v", err)
+		}
+		origins := configOrigins(path, file, env, flags)
+		urls := []*string{&config.HlApiUrl, &config.HlAuthUrl, &config.HlConsoleUrl}
+		var unset []bool
+		for _, url := range urls {
+			unset = append(unset, *url == "")
+		}
+		applyHlRegion(config)
+		for i, key := range []string{"hl_api_url", "hl_auth_url", "hl_console_url"} {
+			if unset[i] && *urls[i] != "" {
+				origins[key] = "hl_region " + config.HlRegion
+			}
+		}
+		utils.RegisterConfigSecrets(config)
+
+		redacted := config.Redacted()
+		settings := redacted.Source()
+		if outputFormat == outputJson {
+			printJson(configShowOutput{ConfigFile: path, Settings: settings, Origins: origins})
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			fmt.Printf("# No configuration file %s, showing the settings of the environment and flags\n", path)
+		} else if profile, _ := utils.ConfigProfile(); profile != "" {
+			fmt.Printf("# Effective configuration of profile %s of %s, with secrets redacted\n", profile, path)
+		} else {
+			fmt.Printf("# Effective configuration of %s, with secrets redacted\n", path)
+		}
+		out, err := configShowYaml(settings, origins)
+		if err != nil {
+			utils.Fatalf("Error encoding the configuration: %v", err)
+		}
+		fmt.Print(utils.Redact(out))
+	},
+}
+
+// configShowOutput is the output of hldbx config show with --output json.
+type configShowOutput struct {
+	ConfigFile string            `json:"config_file"`
+	Settings   hlconfig.Source   `json:"settings"`
+	Origins    map[string]string `json:"origins"` // where each setting comes from, by key
+}
+
+// configShowFlagSource returns the settings of the flags given to hldbx config show, like autoscanFlagSource,
+// with the workspace URL and token of --dbx-host and --dbx-token.
+func configShowFlagSource(cmd *cobra.Command) hlconfig.Source {
+	source := autoscanFlagSource(cmd)
+	if autoscanDbxHost != "" {
+		host, err := dbx.NormalizeHost(autoscanDbxHost)
+		if err != nil {
+			utils.Fatal(err)
+		}
+		source["dbx_host"] = host
+	}
+	if autoscanDbxToken != "" {
+		source["dbx_token"] = autoscanDbxToken
+	}
+	return source
+}
+
+// configOrigins returns where each setting comes from, by key: the configuration file, an environment variable, or
+// a flag, whichever was merged last.
+func configOrigins(path string, file, env, flags hlconfig.Source) map[string]string {
+	origins := map[string]string{}
+	for key := range file {
+		origins[strings.ToLower(key)] = path
+	}
+	for key := range env {
+		origins[key] = hlconfig.EnvPrefix + strings.ToUpper(key)
+	}
+	flagNames := map[string]string{"dbx_schemas": "schema", "dbx_host": "dbx-host", "dbx_token": "dbx-token"}
+	for _, flag := range settingFlags() {
+		flagNames[flag.key] = flag.name
+	}
+	for key := range flags {
+		name, ok := flagNames[key]
+		if !ok {
+			// The HiddenLayer URLs that --hl-region clears
+			name = "hl-region"
+		}
+		origins[key] = "--" + name
+	}
+	return origins
+}
+
+// configShowYaml returns the settings as a YAML configuration file, in the order of the fields of Config, with
+// where each comes from in a comment.
+func configShowYaml(settings hlconfig.Source, origins map[string]string) (string, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, setting := range hlconfig.Settings() {
+		value, ok := settings[setting.Key]
+		if !ok {
+			continue
+		}
+		valueNode := &yaml.Node{}
+		if err := valueNode.Encode(value); err != nil {
+			return "", fmt.Errorf("unable to marshal %s: %w", setting.Key, err)
+		}
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: setting.Key}
+		if origin := origins[setting.Key]; origin != "" {
+			keyNode.LineComment = "from " + origin
+		}
+		root.Content = append(root.Content, keyNode, valueNode)
+	}
+	if len(root.Content) == 0 {
+		return "", nil
+	}
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func init() {
+	supportJsonOutput(configShowCmd)
+	addSettingFlags(configShowCmd)
+	configCmd.AddCommand(configShowCmd)
+}
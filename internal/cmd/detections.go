@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var triageReason string
+var suppressRule string
+var suppressFor time.Duration
+
+var detectionsCmd = &cobra.Command{
+	Use:   "detections",
+	Short: "Triages the detections of scanned model versions",
+	Long: "Acknowledges detections, or suppresses the detections of a rule, so that triaged findings are no longer " +
+		"alerted on. Decisions are recorded in the scan tags of the model version.",
+}
+
+var detectionsAckCmd = &cobra.Command{
+	Use:     "acknowledge <catalog>.<schema>.<model> <version>",
+	Aliases: []string{"ack"},
+	Short:   "Acknowledges the detections of a model version",
+	Example: "  hldbx detections acknowledge prod.ml.fraud 7 --reason \"pickle imports reviewed, benign\"",
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		fullName, version := parseModelVersionArgs(args)
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		result, err := dbx.AcknowledgeDetection(context.Background(), dbxClient, fullName, version, triageReason)
+		if err != nil {
+			utils.Fatalf("Error acknowledging detections: %v", err)
+		}
+		fmt.Printf("Acknowledged the %s threat level detections of model %s version %d\n", result.ThreatLevel, fullName, version)
+	},
+}
+
+var detectionsSuppressCmd = &cobra.Command{
+	Use:     "suppress <catalog>.<schema>.<model> <version>",
+	Short:   "Suppresses the detections of a rule in a model version, until the suppression expires",
+	Example: "  hldbx detections suppress prod.ml.fraud 7 --rule PICKLE_0055_202408 --for 720h --reason \"vendor fix due\"",
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		fullName, version := parseModelVersionArgs(args)
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		expiresAt := time.Now().Add(suppressFor)
+		result, err := dbx.SuppressRule(context.Background(), dbxClient, fullName, version, suppressRule, triageReason, expiresAt)
+		if err != nil {
+			utils.Fatalf("Error suppressing rule %s: %v", suppressRule, err)
+		}
+		suppression := result.Suppressed[len(result.Suppressed)-1]
+		fmt.Printf("Suppressed rule %s for model %s version %d until %s\n", suppressRule, fullName, version,
+			suppression.ExpiresAt.Format(time.RFC3339))
+	},
+}
+
+// parseModelVersionArgs returns the full name and version number of the model version in the arguments.
+// Exit if they are invalid.
+func parseModelVersionArgs(args []string) (string, int) {
+	if parts := strings.Split(args[0], "."); len(parts) != 3 || slices.Contains(parts, "") {
+		utils.Fatalf("Invalid model name %q, expected <catalog>.<schema>.<model>", args[0])
+	}
+	version, err := strconv.Atoi(args[1])
+	if err != nil || version < 1 {
+		utils.Fatalf("Invalid model version %q, expected a version number", args[1])
+	}
+	return args[0], version
+}
+
+func init() {
+	for _, command := range []*cobra.Command{detectionsAckCmd, detectionsSuppressCmd} {
+		command.Flags().StringVar(&triageReason, "reason", "", "why the detections were triaged (required)")
+		_ = command.MarkFlagRequired("reason")
+		detectionsCmd.AddCommand(command)
+	}
+	detectionsSuppressCmd.Flags().StringVar(&suppressRule, "rule", "", "ID of the detection rule to suppress (required)")
+	_ = detectionsSuppressCmd.MarkFlagRequired("rule")
+	detectionsSuppressCmd.Flags().DurationVar(&suppressFor, "for", 30*24*time.Hour,
+		fmt.Sprintf("how long to suppress the rule for, at most %dh", int(dbx.MaxSuppression.Hours())))
+	rootCmd.AddCommand(detectionsCmd)
+}
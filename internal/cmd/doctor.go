@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/hl"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var doctorFix string
+var doctorClusterId string
+var doctorNodeType string
+var doctorSparkVersion string
+var doctorWorkers int
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnoses problems that would stop the installation or its jobs from working, and how to fix them",
+	Long: "Checks the installation and prints how to fix each check that fails:\n\n" +
+		"  - the Databricks credentials authenticate\n" +
+		"  - their identity can update the jobs, put secrets in the schemas' scopes, and write to the workspace directory\n" +
+		"  - the HiddenLayer API is reachable from this machine and accepts the credentials\n" +
+		"  - the Databricks Runtime of the jobs' cluster can run the notebooks\n" +
+		"  - the monitoring job records heartbeats on schedule\n" +
+		"  - the HiddenLayer credentials in the secrets scopes are younger than hl_credentials_max_age_days\n" +
+		"  - the clusters of the installed jobs and of the configuration file exist\n\n" +
+		"Nothing is changed, unless --fix moves the jobs, in place, to another cluster, job clusters, or serverless " +
+		"compute. Exits with an error if a check fails.",
+	Example: "  hldbx doctor\n  hldbx doctor --fix cluster --cluster-id 0123-456789-abcdefgh\n" +
+		"  hldbx doctor --fix job-cluster --workers 2\n  hldbx doctor --fix serverless",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if doctorFix != "" && !slices.Contains(dbx.ComputeFixes, doctorFix) {
+			utils.Fatalf("Invalid --fix %q, expected one of %s", doctorFix, strings.Join(dbx.ComputeFixes, ", "))
+		}
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		ctx := context.Background()
+		failed := 0
+		report := func(check dbx.DoctorCheck) {
+			if check.Ok {
+				fmt.Printf("OK: %s: %s\n", check.Name, check.Message)
+				return
+			}
+			failed++
+			fmt.Printf("FAIL: %s: %s\n", check.Name, check.Message)
+			if check.Remediation != "" {
+				fmt.Printf("  Fix: %s\n", check.Remediation)
+			}
+		}
+		report(doctorDbxIdentity(ctx, dbxClient, config))
+		for _, check := range dbx.CheckPermissions(ctx, dbxClient, config) {
+			report(check)
+		}
+		for _, check := range doctorHlChecks(config) {
+			report(check)
+		}
+		report(dbx.CheckRuntime(ctx, dbxClient, config))
+		report(dbx.HeartbeatCheck(ctx, dbxClient, config))
+		for _, check := range dbx.CredsAgeChecks(ctx, dbxClient, config) {
+			report(check)
+		}
+
+		status, err := dbx.CheckJobCompute(ctx, dbxClient, config)
+		if err != nil {
+			utils.Fatalf("Error checking the compute of the installed jobs: %v", err)
+		}
+		// --fix fixes the compute checks that fail, so they aren't counted as failed after it
+		otherFailed := failed
+		for _, check := range doctorComputeChecks(status, config) {
+			report(check)
+		}
+		if doctorFix == "" {
+			if failed > 0 {
+				utils.Fatalf("%d check(s) failed, see the fixes above", failed)
+			}
+			return
+		}
+
+		settings := computeFixSettings(cmd, config, dbxClient)
+		if err := validateSettings(config); err != nil {
+			utils.Fatalf("Invalid configuration: %v", err)
+		}
+		saveComputeFixSettings(settings)
+		jobIds, err := dbx.FixJobCompute(ctx, dbxClient, config)
+		for _, jobId := range jobIds {
+			fmt.Printf("Moved job %d to %s\n", jobId, computeDescription(config))
+		}
+		if err != nil {
+			utils.Fatalf("Error fixing the compute of the installed jobs: %v", err)
+		}
+		if len(jobIds) == 0 {
+			fmt.Println("No installed jobs to fix, run hldbx autoscan to create them")
+		}
+		if otherFailed > 0 {
+			utils.Fatalf("%d check(s) failed, see the fixes above", otherFailed)
+		}
+	},
+}
+
+// doctorComputeChecks reports the deleted clusters that the installed jobs, and the configuration file, refer to.
+func doctorComputeChecks(status *dbx.ComputeStatus, config *utils.Config) []dbx.DoctorCheck {
+	remediation := "re-run with --fix " + strings.Join(dbx.ComputeFixes, "|")
+	var checks []dbx.DoctorCheck
+	for _, deleted := range status.DeletedClusters {
+		checks = append(checks, dbx.DoctorCheck{Name: fmt.Sprintf("compute of job %s (%d)", deleted.JobName, deleted.JobId),
+			Message:     fmt.Sprintf("task %s runs on cluster %s, which was deleted, so every run fails", deleted.TaskKey, deleted.ClusterId),
+			Remediation: remediation})
+	}
+	if status.ConfigClusterDeleted {
+		checks = append(checks, dbx.DoctorCheck{Name: "dbx_cluster_id",
+			Message: fmt.Sprintf("cluster %s in the configuration file was deleted", config.DbxClusterId), Remediation: remediation})
+	}
+	if len(checks) == 0 {
+		checks = append(checks, dbx.DoctorCheck{Name: "compute", Ok: true,
+			Message: fmt.Sprintf("the compute of the %d installed job(s) exists", status.Jobs)})
+	}
+	return checks
+}
+
+// doctorDbxIdentity reports the identity that the Databricks credentials authenticated as. configDbxCreds has
+// already checked that they authenticate.
+func doctorDbxIdentity(ctx context.Context, dbxClient *databricks.WorkspaceClient, config *utils.Config) dbx.DoctorCheck {
+	check := dbx.DoctorCheck{Name: "Databricks"}
+	me, err := dbxClient.CurrentUser.Me(ctx)
+	if err != nil {
+		check.Message = fmt.Sprintf("unable to get the current user: %v", err)
+		check.Remediation = "check that the token is of a user or service principal with workspace access"
+		return check
+	}
+	check.Ok = true
+	check.Message = fmt.Sprintf("authenticated to %s as %s", config.DbxHost, cmp.Or(me.UserName, me.DisplayName, me.Id))
+	return check
+}
+
+// doctorHlChecks checks that the API of each scanner in use is reachable from this machine, and that those that
+// need credentials accept them. The jobs' compute may reach them differently, which autoscan checks from there.
+func doctorHlChecks(config *utils.Config) []dbx.DoctorCheck {
+	if config.HlApiUrl == "" {
+		return []dbx.DoctorCheck{{Name: "HiddenLayer", Message: "hl_region or hl_api_url is not set",
+			Remediation: "set hl_region, or hl_api_url for an enterprise scanner, in the configuration file"}}
+	}
+	tlsConfig, err := hl.TLSConfig(config.HlTlsMinVersion, config.HlTlsPins)
+	if err != nil {
+		return []dbx.DoctorCheck{{Name: "HiddenLayer", Message: fmt.Sprintf("invalid TLS settings: %v", err),
+			Remediation: "fix hl_tls_min_version and hl_tls_pins in the configuration file"}}
+	}
+	var checks []dbx.DoctorCheck
+	for _, scanner := range config.ScannersInUse() {
+		name := "HiddenLayer"
+		if scanner.Name != utils.DefaultScannerName {
+			name = "scanner " + scanner.Name
+		}
+		apiCheck := dbx.DoctorCheck{Name: name + " API"}
+		if latency, err := hl.Probe(scanner.ApiUrl, tlsConfig); err != nil {
+			apiCheck.Message = fmt.Sprintf("unable to reach %s: %v", scanner.ApiUrl, err)
+			apiCheck.Remediation = "check this machine's network and DNS, and set HTTPS_PROXY if it reaches the " +
+				"internet through a proxy; for an enterprise scanner, check that hl_api_url is its address"
+		} else {
+			apiCheck.Ok, apiCheck.Message = true, fmt.Sprintf("%s answered in %s", scanner.ApiUrl, latency.Round(time.Millisecond))
+		}
+		checks = append(checks, apiCheck)
+		if !scanner.UsesClientCredentials() {
+			continue
+		}
+		check := dbx.DoctorCheck{Name: name + " credentials"}
+		switch {
+		case scanner.ClientID == "" || scanner.ClientSecret == "":
+			check.Message = "the client ID and secret are not set"
+			check.Remediation = "set hl_client_id and hl_client_secret in the configuration file, or run hldbx autoscan to enter them"
+		default:
+			if _, err := hl.Auth(scanner.AuthUrl, scanner.ClientID, scanner.ClientSecret, tlsConfig); err != nil {
+				check.Message = fmt.Sprintf("unable to authenticate at %s: %v", scanner.AuthUrl, err)
+				check.Remediation = "check the client ID and secret in the HiddenLayer console, and update hl_client_id " +
+					"and hl_client_secret; if they were rotated, re-run hldbx autoscan to store the new ones"
+			} else {
+				check.Ok, check.Message = true, "authenticated at "+scanner.AuthUrl
+			}
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// configSetting is a setting of the configuration file, and the value to set it to.
+type configSetting struct {
+	key   string
+	value string
+}
+
+// computeFixSettings changes the compute of the configuration to that of --fix, and returns the settings to save,
+// in an order that keeps the configuration valid after each one is saved.
+func computeFixSettings(cmd *cobra.Command, config *utils.Config, dbxClient *databricks.WorkspaceClient) []configSetting {
+	var settings []configSetting
+	switch doctorFix {
+	case dbx.FixCluster:
+		clusterId := doctorClusterId
+		if clusterId == "" {
+			clusterId = retrieveClusterFromCommandLine(config, dbxClient)
+			if clusterId == "" {
+				utils.Fatal("No cluster to move the jobs to, exiting")
+			}
+		} else if !confirmCluster(config, clusterId, dbxClient) {
+			utils.Fatalf("Cluster %s can't run the jobs", clusterId)
+		}
+		config.DbxJobClusterNodeType, config.DbxServerless, config.DbxClusterId = "", false, clusterId
+		settings = []configSetting{{"dbx_job_cluster_node_type", ""}, {"dbx_serverless", "false"}, {"dbx_cluster_id", clusterId}}
+	case dbx.FixJobCluster:
+		sparkVersion, nodeType := doctorSparkVersion, doctorNodeType
+		if sparkVersion == "" || nodeType == "" {
+			defaultSparkVersion, defaultNodeType, err := dbx.DefaultJobCluster(context.Background(), dbxClient, config)
+			if err != nil {
+				utils.Fatalf("Error choosing the job cluster, set --spark-version and --node-type: %v", err)
+			}
+			sparkVersion = cmp.Or(sparkVersion, defaultSparkVersion)
+			nodeType = cmp.Or(nodeType, defaultNodeType)
+		}
+		if !cmd.Flags().Changed("workers") {
+			doctorWorkers = config.DbxJobClusterWorkers
+		}
+		config.DbxServerless, config.DbxJobClusterSpark, config.DbxJobClusterWorkers, config.DbxJobClusterNodeType =
+			false, sparkVersion, doctorWorkers, nodeType
+		settings = []configSetting{{"dbx_serverless", "false"}, {"dbx_job_cluster_spark_version", sparkVersion},
+			{"dbx_job_cluster_workers", strconv.Itoa(doctorWorkers)}, {"dbx_job_cluster_node_type", nodeType}}
+	case dbx.FixServerless:
+		config.DbxJobClusterNodeType, config.DbxServerless = "", true
+		settings = []configSetting{{"dbx_job_cluster_node_type", ""}, {"dbx_serverless", "true"}}
+	}
+	return settings
+}
+
+// saveComputeFixSettings saves the settings that changed in the configuration file, so that the next autoscan keeps
+// the new compute. The sandbox has no configuration file to save them in.
+func saveComputeFixSettings(settings []configSetting) {
+	if sandboxMode {
+		return
+	}
+	current := readConfig()
+	currentValues := map[string]string{
+		"dbx_cluster_id":                current.DbxClusterId,
+		"dbx_serverless":                strconv.FormatBool(current.DbxServerless),
+		"dbx_job_cluster_node_type":     current.DbxJobClusterNodeType,
+		"dbx_job_cluster_spark_version": current.DbxJobClusterSpark,
+		"dbx_job_cluster_workers":       strconv.Itoa(current.DbxJobClusterWorkers),
+	}
+	path, _ := utils.ConfigFilePath()
+	for _, setting := range settings {
+		if currentValues[setting.key] == setting.value {
+			continue
+		}
+		if _, err := utils.SetConfigValue(setting.key, setting.value, validateSettings); err != nil {
+			utils.Fatalf("Error setting %s: %v", setting.key, err)
+		}
+		fmt.Printf("Set %s in %s\n", setting.key, path)
+	}
+}
+
+// computeDescription describes the compute that the jobs of the configuration run on.
+func computeDescription(config *utils.Config) string {
+	switch {
+	case config.DbxServerless:
+		return "serverless compute"
+	case config.UsesJobCluster():
+		return fmt.Sprintf("job clusters of %s, %s, with %d worker(s)", config.DbxJobClusterNodeType,
+			config.DbxJobClusterSpark, config.DbxJobClusterWorkers)
+	default:
+		return "cluster " + config.DbxClusterId
+	}
+}
+
+func init() {
+	doctorCmd.Flags().StringVar(&doctorFix, "fix", "", "move the jobs to other compute: "+strings.Join(dbx.ComputeFixes, ", "))
+	doctorCmd.Flags().StringVar(&doctorClusterId, "cluster-id", "", "cluster to move the jobs to with --fix cluster, prompted for if not set")
+	_ = doctorCmd.RegisterFlagCompletionFunc("cluster-id", completeClusterIds)
+	doctorCmd.Flags().StringVar(&doctorNodeType, "node-type", "", "node type of the job clusters with --fix job-cluster, defaults to a small one of the workspace's cloud")
+	doctorCmd.Flags().StringVar(&doctorSparkVersion, "spark-version", "", "Databricks Runtime of the job clusters with --fix job-cluster, defaults to the latest LTS")
+	doctorCmd.Flags().IntVar(&doctorWorkers, "workers", 0, "workers of the job clusters with --fix job-cluster, 0 for a single node cluster")
+	rootCmd.AddCommand(doctorCmd)
+}
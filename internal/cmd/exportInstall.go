@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var exportInstallOutput string
+
+var exportInstallCmd = &cobra.Command{
+	Use:   "export-install",
+	Short: "Exports the installation's configuration and state to a tar file",
+	Long: "Packages the selected profile's configuration file and state directory, including the scan policy, " +
+		"monitored schemas, pending manual steps, and cached scan verdicts, into a tar file. Import it with " +
+		"hldbx import-install to re-create the same setup in another workspace or region, e.g. for disaster recovery " +
+		"or a workspace migration. Secrets are left out of the file, so autoscan asks for them again after an import.",
+	Example: "  hldbx export-install --out install.tar",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		file, err := os.OpenFile(exportInstallOutput, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			utils.Fatalf("Error creating %s: %v", exportInstallOutput, err)
+		}
+		manifest, err := utils.ExportInstall(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(exportInstallOutput)
+			utils.Fatalf("Error exporting the installation: %v", err)
+		}
+		fmt.Printf("Installation exported to %s (%d files, secrets left out)\n", exportInstallOutput, len(manifest.Files))
+	},
+}
+
+func init() {
+	exportInstallCmd.Flags().StringVar(&exportInstallOutput, "out", "hldbx-install.tar", "path of the tar file to write")
+	rootCmd.AddCommand(exportInstallCmd)
+}
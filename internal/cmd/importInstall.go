@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var importInstallForce bool
+
+var importInstallCmd = &cobra.Command{
+	Use:   "import-install <file> [workspace URL]",
+	Short: "Imports an installation exported with export-install",
+	Long: "Restores the configuration and state from a tar file written by hldbx export-install into the selected " +
+		"profile. Given a workspace URL, the configuration is pointed at that workspace, and its cluster and service " +
+		"principal are dropped since they belong to the old workspace. Run hldbx autoscan afterwards to install " +
+		"scanning with the imported settings; it asks for the secrets and anything else that is missing.",
+	Example: "  hldbx import-install install.tar https://dbc-a1b2c3d4-e5f6.cloud.databricks.com",
+	Args:    cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		dbxHost := ""
+		if len(args) == 2 {
+			var err error
+			if dbxHost, err = checkDbxHost(args[1]); err != nil {
+				utils.Fatal(err)
+			}
+		}
+		file, err := os.Open(args[0])
+		if err != nil {
+			utils.Fatalf("Error opening %s: %v", args[0], err)
+		}
+		defer file.Close()
+		manifest, err := utils.ImportInstall(file, dbxHost, importInstallForce)
+		if err != nil {
+			utils.Fatalf("Error importing the installation: %v", err)
+		}
+		fmt.Printf("Imported the installation exported by hldbx %s on %s", manifest.Version, manifest.ExportedAt.Format("2006-01-02"))
+		if manifest.DbxHost != "" {
+			fmt.Printf(" from %s", manifest.DbxHost)
+		}
+		fmt.Println()
+		if manifest.Version != utils.Version {
+			slog.Warn("The installation was exported by a different hldbx version than this one", "exported_by",
+				manifest.Version, "hldbx_version", utils.Version)
+		}
+		fmt.Println("Run hldbx autoscan to install scanning with the imported settings")
+	},
+}
+
+func init() {
+	importInstallCmd.Flags().BoolVar(&importInstallForce, "force", false, "replace the profile's existing configuration")
+	rootCmd.AddCommand(importInstallCmd)
+}
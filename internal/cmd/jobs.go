@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	jobsListScanJobs bool
+	jobsDeleteYes    bool
+)
+
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "Inspects and deletes the jobs that hldbx manages",
+}
+
+var jobsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the jobs that hldbx created in the workspace, to audit what scanning is deployed",
+	Long: "Lists the jobs that hldbx created, found by their hl_job tag, or by their default names for jobs created " +
+		"before they were tagged: the monitoring job, and the guardrail, verification, and on-demand scan jobs if " +
+		"they are enabled. Shows each job's schedule, the identity it runs as, the schemas it monitors, and the " +
+		"outcome of its latest run. With --scan-jobs, the scan jobs that the monitoring job created for each model " +
+		"version are listed too, found by their hl_job tag. Scan jobs created before they were tagged aren't listed.",
+	Example: "  hldbx jobs list\n  hldbx jobs list --scan-jobs -o json",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		managed, err := dbx.ListManagedJobs(context.Background(), dbxClient, jobsListScanJobs)
+		if err != nil {
+			utils.Fatalf("Error listing the jobs: %v", err)
+		}
+
+		if outputFormat == outputJson {
+			if managed == nil {
+				managed = []dbx.ManagedJob{}
+			}
+			printJson(managed)
+			return
+		}
+		if len(managed) == 0 {
+			fmt.Println("No jobs created by hldbx in this workspace, run hldbx autoscan to create them")
+			return
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "JOB ID\tNAME\tKIND\tSCHEDULE\tRUN AS\tSCHEMAS\tLAST RUN")
+		for _, job := range managed {
+			fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", job.JobId, job.Name, job.Kind, jobSchedule(job),
+				dashIfEmpty(job.RunAs), dashIfEmpty(strings.Join(job.Schemas, ", ")), jobLastRun(job.LastRun))
+		}
+		_ = table.Flush()
+	},
+}
+
+var jobsDeleteCmd = &cobra.Command{
+	Use:   "delete <job ID or name>",
+	Short: "Deletes a job that hldbx manages, keeping its state consistent",
+	Long: "Deletes a job that hldbx created, or a scan job that the monitoring job created, by its ID or name. Jobs " +
+		"that hldbx doesn't manage, including untagged jobs that are only named like scan jobs, are refused. The " +
+		"job's active runs are canceled, and waited for, before it's deleted. For a scan job, a model version whose " +
+		"scan was pending on a canceled run is marked failed, as when the scan times out. For the other jobs, the " +
+		"installation manifest drops the job, and the install audit log records the deletion. Run hldbx autoscan to " +
+		"recreate a deleted job.",
+	Example: "  hldbx jobs delete 123456789\n  hldbx jobs delete hl_verify_install --yes",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		ctx := context.Background()
+		job, err := dbx.FindManagedJob(ctx, dbxClient, args[0])
+		if err != nil {
+			utils.Fatalf("Error finding the job: %v", err)
+		}
+		fmt.Printf("Deleting %s job %s (ID %d)\n", job.Kind, job.Name, job.JobId)
+		if job.IsMonitor() {
+			slog.Warn("Without the monitoring job, new model versions aren't scanned")
+		}
+		if !jobsDeleteYes {
+			choice := inputStringValue("y to delete the job, or n not to (default: n)", false, false, "n")
+			if choice != "y" {
+				fmt.Println("Nothing was deleted")
+				return
+			}
+		}
+		deleted, err := dbx.DeleteManagedJob(ctx, dbxClient, config, *job, cmd.CommandPath())
+		if err != nil {
+			utils.Fatalf("Error deleting job %d: %v", job.JobId, err)
+		}
+
+		if outputFormat == outputJson {
+			printJson(deleted)
+			return
+		}
+		for _, runId := range deleted.CanceledRuns {
+			fmt.Printf("Canceled run %d\n", runId)
+		}
+		if deleted.FailedScan != "" {
+			fmt.Printf("Marked the pending scan of %s failed, the monitoring job can scan it again\n", deleted.FailedScan)
+		}
+		if deleted.ManifestUpdated {
+			fmt.Println("Removed the job from the installation manifest")
+		}
+		fmt.Printf("Deleted job %d\n", job.JobId)
+	},
+}
+
+// jobSchedule describes the schedule of a job, for the table.
+func jobSchedule(job dbx.ManagedJob) string {
+	switch {
+	case job.Schedule == "":
+		return "-"
+	case job.Paused:
+		return fmt.Sprintf("%s %s, PAUSED", job.Schedule, job.Timezone)
+	default:
+		return fmt.Sprintf("%s %s", job.Schedule, job.Timezone)
+	}
+}
+
+// jobLastRun describes the latest run of a job, for the table.
+func jobLastRun(run *dbx.RunSummary) string {
+	switch {
+	case run == nil:
+		return "none yet"
+	case run.Failed():
+		return fmt.Sprintf("%s, %s (%s)", run.StartTime.Format(time.DateTime), run.State, run.Failure)
+	default:
+		return fmt.Sprintf("%s, %s", run.StartTime.Format(time.DateTime), run.State)
+	}
+}
+
+func init() {
+	jobsListCmd.Flags().BoolVar(&jobsListScanJobs, "scan-jobs", false, "also list the scan jobs that the monitoring job created")
+	jobsDeleteCmd.Flags().BoolVarP(&jobsDeleteYes, "yes", "y", false, "delete without asking for confirmation")
+	supportJsonOutput(jobsListCmd, jobsDeleteCmd)
+	jobsCmd.AddCommand(jobsListCmd, jobsDeleteCmd)
+	rootCmd.AddCommand(jobsCmd)
+}
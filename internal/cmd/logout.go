@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/keyring"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout [workspace URL]",
+	Short: "Deletes the Databricks token stored in the OS keyring for a workspace",
+	Long: "Deletes the Databricks token that was stored in the OS keyring when it was entered at the prompt, for the " +
+		"workspace given, or that of dbx_host in the configuration file. The next command prompts for a token again.",
+	Example: "  hldbx logout\n  hldbx logout https://adb-1234567890123456.7.azuredatabricks.net",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if sandboxMode {
+			utils.Fatal("The sandbox doesn't store tokens in the OS keyring")
+		}
+		host := ""
+		if len(args) > 0 {
+			host = args[0]
+		} else {
+			host = readConfig().DbxHost
+		}
+		if host == "" {
+			utils.Fatal("No workspace to log out of, pass its URL or set dbx_host in the configuration file")
+		}
+		if !keyring.Available() {
+			utils.Fatal("This machine has no OS keyring that hldbx can use")
+		}
+		if err := keyring.DeleteDbxToken(keyringAccount(host)); err != nil {
+			utils.Fatalf("Error deleting the Databricks token from the OS keyring: %v", err)
+		}
+		fmt.Printf("Deleted any Databricks token stored in the OS keyring for %s\n", keyringAccount(host))
+	},
+}
+
+// keyringAccount returns the workspace URL that its token is stored under in the OS keyring, in its canonical
+// form, so that it is found whichever form it is given in.
+func keyringAccount(dbxHost string) string {
+	if host, err := dbx.NormalizeHost(dbxHost); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(dbxHost, "/")
+}
+
+// keyringToken returns the Databricks token stored in the OS keyring for the workspace, or "" if there is none.
+func keyringToken(dbxHost string) string {
+	if sandboxMode || utils.InDatabricks() || !keyring.Available() {
+		return ""
+	}
+	token, err := keyring.LoadDbxToken(keyringAccount(dbxHost))
+	if err != nil {
+		if !errors.Is(err, keyring.ErrNotFound) && !errors.Is(err, keyring.ErrUnavailable) {
+			utils.Printf("Unable to read the Databricks token from the OS keyring: %v\n", err)
+		}
+		return ""
+	}
+	fmt.Println("Using the Databricks token stored in the OS keyring")
+	return token
+}
+
+// offerKeyring offers to store a Databricks token that was entered at the prompt in the OS keyring, so that it isn't
+// asked for again on this machine, rather than in the configuration file in plain text.
+func offerKeyring(dbxHost string, token string) {
+	if utils.InDatabricks() || !keyring.Available() {
+		return
+	}
+	choice := inputStringValue("y to store the Databricks token in the OS keyring, so it isn't asked for again on this machine, or n not to (default: n)", false, false, "n")
+	if !strings.EqualFold(choice, "y") {
+		return
+	}
+	expiry, err := keyring.StoreDbxToken(keyringAccount(dbxHost), token)
+	if err != nil {
+		utils.Printf("Unable to store the Databricks token in the OS keyring: %v\n", err)
+		return
+	}
+	if expiry.IsZero() {
+		fmt.Println("Stored the Databricks token in the OS keyring, run hldbx logout to delete it")
+	} else {
+		fmt.Printf("Stored the Databricks token in the OS keyring until it expires at %s, run hldbx logout to delete it\n",
+			expiry.Local().Format("2006-01-02 15:04"))
+	}
+}
+
+// forgetKeyringToken deletes the Databricks token stored in the OS keyring for the workspace, when it no longer works,
+// e.g. because it was revoked, so that it isn't used again.
+func forgetKeyringToken(dbxHost string) {
+	if err := keyring.DeleteDbxToken(keyringAccount(dbxHost)); err != nil {
+		utils.Printf("Unable to delete the Databricks token from the OS keyring: %v\n", err)
+		return
+	}
+	fmt.Println("Deleted the Databricks token stored in the OS keyring, which no longer works")
+}
+
+func init() {
+	rootCmd.AddCommand(logoutCmd)
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var logsRunId int64
+var logsFollow bool
+var logsInterval time.Duration
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Prints the output of a monitoring job run",
+	Long: "Prints the output of the latest run of the monitoring job, or of the run given by --run-id, without " +
+		"opening the Databricks UI: for each of its tasks, the value its notebook exited with, the error it failed " +
+		"with and its stack trace, and the logs that the Jobs API keeps. The Jobs API only has the output of a task " +
+		"once it ended. With --follow, it polls the run until it ends, printing the output of each task as it ends. " +
+		"Secrets are redacted.",
+	Example: "  hldbx logs\n  hldbx logs --run-id 123456789 --follow",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if logsInterval < time.Second {
+			utils.Fatal("--interval must be at least 1s")
+		}
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		// Ctrl+C stops following, the run goes on
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		runLogs, err := dbx.GetRunLogs(ctx, dbxClient, logsRunId)
+		if err != nil {
+			utils.Fatalf("Error getting the monitoring job run's output: %v", err)
+		}
+		printed := map[int64]bool{}
+		if outputFormat != outputJson {
+			fmt.Printf("Run %d of monitoring job %d is %s\n", runLogs.RunId, runLogs.JobId, runLogs.State)
+			if runLogs.Url != "" {
+				fmt.Printf("See %s\n", runLogs.Url)
+			}
+			printTaskLogs(runLogs, printed, !logsFollow)
+		}
+		lastState := runLogs.State
+		for logsFollow && !runLogs.Ended {
+			select {
+			case <-ctx.Done():
+				utils.Fatalf("Stopped following run %d, it keeps running", runLogs.RunId)
+			case <-time.After(logsInterval):
+			}
+			if runLogs, err = dbx.GetRunLogs(ctx, dbxClient, runLogs.RunId); err != nil {
+				utils.Fatalf("Error getting the monitoring job run's output: %v", err)
+			}
+			if outputFormat == outputJson {
+				continue
+			}
+			if runLogs.State != lastState {
+				fmt.Printf("%s Run %d is %s\n", time.Now().Format(time.TimeOnly), runLogs.RunId, runLogs.State)
+				lastState = runLogs.State
+			}
+			printTaskLogs(runLogs, printed, false)
+		}
+
+		if outputFormat == outputJson {
+			printJson(redactRunLogs(runLogs))
+		}
+	},
+}
+
+// printTaskLogs prints the output of the tasks of a run that ended and aren't in printed yet, adding them to it.
+// With pending, it also lists the tasks that haven't ended.
+func printTaskLogs(runLogs *dbx.RunLogs, printed map[int64]bool, pending bool) {
+	for _, task := range runLogs.Tasks {
+		if printed[task.RunId] || (!task.Ended && !pending) {
+			continue
+		}
+		fmt.Println()
+		fmt.Printf("== Task %s (run %d): %s\n", task.TaskKey, task.RunId, task.State)
+		if !task.Ended {
+			fmt.Println("No output until the task ends, use --follow to wait for it")
+			continue
+		}
+		printed[task.RunId] = true
+		printLogSection("Result", task.Result)
+		printLogSection("Error", task.Error)
+		printLogSection("Stack trace", task.ErrorTrace)
+		printLogSection("Logs", task.Logs)
+		if task.Truncated {
+			fmt.Println("(The output was truncated by the Jobs API, see the run in Databricks for all of it)")
+		}
+		if task.Result == "" && task.Error == "" && task.Logs == "" {
+			fmt.Println("No output")
+		}
+	}
+}
+
+// printLogSection prints a titled section of a task's output, with secrets redacted, unless it's empty.
+func printLogSection(title, text string) {
+	if text == "" {
+		return
+	}
+	fmt.Printf("%s:\n%s\n", title, strings.TrimRight(utils.Redact(text), "\n"))
+}
+
+// redactRunLogs returns the output of a run with the secrets in it redacted.
+func redactRunLogs(runLogs *dbx.RunLogs) *dbx.RunLogs {
+	redacted := *runLogs
+	redacted.Tasks = make([]dbx.TaskLog, len(runLogs.Tasks))
+	for i, task := range runLogs.Tasks {
+		task.Result = utils.Redact(task.Result)
+		task.Error = utils.Redact(task.Error)
+		task.ErrorTrace = utils.Redact(task.ErrorTrace)
+		task.Logs = utils.Redact(task.Logs)
+		redacted.Tasks[i] = task
+	}
+	return &redacted
+}
+
+func init() {
+	logsCmd.Flags().Int64Var(&logsRunId, "run-id", 0, "run of the monitoring job to print the output of (default the latest run)")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "poll the run until it ends, printing the output of each task as it ends")
+	logsCmd.Flags().DurationVar(&logsInterval, "interval", 10*time.Second, "with --follow, how often to poll the run")
+	supportJsonOutput(logsCmd)
+	rootCmd.AddCommand(logsCmd)
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlmodelmap"
+	"github.com/spf13/cobra"
+)
+
+var mapModel string
+var mapVersion int
+var mapHlModelId string
+var mapScanId string
+var mapWarehouseId string
+
+var mapCmd = &cobra.Command{
+	Use:   "map",
+	Short: "Maps model versions to their HiddenLayer model and scan IDs",
+}
+
+var mapLookupCmd = &cobra.Command{
+	Use:   "lookup",
+	Short: "Looks up the HiddenLayer model and scan IDs of model versions, or the model versions of HiddenLayer IDs",
+	Long: "Queries the table next to the state table that the scan jobs record each scanned model version in, with " +
+		"the HiddenLayer model ID and scan ID of its latest scan, through the SQL warehouse of --warehouse-id. Look up " +
+		"by --model, optionally with --version, by --hl-model-id, or by --scan-id.",
+	Example: "  hldbx map lookup --model main.models.fraud --version 3 --warehouse-id 1234567890abcdef\n" +
+		"  hldbx map lookup --scan-id 0f5c3ed2-7b1e-4c8e-9a52-2d4a6f0b9e1c --warehouse-id 1234567890abcdef -o json",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if mapVersion < 0 || (mapVersion > 0 && mapModel == "") {
+			utils.Fatal("--version must be a positive version number of the model of --model")
+		}
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		query := hlmodelmap.Query{Model: mapModel, Version: mapVersion, HlModelId: mapHlModelId, ScanId: mapScanId}
+		mappings, err := hlmodelmap.Lookup(context.Background(), dbxClient, config, mapWarehouseId, query)
+		if err != nil {
+			utils.Fatalf("Error looking up the model mappings: %v", err)
+		}
+
+		if outputFormat == outputJson {
+			if mappings == nil {
+				mappings = []hlmodelmap.Mapping{}
+			}
+			printJson(mappings)
+			return
+		}
+		if len(mappings) == 0 {
+			fmt.Printf("No mappings found in %s\n", hlmodelmap.Table(config))
+			return
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "MODEL\tVERSION\tHL MODEL ID\tSCAN ID\tTHREAT LEVEL\tSCANNED AT")
+		for _, mapping := range mappings {
+			fmt.Fprintf(table, "%s\t%d\t%s\t%s\t%s\t%s\n", mapping.Model, mapping.Version, dashIfEmpty(mapping.HlModelId),
+				mapping.ScanId, dashIfEmpty(mapping.ThreatLevel), dashIfEmpty(mapping.ScannedAt))
+		}
+		_ = table.Flush()
+	},
+}
+
+// dashIfEmpty returns a dash for an empty value, to keep the columns of a table aligned.
+func dashIfEmpty(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func init() {
+	mapLookupCmd.Flags().StringVar(&mapModel, "model", "", "full name of the model, <catalog>.<schema>.<model_name>")
+	mapLookupCmd.Flags().IntVar(&mapVersion, "version", 0, "version of the model of --model (default: every version)")
+	mapLookupCmd.Flags().StringVar(&mapHlModelId, "hl-model-id", "", "HiddenLayer model ID")
+	mapLookupCmd.Flags().StringVar(&mapScanId, "scan-id", "", "HiddenLayer scan ID")
+	mapLookupCmd.Flags().StringVar(&mapWarehouseId, "warehouse-id", "", "SQL warehouse to query the mappings with (required)")
+	supportJsonOutput(mapLookupCmd)
+	_ = mapLookupCmd.MarkFlagRequired("warehouse-id")
+	mapLookupCmd.MarkFlagsMutuallyExclusive("model", "hl-model-id", "scan-id")
+	mapLookupCmd.MarkFlagsOneRequired("model", "hl-model-id", "scan-id")
+	mapCmd.AddCommand(mapLookupCmd)
+	rootCmd.AddCommand(mapCmd)
+}
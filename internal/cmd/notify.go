@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Tests the delivery of detections to the findings sink",
+}
+
+var notifyVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verifies that the webhook findings sink checks the signatures of findings",
+	Long: "Posts test findings to the webhook of dbx_findings_sink, signed with the signing secret that the scan jobs " +
+		"read from the Databricks secrets of each schema. The webhook must accept a correctly signed finding, and " +
+		"reject one with a forged signature and one signed more than " + dbx.WebhookMaxSkew.String() + " ago. " +
+		"Test findings have a test flag, and a title saying that they aren't detections.",
+	Example: "  hldbx notify verify",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		checks, err := dbx.VerifyWebhook(ctx, dbxClient, config)
+		if err != nil {
+			utils.Fatalf("Error verifying the webhook: %v", err)
+		}
+		failed := 0
+		for _, check := range checks {
+			if check.Ok {
+				utils.Printf("OK: %s: %s\n", check.Name, check.Message)
+			} else {
+				utils.Printf("FAIL: %s: %s\n", check.Name, check.Message)
+				failed++
+			}
+		}
+		if failed > 0 {
+			utils.Fatalf("%d of %d webhook check(s) failed", failed, len(checks))
+		}
+		fmt.Printf("The webhook %s verifies the signatures of findings\n", config.DbxFindingsSink)
+	},
+}
+
+func init() {
+	notifyCmd.AddCommand(notifyVerifyCmd)
+	rootCmd.AddCommand(notifyCmd)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// outputFormat is set by --output, a flag of every command: text, or json for the commands that support it, which
+// then print structured JSON for wrapper tools to parse instead of their messages.
+var outputFormat string
+
+// Output formats of --output
+const (
+	outputText = "text"
+	outputJson = "json"
+)
+
+// jsonOut is where the commands write their JSON output: stdout, which carries nothing else with --output json
+var jsonOut io.Writer = os.Stdout
+
+// Annotation of the commands that support --output json, see supportJsonOutput
+const jsonOutputAnnotation = "hldbx_json_output"
+
+// supportJsonOutput marks commands as supporting --output json.
+func supportJsonOutput(commands ...*cobra.Command) {
+	for _, command := range commands {
+		if command.Annotations == nil {
+			command.Annotations = map[string]string{}
+		}
+		command.Annotations[jsonOutputAnnotation] = "true"
+	}
+}
+
+// checkOutputFormat exits if --output isn't a known format, or is json for a command that doesn't support it.
+// With json, everything else that the command prints, including its prompts, warnings, and errors, moves to stderr,
+// so that it doesn't mix in with the JSON on stdout.
+func checkOutputFormat(cmd *cobra.Command) {
+	switch {
+	case cmd == exportInstallCmd && outputFormat != outputText && outputFormat != outputJson && !cmd.Flags().Changed("out"):
+		// -o was the shorthand of --out of export-install before --output took it, so scripts may still pass the path
+		// of the tar file with it
+		slog.Warn("-o is the shorthand of --output, use --out for the tar file", "out", outputFormat)
+		exportInstallOutput, outputFormat = outputFormat, outputText
+	case outputFormat != outputText && outputFormat != outputJson:
+		utils.Fatalf("Invalid output format %q, expected %s or %s", outputFormat, outputText, outputJson)
+	case outputFormat == outputJson && cmd.Annotations[jsonOutputAnnotation] == "":
+		utils.Fatalf("%s doesn't support --output json, only %s do", cmd.CommandPath(), strings.Join(jsonOutputCommands(), ", "))
+	case outputFormat == outputJson:
+		jsonOut = os.Stdout
+		os.Stdout = os.Stderr
+	}
+}
+
+// jsonOutputCommands returns the paths of the commands that support --output json, sorted.
+func jsonOutputCommands() []string {
+	var paths []string
+	var walk func(*cobra.Command)
+	walk = func(command *cobra.Command) {
+		if command.Annotations[jsonOutputAnnotation] != "" {
+			paths = append(paths, command.CommandPath())
+		}
+		for _, child := range command.Commands() {
+			walk(child)
+		}
+	}
+	walk(rootCmd)
+	sort.Strings(paths)
+	return paths
+}
+
+// printJson prints a value to stdout as indented JSON.
+func printJson(value any) {
+	encoder := json.NewEncoder(jsonOut)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		utils.Fatalf("Error encoding the output: %v", err)
+	}
+}
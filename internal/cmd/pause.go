@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Pauses the monitoring job's schedule, e.g. for a maintenance window",
+	Long: "Pauses the schedule of the monitoring job, so that new model versions aren't scanned until hldbx resume. " +
+		"Nothing is deleted, and runs in progress aren't canceled. Re-running autoscan or apply keeps the schedule " +
+		"paused. hldbx status shows who paused it, and when. The install audit log records the change.",
+	Example: "  hldbx pause\n  hldbx resume",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		setMonitorJobPaused(true)
+	},
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resumes the monitoring job's schedule after hldbx pause",
+	Long: "Resumes the schedule of the monitoring job that hldbx pause paused, so that new model versions are " +
+		"scanned at its next run. The versions registered while it was paused are scanned then, or right away with " +
+		"hldbx run-now. The install audit log records the change.",
+	Example: "  hldbx resume\n  hldbx run-now",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		setMonitorJobPaused(false)
+	},
+}
+
+// setMonitorJobPaused pauses or resumes the monitoring job's schedule, and prints what changed.
+func setMonitorJobPaused(paused bool) {
+	config := readConfig()
+	dbxClient := configDbxCreds(config)
+	change, err := dbx.SetMonitorJobPaused(context.Background(), dbxClient, config, paused)
+	if err != nil {
+		utils.Fatalf("Error changing the monitoring job's schedule: %v", err)
+	}
+
+	if outputFormat == outputJson {
+		printJson(change)
+		return
+	}
+	job := fmt.Sprintf("monitoring job %s (ID %d)", change.Name, change.JobId)
+	switch {
+	case !change.Changed && paused:
+		fmt.Printf("The schedule of %s is already paused\n", job)
+	case !change.Changed:
+		fmt.Printf("The schedule of %s isn't paused\n", job)
+	case paused:
+		fmt.Printf("Paused the schedule of %s: new model versions aren't scanned until hldbx resume. Runs in progress "+
+			"continue.\n", job)
+	default:
+		fmt.Printf("Resumed the schedule of %s: %s UTC. Run hldbx run-now to scan the model versions registered while "+
+			"it was paused right away.\n", job, change.Schedule)
+	}
+}
+
+func init() {
+	supportJsonOutput(pauseCmd, resumeCmd)
+	rootCmd.AddCommand(pauseCmd, resumeCmd)
+}
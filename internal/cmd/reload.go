@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/hl"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// reloadMode is set by --reload, a flag of the long-running commands, such as watch: they reload the configuration
+// file when it changes, instead of keeping the settings they started with.
+var reloadMode bool
+
+// configReloader returns a function that returns the configuration to use now, for long-running commands: config,
+// which readConfig returned and the command set up, or with --reload, the configuration file's once it changes,
+// checked as readConfig and autoscan check it. A configuration that can't be loaded or is invalid is reported and
+// ignored, keeping the previous one. The workspace and its credentials can't change while the command runs, since
+// its client is already signed in to it, so a changed dbx_host is reported and ignored too.
+func configReloader(config *utils.Config) func() *utils.Config {
+	if !reloadMode || sandboxMode {
+		return func() *utils.Config { return config }
+	}
+	loader, err := utils.NewConfigLoader()
+	if err == nil {
+		_, err = loader.Load()
+	}
+	if err != nil {
+		utils.Fatalf("Error reading the configuration file: %v", utils.Redact(err.Error()))
+	}
+	path := loader.Path
+	return func() *utils.Config {
+		loaded, reloaded, err := loader.Reload()
+		if err != nil {
+			slog.Warn("Unable to reload the configuration, keeping the previous one", "path", path, "error", err)
+			return config
+		}
+		if !reloaded {
+			return config
+		}
+		// The loaded configuration is shared with the loader, so the copy is set up
+		next := *loaded
+		utils.RegisterConfigSecrets(&next)
+		if err := checkReloadedConfig(&next); err != nil {
+			slog.Warn("Invalid configuration, keeping the previous one", "path", path, "error", err)
+			return config
+		}
+		applyHlRegion(&next)
+		if next.DbxHost != "" {
+			host, err := dbx.NormalizeHost(next.DbxHost)
+			if err != nil {
+				slog.Warn("Invalid configuration, keeping the previous one", "path", path, "error", err)
+				return config
+			}
+			if host != config.DbxHost {
+				slog.Warn("Changed dbx_host, keeping the previous one until a restart", "path", path, "dbx_host", host)
+			}
+		}
+		next.DbxHost, next.DbxToken = config.DbxHost, config.DbxToken
+		if err := validateSettings(&next); err != nil {
+			slog.Warn("Invalid configuration, keeping the previous one", "path", path, "error", err)
+			return config
+		}
+		if err := utils.ConfigureUserAgentSuffix(&next); err != nil {
+			slog.Warn("Invalid configuration, keeping the previous one", "path", path, "error", err)
+			return config
+		}
+		fmt.Printf("Reloaded the configuration from %s\n", path)
+		config = &next
+		return config
+	}
+}
+
+// checkReloadedConfig checks what readConfig and the validators exit on, rather than return, in a reloaded
+// configuration: hl_region, and URLs that don't parse.
+func checkReloadedConfig(config *utils.Config) error {
+	if config.HlRegion != "" && !strings.EqualFold(config.HlRegion, hl.CustomRegion) {
+		if _, ok := hl.LookupRegion(config.HlRegion); !ok {
+			return fmt.Errorf("invalid hl_region %q, expected one of us, eu, or custom", config.HlRegion)
+		}
+	}
+	if problems := lintConfigUrls(config); len(problems) > 0 {
+		return errors.New(problems[0])
+	}
+	return nil
+}
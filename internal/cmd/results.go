@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	resultsFilter  dbx.ResultFilter
+	resultsSchemas []string
+	resultsSince   string
+	resultsSource  string
+	resultsSummary bool
+)
+
+var resultsCmd = &cobra.Command{
+	Use:   "results",
+	Short: "Lists the scan results of model versions, filtered by outcome, owner, MLflow tag, alias, and serving",
+	Long: "Lists the scan results of the model versions that the scan trigger picks in the monitored schemas, or " +
+		"those of --schema, and the other versions there that Model Serving endpoints serve, such as older versions " +
+		"with the new_version trigger, with their models' owners, their aliases, and the endpoints that serve them. " +
+		"Each filter flag can be repeated, or given comma-separated values, and selects the versions that " +
+		"match any of its values; versions must match every flag that is given. --result is one of " +
+		strings.Join(dbx.ResultOutcomes, ", ") + ", where unscanned includes the versions being scanned and those " +
+		"waiting for the HiddenLayer API. --since keeps the versions scanned within a duration, such as 7d or 12h. " +
+		"The results come from the scan tags of the model versions, or with --source hiddenlayer, from the latest " +
+		"finished scan of each version in the HiddenLayer API. --summary counts the outcomes of each model instead.",
+	Example: "  hldbx results --result unsafe,unscanned --owner fraud-analytics --served\n" +
+		"  hldbx results --tag team=fraud --alias prod -o json\n" +
+		"  hldbx results --schema main.models --since 7d --summary",
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := resultsFilter.Validate(); err != nil {
+			utils.Fatal(err)
+		}
+		if resultsSince != "" {
+			since, err := parseSince(resultsSince)
+			if err != nil {
+				utils.Fatalf("Invalid --since: %v", err)
+			}
+			resultsFilter.Since = time.Now().Add(-since)
+		}
+		config := readConfig()
+		if len(resultsSchemas) > 0 {
+			config.DbxSchemas = selectSchemas(config.DbxSchemas, resultsSchemas)
+		}
+		dbxClient := configDbxCreds(config)
+		if len(config.DbxSchemas) == 0 {
+			utils.Fatal("No schemas to list the results of, add dbx_schemas to the configuration file, or pass --schema")
+		}
+		if resultsSource == dbx.ResultSourceHiddenLayer && config.HlApiUrl == "" {
+			utils.Fatal("No HiddenLayer API to get the results from, set hl_region or hl_api_url in the configuration file")
+		}
+		results, err := dbx.ListModelResults(context.Background(), dbxClient, config, resultsFilter, resultsSource)
+		if err != nil {
+			utils.Fatalf("Error listing the scan results: %v", err)
+		}
+
+		if resultsSummary {
+			printResultsSummary(dbx.SummarizeResults(results))
+			return
+		}
+		if outputFormat == outputJson {
+			if results == nil {
+				results = []dbx.ModelResult{}
+			}
+			printJson(results)
+			return
+		}
+		if len(results) == 0 {
+			fmt.Println("No model versions match")
+			return
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "MODEL\tVERSION\tRESULT\tTHREAT LEVEL\tOWNER\tSERVED BY")
+		for _, result := range results {
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", result.Model, result.VersionLabel(), result.Result,
+				dashIfEmpty(result.ThreatLevel), dashIfEmpty(result.Owner), dashIfEmpty(strings.Join(result.ServedBy, ", ")))
+		}
+		_ = table.Flush()
+	},
+}
+
+// printResultsSummary prints the outcomes of each model, and their totals.
+func printResultsSummary(summaries []dbx.ModelSummary) {
+	if outputFormat == outputJson {
+		if summaries == nil {
+			summaries = []dbx.ModelSummary{}
+		}
+		printJson(summaries)
+		return
+	}
+	if len(summaries) == 0 {
+		fmt.Println("No model versions match")
+		return
+	}
+	var total dbx.ModelSummary
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "MODEL\tUNSAFE\tSAFE\tUNSCANNED\tFAILED")
+	for _, summary := range summaries {
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%d\n", summary.Model, summary.Unsafe, summary.Safe, summary.Unscanned, summary.Failed)
+		total.Unsafe += summary.Unsafe
+		total.Safe += summary.Safe
+		total.Unscanned += summary.Unscanned
+		total.Failed += summary.Failed
+	}
+	fmt.Fprintf(table, "TOTAL\t%d\t%d\t%d\t%d\n", total.Unsafe, total.Safe, total.Unscanned, total.Failed)
+	_ = table.Flush()
+}
+
+// selectSchemas returns the schemas named <catalog>.<schema>, with the scanner that the configuration selects for
+// them, if it monitors them. Exit if a name is invalid.
+func selectSchemas(configured []utils.CatalogSchemaConfig, names []string) []utils.CatalogSchemaConfig {
+	var selected []utils.CatalogSchemaConfig
+	for _, name := range names {
+		schema, err := dbx.ParseSchemaName(name)
+		if err != nil {
+			utils.Fatalf("Invalid --schema: %v", err)
+		}
+		for _, monitored := range configured {
+			if monitored.Catalog == schema.Catalog && monitored.Schema == schema.Schema {
+				schema = monitored
+			}
+		}
+		selected = append(selected, schema)
+	}
+	return selected
+}
+
+// parseSince parses the duration of --since: a number of days, such as 7d, or a Go duration, such as 12h.
+func parseSince(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid number of days %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	since, err := time.ParseDuration(value)
+	if err != nil || since <= 0 {
+		return 0, fmt.Errorf("invalid duration %q, expected e.g. 7d or 12h", value)
+	}
+	return since, nil
+}
+
+// completeResultSources completes the values of --source.
+func completeResultSources(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return dbx.ResultSources, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeResultOutcomes completes the values of --result.
+func completeResultOutcomes(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return dbx.ResultOutcomes, cobra.ShellCompDirectiveNoFileComp
+}
+
+func init() {
+	flags := resultsCmd.Flags()
+	flags.StringSliceVar(&resultsFilter.Outcomes, "result", nil, "scan outcome: "+strings.Join(dbx.ResultOutcomes, ", "))
+	flags.StringSliceVar(&resultsFilter.Owners, "owner", nil, "owner of the model, a user, service principal, or group")
+	flags.StringSliceVar(&resultsFilter.Tags, "tag", nil, "MLflow tag of the model version, as key or key=value")
+	flags.StringSliceVar(&resultsFilter.Aliases, "alias", nil, "alias of the model version, without @")
+	flags.BoolVar(&resultsFilter.Served, "served", false, "only model versions that a Model Serving endpoint serves")
+	flags.StringArrayVar(&resultsSchemas, "schema", nil, "schema to list the results of, as <catalog>.<schema>, instead of the monitored schemas; repeat it for several")
+	flags.StringVar(&resultsSince, "since", "", "only model versions scanned within this duration, such as 7d or 12h")
+	flags.StringVar(&resultsSource, "source", dbx.ResultSourceTags, "where the results come from: "+strings.Join(dbx.ResultSources, ", "))
+	flags.BoolVar(&resultsSummary, "summary", false, "count the outcomes of each model, instead of listing its versions")
+	_ = resultsCmd.RegisterFlagCompletionFunc("result", completeResultOutcomes)
+	_ = resultsCmd.RegisterFlagCompletionFunc("schema", completeSchemas)
+	_ = resultsCmd.RegisterFlagCompletionFunc("source", completeResultSources)
+	supportJsonOutput(resultsCmd)
+	rootCmd.AddCommand(resultsCmd)
+}
@@ -2,11 +2,24 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
 	"os"
 
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/telemetry"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
 	"github.com/spf13/cobra"
 )
 
+// configFile is the path of the configuration file given with --config
+var configFile string
+
+// configProfile is the name of the profile of the configuration file given with --config-profile
+var configProfile string
+
+// verbose and debug are set by --verbose and --debug, flags of every command that lower the level of the log
+// messages that are written to stderr, from warnings to info and debug
+var verbose, debug bool
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "hldbx",
@@ -14,10 +27,48 @@ var rootCmd = &cobra.Command{
 	Long:  "hldbx is a CLI tool for setting up automated model scanning in Databricks.",
 }
 
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&sandboxMode, "sandbox", false,
+		"run against a mock Databricks workspace and HiddenLayer API, for demos and training")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText,
+		"output format: text, or json for structured output that wrapper tools can parse, where the command supports it")
+	_ = rootCmd.RegisterFlagCompletionFunc("output", completeOutputFormats)
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "",
+		"path of the configuration file (default $"+utils.ConfigEnv+", or the profile's hldbx.yaml)")
+	_ = rootCmd.MarkPersistentFlagFilename("config", "yaml", "yml")
+	rootCmd.PersistentFlags().StringVar(&configProfile, "config-profile", "",
+		"profile of the configuration file to use, e.g. of a deployment (default $"+utils.ConfigProfileEnv+
+			", or the settings at the top of the file)")
+	_ = rootCmd.RegisterFlagCompletionFunc("config-profile", completeConfigProfiles)
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false,
+		"log what the command does, such as the phases of autoscan, to stderr")
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false,
+		"log in detail to stderr, including a summary of each Databricks and HiddenLayer API request, for diagnosing failures")
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		configureLogging()
+		checkOutputFormat(cmd)
+		utils.SetConfigFile(configFile)
+		utils.SetConfigProfile(configProfile)
+		startTelemetry(cmd, args)
+	}
+	rootCmd.PersistentPostRun = finishTelemetry
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		telemetryEvent.Finish(telemetry.StatusFailure)
+		fmt.Println(utils.Redact(err.Error()))
 		os.Exit(1)
 	}
 }
+
+// configureLogging sets the level of the log messages from --verbose and --debug.
+func configureLogging() {
+	switch {
+	case debug:
+		utils.ConfigureLogging(os.Stderr, slog.LevelDebug)
+	case verbose:
+		utils.ConfigureLogging(os.Stderr, slog.LevelInfo)
+	}
+}
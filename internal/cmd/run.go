@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var runHistoryLimit int
+
+var runCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Inspects the runs of the monitoring job",
+}
+
+var runHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Summarizes recent monitoring job runs, and why the failed ones failed",
+	Long: "Lists the latest completed runs of the monitoring job, classifies why the failed runs failed (the cluster " +
+		"didn't start, HiddenLayer rejected the credentials, a Databricks permission is missing, or scans failed), " +
+		"and counts runs and failures by day.",
+	Example: "  hldbx run history --limit 100",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if runHistoryLimit < 1 {
+			utils.Fatal("--limit must be at least 1")
+		}
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		history, err := dbx.GetRunHistory(context.Background(), dbxClient, runHistoryLimit)
+		if err != nil {
+			utils.Fatalf("Error getting the monitoring job's run history: %v", err)
+		}
+
+		if outputFormat == outputJson {
+			printJson(history)
+			return
+		}
+		failed := 0
+		for _, count := range history.Failures {
+			failed += count
+		}
+		fmt.Printf("Monitoring job %d: %d run(s), %d failed\n", history.JobId, len(history.Runs), failed)
+		if len(history.Runs) == 0 {
+			return
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "RUN ID\tSTARTED (UTC)\tDURATION\tSTATE\tFAILURE\tMESSAGE")
+		for _, run := range history.Runs {
+			fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\t%s\n", run.RunId, run.StartTime.Format(time.DateTime),
+				time.Duration(run.DurationSeconds)*time.Second, run.State, run.Failure, firstLine(run.Message))
+		}
+		_ = table.Flush()
+		if failed > 0 {
+			fmt.Println()
+			fmt.Printf("Failures: %s\n", formatFailureCounts(history.Failures))
+		}
+		fmt.Println()
+		table = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "DATE (UTC)\tRUNS\tFAILED\tFAILURES")
+		for _, trend := range history.Trend {
+			fmt.Fprintf(table, "%s\t%d\t%d\t%s\n", trend.Date, trend.Runs, trend.Failed, formatFailureCounts(trend.Failures))
+		}
+		_ = table.Flush()
+	},
+}
+
+// formatFailureCounts returns failure counts by class as "class count, ...", sorted by class.
+func formatFailureCounts(failures map[string]int) string {
+	var counts []string
+	for _, class := range slices.Sorted(maps.Keys(failures)) {
+		counts = append(counts, fmt.Sprintf("%s %d", class, failures[class]))
+	}
+	return strings.Join(counts, ", ")
+}
+
+// firstLine returns the first line of a message, with secrets redacted, so a stack trace doesn't break the table.
+func firstLine(message string) string {
+	line, _, _ := strings.Cut(message, "\n")
+	return utils.Redact(line)
+}
+
+func init() {
+	runHistoryCmd.Flags().IntVar(&runHistoryLimit, "limit", 50, "number of recent runs to summarize")
+	supportJsonOutput(runHistoryCmd)
+	runCmd.AddCommand(runHistoryCmd)
+	rootCmd.AddCommand(runCmd)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var runNowWait bool
+var runNowTimeout time.Duration
+
+var runNowCmd = &cobra.Command{
+	Use:   "run-now",
+	Short: "Runs the monitoring job immediately",
+	Long: "Triggers an immediate run of the monitoring job, hl_find_new_model_versions, rather than waiting for its " +
+		"schedule, e.g. to scan the model versions that are already registered right after setting up autoscan. " +
+		"It first waits for the cluster to be running, unless the jobs run on serverless compute or job clusters. " +
+		"With --wait, it prints the state of the run as it changes until the run ends, and exits with a non-zero " +
+		"status if the run failed.",
+	Example: "  hldbx run-now --wait --start-cluster",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		run := runMonitorJobNow(dbxClient, config)
+		if !runNowWait {
+			if outputFormat == outputJson {
+				printJson(run)
+			}
+			return
+		}
+
+		// Ctrl+C stops waiting, the run goes on
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		summary, err := dbx.WaitForRun(ctx, dbxClient, run, runNowTimeout, func(state string, elapsed time.Duration) {
+			fmt.Printf("%s Run %d is %s (%s)\n", time.Now().Format(time.TimeOnly), run.RunId, state, elapsed.Round(time.Second))
+		})
+		if err != nil {
+			utils.Fatalf("Error waiting for the monitoring job run: %v", err)
+		}
+
+		if outputFormat == outputJson {
+			printJson(struct {
+				*dbx.MonitorRun
+				Result *dbx.RunSummary `json:"result"`
+			}{run, summary})
+		} else if !summary.Failed() {
+			fmt.Printf("Monitoring job run %d succeeded in %s\n", run.RunId, time.Duration(summary.DurationSeconds)*time.Second)
+		}
+		if summary.Failed() {
+			utils.Fatalf("Monitoring job run %d failed (%s): %s. See %s, or run hldbx doctor", run.RunId, summary.Failure,
+				dashIfEmpty(summary.Message), run.Url)
+		}
+	},
+}
+
+func init() {
+	runNowCmd.Flags().BoolVar(&runNowWait, "wait", false, "wait for the run to end, printing its state as it changes")
+	runNowCmd.Flags().DurationVar(&runNowTimeout, "timeout", time.Hour, "with --wait, how long to wait for the run to end")
+	supportJsonOutput(runNowCmd)
+	addClusterReadinessFlags(runNowCmd)
+	rootCmd.AddCommand(runNowCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/hl"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/mock"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+)
+
+// Profile whose directory holds the files written by commands run in the sandbox
+const sandboxProfile = "sandbox"
+
+var sandboxMode bool
+
+// sandboxConfig starts a mock of the Databricks and HiddenLayer APIs, and returns the configuration of its
+// sandbox workspace. Files that commands write go in the sandbox profile's directory, apart from real ones.
+func sandboxConfig() *utils.Config {
+	server, err := mock.NewSandboxServer()
+	if err != nil {
+		utils.Fatalf("Error starting the sandbox: %v", err)
+	}
+	hl.DialContext = server.DialContext
+	if err := os.Setenv(utils.ProfileEnv, sandboxProfile); err != nil {
+		utils.Fatalf("Error selecting the sandbox profile: %v", err)
+	}
+	fmt.Printf("Running in the sandbox, against a mock Databricks workspace at %s and HiddenLayer API at %s\n", server.URL,
+		server.HlApiUrl())
+	return &utils.Config{
+		WorkspaceConfig: hlconfig.WorkspaceConfig{
+			DbxHost:  server.URL,
+			DbxToken: mock.SandboxToken,
+		},
+		ComputeConfig: hlconfig.ComputeConfig{DbxClusterId: mock.SandboxClusterId},
+		JobsConfig: hlconfig.JobsConfig{
+			DbxRunAs:             mock.SandboxServicePrincipal,
+			DbxMaxActiveScanJobs: 10,
+			DbxPollingQuartzCron: "0 0 */12 * * ?",
+		},
+		AccessConfig:     hlconfig.AccessConfig{DbxSecretsGroup: "security-admins"},
+		ScanPolicyConfig: hlconfig.ScanPolicyConfig{DbxSchemas: mock.SandboxRegistry.Schemas},
+		HiddenLayerConfig: hlconfig.HiddenLayerConfig{
+			// The mock stubs the HiddenLayer API and its authentication, so the sandbox never calls the real API
+			HlApiUrl:       server.HlApiUrl(),
+			HlAuthUrl:      server.HlApiUrl(),
+			HlConsoleUrl:   "https://console.us.hiddenlayer.ai",
+			HlApiKeyName:   "hl-sandbox",
+			HlClientID:     "00000000-0000-0000-0000-000000000000",
+			HlClientSecret: "sandbox-secret",
+		},
+	}
+}
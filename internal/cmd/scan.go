@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var scanModel string
+var scanVersion string
+
+var scanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Scans a single model version on demand",
+	Long: "Scans one version of a Unity Catalog model now, without the monitoring job, and prints the verdict and " +
+		"the link to the scan in the HiddenLayer console. The scan runs once in Databricks, on the configured " +
+		"compute, which submits the model version to the HiddenLayer API of the scanner that its schema selects. " +
+		"The notebooks are uploaded, and the scanner's credentials stored for the model's schema, if they aren't " +
+		"already. The model version is tagged with the result, as the monitoring job's scans are.",
+	Example: "  hldbx scan --model prod.ml.fraud --version 3",
+	Run: func(cmd *cobra.Command, args []string) {
+		fullName, version := parseModelVersionArgs([]string{scanModel, scanVersion})
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if config.UsesExistingCluster() {
+			if err := waitForCluster(ctx, dbxClient, config.DbxClusterId); err != nil {
+				utils.Fatalf("Cluster is not ready for the scan: %v", err)
+			}
+		}
+		fmt.Printf("Scanning model %s version %d, this may take a few minutes if the cluster is starting\n", fullName, version)
+		result, runId, err := dbx.ScanModelVersion(ctx, dbxClient, config, fullName, version)
+		if err != nil {
+			utils.Fatalf("Error scanning model %s version %d: %v", fullName, version, err)
+		}
+		fmt.Printf("Scan run %d finished\n", runId)
+		printScanVerdict(result)
+	},
+}
+
+// printScanVerdict prints the outcome of a scan, as recorded in the model version's tags.
+// Exit if the scan didn't finish.
+func printScanVerdict(result dbx.ScanResult) {
+	switch {
+	case result.IsOutageBacklog():
+		utils.Fatalf("The HiddenLayer API was unreachable, model %s version %d is waiting to be scanned: %s",
+			result.Model, result.Version, result.Message)
+	case !result.IsScanned():
+		utils.Fatalf("The scan of model %s version %d is %s: %s", result.Model, result.Version, result.Status, result.Message)
+	}
+	verdict := "no detections"
+	if result.IsDetection() {
+		verdict = "DETECTION"
+	}
+	fmt.Printf("Verdict: %s, threat level %s\n", verdict, result.ThreatLevel)
+	if len(result.Rules) > 0 {
+		fmt.Printf("Rules: %s\n", strings.Join(result.Rules, ", "))
+	}
+	if result.Scanner != "" {
+		fmt.Printf("Scanner version: %s\n", result.Scanner)
+	}
+	if result.ScanUrl != "" {
+		utils.Printf("Console: %s\n", result.ScanUrl)
+	} else if result.ScanId != "" {
+		fmt.Printf("Scan ID: %s\n", result.ScanId)
+	}
+}
+
+func init() {
+	scanCmd.Flags().StringVar(&scanModel, "model", "", "full name of the model, <catalog>.<schema>.<model> (required)")
+	scanCmd.Flags().StringVar(&scanVersion, "version", "", "version number of the model to scan (required)")
+	_ = scanCmd.MarkFlagRequired("model")
+	_ = scanCmd.MarkFlagRequired("version")
+	addClusterReadinessFlags(scanCmd)
+	rootCmd.AddCommand(scanCmd)
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var scannerPingAuth string
+var scannerPingAuthUrl string
+var scannerPingWait time.Duration
+
+var scannerCmd = &cobra.Command{
+	Use:   "scanner",
+	Short: "Checks the HiddenLayer scanners",
+}
+
+var scannerPingCmd = &cobra.Command{
+	Use:   "ping [<scanner name> | <API URL>]",
+	Short: "Checks that a scanner accepts and finishes a scan, and how long it takes",
+	Long: "Checks a scanner from this machine, without Databricks: it authenticates to it, if it needs credentials, " +
+		"checks that its API answers, submits a scan of a tiny text file as model hldbx-ping, and waits up to --wait " +
+		"for the scan to finish. It reports how long each step took, and the scanner's version. Without an argument, " +
+		"it pings the scanner of hl_api_url; give the name of an hl_scanners entry to ping that one, or the API URL " +
+		"of a scanner that isn't configured, such as a newly deployed enterprise scanner. Such a scanner authenticates " +
+		"as given by --auth, with hl_client_id and hl_client_secret at --auth-url for client_credentials; a scanner " +
+		"with databricks_token auth must be in hl_scanners, so that a Databricks token is only sent to a scanner " +
+		"that the configuration trusts. It exits with an error if a step fails.",
+	Example: "  hldbx scanner ping\n  hldbx scanner ping staging\n  hldbx scanner ping https://scanner.example.com --wait 2m",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		scanner := pingedScanner(cmd, config, args)
+		result, err := dbx.PingScanner(config, scanner, scannerPingWait)
+		if err != nil {
+			utils.Fatalf("Error pinging the scanner at %s: %v", scanner.ApiUrl, err)
+		}
+
+		if outputFormat == outputJson {
+			printJson(result)
+		} else {
+			fmt.Printf("Scanner %s at %s\n", scanner.Name, scanner.ApiUrl)
+			for _, step := range result.Steps {
+				status := "OK"
+				if !step.Ok {
+					status = "FAIL"
+				}
+				utils.Printf("%s: %s (%s): %s\n", status, step.Name, time.Duration(step.DurationMs)*time.Millisecond, step.Message)
+			}
+			fmt.Printf("Version: %s\n", dashIfEmpty(result.Version))
+		}
+		if !result.Ok() {
+			utils.Fatalf("Scanner %s failed the ping", scanner.Name)
+		}
+	},
+}
+
+// pingedScanner returns the scanner of the arguments of scanner ping, with its defaults filled in. Exit if it isn't
+// configured, or the flags are invalid.
+func pingedScanner(cmd *cobra.Command, config *utils.Config, args []string) utils.ScannerConfig {
+	if len(args) == 0 || !strings.Contains(args[0], "://") {
+		name := utils.DefaultScannerName
+		if len(args) > 0 {
+			name = args[0]
+		}
+		if cmd.Flags().Changed("auth") || cmd.Flags().Changed("auth-url") {
+			utils.Fatal("--auth and --auth-url are only for the API URL of a scanner that isn't configured, a configured " +
+				"scanner authenticates as its settings say")
+		}
+		if name == utils.DefaultScannerName && config.HlApiUrl == "" {
+			utils.Fatal("hl_region or hl_api_url is not set, set one in the configuration file or give the API URL of the scanner")
+		}
+		scanner, ok := config.Scanner(utils.CatalogSchemaConfig{Scanner: name})
+		if !ok {
+			utils.Fatalf("No scanner named %s in hl_scanners", name)
+		}
+		return scanner
+	}
+
+	apiUrl := args[0]
+	if parsed, err := url.Parse(apiUrl); err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		utils.Fatalf("Invalid API URL %q, expected an https:// URL", apiUrl)
+	}
+	// A configured scanner's URL pings it as configured
+	for _, scanner := range config.ScannersInUse() {
+		if strings.TrimSuffix(scanner.ApiUrl, "/") == strings.TrimSuffix(apiUrl, "/") && !cmd.Flags().Changed("auth") {
+			return scanner
+		}
+	}
+	scanner := utils.ScannerConfig{Name: apiUrl, ApiUrl: apiUrl, AuthUrl: scannerPingAuthUrl, Auth: utils.ScannerAuth(scannerPingAuth),
+		ClientID: config.HlClientID, ClientSecret: config.HlClientSecret}
+	if err := scanner.Auth.Validate(); err != nil {
+		utils.Fatalf("Invalid --auth: %v", err)
+	}
+	if scanner.Auth == utils.ScannerAuthDatabricksToken {
+		// Any URL can be typed, so a Databricks token is only sent to a scanner that the configuration trusts
+		utils.Fatalf("--auth %s is only for the scanners of hl_scanners, add the scanner to hl_scanners to ping it "+
+			"with a Databricks token", utils.ScannerAuthDatabricksToken)
+	}
+	if scanner.Auth == "" {
+		scanner.Auth = utils.ScannerAuthNone
+		if !scanner.IsEnterprise() {
+			scanner.Auth = utils.ScannerAuthClientCredentials
+		}
+	}
+	if scanner.UsesClientCredentials() && scanner.AuthUrl == "" {
+		if scanner.IsEnterprise() {
+			utils.Fatal("--auth-url is required to authenticate with client credentials")
+		}
+		scanner.AuthUrl = config.HlAuthUrl
+	}
+	return scanner
+}
+
+func init() {
+	scannerPingCmd.Flags().StringVar(&scannerPingAuth, "auth", "",
+		"how the scanner of an API URL authenticates: client_credentials or none (default: none, "+
+			"or client_credentials for hiddenlayer.ai)")
+	scannerPingCmd.Flags().StringVar(&scannerPingAuthUrl, "auth-url", "", "auth URL of the scanner of an API URL, for client_credentials")
+	scannerPingCmd.Flags().DurationVar(&scannerPingWait, "wait", time.Minute, "how long to wait for the scan to finish, 0 not to wait")
+	supportJsonOutput(scannerPingCmd)
+	scannerCmd.AddCommand(scannerPingCmd)
+	rootCmd.AddCommand(scannerCmd)
+}
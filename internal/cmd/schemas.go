@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var schemasCmd = &cobra.Command{
+	Use:   "schemas",
+	Short: "Changes the schemas monitored by an existing installation",
+	Long: "Adds or removes monitored Unity Catalog schemas, updating the monitoring job's parameters and the " +
+		"schemas' secrets, without re-running autoscan.",
+}
+
+var schemasAddCmd = &cobra.Command{
+	Use:     "add <catalog>.<schema>...",
+	Short:   "Starts monitoring schemas",
+	Example: "  hldbx schemas add prod.new_ml",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		requireUnityCatalog(dbxClient)
+		ctx := context.Background()
+		for _, arg := range args {
+			schema, err := dbx.ParseSchemaName(arg)
+			if err != nil {
+				utils.Fatal(err)
+			}
+			status, err := dbx.CheckSchema(ctx, dbxClient, schema.Catalog, schema.Schema)
+			if err != nil {
+				utils.Fatal(err)
+			}
+			switch status {
+			case dbx.SchemaMissing:
+				utils.Fatalf("Schema %s not found in Databricks", arg)
+			case dbx.SchemaForbidden:
+				slog.Warn("Unable to confirm that the schema exists, you lack USE CATALOG or USE SCHEMA on it", "schema", arg)
+			}
+			if err := dbx.AddMonitoredSchema(ctx, dbxClient, config, schema); err != nil {
+				utils.Fatalf("Error adding schema %s: %v", arg, err)
+			}
+			fmt.Printf("Now monitoring schema %s\n", arg)
+		}
+		updateManifest(ctx, dbxClient, config, cmd.CommandPath())
+		printSchemasConfigReminder()
+	},
+}
+
+var schemasRemoveCmd = &cobra.Command{
+	Use:     "remove <catalog>.<schema>...",
+	Short:   "Stops monitoring schemas",
+	Example: "  hldbx schemas remove dev.scratch",
+	Args:    cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		ctx := context.Background()
+		for _, arg := range args {
+			schema, err := dbx.ParseSchemaName(arg)
+			if err != nil {
+				utils.Fatal(err)
+			}
+			if err := dbx.RemoveMonitoredSchema(ctx, dbxClient, schema); err != nil {
+				utils.Fatalf("Error removing schema %s: %v", arg, err)
+			}
+			fmt.Printf("No longer monitoring schema %s\n", arg)
+		}
+		updateManifest(ctx, dbxClient, config, cmd.CommandPath())
+		printSchemasConfigReminder()
+	},
+}
+
+// updateManifest rewrites the installation manifest after command changed the installation, and records the change
+// in the install audit log, warning if it can't.
+func updateManifest(ctx context.Context, dbxClient *databricks.WorkspaceClient, config *utils.Config, command string) {
+	if _, err := dbx.WriteManifest(ctx, dbxClient, config, command); err != nil {
+		slog.Warn("Unable to update the installation manifest", "error", err)
+	}
+}
+
+// printSchemasConfigReminder reminds the user to keep the configuration file in sync with the installation,
+// since autoscan sets the monitored schemas from it.
+func printSchemasConfigReminder() {
+	fmt.Println("Update dbx_schemas in your configuration file to match, or the next autoscan run will undo this change")
+}
+
+func init() {
+	schemasCmd.AddCommand(schemasAddCmd)
+	schemasCmd.AddCommand(schemasRemoveCmd)
+	rootCmd.AddCommand(schemasCmd)
+}
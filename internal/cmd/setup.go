@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	hldatabricks "github.com/hiddenlayer-engineering/hl-databricks"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var setupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Creates the hldbx directory and a configuration file to start from",
+	Long: "Prepares a machine for its first run of hldbx: creates the hldbx directory (~/.hl, or that of the " +
+		"profile selected with HLDBX_HOME and HLDBX_PROFILE), and writes a configuration file in it with every " +
+		"setting commented out, with examples, unless there already is one. The directory and the files in it that " +
+		"may hold secrets are made accessible only to you. Then checks that the terminal can prompt for settings, " +
+		"including secrets without echoing them.",
+	Example: "  hldbx setup\n  HLDBX_PROFILE=prod hldbx setup",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if sandboxMode {
+			utils.Fatal("hldbx setup creates the configuration file, which the sandbox doesn't use")
+		}
+		result, err := utils.Setup(hldatabricks.ConfigTemplate)
+		if err != nil {
+			utils.Fatalf("Error setting up hldbx: %v", err)
+		}
+		fmt.Printf("OK: hldbx directory %s\n", result.ProfileDir)
+		if result.ConfigCreated {
+			fmt.Printf("OK: wrote the configuration file %s, with every setting commented out\n", result.ConfigPath)
+		} else {
+			fmt.Printf("OK: kept the existing configuration file %s\n", result.ConfigPath)
+		}
+		for _, path := range result.Secured {
+			fmt.Printf("OK: made %s accessible only to you\n", path)
+		}
+		if runtime.GOOS == "windows" {
+			fmt.Println("OK: the hldbx directory is in your user profile, which only you can access by default")
+		}
+
+		warnings := 0
+		for _, check := range terminalChecks() {
+			if check.ok {
+				fmt.Printf("OK: %s\n", check.message)
+			} else {
+				fmt.Printf("WARN: %s\n", check.message)
+				warnings++
+			}
+		}
+		fmt.Println()
+		if warnings > 0 {
+			fmt.Printf("hldbx can't prompt here, so next, put every required setting in %s.\n", result.ConfigPath)
+		} else {
+			fmt.Printf("Next, edit %s, or run hldbx autoscan to be prompted for the settings.\n", result.ConfigPath)
+		}
+	},
+}
+
+// terminalCheck is the outcome of checking one thing that prompting needs from the terminal.
+type terminalCheck struct {
+	ok      bool
+	message string
+}
+
+// terminalChecks checks that hldbx can prompt for settings, and for secrets without echoing them.
+func terminalChecks() []terminalCheck {
+	stdin := term.IsTerminal(int(os.Stdin.Fd()))
+	stdout := term.IsTerminal(int(os.Stdout.Fd()))
+	checks := []terminalCheck{
+		{stdin, "stdin is a terminal, so hldbx can prompt for settings"},
+		{stdout, "stdout is a terminal, so prompts are shown"},
+	}
+	if !stdin {
+		checks[0].message = "stdin isn't a terminal, so hldbx can't prompt for settings"
+	}
+	if !stdout {
+		checks[1].message = "stdout isn't a terminal, so prompts may not be shown"
+	}
+	if stdin {
+		// Secrets are read without echo, which needs a terminal whose echo can be turned off
+		state, err := term.GetState(int(os.Stdin.Fd()))
+		if err == nil {
+			err = term.Restore(int(os.Stdin.Fd()), state)
+		}
+		if err != nil {
+			checks = append(checks, terminalCheck{false, fmt.Sprintf("the terminal's echo can't be turned off to read secrets: %v", err)})
+		} else {
+			checks = append(checks, terminalCheck{true, "secrets can be read without echoing them"})
+		}
+	}
+	return checks
+}
+
+func init() {
+	rootCmd.AddCommand(setupCmd)
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var shareCmd = &cobra.Command{
+	Use:   "share",
+	Short: "Shares the scan results with a central workspace through Delta Sharing",
+	Long: "Manages a Delta Sharing share of this workspace's scan results tables, and a recipient for the central " +
+		"security workspace that aggregates the results of every workspace, across regions and accounts. The share " +
+		"is dbx_results_share, the recipient dbx_results_recipient, and the central workspace's metastore " +
+		"dbx_results_recipient_id. Creating shares and recipients takes the CREATE SHARE and CREATE RECIPIENT " +
+		"privileges on the metastore.",
+}
+
+var shareApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Creates the share and the recipient, and shares the results tables that exist",
+	Long: "Creates the share and the recipient if they don't exist, adds the tables of model version scans, artifact " +
+		"scans, and monitoring job heartbeats to the share, as hiddenlayer.model_scans, hiddenlayer.artifact_scans, " +
+		"and hiddenlayer.heartbeats, and grants the recipient SELECT on it. The jobs create the tables on their first " +
+		"runs; re-run it to share the tables that didn't exist yet.",
+	Example: "  hldbx share apply",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		if err := dbx.ValidateResultsShare(config); err != nil {
+			utils.Fatal(err)
+		}
+		dbxClient := configDbxCreds(config)
+		share, err := dbx.ShareResults(context.Background(), dbxClient, config)
+		if err != nil {
+			utils.Fatalf("Error sharing the scan results: %v", err)
+		}
+		if share.RecipientCreated {
+			fmt.Printf("Created recipient %s for metastore %s\n", share.Recipient, config.DbxResultsRecipientId)
+		}
+		for _, table := range share.Added {
+			fmt.Printf("Shared %s in share %s\n", table, share.Share)
+		}
+		for _, table := range share.Pending {
+			slog.Warn("A results table doesn't exist yet, re-run hldbx share apply after the jobs have run", "table", table)
+		}
+		fmt.Printf("Share %s has %d results table(s), readable by recipient %s\n", share.Share, len(share.Tables), share.Recipient)
+		fmt.Printf("In the central workspace, mount it as a catalog with:\n"+
+			"  CREATE CATALOG <catalog> USING SHARE <provider>.%s\n"+
+			"where <provider> is the provider with sharing identifier %s\n", share.Share, share.ProviderSharingId)
+	},
+}
+
+var shareRevertCmd = &cobra.Command{
+	Use:     "revert",
+	Short:   "Deletes the share and the recipient, and keeps the results tables",
+	Example: "  hldbx share revert",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		if err := dbx.UnshareResults(context.Background(), dbxClient, config); err != nil {
+			utils.Fatalf("Error deleting the share of the scan results: %v", err)
+		}
+		fmt.Println("The scan results are no longer shared")
+	},
+}
+
+func init() {
+	shareCmd.AddCommand(shareApplyCmd)
+	shareCmd.AddCommand(shareRevertCmd)
+	rootCmd.AddCommand(shareCmd)
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var statusScanRuns int
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Shows the health of the installation, the scan backlog, scan latency and throughput, and detection counts",
+	Long: "Reports the health and throughput of the installation:\n\n" +
+		"  - the monitoring job, its schedule, and the outcome of its latest run\n" +
+		"  - the notebooks, the secrets scopes of the monitored schemas, and the clusters of the jobs\n" +
+		"  - when the HiddenLayer credentials were last rotated\n" +
+		"  - the model versions that the scan trigger picks, counted by scan status, and their detections\n" +
+		"  - the latency of the latest scan job runs, and the scans an hour that dbx_max_active_scan_jobs allows\n\n" +
+		"Exits with an error if the monitoring job is paused or missed dbx_heartbeat_max_missed heartbeats, or a job " +
+		"runs on a deleted cluster.",
+	Example: "  hldbx status --scan-runs 100",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if statusScanRuns < 1 {
+			utils.Fatal("--scan-runs must be at least 1")
+		}
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		if len(config.DbxSchemas) == 0 {
+			utils.Fatal("No schemas to report on, add dbx_schemas to the configuration file")
+		}
+		status, err := dbx.GetScanStatus(context.Background(), dbxClient, config, statusScanRuns)
+		if err != nil {
+			utils.Fatalf("Error getting the scan status: %v", err)
+		}
+
+		if outputFormat == outputJson {
+			printJson(status)
+			alertProblems(status)
+			return
+		}
+		table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		printDeployment(table, status.Deployment)
+		fmt.Fprintf(table, "Model versions to scan:\t%d\n", status.Candidates)
+		fmt.Fprintf(table, "  Backlog:\t%d\n", status.Backlog)
+		fmt.Fprintf(table, "  Scanning:\t%d\n", status.Scanning)
+		fmt.Fprintf(table, "  Waiting for HiddenLayer:\t%d (%d quarantined)\n", status.OutageBacklog, status.Quarantined)
+		fmt.Fprintf(table, "  Scanned:\t%d\n", status.Scanned)
+		fmt.Fprintf(table, "  Failed:\t%d\n", status.Failed)
+		fmt.Fprintf(table, "  Skipped:\t%d\n", status.Skipped)
+		fmt.Fprintf(table, "Detections:\t%d (%d untriaged)\n", status.Detections, status.UntriagedDetections)
+		if status.ScanRuns > 0 {
+			fmt.Fprintf(table, "Scan latency:\tmean %s, max %s, over %d run(s)\n", seconds(status.MeanScanSeconds),
+				seconds(status.MaxScanSeconds), status.ScanRuns)
+			fmt.Fprintf(table, "Scan throughput:\t%.1f an hour, capacity %.1f an hour at dbx_max_active_scan_jobs %d\n",
+				status.ScansPerHour, status.CapacityPerHour, status.MaxActiveScanJobs)
+		} else {
+			fmt.Fprintln(table, "Scan latency:\tno completed scan runs")
+		}
+		if heartbeat := status.LastHeartbeat; heartbeat != nil {
+			fmt.Fprintf(table, "Last monitoring run:\t%s, found %d version(s), started %d scan(s), %s\n",
+				heartbeat.HeartbeatAt.Format(time.RFC3339), heartbeat.VersionsFound, heartbeat.ScansStarted, heartbeat.MonitorStatus)
+		}
+		for _, age := range status.Credentials {
+			rotated := fmt.Sprintf("%s, rotated %s", age.Scope, age.RotatedAt.Format(time.DateOnly))
+			if age.Expired {
+				rotated += ", EXPIRED, rotate them and re-run hldbx autoscan"
+			}
+			fmt.Fprintf(table, "HiddenLayer credentials:\t%s\n", rotated)
+		}
+		_ = table.Flush()
+		alertProblems(status)
+	},
+}
+
+// alertProblems exits with an error if the monitoring job isn't recording heartbeats on schedule, or a job runs on a
+// deleted cluster, so that scheduled runs of hldbx status alert on them.
+func alertProblems(status *dbx.ScanStatus) {
+	if status.HeartbeatProblem != "" {
+		utils.Fatalf("Alert: %s", status.HeartbeatProblem)
+	}
+	if deleted := status.Deployment.Compute.DeletedClusters; len(deleted) > 0 {
+		utils.Fatalf("Alert: %d job task(s) run on deleted clusters, so every run fails", len(deleted))
+	}
+}
+
+// printDeployment prints whether the monitoring job, its notebooks, and the secrets scopes are in place.
+func printDeployment(table io.Writer, deployment dbx.Deployment) {
+	if deployment.MonitorJobId == 0 {
+		fmt.Fprintln(table, "Monitoring job:\tMISSING, run hldbx autoscan to create it")
+	} else {
+		fmt.Fprintf(table, "Monitoring job:\t%s (ID %d)\n", deployment.MonitorJobName, deployment.MonitorJobId)
+		switch {
+		case deployment.Schedule == "":
+			fmt.Fprintln(table, "  Schedule:\tnone, the job only runs when started")
+		case deployment.Paused && deployment.PausedBy != "":
+			fmt.Fprintf(table, "  Schedule:\t%s UTC, PAUSED by %s at %s, hldbx resume to resume it\n", deployment.Schedule,
+				deployment.PausedBy, deployment.PausedAt)
+		case deployment.Paused:
+			fmt.Fprintf(table, "  Schedule:\t%s UTC, PAUSED, hldbx resume to resume it\n", deployment.Schedule)
+		default:
+			fmt.Fprintf(table, "  Schedule:\t%s UTC\n", deployment.Schedule)
+		}
+		switch run := deployment.LastRun; {
+		case run == nil:
+			fmt.Fprintln(table, "  Last run:\tnone yet")
+		case run.Failed():
+			fmt.Fprintf(table, "  Last run:\t%s, %s (%s): %s\n", run.StartTime.Format(time.RFC3339), run.State, run.Failure,
+				firstLine(run.Message))
+		default:
+			fmt.Fprintf(table, "  Last run:\t%s, %s\n", run.StartTime.Format(time.RFC3339), run.State)
+		}
+	}
+	if len(deployment.MissingNotebooks) > 0 {
+		fmt.Fprintf(table, "Notebooks:\tMISSING %s in %s, run hldbx autoscan to upload them\n",
+			strings.Join(deployment.MissingNotebooks, ", "), deployment.NotebooksDir)
+	} else {
+		fmt.Fprintf(table, "Notebooks:\tin %s\n", deployment.NotebooksDir)
+	}
+	if len(deployment.MissingSecretScopes) > 0 {
+		fmt.Fprintf(table, "Secrets scopes:\tMISSING for %s, run hldbx autoscan to store the credentials\n",
+			strings.Join(deployment.MissingSecretScopes, ", "))
+	} else {
+		fmt.Fprintf(table, "Secrets scopes:\t%s\n", strings.Join(deployment.SecretsScopes, ", "))
+	}
+	fix := "hldbx doctor --fix " + strings.Join(dbx.ComputeFixes, "|")
+	for _, deleted := range deployment.Compute.DeletedClusters {
+		fmt.Fprintf(table, "Compute:\tMISSING cluster %s of job %s (ID %d), every run fails, run %s\n",
+			deleted.ClusterId, deleted.JobName, deleted.JobId, fix)
+	}
+	if deployment.Compute.ConfigClusterDeleted {
+		fmt.Fprintf(table, "Compute:\tMISSING cluster of dbx_cluster_id, run %s\n", fix)
+	}
+}
+
+// seconds formats a number of seconds as a duration, e.g. 2m5s.
+func seconds(s float64) time.Duration {
+	return (time.Duration(s * float64(time.Second))).Round(time.Second)
+}
+
+func init() {
+	statusCmd.Flags().IntVar(&statusScanRuns, "scan-runs", 25, "number of recent scan job runs to compute the latency over")
+	supportJsonOutput(statusCmd)
+	rootCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var supportBundleOutput string
+
+var supportBundleCmd = &cobra.Command{
+	Use:   "support-bundle",
+	Short: "Collects diagnostics into a zip file for HiddenLayer support",
+	Long: "Checks the automated model scanning setup in Databricks with full debug tracing, and packages the trace, " +
+		"the configuration (secrets redacted), the monitoring job definitions, and recent run outputs into a zip file " +
+		"to attach to a HiddenLayer support ticket.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		configDbxCreds(config) // Get Databricks credentials from the user, if needed (not already in the config)
+
+		if supportBundleOutput == "" {
+			logsDir, err := utils.LogsDir()
+			if err != nil {
+				utils.Fatal(err)
+			}
+			supportBundleOutput = filepath.Join(logsDir, fmt.Sprintf("hldbx-support-%s.zip", time.Now().Format("20060102-150405")))
+		}
+		if err := dbx.SupportBundle(context.Background(), config, supportBundleOutput); err != nil {
+			utils.Fatalf("Error creating support bundle: %v", err)
+		}
+		fmt.Printf("Support bundle written to %s\n", supportBundleOutput)
+	},
+}
+
+func init() {
+	supportBundleCmd.Flags().StringVarP(&supportBundleOutput, "file", "f", "", "path of the zip file to write (default: hldbx-support-<timestamp>.zip in the profile's logs directory)")
+	rootCmd.AddCommand(supportBundleCmd)
+}
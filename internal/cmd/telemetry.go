@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/telemetry"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var telemetryEndpoint string
+
+// Usage event of the command being run, nil unless telemetry is active
+var telemetryEvent *telemetry.Event
+
+var telemetryCmd = &cobra.Command{
+	Use:   "telemetry",
+	Short: "Manages anonymous usage telemetry",
+	Long: "hldbx can report anonymous usage metrics to HiddenLayer, to help improve it: the command that was run, " +
+		"whether it succeeded, how long it took, the hldbx version, the OS, and a random installation ID. " +
+		"Workspace URLs, schema and model names, and credentials are never sent. Telemetry is off until you enable it, " +
+		"and is disabled regardless of this setting when " + telemetry.DisableEnv + "=off or DO_NOT_TRACK=1.",
+}
+
+var telemetryStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Shows whether telemetry is enabled",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		settings, err := telemetry.LoadSettings()
+		if err != nil {
+			utils.Fatalf("Error: %v", err)
+		}
+		switch {
+		case !settings.Enabled:
+			fmt.Println("Telemetry is disabled")
+			return
+		case telemetry.DisabledByEnv() != "":
+			fmt.Printf("Telemetry is enabled, but disabled by the %s environment variable\n", telemetry.DisabledByEnv())
+		default:
+			fmt.Println("Telemetry is enabled")
+		}
+		fmt.Printf("Endpoint: %s\n", settings.EffectiveEndpoint())
+		fmt.Printf("Installation ID: %s\n", settings.InstallId)
+	},
+}
+
+var telemetryEnableCmd = &cobra.Command{
+	Use:   "enable",
+	Short: "Enables anonymous usage telemetry",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		settings, err := telemetry.LoadSettings()
+		if err != nil {
+			utils.Fatalf("Error: %v", err)
+		}
+		settings.Enabled = true
+		if cmd.Flags().Changed("endpoint") {
+			settings.Endpoint = telemetryEndpoint
+		}
+		if err := settings.Save(); err != nil {
+			utils.Fatalf("Error saving the telemetry settings: %v", err)
+		}
+		fmt.Printf("Telemetry enabled, usage metrics will be sent to %s\n", settings.EffectiveEndpoint())
+	},
+}
+
+var telemetryDisableCmd = &cobra.Command{
+	Use:   "disable",
+	Short: "Disables anonymous usage telemetry",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		settings, err := telemetry.LoadSettings()
+		if err != nil {
+			utils.Fatalf("Error: %v", err)
+		}
+		settings.Enabled = false
+		if err := settings.Save(); err != nil {
+			utils.Fatalf("Error saving the telemetry settings: %v", err)
+		}
+		fmt.Println("Telemetry disabled")
+	},
+}
+
+// startTelemetry starts the usage event of a command, unless it is run in the sandbox or is one that
+// doesn't touch a workspace.
+func startTelemetry(cmd *cobra.Command, args []string) {
+	if sandboxMode {
+		return
+	}
+	for c := cmd; c != nil; c = c.Parent() {
+		switch {
+		case c == telemetryCmd, c == versionCmd, c == benchCmd, c.Name() == "help", c.Name() == "completion",
+			c.Name() == cobra.ShellCompRequestCmd, c.Name() == cobra.ShellCompNoDescRequestCmd:
+			return
+		}
+	}
+	telemetryEvent = telemetry.Start(cmd.CommandPath())
+}
+
+// finishTelemetry sends the usage event of a command that succeeded. Commands that fail with utils.Fatal
+// exit before this runs, and are reported as failures by the next command.
+func finishTelemetry(cmd *cobra.Command, args []string) {
+	telemetryEvent.Finish(telemetry.StatusSuccess)
+}
+
+func init() {
+	telemetryEnableCmd.Flags().StringVar(&telemetryEndpoint, "endpoint", "",
+		"URL to send usage metrics to, defaults to "+telemetry.DefaultEndpoint)
+	telemetryCmd.AddCommand(telemetryStatusCmd, telemetryEnableCmd, telemetryDisableCmd)
+	rootCmd.AddCommand(telemetryCmd)
+}
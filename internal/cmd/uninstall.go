@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var uninstallDryRun bool
+var uninstallYes bool
+
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Deletes everything that autoscan created in the Databricks workspace",
+	Long: "Discovers and deletes the resources that autoscan created: the monitoring, serving guardrail, " +
+		"verification, and on-demand scan jobs, the scan jobs that the monitoring job created, the notebooks and the " +
+		"installation manifest in the workspace directory, and the HL secrets scopes of the schemas in the manifest. " +
+		"Jobs and scopes that are only named like those, without the hl_job tag or a listing in the manifest, may " +
+		"belong to another installation or a user, so they are listed for you to verify, but not deleted. Scan " +
+		"results are kept: the state tables, the scan tags of model versions, and the unsafe tags and revoked grants " +
+		"of hldbx abac. So is the install audit log, which records the uninstall. Use --dry-run to list the " +
+		"resources without deleting them.",
+	Example: "  hldbx uninstall --dry-run\n  hldbx uninstall --yes",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		ctx := context.Background()
+		resources, err := dbx.FindInstalledResources(ctx, dbxClient, config)
+		if err != nil {
+			utils.Fatalf("Error finding the installed resources: %v", err)
+		}
+		var unverified []dbx.InstalledResource
+		resources = slices.DeleteFunc(resources, func(resource dbx.InstalledResource) bool {
+			if resource.Unverified {
+				unverified = append(unverified, resource)
+			}
+			return resource.Unverified
+		})
+		if len(unverified) > 0 {
+			fmt.Printf("Not deleting %d resource(s) that are named like those autoscan creates, but have no %s tag and "+
+				"aren't in the installation manifest, verify them manually and delete them yourself if they're hldbx's:\n",
+				len(unverified), "hl_job")
+			for _, resource := range unverified {
+				fmt.Printf("  %s\n", resource)
+			}
+		}
+		if len(resources) == 0 {
+			fmt.Println("Nothing to uninstall, no resources that autoscan created were found")
+			return
+		}
+		verb := "Deleting"
+		if uninstallDryRun {
+			verb = "Would delete"
+		}
+		fmt.Printf("%s %d resource(s):\n", verb, len(resources))
+		for _, resource := range resources {
+			fmt.Printf("  %s\n", resource)
+		}
+		if uninstallDryRun {
+			return
+		}
+		if !uninstallYes {
+			choice := inputStringValue(fmt.Sprintf("y to delete these %d resource(s), or n not to (default: n)", len(resources)), false, false, "n")
+			if choice != "y" {
+				fmt.Println("Nothing was deleted")
+				return
+			}
+		}
+
+		failed := 0
+		for _, resource := range resources {
+			if err := dbx.DeleteInstalledResource(ctx, dbxClient, resource); err != nil {
+				slog.Warn("Unable to delete an installed resource", "resource", resource, "error", err)
+				failed++
+				continue
+			}
+			fmt.Printf("Deleted %s\n", resource)
+		}
+		if err := dbx.AppendAuditRecord(ctx, dbxClient, config, cmd.CommandPath()); err != nil {
+			slog.Warn("Unable to record the uninstall in the install audit log", "error", err)
+		}
+		dbx.RemoveWorkspaceDir(ctx, dbxClient, config)
+		if failed > 0 {
+			utils.Fatalf("%d of %d resource(s) couldn't be deleted, re-run hldbx uninstall to retry", failed, len(resources))
+		}
+		fmt.Println("Uninstalled HiddenLayer model scanning")
+	},
+}
+
+func init() {
+	uninstallCmd.Flags().BoolVar(&uninstallDryRun, "dry-run", false, "list the resources that would be deleted, without deleting them")
+	uninstallCmd.Flags().BoolVarP(&uninstallYes, "yes", "y", false, "delete without asking for confirmation")
+	rootCmd.AddCommand(uninstallCmd)
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var upgradeDryRun bool
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Moves the installed jobs to the notebooks of this hldbx version",
+	Long: "Uploads the notebooks of this hldbx version to the workspace directory, points the notebook tasks of the " +
+		"installed jobs at them, and adds the job parameters that this version introduced, in place, so the jobs keep " +
+		"their IDs, run history, and permissions. If a job can't be updated, the jobs updated before it are rolled " +
+		"back. The notebook directories of older versions are then deleted, except the latest ones that " +
+		"dbx_keep_notebook_versions keeps (default: 2, this version and the previous one), and those that a job or " +
+		"an active run still uses. Use --dry-run to see what would change.",
+	Example: "  hldbx upgrade --dry-run\n  hldbx upgrade",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		if err := validateSettings(config); err != nil {
+			utils.Fatalf("Invalid configuration: %v", err)
+		}
+		dbxClient := configDbxCreds(config)
+		ctx := context.Background()
+		upgrade, err := dbx.UpgradeInstallation(ctx, dbxClient, config, upgradeDryRun)
+		if err != nil {
+			utils.Fatalf("Error upgrading the installation: %v", err)
+		}
+		changes, err := dbx.ApplyConfig(ctx, dbxClient, config, upgradeDryRun)
+		if err != nil {
+			utils.Fatalf("Error updating the parameters of the monitoring job: %v", err)
+		}
+		if !upgradeDryRun {
+			updateManifest(ctx, dbxClient, config, cmd.CommandPath())
+		}
+		if outputFormat == outputJson {
+			if changes == nil {
+				changes = []dbx.SettingChange{}
+			}
+			printJson(struct {
+				HldbxVersion string              `json:"hldbx_version"`
+				DryRun       bool                `json:"dry_run"`
+				Upgrade      *dbx.Upgrade        `json:"upgrade"`
+				Changes      []dbx.SettingChange `json:"changes"`
+			}{utils.Version, upgradeDryRun, upgrade, changes})
+			return
+		}
+
+		moved, deleted := "Moved", "Deleted"
+		if upgradeDryRun {
+			moved, deleted = "Would move", "Would delete"
+		}
+		for _, task := range upgrade.Repointed {
+			fmt.Printf("%s task %s of job %s (%d): %s -> %s\n", moved, task.TaskKey, task.JobName, task.JobId, task.From, task.To)
+		}
+		for _, change := range changes {
+			if change.Added {
+				fmt.Printf("  %s: added as %q\n", change.Name, change.To)
+				continue
+			}
+			fmt.Printf("  %s: %q -> %q\n", change.Name, change.From, change.To)
+		}
+		for _, dir := range upgrade.Removed {
+			fmt.Printf("%s obsolete notebooks in %s\n", deleted, dir)
+		}
+		for _, dir := range upgrade.Kept {
+			fmt.Printf("Kept notebooks in %s, one of the %d latest versions of dbx_keep_notebook_versions\n", dir,
+				config.KeepNotebookVersions())
+		}
+		for _, dir := range upgrade.InUse {
+			slog.Warn("Kept notebooks that are still in use; re-run hldbx upgrade when their runs end", "dir", dir)
+		}
+		switch {
+		case upgradeDryRun:
+			fmt.Printf("%d task(s) and %d setting(s) would change, run without --dry-run to upgrade to %s\n",
+				len(upgrade.Repointed), len(changes), utils.Version)
+		case len(upgrade.Repointed) == 0 && len(changes) == 0:
+			fmt.Printf("The installed jobs already run the notebooks of %s\n", utils.Version)
+		default:
+			fmt.Printf("Upgraded the installation to %s\n", utils.Version)
+		}
+	},
+}
+
+func init() {
+	upgradeCmd.Flags().BoolVar(&upgradeDryRun, "dry-run", false, "show what would change, without changing it")
+	supportJsonOutput(upgradeCmd)
+	rootCmd.AddCommand(upgradeCmd)
+}
@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/hl"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Checks the configuration file without deploying anything",
+	Long: "Reads the configuration file, authenticates to HiddenLayer and Databricks with its credentials, and checks " +
+		"that the monitored schemas, the cluster, and the service principal that the jobs run as exist, and that the " +
+		"schedules are valid Quartz cron expressions. Each check is reported as PASS or FAIL, and WARN for what " +
+		"autoscan accepts with a warning. Nothing is prompted for, and nothing is created: no jobs, secrets, or " +
+		"notebooks. Exits with an error if a check fails.",
+	Example: "  hldbx validate",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !sandboxMode {
+			path, err := utils.ConfigFilePath()
+			if err != nil {
+				utils.Fatal(err)
+			}
+			if _, err := os.Stat(path); err != nil {
+				utils.Fatalf("No configuration file to validate: %v", err)
+			}
+			fmt.Printf("Validating %s\n", path)
+		}
+		// Report missing values as failures, rather than prompting for them
+		nonInteractive = true
+		config := readConfig()
+		v := &validation{}
+		v.report("settings", validateSettings(config), "the settings are valid")
+		validateSchedules(v, config)
+		validateCloud(v, config)
+		_, err := hl.TLSConfig(config.HlTlsMinVersion, config.HlTlsPins)
+		v.report("TLS settings", err, "the TLS settings are valid")
+		validateHlCreds(v, config)
+		if dbxClient := validateDbxCreds(v, config); dbxClient != nil {
+			validateDbxResources(v, config, dbxClient)
+		}
+
+		if v.failed > 0 {
+			utils.Fatalf("%d check(s) failed", v.failed)
+		}
+		fmt.Println("All checks passed, hldbx autoscan can deploy this configuration")
+	},
+}
+
+// validation reports the checks of hldbx validate, and counts those that failed.
+type validation struct {
+	failed int
+}
+
+// report reports a check, which failed if err is not nil, and otherwise passed with the detail.
+func (v *validation) report(item string, err error, detail string) {
+	if err != nil {
+		v.failed++
+		fmt.Printf("FAIL: %s: %v\n", item, err)
+		return
+	}
+	fmt.Printf("PASS: %s: %s\n", item, detail)
+}
+
+// warn reports a check that passed with a warning.
+func (v *validation) warn(item string, detail string) {
+	fmt.Printf("WARN: %s: %s\n", item, detail)
+}
+
+// validateSchedules checks the schedules of the monitoring and verification jobs. Without a schedule, autoscan
+// schedules the monitoring job every 12 hours.
+func validateSchedules(v *validation, config *utils.Config) {
+	if config.DbxPollingQuartzCron == "" {
+		v.report("monitoring schedule", nil, "not set, autoscan schedules the monitoring job every 12 hours")
+	} else {
+		v.report("monitoring schedule", validateCronExpression(config.DbxPollingQuartzCron), config.DbxPollingQuartzCron)
+	}
+	if config.DbxVerifyJob && config.DbxVerifyQuartzCron != "" {
+		v.report("verification schedule", validateCronExpression(config.DbxVerifyQuartzCron), config.DbxVerifyQuartzCron)
+	}
+}
+
+// validateCloud reports the cloud of the workspace, whose node types and secret scopes the settings were checked
+// against, and warns if it isn't known.
+func validateCloud(v *validation, config *utils.Config) {
+	template, ok := dbx.WorkspaceCloud(config)
+	switch {
+	case !ok:
+		v.warn("cloud", "dbx_host doesn't tell the cloud of the workspace, set dbx_cloud to check the settings that "+
+			"differ between clouds, such as dbx_job_cluster_node_type")
+	case config.DbxCloud != "":
+		v.report("cloud", nil, template.Name+", from dbx_cloud")
+	default:
+		v.report("cloud", nil, template.Name+", detected from dbx_host")
+	}
+}
+
+// validateHlCreds authenticates to the default scanner, and to the alternative scanners that monitored schemas select,
+// like configHlCreds and authenticateScanners do, without prompting for missing credentials.
+func validateHlCreds(v *validation, config *utils.Config) {
+	if config.HlApiUrl == "" {
+		v.report("HiddenLayer", errors.New("hl_region or hl_api_url is not set"), "")
+		return
+	}
+	tlsConfig, err := hl.TLSConfig(config.HlTlsMinVersion, config.HlTlsPins)
+	if err != nil {
+		// Reported by the TLS settings check
+		return
+	}
+	for _, scanner := range config.ScannersInUse() {
+		item := "HiddenLayer"
+		if scanner.Name != utils.DefaultScannerName {
+			item = "scanner " + scanner.Name
+		}
+		switch {
+		case !scanner.UsesClientCredentials():
+			v.report(item, nil, fmt.Sprintf("%s needs no credentials", scanner.ApiUrl))
+		case scanner.ClientID == "" || scanner.ClientSecret == "":
+			v.report(item, errors.New("the client ID and secret are not set"), "")
+		default:
+			_, err := hl.Auth(scanner.AuthUrl, scanner.ClientID, scanner.ClientSecret, tlsConfig)
+			v.report(item, err, "authenticated at "+scanner.AuthUrl)
+		}
+	}
+	if config.DefaultScanner().UsesClientCredentials() && config.HlApiKeyName == "" {
+		v.report("hl_api_key_name", errors.New("not set, autoscan needs it to store the credentials"), "")
+	}
+}
+
+// validateDbxCreds authenticates to Databricks, and returns the client, or nil if the workspace URL or the token
+// isn't set. Authentication errors exit, like they do for autoscan with --non-interactive.
+func validateDbxCreds(v *validation, config *utils.Config) *databricks.WorkspaceClient {
+	if !utils.InDatabricks() {
+		if config.DbxHost == "" {
+			v.report("Databricks", errors.New("dbx_host is not set"), "")
+			return nil
+		}
+		if config.DbxToken == "" && config.DbxOidcIssuer == "" && keyringToken(config.DbxHost) == "" {
+			v.report("Databricks", errors.New("dbx_token is not set, and no token is stored in the OS keyring"), "")
+			return nil
+		}
+	}
+	dbxClient := configDbxCreds(config)
+	v.report("Databricks", nil, "authenticated to "+config.DbxHost)
+	return dbxClient
+}
+
+// validateDbxResources checks the Databricks resources that the configuration refers to: the monitored schemas,
+// the cluster of the jobs, and the service principal that they run as.
+func validateDbxResources(v *validation, config *utils.Config, dbxClient *databricks.WorkspaceClient) {
+	enabled, err := dbx.CheckUnityCatalog(dbxClient)
+	if err == nil && !enabled {
+		err = errors.New("no Unity Catalog metastore is assigned to the workspace")
+	}
+	v.report("Unity Catalog", err, "enabled")
+
+	if len(config.DbxSchemas) == 0 {
+		v.report("schemas", errors.New("dbx_schemas is not set"), "")
+	}
+	results, _ := dbx.ValidateSchemas(context.Background(), dbxClient, config.DbxSchemas, nil)
+	for _, result := range results {
+		item := fmt.Sprintf("schema %s.%s", result.Schema.Catalog, result.Schema.Schema)
+		switch {
+		case result.Err != nil:
+			v.report(item, result.Err, "")
+		case result.Status == dbx.SchemaFound:
+			v.report(item, nil, "found")
+		case result.Status == dbx.SchemaForbidden:
+			v.warn(item, "the Databricks token lacks USE CATALOG or USE SCHEMA on it, the jobs' identity needs them")
+		default:
+			v.report(item, errors.New("not found in Unity Catalog"), "")
+		}
+	}
+
+	switch {
+	case !config.UsesExistingCluster():
+		v.report("compute", nil, "the jobs run on "+computeDescription(config))
+	case config.DbxClusterId == "":
+		v.report("compute", errors.New("dbx_cluster_id is not set"), "")
+	default:
+		item := "cluster " + config.DbxClusterId
+		cluster := dbx.CheckCluster(dbxClient, config.DbxClusterId)
+		missing := dbx.MissingClusterTags(config, cluster.Tags)
+		switch {
+		case !cluster.Exists:
+			v.report(item, errors.New("not found in Databricks"), "")
+		case len(missing) > 0 && dbx.ClusterTagsEnforced(config):
+			v.report(item, fmt.Errorf("lacks the tags that dbx_required_cluster_tags requires: %s", strings.Join(missing, ", ")), "")
+		default:
+			v.report(item, nil, fmt.Sprintf("found (state: %s, access mode: %s, Unity Catalog: %t)", cluster.State,
+				cluster.AccessMode(), cluster.UnityCatalogEnabled))
+			if len(missing) > 0 {
+				v.warn(item, "lacks the tags that dbx_required_cluster_tags requires: "+strings.Join(missing, ", "))
+			}
+			for _, warning := range clusterWarnings(config, dbxClient, cluster) {
+				v.warn(item, warning)
+			}
+		}
+	}
+
+	if config.DbxServerless {
+		// The network policy is an account setting, which workspace credentials can't read
+		for _, destination := range dbx.ServerlessEgressDestinations(config) {
+			v.warn("serverless egress", fmt.Sprintf("the serverless network policy must allow %s (%s), which "+
+				"autoscan checks from the jobs' compute", destination.Host, destination.Purpose))
+		}
+	}
+
+	if config.DbxRunAs == "" {
+		v.report("run as", nil, "not set, the jobs run as the identity that creates them")
+	} else if dbx.ServicePrincipalExists(dbxClient.ServicePrincipals, config.DbxRunAs) {
+		v.report("service principal "+config.DbxRunAs, nil, "found")
+	} else {
+		v.report("service principal "+config.DbxRunAs, errors.New("not found in Databricks"), "")
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+
 	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
 	"github.com/spf13/cobra"
 )
@@ -11,10 +12,21 @@ var versionCmd = &cobra.Command{
 	Short: "Prints the hldbx version",
 	Long:  "Prints the version of the hldbx CLI tool.",
 	Run: func(cmd *cobra.Command, args []string) {
+		if outputFormat == outputJson {
+			printJson(struct {
+				Version   string `json:"version"`
+				GitCommit string `json:"git_commit,omitempty"`
+			}{utils.Version, utils.GitCommit()})
+			return
+		}
 		fmt.Printf("hldbx version: %s\n", utils.Version)
+		if commit := utils.GitCommit(); commit != "" {
+			fmt.Printf("git commit: %s\n", commit)
+		}
 	},
 }
 
 func init() {
+	supportJsonOutput(versionCmd)
 	rootCmd.AddCommand(versionCmd)
 }
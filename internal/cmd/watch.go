@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var watchInterval time.Duration
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Prints scanning activity in real time",
+	Long: "Tails the monitoring job runs, the scan job runs, and the scan results of the monitored schemas, " +
+		"printing new scan events and detections as they happen. With --reload, it picks up changes to the " +
+		"configuration file, such as newly monitored schemas, on the next poll. Press Ctrl+C to stop.",
+	Run: func(cmd *cobra.Command, args []string) {
+		config := readConfig()
+		dbxClient := configDbxCreds(config)
+		if len(config.DbxSchemas) == 0 {
+			utils.Fatal("No schemas to watch, add dbx_schemas to the configuration file")
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if outputFormat == outputText {
+			fmt.Printf("Watching %d schema(s), polling every %s. Press Ctrl+C to stop.\n", len(config.DbxSchemas), watchInterval)
+		}
+		encoder := json.NewEncoder(jsonOut)
+		err := dbx.Watch(ctx, dbxClient, configReloader(config), watchInterval, func(event dbx.WatchEvent) {
+			if outputFormat == outputJson {
+				// One JSON object per line, for piping into other tools
+				_ = encoder.Encode(event)
+				return
+			}
+			fmt.Printf("%s %s\n", event.Time.Format(time.TimeOnly), event.Message)
+		})
+		if err != nil {
+			utils.Fatalf("Error watching scanning activity: %v", err)
+		}
+	},
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 30*time.Second, "how often to poll Databricks")
+	watchCmd.Flags().BoolVar(&reloadMode, "reload", false, "reload the configuration file when it changes")
+	supportJsonOutput(watchCmd)
+	rootCmd.AddCommand(watchCmd)
+}
@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/dbx"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// The guided setup of autoscan offers what it detects as numbered choices: the workspaces of the Databricks CLI's
+// profiles and token caches, the clusters that the jobs can run on, the schemas that hold models, and the service
+// principals of the workspace. A value that isn't offered can still be typed.
+
+// choice is an option of inputChoice: the value that choosing it returns, and how it's listed.
+type choice struct {
+	value string
+	label string
+}
+
+// Most choices that inputChoice lists; with more, typing the value is quicker than finding its number
+const maxChoices = 30
+
+// canChoose returns true if the user can be prompted, so that detecting the choices to offer is worthwhile: not with
+// --non-interactive, nor within Databricks, where nobody can answer.
+func canChoose() bool {
+	return !nonInteractive && !utils.InDatabricks()
+}
+
+// inputChoice prompts the user to choose one of the choices for name by its number, or other, if not empty, to type
+// a value instead. Pressing Enter chooses defaultValue, which may be empty.
+// Returns the value chosen, and false if the user chose other, or nothing was offered because there are no choices,
+// too many to list, or nobody to ask, so that the caller prompts for the value instead.
+func inputChoice(name string, choices []choice, other string, defaultValue string) (string, bool) {
+	if len(choices) == 0 || len(choices) > maxChoices || !canChoose() {
+		return "", false
+	}
+	fmt.Printf("Choose %s:\n", name)
+	for i, c := range choices {
+		fmt.Printf("  %d) %s\n", i+1, c.label)
+	}
+	count := len(choices)
+	if other != "" {
+		count++
+		fmt.Printf("  %d) %s\n", count, other)
+	}
+	prompt := fmt.Sprintf("Enter a number from 1 to %d", count)
+	if defaultValue != "" {
+		prompt += fmt.Sprintf(" (default: %s)", defaultValue)
+	}
+	for {
+		fmt.Print(prompt + ": ")
+		input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if errors.Is(err, io.EOF) && strings.TrimSpace(input) == "" {
+			utils.Fatalf("No input for %s, stdin is closed", name)
+		} else if err != nil && !errors.Is(err, io.EOF) {
+			utils.Printf("Error reading %s: %v. Please try again.\n", name, err)
+			continue
+		}
+		input = strings.TrimSpace(input)
+		if input == "" {
+			return defaultValue, true
+		}
+		n, err := strconv.Atoi(input)
+		switch {
+		case err != nil || n < 1 || n > count:
+			fmt.Printf("Invalid choice %q. Please try again.\n", input)
+		case n > len(choices):
+			return "", false
+		default:
+			return choices[n-1].value, true
+		}
+	}
+}
+
+// tokenCachePaths returns the Databricks token caches that exist: the profile's own, then the Databricks CLI's.
+func tokenCachePaths() []string {
+	var paths []string
+	if profileTokenCache, err := utils.TokenCachePath(); err == nil {
+		paths = append(paths, profileTokenCache)
+	}
+	if usersHomeDir, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, usersHomeDir+"/.databricks/token-cache.json")
+	}
+	return slices.DeleteFunc(paths, func(path string) bool {
+		stats, err := os.Stat(path)
+		return err != nil || stats.IsDir()
+	})
+}
+
+// tokenCacheHosts returns the keys of the tokens in a token cache, which are workspace URLs, or nothing if it can't
+// be read.
+func tokenCacheHosts(path string) []string {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var tokenCache struct {
+		Tokens map[string]struct {
+			AccessToken string `json:"access_token"`
+		} `json:"tokens"`
+	}
+	if json.Unmarshal(contents, &tokenCache) != nil {
+		return nil
+	}
+	var hosts []string
+	for host, token := range tokenCache.Tokens {
+		if token.AccessToken != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	slices.Sort(hosts)
+	return hosts
+}
+
+// detectedWorkspace is a workspace found in a Databricks CLI profile, or a token cache, that can be signed in to.
+type detectedWorkspace struct {
+	host    string
+	profile dbx.DbxProfile // if found in a profile
+	cache   string         // if found in a token cache, its path
+	key     string         // of the token in the cache
+}
+
+// chooseDetectedWorkspace offers the reachable workspaces of the Databricks CLI's profiles and token caches, only
+// those at host if it's set, and returns the URL and a token of the one that the user chooses.
+// Returns empty values if there are none, or the user chooses to enter another workspace.
+func chooseDetectedWorkspace(host string) (string, string) {
+	if !canChoose() {
+		return "", ""
+	}
+	profiles, err := dbx.ListProfiles()
+	if err != nil {
+		slog.Warn("Unable to read the Databricks CLI profiles", "error", err)
+	}
+	var detected []detectedWorkspace
+	for _, profile := range profiles {
+		detected = append(detected, detectedWorkspace{host: profile.Host, profile: profile})
+	}
+	for _, path := range tokenCachePaths() {
+		for _, key := range tokenCacheHosts(path) {
+			if cacheHost, err := dbx.NormalizeHost(key); err == nil {
+				detected = append(detected, detectedWorkspace{host: cacheHost, cache: path, key: key})
+			}
+		}
+	}
+	detected = slices.DeleteFunc(detected, func(workspace detectedWorkspace) bool {
+		return host != "" && workspace.host != host
+	})
+	if len(detected) == 0 {
+		return "", ""
+	}
+	var hosts []string
+	for _, workspace := range detected {
+		if !slices.Contains(hosts, workspace.host) {
+			hosts = append(hosts, workspace.host)
+		}
+	}
+	fmt.Println("Checking the workspaces of your Databricks CLI profiles and token caches...")
+	reachable := dbx.ReachableHosts(context.Background(), hosts)
+	var choices []choice
+	for i, workspace := range detected {
+		if !slices.Contains(reachable, workspace.host) {
+			continue
+		}
+		label := fmt.Sprintf("%s (token cache %s)", workspace.host, workspace.cache)
+		if workspace.cache == "" {
+			label = fmt.Sprintf("%s (profile %s", workspace.host, workspace.profile.Name)
+			if workspace.profile.AuthType != "" {
+				label += ", " + workspace.profile.AuthType
+			}
+			label += ")"
+		}
+		choices = append(choices, choice{value: strconv.Itoa(i), label: label})
+	}
+	value, ok := inputChoice("the Databricks workspace to set up", choices, "Another workspace, or another way to sign in", "")
+	if !ok || value == "" {
+		return "", ""
+	}
+	i, _ := strconv.Atoi(value)
+	workspace := detected[i]
+	if workspace.cache != "" {
+		fmt.Println("Using OAuth Token from file")
+		return workspace.host, GetOAuthTokenFromFile(workspace.cache, workspace.key)
+	}
+	token, err := dbx.ProfileToken(context.Background(), workspace.profile)
+	if err != nil {
+		utils.Printf("Error signing in with profile %s: %v\n", workspace.profile.Name, err)
+		return "", ""
+	}
+	fmt.Printf("Using the credentials of Databricks CLI profile %s\n", workspace.profile.Name)
+	return workspace.host, token
+}
+
+// clusterChoices returns the clusters that the jobs can run on as choices, or nothing if they can't be listed.
+func clusterChoices(dbxClient *databricks.WorkspaceClient) []choice {
+	if !canChoose() {
+		return nil
+	}
+	clusters, err := dbx.AttachableClusters(context.Background(), dbxClient)
+	if err != nil {
+		slog.Warn("Unable to list the clusters", "error", err)
+		return nil
+	}
+	var choices []choice
+	for _, cluster := range clusters {
+		choices = append(choices, choice{
+			value: cluster.ClusterId,
+			label: fmt.Sprintf("%s (%s, %s, %s)", cluster.ClusterName, cluster.ClusterId, cluster.State, cluster.SparkVersion),
+		})
+	}
+	return choices
+}
+
+// detectSchemas returns the schemas that hold models, or nothing if they can't be listed.
+func detectSchemas(dbxClient *databricks.WorkspaceClient) []dbx.SchemaModels {
+	if !canChoose() {
+		return nil
+	}
+	fmt.Println("Looking for the schemas that hold models...")
+	schemas, err := dbx.SchemasWithModels(context.Background(), dbxClient)
+	if err != nil {
+		slog.Warn("Unable to list the schemas that hold models", "error", err)
+		return nil
+	}
+	return schemas
+}
+
+// schemaChoices returns the detected schemas as choices, other than those already chosen.
+func schemaChoices(detected []dbx.SchemaModels, chosen []utils.CatalogSchemaConfig) []choice {
+	var choices []choice
+	for _, schema := range detected {
+		if slices.Contains(chosen, schema.Schema) {
+			continue
+		}
+		name := schema.Schema.Catalog + "." + schema.Schema.Schema
+		choices = append(choices, choice{value: name, label: fmt.Sprintf("%s (%d models)", name, schema.Models)})
+	}
+	return choices
+}
+
+// servicePrincipalChoices returns the service principals of the workspace as choices, or nothing if they can't be
+// listed.
+func servicePrincipalChoices(dbxClient *databricks.WorkspaceClient) []choice {
+	if !canChoose() {
+		return nil
+	}
+	servicePrincipals, err := dbx.ListServicePrincipals(context.Background(), dbxClient.ServicePrincipals)
+	if err != nil {
+		slog.Warn("Unable to list the service principals", "error", err)
+		return nil
+	}
+	var choices []choice
+	for _, sp := range servicePrincipals {
+		choices = append(choices, choice{value: sp.ApplicationId, label: fmt.Sprintf("%s (%s)", sp.DisplayName, sp.ApplicationId)})
+	}
+	return choices
+}
+
+// Polling schedules that the guided setup offers, the first being the default
+var cronChoices = []choice{
+	{"0 0 */12 * * ?", "Every 12 hours: 0 0 */12 * * ?"},
+	{"0 0 */6 * * ?", "Every 6 hours: 0 0 */6 * * ?"},
+	{"0 0 * * * ?", "Every hour: 0 0 * * * ?"},
+	{"0 0 2 * * ?", "Every day at 02:00: 0 0 2 * * ?"},
+}
@@ -0,0 +1,301 @@
+package dbx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/catalog"
+	"github.com/databricks/databricks-sdk-go/service/sql"
+	"github.com/databricks/databricks-sdk-go/service/workspace"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Governed tag that marks the models that HiddenLayer found unsafe
+const abacUnsafeTag = "hl_unsafe"
+
+// Name of the record of the grants that blocking unsafe models revoked, in the workspace directory next to the
+// installation manifest, which unblocking them restores
+const abacGrantsFileName = "hl_abac_grants.json"
+
+// Privileges on a registered model that let a principal use it, which blocking it revokes. ALL PRIVILEGES can't be
+// revoked in part, so it's revoked whole.
+var abacBlockedPrivileges = []catalog.Privilege{catalog.PrivilegeExecute, catalog.PrivilegeAllPrivileges}
+
+// AbacReport lists the models that an ABAC run blocked or unblocked, by full name, and the principals that can still
+// use the blocked models through EXECUTE inherited from their schema or catalog, by model.
+type AbacReport struct {
+	Blocked   []string            `json:"blocked"`
+	Unblocked []string            `json:"unblocked"`
+	Inherited map[string][]string `json:"inherited,omitempty"`
+}
+
+// abacGrants records the privileges revoked from each principal, by model full name.
+type abacGrants map[string]map[string][]catalog.Privilege
+
+// ValidateAbacGroup checks the group that keeps access to models that HiddenLayer found unsafe.
+func ValidateAbacGroup(config *utils.Config) error {
+	if strings.Contains(config.DbxAbacGroup, "`") {
+		return fmt.Errorf("invalid dbx_abac_group %q, group names can't contain backticks", config.DbxAbacGroup)
+	}
+	return nil
+}
+
+// quoteIdentifier quotes a Unity Catalog name, e.g. a catalog.schema.model full name, for use in SQL.
+func quoteIdentifier(fullName string) string {
+	parts := strings.Split(fullName, ".")
+	for i, part := range parts {
+		parts[i] = "`" + strings.ReplaceAll(part, "`", "``") + "`"
+	}
+	return strings.Join(parts, ".")
+}
+
+// quoteLiteral quotes a string literal for use in SQL, doubling its single quotes.
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// abacTagStatement returns the SQL statement that sets or unsets the unsafe tag of a registered model.
+func abacTagStatement(model string, unsafe bool) string {
+	if unsafe {
+		return fmt.Sprintf("ALTER REGISTERED MODEL %s SET TAGS (%s = 'true')", quoteIdentifier(model), quoteLiteral(abacUnsafeTag))
+	}
+	return fmt.Sprintf("ALTER REGISTERED MODEL %s UNSET TAGS (%s)", quoteIdentifier(model), quoteLiteral(abacUnsafeTag))
+}
+
+// ApplyAbacPolicies tags the models with detections in their scanned versions as unsafe, through a SQL warehouse,
+// and blocks them: it revokes the privileges to use them that are granted on the models themselves from everyone but
+// the dbx_abac_group, and grants the group EXECUTE. Unity Catalog has no deny, and ABAC policies only filter tables,
+// so principals with EXECUTE on the schema or catalog can still use them; they're reported. The revoked grants are
+// recorded in the workspace directory. Models whose scanned versions are all safe are untagged, and their revoked
+// grants restored, so a rescan or a new version lifts the block.
+func ApplyAbacPolicies(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config,
+	warehouseId string) (AbacReport, error) {
+	report := AbacReport{Inherited: map[string][]string{}}
+	if config.DbxAbacGroup == "" {
+		return report, fmt.Errorf("set dbx_abac_group to the group that keeps access to unsafe models")
+	}
+	results, err := ListScanResults(ctx, client, config)
+	if err != nil {
+		return report, err
+	}
+	unsafe := map[string]bool{}
+	for _, result := range results {
+		if result.Status == scanStatusDone {
+			unsafe[result.Model] = unsafe[result.Model] || result.IsDetection()
+		}
+	}
+	grants, err := readAbacGrants(ctx, client, config)
+	if err != nil {
+		return report, err
+	}
+	// The record is written after each model, so that a failure doesn't lose the grants revoked before it
+	for _, model := range slices.Sorted(maps.Keys(unsafe)) {
+		isUnsafe := unsafe[model]
+		if err := executeStatement(ctx, client, warehouseId, abacTagStatement(model, isUnsafe)); err != nil {
+			return report, fmt.Errorf("unable to update the %s tag of model %s: %w", abacUnsafeTag, model, err)
+		}
+		if isUnsafe {
+			inherited, err := blockModel(ctx, client, model, config.DbxAbacGroup, grants)
+			if err != nil {
+				return report, err
+			}
+			if len(inherited) > 0 {
+				report.Inherited[model] = inherited
+			}
+			report.Blocked = append(report.Blocked, model)
+		} else {
+			if err := unblockModel(ctx, client, model, grants); err != nil {
+				return report, err
+			}
+			report.Unblocked = append(report.Unblocked, model)
+		}
+		if err := writeAbacGrants(ctx, client, config, grants); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// RevertAbacPolicies untags the models of each monitored schema, through a SQL warehouse, and restores the grants
+// that blocking them revoked, so that access to them is back to their grants before.
+func RevertAbacPolicies(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config,
+	warehouseId string) (AbacReport, error) {
+	var report AbacReport
+	grants, err := readAbacGrants(ctx, client, config)
+	if err != nil {
+		return report, err
+	}
+	for _, schema := range config.DbxSchemas {
+		models, err := client.RegisteredModels.ListAll(ctx, catalog.ListRegisteredModelsRequest{
+			CatalogName: schema.Catalog,
+			SchemaName:  schema.Schema,
+		})
+		if err != nil {
+			return report, fmt.Errorf("unable to list models in %s.%s: %w", schema.Catalog, schema.Schema, err)
+		}
+		for _, model := range models {
+			if err := executeStatement(ctx, client, warehouseId, abacTagStatement(model.FullName, false)); err != nil {
+				return report, fmt.Errorf("unable to remove the %s tag of model %s: %w", abacUnsafeTag, model.FullName, err)
+			}
+			if err := unblockModel(ctx, client, model.FullName, grants); err != nil {
+				return report, err
+			}
+			if err := writeAbacGrants(ctx, client, config, grants); err != nil {
+				return report, err
+			}
+			report.Unblocked = append(report.Unblocked, model.FullName)
+		}
+	}
+	return report, nil
+}
+
+// blockModel revokes the privileges to use a model that are granted on it from everyone but the group, adding them
+// to grants, and grants the group EXECUTE. Returns the other principals that have EXECUTE through inheritance.
+func blockModel(ctx context.Context, client *databricks.WorkspaceClient, model string, group string,
+	grants abacGrants) ([]string, error) {
+	current, err := client.Grants.Get(ctx, catalog.GetGrantRequest{SecurableType: catalog.SecurableTypeFunction, FullName: model})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the grants of model %s: %w", model, err)
+	}
+	changes := []catalog.PermissionsChange{{Principal: group, Add: []catalog.Privilege{catalog.PrivilegeExecute}}}
+	revoked := grants[model]
+	if revoked == nil {
+		revoked = map[string][]catalog.Privilege{}
+	}
+	for _, assignment := range current.PrivilegeAssignments {
+		if assignment.Principal == group {
+			continue
+		}
+		var remove []catalog.Privilege
+		for _, privilege := range assignment.Privileges {
+			if slices.Contains(abacBlockedPrivileges, privilege) {
+				remove = append(remove, privilege)
+			}
+		}
+		if len(remove) > 0 {
+			changes = append(changes, catalog.PermissionsChange{Principal: assignment.Principal, Remove: remove})
+			for _, privilege := range remove {
+				if !slices.Contains(revoked[assignment.Principal], privilege) {
+					revoked[assignment.Principal] = append(revoked[assignment.Principal], privilege)
+				}
+			}
+		}
+	}
+	_, err = client.Grants.Update(ctx, catalog.UpdatePermissions{
+		SecurableType: catalog.SecurableTypeFunction,
+		FullName:      model,
+		Changes:       changes,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to revoke the grants of model %s: %w", model, err)
+	}
+	if len(revoked) > 0 {
+		grants[model] = revoked
+	}
+
+	effective, err := client.Grants.GetEffective(ctx, catalog.GetEffectiveRequest{
+		SecurableType: catalog.SecurableTypeFunction,
+		FullName:      model,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the effective grants of model %s: %w", model, err)
+	}
+	var inherited []string
+	for _, assignment := range effective.PrivilegeAssignments {
+		if assignment.Principal == group {
+			continue
+		}
+		for _, privilege := range assignment.Privileges {
+			if privilege.InheritedFromName != "" && slices.Contains(abacBlockedPrivileges, privilege.Privilege) {
+				inherited = append(inherited, assignment.Principal)
+				break
+			}
+		}
+	}
+	return inherited, nil
+}
+
+// unblockModel restores the grants of a model that blocking it revoked, removing them from grants.
+func unblockModel(ctx context.Context, client *databricks.WorkspaceClient, model string, grants abacGrants) error {
+	revoked := grants[model]
+	if len(revoked) == 0 {
+		return nil
+	}
+	var changes []catalog.PermissionsChange
+	for _, principal := range slices.Sorted(maps.Keys(revoked)) {
+		changes = append(changes, catalog.PermissionsChange{Principal: principal, Add: revoked[principal]})
+	}
+	_, err := client.Grants.Update(ctx, catalog.UpdatePermissions{
+		SecurableType: catalog.SecurableTypeFunction,
+		FullName:      model,
+		Changes:       changes,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to restore the grants of model %s: %w", model, err)
+	}
+	delete(grants, model)
+	return nil
+}
+
+// abacGrantsPath returns the workspace path of the record of revoked grants.
+func abacGrantsPath(config *utils.Config) string {
+	dir := config.DbxWorkspaceDir
+	if dir == "" {
+		dir = defaultWorkspaceDir
+	}
+	return fmt.Sprintf("%s/%s", dir, abacGrantsFileName)
+}
+
+// readAbacGrants reads the record of revoked grants, which is empty if there is none.
+func readAbacGrants(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) (abacGrants, error) {
+	path := abacGrantsPath(config)
+	grants := abacGrants{}
+	reader, err := client.Workspace.Download(ctx, path)
+	if errors.Is(err, databricks.ErrResourceDoesNotExist) || errors.Is(err, databricks.ErrNotFound) {
+		return grants, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to download %s: %w", path, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &grants); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+	return grants, nil
+}
+
+// writeAbacGrants writes the record of revoked grants.
+func writeAbacGrants(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, grants abacGrants) error {
+	path := abacGrantsPath(config)
+	data, err := json.MarshalIndent(grants, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = client.Workspace.Upload(ctx, path, bytes.NewReader(data), workspace.UploadFormat(workspace.ImportFormatAuto),
+		workspace.UploadOverwrite())
+	if err != nil {
+		return fmt.Errorf("unable to upload %s: %w", path, err)
+	}
+	return nil
+}
+
+// executeStatement runs a SQL statement that returns no results on a SQL warehouse.
+func executeStatement(ctx context.Context, client *databricks.WorkspaceClient, warehouseId string, statement string) error {
+	_, err := client.StatementExecution.ExecuteAndWait(ctx, sql.ExecuteStatementRequest{
+		WarehouseId: warehouseId,
+		Statement:   statement,
+		WaitTimeout: "30s",
+	})
+	return err
+}
@@ -0,0 +1,253 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/catalog"
+	"github.com/databricks/databricks-sdk-go/service/sql"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/reugn/go-quartz/quartz"
+)
+
+// Granularity of the start times of recommended schedules
+const adviseScheduleStep = 15 * time.Minute
+
+// Recommend a schedule only if it cuts the mean exposure window by at least this fraction
+const adviseMinImprovement = 0.2
+
+// Sources of model version registration times
+const (
+	RegistrationSourceAudit    = "system.access.audit"
+	RegistrationSourceRegistry = "registry"
+)
+
+// Query of the Unity Catalog audit log for the registration times of model versions in a schema, as epoch millis
+const registrationsQuery = `SELECT CAST(unix_millis(event_time) AS STRING)
+FROM system.access.audit
+WHERE service_name = 'unityCatalog'
+  AND action_name = 'createModelVersion'
+  AND response.status_code = 200
+  AND event_date >= date_sub(current_date(), :days)
+  AND request_params['name'] LIKE :model_prefix`
+
+// ScanWindowReport compares when model versions are registered with when the monitoring job runs.
+// The exposure window of a model version is the time from its registration to the next monitoring job run,
+// when its scan starts, during which it can be deployed unscanned.
+type ScanWindowReport struct {
+	Source                  string        `json:"source"` // where the registration times come from
+	Days                    int           `json:"days"`
+	Schedule                string        `json:"schedule"`
+	Interval                time.Duration `json:"interval_ns"`
+	Registrations           int           `json:"registrations"`
+	RegistrationsByHour     [24]int       `json:"registrations_by_hour"` // UTC
+	MeanExposure            time.Duration `json:"mean_exposure_ns"`
+	P90Exposure             time.Duration `json:"p90_exposure_ns"`
+	MaxExposure             time.Duration `json:"max_exposure_ns"`
+	Recommended             string        `json:"recommended_schedule,omitempty"`
+	RecommendedMeanExposure time.Duration `json:"recommended_mean_exposure_ns,omitempty"`
+}
+
+// PeakHour returns the UTC hour in which the most model versions were registered, and its share of registrations.
+func (r *ScanWindowReport) PeakHour() (int, float64) {
+	peak := 0
+	for hour, count := range r.RegistrationsByHour {
+		if count > r.RegistrationsByHour[peak] {
+			peak = hour
+		}
+	}
+	if r.Registrations == 0 {
+		return peak, 0
+	}
+	return peak, float64(r.RegistrationsByHour[peak]) / float64(r.Registrations)
+}
+
+// AdviseScanWindow analyzes the registration times of model versions in the monitored schemas over the past days,
+// and reports how long they wait for the monitoring job's schedule, as configured or else as installed, to scan
+// them. If a schedule with the same number of runs a day would shorten the wait significantly, it is recommended.
+// Registration times come from the audit system table if a SQL warehouse is given, otherwise from the model
+// registry, which only has the versions that still exist.
+func AdviseScanWindow(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, days int,
+	warehouseId string) (*ScanWindowReport, error) {
+	report := &ScanWindowReport{Source: RegistrationSourceRegistry, Days: days, Schedule: config.DbxPollingQuartzCron}
+	if report.Schedule == "" {
+		// Use the schedule of the installed monitoring job
+		job, err := monitorJob(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+		if job.Settings.Schedule == nil {
+			return nil, fmt.Errorf("job %d has no schedule", job.JobId)
+		}
+		report.Schedule = job.Settings.Schedule.QuartzCronExpression
+	}
+	var err error
+	if report.Interval, err = scheduleInterval(report.Schedule); err != nil {
+		return nil, err
+	}
+	since := time.Now().Add(-time.Duration(days) * 24 * time.Hour)
+	var registrations []time.Time
+	if warehouseId != "" {
+		report.Source = RegistrationSourceAudit
+		registrations, err = auditRegistrationTimes(ctx, client, config, days, warehouseId)
+	} else {
+		registrations, err = registryRegistrationTimes(ctx, client, config, since)
+	}
+	if err != nil {
+		return nil, err
+	}
+	report.Registrations = len(registrations)
+	if len(registrations) == 0 {
+		return report, nil
+	}
+	for _, registration := range registrations {
+		report.RegistrationsByHour[registration.UTC().Hour()]++
+	}
+
+	trigger, err := quartz.NewCronTrigger(report.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quartz cron expression %q: %w", report.Schedule, err)
+	}
+	exposures := make([]time.Duration, 0, len(registrations))
+	for _, registration := range registrations {
+		next, err := trigger.NextFireTime(registration.UnixNano())
+		if err != nil {
+			return nil, err
+		}
+		exposures = append(exposures, time.Duration(next-registration.UnixNano()))
+	}
+	report.MeanExposure, report.P90Exposure, report.MaxExposure = exposureStats(exposures)
+
+	// Only schedules whose runs are evenly spaced within a day can be shifted to fit the registrations
+	if report.Interval < time.Hour || report.Interval > 24*time.Hour || report.Interval%time.Hour != 0 || (24*time.Hour)%report.Interval != 0 {
+		return report, nil
+	}
+	// Pick the start time with the shortest mean wait, that doesn't make the longer waits any longer
+	best, bestMean := time.Duration(-1), report.MeanExposure
+	for offset := time.Duration(0); offset < report.Interval; offset += adviseScheduleStep {
+		mean, p90, _ := exposureStats(shiftedExposures(registrations, report.Interval, offset))
+		if mean < bestMean && p90 <= report.P90Exposure {
+			best, bestMean = offset, mean
+		}
+	}
+	if best >= 0 && float64(bestMean) <= float64(report.MeanExposure)*(1-adviseMinImprovement) {
+		report.Recommended = shiftedSchedule(report.Interval, best)
+		report.RecommendedMeanExposure = bestMean
+	}
+	return report, nil
+}
+
+// auditRegistrationTimes queries the audit system table, through a SQL warehouse, for the times that model versions
+// were created in the monitored schemas.
+func auditRegistrationTimes(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, days int,
+	warehouseId string) ([]time.Time, error) {
+	var registrations []time.Time
+	for _, schema := range config.DbxSchemas {
+		response, err := client.StatementExecution.ExecuteAndWait(ctx, sql.ExecuteStatementRequest{
+			WarehouseId: warehouseId,
+			Statement:   registrationsQuery,
+			Parameters: []sql.StatementParameterListItem{
+				{Name: "days", Type: "INT", Value: strconv.Itoa(days)},
+				{Name: "model_prefix", Value: fmt.Sprintf("%s.%s.%%", schema.Catalog, schema.Schema)},
+			},
+			Disposition: sql.DispositionInline,
+			Format:      sql.FormatJsonArray,
+			WaitTimeout: "30s",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to query %s for registrations in %s.%s: %w", RegistrationSourceAudit, schema.Catalog, schema.Schema, err)
+		}
+		result := response.Result
+		for result != nil {
+			for _, row := range result.DataArray {
+				if len(row) == 0 {
+					continue
+				}
+				millis, err := strconv.ParseInt(row[0], 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("unexpected event time %q in %s: %w", row[0], RegistrationSourceAudit, err)
+				}
+				registrations = append(registrations, time.UnixMilli(millis))
+			}
+			if result.NextChunkIndex == 0 {
+				break
+			}
+			result, err = client.StatementExecution.GetStatementResultChunkNByStatementIdAndChunkIndex(ctx, response.StatementId, result.NextChunkIndex)
+			if err != nil {
+				return nil, fmt.Errorf("unable to get results of the %s query: %w", RegistrationSourceAudit, err)
+			}
+		}
+	}
+	return registrations, nil
+}
+
+// registryRegistrationTimes returns the creation times of the model versions in the monitored schemas since a time.
+func registryRegistrationTimes(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config,
+	since time.Time) ([]time.Time, error) {
+	var registrations []time.Time
+	for _, schema := range config.DbxSchemas {
+		models, err := client.RegisteredModels.ListAll(ctx, catalog.ListRegisteredModelsRequest{
+			CatalogName: schema.Catalog,
+			SchemaName:  schema.Schema,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list models in %s.%s: %w", schema.Catalog, schema.Schema, err)
+		}
+		for _, model := range models {
+			versions, err := client.ModelVersions.ListAll(ctx, catalog.ListModelVersionsRequest{FullName: model.FullName})
+			if err != nil {
+				return nil, fmt.Errorf("unable to list versions of model %s: %w", model.FullName, err)
+			}
+			for _, version := range versions {
+				if created := time.UnixMilli(version.CreatedAt); created.After(since) {
+					registrations = append(registrations, created)
+				}
+			}
+		}
+	}
+	return registrations, nil
+}
+
+// exposureStats returns the mean, 90th percentile, and maximum of the exposure windows.
+func exposureStats(exposures []time.Duration) (time.Duration, time.Duration, time.Duration) {
+	sorted := slices.Clone(exposures)
+	slices.Sort(sorted)
+	var total time.Duration
+	for _, exposure := range sorted {
+		total += exposure
+	}
+	p90 := sorted[min(len(sorted)*9/10, len(sorted)-1)]
+	return total / time.Duration(len(sorted)), p90, sorted[len(sorted)-1]
+}
+
+// shiftedExposures returns the exposure windows if the monitoring job ran every interval, starting at the offset
+// from midnight UTC each day. The interval must divide a day.
+func shiftedExposures(registrations []time.Time, interval time.Duration, offset time.Duration) []time.Duration {
+	exposures := make([]time.Duration, 0, len(registrations))
+	for _, registration := range registrations {
+		utc := registration.UTC()
+		sinceMidnight := utc.Sub(time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.UTC))
+		// Time since the latest run at or before the registration, then until the next run after it
+		sinceRun := (sinceMidnight - offset + 24*time.Hour) % interval
+		exposures = append(exposures, interval-sinceRun)
+	}
+	return exposures
+}
+
+// shiftedSchedule returns the quartz cron expression of runs every interval, starting at the offset from midnight
+// UTC each day. The interval must be a whole number of hours that divides a day.
+func shiftedSchedule(interval time.Duration, offset time.Duration) string {
+	hour, minute := int(offset/time.Hour), int(offset%time.Hour/time.Minute)
+	hours := int(interval / time.Hour)
+	if hours == 24 {
+		return fmt.Sprintf("0 %d %d * * ?", minute, hour)
+	}
+	if hours == 1 {
+		return fmt.Sprintf("0 %d * * * ?", minute)
+	}
+	return fmt.Sprintf("0 %d %d/%d * * ?", minute, hour, hours)
+}
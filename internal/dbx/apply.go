@@ -0,0 +1,135 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Names of the settings that ApplyConfig reports for changes to the monitoring job's schedule, name, description,
+// tags, compute, and budget policy
+const (
+	scheduleSettingName     = "schedule"
+	nameSettingName         = "name"
+	descriptionSettingName  = "description"
+	tagsSettingName         = "tags"
+	computeSettingName      = "compute"
+	budgetPolicySettingName = "budget_policy"
+)
+
+// SettingChange is a setting of the installed monitoring job that differs from the configuration.
+type SettingChange struct {
+	Name  string `json:"name"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Added bool   `json:"added,omitempty"` // the installed job doesn't have the setting yet
+}
+
+// ApplyConfig updates the parameters and schedule of the installed monitoring job to match the configuration,
+// in place, so its ID, run history, and permissions are kept. If the job runs on other compute than the
+// configuration's, e.g. after dbx_serverless changed, or is attributed to another budget policy, every installed job
+// is moved, as FixJobCompute moves them. The monitored schemas are left as installed, since changing them also moves
+// secrets; use AddMonitoredSchema and RemoveMonitoredSchema for those.
+// Returns the settings that changed. If dryRun is set, the jobs are left as is.
+func ApplyConfig(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, dryRun bool) ([]SettingChange, error) {
+	job, err := monitorJob(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	desired := monitorJobSettings(config)
+
+	var changes []SettingChange
+	params := slices.Clone(job.Settings.Parameters)
+	for _, param := range desired.Parameters {
+		if param.Name == schemasParamName {
+			continue
+		}
+		i := slices.IndexFunc(params, func(p jobs.JobParameterDefinition) bool { return p.Name == param.Name })
+		if i < 0 {
+			// A parameter added by a newer hldbx
+			changes = append(changes, SettingChange{Name: param.Name, To: param.Default, Added: true})
+			params = append(params, param)
+			continue
+		}
+		if params[i].Default != param.Default {
+			changes = append(changes, SettingChange{Name: param.Name, From: params[i].Default, To: param.Default})
+			params[i].Default = param.Default
+		}
+	}
+	settings := &jobs.JobSettings{Parameters: params}
+	if config.DbxPollingQuartzCron != "" && (job.Settings.Schedule == nil ||
+		job.Settings.Schedule.QuartzCronExpression != config.DbxPollingQuartzCron) {
+		change := SettingChange{Name: scheduleSettingName, To: config.DbxPollingQuartzCron}
+		if job.Settings.Schedule != nil {
+			change.From = job.Settings.Schedule.QuartzCronExpression
+			// Keep the pause status of the installed schedule
+			schedule := *job.Settings.Schedule
+			schedule.QuartzCronExpression = config.DbxPollingQuartzCron
+			settings.Schedule = &schedule
+		} else {
+			settings.Schedule = desired.Schedule
+		}
+		changes = append(changes, change)
+	}
+	if job.Settings.Name != desired.Name {
+		changes = append(changes, SettingChange{Name: nameSettingName, From: job.Settings.Name, To: desired.Name})
+		settings.Name = desired.Name
+	}
+	if job.Settings.Description != desired.Description {
+		changes = append(changes, SettingChange{Name: descriptionSettingName, From: job.Settings.Description, To: desired.Description})
+		settings.Description = desired.Description
+		settings.ForceSendFields = append(settings.ForceSendFields, "Description") // to clear it, too
+	}
+	if _, tagged := job.Settings.Tags[hlJobTag]; !tagged {
+		// A job created before jobs were tagged, keep its other tags
+		tags := maps.Clone(job.Settings.Tags)
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		maps.Copy(tags, desired.Tags)
+		changes = append(changes, SettingChange{Name: tagsSettingName, To: fmt.Sprintf("%s=%s", hlJobTag, monitorJobKey), Added: true})
+		settings.Tags = tags
+	}
+	jobChanges := len(changes)
+	if len(job.Settings.Tasks) > 0 && len(desired.Tasks) > 0 {
+		if from, to := taskCompute(job.Settings.Tasks[0]), taskCompute(desired.Tasks[0]); from != to {
+			changes = append(changes, SettingChange{Name: computeSettingName, From: from, To: to})
+		}
+	}
+	if job.Settings.BudgetPolicyId != desired.BudgetPolicyId {
+		changes = append(changes, SettingChange{Name: budgetPolicySettingName, From: job.Settings.BudgetPolicyId,
+			To: desired.BudgetPolicyId})
+	}
+	if len(changes) == 0 || dryRun {
+		return changes, nil
+	}
+	if jobChanges > 0 {
+		// Top-level settings are replaced as a whole, so send the full parameter list
+		if err := client.Jobs.Update(ctx, jobs.UpdateJob{JobId: job.JobId, NewSettings: settings}); err != nil {
+			return nil, fmt.Errorf("unable to update job %d: %w", job.JobId, err)
+		}
+	}
+	if len(changes) > jobChanges {
+		if _, err := FixJobCompute(ctx, client, config); err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+// taskCompute describes the compute that a job task runs on: a cluster, a job cluster, or serverless compute.
+func taskCompute(task jobs.Task) string {
+	switch {
+	case task.ExistingClusterId != "":
+		return "cluster " + task.ExistingClusterId
+	case task.JobClusterKey != "" || task.NewCluster != nil:
+		return "job cluster"
+	default:
+		return "serverless"
+	}
+}
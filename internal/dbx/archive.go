@@ -0,0 +1,66 @@
+package dbx
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Name of the notebook that exports aged records of the state tables to the archive location, and prunes them
+const archiveNotebookName = "hl_archive"
+
+// Task key of the archiving task in the monitoring job
+const archiveTaskKey = "archive"
+
+// Prefixes of the archive locations that the notebook can write Parquet files to: the cloud storage of a Unity
+// Catalog external location, or a Volume
+var archiveLocationPrefixes = []string{"s3://", "abfss://", "gs://", "/Volumes/"}
+
+// ValidateRetention checks the retention of the state tables' records. Records are only pruned once they are
+// exported, so a retention needs an archive location.
+func ValidateRetention(config *utils.Config) error {
+	if config.DbxArchiveLocation != "" && !slices.ContainsFunc(archiveLocationPrefixes, func(prefix string) bool {
+		return strings.HasPrefix(config.DbxArchiveLocation, prefix)
+	}) {
+		return fmt.Errorf("invalid dbx_archive_location %q, expected the URL of an external location, such as "+
+			"s3://<bucket>/hiddenlayer, abfss://<container>@<account>.dfs.core.windows.net/hiddenlayer, or "+
+			"gs://<bucket>/hiddenlayer, or a Volume path, such as /Volumes/<catalog>/<schema>/<volume>",
+			config.DbxArchiveLocation)
+	}
+	if config.DbxRetentionDays > 0 && config.DbxArchiveLocation == "" {
+		return errors.New("dbx_retention_days needs dbx_archive_location, which aged records are exported to before " +
+			"they are pruned")
+	}
+	if config.DbxRetentionDays > 0 && config.StateTable() == "" {
+		return errors.New("dbx_retention_days needs a state table, set dbx_state_table or dbx_schemas")
+	}
+	return nil
+}
+
+// archiveTask returns the monitoring job task that exports the records of the state tables older than the retention
+// to Parquet files in the archive location, then deletes them from the tables. It runs after the heartbeat task, so
+// that the heartbeats it prunes are up to date.
+func archiveTask(config *utils.Config) jobs.Task {
+	return jobs.Task{
+		Description:       "Export aged HiddenLayer scan records to the archive location, and prune them",
+		ExistingClusterId: taskClusterId(config),
+		JobClusterKey:     taskJobClusterKey(config),
+		TaskKey:           archiveTaskKey,
+		DependsOn:         []jobs.TaskDependency{{TaskKey: heartbeatTaskKey}},
+		RunIf:             jobs.RunIfAllDone,
+		NotebookTask: &jobs.NotebookTask{
+			NotebookPath: fmt.Sprintf("%s/%s", getHLWorkspaceDirectory(config), archiveNotebookName),
+			BaseParameters: map[string]string{
+				"state_table":      config.StateTable(),
+				"retention_days":   strconv.Itoa(int(config.DbxRetentionDays)),
+				"archive_location": strings.TrimSuffix(config.DbxArchiveLocation, "/"),
+				"job_run_id":       "{{job.run_id}}",
+			},
+		},
+	}
+}
@@ -0,0 +1,78 @@
+package dbx
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Name of the notebook that scans prompt and agent artifacts
+const artifactsNotebookName = "hl_scan_artifacts"
+
+// Task key of the artifact scanning task in the monitoring job
+const artifactsTaskKey = "artifacts"
+
+// Types of artifact sources. These must match hl_scan_artifacts.py.
+const (
+	ArtifactSourceVolume           = "volume"
+	ArtifactSourceMlflowExperiment = "mlflow_experiment"
+)
+
+// ValidateArtifactSources checks the configured sources of prompt and agent artifacts to scan.
+func ValidateArtifactSources(config *utils.Config) error {
+	names := map[string]bool{}
+	for i, source := range config.DbxArtifactSources {
+		if source.Name == "" {
+			return fmt.Errorf("artifact source %d has no name", i+1)
+		}
+		if names[source.Name] {
+			return fmt.Errorf("artifact source name %q is used more than once", source.Name)
+		}
+		names[source.Name] = true
+		switch source.Type {
+		case ArtifactSourceVolume:
+			if !strings.HasPrefix(source.Path, "/Volumes/") {
+				return fmt.Errorf("artifact source %q needs a path on a Unity Catalog Volume, such as /Volumes/<catalog>/<schema>/<volume>/prompts", source.Name)
+			}
+		case ArtifactSourceMlflowExperiment:
+			if source.Experiment == "" {
+				return fmt.Errorf("artifact source %q needs the name of an MLflow experiment, such as /Shared/agents", source.Name)
+			}
+		default:
+			return fmt.Errorf("artifact source %q has unknown type %q, expected %s or %s", source.Name, source.Type,
+				ArtifactSourceVolume, ArtifactSourceMlflowExperiment)
+		}
+		for _, pattern := range source.Patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("artifact source %q has invalid pattern %q: %w", source.Name, pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// artifactsTask returns the monitoring job task that scans the configured artifact sources.
+func artifactsTask(config *utils.Config) jobs.Task {
+	sourcesParam, err := json.Marshal(config.DbxArtifactSources)
+	if err != nil {
+		utils.Fatalf("Error marshalling artifact sources: %v", err)
+	}
+	return jobs.Task{
+		Description:       "Scan prompt and agent artifacts using HiddenLayer",
+		ExistingClusterId: taskClusterId(config),
+		JobClusterKey:     taskJobClusterKey(config),
+		TaskKey:           artifactsTaskKey,
+		NotebookTask: &jobs.NotebookTask{
+			NotebookPath: fmt.Sprintf("%s/%s", getHLWorkspaceDirectory(config), artifactsNotebookName),
+			BaseParameters: map[string]string{
+				"artifact_sources":    string(sourcesParam),
+				"state_table":         config.StateTable(),
+				requestingJobRunIdKey: "{{job.run_id}}",
+			},
+		},
+	}
+}
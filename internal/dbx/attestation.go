@@ -0,0 +1,131 @@
+package dbx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/workspace"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Name of the install audit log, next to the installation manifest: a JSON record per line of each change that hldbx
+// made to the installation, appended to and never rewritten, so that security can trace changes to operators
+const auditLogFileName = "hl_install_audit.jsonl"
+
+// Operator identifies who changed an installation, from where, and with which hldbx binary.
+type Operator struct {
+	Identity     string `json:"identity"` // user name, or service principal application ID, of the Databricks credentials
+	Hostname     string `json:"hostname,omitempty"`
+	HldbxVersion string `json:"hldbx_version"`
+	GitCommit    string `json:"git_commit,omitempty"` // of the hldbx binary, see utils.GitCommit
+}
+
+// InstallRecord is an entry of the install audit log.
+type InstallRecord struct {
+	Time          string   `json:"time"`
+	Command       string   `json:"command"`        // e.g. hldbx autoscan
+	CorrelationId string   `json:"correlation_id"` // matches the invocation's requests in the Databricks audit log
+	InstallId     string   `json:"install_id,omitempty"`
+	Operator      Operator `json:"operator"`
+}
+
+// AuditLogPath returns the workspace path of the install audit log.
+func AuditLogPath(config *utils.Config) string {
+	dir := config.DbxWorkspaceDir
+	if dir == "" {
+		dir = defaultWorkspaceDir
+	}
+	return fmt.Sprintf("%s/%s", dir, auditLogFileName)
+}
+
+// currentOperator returns the operator of this invocation: the identity of the client's credentials, this machine,
+// and this hldbx binary.
+func currentOperator(ctx context.Context, client *databricks.WorkspaceClient) (Operator, error) {
+	me, err := client.CurrentUser.Me(ctx)
+	if err != nil {
+		return Operator{}, fmt.Errorf("unable to get the current Databricks user: %w", err)
+	}
+	operator := Operator{Identity: me.UserName, HldbxVersion: utils.Version, GitCommit: utils.GitCommit()}
+	if operator.Identity == "" {
+		operator.Identity = me.Id
+	}
+	// Best effort, the identity is what matters
+	operator.Hostname, _ = os.Hostname()
+	return operator, nil
+}
+
+// readAuditRecords returns the records of the install audit log, oldest first, or nothing if there is no log yet.
+// Lines that aren't records are skipped.
+func readAuditRecords(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) ([]InstallRecord, error) {
+	path := AuditLogPath(config)
+	reader, err := client.Workspace.Download(ctx, path)
+	if errors.Is(err, databricks.ErrResourceDoesNotExist) || errors.Is(err, databricks.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to download %s: %w", path, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	var records []InstallRecord
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var record InstallRecord
+		if json.Unmarshal(line, &record) == nil && record.Command != "" {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// AppendAuditRecord records a change to the installation that command made in the install audit log.
+func AppendAuditRecord(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, command string) error {
+	operator, err := currentOperator(ctx, client)
+	if err != nil {
+		return err
+	}
+	record := InstallRecord{Time: time.Now().UTC().Format(time.RFC3339), Command: command,
+		CorrelationId: utils.CorrelationId(), Operator: operator}
+	if manifest, err := ReadManifest(ctx, client, config); err == nil && manifest != nil {
+		record.InstallId = manifest.InstallId
+	}
+	return appendAuditRecord(ctx, client, config, record)
+}
+
+// appendAuditRecord appends a record to the install audit log, creating it if needed. Workspace files can't be
+// appended to, so the log is downloaded and uploaded again with the record.
+func appendAuditRecord(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, record InstallRecord) error {
+	path := AuditLogPath(config)
+	var data []byte
+	reader, err := client.Workspace.Download(ctx, path)
+	if err == nil {
+		defer reader.Close()
+		if data, err = io.ReadAll(reader); err != nil {
+			return fmt.Errorf("unable to read %s: %w", path, err)
+		}
+	} else if !errors.Is(err, databricks.ErrResourceDoesNotExist) && !errors.Is(err, databricks.ErrNotFound) {
+		return fmt.Errorf("unable to download %s: %w", path, err)
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	data = append(append(data, line...), '\n')
+	err = client.Workspace.Upload(ctx, path, bytes.NewReader(data), workspace.UploadFormat(workspace.ImportFormatAuto),
+		workspace.UploadOverwrite())
+	if err != nil {
+		return fmt.Errorf("unable to upload %s: %w", path, err)
+	}
+	return nil
+}
@@ -2,17 +2,25 @@ package dbx
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/databricks/databricks-sdk-go"
 	"github.com/databricks/databricks-sdk-go/service/compute"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
 )
 
 // Auth returns a new WorkspaceClient using the provided host and token.
 // Check that the client is authenticated by listing clusters in the workspace.
 func Auth(dbxHost string, dbxToken string) (*databricks.WorkspaceClient, error) {
 	config := &databricks.Config{
-		Host:  dbxHost,
-		Token: dbxToken,
+		Host:          dbxHost,
+		Token:         dbxToken,
+		HTTPTransport: utils.CorrelationTransport(nil),
 	}
 	dbxClient, err := databricks.NewWorkspaceClient(config)
 	if err != nil {
@@ -30,9 +38,54 @@ func Auth(dbxHost string, dbxToken string) (*databricks.WorkspaceClient, error)
 
 // DefaultAuth returns a new WorkspaceClient using the default host and token read from ~/.databrickscfg.
 func DefaultAuth() (*databricks.WorkspaceClient, error) {
-	dbxClient, err := databricks.NewWorkspaceClient()
+	dbxClient, err := databricks.NewWorkspaceClient(&databricks.Config{HTTPTransport: utils.CorrelationTransport(nil)})
 	if err != nil {
 		return nil, err
 	}
 	return dbxClient, nil
 }
+
+// Timeout for checking that a workspace URL is reachable
+const pingTimeout = 15 * time.Second
+
+// NormalizeHost returns the canonical form of a Databricks workspace URL: https://<hostname>, without a trailing
+// slash or path. A URL given without a scheme is assumed to be https. Returns an error if it isn't a valid URL.
+func NormalizeHost(host string) (string, error) {
+	host = strings.TrimSpace(host)
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	parsed, err := url.Parse(host)
+	if err != nil || parsed.Hostname() == "" {
+		return "", fmt.Errorf("invalid Databricks workspace URL %q", host)
+	}
+	if parsed.Scheme != "https" {
+		return "", fmt.Errorf("the Databricks workspace URL %q must start with https://", host)
+	}
+	return "https://" + parsed.Host, nil
+}
+
+// PingWorkspace checks that the host is a reachable Databricks workspace, without authenticating.
+// It fetches the workspace's OAuth metadata, which every workspace serves whatever its hostname,
+// so that vanity and private DNS hostnames are accepted.
+func PingWorkspace(ctx context.Context, host string) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, host+"/oidc/.well-known/oauth-authorization-server", nil)
+	if err != nil {
+		return err
+	}
+	response, err := (&http.Client{Transport: utils.CorrelationTransport(nil)}).Do(request)
+	if err != nil {
+		return fmt.Errorf("unable to reach %s: %w", host, err)
+	}
+	defer response.Body.Close()
+	var metadata struct {
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+	}
+	if response.StatusCode != http.StatusOK || json.NewDecoder(response.Body).Decode(&metadata) != nil ||
+		metadata.AuthorizationEndpoint == "" {
+		return fmt.Errorf("%s doesn't look like a Databricks workspace (HTTP %d from its OAuth metadata endpoint)", host, response.StatusCode)
+	}
+	return nil
+}
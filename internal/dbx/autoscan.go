@@ -5,16 +5,20 @@ import (
 	"embed"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/logger"
 	"github.com/databricks/databricks-sdk-go/service/jobs"
 	"github.com/databricks/databricks-sdk-go/service/workspace"
-	"github.com/google/uuid"
 	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlmodelmap"
 )
 
 // Constants
@@ -26,37 +30,154 @@ const modelMonitorNotebookName = "hl_monitor_models"
 var sourceFiles embed.FS
 
 func init() {
-	// Set log output to stdout and include the date, time, and file name in log messages
-	log.SetOutput(os.Stdout)
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
+	// Log the Databricks SDK's messages at the level of --verbose and --debug
+	logger.DefaultLogger = utils.DatabricksLogger{}
 }
 
 // Autoscan sets up automatic model scanning in Databricks, using the HiddenLayer Model Scanner.
-func Autoscan(ctx context.Context, config *utils.Config) {
+// If validate is true, the installation is checked from the jobs' compute before the jobs are created.
+// The progress of each phase is reported to progress, if it isn't nil. The installation manifest records command as
+// the command that installed it.
+func Autoscan(ctx context.Context, config *utils.Config, command string, validate bool, progress ProgressFunc) {
 	// Sanity-check the configuration
 	if config.DbxHost == "" || config.DbxToken == "" {
-		log.Fatalf("Databricks host and token must be provided")
+		utils.Fatalf("Databricks host and token must be provided")
 	}
+	install := startPhase(progress, phaseInstall)
 
 	// Authenticate to Databricks
+	phase := startPhase(progress, phaseAuth)
 	dbx_client, err := Auth(config.DbxHost, config.DbxToken)
 	if err != nil {
-		log.Fatalf("Unable to authenticate to Databricks, got this error: %s", err.Error())
+		utils.Fatalf("Unable to authenticate to Databricks, got this error: %s", err.Error())
 	}
 
-	if !config.UsesEnterpriseModelScanner() {
-		// Store the HiddenLayer credentials in the Databricks secret store for use by the Python notebooks
-		// Only needed when using Saas
-		storeHLCreds(ctx, dbx_client, config)
+	if config.DbxScanComments {
+		// Scan summaries are written into model version comments by the job's identity, check that it can
+		warnings, err := CheckCommentPermissions(ctx, dbx_client, config)
+		if err != nil {
+			utils.Fatalf("Unable to check permissions for writing scan comments: %v", err)
+		}
+		for _, warning := range warnings {
+			slog.Warn(warning)
+		}
+	}
+	phase.finish(config.DbxHost)
+
+	// Steps that fail for lack of permission are skipped rather than fatal, so that an admin can complete them.
+	// Every step is safe to repeat, so re-running autoscan completes the remaining steps.
+	var skipped []skippedStep
+	// skip records a skipped step, and reports its phase as skipped
+	skip := func(phase *phaseProgress, step skippedStep) {
+		skipped = append(skipped, step)
+		phase.skip(utils.RedactConfigSecrets(config, fmt.Sprintf("%s: %v", step.step, step.err)))
+	}
+	if config.UsesClientCredentials() || config.FindingsSinkNeedsKey() || config.UsesDatabricksTokens() {
+		phase = startPhase(progress, phaseSecrets)
+		var scopes []string
+		secretsSkipped := len(skipped)
+		if config.UsesClientCredentials() {
+			// Store the HiddenLayer credentials in the Databricks secret store for use by the Python notebooks
+			// Only needed when using Saas, or another scanner that authenticates with client credentials
+			stored, err := storeHLCreds(ctx, dbx_client, config)
+			if err != nil {
+				skip(phase, skipStep("Store the HiddenLayer credentials in Databricks secrets", err, manualSecretsCommands(config)))
+			}
+			scopes = append(scopes, stored...)
+		}
+
+		if config.FindingsSinkNeedsKey() {
+			// Store the Event Hub key or webhook signing secret in the Databricks secret store for use by the scan notebook
+			stored, err := storeFindingsSinkKey(ctx, dbx_client, config)
+			if err != nil {
+				skip(phase, skipStep("Store the findings sink key in Databricks secrets", err, manualFindingsSinkKeyCommands(config)))
+			}
+			scopes = append(scopes, stored...)
+		}
+
+		if config.UsesDatabricksTokens() {
+			// Scan jobs mint short-lived tokens of the run-as service principal instead of reading stored credentials
+			if err := allowTokenMinting(ctx, dbx_client, config); err != nil {
+				skip(phase, skipStep("Let the run-as service principal mint Databricks tokens", err, manualTokenMintingCommands(config)))
+			}
+		}
+		if len(skipped) == secretsSkipped {
+			slices.Sort(scopes)
+			phase.finish(slices.Compact(scopes)...)
+		}
 	}
 
 	// Upload auto-scan Python files to the Databricks workspace
-	uploadPythonFiles(dbx_client)
+	phase = startPhase(progress, phaseUpload)
+	if err := uploadPythonFiles(dbx_client, config); err != nil {
+		skip(phase, skipStep("Upload the notebooks to the Databricks workspace", err, manualUploadCommands(config)))
+	} else {
+		phase.finish(getHLWorkspaceDirectory(config))
+	}
+
+	// Check the secrets, HiddenLayer endpoints, and schemas from the cluster, which the checks above can't see,
+	// so the monitoring job isn't created only to fail on its first run. Skipped steps would fail the checks.
+	if validate && len(skipped) == 0 {
+		phase = startPhase(progress, phaseValidate)
+		if err := validateInstall(ctx, dbx_client, config); err != nil {
+			utils.Fatalf("Installation validation failed, so the monitoring job wasn't created: %v. "+
+				"Fix the problems and re-run autoscan, or re-run it with --skip-validation", err)
+		}
+		phase.finish()
+	}
 
 	// Run the monitor notebook periodically to detect and scan new model versions
-	scheduleMonitorJob(ctx, dbx_client, config)
+	phase = startPhase(progress, phaseJob)
+	if jobId, err := scheduleMonitorJob(ctx, dbx_client, config); err != nil {
+		skip(phase, skipStep("Schedule the model monitoring job", err, manualJobCommands(monitorJobSettings(config))))
+	} else {
+		phase.finish(strconv.FormatInt(jobId, 10))
+	}
+
+	if config.DbxServingGuardrail {
+		// Provide a pre-deployment check for Model Serving endpoints
+		phase = startPhase(progress, phaseGuardrail)
+		if jobId, err := setUpServingGuardrail(ctx, dbx_client, config); err != nil {
+			skip(phase, skipStep("Create the serving guardrail job", err, manualJobCommands(guardrailJobSettings(config))))
+		} else {
+			phase.finish(strconv.FormatInt(jobId, 10))
+		}
+	}
+
+	if config.DbxVerifyJob {
+		// Verify the installation on a schedule, and notify the configured channels if it degrades
+		phase = startPhase(progress, phaseVerify)
+		if jobId, err := setUpVerifyJob(ctx, dbx_client, config); err != nil {
+			skip(phase, skipStep("Create the verification job", err, manualJobCommands(verifyJobSettings(config))))
+		} else {
+			phase.finish(strconv.FormatInt(jobId, 10))
+		}
+	}
 
+	if config.DbxOnDemandJob {
+		// Let workspace users have a model version scanned now, without access to the HiddenLayer console
+		phase = startPhase(progress, phaseOnDemand)
+		if jobId, err := setUpOnDemandJob(ctx, dbx_client, config); err != nil {
+			skip(phase, skipStep("Create the on-demand scan job", err, manualJobCommands(onDemandJobSettings(config))))
+		} else {
+			phase.finish(strconv.FormatInt(jobId, 10))
+		}
+	}
+
+	// Record what the installation covers, for other tooling and auditors to discover
+	if path, err := WriteManifest(ctx, dbx_client, config, command); err != nil {
+		slog.Warn("Unable to write the installation manifest", "error", err)
+	} else {
+		fmt.Printf("Wrote the installation manifest to %s\n", path)
+	}
+
+	if len(skipped) > 0 {
+		reportSkippedSteps(skipped)
+		install.skip(fmt.Sprintf("%d step(s) skipped for lack of permission", len(skipped)))
+		return
+	}
 	fmt.Println("Finished setting up automated HiddenLayer model scanning")
+	install.finish()
 }
 
 // secretsScopeName returns the name of the Databricks secrets scope for HiddenLayer credentials.
@@ -67,99 +188,203 @@ func secretsScopeName(catalog string, schema string) string {
 }
 
 // StoreHLCreds stores the HiddenLayer API key name, client ID, and client secret in the Databricks secret store.
-// Use a secrets scope named "hl_<catalog_name>_<schema_name>" for uniqueness across Unity Catalog schemas.
-func storeHLCreds(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) {
+// Use a secrets scope named "hl_<catalog_name>_<schema_name>" for uniqueness across Unity Catalog schemas, or the
+// consolidated scope if the workspace has no secret scopes left.
+// Each schema's scope holds the credentials of the scanner that the schema selects.
+// Returns the scopes that hold the credentials, or an error if a Databricks call fails.
+func storeHLCreds(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) ([]string, error) {
 	// Sanity-check the configuration
 	if len(config.DbxSchemas) == 0 {
-		log.Fatalf("Databricks catalogs and schemas must be provided")
-	}
-	// if using the Saas model scanner, ensure HL credentials are provided
-	if !config.UsesEnterpriseModelScanner() && (config.HlClientID == "" || config.HlClientSecret == "") {
-		log.Fatalf("HiddenLayer client ID and secret must be provided")
+		utils.Fatalf("Databricks catalogs and schemas must be provided")
 	}
 
+	var scopes []string
 	for _, schemaToMonitor := range config.DbxSchemas {
-		// this is a redundant check, calling code should have confirmed this already. Never hurts to be sure
-		if !config.UsesEnterpriseModelScanner() {
-			// Create the scope if it doesn't already exist
-			scopeName := secretsScopeName(schemaToMonitor.Catalog, schemaToMonitor.Schema)
-			err := client.Secrets.CreateScope(ctx, workspace.CreateScope{Scope: scopeName})
-			if err != nil {
-				if !strings.Contains(err.Error(), "already exists") {
-					log.Fatalf("Error creating secret scope %s: %s", scopeName, err.Error())
-				}
+		scanner, ok := config.Scanner(schemaToMonitor)
+		if !ok {
+			utils.Fatalf("Schema %s.%s selects scanner %q, which isn't in hl_scanners", schemaToMonitor.Catalog, schemaToMonitor.Schema, schemaToMonitor.Scanner)
+		}
+		// if using the Saas model scanner, ensure HL credentials are provided
+		if scanner.UsesClientCredentials() && (scanner.ClientID == "" || scanner.ClientSecret == "") {
+			utils.Fatalf("HiddenLayer client ID and secret must be provided for scanner %s", scanner.Name)
+		}
+		if scanner.UsesClientCredentials() {
+			scopeName, err := storeSchemaHLCreds(ctx, client, config, schemaToMonitor, scanner)
+			if scopeName != "" {
+				scopes = append(scopes, scopeName)
 			}
-			// Create the secret. The key is the HL API key name, and the value is "<client ID>:<client secret>".
-			// This convention must match between the Go and Python code.
-			err = client.Secrets.PutSecret(ctx, workspace.PutSecret{
-				Scope:       scopeName,
-				Key:         config.HlApiKeyName,
-				StringValue: fmt.Sprintf("%s:%s", config.HlClientID, config.HlClientSecret),
-			})
 			if err != nil {
-				if !strings.Contains(err.Error(), "already exists") {
-					log.Fatalf("Error creating secret %s in scope %s: %s", config.HlApiKeyName, scopeName, err.Error())
-				}
+				return scopes, err
 			}
+		}
+	}
+	return scopes, nil
+}
 
-			// Double-check that the secret was created successfully
-			secret, err := client.Secrets.GetSecret(ctx, workspace.GetSecretRequest{Key: config.HlApiKeyName, Scope: scopeName})
-			if err != nil {
-				log.Fatalf("Error fetching secret %s from scope %s: %s", config.HlApiKeyName, scopeName, err.Error())
-			}
-			decodedBytes, err := base64.StdEncoding.DecodeString(secret.Value)
-			if err != nil {
-				log.Fatalf("failed to decode secret: %s", err.Error())
-			}
-			decodedSecret := string(decodedBytes)
-			if decodedSecret != fmt.Sprintf("%s:%s", config.HlClientID, config.HlClientSecret) {
-				// For security, don't echo the secret in the error message
-				log.Fatalf("Secret %s in scope %s has the wrong value", config.HlApiKeyName, scopeName)
-			}
+// storeSchemaHLCreds stores the client credentials of the scanner that a schema selects in the schema's secrets scope,
+// creating the scope if needed. Returns the scope, or an error if a Databricks call fails.
+func storeSchemaHLCreds(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config,
+	schema utils.CatalogSchemaConfig, scanner utils.ScannerConfig) (string, error) {
+	credentials := fmt.Sprintf("%s:%s", scanner.ClientID, scanner.ClientSecret)
+	// Create the scope if it doesn't already exist
+	location, err := createSchemaSecretsScope(ctx, client, schema)
+	if err != nil {
+		return "", err
+	}
+	scopeName, keyName := location.Scope, location.key(config.HlApiKeyName)
+	if err := grantSecretsScope(ctx, client, config, scopeName); err != nil {
+		return scopeName, err
+	}
+	// If the secret already holds these credentials, leave it alone so that its last-updated time
+	// keeps recording when the credentials were last rotated
+	if hlCredsStored(ctx, client, scopeName, keyName, credentials) {
+		fmt.Printf("HiddenLayer credentials in scope %s are unchanged\n", scopeName)
+		return scopeName, nil
+	}
+	// Create the secret. The key is the HL API key name, and the value is "<client ID>:<client secret>".
+	// This convention must match between the Go and Python code.
+	err = client.Secrets.PutSecret(ctx, workspace.PutSecret{
+		Scope:       scopeName,
+		Key:         keyName,
+		StringValue: credentials,
+	})
+	if err != nil {
+		if !strings.Contains(err.Error(), "already exists") {
+			return scopeName, fmt.Errorf("error creating secret %s in scope %s: %w", keyName, scopeName, err)
 		}
 	}
+
+	// Double-check that the secret was created successfully
+	secret, err := client.Secrets.GetSecret(ctx, workspace.GetSecretRequest{Key: keyName, Scope: scopeName})
+	if err != nil {
+		return scopeName, fmt.Errorf("error fetching secret %s from scope %s: %w", keyName, scopeName, err)
+	}
+	decodedBytes, err := base64.StdEncoding.DecodeString(secret.Value)
+	if err != nil {
+		return scopeName, fmt.Errorf("failed to decode secret %s from scope %s: %w", keyName, scopeName, err)
+	}
+	if string(decodedBytes) != credentials {
+		// For security, don't echo the secret in the error message
+		return scopeName, fmt.Errorf("secret %s in scope %s has the wrong value", keyName, scopeName)
+	}
+	return scopeName, nil
 }
 
-// getHLWorkspaceDirectory returns the path to the HiddenLayer workspace directory in the Databricks workspace.
-func getHLWorkspaceDirectory() string {
-	return fmt.Sprintf("/Shared/HiddenLayer/%s", utils.Version)
+// hlCredsStored returns true if the secrets scope already holds the given "<client ID>:<client secret>" credentials.
+func hlCredsStored(ctx context.Context, client *databricks.WorkspaceClient, scopeName string, keyName string, credentials string) bool {
+	secret, err := client.Secrets.GetSecret(ctx, workspace.GetSecretRequest{Key: keyName, Scope: scopeName})
+	if err != nil {
+		return false // most likely the secret doesn't exist yet
+	}
+	decodedBytes, err := base64.StdEncoding.DecodeString(secret.Value)
+	if err != nil {
+		return false
+	}
+	return string(decodedBytes) == credentials
 }
 
-// Upload auto-scan Python files to the Databricks workspace
-func uploadPythonFiles(client *databricks.WorkspaceClient) {
+// getHLWorkspaceDirectory returns the path to the HiddenLayer workspace directory in the Databricks workspace,
+// a directory per version under dbx_workspace_dir.
+func getHLWorkspaceDirectory(config *utils.Config) string {
+	dir := config.DbxWorkspaceDir
+	if dir == "" {
+		dir = defaultWorkspaceDir
+	}
+	return fmt.Sprintf("%s/%s", dir, utils.Version)
+}
+
+// Outcomes of uploading a Python file to the Databricks workspace
+const (
+	uploadCreated   = "created"
+	uploadUpdated   = "updated"
+	uploadUnchanged = "unchanged"
+)
+
+// Upload auto-scan Python files to the Databricks workspace.
+// Snapshot the workspace directory first, then import the files in parallel and summarize what changed.
+// Return an error if a Databricks call fails.
+func uploadPythonFiles(client *databricks.WorkspaceClient, config *utils.Config) error {
+	ctx := context.Background()
 	entries, err := sourceFiles.ReadDir("notebooks")
 	if err != nil {
-		log.Fatal(err)
+		utils.Fatal(err)
 	}
-	workspaceDir := getHLWorkspaceDirectory()
+	workspaceDir := getHLWorkspaceDirectory(config)
 
-	// Create the workspace directory if it doesn't exist
-	err = client.Workspace.Mkdirs(context.Background(), workspace.Mkdirs{
-		Path: workspaceDir,
-	})
-	if err != nil {
-		log.Fatalf("Error creating workspace directory %s: %v", workspaceDir, err)
+	// Snapshot the workspace directory. Only create it if it doesn't exist yet.
+	existing := map[string]bool{}
+	objects, err := client.Workspace.ListAll(ctx, workspace.ListWorkspaceRequest{Path: workspaceDir})
+	if errors.Is(err, databricks.ErrResourceDoesNotExist) || errors.Is(err, databricks.ErrNotFound) {
+		err = client.Workspace.Mkdirs(ctx, workspace.Mkdirs{Path: workspaceDir})
+		if err != nil {
+			return fmt.Errorf("error creating workspace directory %s: %w", workspaceDir, err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("error listing workspace directory %s: %w", workspaceDir, err)
+	}
+	for _, object := range objects {
+		existing[object.Path] = true
 	}
 
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	summary := map[string][]string{}
+	var uploadErr error
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
 		}
-		fmt.Printf("Uploading %s\n", entry.Name())
-		source := fmt.Sprintf("notebooks/%s", entry.Name())
-		// Upload the Python file.
-		// When computing the destination path, do it Unix-style because this is a Databricks path, not a local path.
-		uploadPythonFile(client, source, fmt.Sprintf("%s/%s", workspaceDir, entry.Name()))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			source := fmt.Sprintf("notebooks/%s", entry.Name())
+			// When computing the destination path, do it Unix-style because this is a Databricks path, not a local path.
+			dest := fmt.Sprintf("%s/%s", workspaceDir, entry.Name())
+			// Notebooks are imported without the .py extension, other files keep it
+			exists := existing[dest] || existing[strings.TrimSuffix(dest, ".py")]
+			outcome, err := uploadPythonFile(ctx, client, source, dest, exists)
+			mu.Lock()
+			if err != nil {
+				uploadErr = errors.Join(uploadErr, err)
+			} else {
+				summary[outcome] = append(summary[outcome], entry.Name())
+			}
+			mu.Unlock()
+		}()
 	}
+	wg.Wait()
+	if uploadErr != nil {
+		return uploadErr
+	}
+
+	fmt.Printf("Uploaded files to %s: ", workspaceDir)
+	for i, outcome := range []string{uploadCreated, uploadUpdated, uploadUnchanged} {
+		if i > 0 {
+			fmt.Print(", ")
+		}
+		slices.Sort(summary[outcome])
+		fmt.Printf("%d %s %v", len(summary[outcome]), outcome, summary[outcome])
+	}
+	fmt.Println()
+	return nil
 }
 
-// uploadPythonFile uploads a Python file to the Databricks workspace
+// uploadPythonFile uploads a Python file to the Databricks workspace, and returns whether it was created, updated,
+// or left unchanged because the workspace already has the same content. Return an error if the import fails.
 // Import files as notebooks, except for the common code, which is imported automatically as a script.
-func uploadPythonFile(client *databricks.WorkspaceClient, source string, dest string) {
+func uploadPythonFile(ctx context.Context, client *databricks.WorkspaceClient, source string, dest string, exists bool) (string, error) {
 	// Read the Python file from the embedded filesystem
 	content, err := sourceFiles.ReadFile(source)
 	if err != nil {
-		log.Fatalf("Error reading Python file: %v", err.Error())
+		utils.Fatalf("Error reading Python file: %v", err.Error())
+	}
+
+	outcome := uploadCreated
+	if exists {
+		if workspaceFileMatches(ctx, client, dest, content) {
+			return uploadUnchanged, nil
+		}
+		outcome = uploadUpdated
 	}
 
 	// Import the file into the workspace.
@@ -167,42 +392,63 @@ func uploadPythonFile(client *databricks.WorkspaceClient, source string, dest st
 	// ImportFormatSource causes all the files to be imported as notebooks.
 	encodedContent := base64.StdEncoding.EncodeToString(content)
 	importRequest := workspace.Import{
-		Content:  encodedContent,
-		Format:   workspace.ImportFormatAuto,
-		Language: workspace.LanguagePython,
-		Path:     dest,
+		Content:   encodedContent,
+		Format:    workspace.ImportFormatAuto,
+		Language:  workspace.LanguagePython,
+		Path:      dest,
+		Overwrite: exists,
+	}
+	err = client.Workspace.Import(ctx, importRequest)
+	if err != nil && !exists && strings.Contains(err.Error(), "already exists") {
+		// Created since the directory snapshot was taken: it's only unchanged if it has the same content
+		if workspaceFileMatches(ctx, client, dest, content) {
+			return uploadUnchanged, nil
+		}
+		importRequest.Overwrite = true
+		outcome = uploadUpdated
+		err = client.Workspace.Import(ctx, importRequest)
 	}
-	err = client.Workspace.Import(context.Background(), importRequest)
 	if err != nil {
-		if strings.Contains(err.Error(), "already exists") {
-			// If the file already exists, we can ignore the error
-			fmt.Printf("File %s already exists in workspace, skipping upload\n", dest)
-			return
+		return "", fmt.Errorf("error importing Python file %s to workspace file %s: %w", source, dest, err)
+	}
+	return outcome, nil
+}
+
+// workspaceFileMatches returns true if the workspace file or notebook at the path has the given content.
+// Any difference, or failure to export the existing file, counts as a mismatch.
+func workspaceFileMatches(ctx context.Context, client *databricks.WorkspaceClient, path string, content []byte) bool {
+	for _, candidate := range []string{path, strings.TrimSuffix(path, ".py")} {
+		exported, err := client.Workspace.Export(ctx, workspace.ExportRequest{Path: candidate, Format: workspace.ExportFormatSource})
+		if err != nil {
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(exported.Content)
+		if err != nil {
+			return false
 		}
-		log.Fatalf("Error importing Python file %s to workspace file %s: %v", source, dest, err)
+		return strings.TrimSpace(string(decoded)) == strings.TrimSpace(string(content))
 	}
+	return false
 }
 
-// Schedule the monitor job to run periodically. The monitor job finds new model versions and scans them.
-func scheduleMonitorJob(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) {
+// monitorJobSettings returns the settings of the job that runs the monitor notebook periodically.
+func monitorJobSettings(config *utils.Config) jobs.CreateJob {
 	// Get location of the monitor notebook
-	workspaceDir := getHLWorkspaceDirectory()
+	workspaceDir := getHLWorkspaceDirectory(config)
 	// This is a Unix-style path because it's a Databricks path, not a local path, so don't use filepath.Join
 	notebookPath := fmt.Sprintf("%s/%s", workspaceDir, modelMonitorNotebookName)
 
 	// Create a schedule for running the notebook.
-	// If you change the schedule, update the job_name accordingly.
 	schedule := jobs.CronSchedule{
 		QuartzCronExpression: config.DbxPollingQuartzCron,
 		//QuartzCronExpression: "0 * * * * ?", // Run every minute (useful for testing)
 		TimezoneId: "UTC",
 	}
-	const job_name = "hl_find_new_model_versions"
 
 	// Build the parameter list for the notebook job
 	catalogAndSchemasParam, err := json.Marshal(config.DbxSchemas)
 	if err != nil {
-		log.Fatalf("Error marshalling catalog and schemas: %v", err)
+		utils.Fatalf("Error marshalling catalog and schemas: %v", err)
 	}
 	params := []jobs.JobParameterDefinition{
 		{Name: "schemas", Default: string(catalogAndSchemasParam)},
@@ -210,34 +456,154 @@ func scheduleMonitorJob(ctx context.Context, client *databricks.WorkspaceClient,
 		{Name: "hl_api_url", Default: config.HlApiUrl},
 		{Name: "hl_auth_url", Default: config.HlAuthUrl},
 		{Name: "hl_console_url", Default: config.HlConsoleUrl},
+		// Egress settings, for reaching the HL API through a proxy from restricted clusters
+		{Name: "hl_https_proxy", Default: config.HlHttpsProxy},
+		{Name: "hl_no_proxy", Default: config.HlNoProxy},
+		{Name: "hl_ca_bundle_path", Default: config.HlCaBundlePath},
+		// TLS settings, for the scan jobs to hold the HL endpoints to the same standard as hldbx
+		{Name: "hl_tls_min_version", Default: config.HlTlsMinVersion},
+		{Name: "hl_tls_pins", Default: strings.Join(config.HlTlsPins, ",")},
+		// Identifies the deployment in the user agent of the scan jobs' requests to the HL API
+		{Name: "user_agent_suffix", Default: config.UserAgentSuffix},
+		{Name: "serving_guardrail", Default: strconv.FormatBool(config.DbxServingGuardrail)},
+		{Name: "scan_comments", Default: strconv.FormatBool(config.DbxScanComments)},
+		{Name: "findings_sink", Default: config.DbxFindingsSink},
+		{Name: "scan_trigger", Default: string(config.DbxScanTrigger)},
+		{Name: "scan_aliases", Default: strings.Join(config.DbxScanAliases, ",")},
+		{Name: "discovery_source", Default: string(config.DbxDiscoverySource)},
+		// Versions registered from outside this workspace are scanned from their Unity Catalog storage, unless skipped
+		{Name: "skip_external_versions", Default: strconv.FormatBool(config.DbxSkipExternal)},
+		// Shared with the installs of other workspaces on the same metastore, so only one scans each version
+		{Name: "coordination_table", Default: config.DbxCoordinationTable},
+		// Maps model versions to their HL model and scan IDs, see hldbx map lookup
+		{Name: "model_map_table", Default: hlmodelmap.Table(config)},
+		// Alternative scanners that schemas select, see hl_scanners
+		{Name: "scanners", Default: scannersParam(config)},
+		{Name: "max_active_scan_jobs", Default: strconv.Itoa(config.MaxActiveScanJobs())},
+		{Name: "outage_policy", Default: string(config.HlOutagePolicy)},
+		{Name: "circuit_breaker_threshold", Default: strconv.Itoa(config.CircuitBreakerThreshold())},
+		{Name: "scan_origin", Default: config.HlScanOrigin},
+		{Name: "scan_metadata", Default: scanMetadataParam(config)},
+		// Compute settings, for the scan jobs to run on the same compute as the monitoring job
+		{Name: "serverless", Default: strconv.FormatBool(config.DbxServerless)},
+		{Name: "budget_policy_id", Default: taskBudgetPolicyId(config)},
+		{Name: "job_cluster", Default: jobClusterParam(config)},
 	}
 
 	// Create and schedule the notebook job
 	notebookTask := jobs.NotebookTask{
 		NotebookPath: notebookPath,
 		BaseParameters: map[string]string{
-			"MAX_ACTIVE_SCAN_JOBS": config.DbxMaxActiveScanJobs},
+			"monitor_run_id": "{{job.run_id}}"},
+	}
+	// The heartbeat task runs after the monitoring task, even if it fails, to record that the job ran
+	heartbeatTask := jobs.NotebookTask{
+		NotebookPath: fmt.Sprintf("%s/%s", workspaceDir, heartbeatNotebookName),
+		BaseParameters: map[string]string{
+			"state_table": config.StateTable(),
+			"job_run_id":  "{{job.run_id}}",
+		},
 	}
-	createJob := jobs.CreateJob{Name: job_name,
+	createJob := jobs.CreateJob{Name: jobName(config, monitorJobKey),
+		Description: jobDescription(config),
+		Tags:        jobTags(monitorJobKey),
 		Tasks: []jobs.Task{{
 			Description:       "Poll for new model versions and scan them using HiddenLayer",
-			ExistingClusterId: config.DbxClusterId,
-			TaskKey:           uuid.New().String(),
+			ExistingClusterId: taskClusterId(config),
+			JobClusterKey:     taskJobClusterKey(config),
+			TaskKey:           monitorTaskKey,
 			TimeoutSeconds:    0,
 			NotebookTask:      &notebookTask,
+		}, {
+			Description:       "Record a heartbeat for this run of the HiddenLayer monitoring job",
+			ExistingClusterId: taskClusterId(config),
+			JobClusterKey:     taskJobClusterKey(config),
+			TaskKey:           heartbeatTaskKey,
+			DependsOn:         []jobs.TaskDependency{{TaskKey: monitorTaskKey}},
+			RunIf:             jobs.RunIfAllDone,
+			NotebookTask:      &heartbeatTask,
 		}},
-		Parameters: params,
-		Schedule:   &schedule,
+		JobClusters:    jobClusters(config),
+		Parameters:     params,
+		Schedule:       &schedule,
+		BudgetPolicyId: taskBudgetPolicyId(config),
+	}
+	if len(config.DbxArtifactSources) > 0 {
+		// The artifact scanning task runs alongside the monitoring task, independently of it
+		createJob.Tasks = append(createJob.Tasks, artifactsTask(config))
+	}
+	if config.DbxRetentionDays > 0 {
+		createJob.Tasks = append(createJob.Tasks, archiveTask(config))
 	}
 	if config.DbxRunAs != "" {
 		createJob.RunAs = &jobs.JobRunAs{ServicePrincipalName: config.DbxRunAs}
-	} else {
+	}
+	return createJob
+}
+
+// Schedule the monitor job to run periodically. The monitor job finds new model versions and scans them.
+// Return the job ID, or an error if a Databricks call fails.
+func scheduleMonitorJob(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) (int64, error) {
+	if config.DbxRunAs == "" {
 		fmt.Println("No run_as user provided, setting runner to the user who created the job")
 	}
+	jobId, created, err := createOrResetJob(ctx, client, monitorJobSettings(config))
+	if err != nil {
+		return 0, fmt.Errorf("error scheduling model monitoring job: %w", err)
+	}
+	if created {
+		fmt.Printf("Scheduled monitoring job with ID: %d\n", jobId)
+	} else {
+		fmt.Printf("Updated existing monitoring job with ID: %d\n", jobId)
+	}
+	return jobId, nil
+}
+
+// createOrResetJob creates a job, or if a job with the same key already exists, replaces its settings, renaming it
+// if its name changed. This makes it safe to re-run autoscan. If Databricks doesn't apply the job's budget policy, the
+// created job is deleted, or the existing job's settings are restored, see checkBudgetPolicy.
+// Returns the job ID, and whether the job was newly created.
+func createOrResetJob(ctx context.Context, client *databricks.WorkspaceClient, createJob jobs.CreateJob) (int64, bool, error) {
+	existing, err := findJobs(ctx, client, createJob.Tags[hlJobTag])
+	if err != nil {
+		return 0, false, err
+	}
+	if len(existing) == 0 {
+		job, err := client.Jobs.Create(ctx, createJob)
+		if err != nil {
+			return 0, false, err
+		}
+		err = checkBudgetPolicy(ctx, client, createJob.BudgetPolicyId, job.JobId, func() error {
+			return client.Jobs.DeleteByJobId(ctx, job.JobId)
+		})
+		if err != nil {
+			return 0, false, err
+		}
+		return job.JobId, true, nil
+	}
 
-	job, err := client.Jobs.Create(ctx, createJob)
+	// The create request and the job settings share their JSON representation
+	settingsJson, err := json.Marshal(createJob)
+	if err != nil {
+		return 0, false, err
+	}
+	var settings jobs.JobSettings
+	if err := json.Unmarshal(settingsJson, &settings); err != nil {
+		return 0, false, err
+	}
+	// Keep the pause status of the installed schedule, so that re-running autoscan doesn't undo hldbx pause
+	if schedule := existing[0].Settings.Schedule; schedule != nil && settings.Schedule != nil {
+		settings.Schedule.PauseStatus = schedule.PauseStatus
+	}
+	jobId, previous := existing[0].JobId, existing[0].Settings
+	if err := client.Jobs.Reset(ctx, jobs.ResetJob{JobId: jobId, NewSettings: settings}); err != nil {
+		return 0, false, err
+	}
+	err = checkBudgetPolicy(ctx, client, createJob.BudgetPolicyId, jobId, func() error {
+		return client.Jobs.Reset(ctx, jobs.ResetJob{JobId: jobId, NewSettings: *previous})
+	})
 	if err != nil {
-		log.Fatalf("Error scheduling model monitoring job: %v", err)
+		return 0, false, err
 	}
-	fmt.Printf("Scheduled monitoring job with ID: %d\n", job.JobId)
+	return jobId, false, nil
 }
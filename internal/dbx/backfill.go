@@ -0,0 +1,423 @@
+package dbx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/apierr"
+	"github.com/databricks/databricks-sdk-go/service/catalog"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlmodelmap"
+)
+
+// Name of the notebook that scans a model version
+const scanNotebookName = "hl_scan_model"
+
+// File in the profile's state directory that records the model versions that the backfill has finished
+const backfillCheckpointFileName = "backfill.json"
+
+// Save the checkpoint after this many model versions finish, as well as when the backfill stops
+const backfillCheckpointEvery = 25
+
+// Longest a backfill scan may run. This must match HL_SCAN_NOTEBOOK_TIMEOUT_MINS in hl_monitor_models.py.
+const backfillScanTimeout = 4800 * time.Minute
+
+// Pause of all workers after Databricks rate-limits a request, doubled for each consecutive rate-limited request
+const (
+	backfillMinBackoff = 5 * time.Second
+	backfillMaxBackoff = 5 * time.Minute
+)
+
+// Outcomes of the model versions in a backfill, as recorded in the checkpoint
+const (
+	BackfillScanned = "scanned" // a scan run finished, see the model version's tags for the verdict
+	BackfillSkipped = "skipped" // already scanned, or being scanned
+	BackfillFailed  = "failed"  // the scan run failed, retried by the next backfill
+)
+
+// BackfillCheckpoint records the outcome of each model version that the backfill has finished, keyed by
+// <model>@<version>, so that an interrupted backfill resumes where it stopped.
+type BackfillCheckpoint struct {
+	path string
+	Done map[string]string `json:"done"`
+}
+
+// LoadBackfillCheckpoint reads the selected profile's backfill checkpoint, which is empty if it doesn't exist yet.
+func LoadBackfillCheckpoint() (*BackfillCheckpoint, error) {
+	stateDir, err := utils.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	checkpoint := &BackfillCheckpoint{path: filepath.Join(stateDir, backfillCheckpointFileName), Done: map[string]string{}}
+	data, err := os.ReadFile(checkpoint.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read the backfill checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("unable to parse the backfill checkpoint %s: %w", checkpoint.path, err)
+	}
+	return checkpoint, nil
+}
+
+// Reset forgets the finished model versions, so the backfill starts over.
+func (c *BackfillCheckpoint) Reset() {
+	c.Done = map[string]string{}
+}
+
+// Save writes the backfill checkpoint.
+func (c *BackfillCheckpoint) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path, data, 0600); err != nil {
+		return fmt.Errorf("unable to write the backfill checkpoint: %w", err)
+	}
+	return nil
+}
+
+// isFinished returns true if the model version finished in an earlier backfill, other than by failing.
+func (c *BackfillCheckpoint) isFinished(key string) bool {
+	outcome, ok := c.Done[key]
+	return ok && outcome != BackfillFailed
+}
+
+// BackfillProgress counts the model versions that the backfill has finished, and estimates when it will be done.
+type BackfillProgress struct {
+	Total       int           `json:"total"`                 // model versions to backfill in this run, excluding those already checkpointed
+	Resumed     int           `json:"resumed"`               // model versions skipped because the checkpoint has them
+	Registering int           `json:"registering,omitempty"` // model versions left for the next backfill, not READY yet
+	External    int           `json:"external,omitempty"`    // model versions skipped by dbx_skip_external_versions
+	Scanned     int           `json:"scanned"`
+	Skipped     int           `json:"skipped"`
+	Failed      int           `json:"failed"`
+	Elapsed     time.Duration `json:"elapsed"`
+	Remaining   time.Duration `json:"remaining"`              // estimated, 0 until a model version finishes
+	RateLimited bool          `json:"rate_limited,omitempty"` // workers are pausing because Databricks rate-limited a request
+	Last        string        `json:"last,omitempty"`         // the model version that finished last, as <model>@<version>
+	LastErr     error         `json:"-"`                      // why the last model version failed, if it did
+}
+
+// Finished returns the number of model versions that the backfill has finished in this run.
+func (p BackfillProgress) Finished() int {
+	return p.Scanned + p.Skipped + p.Failed
+}
+
+// backfillVersion is a model version to backfill.
+type backfillVersion struct {
+	Model          string
+	Version        int
+	Status         catalog.ModelVersionInfoStatus
+	RunWorkspaceId int // of the MLflow run that created the version, see isExternalVersion
+}
+
+// key returns the key of the model version in the checkpoint.
+func (v backfillVersion) key() string {
+	return fmt.Sprintf("%s@%d", v.Model, v.Version)
+}
+
+// rateLimiter pauses all workers after Databricks rate-limits a request, backing off while it keeps doing so.
+type rateLimiter struct {
+	mu          sync.Mutex
+	pausedUntil time.Time
+	backoff     time.Duration
+}
+
+// wait blocks until the pause, if any, is over, or the context is canceled.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	delay := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+// observe updates the pause after a request: it grows if the request was rate-limited, and resets if it succeeded.
+// Returns true if the request was rate-limited, and should be retried.
+func (l *rateLimiter) observe(err error) bool {
+	var apiErr *apierr.APIError
+	limited := errors.As(err, &apiErr) && apiErr.IsTooManyRequests()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !limited {
+		if err == nil {
+			l.backoff = 0
+		}
+		return false
+	}
+	l.backoff = min(max(2*l.backoff, backfillMinBackoff), backfillMaxBackoff)
+	l.pausedUntil = time.Now().Add(l.backoff)
+	return true
+}
+
+// isPaused returns true if the workers are pausing because of rate limiting.
+func (l *rateLimiter) isPaused() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Now().Before(l.pausedUntil)
+}
+
+// Backfill scans every version of every model in the monitored schemas that hasn't been scanned, not just the
+// versions that the monitoring job picks, with the given number of scan runs at once. Model versions in the
+// checkpoint are skipped, and the checkpoint is saved as versions finish, so an interrupted backfill resumes where it
+// stopped; cancel the context to interrupt it. onProgress is called each time a model version finishes.
+// Call WaitForCluster first, unless jobs run on serverless compute.
+func Backfill(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, workers int,
+	checkpoint *BackfillCheckpoint, onProgress func(BackfillProgress)) (BackfillProgress, error) {
+	var progress BackfillProgress
+	if workers < 1 {
+		return progress, fmt.Errorf("invalid number of workers %d, expected at least 1", workers)
+	}
+	versions, err := listAllModelVersions(ctx, client, config)
+	if err != nil {
+		return progress, err
+	}
+	var workspaceId int64
+	if config.DbxSkipExternal {
+		if workspaceId, err = client.CurrentWorkspaceID(ctx); err != nil {
+			return progress, fmt.Errorf("unable to get the workspace ID: %w", err)
+		}
+	}
+	todo := selectBackfillVersions(versions, checkpoint, config.DbxSkipExternal, workspaceId, &progress)
+
+	limiter := &rateLimiter{}
+	start := time.Now()
+	queue := make(chan backfillVersion)
+	var mu sync.Mutex
+	var saveErr error
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for version := range queue {
+				outcome, err := backfillModelVersion(ctx, client, config, limiter, version)
+				if ctx.Err() != nil {
+					return // interrupted, leave the model version for the next backfill
+				}
+				mu.Lock()
+				checkpoint.Done[version.key()] = outcome
+				switch outcome {
+				case BackfillScanned:
+					progress.Scanned++
+				case BackfillSkipped:
+					progress.Skipped++
+				default:
+					progress.Failed++
+				}
+				progress.Last = version.key()
+				progress.LastErr = err
+				progress.Elapsed = time.Since(start)
+				progress.Remaining = progress.Elapsed / time.Duration(progress.Finished()) *
+					time.Duration(progress.Total-progress.Finished())
+				progress.RateLimited = limiter.isPaused()
+				if progress.Finished()%backfillCheckpointEvery == 0 {
+					if err := checkpoint.Save(); err != nil && saveErr == nil {
+						saveErr = err
+					}
+				}
+				if onProgress != nil {
+					onProgress(progress)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for _, version := range todo {
+		select {
+		case queue <- version:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if err := checkpoint.Save(); err != nil {
+		return progress, err
+	}
+	return progress, saveErr
+}
+
+// backfillModelVersion scans a model version, unless it is already scanned or being scanned, and waits for the
+// scan run to finish. Returns the outcome, and the error of a failed scan.
+func backfillModelVersion(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config,
+	limiter *rateLimiter, version backfillVersion) (string, error) {
+	var tags map[string]string
+	err := retryRateLimited(ctx, limiter, func() error {
+		var err error
+		tags, err = getModelVersionTags(ctx, client, version.Model, version.Version)
+		return err
+	})
+	if err != nil {
+		return BackfillFailed, err
+	}
+	if status := tags[hlScanStatusTag]; status == scanStatusDone || status == scanStatusPending {
+		return BackfillSkipped, nil
+	}
+
+	var wait *jobs.WaitGetRunJobTerminatedOrSkipped[jobs.SubmitRunResponse]
+	err = retryRateLimited(ctx, limiter, func() error {
+		var err error
+		wait, err = client.Jobs.Submit(ctx, backfillScanRun(config, version))
+		return err
+	})
+	if err != nil {
+		return BackfillFailed, fmt.Errorf("unable to submit the scan run: %w", err)
+	}
+	run, err := wait.GetWithTimeout(backfillScanTimeout)
+	if err != nil {
+		return BackfillFailed, fmt.Errorf("scan run %d: %w", wait.RunId, err)
+	}
+	if run.State != nil && run.State.ResultState != jobs.RunResultStateSuccess {
+		return BackfillFailed, fmt.Errorf("scan run %d is %s", wait.RunId, runState(run.State))
+	}
+	return BackfillScanned, nil
+}
+
+// retryRateLimited calls a Databricks request until it isn't rate-limited, pausing as the rate limiter says.
+func retryRateLimited(ctx context.Context, limiter *rateLimiter, request func() error) error {
+	for {
+		if err := limiter.wait(ctx); err != nil {
+			return err
+		}
+		err := request()
+		if !limiter.observe(err) {
+			return err
+		}
+	}
+}
+
+// backfillScanRun returns the one-time run that scans a model version. Its name and parameters must match those
+// of the scan jobs that scan_model() in hl_monitor_models.py creates.
+func backfillScanRun(config *utils.Config, version backfillVersion) jobs.SubmitRun {
+	scanner := config.ModelScanner(version.Model)
+	parameters := map[string]string{
+		"full_model_name":   version.Model,
+		"model_version_num": strconv.Itoa(version.Version),
+		"hl_api_url":        scanner.ApiUrl,
+		"hl_auth_url":       scanner.AuthUrl,
+		"hl_auth":           string(scanner.Auth),
+		"outage_policy":     string(config.HlOutagePolicy),
+	}
+	optional := map[string]string{
+		"hl_console_url":     scanner.ConsoleUrl,
+		"hl_api_key_name":    config.HlApiKeyName,
+		"hl_https_proxy":     config.HlHttpsProxy,
+		"hl_no_proxy":        config.HlNoProxy,
+		"hl_ca_bundle_path":  config.HlCaBundlePath,
+		"hl_tls_min_version": config.HlTlsMinVersion,
+		"hl_tls_pins":        strings.Join(config.HlTlsPins, ","),
+		"user_agent_suffix":  config.UserAgentSuffix,
+		"findings_sink":      config.DbxFindingsSink,
+		"scan_origin":        config.HlScanOrigin,
+		"scan_metadata":      scanMetadataParam(config),
+		"model_map_table":    hlmodelmap.Table(config),
+	}
+	for name, value := range optional {
+		if value != "" {
+			parameters[name] = value
+		}
+	}
+	if config.DbxScanComments {
+		parameters["scan_comments"] = "true"
+	}
+	if parameters["outage_policy"] == "" {
+		parameters["outage_policy"] = string(hlconfig.OutagePolicyFailOpen)
+	}
+
+	run := jobs.SubmitRun{
+		RunName:        fmt.Sprintf("%s%s.%d", scanJobNamePrefix, version.Model, version.Version),
+		BudgetPolicyId: taskBudgetPolicyId(config),
+		Tasks: []jobs.SubmitTask{{
+			TaskKey:           "scan",
+			ExistingClusterId: taskClusterId(config),
+			NewCluster:        taskNewCluster(config),
+			NotebookTask: &jobs.NotebookTask{
+				NotebookPath:   fmt.Sprintf("%s/%s", getHLWorkspaceDirectory(config), scanNotebookName),
+				BaseParameters: parameters,
+			},
+			TimeoutSeconds: int(backfillScanTimeout.Seconds()),
+		}},
+	}
+	if config.DbxRunAs != "" {
+		run.RunAs = &jobs.JobRunAs{ServicePrincipalName: config.DbxRunAs}
+	}
+	return run
+}
+
+// selectBackfillVersions returns the model versions to backfill in this run, and counts those left out in progress:
+// the versions that the checkpoint has, those not READY yet, and with skipExternal, those registered from outside
+// the workspace.
+func selectBackfillVersions(versions []backfillVersion, checkpoint *BackfillCheckpoint, skipExternal bool,
+	workspaceId int64, progress *BackfillProgress) []backfillVersion {
+	var todo []backfillVersion
+	for _, version := range versions {
+		switch {
+		case checkpoint.isFinished(version.key()):
+			progress.Resumed++
+		case version.Status != "" && version.Status != catalog.ModelVersionInfoStatusReady:
+			// Its files may still be uploading, e.g. by an MLflow client outside Databricks
+			progress.Registering++
+		case skipExternal && isExternalVersion(version.RunWorkspaceId, workspaceId):
+			progress.External++
+		default:
+			todo = append(todo, version)
+		}
+	}
+	progress.Total = len(todo)
+	return todo
+}
+
+// isExternalVersion returns true if a model version was registered from outside the workspace: by an MLflow client
+// outside Databricks, without a run in a workspace, or from another workspace on the metastore.
+// This must match is_external_model_version() in hl_common.py.
+func isExternalVersion(runWorkspaceId int, workspaceId int64) bool {
+	return runWorkspaceId == 0 || int64(runWorkspaceId) != workspaceId
+}
+
+// listAllModelVersions returns every version of every model in the monitored schemas.
+func listAllModelVersions(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) ([]backfillVersion, error) {
+	var versions []backfillVersion
+	for _, schema := range config.DbxSchemas {
+		models, err := client.RegisteredModels.ListAll(ctx, catalog.ListRegisteredModelsRequest{
+			CatalogName: schema.Catalog,
+			SchemaName:  schema.Schema,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list models in %s.%s: %w", schema.Catalog, schema.Schema, err)
+		}
+		for _, model := range models {
+			modelVersions, err := client.ModelVersions.ListAll(ctx, catalog.ListModelVersionsRequest{FullName: model.FullName})
+			if err != nil {
+				return nil, fmt.Errorf("unable to list versions of model %s: %w", model.FullName, err)
+			}
+			for _, version := range modelVersions {
+				versions = append(versions, backfillVersion{Model: model.FullName, Version: version.Version,
+					Status: version.Status, RunWorkspaceId: version.RunWorkspaceId})
+			}
+		}
+	}
+	return versions, nil
+}
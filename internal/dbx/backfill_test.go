@@ -0,0 +1,78 @@
+package dbx
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/databricks/databricks-sdk-go/service/catalog"
+)
+
+func TestIsExternalVersion(t *testing.T) {
+	const workspaceId = 1234567890
+	tests := []struct {
+		name           string
+		runWorkspaceId int
+		want           bool
+	}{
+		{name: "run in this workspace", runWorkspaceId: workspaceId, want: false},
+		{name: "no run in a workspace", runWorkspaceId: 0, want: true},
+		{name: "run in another workspace", runWorkspaceId: 987654321, want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isExternalVersion(test.runWorkspaceId, workspaceId); got != test.want {
+				t.Errorf("isExternalVersion(%d, %d) = %t, want %t", test.runWorkspaceId, workspaceId, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSelectBackfillVersions(t *testing.T) {
+	const workspaceId = 1234567890
+	versions := []backfillVersion{
+		{Model: "main.models.done", Version: 1, Status: catalog.ModelVersionInfoStatusReady, RunWorkspaceId: workspaceId},
+		{Model: "main.models.failed", Version: 1, Status: catalog.ModelVersionInfoStatusReady, RunWorkspaceId: workspaceId},
+		{Model: "main.models.uploading", Version: 2, Status: catalog.ModelVersionInfoStatusPendingRegistration},
+		{Model: "main.models.external", Version: 1, Status: catalog.ModelVersionInfoStatusReady},
+		{Model: "main.models.other_workspace", Version: 3, Status: catalog.ModelVersionInfoStatusReady, RunWorkspaceId: 42},
+		{Model: "main.models.local", Version: 4, Status: catalog.ModelVersionInfoStatusReady, RunWorkspaceId: workspaceId},
+		// Versions listed without a status are taken as READY
+		{Model: "main.models.no_status", Version: 1, RunWorkspaceId: workspaceId},
+	}
+	checkpoint := &BackfillCheckpoint{Done: map[string]string{
+		"main.models.done@1":   BackfillScanned,
+		"main.models.failed@1": BackfillFailed,
+	}}
+	tests := []struct {
+		name            string
+		skipExternal    bool
+		wantTodo        []string
+		wantRegistering int
+		wantExternal    int
+	}{
+		{name: "external versions scanned", wantRegistering: 1,
+			wantTodo: []string{"main.models.failed@1", "main.models.external@1", "main.models.other_workspace@3",
+				"main.models.local@4", "main.models.no_status@1"}},
+		{name: "external versions skipped", skipExternal: true, wantRegistering: 1, wantExternal: 2,
+			wantTodo: []string{"main.models.failed@1", "main.models.local@4", "main.models.no_status@1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var progress BackfillProgress
+			todo := selectBackfillVersions(versions, checkpoint, test.skipExternal, workspaceId, &progress)
+			var keys []string
+			for _, version := range todo {
+				keys = append(keys, version.key())
+			}
+			if !slices.Equal(keys, test.wantTodo) {
+				t.Errorf("selectBackfillVersions() = %v, want %v", keys, test.wantTodo)
+			}
+			if progress.Resumed != 1 || progress.Registering != test.wantRegistering ||
+				progress.External != test.wantExternal || progress.Total != len(test.wantTodo) {
+				t.Errorf("progress = %d resumed, %d registering, %d external, %d total, want 1, %d, %d, %d",
+					progress.Resumed, progress.Registering, progress.External, progress.Total, test.wantRegistering,
+					test.wantExternal, len(test.wantTodo))
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package dbx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+)
+
+// BenchResult is the measurement of one benchmark phase.
+type BenchResult struct {
+	Phase    string        `json:"phase"`
+	Duration time.Duration `json:"duration_ns"`
+	Requests int64         `json:"requests,omitempty"` // Databricks API calls made during the phase
+	Items    int           `json:"items"`              // model versions found, or job parameters generated
+	Bytes    int           `json:"bytes,omitempty"`    // size of the generated job settings
+}
+
+// Bench measures model version discovery, for each scan trigger policy, and the generation of the monitoring
+// job's settings, against the client's workspace. requests returns the number of API calls made so far, so
+// that each phase can report its own; it is meant for a mock server, see internal/mock.
+func Bench(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, requests func() int64) ([]BenchResult, error) {
+	var results []BenchResult
+	for _, trigger := range []hlconfig.ScanTrigger{hlconfig.ScanTriggerNewVersion, hlconfig.ScanTriggerAlias} {
+		triggerConfig := *config
+		triggerConfig.DbxScanTrigger = trigger
+		startRequests := requests()
+		start := time.Now()
+		scanResults, err := ListScanResults(ctx, client, &triggerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("discovery with scan trigger %s failed: %w", trigger, err)
+		}
+		results = append(results, BenchResult{
+			Phase:    "discovery (" + string(trigger) + ")",
+			Duration: time.Since(start),
+			Requests: requests() - startRequests,
+			Items:    len(scanResults),
+		})
+	}
+
+	start := time.Now()
+	settings := monitorJobSettings(config)
+	duration := time.Since(start)
+	body, err := json.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal the monitoring job settings: %w", err)
+	}
+	results = append(results, BenchResult{
+		Phase:    "job settings",
+		Duration: duration,
+		Items:    len(settings.Parameters),
+		Bytes:    len(body),
+	})
+	return results, nil
+}
@@ -0,0 +1,30 @@
+package dbx
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Notebook that runs hldbx from within Databricks. It isn't uploaded with the scanning notebooks, since hldbx
+// doesn't need it once it can reach the workspace.
+//
+//go:embed bootstrap/hldbx_bootstrap.py
+var bootstrapNotebook []byte
+
+// Name of the bootstrap notebook's file
+const bootstrapNotebookName = "hldbx_bootstrap.py"
+
+// WriteBootstrapNotebook writes the notebook that runs hldbx from within Databricks to a directory, for importing
+// into the workspace. Returns the path of the notebook.
+func WriteBootstrapNotebook(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("unable to create directory %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, bootstrapNotebookName)
+	if err := os.WriteFile(path, bootstrapNotebook, 0o644); err != nil {
+		return "", fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	return path, nil
+}
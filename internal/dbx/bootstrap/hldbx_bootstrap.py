@@ -0,0 +1,113 @@
+# Databricks notebook source
+# This HiddenLayer (HL) notebook runs the hldbx installer from within Databricks, e.g. as a one-time job, for operators
+# whose machines can't reach the workspace. hldbx authenticates to Databricks with the token of the notebook's
+# context, as the identity that runs the notebook, so no Databricks credentials are stored. The HL credentials are
+# read from a secret, and merged into the configuration file that hldbx reads.
+# Write it out with hldbx bootstrap, and import it into the workspace.
+# Python version: 3.11+
+
+# Job parameters:
+# * hldbx_binary (string) - path of a Linux build of hldbx, on a Unity Catalog Volume or in the workspace
+# * config_file (string) - path of the hldbx.yaml configuration file, on a Unity Catalog Volume or in the workspace,
+#   without dbx_host or dbx_token
+# * hl_credentials_secret (string) - optional <scope>/<key> of a secret holding the HL credentials as
+#   <client_id>:<client_secret>, the same format as the secrets of the scan jobs
+# * hldbx_home (string) - optional directory for the state of hldbx, e.g. on a Unity Catalog Volume so that it is kept
+#   between runs, defaults to a temporary directory
+# * args (string) - optional hldbx command line, defaults to "autoscan"
+
+# COMMAND ----------
+
+import os
+import shlex
+import shutil
+import stat
+import subprocess
+import tempfile
+
+import yaml
+
+# Environment variables that hldbx reads the Databricks host and token from when it runs in Databricks.
+# These must match the Go code.
+DATABRICKS_HOST_ENV = "DATABRICKS_HOST"
+DATABRICKS_TOKEN_ENV = "DATABRICKS_TOKEN"
+HLDBX_HOME_ENV = "HLDBX_HOME"
+
+# Settings that must not be in the configuration file, since the notebook's context provides them
+CONTEXT_SETTINGS = ["dbx_host", "dbx_token"]
+
+def widget(name: str, default: str = "") -> str:
+    """Return the value of a widget, or the default if it isn't set."""
+    dbutils.widgets.text(name, default)
+    return dbutils.widgets.get(name).strip() or default
+
+def read_hl_credentials(secret: str) -> tuple:
+    """Return the HL client ID and secret from a <scope>/<key> secret holding <client_id>:<client_secret>."""
+    scope, _, key = secret.partition("/")
+    if not scope or not key:
+        raise ValueError(f"Invalid hl_credentials_secret {secret}, expected <scope>/<key>")
+    value = dbutils.secrets.get(scope=scope, key=key)
+    if ":" not in value:
+        raise ValueError(f"Invalid secret {secret}: must be a colon-separated client_id:client_secret string")
+    client_id, client_secret = value.split(":", 1)
+    return client_id, client_secret
+
+def write_config(config_file: str, hldbx_home: str, hl_credentials_secret: str) -> None:
+    """Copy the configuration file into hldbx_home, with the HL credentials from the secret, if any."""
+    with open(config_file) as f:
+        config = yaml.safe_load(f) or {}
+    for key in CONTEXT_SETTINGS:
+        if key in config:
+            raise ValueError(f"Remove {key} from {config_file}: hldbx uses the notebook's Databricks workspace and token")
+    if hl_credentials_secret:
+        config["hl_client_id"], config["hl_client_secret"] = read_hl_credentials(hl_credentials_secret)
+    path = os.path.join(hldbx_home, "hldbx.yaml")
+    # Readable only by its owner, since it may hold the HL client secret
+    fd = os.open(path, os.O_WRONLY | os.O_CREAT | os.O_TRUNC, 0o600)
+    with os.fdopen(fd, "w") as f:
+        yaml.safe_dump(config, f)
+
+def local_binary(hldbx_binary: str, work_dir: str) -> str:
+    """Copy hldbx to local disk and make it executable, since Volumes and workspace files can't be executed."""
+    path = os.path.join(work_dir, "hldbx")
+    shutil.copyfile(hldbx_binary, path)
+    os.chmod(path, os.stat(path).st_mode | stat.S_IXUSR)
+    return path
+
+def run_hldbx(binary: str, args: str, hldbx_home: str) -> None:
+    """Run hldbx with the notebook's Databricks host and token, printing its output as it goes.
+    Raise if it fails, which fails the job."""
+    context = dbutils.notebook.entry_point.getDbutils().notebook().getContext()
+    env = dict(os.environ)
+    env[DATABRICKS_HOST_ENV] = context.apiUrl().get()
+    env[DATABRICKS_TOKEN_ENV] = context.apiToken().get()
+    env[HLDBX_HOME_ENV] = hldbx_home
+    # hldbx can't prompt in a notebook, so settings it would prompt for must be in the configuration file
+    process = subprocess.Popen([binary] + shlex.split(args), env=env, stdin=subprocess.DEVNULL,
+                               stdout=subprocess.PIPE, stderr=subprocess.STDOUT, text=True)
+    for line in process.stdout:
+        print(line, end="")
+    if process.wait() != 0:
+        raise RuntimeError(f"hldbx {args} failed with exit status {process.returncode}, see its output above")
+
+# COMMAND ----------
+
+hldbx_binary = widget("hldbx_binary")
+config_file = widget("config_file")
+hl_credentials_secret = widget("hl_credentials_secret")
+args = widget("args", "autoscan")
+if not hldbx_binary or not config_file:
+    raise ValueError("Set the hldbx_binary and config_file parameters")
+
+work_dir = tempfile.mkdtemp(prefix="hldbx-")
+hldbx_home = widget("hldbx_home") or os.path.join(work_dir, "home")
+os.makedirs(hldbx_home, exist_ok=True)
+try:
+    write_config(config_file, hldbx_home, hl_credentials_secret)
+    run_hldbx(local_binary(hldbx_binary, work_dir), args, hldbx_home)
+finally:
+    # Don't leave the HL client secret behind, even when hldbx_home is kept
+    config_path = os.path.join(hldbx_home, "hldbx.yaml")
+    if os.path.exists(config_path):
+        os.remove(config_path)
+    shutil.rmtree(work_dir, ignore_errors=True)
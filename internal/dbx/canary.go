@@ -0,0 +1,122 @@
+package dbx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Name of the notebook that validates an installation from the jobs' compute
+const canaryNotebookName = "hl_validate_install"
+
+// How long to wait for the validation run, including starting a terminated cluster
+const canaryTimeout = 30 * time.Minute
+
+// CanaryCheck is the outcome of one check of the validation notebook. The JSON names must match hl_validate_install.py.
+type CanaryCheck struct {
+	Name        string `json:"name"`
+	Ok          bool   `json:"ok"`
+	Message     string `json:"message"`
+	Unreachable bool   `json:"unreachable,omitempty"` // the check failed to connect to an endpoint
+}
+
+// canaryOutput is the notebook output of the validation notebook.
+type canaryOutput struct {
+	Checks []CanaryCheck `json:"checks"`
+}
+
+// canaryRun returns the one-time run of the validation notebook. It gets the monitoring job's parameters and runs on
+// its compute, as its identity, so that it sees what the job will.
+func canaryRun(config *utils.Config) jobs.SubmitRun {
+	monitorJob := monitorJobSettings(config)
+	parameters := map[string]string{}
+	for _, param := range monitorJob.Parameters {
+		parameters[param.Name] = param.Default
+	}
+	return jobs.SubmitRun{
+		RunName:        "hl_validate_install",
+		BudgetPolicyId: monitorJob.BudgetPolicyId,
+		Tasks: []jobs.SubmitTask{{
+			TaskKey:           "validate",
+			ExistingClusterId: taskClusterId(config),
+			NewCluster:        taskNewCluster(config),
+			NotebookTask: &jobs.NotebookTask{
+				NotebookPath:   fmt.Sprintf("%s/%s", getHLWorkspaceDirectory(config), canaryNotebookName),
+				BaseParameters: parameters,
+			},
+			TimeoutSeconds: int(canaryTimeout.Seconds()),
+		}},
+		RunAs: monitorJob.RunAs,
+	}
+}
+
+// runCanary runs the validation notebook, which checks the secret scopes, the HiddenLayer endpoints, and the
+// monitored schemas from the compute that the jobs run on, and returns the outcome of each check.
+// Returns an error if the notebook can't run, which is a problem with the compute itself.
+func runCanary(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) ([]CanaryCheck, error) {
+	wait, err := client.Jobs.Submit(ctx, canaryRun(config))
+	if err != nil {
+		return nil, fmt.Errorf("unable to submit the validation run: %w", err)
+	}
+	run, err := wait.GetWithTimeout(canaryTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("validation run %d: %w", wait.RunId, err)
+	}
+	if run.State != nil && run.State.ResultState != jobs.RunResultStateSuccess {
+		return nil, fmt.Errorf("validation run %d is %s: %s", wait.RunId, runState(run.State), run.State.StateMessage)
+	}
+	if len(run.Tasks) == 0 {
+		return nil, fmt.Errorf("validation run %d has no task", wait.RunId)
+	}
+	output, err := client.Jobs.GetRunOutput(ctx, jobs.GetRunOutputRequest{RunId: run.Tasks[0].RunId})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get output of validation run %d: %w", wait.RunId, err)
+	}
+	if output.NotebookOutput == nil || output.NotebookOutput.Result == "" {
+		return nil, fmt.Errorf("validation run %d returned no output", wait.RunId)
+	}
+	var result canaryOutput
+	if err := json.Unmarshal([]byte(output.NotebookOutput.Result), &result); err != nil {
+		return nil, fmt.Errorf("unable to parse output of validation run %d: %w", wait.RunId, err)
+	}
+	return result.Checks, nil
+}
+
+// validateInstall runs the validation notebook and prints the outcome of its checks.
+// Returns an error if a check failed, or the notebook couldn't run.
+func validateInstall(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) error {
+	fmt.Println("Validating the installation from the jobs' compute, this may take a few minutes if the cluster is starting")
+	if config.DbxServerless {
+		fmt.Printf("Serverless jobs need the workspace's serverless network policy to allow egress to: %s\n",
+			EgressHosts(ServerlessEgressDestinations(config)))
+	}
+	checks, err := runCanary(ctx, client, config)
+	if err != nil {
+		return err
+	}
+	failed, unreachable := 0, false
+	for _, check := range checks {
+		if check.Ok {
+			utils.Printf("OK: %s: %s\n", check.Name, check.Message)
+		} else {
+			utils.Printf("FAIL: %s: %s\n", check.Name, check.Message)
+			failed++
+			unreachable = unreachable || check.Unreachable
+		}
+	}
+	if failed > 0 && unreachable && config.DbxServerless {
+		// Serverless egress is denied by the network policy rather than a firewall that hldbx could point to
+		return fmt.Errorf("%d of %d validation check(s) failed, serverless compute can't reach an endpoint: allow "+
+			"egress to %s in the serverless network policy of the workspace's network connectivity configuration",
+			failed, len(checks), EgressHosts(ServerlessEgressDestinations(config)))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d validation check(s) failed", failed, len(checks))
+	}
+	return nil
+}
@@ -0,0 +1,389 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/catalog"
+	"github.com/databricks/databricks-sdk-go/service/compute"
+	"github.com/databricks/databricks-sdk-go/service/iam"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/databricks/databricks-sdk-go/service/workspace"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlmodelmap"
+)
+
+// Command that changes the identity of the installed jobs, as the install audit log records it
+const chownCommand = "hldbx chown"
+
+// Workspace group that every user and service principal belongs to, which SCIM doesn't list
+const allUsersGroup = "users"
+
+// Cluster permissions that let a job's identity attach to the cluster
+var clusterAttachPermissions = []compute.ClusterPermissionLevel{compute.ClusterPermissionLevelCanAttachTo,
+	compute.ClusterPermissionLevelCanRestart, compute.ClusterPermissionLevelCanManage}
+
+// ChownedJob is an installed job whose identity ChangeJobsRunAs changed, or that already had it.
+type ChownedJob struct {
+	JobId         int64  `json:"job_id"`
+	Name          string `json:"name"`
+	Kind          string `json:"kind"` // monitor, guardrail, verify, or on_demand
+	PreviousRunAs string `json:"previous_run_as,omitempty"`
+	PreviousOwner string `json:"previous_owner,omitempty"`
+	Changed       bool   `json:"changed"` // false if the job already ran as, and was owned by, the service principal
+}
+
+// lookupRunAsPrincipal returns the service principal with the application ID, and its groups.
+func lookupRunAsPrincipal(ctx context.Context, client *databricks.WorkspaceClient, applicationId string) (*grantee, error) {
+	found, err := client.ServicePrincipals.ListAll(ctx, iam.ListServicePrincipalsRequest{
+		Filter:     scimEqFilter("applicationId", applicationId),
+		Attributes: "id,applicationId,active,groups",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to look up service principal %s: %w", applicationId, err)
+	}
+	for _, sp := range found {
+		if sp.ApplicationId != applicationId {
+			continue
+		}
+		if !sp.Active {
+			return nil, fmt.Errorf("service principal %s is disabled", applicationId)
+		}
+		principal := &grantee{name: applicationId, groups: []string{allUsersGroup}}
+		for _, group := range sp.Groups {
+			principal.groups = append(principal.groups, group.Display)
+		}
+		return principal, nil
+	}
+	return nil, fmt.Errorf("service principal %s not found in Databricks", applicationId)
+}
+
+// CheckRunAsPermissions checks that a service principal has what the installed jobs need to run as it: to attach
+// to the clusters that they run on, to read the secrets scopes of the monitored schemas, USE CATALOG, USE SCHEMA, and
+// EXECUTE on the monitored schemas, to read their model versions, and APPLY TAG or MANAGE, to tag them, and to write
+// the state and model map tables. Grants to the groups it belongs to count.
+func CheckRunAsPermissions(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config,
+	applicationId string) ([]DoctorCheck, error) {
+	principal, err := lookupRunAsPrincipal(ctx, client, applicationId)
+	if err != nil {
+		return nil, err
+	}
+	job, err := monitorJob(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	schemas, err := jobSchemas(job)
+	if err != nil {
+		return nil, err
+	}
+	clusterChecks, err := checkClusterAttach(ctx, client, principal)
+	if err != nil {
+		return nil, err
+	}
+	checks := clusterChecks
+	secretChecks, err := checkSecretsRead(ctx, client, principal, schemas)
+	if err != nil {
+		return nil, err
+	}
+	checks = append(checks, secretChecks...)
+	for _, schema := range schemas {
+		checks = append(checks, checkSchemaGrants(ctx, client, principal, schema))
+	}
+	for _, table := range []string{config.StateTable(), hlmodelmap.Table(config)} {
+		if table != "" {
+			checks = append(checks, checkTableGrants(ctx, client, principal, table))
+		}
+	}
+	return checks, nil
+}
+
+// checkClusterAttach checks that the service principal can attach to each cluster that the installed jobs run on.
+func checkClusterAttach(ctx context.Context, client *databricks.WorkspaceClient, principal *grantee) ([]DoctorCheck, error) {
+	var clusterIds []string
+	for _, key := range []string{monitorJobKey, guardrailJobKey, verifyJobKey, onDemandJobKey} {
+		installed, err := findJobs(ctx, client, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, job := range installed {
+			for _, task := range job.Settings.Tasks {
+				if task.ExistingClusterId != "" && !slices.Contains(clusterIds, task.ExistingClusterId) {
+					clusterIds = append(clusterIds, task.ExistingClusterId)
+				}
+			}
+		}
+	}
+	if len(clusterIds) == 0 {
+		return []DoctorCheck{{Name: "attach to clusters", Ok: true,
+			Message: "the jobs run on serverless compute or job clusters, which they create"}}, nil
+	}
+	var checks []DoctorCheck
+	for _, clusterId := range clusterIds {
+		check := DoctorCheck{Name: "attach to cluster " + clusterId}
+		permissions, err := client.Clusters.GetPermissions(ctx, compute.GetClusterPermissionsRequest{ClusterId: clusterId})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the permissions of cluster %s: %w", clusterId, err)
+		}
+		for _, acl := range permissions.AccessControlList {
+			if !principal.grantedTo(acl.ServicePrincipalName, acl.GroupName) {
+				continue
+			}
+			for _, permission := range acl.AllPermissions {
+				if slices.Contains(clusterAttachPermissions, permission.PermissionLevel) {
+					check.Ok = true
+				}
+			}
+		}
+		if check.Ok {
+			check.Message = "the jobs can attach to the cluster"
+		} else {
+			check.Message = fmt.Sprintf("%s can't attach to the cluster", principal.name)
+			check.Remediation = fmt.Sprintf("grant %s %s on cluster %s", principal.name,
+				compute.ClusterPermissionLevelCanAttachTo, clusterId)
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// checkSecretsRead checks that the service principal can read the secrets scope of each monitored schema, its own
+// scope, or the consolidated scope if it has none.
+func checkSecretsRead(ctx context.Context, client *databricks.WorkspaceClient, principal *grantee,
+	schemas []utils.CatalogSchemaConfig) ([]DoctorCheck, error) {
+	scopes, err := client.Secrets.ListScopesAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list secrets scopes: %w", err)
+	}
+	var checked []string
+	var checks []DoctorCheck
+	for _, schema := range schemas {
+		scope := ownSecretsLocation(schema).Scope
+		if !slices.ContainsFunc(scopes, func(s workspace.SecretScope) bool { return s.Name == scope }) {
+			scope = consolidatedSecretsScope
+		}
+		if slices.Contains(checked, scope) {
+			continue
+		}
+		checked = append(checked, scope)
+		check := DoctorCheck{Name: "read secrets scope " + scope}
+		acls, err := client.Secrets.ListAclsAll(ctx, workspace.ListAclsRequest{Scope: scope})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list the ACLs of secret scope %s: %w", scope, err)
+		}
+		// Every permission of a secrets scope includes reading it
+		check.Ok = slices.ContainsFunc(acls, func(acl workspace.AclItem) bool {
+			return principal.grantedTo(acl.Principal, acl.Principal)
+		})
+		if check.Ok {
+			check.Message = "the jobs can read the HiddenLayer credentials"
+		} else {
+			check.Message = fmt.Sprintf("%s can't read the HiddenLayer credentials", principal.name)
+			check.Remediation = fmt.Sprintf("databricks secrets put-acl %s %s %s", scope, principal.name,
+				workspace.AclPermissionRead)
+		}
+		checks = append(checks, check)
+	}
+	return checks, nil
+}
+
+// checkSchemaGrants checks that the service principal owns a monitored schema, or has USE CATALOG, USE SCHEMA,
+// EXECUTE, and APPLY TAG or MANAGE on it, directly or inherited from its catalog.
+func checkSchemaGrants(ctx context.Context, client *databricks.WorkspaceClient, principal *grantee,
+	schema utils.CatalogSchemaConfig) DoctorCheck {
+	fullName := fmt.Sprintf("%s.%s", schema.Catalog, schema.Schema)
+	check := DoctorCheck{Name: "Unity Catalog grants on " + fullName}
+	schemaInfo, err := client.Schemas.GetByFullName(ctx, fullName)
+	if err != nil {
+		check.Message = fmt.Sprintf("unable to get schema %s: %v", fullName, err)
+		check.Remediation = "check that the schema still exists, and that your identity can see it"
+		return check
+	}
+	if principal.grantedTo(schemaInfo.Owner, schemaInfo.Owner) {
+		check.Ok, check.Message = true, fmt.Sprintf("%s owns the schema", schemaInfo.Owner)
+		return check
+	}
+	permissions, err := client.Grants.GetEffective(ctx, catalog.GetEffectiveRequest{
+		SecurableType: catalog.SecurableTypeSchema,
+		FullName:      fullName,
+		Principal:     principal.name,
+	})
+	if err != nil {
+		check.Message = fmt.Sprintf("unable to get the permissions of %s on schema %s: %v", principal.name, fullName, err)
+		check.Remediation = "ask the schema's owner or a metastore admin to check its grants"
+		return check
+	}
+	var missing []string
+	if !hasPrivilege(permissions, catalog.PrivilegeAllPrivileges) {
+		for _, privilege := range []catalog.Privilege{catalog.PrivilegeUseCatalog, catalog.PrivilegeUseSchema,
+			catalog.PrivilegeExecute, catalog.PrivilegeApplyTag} {
+			// MANAGE includes applying tags
+			if !hasPrivilege(permissions, privilege) &&
+				!(privilege == catalog.PrivilegeApplyTag && hasPrivilege(permissions, catalog.PrivilegeManage)) {
+				missing = append(missing, strings.ReplaceAll(string(privilege), "_", " "))
+			}
+		}
+	}
+	if len(missing) == 0 {
+		check.Ok, check.Message = true, "the jobs can read and tag the schema's model versions"
+		return check
+	}
+	check.Message = fmt.Sprintf("%s lacks %s", principal.name, strings.Join(missing, ", "))
+	var grants []string
+	for _, privilege := range missing {
+		on := "SCHEMA " + fullName
+		if privilege == "USE CATALOG" {
+			on = "CATALOG " + schema.Catalog
+		}
+		grants = append(grants, fmt.Sprintf("GRANT %s ON %s TO `%s`", privilege, on, principal.name))
+	}
+	check.Remediation = strings.Join(grants, "; ")
+	return check
+}
+
+// checkTableGrants checks that the service principal can write a table that the jobs write: that it owns the table, or
+// has SELECT and MODIFY on it, or if the table doesn't exist yet, that it has CREATE TABLE on its schema, to create it.
+func checkTableGrants(ctx context.Context, client *databricks.WorkspaceClient, principal *grantee,
+	table string) DoctorCheck {
+	check := DoctorCheck{Name: "write table " + table}
+	securableType, fullName := catalog.SecurableTypeTable, table
+	required := []catalog.Privilege{catalog.PrivilegeSelect, catalog.PrivilegeModify}
+	tableInfo, err := client.Tables.GetByFullName(ctx, table)
+	if errors.Is(err, databricks.ErrResourceDoesNotExist) || errors.Is(err, databricks.ErrNotFound) {
+		securableType, fullName = catalog.SecurableTypeSchema, table[:strings.LastIndex(table, ".")]
+		required = []catalog.Privilege{catalog.PrivilegeCreateTable}
+	} else if err != nil {
+		check.Message = fmt.Sprintf("unable to get table %s: %v", table, err)
+		check.Remediation = "check that your identity can see the table"
+		return check
+	} else if principal.grantedTo(tableInfo.Owner, tableInfo.Owner) {
+		check.Ok, check.Message = true, fmt.Sprintf("%s owns the table", tableInfo.Owner)
+		return check
+	}
+	permissions, err := client.Grants.GetEffective(ctx, catalog.GetEffectiveRequest{
+		SecurableType: securableType,
+		FullName:      fullName,
+		Principal:     principal.name,
+	})
+	if err != nil {
+		check.Message = fmt.Sprintf("unable to get the permissions of %s on %s %s: %v", principal.name,
+			strings.ToLower(string(securableType)), fullName, err)
+		check.Remediation = "ask the owner or a metastore admin to check its grants"
+		return check
+	}
+	var missing []string
+	if !hasPrivilege(permissions, catalog.PrivilegeAllPrivileges) {
+		for _, privilege := range required {
+			if !hasPrivilege(permissions, privilege) {
+				missing = append(missing, strings.ReplaceAll(string(privilege), "_", " "))
+			}
+		}
+	}
+	if len(missing) == 0 {
+		check.Ok, check.Message = true, "the jobs can write the table"
+		return check
+	}
+	check.Message = fmt.Sprintf("%s lacks %s on %s %s", principal.name, strings.Join(missing, ", "),
+		strings.ToLower(string(securableType)), fullName)
+	check.Remediation = fmt.Sprintf("GRANT %s ON %s %s TO `%s`", strings.Join(missing, ", "), securableType, fullName,
+		principal.name)
+	return check
+}
+
+// ChangeJobsRunAs makes the installed jobs run as, and be owned by, a service principal, e.g. after the user who
+// installed them left, so that they don't stop when that user is deactivated. The scan jobs that the monitoring job
+// creates from then on run as it too. The install audit log records the change, if any job changed, including when
+// a later job fails to change, with the jobs that did.
+func ChangeJobsRunAs(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, applicationId string) ([]ChownedJob, error) {
+	chowned, err := chownJobs(ctx, client, applicationId)
+	var changed []string
+	for _, job := range chowned {
+		if job.Changed {
+			changed = append(changed, strconv.FormatInt(job.JobId, 10))
+		}
+	}
+	if len(changed) == 0 {
+		return chowned, err
+	}
+	command := fmt.Sprintf("%s --run-as %s", chownCommand, applicationId)
+	if err != nil {
+		command += fmt.Sprintf(" (failed partway, changed jobs %s)", strings.Join(changed, ", "))
+	}
+	if auditErr := AppendAuditRecord(ctx, client, config, command); auditErr != nil {
+		return chowned, errors.Join(err, fmt.Errorf("unable to record the change in the install audit log: %w", auditErr))
+	}
+	return chowned, err
+}
+
+// chownJobs makes the installed jobs run as, and be owned by, the service principal, stopping at the first job that
+// fails to change. Returns the jobs before it.
+func chownJobs(ctx context.Context, client *databricks.WorkspaceClient, applicationId string) ([]ChownedJob, error) {
+	chowned := []ChownedJob{}
+	for _, key := range []string{monitorJobKey, guardrailJobKey, verifyJobKey, onDemandJobKey} {
+		installed, err := findJobs(ctx, client, key)
+		if err != nil {
+			return chowned, err
+		}
+		for _, found := range installed {
+			job, err := chownJob(ctx, client, found.JobId, key, applicationId)
+			if err != nil {
+				return chowned, err
+			}
+			chowned = append(chowned, *job)
+		}
+	}
+	return chowned, nil
+}
+
+// chownJob makes a job run as, and be owned by, the service principal, unless it already is.
+func chownJob(ctx context.Context, client *databricks.WorkspaceClient, jobId int64, kind, applicationId string) (*ChownedJob, error) {
+	job, err := client.Jobs.GetByJobId(ctx, jobId)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get job %d: %w", jobId, err)
+	}
+	chowned := &ChownedJob{JobId: jobId, Kind: kind, PreviousRunAs: job.RunAsUserName}
+	if job.Settings != nil {
+		chowned.Name = job.Settings.Name
+	}
+	permissions, err := client.Jobs.GetPermissions(ctx, jobs.GetJobPermissionsRequest{JobId: strconv.FormatInt(jobId, 10)})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the permissions of job %d: %w", jobId, err)
+	}
+	for _, acl := range permissions.AccessControlList {
+		for _, permission := range acl.AllPermissions {
+			if permission.PermissionLevel == jobs.JobPermissionLevelIsOwner {
+				chowned.PreviousOwner = acl.ServicePrincipalName + acl.UserName
+			}
+		}
+	}
+	if chowned.PreviousRunAs == applicationId && chowned.PreviousOwner == applicationId {
+		return chowned, nil
+	}
+
+	if chowned.PreviousRunAs != applicationId {
+		err := client.Jobs.Update(ctx, jobs.UpdateJob{JobId: jobId, NewSettings: &jobs.JobSettings{
+			RunAs: &jobs.JobRunAs{ServicePrincipalName: applicationId},
+		}})
+		if err != nil {
+			return nil, fmt.Errorf("unable to make job %d run as %s: %w", jobId, applicationId, err)
+		}
+	}
+	if chowned.PreviousOwner != applicationId {
+		_, err := client.Jobs.UpdatePermissions(ctx, jobs.JobPermissionsRequest{
+			JobId: strconv.FormatInt(jobId, 10),
+			AccessControlList: []jobs.JobAccessControlRequest{{
+				ServicePrincipalName: applicationId,
+				PermissionLevel:      jobs.JobPermissionLevelIsOwner,
+			}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to make %s the owner of job %d: %w", applicationId, jobId, err)
+		}
+	}
+	chowned.Changed = true
+	return chowned, nil
+}
@@ -0,0 +1,123 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/compute"
+	"github.com/databricks/databricks-sdk-go/service/workspace"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+)
+
+// CloudTemplate is what differs between the clouds that Databricks workspaces run on, which the defaults and the
+// validation of the settings follow.
+type CloudTemplate struct {
+	Cloud           hlconfig.Cloud
+	Name            string
+	TokenTypes      string         // the kinds of tokens that authenticate to a workspace, as offered at the token prompt
+	NodeTypePattern *regexp.Regexp // of the node type IDs of clusters
+	NodeTypeExample string
+	// Node types of job clusters when none is configured, by preference: small general purpose nodes with a local
+	// disk, which are available in most regions
+	DefaultNodeTypes []string
+}
+
+// Templates of the clouds, by cloud
+var cloudTemplates = map[hlconfig.Cloud]CloudTemplate{
+	hlconfig.CloudAzure: {
+		Cloud:            hlconfig.CloudAzure,
+		Name:             "Azure Databricks",
+		TokenTypes:       "a personal access token, or a Microsoft Entra ID token",
+		NodeTypePattern:  regexp.MustCompile(`^Standard_[A-Za-z0-9_]+$`),
+		NodeTypeExample:  "Standard_D4ds_v5",
+		DefaultNodeTypes: []string{"Standard_D4ds_v5", "Standard_D4ds_v4", "Standard_DS3_v2"},
+	},
+	hlconfig.CloudAWS: {
+		Cloud:            hlconfig.CloudAWS,
+		Name:             "Databricks on AWS",
+		TokenTypes:       "a personal access token",
+		NodeTypePattern:  regexp.MustCompile(`^[a-z][a-z0-9-]*\.[0-9]*[a-z]+$`),
+		NodeTypeExample:  "m5d.large",
+		DefaultNodeTypes: []string{"m5d.large", "m6gd.large", "i3.xlarge"},
+	},
+	hlconfig.CloudGCP: {
+		Cloud:            hlconfig.CloudGCP,
+		Name:             "Databricks on Google Cloud",
+		TokenTypes:       "a personal access token",
+		NodeTypePattern:  regexp.MustCompile(`^[a-z][a-z0-9]*-[a-z]+(-[a-z]+)?-[0-9]+[a-z]*$`),
+		NodeTypeExample:  "n2-standard-4",
+		DefaultNodeTypes: []string{"n2-standard-4", "n1-standard-4"},
+	},
+}
+
+// WorkspaceCloud returns the template of the cloud of the workspace, see Config.Cloud. Returns false if the cloud
+// isn't known, e.g. for a vanity hostname without dbx_cloud, and then nothing that differs between clouds is checked.
+func WorkspaceCloud(config *utils.Config) (CloudTemplate, bool) {
+	template, ok := cloudTemplates[config.Cloud()]
+	return template, ok
+}
+
+// TokenTypes returns the kinds of tokens that authenticate to a workspace, for the token prompt.
+func TokenTypes(host string) string {
+	if template, ok := cloudTemplates[hlconfig.DetectCloud(host)]; ok {
+		return template.TokenTypes
+	}
+	return "a personal access token"
+}
+
+// ValidateCloudNodeType checks that dbx_job_cluster_node_type is a node type of the cloud of the workspace, e.g. not
+// an AWS node type in a configuration file copied to an Azure workspace, which Databricks would only reject when the
+// first run creates its cluster.
+func ValidateCloudNodeType(config *utils.Config) error {
+	template, ok := WorkspaceCloud(config)
+	if !ok || config.DbxJobClusterNodeType == "" || template.NodeTypePattern.MatchString(config.DbxJobClusterNodeType) {
+		return nil
+	}
+	return fmt.Errorf("dbx_job_cluster_node_type %s isn't a node type of %s, the cloud of the workspace; use one "+
+		"of its node types, e.g. %s", config.DbxJobClusterNodeType, template.Name, template.NodeTypeExample)
+}
+
+// defaultNodeType returns the node type of job clusters when none is configured: the first of the cloud's default
+// node types that the workspace offers, or else the smallest node type with a local disk.
+func defaultNodeType(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) (string, error) {
+	if template, ok := WorkspaceCloud(config); ok {
+		available, err := client.Clusters.ListNodeTypes(ctx)
+		if err != nil {
+			return "", fmt.Errorf("unable to list node types: %w", err)
+		}
+		for _, nodeType := range template.DefaultNodeTypes {
+			if slices.ContainsFunc(available.NodeTypes, func(n compute.NodeType) bool {
+				return n.NodeTypeId == nodeType && !n.IsDeprecated && (n.NodeInfo == nil || len(n.NodeInfo.Status) == 0)
+			}) {
+				return nodeType, nil
+			}
+		}
+	}
+	nodeType, err := client.Clusters.SelectNodeType(ctx, compute.NodeTypeRequest{LocalDisk: true})
+	if err != nil {
+		return "", fmt.Errorf("unable to select a node type: %w", err)
+	}
+	return nodeType, nil
+}
+
+// checkScopeBackend checks that hldbx can write the secrets of a schema to an existing secrets scope, which only
+// Databricks-backed scopes allow. Azure Key Vault-backed scopes are read-only through Databricks.
+func checkScopeBackend(ctx context.Context, client *databricks.WorkspaceClient, scope string) error {
+	scopes, err := client.Secrets.ListScopesAll(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to list secrets scopes: %w", err)
+	}
+	for _, existing := range scopes {
+		if existing.Name != scope || existing.BackendType == "" || existing.BackendType == workspace.ScopeBackendTypeDatabricks {
+			continue
+		}
+		return fmt.Errorf("secret scope %s is backed by %s, which is read-only through Databricks, so hldbx can't store "+
+			"the HiddenLayer credentials in it; delete it, or rename it, and re-run autoscan to create a Databricks-backed "+
+			"scope", scope, existing.BackendType)
+	}
+	return nil
+}
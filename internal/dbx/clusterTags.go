@@ -0,0 +1,66 @@
+package dbx
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+)
+
+// ValidateClusterTags checks the tags that the cluster the jobs run on must carry, and the policy for one that doesn't.
+func ValidateClusterTags(config *utils.Config) error {
+	if err := config.DbxClusterTagPolicy.Validate(); err != nil {
+		return err
+	}
+	for key := range config.DbxClusterTags {
+		if strings.TrimSpace(key) == "" {
+			return fmt.Errorf("dbx_required_cluster_tags has an empty tag key")
+		}
+	}
+	return nil
+}
+
+// ClusterTagsEnforced returns whether a cluster that lacks the required tags is refused, rather than used with
+// a warning.
+func ClusterTagsEnforced(config *utils.Config) bool {
+	return config.DbxClusterTagPolicy != hlconfig.ClusterTagPolicyWarn
+}
+
+// MissingClusterTags returns the tags of dbx_required_cluster_tags that a cluster doesn't carry, as key=value, or
+// key alone for a tag whose value doesn't matter, sorted. Keys are compared regardless of case, since the
+// configuration file's keys are read in lowercase.
+func MissingClusterTags(config *utils.Config, tags map[string]string) []string {
+	var missing []string
+	for key, value := range config.DbxClusterTags {
+		var actual string
+		ok := false
+		for tagKey, tagValue := range tags {
+			if strings.EqualFold(tagKey, key) {
+				actual, ok = tagValue, true
+				break
+			}
+		}
+		switch {
+		case value == "" && !ok:
+			missing = append(missing, key)
+		case value != "" && actual != value:
+			missing = append(missing, fmt.Sprintf("%s=%s", key, value))
+		}
+	}
+	slices.Sort(missing)
+	return missing
+}
+
+// requiredJobClusterTags returns the tags of dbx_required_cluster_tags that have a value, for the clusters that
+// the jobs create to carry. A tag whose value doesn't matter can't be set for lack of a value.
+func requiredJobClusterTags(config *utils.Config) map[string]string {
+	tags := map[string]string{}
+	for key, value := range config.DbxClusterTags {
+		if value != "" {
+			tags[key] = value
+		}
+	}
+	return tags
+}
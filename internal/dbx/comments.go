@@ -0,0 +1,64 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/catalog"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// CheckCommentPermissions checks that the identity the monitoring job runs as can update model version comments
+// in each monitored schema, which requires owning the schema or having MANAGE on it. Returns a warning for each
+// schema where it can't; the identity may still own individual models, so this isn't fatal.
+func CheckCommentPermissions(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) ([]string, error) {
+	principal := config.DbxRunAs
+	if principal == "" {
+		// The job runs as the user who created it
+		me, err := client.CurrentUser.Me(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the current user: %w", err)
+		}
+		principal = me.UserName
+	}
+
+	var warnings []string
+	for _, schema := range config.DbxSchemas {
+		schemaFullName := fmt.Sprintf("%s.%s", schema.Catalog, schema.Schema)
+		schemaInfo, err := client.Schemas.GetByFullName(ctx, schemaFullName)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get schema %s: %w", schemaFullName, err)
+		}
+		if schemaInfo.Owner == principal {
+			continue
+		}
+		permissions, err := client.Grants.GetEffective(ctx, catalog.GetEffectiveRequest{
+			SecurableType: catalog.SecurableTypeSchema,
+			FullName:      schemaFullName,
+			Principal:     principal,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the permissions of %s on schema %s: %w", principal, schemaFullName, err)
+		}
+		if !hasPrivilege(permissions, catalog.PrivilegeManage, catalog.PrivilegeAllPrivileges) {
+			warnings = append(warnings, fmt.Sprintf("%s can't update model version comments in schema %s, "+
+				"grant it MANAGE on the schema, e.g. GRANT MANAGE ON SCHEMA %s TO `%s`", principal, schemaFullName, schemaFullName, principal))
+		}
+	}
+	return warnings, nil
+}
+
+// hasPrivilege returns true if the effective permissions include any of the privileges.
+func hasPrivilege(permissions *catalog.EffectivePermissionsList, privileges ...catalog.Privilege) bool {
+	for _, assignment := range permissions.PrivilegeAssignments {
+		for _, effective := range assignment.Privileges {
+			for _, privilege := range privileges {
+				if effective.Privilege == privilege {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
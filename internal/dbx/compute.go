@@ -0,0 +1,282 @@
+package dbx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/compute"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Key of the job cluster that the tasks of a job share, when the jobs run on job clusters
+const jobClusterKey = "hl_job_cluster"
+
+// Databricks limits the clusters that jobs create to this many workers, well beyond what scanning needs
+const maxJobClusterWorkers = 100
+
+// Compute that FixJobCompute can move the jobs to, when the cluster they run on was deleted
+const (
+	FixCluster    = "cluster"     // another existing cluster
+	FixJobCluster = "job-cluster" // a cluster that each run creates, and terminates when it ends
+	FixServerless = "serverless"
+)
+
+// ComputeFixes are the ways to fix jobs whose cluster was deleted, see FixJobCompute.
+var ComputeFixes = []string{FixCluster, FixJobCluster, FixServerless}
+
+// ValidateJobCluster checks the settings of the cluster that each job run creates, if the jobs run on job clusters.
+func ValidateJobCluster(config *utils.Config) error {
+	if config.DbxJobClusterNodeType == "" {
+		return nil
+	}
+	if config.DbxServerless {
+		return errors.New("dbx_job_cluster_node_type doesn't apply with dbx_serverless: true, set one or the other")
+	}
+	if config.DbxJobClusterSpark == "" {
+		return errors.New("dbx_job_cluster_spark_version is required with dbx_job_cluster_node_type, e.g. 15.4.x-scala2.12")
+	}
+	if config.DbxJobClusterWorkers < 0 || config.DbxJobClusterWorkers > maxJobClusterWorkers {
+		return fmt.Errorf("dbx_job_cluster_workers must be between 0 and %d, got %d", maxJobClusterWorkers, config.DbxJobClusterWorkers)
+	}
+	return nil
+}
+
+// jobClusterSpec returns the spec of the cluster that each job run creates. It has no workers unless configured,
+// which makes it a single node cluster, and runs in dedicated access mode, as the identity of the job, for Unity Catalog.
+// It carries the tags of dbx_required_cluster_tags.
+func jobClusterSpec(config *utils.Config) compute.ClusterSpec {
+	spec := compute.ClusterSpec{
+		SparkVersion:     config.DbxJobClusterSpark,
+		NodeTypeId:       config.DbxJobClusterNodeType,
+		NumWorkers:       config.DbxJobClusterWorkers,
+		DataSecurityMode: compute.DataSecurityModeSingleUser,
+	}
+	tags := requiredJobClusterTags(config)
+	if config.DbxJobClusterWorkers == 0 {
+		spec.SparkConf = map[string]string{"spark.databricks.cluster.profile": "singleNode", "spark.master": "local[*]"}
+		tags["ResourceClass"] = "SingleNode"
+		spec.ForceSendFields = []string{"NumWorkers"}
+	}
+	if len(tags) > 0 {
+		spec.CustomTags = tags
+	}
+	return spec
+}
+
+// jobClusters returns the job clusters of a job, which its tasks share, or none unless the jobs run on job clusters.
+func jobClusters(config *utils.Config) []jobs.JobCluster {
+	if !config.UsesJobCluster() {
+		return nil
+	}
+	return []jobs.JobCluster{{JobClusterKey: jobClusterKey, NewCluster: jobClusterSpec(config)}}
+}
+
+// taskJobClusterKey returns the job cluster that job tasks run on, or "" unless the jobs run on job clusters.
+func taskJobClusterKey(config *utils.Config) string {
+	if !config.UsesJobCluster() {
+		return ""
+	}
+	return jobClusterKey
+}
+
+// taskNewCluster returns the cluster that a one-time run creates, or nil unless the jobs run on job clusters.
+func taskNewCluster(config *utils.Config) *compute.ClusterSpec {
+	if !config.UsesJobCluster() {
+		return nil
+	}
+	spec := jobClusterSpec(config)
+	return &spec
+}
+
+// jobClusterParam returns the spec of the job cluster as JSON, for the monitoring job to create the same cluster
+// for the scan jobs, or "" unless the jobs run on job clusters.
+func jobClusterParam(config *utils.Config) string {
+	if !config.UsesJobCluster() {
+		return ""
+	}
+	spec, err := json.Marshal(jobClusterSpec(config))
+	if err != nil {
+		utils.Fatalf("Error marshalling the job cluster: %v", err)
+	}
+	return string(spec)
+}
+
+// DefaultJobCluster returns the latest long-term support Databricks Runtime, and the default node type of the cloud of
+// the workspace, see defaultNodeType, for a job cluster when none is configured.
+func DefaultJobCluster(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) (string, string, error) {
+	sparkVersion, err := client.Clusters.SelectSparkVersion(ctx, compute.SparkVersionRequest{Latest: true, LongTermSupport: true})
+	if err != nil {
+		return "", "", fmt.Errorf("unable to select a Databricks Runtime version: %w", err)
+	}
+	nodeType, err := defaultNodeType(ctx, client, config)
+	if err != nil {
+		return "", "", err
+	}
+	return sparkVersion, nodeType, nil
+}
+
+// DeletedCluster is a task of an installed job that runs on a cluster that no longer exists, so every run fails.
+type DeletedCluster struct {
+	JobId     int64  `json:"job_id"`
+	JobName   string `json:"job_name"`
+	TaskKey   string `json:"task_key"`
+	ClusterId string `json:"cluster_id"`
+}
+
+// ComputeStatus is what CheckJobCompute found: the installed jobs, and the tasks whose cluster was deleted.
+type ComputeStatus struct {
+	Jobs                 int              `json:"jobs"`
+	DeletedClusters      []DeletedCluster `json:"deleted_clusters"`
+	ConfigClusterDeleted bool             `json:"config_cluster_deleted,omitempty"` // dbx_cluster_id
+}
+
+// CheckJobCompute checks that the clusters that the installed jobs run on still exist, and the one in the
+// configuration, if the jobs run on an existing cluster. A job whose cluster was deleted fails every run.
+func CheckJobCompute(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) (*ComputeStatus, error) {
+	status := &ComputeStatus{DeletedClusters: []DeletedCluster{}}
+	exists := map[string]bool{}
+	check := func(clusterId string) (bool, error) {
+		if found, checked := exists[clusterId]; checked {
+			return found, nil
+		}
+		found, err := clusterExists(ctx, client, clusterId)
+		if err != nil {
+			return false, err
+		}
+		exists[clusterId] = found
+		return found, nil
+	}
+	for _, key := range []string{monitorJobKey, guardrailJobKey, verifyJobKey, onDemandJobKey} {
+		installed, err := findJobs(ctx, client, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, job := range installed {
+			status.Jobs++
+			for _, task := range job.Settings.Tasks {
+				if task.ExistingClusterId == "" {
+					continue
+				}
+				found, err := check(task.ExistingClusterId)
+				if err != nil {
+					return nil, err
+				}
+				if !found {
+					status.DeletedClusters = append(status.DeletedClusters, DeletedCluster{
+						JobId: job.JobId, JobName: job.Settings.Name, TaskKey: task.TaskKey, ClusterId: task.ExistingClusterId,
+					})
+				}
+			}
+		}
+	}
+	if config.UsesExistingCluster() && config.DbxClusterId != "" {
+		found, err := check(config.DbxClusterId)
+		if err != nil {
+			return nil, err
+		}
+		status.ConfigClusterDeleted = !found
+	}
+	return status, nil
+}
+
+// clusterExists returns true if the cluster exists, including if it is terminated.
+func clusterExists(ctx context.Context, client *databricks.WorkspaceClient, clusterId string) (bool, error) {
+	_, err := client.Clusters.GetByClusterId(ctx, clusterId)
+	if err == nil {
+		return true, nil
+	}
+	// Databricks reports a deleted cluster as an invalid parameter, rather than a missing resource
+	if errors.Is(err, databricks.ErrResourceDoesNotExist) || strings.Contains(err.Error(), "does not exist") {
+		return false, nil
+	}
+	return false, fmt.Errorf("unable to get cluster %s: %w", clusterId, err)
+}
+
+// FixJobCompute moves the tasks of the installed jobs to the compute of the configuration: the cluster of
+// dbx_cluster_id, a job cluster, or serverless compute. The compute parameters of the monitoring and on-demand scan
+// jobs are updated too, so that their scan jobs follow. The jobs are updated in place, keeping their IDs and run history.
+// Returns the IDs of the updated jobs.
+func FixJobCompute(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) ([]int64, error) {
+	desiredParams := monitorJobSettings(config).Parameters
+	var updated []int64
+	for _, key := range []string{monitorJobKey, guardrailJobKey, verifyJobKey, onDemandJobKey} {
+		installed, err := findJobs(ctx, client, key)
+		if err != nil {
+			return updated, err
+		}
+		for _, job := range installed {
+			settings := &jobs.JobSettings{
+				Tasks:          slices.Clone(job.Settings.Tasks),
+				JobClusters:    jobClusters(config),
+				BudgetPolicyId: taskBudgetPolicyId(config),
+			}
+			for i := range settings.Tasks {
+				settings.Tasks[i].ExistingClusterId = taskClusterId(config)
+				settings.Tasks[i].JobClusterKey = taskJobClusterKey(config)
+				settings.Tasks[i].NewCluster = nil
+			}
+			if key == monitorJobKey {
+				settings.Parameters = withComputeParams(job.Settings.Parameters, desiredParams)
+			}
+			if key == onDemandJobKey {
+				// The on-demand scan job has the monitoring job's parameters as base parameters of its task
+				for i, task := range settings.Tasks {
+					if task.NotebookTask == nil {
+						continue
+					}
+					notebookTask := *task.NotebookTask
+					notebookTask.BaseParameters = maps.Clone(notebookTask.BaseParameters)
+					if notebookTask.BaseParameters == nil {
+						notebookTask.BaseParameters = map[string]string{}
+					}
+					for _, param := range withComputeParams(nil, desiredParams) {
+						notebookTask.BaseParameters[param.Name] = param.Default
+					}
+					settings.Tasks[i].NotebookTask = &notebookTask
+				}
+			}
+			var fieldsToRemove []string
+			if !config.UsesJobCluster() && slices.ContainsFunc(job.Settings.JobClusters,
+				func(c jobs.JobCluster) bool { return c.JobClusterKey == jobClusterKey }) {
+				fieldsToRemove = append(fieldsToRemove, "job_clusters/"+jobClusterKey)
+			}
+			err = client.Jobs.Update(ctx, jobs.UpdateJob{JobId: job.JobId, NewSettings: settings, FieldsToRemove: fieldsToRemove})
+			if err != nil {
+				return updated, fmt.Errorf("unable to update the compute of job %d: %w", job.JobId, err)
+			}
+			err = checkBudgetPolicy(ctx, client, settings.BudgetPolicyId, job.JobId, func() error {
+				return client.Jobs.Reset(ctx, jobs.ResetJob{JobId: job.JobId, NewSettings: *job.Settings})
+			})
+			if err != nil {
+				return updated, err
+			}
+			updated = append(updated, job.JobId)
+		}
+	}
+	return updated, nil
+}
+
+// withComputeParams returns the installed job parameters, with the parameters that choose the compute of the scan
+// jobs set to the desired ones. These must match COMPUTE_PARAMS in hl_common.py.
+func withComputeParams(installed []jobs.JobParameterDefinition, desired []jobs.JobParameterDefinition) []jobs.JobParameterDefinition {
+	params := slices.Clone(installed)
+	for _, param := range desired {
+		if !slices.Contains([]string{"serverless", "budget_policy_id", "job_cluster"}, param.Name) {
+			continue
+		}
+		i := slices.IndexFunc(params, func(p jobs.JobParameterDefinition) bool { return p.Name == param.Name })
+		if i < 0 {
+			params = append(params, param)
+		} else {
+			params[i].Default = param.Default
+		}
+	}
+	return params
+}
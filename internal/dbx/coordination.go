@@ -0,0 +1,21 @@
+package dbx
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// ValidateCoordinationTable checks the Delta table that installs in workspaces that share a Unity Catalog metastore
+// claim model versions in, so that only one of them scans each version.
+func ValidateCoordinationTable(config *utils.Config) error {
+	if config.DbxCoordinationTable == "" {
+		return nil
+	}
+	parts := strings.Split(config.DbxCoordinationTable, ".")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" || strings.ContainsAny(config.DbxCoordinationTable, "` ") {
+		return fmt.Errorf("invalid dbx_coordination_table %q, expected <catalog>.<schema>.<table>", config.DbxCoordinationTable)
+	}
+	return nil
+}
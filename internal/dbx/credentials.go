@@ -0,0 +1,254 @@
+package dbx
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/apierr"
+	"github.com/databricks/databricks-sdk-go/service/workspace"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// HLCredsAge describes when the HiddenLayer API credentials stored for a schema were last rotated.
+type HLCredsAge struct {
+	Scope     string    `json:"scope"`
+	RotatedAt time.Time `json:"rotated_at"`
+	Expired   bool      `json:"expired"` // older than the configured maximum age, so they should be rotated
+}
+
+// CheckHLCredsAge returns when the HiddenLayer API credentials in each schema's secrets scope were last rotated.
+// The rotation time is the secret's last-updated time, which only changes when autoscan stores new credentials.
+func CheckHLCredsAge(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) ([]HLCredsAge, error) {
+	var ages []HLCredsAge
+	for _, schema := range config.DbxSchemas {
+		if scanner, ok := config.Scanner(schema); !ok || !scanner.UsesClientCredentials() {
+			continue // the schema's scanner doesn't need credentials
+		}
+		location, secrets, err := listSchemaSecrets(ctx, client, schema)
+		if err != nil {
+			return nil, err
+		}
+		for _, secret := range secrets {
+			if secret.Key != config.HlApiKeyName {
+				continue
+			}
+			rotatedAt := time.UnixMilli(secret.LastUpdatedTimestamp)
+			ages = append(ages, HLCredsAge{
+				Scope:     location.Scope,
+				RotatedAt: rotatedAt,
+				Expired:   time.Since(rotatedAt) > config.HlCredsMaxAge(),
+			})
+		}
+	}
+	return ages, nil
+}
+
+// CredsAgeChecks checks that the HiddenLayer API credentials in each schema's secrets scope are younger than
+// hl_credentials_max_age_days.
+func CredsAgeChecks(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) []DoctorCheck {
+	if !config.UsesClientCredentials() {
+		return nil
+	}
+	ages, err := CheckHLCredsAge(ctx, client, config)
+	if err != nil {
+		return []DoctorCheck{{Name: "HiddenLayer credentials age", Message: err.Error(),
+			Remediation: "check that your identity can list the secrets of the schemas' secrets scopes"}}
+	}
+	var checks []DoctorCheck
+	for _, age := range ages {
+		check := DoctorCheck{Name: "HiddenLayer credentials in " + age.Scope, Ok: !age.Expired,
+			Message: fmt.Sprintf("last rotated %s, %d day(s) ago", age.RotatedAt.Format(time.DateOnly),
+				int(time.Since(age.RotatedAt).Hours()/24))}
+		if age.Expired {
+			check.Message += fmt.Sprintf(", older than hl_credentials_max_age_days %d", int(config.HlCredsMaxAge().Hours()/24))
+			check.Remediation = "create new credentials in the HiddenLayer console, update hl_client_id and " +
+				"hl_client_secret, and re-run hldbx autoscan to store them"
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// Permission granted on the HL secrets scopes to the dbx_secrets_group when dbx_secrets_permission isn't set.
+// MANAGE lets the group rotate the credentials and manage who can read them.
+const defaultSecretsPermission = workspace.AclPermissionManage
+
+// secretsPermission returns the permission to grant the dbx_secrets_group on the HL secrets scopes.
+func secretsPermission(config *utils.Config) workspace.AclPermission {
+	if config.DbxSecretsPermission == "" {
+		return defaultSecretsPermission
+	}
+	return workspace.AclPermission(config.DbxSecretsPermission)
+}
+
+// ValidateSecretsGroup checks the group that is granted access to the HL secrets scopes, and its permission.
+func ValidateSecretsGroup(config *utils.Config) error {
+	permissions := []workspace.AclPermission{workspace.AclPermissionRead, workspace.AclPermissionWrite, workspace.AclPermissionManage}
+	if !slices.Contains(permissions, secretsPermission(config)) {
+		return fmt.Errorf("invalid dbx_secrets_permission %q, expected READ, WRITE, or MANAGE", config.DbxSecretsPermission)
+	}
+	if config.DbxSecretsPermission != "" && config.DbxSecretsGroup == "" {
+		return fmt.Errorf("dbx_secrets_permission only applies with dbx_secrets_group")
+	}
+	return nil
+}
+
+// grantSecretsScope grants the dbx_secrets_group its permission on a secrets scope, if a group is configured,
+// so that rotating the credentials doesn't depend on the identity that created the scope.
+func grantSecretsScope(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, scopeName string) error {
+	if config.DbxSecretsGroup == "" {
+		return nil
+	}
+	err := client.Secrets.PutAcl(ctx, workspace.PutAcl{
+		Scope:      scopeName,
+		Principal:  config.DbxSecretsGroup,
+		Permission: secretsPermission(config),
+	})
+	if err != nil {
+		return fmt.Errorf("error granting %s on secret scope %s to %s: %w", secretsPermission(config), scopeName, config.DbxSecretsGroup, err)
+	}
+	return nil
+}
+
+// copySecretsScopeAcls grants the principals with access to one secrets scope the same access to another,
+// such as a dbx_secrets_group.
+func copySecretsScopeAcls(ctx context.Context, client *databricks.WorkspaceClient, fromScope string, toScope string) error {
+	acls, err := client.Secrets.ListAclsAll(ctx, workspace.ListAclsRequest{Scope: fromScope})
+	if err != nil {
+		return fmt.Errorf("unable to list the ACLs of secret scope %s: %w", fromScope, err)
+	}
+	for _, acl := range acls {
+		err := client.Secrets.PutAcl(ctx, workspace.PutAcl{Scope: toScope, Principal: acl.Principal, Permission: acl.Permission})
+		if err != nil {
+			return fmt.Errorf("error granting %s on secret scope %s to %s: %w", acl.Permission, toScope, acl.Principal, err)
+		}
+	}
+	return nil
+}
+
+// Secrets scope that holds the secrets of the schemas that have no scope of their own, because the workspace already
+// had as many secret scopes as it allows when they were installed. Its keys are "<catalog>.<schema>.<key>".
+// This convention must match between the Go and Python code.
+const consolidatedSecretsScope = "hl_scan"
+
+// secretsLocation is where a schema's HL secrets are stored: its own scope, or the consolidated scope.
+type secretsLocation struct {
+	Scope  string
+	prefix string // of the schema's keys in the consolidated scope
+}
+
+// key returns the key that stores the schema's secret with the given name.
+func (l secretsLocation) key(name string) string {
+	return l.prefix + name
+}
+
+// consolidated returns true if the schema's secrets are in the consolidated scope.
+func (l secretsLocation) consolidated() bool {
+	return l.prefix != ""
+}
+
+// ownSecretsLocation returns the location of a schema's secrets in its own scope.
+func ownSecretsLocation(schema utils.CatalogSchemaConfig) secretsLocation {
+	return secretsLocation{Scope: secretsScopeName(schema.Catalog, schema.Schema)}
+}
+
+// consolidatedSecretsLocation returns the location of a schema's secrets in the consolidated scope.
+func consolidatedSecretsLocation(schema utils.CatalogSchemaConfig) secretsLocation {
+	return secretsLocation{Scope: consolidatedSecretsScope, prefix: fmt.Sprintf("%s.%s.", schema.Catalog, schema.Schema)}
+}
+
+// isScopeQuotaError returns true if a secret scope couldn't be created because the workspace has as many as it allows.
+func isScopeQuotaError(err error) bool {
+	var apiErr *apierr.APIError
+	if errors.As(err, &apiErr) && (apiErr.ErrorCode == "RESOURCE_LIMIT_EXCEEDED" || apiErr.ErrorCode == "QUOTA_EXCEEDED") {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "maximum number of") || strings.Contains(message, "quota")
+}
+
+// createSchemaSecretsScope creates a schema's HL secrets scope if it doesn't already exist. If the workspace has no
+// secret scopes left, it creates the consolidated scope instead, and returns the location of the schema's secrets there.
+// Returns an error if a Databricks call fails.
+func createSchemaSecretsScope(ctx context.Context, client *databricks.WorkspaceClient, schema utils.CatalogSchemaConfig) (secretsLocation, error) {
+	location := ownSecretsLocation(schema)
+	err := client.Secrets.CreateScope(ctx, workspace.CreateScope{Scope: location.Scope})
+	if err == nil {
+		return location, nil
+	}
+	if strings.Contains(err.Error(), "already exists") {
+		return location, checkScopeBackend(ctx, client, location.Scope)
+	}
+	if !isScopeQuotaError(err) {
+		return location, fmt.Errorf("error creating secret scope %s: %w", location.Scope, err)
+	}
+	fallback := consolidatedSecretsLocation(schema)
+	utils.Printf("Unable to create secret scope %s, the workspace has as many secret scopes as it allows (at most 1000): %v\n", location.Scope, err)
+	fmt.Printf("Storing the secrets of schema %s.%s in the shared scope %s instead. "+
+		"Delete unused secret scopes, and re-run autoscan, to give it a scope of its own.\n", schema.Catalog, schema.Schema, fallback.Scope)
+	err = client.Secrets.CreateScope(ctx, workspace.CreateScope{Scope: fallback.Scope})
+	if err != nil && strings.Contains(err.Error(), "already exists") {
+		return fallback, checkScopeBackend(ctx, client, fallback.Scope)
+	} else if err != nil {
+		return fallback, fmt.Errorf("error creating secret scope %s: %w", fallback.Scope, err)
+	}
+	return fallback, nil
+}
+
+// listSchemaSecrets returns the location of a schema's HL secrets, and their metadata, from its own scope, or else
+// the consolidated scope, where the keys are returned without the schema's prefix. Returns no secrets if neither has any.
+func listSchemaSecrets(ctx context.Context, client *databricks.WorkspaceClient, schema utils.CatalogSchemaConfig) (secretsLocation, []workspace.SecretMetadata, error) {
+	location := ownSecretsLocation(schema)
+	secrets, err := client.Secrets.ListSecretsAll(ctx, workspace.ListSecretsRequest{Scope: location.Scope})
+	if err == nil {
+		return location, secrets, nil
+	}
+	if !errors.Is(err, databricks.ErrResourceDoesNotExist) && !errors.Is(err, databricks.ErrNotFound) {
+		return location, nil, fmt.Errorf("unable to list secrets in scope %s: %w", location.Scope, err)
+	}
+	location = consolidatedSecretsLocation(schema)
+	all, err := client.Secrets.ListSecretsAll(ctx, workspace.ListSecretsRequest{Scope: location.Scope})
+	if err != nil {
+		if errors.Is(err, databricks.ErrResourceDoesNotExist) || errors.Is(err, databricks.ErrNotFound) {
+			return location, nil, nil
+		}
+		return location, nil, fmt.Errorf("unable to list secrets in scope %s: %w", location.Scope, err)
+	}
+	for _, secret := range all {
+		if name, found := strings.CutPrefix(secret.Key, location.prefix); found {
+			secret.Key = name
+			secrets = append(secrets, secret)
+		}
+	}
+	return location, secrets, nil
+}
+
+// getSchemaSecret returns the value of a schema's HL secret, and the scope it is in: the schema's own scope, or else
+// the consolidated scope. Returns the error of reading the schema's own scope if neither has it.
+// This must match get_schema_secret() in hl_common.py.
+func getSchemaSecret(ctx context.Context, client *databricks.WorkspaceClient, schema utils.CatalogSchemaConfig,
+	name string) (string, string, error) {
+	location := ownSecretsLocation(schema)
+	secret, err := client.Secrets.GetSecret(ctx, workspace.GetSecretRequest{Scope: location.Scope, Key: location.key(name)})
+	if err != nil {
+		consolidated := consolidatedSecretsLocation(schema)
+		var consolidatedErr error
+		secret, consolidatedErr = client.Secrets.GetSecret(ctx, workspace.GetSecretRequest{Scope: consolidated.Scope,
+			Key: consolidated.key(name)})
+		if consolidatedErr != nil {
+			return "", location.Scope, fmt.Errorf("unable to read secret %s in scope %s: %w", name, location.Scope, err)
+		}
+		location = consolidated
+	}
+	value, err := base64.StdEncoding.DecodeString(secret.Value)
+	if err != nil {
+		return "", location.Scope, fmt.Errorf("unable to decode secret %s in scope %s: %w", name, location.Scope, err)
+	}
+	return string(value), location.Scope, nil
+}
@@ -0,0 +1,83 @@
+package dbx
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reugn/go-quartz/quartz"
+)
+
+// Range of the optional year field in Quartz, as Databricks parses it
+const (
+	quartzMinYear = 1970
+	quartzMaxYear = 2099
+)
+
+// Indexes of the day fields of a quartz cron expression
+const (
+	quartzDayOfMonthField = 3
+	quartzDayOfWeekField  = 5
+)
+
+// ValidateQuartzCron checks that a quartz cron expression is accepted by Databricks, whose job schedules use the
+// Java Quartz parser, and by go-quartz, which hldbx uses to work out when the job runs. Databricks is stricter
+// than go-quartz in ways that would otherwise only show up when the job is created or updated.
+func ValidateQuartzCron(expression string) error {
+	fields := strings.Fields(expression)
+	if len(fields) == 1 && strings.HasPrefix(fields[0], "@") {
+		return fmt.Errorf("invalid quartz cron expression %q: Databricks doesn't accept macros such as @daily", expression)
+	}
+	if len(fields) < 6 || len(fields) > 7 {
+		return fmt.Errorf("invalid quartz cron expression %q: expected 6 or 7 fields, "+
+			"<seconds> <minutes> <hours> <day of month> <month> <day of week> [<year>]", expression)
+	}
+	for i, field := range fields {
+		if strings.Contains(field, "?") && field != "?" {
+			return fmt.Errorf("invalid quartz cron expression %q: ? can't be combined with other values", expression)
+		}
+		if field == "?" && i != quartzDayOfMonthField && i != quartzDayOfWeekField {
+			return fmt.Errorf("invalid quartz cron expression %q: ? is only allowed for the day of month or the day of week", expression)
+		}
+	}
+	// Quartz doesn't combine days of the month with days of the week, one of them must be ?
+	if (fields[quartzDayOfMonthField] == "?") == (fields[quartzDayOfWeekField] == "?") {
+		return fmt.Errorf("invalid quartz cron expression %q: exactly one of the day of month and the day of week "+
+			"must be ?, e.g. \"0 0 */12 * * ?\"", expression)
+	}
+	if len(fields) == 7 {
+		if err := validateQuartzYear(fields[6]); err != nil {
+			return fmt.Errorf("invalid quartz cron expression %q: %w", expression, err)
+		}
+	}
+
+	trigger, err := quartz.NewCronTrigger(strings.Join(fields, " "))
+	if err != nil {
+		return fmt.Errorf("invalid quartz cron expression %q: %w", expression, err)
+	}
+	if _, err := trigger.NextFireTime(time.Now().UnixNano()); err != nil {
+		return fmt.Errorf("quartz cron expression %q never fires again", expression)
+	}
+	return nil
+}
+
+// validateQuartzYear checks that the years in the year field of a quartz cron expression are ones that Quartz
+// supports.
+func validateQuartzYear(field string) error {
+	for _, part := range strings.FieldsFunc(field, func(r rune) bool { return r == ',' || r == '-' }) {
+		value, _, _ := strings.Cut(part, "/")
+		if value == "*" {
+			continue
+		}
+		year, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("the year must be a number, a range, or *")
+		}
+		if year < quartzMinYear || year > quartzMaxYear {
+			return fmt.Errorf("year %d is outside %d-%d", year, quartzMinYear, quartzMaxYear)
+		}
+	}
+	return nil
+}
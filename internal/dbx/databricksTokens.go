@@ -0,0 +1,105 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/settings"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Lifetime of the on-behalf-of token that the installer mints to check that the run-as service principal can have
+// tokens. It is deleted right away.
+const oboCheckTokenLifetimeSeconds = 300
+
+// allowTokenMinting lets the run-as service principal create Databricks tokens, so that scan jobs can mint short-lived
+// tokens for scanners with databricks_token auth, instead of reading long-lived credentials from secrets. It checks
+// that tokens can be minted on behalf of the service principal, by minting one and deleting it.
+// Return an error if a Databricks call fails.
+func allowTokenMinting(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) error {
+	_, err := client.TokenManagement.UpdatePermissions(ctx, settings.TokenPermissionsRequest{
+		AccessControlList: []settings.TokenAccessControlRequest{{
+			ServicePrincipalName: config.DbxRunAs,
+			PermissionLevel:      settings.TokenPermissionLevelCanUse,
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error granting %s on tokens to service principal %s: %w", settings.TokenPermissionLevelCanUse, config.DbxRunAs, err)
+	}
+	token, err := client.TokenManagement.CreateOboToken(ctx, settings.CreateOboTokenRequest{
+		ApplicationId:   config.DbxRunAs,
+		Comment:         "hldbx check that scan jobs can mint tokens",
+		LifetimeSeconds: oboCheckTokenLifetimeSeconds,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to mint a token on behalf of service principal %s: %w", config.DbxRunAs, err)
+	}
+	if token.TokenInfo != nil {
+		if err := client.TokenManagement.DeleteByTokenId(ctx, token.TokenInfo.TokenId); err != nil {
+			slog.Warn("Unable to delete the token, it expires by itself", "token_id", token.TokenInfo.TokenId,
+				"lifetime_seconds", oboCheckTokenLifetimeSeconds, "error", err)
+		}
+	}
+	fmt.Printf("Service principal %s can mint Databricks tokens for scanners with %s auth\n", config.DbxRunAs, utils.ScannerAuthDatabricksToken)
+	return nil
+}
+
+// manualTokenMintingCommands returns the command to let the run-as service principal create Databricks tokens.
+func manualTokenMintingCommands(config *utils.Config) []string {
+	return []string{fmt.Sprintf("databricks token-management update-permissions --json "+
+		"'{\"access_control_list\": [{\"service_principal_name\": \"%s\", \"permission_level\": \"%s\"}]}'",
+		config.DbxRunAs, settings.TokenPermissionLevelCanUse)}
+}
+
+// Lifetime of the Databricks tokens that hldbx mints to call scanners with databricks_token auth, long enough for one
+// command. They are revoked once the command is done with them, and expire on their own if it fails first.
+const scannerTokenLifetimeSeconds = 3600
+
+// mintScannerToken mints a short-lived Databricks token for a scanner with databricks_token auth, of the identity
+// that the scan jobs mint theirs of: the run-as service principal, or without one, the operator, who the jobs then
+// run as. The operator's own long-lived token is never sent to a scanner. Returns the token, and a function that
+// revokes it.
+func mintScannerToken(ctx context.Context, config *utils.Config) (string, func(), error) {
+	client, err := Auth(config.DbxHost, config.DbxToken)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to authenticate to Databricks to mint a token for the scanner: %w", err)
+	}
+	const comment = "hldbx call to a scanner with databricks_token auth"
+	var token string
+	var revoke func() error
+	if config.DbxRunAs != "" {
+		created, err := client.TokenManagement.CreateOboToken(ctx, settings.CreateOboTokenRequest{
+			ApplicationId:   config.DbxRunAs,
+			Comment:         comment,
+			LifetimeSeconds: scannerTokenLifetimeSeconds,
+		})
+		if err != nil {
+			return "", nil, fmt.Errorf("unable to mint a token on behalf of service principal %s: %w", config.DbxRunAs, err)
+		}
+		token = created.TokenValue
+		if created.TokenInfo != nil {
+			revoke = func() error { return client.TokenManagement.DeleteByTokenId(ctx, created.TokenInfo.TokenId) }
+		}
+	} else {
+		created, err := client.Tokens.Create(ctx, settings.CreateTokenRequest{Comment: comment, LifetimeSeconds: scannerTokenLifetimeSeconds})
+		if err != nil {
+			return "", nil, fmt.Errorf("unable to mint a Databricks token: %w", err)
+		}
+		token = created.TokenValue
+		if created.TokenInfo != nil {
+			revoke = func() error { return client.Tokens.DeleteByTokenId(ctx, created.TokenInfo.TokenId) }
+		}
+	}
+	utils.RegisterSecret(token)
+	return token, func() {
+		if revoke == nil {
+			return
+		}
+		if err := revoke(); err != nil {
+			slog.Warn("Unable to revoke the scanner's Databricks token, it expires by itself",
+				"lifetime_seconds", scannerTokenLifetimeSeconds, "error", err)
+		}
+	}, nil
+}
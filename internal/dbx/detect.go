@@ -0,0 +1,142 @@
+package dbx
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/config"
+	"github.com/databricks/databricks-sdk-go/service/catalog"
+	"github.com/databricks/databricks-sdk-go/service/compute"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// The functions here detect what the guided setup of autoscan can offer as choices: the workspaces of the Databricks
+// CLI's profiles, the clusters that jobs can run on, and the schemas that hold models.
+
+// DbxProfile is a profile of the Databricks CLI's configuration file, ~/.databrickscfg.
+type DbxProfile struct {
+	Name     string
+	Host     string // normalized, see NormalizeHost
+	AuthType string // auth_type of the profile, or pat for a profile with a token
+}
+
+// ListProfiles returns the profiles of the Databricks CLI's configuration file, at DATABRICKS_CONFIG_FILE or
+// ~/.databrickscfg, that are for a workspace rather than an account. Returns nothing if there's no such file.
+func ListProfiles() ([]DbxProfile, error) {
+	file, err := config.LoadFile(os.Getenv("DATABRICKS_CONFIG_FILE"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read the Databricks CLI configuration: %w", err)
+	}
+	var profiles []DbxProfile
+	for _, section := range file.Sections() {
+		if !section.HasKey("host") || section.HasKey("account_id") {
+			continue
+		}
+		host, err := NormalizeHost(section.Key("host").String())
+		if err != nil {
+			continue
+		}
+		authType := section.Key("auth_type").String()
+		if authType == "" && section.HasKey("token") {
+			authType = "pat"
+		}
+		profiles = append(profiles, DbxProfile{Name: section.Name(), Host: host, AuthType: authType})
+	}
+	return profiles, nil
+}
+
+// ProfileToken returns a token for the workspace of a Databricks CLI profile, authenticating the way the CLI would,
+// e.g. refreshing the OAuth token of databricks auth login.
+func ProfileToken(ctx context.Context, profile DbxProfile) (string, error) {
+	dbxConfig := &config.Config{Profile: profile.Name, HTTPTransport: utils.CorrelationTransport(nil)}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, profile.Host, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := dbxConfig.Authenticate(request); err != nil {
+		return "", fmt.Errorf("unable to authenticate with profile %s: %w", profile.Name, err)
+	}
+	token, ok := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", fmt.Errorf("profile %s doesn't authenticate with a token", profile.Name)
+	}
+	return token, nil
+}
+
+// ReachableHosts returns the workspace URLs that respond to PingWorkspace, in their order. The workspaces are pinged
+// concurrently, so that unreachable ones don't each wait for the timeout.
+func ReachableHosts(ctx context.Context, hosts []string) []string {
+	reachable := make([]bool, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reachable[i] = PingWorkspace(ctx, host) == nil
+		}()
+	}
+	wg.Wait()
+	var result []string
+	for i, host := range hosts {
+		if reachable[i] {
+			result = append(result, host)
+		}
+	}
+	return result
+}
+
+// AttachableClusters returns the all-purpose clusters that the client's identity can see, which are the clusters
+// that it has permissions on unless it's an admin, sorted by name. Job and pipeline clusters are left out, since
+// other jobs can't run on them.
+func AttachableClusters(ctx context.Context, client *databricks.WorkspaceClient) ([]compute.ClusterDetails, error) {
+	clusters, err := client.Clusters.ListAll(ctx, compute.ListClustersRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list clusters: %w", err)
+	}
+	clusters = slices.DeleteFunc(clusters, func(cluster compute.ClusterDetails) bool {
+		return cluster.ClusterSource == compute.ClusterSourceJob || cluster.ClusterSource == compute.ClusterSourcePipeline ||
+			cluster.ClusterSource == compute.ClusterSourcePipelineMaintenance
+	})
+	slices.SortFunc(clusters, func(a, b compute.ClusterDetails) int {
+		return cmp.Compare(strings.ToLower(a.ClusterName), strings.ToLower(b.ClusterName))
+	})
+	return clusters, nil
+}
+
+// SchemaModels is a schema of Unity Catalog that holds registered models.
+type SchemaModels struct {
+	Schema utils.CatalogSchemaConfig
+	Models int
+}
+
+// SchemasWithModels returns the schemas that hold models that the client's identity can see, sorted by catalog and
+// schema.
+func SchemasWithModels(ctx context.Context, client *databricks.WorkspaceClient) ([]SchemaModels, error) {
+	models, err := client.RegisteredModels.ListAll(ctx, catalog.ListRegisteredModelsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list registered models: %w", err)
+	}
+	counts := map[utils.CatalogSchemaConfig]int{}
+	for _, model := range models {
+		counts[utils.CatalogSchemaConfig{Catalog: model.CatalogName, Schema: model.SchemaName}]++
+	}
+	var schemas []SchemaModels
+	for schema, count := range counts {
+		schemas = append(schemas, SchemaModels{Schema: schema, Models: count})
+	}
+	slices.SortFunc(schemas, func(a, b SchemaModels) int {
+		return cmp.Or(cmp.Compare(a.Schema.Catalog, b.Schema.Catalog), cmp.Compare(a.Schema.Schema, b.Schema.Schema))
+	})
+	return schemas, nil
+}
@@ -0,0 +1,267 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/compute"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/databricks/databricks-sdk-go/service/workspace"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// DoctorCheck is the outcome of a check of hldbx doctor, and how to fix it if it failed.
+type DoctorCheck struct {
+	Name        string `json:"name"`
+	Ok          bool   `json:"ok"`
+	Message     string `json:"message"`
+	Remediation string `json:"remediation,omitempty"` // only if the check failed
+}
+
+// Oldest Databricks Runtime that the notebooks run on: they import the hldbx modules from workspace files, and use
+// the Databricks SDK for Python that it ships with
+const (
+	minRuntimeMajor = 13
+	minRuntimeMinor = 3
+)
+
+// Major and minor version of a Databricks Runtime version key, e.g. 15.4.x-scala2.12
+var runtimeVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)\.`)
+
+// Workspace group whose members can do anything in the workspace
+const adminsGroup = "admins"
+
+// Permissions that let an identity update an installed job, which autoscan resets
+var jobManagePermissions = []jobs.JobPermissionLevel{jobs.JobPermissionLevelCanManage, jobs.JobPermissionLevelIsOwner}
+
+// Permissions of a workspace directory that let an identity upload files to it
+var directoryWritePermissions = []workspace.WorkspaceObjectPermissionLevel{workspace.WorkspaceObjectPermissionLevelCanEdit,
+	workspace.WorkspaceObjectPermissionLevelCanManage}
+
+// Permissions of a secrets scope that let an identity put secrets in it
+var scopeWritePermissions = []workspace.AclPermission{workspace.AclPermissionWrite, workspace.AclPermissionManage}
+
+// grantee is an identity whose permissions are checked, a user name or a service principal's application ID, with
+// the groups it belongs to, through which it may have been granted access.
+type grantee struct {
+	name   string
+	groups []string
+}
+
+// grantedTo returns true if an access control entry for the user, service principal, or group applies to the
+// identity.
+func (g *grantee) grantedTo(principalName, groupName string) bool {
+	return (principalName != "" && principalName == g.name) || (groupName != "" && slices.Contains(g.groups, groupName))
+}
+
+// CheckPermissions checks that the client's identity can do what autoscan does: update the installed jobs, put the
+// HiddenLayer credentials in the secrets scopes of the configured schemas, and write to the workspace directory. They
+// are checked with the permissions of the jobs, scopes, and directory, without changing anything. Workspace admins
+// can do all of them.
+func CheckPermissions(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) []DoctorCheck {
+	me, err := client.CurrentUser.Me(ctx)
+	if err != nil {
+		return []DoctorCheck{{Name: "permissions", Message: fmt.Sprintf("unable to get the current user: %v", err),
+			Remediation: "check that the token is of a user or service principal with workspace access"}}
+	}
+	identity := &grantee{name: me.UserName, groups: []string{allUsersGroup}}
+	for _, group := range me.Groups {
+		identity.groups = append(identity.groups, group.Display)
+	}
+	if slices.Contains(identity.groups, adminsGroup) {
+		return []DoctorCheck{{Name: "permissions", Ok: true,
+			Message: me.UserName + " is a workspace admin, which can create and update jobs, secret scopes, and files"}}
+	}
+	checks := []DoctorCheck{checkManageJobs(ctx, client, identity)}
+	checks = append(checks, checkWriteScopes(ctx, client, config, identity)...)
+	return append(checks, checkWorkspaceWrite(ctx, client, config, identity))
+}
+
+// checkManageJobs checks that the identity can manage the installed jobs. Any identity with workspace access can
+// create jobs, so there is nothing to check before the first autoscan.
+func checkManageJobs(ctx context.Context, client *databricks.WorkspaceClient, identity *grantee) DoctorCheck {
+	check := DoctorCheck{Name: "manage jobs"}
+	var denied []string
+	installed := 0
+	for _, key := range []string{monitorJobKey, guardrailJobKey, verifyJobKey, onDemandJobKey} {
+		found, err := findJobs(ctx, client, key)
+		if err != nil {
+			check.Message = err.Error()
+			check.Remediation = "check that your identity can list the workspace's jobs"
+			return check
+		}
+		for _, job := range found {
+			installed++
+			permissions, err := client.Jobs.GetPermissions(ctx, jobs.GetJobPermissionsRequest{JobId: strconv.FormatInt(job.JobId, 10)})
+			if err != nil {
+				check.Message = fmt.Sprintf("unable to get the permissions of job %d: %v", job.JobId, err)
+				check.Remediation = fmt.Sprintf("ask the owner of job %d or a workspace admin for %s on it", job.JobId,
+					jobs.JobPermissionLevelCanManage)
+				return check
+			}
+			if !slices.ContainsFunc(permissions.AccessControlList, func(acl jobs.JobAccessControlResponse) bool {
+				return identity.grantedTo(acl.UserName+acl.ServicePrincipalName, acl.GroupName) &&
+					slices.ContainsFunc(acl.AllPermissions, func(permission jobs.JobPermission) bool {
+						return slices.Contains(jobManagePermissions, permission.PermissionLevel)
+					})
+			}) {
+				denied = append(denied, strconv.FormatInt(job.JobId, 10))
+			}
+		}
+	}
+	switch {
+	case installed == 0:
+		check.Ok, check.Message = true, "no jobs are installed yet, and any identity with workspace access can create them"
+	case len(denied) > 0:
+		check.Message = fmt.Sprintf("%s can't update job(s) %s, which autoscan updates", identity.name, strings.Join(denied, ", "))
+		check.Remediation = fmt.Sprintf("ask the jobs' owner or a workspace admin for %s on them, or run hldbx as their "+
+			"owner", jobs.JobPermissionLevelCanManage)
+	default:
+		check.Ok, check.Message = true, fmt.Sprintf("the %d installed job(s) can be updated", installed)
+	}
+	return check
+}
+
+// checkWriteScopes checks that the identity can put secrets in the secrets scope of each configured schema that has
+// one. autoscan creates the scopes that don't exist yet, which every identity can unless the workspace is at its
+// limit of secret scopes, in which case it uses the shared scope.
+func checkWriteScopes(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config,
+	identity *grantee) []DoctorCheck {
+	scopes, err := client.Secrets.ListScopesAll(ctx)
+	if err != nil {
+		return []DoctorCheck{{Name: "write secret scopes", Message: fmt.Sprintf("unable to list secret scopes: %v", err),
+			Remediation: "check that your identity has workspace access"}}
+	}
+	var checks []DoctorCheck
+	var missing []string
+	for _, schema := range config.DbxSchemas {
+		scope := ownSecretsLocation(schema).Scope
+		if !slices.ContainsFunc(scopes, func(s workspace.SecretScope) bool { return s.Name == scope }) {
+			missing = append(missing, scope)
+			continue
+		}
+		check := DoctorCheck{Name: "write secret scope " + scope}
+		acls, err := client.Secrets.ListAclsAll(ctx, workspace.ListAclsRequest{Scope: scope})
+		if err != nil {
+			check.Message = fmt.Sprintf("unable to list the ACLs of the scope, which needs %s: %v", workspace.AclPermissionManage, err)
+			check.Remediation = fmt.Sprintf("ask a workspace admin for %s on scope %s", workspace.AclPermissionWrite, scope)
+			checks = append(checks, check)
+			continue
+		}
+		check.Ok = slices.ContainsFunc(acls, func(acl workspace.AclItem) bool {
+			return identity.grantedTo(acl.Principal, acl.Principal) && slices.Contains(scopeWritePermissions, acl.Permission)
+		})
+		if check.Ok {
+			check.Message = "the HiddenLayer credentials can be stored"
+		} else {
+			check.Message = fmt.Sprintf("%s can't put secrets in the scope", identity.name)
+			check.Remediation = fmt.Sprintf("databricks secrets put-acl %s %s %s", scope, identity.name, workspace.AclPermissionWrite)
+		}
+		checks = append(checks, check)
+	}
+	if len(missing) > 0 {
+		checks = append(checks, DoctorCheck{Name: "create secret scopes", Ok: true,
+			Message: fmt.Sprintf("autoscan creates %s, or stores the secrets in the shared %s scope if the workspace "+
+				"has as many secret scopes as it allows", strings.Join(missing, ", "), consolidatedSecretsScope)})
+	}
+	return checks
+}
+
+// checkWorkspaceWrite checks that the identity can write to the workspace directory of the notebooks, or to its
+// closest existing parent, where autoscan creates it.
+func checkWorkspaceWrite(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config,
+	identity *grantee) DoctorCheck {
+	dir := config.DbxWorkspaceDir
+	if dir == "" {
+		dir = defaultWorkspaceDir
+	}
+	check := DoctorCheck{Name: "write " + dir}
+	remediation := fmt.Sprintf("ask a workspace admin for %s on %s, or set dbx_workspace_dir to a directory that you "+
+		"can write to", workspace.WorkspaceObjectPermissionLevelCanManage, dir)
+	existing := dir
+	info, err := client.Workspace.GetStatusByPath(ctx, existing)
+	for isNotFound(err) && path.Dir(existing) != existing {
+		existing = path.Dir(existing)
+		info, err = client.Workspace.GetStatusByPath(ctx, existing)
+	}
+	if err != nil {
+		check.Message = fmt.Sprintf("unable to get the status of %s: %v", existing, err)
+		check.Remediation = remediation
+		return check
+	}
+	permissions, err := client.Workspace.GetPermissionsByWorkspaceObjectTypeAndWorkspaceObjectId(ctx, "directories",
+		strconv.FormatInt(info.ObjectId, 10))
+	if err != nil {
+		check.Message = fmt.Sprintf("unable to get the permissions of %s: %v", existing, err)
+		check.Remediation = remediation
+		return check
+	}
+	check.Ok = slices.ContainsFunc(permissions.AccessControlList, func(acl workspace.WorkspaceObjectAccessControlResponse) bool {
+		return identity.grantedTo(acl.UserName+acl.ServicePrincipalName, acl.GroupName) &&
+			slices.ContainsFunc(acl.AllPermissions, func(permission workspace.WorkspaceObjectPermission) bool {
+				return slices.Contains(directoryWritePermissions, permission.PermissionLevel)
+			})
+	})
+	switch {
+	case !check.Ok:
+		check.Message = fmt.Sprintf("%s can't write to %s", identity.name, existing)
+		check.Remediation = remediation
+	case existing != dir:
+		check.Message = fmt.Sprintf("autoscan can create the directory in %s, and upload the notebooks", existing)
+	default:
+		check.Message = "the notebooks can be uploaded"
+	}
+	return check
+}
+
+// CheckRuntime checks that the Databricks Runtime of the jobs' cluster, or of their job clusters, can run the
+// notebooks. Serverless compute always can.
+func CheckRuntime(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) DoctorCheck {
+	check := DoctorCheck{Name: "Databricks Runtime"}
+	var sparkVersion string
+	switch {
+	case config.DbxServerless:
+		check.Ok, check.Message = true, "serverless compute runs a supported runtime"
+		return check
+	case config.UsesJobCluster():
+		sparkVersion = config.DbxJobClusterSpark
+		check.Remediation = fmt.Sprintf("set dbx_job_cluster_spark_version to %d.%d LTS or later, e.g. with "+
+			"hldbx doctor --fix job-cluster, and re-run autoscan", minRuntimeMajor, minRuntimeMinor)
+	case config.DbxClusterId == "":
+		check.Ok, check.Message = true, "no cluster is configured"
+		return check
+	default:
+		cluster, err := client.Clusters.Get(ctx, compute.GetClusterRequest{ClusterId: config.DbxClusterId})
+		if err != nil {
+			// Reported by the compute check
+			check.Ok, check.Message = true, fmt.Sprintf("unable to get cluster %s: %v", config.DbxClusterId, err)
+			return check
+		}
+		sparkVersion = cluster.SparkVersion
+		check.Remediation = fmt.Sprintf("upgrade cluster %s to Databricks Runtime %d.%d LTS or later, or move the "+
+			"jobs with hldbx doctor --fix cluster, job-cluster, or serverless", config.DbxClusterId, minRuntimeMajor,
+			minRuntimeMinor)
+	}
+	match := runtimeVersionPattern.FindStringSubmatch(sparkVersion)
+	if match == nil {
+		check.Ok, check.Remediation = true, ""
+		check.Message = fmt.Sprintf("unable to tell the version of runtime %q, it must be %d.%d or later", sparkVersion,
+			minRuntimeMajor, minRuntimeMinor)
+		return check
+	}
+	major, _ := strconv.Atoi(match[1])
+	minor, _ := strconv.Atoi(match[2])
+	if major < minRuntimeMajor || major == minRuntimeMajor && minor < minRuntimeMinor {
+		check.Message = fmt.Sprintf("%s is older than %d.%d, which the notebooks need", sparkVersion, minRuntimeMajor,
+			minRuntimeMinor)
+		return check
+	}
+	check.Ok, check.Remediation, check.Message = true, "", sparkVersion+" is supported"
+	return check
+}
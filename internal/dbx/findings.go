@@ -0,0 +1,102 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/workspace"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Name of the secret, in each schema's HL secrets scope, that holds the Event Hub key as "<SAS policy name>:<SAS key>",
+// or the webhook signing secret. This convention must match hl_sinks.py.
+const findingsSinkKeyName = "hl_findings_sink_key"
+
+// URI prefixes of the sinks that the scan notebook can export detections to. This must match hl_sinks.py.
+var findingsSinkPrefixes = []string{"s3://", "abfss://", "gs://", "/Volumes/", "eventhub://", "https://"}
+
+// Shortest webhook signing secret, so that signatures can't be forged by guessing it
+const minWebhookSecretLength = 32
+
+// ValidateFindingsSink checks the URI of the sink that detections are exported to, in OCSF format.
+func ValidateFindingsSink(config *utils.Config) error {
+	sink := config.DbxFindingsSink
+	if sink == "" {
+		return nil
+	}
+	supported := false
+	for _, prefix := range findingsSinkPrefixes {
+		supported = supported || strings.HasPrefix(sink, prefix)
+	}
+	if !supported {
+		return fmt.Errorf("unsupported findings sink %q, expected a URI starting with one of %s",
+			sink, strings.Join(findingsSinkPrefixes, ", "))
+	}
+	if config.UsesWebhookFindingsSink() {
+		if webhook, err := url.Parse(sink); err != nil || webhook.Host == "" {
+			return fmt.Errorf("invalid findings sink %q, expected https://<host>/<path> of a webhook", sink)
+		}
+		if len(config.DbxFindingsSinkKey) < minWebhookSecretLength {
+			return fmt.Errorf("a webhook findings sink needs dbx_findings_sink_key set to a signing secret of at least %d characters",
+				minWebhookSecretLength)
+		}
+		return nil
+	}
+	if !config.UsesEventHubFindingsSink() {
+		return nil
+	}
+	hub, err := url.Parse(sink)
+	if err != nil || hub.Host == "" || strings.Trim(hub.Path, "/") == "" {
+		return fmt.Errorf("invalid findings sink %q, expected eventhub://<namespace>.servicebus.windows.net/<event hub>", sink)
+	}
+	if policy, key, found := strings.Cut(config.DbxFindingsSinkKey, ":"); !found || policy == "" || key == "" {
+		return fmt.Errorf("an Event Hub findings sink needs dbx_findings_sink_key set to \"<SAS policy name>:<SAS key>\"")
+	}
+	return nil
+}
+
+// storeFindingsSinkKey stores the Event Hub key with the HL secrets of each schema, where the scan notebook reads it.
+// Returns the scopes that hold the key, or an error if a Databricks call fails.
+func storeFindingsSinkKey(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) ([]string, error) {
+	var scopes []string
+	for _, schema := range config.DbxSchemas {
+		location, err := createSchemaSecretsScope(ctx, client, schema)
+		if err != nil {
+			return scopes, err
+		}
+		scopes = append(scopes, location.Scope)
+		if err := grantSecretsScope(ctx, client, config, location.Scope); err != nil {
+			return scopes, err
+		}
+		err = client.Secrets.PutSecret(ctx, workspace.PutSecret{
+			Scope:       location.Scope,
+			Key:         location.key(findingsSinkKeyName),
+			StringValue: config.DbxFindingsSinkKey,
+		})
+		if err != nil {
+			return scopes, fmt.Errorf("error creating secret %s in scope %s: %w", location.key(findingsSinkKeyName), location.Scope, err)
+		}
+	}
+	return scopes, nil
+}
+
+// manualFindingsSinkKeyCommands returns the commands to store the Event Hub key or webhook signing secret for each
+// schema. The key is left as a placeholder, so it is never printed.
+func manualFindingsSinkKeyCommands(config *utils.Config) []string {
+	placeholder := "<sas_policy_name>:<sas_key>"
+	if config.UsesWebhookFindingsSink() {
+		placeholder = "<signing_secret>"
+	}
+	var commands []string
+	for _, schema := range config.DbxSchemas {
+		scopeName := secretsScopeName(schema.Catalog, schema.Schema)
+		commands = append(commands,
+			fmt.Sprintf("databricks secrets create-scope %s", scopeName),
+			fmt.Sprintf("databricks secrets put-secret %s %s --string-value \"%s\"", scopeName, findingsSinkKeyName, placeholder))
+		commands = append(commands, manualSecretsAclCommands(config, scopeName)...)
+	}
+	return commands
+}
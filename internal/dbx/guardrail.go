@@ -0,0 +1,125 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/databricks/databricks-sdk-go/service/serving"
+	"github.com/google/uuid"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Name of the guardrail notebook, which is also the default name of the job that runs it.
+// The job fails if a model version has not passed a HiddenLayer scan, so deployment pipelines can run it
+// before updating a Model Serving endpoint.
+const guardrailNotebookName = "hl_check_model_version"
+
+// ServingCoverage describes a Model Serving endpoint, and whether it serves models from the monitored schemas.
+type ServingCoverage struct {
+	Endpoint string
+	Models   []string // served UC models, as <catalog>.<schema>.<model_name>@<version>
+	Covered  bool
+}
+
+// CheckServingCoverage lists the Model Serving endpoints in the workspace, and reports which of them serve
+// models from the monitored schemas and so are covered by the guardrail.
+func CheckServingCoverage(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) ([]ServingCoverage, error) {
+	monitored := map[string]bool{}
+	for _, schema := range config.DbxSchemas {
+		monitored[fmt.Sprintf("%s.%s", schema.Catalog, schema.Schema)] = true
+	}
+
+	endpoints, err := client.ServingEndpoints.ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list Model Serving endpoints: %w", err)
+	}
+	var coverage []ServingCoverage
+	for _, endpoint := range endpoints {
+		entry := ServingCoverage{Endpoint: endpoint.Name, Models: servedModelVersions(endpoint)}
+		for _, model := range entry.Models {
+			name, _, _ := strings.Cut(model, "@")
+			if monitored[name[:strings.LastIndex(name, ".")]] {
+				entry.Covered = true
+			}
+		}
+		coverage = append(coverage, entry)
+	}
+	return coverage, nil
+}
+
+// servedModelVersions returns the UC model versions that an endpoint serves, as <catalog>.<schema>.<model_name>@<version>.
+func servedModelVersions(endpoint serving.ServingEndpoint) []string {
+	if endpoint.Config == nil {
+		return nil
+	}
+	var models []string
+	for _, entity := range endpoint.Config.ServedEntities {
+		// UC model names are <catalog>.<schema>.<model_name>; external and foundation models have no version
+		if !strings.Contains(entity.EntityName, ".") || entity.EntityVersion == "" {
+			continue
+		}
+		models = append(models, fmt.Sprintf("%s@%s", entity.EntityName, entity.EntityVersion))
+	}
+	return models
+}
+
+// guardrailJobSettings returns the settings of the guardrail job. It has no schedule, deployment pipelines run it.
+func guardrailJobSettings(config *utils.Config) jobs.CreateJob {
+	notebookPath := fmt.Sprintf("%s/%s", getHLWorkspaceDirectory(config), guardrailNotebookName)
+	createJob := jobs.CreateJob{Name: jobName(config, guardrailJobKey),
+		Description: jobDescription(config),
+		Tags:        jobTags(guardrailJobKey),
+		Tasks: []jobs.Task{{
+			Description:       "Fail if a model version has not passed a HiddenLayer scan",
+			ExistingClusterId: taskClusterId(config),
+			JobClusterKey:     taskJobClusterKey(config),
+			TaskKey:           uuid.New().String(),
+			NotebookTask:      &jobs.NotebookTask{NotebookPath: notebookPath},
+		}},
+		Parameters: []jobs.JobParameterDefinition{
+			{Name: "full_model_name", Default: ""},
+			{Name: "model_version_num", Default: ""},
+		},
+		JobClusters:    jobClusters(config),
+		BudgetPolicyId: taskBudgetPolicyId(config),
+	}
+	if config.DbxRunAs != "" {
+		createJob.RunAs = &jobs.JobRunAs{ServicePrincipalName: config.DbxRunAs}
+	}
+	return createJob
+}
+
+// setUpServingGuardrail creates the guardrail job, then reports which Model Serving endpoints it covers.
+// Return the job ID, or an error if the job can't be created.
+func setUpServingGuardrail(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) (int64, error) {
+	jobId, _, err := createOrResetJob(ctx, client, guardrailJobSettings(config))
+	if err != nil {
+		return 0, fmt.Errorf("error creating serving guardrail job: %w", err)
+	}
+	fmt.Printf("Serving guardrail job ID: %d\n", jobId)
+	fmt.Println("Run it from your deployment pipeline before updating an endpoint, e.g.")
+	fmt.Printf("  databricks jobs run-now %d --json '{\"job_parameters\": {\"full_model_name\": \"<catalog>.<schema>.<model>\", \"model_version_num\": \"<version>\"}}'\n", jobId)
+
+	coverage, err := CheckServingCoverage(ctx, client, config)
+	if err != nil {
+		slog.Warn("Unable to report serving endpoint coverage", "error", err)
+		return jobId, nil
+	}
+	if len(coverage) == 0 {
+		fmt.Println("No Model Serving endpoints found")
+		return jobId, nil
+	}
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ENDPOINT\tCOVERED\tSERVED MODELS")
+	for _, entry := range coverage {
+		fmt.Fprintf(table, "%s\t%t\t%s\n", entry.Endpoint, entry.Covered, strings.Join(entry.Models, ", "))
+	}
+	table.Flush()
+	return jobId, nil
+}
@@ -0,0 +1,164 @@
+package dbx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/reugn/go-quartz/quartz"
+)
+
+// Name of the notebook that records a heartbeat for each run of the monitoring job
+const heartbeatNotebookName = "hl_heartbeat"
+
+// Task keys of the monitoring job. These must match hl_heartbeat.py.
+const (
+	monitorTaskKey   = "monitor"
+	heartbeatTaskKey = "heartbeat"
+)
+
+// Number of recent monitoring job runs to look through for the latest heartbeat
+const heartbeatRecentRuns = 10
+
+// Heartbeat is the record of a monitoring job run, written by the heartbeat task. This must match hl_heartbeat.py.
+type Heartbeat struct {
+	RunId           string    `json:"run_id"`
+	HeartbeatAt     time.Time `json:"heartbeat_at"`
+	DurationSeconds int       `json:"duration_seconds"`
+	VersionsFound   int       `json:"versions_found"`
+	ScansStarted    int       `json:"scans_started"`
+	MonitorStatus   string    `json:"monitor_status"`
+}
+
+// HeartbeatStatus says whether the monitoring job is running on schedule.
+type HeartbeatStatus struct {
+	Last     *Heartbeat    // the latest heartbeat, or nil if there is none
+	Interval time.Duration // the interval between scheduled runs
+	Paused   bool          // the job's schedule is paused
+	Missing  bool          // no heartbeat for longer than the allowed number of scheduled runs
+}
+
+// Problem returns a description of what's wrong with the monitoring job's heartbeats, or "" if nothing is.
+func (s HeartbeatStatus) Problem() string {
+	switch {
+	case s.Paused:
+		return "the monitoring job's schedule is paused, so new model versions aren't being scanned"
+	case s.Missing && s.Last == nil:
+		return "the monitoring job has never recorded a heartbeat, check that it is running"
+	case s.Missing:
+		return fmt.Sprintf("the monitoring job's last heartbeat was %s ago, but it is scheduled every %s; "+
+			"check that it is running", time.Since(s.Last.HeartbeatAt).Round(time.Minute), s.Interval)
+	case s.Last != nil && s.Last.MonitorStatus != "ok":
+		return fmt.Sprintf("the monitoring task failed in run %s", s.Last.RunId)
+	}
+	return ""
+}
+
+// CheckHeartbeat finds the latest heartbeat of the monitoring job, and whether heartbeats are missing for longer
+// than the configured number of scheduled runs. This catches jobs that are paused or broken without anyone noticing.
+func CheckHeartbeat(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) (HeartbeatStatus, error) {
+	var status HeartbeatStatus
+	job, err := monitorJob(ctx, client)
+	if err != nil {
+		return status, err
+	}
+	schedule := job.Settings.Schedule
+	if schedule == nil {
+		return status, fmt.Errorf("job %d has no schedule", job.JobId)
+	}
+	status.Paused = schedule.PauseStatus == jobs.PauseStatusPaused
+	status.Interval, err = scheduleInterval(schedule.QuartzCronExpression)
+	if err != nil {
+		return status, err
+	}
+
+	status.Last, err = latestHeartbeat(ctx, client, job.JobId)
+	if err != nil {
+		return status, err
+	}
+	allowed := time.Duration(config.HeartbeatMaxMissed()) * status.Interval
+	if status.Last != nil {
+		status.Missing = time.Since(status.Last.HeartbeatAt) > allowed
+	} else {
+		// A new job hasn't had the chance to record a heartbeat yet
+		status.Missing = time.Since(time.UnixMilli(job.CreatedTime)) > allowed
+	}
+	return status, nil
+}
+
+// HeartbeatCheck checks that the monitoring job records heartbeats on schedule, and that its latest run's monitoring
+// task succeeded.
+func HeartbeatCheck(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) DoctorCheck {
+	check := DoctorCheck{Name: "heartbeats"}
+	status, err := CheckHeartbeat(ctx, client, config)
+	switch {
+	case err != nil:
+		check.Message = fmt.Sprintf("unable to check the monitoring job's heartbeats: %v", err)
+		check.Remediation = "run hldbx autoscan to install the monitoring job with its schedule"
+	case status.Paused:
+		check.Message = status.Problem()
+		check.Remediation = "run hldbx resume to resume it"
+	case status.Problem() != "":
+		check.Message = status.Problem()
+		check.Remediation = "see why its latest runs failed or didn't start with hldbx run history"
+	case status.Last != nil:
+		check.Ok = true
+		check.Message = fmt.Sprintf("the monitoring job's last heartbeat was at %s",
+			status.Last.HeartbeatAt.Format(time.RFC3339))
+	default:
+		check.Ok = true
+		check.Message = "the monitoring job hasn't had a scheduled run to record a heartbeat yet"
+	}
+	return check
+}
+
+// latestHeartbeat returns the heartbeat of the latest completed run of the monitoring job that recorded one.
+// The heartbeat task returns it as its notebook output.
+func latestHeartbeat(ctx context.Context, client *databricks.WorkspaceClient, jobId int64) (*Heartbeat, error) {
+	runs := client.Jobs.ListRuns(ctx, jobs.ListRunsRequest{JobId: jobId, CompletedOnly: true, ExpandTasks: true, Limit: heartbeatRecentRuns})
+	for i := 0; i < heartbeatRecentRuns && runs.HasNext(ctx); i++ {
+		run, err := runs.Next(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list runs of job %d: %w", jobId, err)
+		}
+		for _, task := range run.Tasks {
+			if task.TaskKey != heartbeatTaskKey {
+				continue
+			}
+			output, err := client.Jobs.GetRunOutput(ctx, jobs.GetRunOutputRequest{RunId: task.RunId})
+			if err != nil {
+				return nil, fmt.Errorf("unable to get output of run %d: %w", task.RunId, err)
+			}
+			if output.NotebookOutput == nil || output.NotebookOutput.Result == "" {
+				continue
+			}
+			var heartbeat Heartbeat
+			if err := json.Unmarshal([]byte(output.NotebookOutput.Result), &heartbeat); err != nil {
+				return nil, fmt.Errorf("unable to parse heartbeat of run %d: %w", run.RunId, err)
+			}
+			return &heartbeat, nil
+		}
+	}
+	return nil, nil
+}
+
+// scheduleInterval returns the time between the next two runs of a quartz cron schedule.
+func scheduleInterval(quartzCron string) (time.Duration, error) {
+	trigger, err := quartz.NewCronTrigger(quartzCron)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quartz cron expression %q: %w", quartzCron, err)
+	}
+	next, err := trigger.NextFireTime(time.Now().UnixNano())
+	if err != nil {
+		return 0, err
+	}
+	after, err := trigger.NextFireTime(next)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(after - next), nil
+}
@@ -0,0 +1,200 @@
+package dbx
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/hl"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Tags of failed or deferred scans, which an imported scan result replaces
+var staleScanTags = []string{hlScanMessageTag, hlScanQuarantineTag, hlScanRunIdTag}
+
+// Outcomes of importing the scan result of a model version from HiddenLayer
+const (
+	ImportImported = "imported"
+	ImportSkipped  = "skipped"   // already scanned, or being scanned
+	ImportNotFound = "not_found" // HiddenLayer has no finished scan of the model version
+	ImportFailed   = "failed"
+)
+
+// ImportProgress counts the model versions whose scan results were imported, and those that weren't.
+type ImportProgress struct {
+	Total    int
+	Imported int
+	Skipped  int
+	NotFound int
+	Failed   int
+	Last     string // the model version that finished last, as <catalog>.<schema>.<model>/<version>
+	LastErr  error  // why it failed, if it did
+}
+
+// Finished returns how many model versions were looked up.
+func (p ImportProgress) Finished() int {
+	return p.Imported + p.Skipped + p.NotFound + p.Failed
+}
+
+// scannerClient calls the API of one scanner, authenticated once for all the model versions that it scanned.
+type scannerClient struct {
+	scanner     utils.ScannerConfig
+	accessToken string
+	revoke      func() // revokes the access token
+}
+
+// ImportScanResults tags every version of every model in the monitored schemas that hasn't been scanned with the
+// result of its latest finished HiddenLayer scan, if it has one, e.g. from another integration that scanned it
+// before hldbx was adopted. The monitoring job then treats it as scanned, without rescanning it. Results are looked
+// up in the scanner of each model's schema, by the model name and version; dryRun only looks them up.
+// onProgress is called each time a model version finishes.
+func ImportScanResults(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, dryRun bool,
+	onProgress func(ImportProgress)) (ImportProgress, error) {
+	var progress ImportProgress
+	tlsConfig, err := hl.TLSConfig(config.HlTlsMinVersion, config.HlTlsPins)
+	if err != nil {
+		return progress, err
+	}
+	httpClient := hl.NewHttpClient(tlsConfig)
+	versions, err := listAllModelVersions(ctx, client, config)
+	if err != nil {
+		return progress, err
+	}
+	progress.Total = len(versions)
+
+	scanners := map[string]*scannerClient{}
+	defer func() {
+		for _, scanner := range scanners {
+			scanner.revoke()
+		}
+	}()
+	for _, version := range versions {
+		if ctx.Err() != nil {
+			return progress, ctx.Err()
+		}
+		scanner, err := scannerClientFor(ctx, config, scanners, version.Model, tlsConfig)
+		outcome := ImportFailed
+		if err == nil {
+			outcome, err = importScanResult(ctx, client, httpClient, scanner, version, dryRun)
+		}
+		switch outcome {
+		case ImportImported:
+			progress.Imported++
+		case ImportSkipped:
+			progress.Skipped++
+		case ImportNotFound:
+			progress.NotFound++
+		default:
+			progress.Failed++
+		}
+		progress.Last = version.key()
+		progress.LastErr = err
+		if onProgress != nil {
+			onProgress(progress)
+		}
+	}
+	return progress, nil
+}
+
+// scannerClientFor returns the client of the scanner of a model, authenticating to the scanner the first time.
+func scannerClientFor(ctx context.Context, config *utils.Config, scanners map[string]*scannerClient, fullName string,
+	tlsConfig *tls.Config) (*scannerClient, error) {
+	scanner := config.ModelScanner(fullName)
+	if client, ok := scanners[scanner.Name]; ok {
+		return client, nil
+	}
+	accessToken, revoke, err := scannerAccessToken(ctx, config, scanner, tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to authenticate to scanner %s: %w", scanner.Name, err)
+	}
+	scanners[scanner.Name] = &scannerClient{scanner: scanner, accessToken: accessToken, revoke: revoke}
+	return scanners[scanner.Name], nil
+}
+
+// hlModelNames returns the names that a model may have been scanned under. hldbx puts the model name first, see
+// _reverse_full_model_name() in hl_scan_model.py; other integrations may use the Unity Catalog full name.
+func hlModelNames(fullName string) []string {
+	parts := strings.Split(fullName, ".")
+	if len(parts) != 3 {
+		return []string{fullName}
+	}
+	return []string{fmt.Sprintf("%s.%s.%s", parts[2], parts[1], parts[0]), fullName}
+}
+
+// foundScanUrl returns the console URL of a scan found in the scanner's API, the same as the scan notebook records,
+// or "" if the scanner has no console or doesn't report the model ID.
+func foundScanUrl(scanner utils.ScannerConfig, found hl.FoundScan) string {
+	if scanner.ConsoleUrl == "" || found.ModelId == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/model-details/%s/scans/%s", scanner.ConsoleUrl, found.ModelId, found.ScanId)
+}
+
+// importScanResult tags a model version that hasn't been scanned with the result of its latest finished scan.
+// Returns the outcome, and the error of a failed import.
+func importScanResult(ctx context.Context, client *databricks.WorkspaceClient, httpClient *http.Client,
+	scanner *scannerClient, version backfillVersion, dryRun bool) (string, error) {
+	tags, err := getModelVersionTags(ctx, client, version.Model, version.Version)
+	if err != nil {
+		return ImportFailed, err
+	}
+	if status := tags[hlScanStatusTag]; status == scanStatusDone || status == scanStatusPending {
+		return ImportSkipped, nil
+	}
+	var found hl.FoundScan
+	ok := false
+	for _, name := range hlModelNames(version.Model) {
+		found, ok, err = hl.FindLatestScan(httpClient, scanner.scanner.ApiUrl, scanner.accessToken, name,
+			strconv.Itoa(version.Version))
+		if err != nil {
+			return ImportFailed, err
+		}
+		if ok {
+			break
+		}
+	}
+	if !ok {
+		return ImportNotFound, nil
+	}
+	if dryRun {
+		return ImportImported, nil
+	}
+
+	for _, key := range staleScanTags {
+		if _, set := tags[key]; set {
+			if err := deleteModelVersionTag(ctx, client, version.Model, version.Version, key); err != nil {
+				return ImportFailed, err
+			}
+		}
+	}
+	updatedAt := found.UpdatedAt
+	if updatedAt == "" {
+		updatedAt = time.Now().UTC().Format(time.RFC3339)
+	}
+	resultTags := [][2]string{
+		{hlScanIdTag, found.ScanId},
+		{hlScanThreatLevelTag, found.ThreatLevel},
+		{hlScanUpdatedAtTag, updatedAt},
+		{hlScanVersionTag, found.ScannerVersion},
+		{hlScanRulesTag, strings.Join(found.Rules, ",")},
+	}
+	if scanUrl := foundScanUrl(scanner.scanner, found); scanUrl != "" {
+		resultTags = append(resultTags, [2]string{hlScanUrlTag, scanUrl})
+	}
+	// The status goes last, so that a model version is only marked scanned once the rest of its result is recorded
+	resultTags = append(resultTags, [2]string{hlScanStatusTag, scanStatusDone})
+	for _, tag := range resultTags {
+		if tag[1] == "" {
+			continue
+		}
+		if err := setModelVersionTag(ctx, client, version.Model, version.Version, tag[0], tag[1]); err != nil {
+			return ImportFailed, err
+		}
+	}
+	return ImportImported, nil
+}
@@ -0,0 +1,97 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+)
+
+// TaskLog is the output of a task of a monitoring job run. The Jobs API only has the output of a task once it ended.
+type TaskLog struct {
+	TaskKey    string `json:"task_key"`
+	RunId      int64  `json:"run_id"`
+	State      string `json:"state"`
+	Ended      bool   `json:"ended"`
+	Result     string `json:"result,omitempty"`      // the value the notebook exited with
+	Error      string `json:"error,omitempty"`       // why the task failed
+	ErrorTrace string `json:"error_trace,omitempty"` // the stack trace of the error
+	Logs       string `json:"logs,omitempty"`        // stdout and stderr, of the tasks that aren't notebooks
+	Truncated  bool   `json:"truncated,omitempty"`   // the result or logs were cut short by the Jobs API
+}
+
+// RunLogs is the output of the tasks of a monitoring job run, in the order of the job's tasks.
+type RunLogs struct {
+	JobId int64     `json:"job_id"`
+	RunId int64     `json:"run_id"`
+	State string    `json:"state"`
+	Ended bool      `json:"ended"`
+	Url   string    `json:"url,omitempty"`
+	Tasks []TaskLog `json:"tasks"`
+}
+
+// GetRunLogs returns the output of a run of the monitoring job, or of its latest run if runId is 0, whether it ended
+// or not. A task that hasn't ended has no output yet.
+func GetRunLogs(ctx context.Context, client *databricks.WorkspaceClient, runId int64) (*RunLogs, error) {
+	monitorJobs, err := findMonitorJobs(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if len(monitorJobs) == 0 {
+		return nil, fmt.Errorf("no model monitoring job, run hldbx autoscan to create it")
+	}
+	jobId := monitorJobs[0].JobId
+	if runId == 0 {
+		runs := client.Jobs.ListRuns(ctx, jobs.ListRunsRequest{JobId: jobId, Limit: 1})
+		if !runs.HasNext(ctx) {
+			return nil, fmt.Errorf("the monitoring job %d hasn't run yet, run hldbx run-now to run it", jobId)
+		}
+		latest, err := runs.Next(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list runs of job %d: %w", jobId, err)
+		}
+		runId = latest.RunId
+	}
+	run, err := client.Jobs.GetRun(ctx, jobs.GetRunRequest{RunId: runId})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get run %d: %w", runId, err)
+	}
+	if run.JobId != jobId {
+		return nil, fmt.Errorf("run %d is a run of job %d, not of the monitoring job %d", runId, run.JobId, jobId)
+	}
+
+	logs := &RunLogs{
+		JobId: jobId,
+		RunId: run.RunId,
+		State: runState(run.State),
+		Ended: runEnded(run.State),
+		Url:   run.RunPageUrl,
+		Tasks: []TaskLog{},
+	}
+	for _, task := range run.Tasks {
+		taskLog := TaskLog{TaskKey: task.TaskKey, RunId: task.RunId, State: runState(task.State), Ended: runEnded(task.State)}
+		if taskLog.Ended {
+			output, err := client.Jobs.GetRunOutputByRunId(ctx, task.RunId)
+			if err != nil {
+				return nil, fmt.Errorf("unable to get the output of task %s of run %d: %w", task.TaskKey, run.RunId, err)
+			}
+			taskLog.Error = output.Error
+			taskLog.ErrorTrace = output.ErrorTrace
+			taskLog.Logs = output.Logs
+			taskLog.Truncated = output.LogsTruncated
+			if output.NotebookOutput != nil {
+				taskLog.Result = output.NotebookOutput.Result
+				taskLog.Truncated = taskLog.Truncated || output.NotebookOutput.Truncated
+			}
+		}
+		logs.Tasks = append(logs.Tasks, taskLog)
+	}
+	return logs, nil
+}
+
+// runEnded returns true if a run, or a task run, has ended.
+func runEnded(state *jobs.RunState) bool {
+	return state != nil && slices.Contains(endedLifeCycleStates, state.LifeCycleState)
+}
@@ -0,0 +1,283 @@
+package dbx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Kind of the scan jobs that the monitoring job creates, and the value of their hlJobTag tag. The other jobs' kinds
+// are their keys.
+const scanJobKind = "scan"
+
+// ManagedJob is a job that hldbx created, or that the monitoring job created to scan a model version.
+type ManagedJob struct {
+	JobId    int64       `json:"job_id"`
+	Name     string      `json:"name"`
+	Kind     string      `json:"kind"`               // monitor, guardrail, verify, on_demand, or scan
+	Schedule string      `json:"schedule,omitempty"` // Quartz cron expression, empty for jobs without a schedule
+	Timezone string      `json:"timezone,omitempty"`
+	Paused   bool        `json:"paused,omitempty"`
+	RunAs    string      `json:"run_as,omitempty"`  // user name, or service principal application ID
+	Schemas  []string    `json:"schemas,omitempty"` // monitored schemas, as <catalog>.<schema>
+	Creator  string      `json:"creator,omitempty"`
+	LastRun  *RunSummary `json:"last_run,omitempty"`
+}
+
+// IsMonitor returns true for the monitoring job, without which new model versions aren't scanned.
+func (m ManagedJob) IsMonitor() bool {
+	return m.Kind == monitorJobKey
+}
+
+// ListManagedJobs returns the jobs that hldbx created, found by their tag, or by their default names for those
+// created before jobs were tagged, and with includeScanJobs, the scan jobs that the monitoring job created, found by
+// their tag. The monitoring job creates a scan job for each model version it scans, so there can be many.
+func ListManagedJobs(ctx context.Context, client *databricks.WorkspaceClient, includeScanJobs bool) ([]ManagedJob, error) {
+	all, err := client.Jobs.ListAll(ctx, jobs.ListJobsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list jobs: %w", err)
+	}
+	var managed []ManagedJob
+	for _, job := range all {
+		kind := managedJobKind(job.Settings, includeScanJobs)
+		if kind == "" {
+			continue
+		}
+		entry, err := newManagedJob(ctx, client, job, kind)
+		if err != nil {
+			return nil, err
+		}
+		if entry.LastRun, err = latestRun(ctx, client, job.JobId); err != nil {
+			return nil, err
+		}
+		managed = append(managed, entry)
+	}
+	return managed, nil
+}
+
+// managedJobKind returns the kind of a job that hldbx manages, by its tag, or its default name, skipping scan jobs
+// unless includeScanJobs. A scan job is only managed with its tag, since anyone can name a job like one. Returns ""
+// for other jobs.
+func managedJobKind(settings *jobs.JobSettings, includeScanJobs bool) string {
+	if settings == nil {
+		return ""
+	}
+	if kind, tagged := settings.Tags[hlJobTag]; tagged {
+		if kind == scanJobKind && !includeScanJobs {
+			return ""
+		}
+		return kind
+	}
+	for key, name := range defaultJobNames {
+		if settings.Name == name {
+			return key
+		}
+	}
+	return ""
+}
+
+// newManagedJob describes a job of the kind, without its latest run.
+func newManagedJob(ctx context.Context, client *databricks.WorkspaceClient, job jobs.BaseJob, kind string) (ManagedJob, error) {
+	entry := ManagedJob{JobId: job.JobId, Name: job.Settings.Name, Kind: kind, Creator: job.CreatorUserName}
+	if kind == scanJobKind {
+		// Named hl_scan_<catalog>.<schema>.<model>.<version>, see hl_monitor_models.py
+		if parts := strings.Split(strings.TrimPrefix(job.Settings.Name, scanJobNamePrefix), "."); len(parts) >= 3 {
+			entry.Schemas = []string{parts[0] + "." + parts[1]}
+		}
+	} else if err := entry.addSettings(ctx, client); err != nil {
+		return ManagedJob{}, err
+	}
+	return entry, nil
+}
+
+// addSettings adds the schedule, identity, and monitored schemas of the job, which only getting the job returns.
+func (m *ManagedJob) addSettings(ctx context.Context, client *databricks.WorkspaceClient) error {
+	job, err := client.Jobs.GetByJobId(ctx, m.JobId)
+	if err != nil {
+		return fmt.Errorf("unable to get job %d: %w", m.JobId, err)
+	}
+	m.RunAs = job.RunAsUserName
+	if job.Settings == nil {
+		return nil
+	}
+	if schedule := job.Settings.Schedule; schedule != nil {
+		m.Schedule, m.Timezone = schedule.QuartzCronExpression, schedule.TimezoneId
+		m.Paused = schedule.PauseStatus == jobs.PauseStatusPaused
+	}
+	if runAs := job.Settings.RunAs; m.RunAs == "" && runAs != nil {
+		m.RunAs = runAs.ServicePrincipalName
+		if m.RunAs == "" {
+			m.RunAs = runAs.UserName
+		}
+	}
+	for _, param := range job.Settings.Parameters {
+		if param.Name != "schemas" {
+			continue
+		}
+		var schemas []utils.CatalogSchemaConfig
+		if err := json.Unmarshal([]byte(param.Default), &schemas); err != nil {
+			return fmt.Errorf("invalid schemas parameter of job %d: %w", m.JobId, err)
+		}
+		for _, schema := range schemas {
+			m.Schemas = append(m.Schemas, schema.Catalog+"."+schema.Schema)
+		}
+	}
+	return nil
+}
+
+// FindManagedJob returns the job with the ID or name, including scan jobs. Returns an error if there is no such job,
+// if several jobs have the name, or if hldbx doesn't manage the job, so that other jobs aren't deleted by mistake.
+func FindManagedJob(ctx context.Context, client *databricks.WorkspaceClient, idOrName string) (*ManagedJob, error) {
+	var found []jobs.BaseJob
+	if jobId, err := strconv.ParseInt(idOrName, 10, 64); err == nil {
+		job, err := client.Jobs.GetByJobId(ctx, jobId)
+		if errors.Is(err, databricks.ErrResourceDoesNotExist) || errors.Is(err, databricks.ErrNotFound) {
+			return nil, fmt.Errorf("no job with ID %d", jobId)
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to get job %d: %w", jobId, err)
+		}
+		found = append(found, jobs.BaseJob{JobId: job.JobId, CreatorUserName: job.CreatorUserName, Settings: job.Settings})
+	} else {
+		all, err := client.Jobs.ListAll(ctx, jobs.ListJobsRequest{Name: idOrName})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list jobs: %w", err)
+		}
+		for _, job := range all {
+			if job.Settings != nil && job.Settings.Name == idOrName {
+				found = append(found, job)
+			}
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, fmt.Errorf("no job named %s", idOrName)
+	case 1:
+	default:
+		return nil, fmt.Errorf("%d jobs are named %s, pass the ID of the one to delete", len(found), idOrName)
+	}
+	kind := managedJobKind(found[0].Settings, true)
+	if kind == "" {
+		return nil, fmt.Errorf("job %d isn't managed by hldbx: it has no %s tag, nor the default name of a job that "+
+			"hldbx creates", found[0].JobId, hlJobTag)
+	}
+	job, err := newManagedJob(ctx, client, found[0], kind)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// How long DeleteManagedJob waits for each canceled run to end
+const cancelRunTimeout = 5 * time.Minute
+
+// DeletedJob is a job that DeleteManagedJob deleted, and how it cleaned up after it.
+type DeletedJob struct {
+	ManagedJob
+	CanceledRuns    []int64 `json:"canceled_runs,omitempty"`
+	FailedScan      string  `json:"failed_scan,omitempty"` // model version whose pending scan was marked failed, as <model>@<version>
+	ManifestUpdated bool    `json:"manifest_updated,omitempty"`
+}
+
+// DeleteManagedJob deletes a job that FindManagedJob found, leaving hldbx's state consistent, unlike deleting the job
+// in the Databricks UI:
+//   - Its active runs are canceled, and waited for, before the job is deleted.
+//   - For a scan job, a model version left pending by a canceled run is marked failed, as the monitoring job marks
+//     scans that time out, so that it doesn't wait for the scan until then.
+//   - For the other jobs, the installation manifest drops the job, and the install audit log records command.
+func DeleteManagedJob(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, job ManagedJob,
+	command string) (*DeletedJob, error) {
+	deleted := &DeletedJob{ManagedJob: job}
+	runs, err := client.Jobs.ListRunsAll(ctx, jobs.ListRunsRequest{JobId: job.JobId, ActiveOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the active runs of job %d: %w", job.JobId, err)
+	}
+	for _, run := range runs {
+		if run.State != nil && slices.Contains(endedLifeCycleStates, run.State.LifeCycleState) {
+			continue
+		}
+		wait, err := client.Jobs.CancelRun(ctx, jobs.CancelRun{RunId: run.RunId})
+		if err != nil {
+			return nil, fmt.Errorf("unable to cancel run %d of job %d: %w", run.RunId, job.JobId, err)
+		}
+		if _, err := wait.GetWithTimeout(cancelRunTimeout); err != nil {
+			return nil, fmt.Errorf("run %d of job %d didn't end after it was canceled: %w", run.RunId, job.JobId, err)
+		}
+		deleted.CanceledRuns = append(deleted.CanceledRuns, run.RunId)
+	}
+	if job.Kind == scanJobKind && len(deleted.CanceledRuns) > 0 {
+		if deleted.FailedScan, err = failCanceledScan(ctx, client, job, deleted.CanceledRuns); err != nil {
+			return nil, err
+		}
+	}
+
+	err = client.Jobs.DeleteByJobId(ctx, job.JobId)
+	if err != nil && !errors.Is(err, databricks.ErrResourceDoesNotExist) && !errors.Is(err, databricks.ErrNotFound) {
+		return nil, fmt.Errorf("unable to delete job %d: %w", job.JobId, err)
+	}
+	if job.Kind == scanJobKind {
+		return deleted, nil
+	}
+	manifest, err := ReadManifest(ctx, client, config)
+	if err != nil {
+		return nil, err
+	}
+	if manifest != nil && slices.Contains([]int64{manifest.MonitorJobId, manifest.GuardrailJobId, manifest.VerifyJobId,
+		manifest.OnDemandJobId}, job.JobId) {
+		// Rewriting the manifest records the command in the install audit log
+		if _, err := WriteManifest(ctx, client, config, command); err != nil {
+			return nil, fmt.Errorf("unable to update the installation manifest: %w", err)
+		}
+		deleted.ManifestUpdated = true
+	} else if err := AppendAuditRecord(ctx, client, config, command); err != nil {
+		return nil, fmt.Errorf("unable to record the deletion in the install audit log: %w", err)
+	}
+	return deleted, nil
+}
+
+// Life cycle states of runs that have ended, which can't be canceled
+var endedLifeCycleStates = []jobs.RunLifeCycleState{jobs.RunLifeCycleStateTerminated, jobs.RunLifeCycleStateSkipped,
+	jobs.RunLifeCycleStateInternalError}
+
+// failCanceledScan marks the scan of the model version of a scan job failed, if it's pending on one of the canceled
+// runs. Returns the model version, as <model>@<version>, or "" if its scan wasn't pending on them.
+func failCanceledScan(ctx context.Context, client *databricks.WorkspaceClient, job ManagedJob, canceledRuns []int64) (string, error) {
+	// Named hl_scan_<catalog>.<schema>.<model>.<version>, see hl_monitor_models.py
+	name := strings.TrimPrefix(job.Name, scanJobNamePrefix)
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 {
+		return "", nil
+	}
+	fullName := name[:dot]
+	version, err := strconv.Atoi(name[dot+1:])
+	if err != nil {
+		return "", nil
+	}
+	tags, err := getModelVersionTags(ctx, client, fullName, version)
+	if err != nil {
+		return "", err
+	}
+	runId, _ := strconv.ParseInt(tags[hlScanRunIdTag], 10, 64)
+	if tags[hlScanStatusTag] != scanStatusPending || !slices.Contains(canceledRuns, runId) {
+		return "", nil
+	}
+	// The tags that the monitoring job sets on a scan that timed out
+	for _, tag := range [][2]string{
+		{hlScanStatusTag, scanStatusFailed},
+		{hlScanMessageTag, "Scan job deleted with hldbx jobs delete"},
+		{hlScanUpdatedAtTag, time.Now().UTC().Format(time.RFC3339)},
+	} {
+		if err := setModelVersionTag(ctx, client, fullName, version, tag[0], tag[1]); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%s@%d", fullName, version), nil
+}
@@ -0,0 +1,173 @@
+package dbx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/workspace"
+	"github.com/google/uuid"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlmodelmap"
+)
+
+// Name of the installation manifest, in the workspace directory above the notebooks of each version, where other
+// tooling and auditors can find out that HiddenLayer scanning is active and what it covers
+const manifestFileName = "hl_manifest.json"
+
+// Version of the manifest format, increased when fields are removed or change meaning
+const manifestFormatVersion = 1
+
+// Manifest describes an installation: what it scans, with which scanners, and who to contact about it.
+// It holds no credentials.
+type Manifest struct {
+	FormatVersion   int                         `json:"manifest_version"`
+	InstallId       string                      `json:"install_id"` // random, kept across re-installs
+	HldbxVersion    string                      `json:"hldbx_version"`
+	InstalledAt     string                      `json:"installed_at"`
+	InstalledBy     *Operator                   `json:"installed_by,omitempty"`
+	UpdatedAt       string                      `json:"updated_at"`
+	UpdatedBy       *Operator                   `json:"updated_by,omitempty"` // the full history is in the install audit log
+	OwnerContact    string                      `json:"owner_contact,omitempty"`
+	MonitorJobId    int64                       `json:"monitor_job_id,omitempty"`
+	GuardrailJobId  int64                       `json:"guardrail_job_id,omitempty"`
+	VerifyJobId     int64                       `json:"verify_job_id,omitempty"`
+	OnDemandJobId   int64                       `json:"on_demand_job_id,omitempty"`
+	Schedule        string                      `json:"schedule,omitempty"` // Quartz cron expression of the monitoring job
+	NotebookDir     string                      `json:"notebook_dir"`
+	Schemas         []utils.CatalogSchemaConfig `json:"schemas"`
+	OwnerGroups     []string                    `json:"owner_groups,omitempty"`
+	Scanners        map[string]string           `json:"scanners"` // API URL by scanner name
+	ScanTrigger     string                      `json:"scan_trigger"`
+	StateTable      string                      `json:"state_table,omitempty"`
+	ModelMapTable   string                      `json:"model_map_table,omitempty"` // see hlmodelmap.Lookup
+	RetentionDays   int                         `json:"retention_days,omitempty"`  // of the state tables' records, 0 for ever
+	ArchiveLocation string                      `json:"archive_location,omitempty"`
+}
+
+// ManifestPath returns the workspace path of the installation manifest.
+func ManifestPath(config *utils.Config) string {
+	dir := config.DbxWorkspaceDir
+	if dir == "" {
+		dir = defaultWorkspaceDir
+	}
+	return fmt.Sprintf("%s/%s", dir, manifestFileName)
+}
+
+// ReadManifest returns the installation manifest, or nil if there is none, e.g. before the first autoscan.
+func ReadManifest(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) (*Manifest, error) {
+	path := ManifestPath(config)
+	reader, err := client.Workspace.Download(ctx, path)
+	if errors.Is(err, databricks.ErrResourceDoesNotExist) || errors.Is(err, databricks.ErrNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to download %s: %w", path, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+	return &manifest, nil
+}
+
+// WriteManifest writes the installation manifest from the installed jobs and the configuration, keeping the
+// installation ID, time, and operator of the previous manifest, and records the change that command made in the
+// install audit log. The schemas are those that the monitoring job monitors, which hldbx schemas and
+// dbx_owner_groups may have changed since the install. Returns its workspace path.
+func WriteManifest(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, command string) (string, error) {
+	path := ManifestPath(config)
+	now := time.Now().UTC().Format(time.RFC3339)
+	operator, err := currentOperator(ctx, client)
+	if err != nil {
+		return path, err
+	}
+	manifest := &Manifest{InstallId: uuid.NewString(), InstalledAt: now, InstalledBy: &operator}
+	if previous, err := ReadManifest(ctx, client, config); err != nil {
+		return path, err
+	} else if previous != nil && previous.InstallId != "" {
+		// Manifests written before operators were recorded don't say who installed
+		manifest.InstallId, manifest.InstalledAt, manifest.InstalledBy = previous.InstallId, previous.InstalledAt, previous.InstalledBy
+	}
+	manifest.FormatVersion = manifestFormatVersion
+	manifest.HldbxVersion = utils.Version
+	manifest.UpdatedAt = now
+	manifest.UpdatedBy = &operator
+	manifest.OwnerContact = config.OwnerContact
+	manifest.NotebookDir = getHLWorkspaceDirectory(config)
+	manifest.Schemas = config.DbxSchemas
+	manifest.OwnerGroups = config.DbxOwnerGroups
+	manifest.ScanTrigger = string(config.DbxScanTrigger)
+	if manifest.ScanTrigger == "" {
+		manifest.ScanTrigger = string(hlconfig.ScanTriggerNewVersion)
+	}
+	manifest.StateTable = config.StateTable()
+	manifest.ModelMapTable = hlmodelmap.Table(config)
+	manifest.RetentionDays, manifest.ArchiveLocation = int(config.DbxRetentionDays), config.DbxArchiveLocation
+	manifest.Scanners = map[string]string{utils.DefaultScannerName: config.HlApiUrl}
+	for _, scanner := range config.HlScanners {
+		manifest.Scanners[scanner.Name] = scanner.ApiUrl
+	}
+
+	monitorJobs, err := findMonitorJobs(ctx, client)
+	if err != nil {
+		return path, err
+	}
+	if len(monitorJobs) > 0 {
+		job, err := client.Jobs.GetByJobId(ctx, monitorJobs[0].JobId)
+		if err != nil {
+			return path, fmt.Errorf("unable to get job %d: %w", monitorJobs[0].JobId, err)
+		}
+		manifest.MonitorJobId = job.JobId
+		if job.Settings.Schedule != nil {
+			manifest.Schedule = job.Settings.Schedule.QuartzCronExpression
+		}
+		if manifest.Schemas, err = jobSchemas(job); err != nil {
+			return path, err
+		}
+	}
+	guardrailJobs, err := findJobs(ctx, client, guardrailJobKey)
+	if err != nil {
+		return path, err
+	}
+	if len(guardrailJobs) > 0 {
+		manifest.GuardrailJobId = guardrailJobs[0].JobId
+	}
+	verifyJobs, err := findJobs(ctx, client, verifyJobKey)
+	if err != nil {
+		return path, err
+	}
+	if len(verifyJobs) > 0 {
+		manifest.VerifyJobId = verifyJobs[0].JobId
+	}
+	onDemandJobs, err := findJobs(ctx, client, onDemandJobKey)
+	if err != nil {
+		return path, err
+	}
+	if len(onDemandJobs) > 0 {
+		manifest.OnDemandJobId = onDemandJobs[0].JobId
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return path, err
+	}
+	err = client.Workspace.Upload(ctx, path, bytes.NewReader(data), workspace.UploadFormat(workspace.ImportFormatAuto),
+		workspace.UploadOverwrite())
+	if err != nil {
+		return path, fmt.Errorf("unable to upload %s: %w", path, err)
+	}
+	record := InstallRecord{Time: now, Command: command, CorrelationId: utils.CorrelationId(), InstallId: manifest.InstallId,
+		Operator: operator}
+	return path, appendAuditRecord(ctx, client, config, record)
+}
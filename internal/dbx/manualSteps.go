@@ -0,0 +1,119 @@
+package dbx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// skippedStep is a setup step that was skipped because the Databricks identity lacks permission for it,
+// along with the Databricks CLI commands that an admin can run to complete it.
+type skippedStep struct {
+	step     string
+	err      error
+	commands []string
+}
+
+// skipStep records a step that failed for lack of permission. Any other failure is fatal.
+func skipStep(step string, err error, commands []string) skippedStep {
+	if !errors.Is(err, databricks.ErrPermissionDenied) {
+		utils.Fatalf("Unable to %s: %v", step, err)
+	}
+	utils.Printf("Skipping step '%s' for lack of permission: %v\n", step, err)
+	return skippedStep{step: step, err: err, commands: commands}
+}
+
+// reportSkippedSteps explains which steps were skipped, and how to complete them.
+func reportSkippedSteps(skipped []skippedStep) {
+	fmt.Println()
+	fmt.Printf("Automated HiddenLayer model scanning is only partly set up, %d step(s) were skipped for lack of permission.\n", len(skipped))
+	fmt.Println("Either re-run autoscan with an identity that has the permissions, which completes the remaining steps,")
+	fmt.Println("or have a Databricks admin run these Databricks CLI commands:")
+	for _, skip := range skipped {
+		utils.Printf("\n# %s (%v)\n", skip.step, skip.err)
+		for _, command := range skip.commands {
+			fmt.Println(command)
+		}
+	}
+}
+
+// manualSecretsCommands returns the commands to store the HiddenLayer credentials for each schema whose scanner
+// needs them. The client secret is left as a placeholder, so it is never printed.
+func manualSecretsCommands(config *utils.Config) []string {
+	var commands []string
+	for _, schema := range config.DbxSchemas {
+		scanner, ok := config.Scanner(schema)
+		if !ok || !scanner.UsesClientCredentials() {
+			continue
+		}
+		scopeName := secretsScopeName(schema.Catalog, schema.Schema)
+		commands = append(commands,
+			fmt.Sprintf("databricks secrets create-scope %s", scopeName),
+			fmt.Sprintf("databricks secrets put-secret %s %s --string-value \"%s:<client_secret>\"", scopeName, config.HlApiKeyName, scanner.ClientID))
+		commands = append(commands, manualSecretsAclCommands(config, scopeName)...)
+	}
+	return commands
+}
+
+// manualSecretsAclCommands returns the command to grant the dbx_secrets_group its permission on a secrets scope,
+// if a group is configured.
+func manualSecretsAclCommands(config *utils.Config, scopeName string) []string {
+	if config.DbxSecretsGroup == "" {
+		return nil
+	}
+	return []string{fmt.Sprintf("databricks secrets put-acl %s %s %s", scopeName, config.DbxSecretsGroup, secretsPermission(config))}
+}
+
+// manualUploadCommands writes the notebooks to the profile's state directory, and returns the commands to import them.
+func manualUploadCommands(config *utils.Config) []string {
+	stateDir, err := utils.StateDir()
+	if err != nil {
+		utils.Fatal(err)
+	}
+	localDir := filepath.Join(stateDir, fmt.Sprintf("hldbx-notebooks-%s", utils.Version))
+	entries, err := sourceFiles.ReadDir("notebooks")
+	if err != nil {
+		utils.Fatal(err)
+	}
+	if err := os.MkdirAll(localDir, 0o755); err != nil {
+		utils.Fatalf("Error creating directory %s: %v", localDir, err)
+	}
+	for _, entry := range entries {
+		content, err := sourceFiles.ReadFile(fmt.Sprintf("notebooks/%s", entry.Name()))
+		if err != nil {
+			utils.Fatalf("Error reading Python file: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(localDir, entry.Name()), content, 0o644); err != nil {
+			utils.Fatalf("Error writing %s: %v", entry.Name(), err)
+		}
+	}
+	workspaceDir := getHLWorkspaceDirectory(config)
+	return []string{
+		fmt.Sprintf("databricks workspace mkdirs %s", workspaceDir),
+		fmt.Sprintf("databricks workspace import-dir %s %s --overwrite", localDir, workspaceDir),
+	}
+}
+
+// manualJobCommands writes the job settings to a JSON file in the profile's state directory, and returns the command to create the job.
+func manualJobCommands(createJob jobs.CreateJob) []string {
+	stateDir, err := utils.StateDir()
+	if err != nil {
+		utils.Fatal(err)
+	}
+	// Name the file by the job's key, since configured job names needn't be valid file names
+	payloadFile := filepath.Join(stateDir, fmt.Sprintf("hl_%s_job.json", createJob.Tags[hlJobTag]))
+	payload, err := json.MarshalIndent(createJob, "", "  ")
+	if err != nil {
+		utils.Fatalf("Error marshalling job %s: %v", createJob.Name, err)
+	}
+	if err := os.WriteFile(payloadFile, payload, 0o644); err != nil {
+		utils.Fatalf("Error writing %s: %v", payloadFile, err)
+	}
+	return []string{fmt.Sprintf("databricks jobs create --json @%s", payloadFile)}
+}
@@ -0,0 +1,328 @@
+package dbx
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/catalog"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/hl"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Outcomes of the scan of a model version, which results can be filtered by
+const (
+	ResultUnsafe    = "unsafe"    // the scan found threats above the safe threat levels
+	ResultSafe      = "safe"      // the scan found no threats above them
+	ResultUnscanned = "unscanned" // not scanned yet: waiting, being scanned, or waiting for the HiddenLayer API
+	ResultFailed    = "failed"    // the scan failed
+)
+
+// ResultOutcomes are the outcomes of ModelResult.Result.
+var ResultOutcomes = []string{ResultUnsafe, ResultSafe, ResultUnscanned, ResultFailed}
+
+// Sources of the scan results that ListModelResults returns
+const (
+	ResultSourceTags        = "tags"        // the scan tags of the model versions, which the scan jobs record
+	ResultSourceHiddenLayer = "hiddenlayer" // the latest finished scan of each model version in the HiddenLayer API
+)
+
+// ResultSources are the sources of ListModelResults.
+var ResultSources = []string{ResultSourceTags, ResultSourceHiddenLayer}
+
+// ModelResult is the scan result of a model version, joined with the registry and serving metadata that results
+// can be filtered by.
+type ModelResult struct {
+	ScanResult
+	Result   string            `json:"result"` // one of ResultOutcomes
+	Owner    string            `json:"owner,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"` // MLflow tags of the model version, other than the scan tags
+	Aliases  []string          `json:"aliases,omitempty"`
+	ServedBy []string          `json:"served_by,omitempty"` // Model Serving endpoints that serve the version
+}
+
+// ResultFilter selects model results. A result must match each field that is set, and any one of its values.
+type ResultFilter struct {
+	Outcomes []string  // of ResultOutcomes
+	Owners   []string  // model owners, users or groups, ignoring case
+	Tags     []string  // MLflow tags of the model version, as key, for any value, or key=value
+	Aliases  []string  // without @
+	Served   bool      // only versions that a Model Serving endpoint serves
+	Since    time.Time // only versions scanned, or whose scan was updated, since then, unless zero
+}
+
+// Validate checks the outcomes and tags of the filter.
+func (f ResultFilter) Validate() error {
+	for _, outcome := range f.Outcomes {
+		if !slices.Contains(ResultOutcomes, outcome) {
+			return fmt.Errorf("invalid result %q, expected one of %s", outcome, strings.Join(ResultOutcomes, ", "))
+		}
+	}
+	for _, tag := range f.Tags {
+		if key, _, _ := strings.Cut(tag, "="); key == "" {
+			return fmt.Errorf("invalid tag %q, expected key or key=value", tag)
+		}
+	}
+	return nil
+}
+
+// matches returns true if the filter selects the result.
+func (f ResultFilter) matches(result ModelResult) bool {
+	if len(f.Outcomes) > 0 && !slices.Contains(f.Outcomes, result.Result) {
+		return false
+	}
+	if len(f.Owners) > 0 && !slices.ContainsFunc(f.Owners, func(owner string) bool { return strings.EqualFold(owner, result.Owner) }) {
+		return false
+	}
+	if len(f.Tags) > 0 && !slices.ContainsFunc(f.Tags, func(tag string) bool {
+		key, value, hasValue := strings.Cut(tag, "=")
+		actual, ok := result.Tags[key]
+		return ok && (!hasValue || actual == value)
+	}) {
+		return false
+	}
+	if len(f.Aliases) > 0 && !slices.ContainsFunc(f.Aliases, func(alias string) bool { return slices.Contains(result.Aliases, alias) }) {
+		return false
+	}
+	if f.Served && len(result.ServedBy) == 0 {
+		return false
+	}
+	if !f.Since.IsZero() {
+		updatedAt, ok := parseUpdatedAt(result.UpdatedAt)
+		return ok && !updatedAt.Before(f.Since)
+	}
+	return true
+}
+
+// parseUpdatedAt parses the time of a scan tag: RFC 3339, or the ISO 8601 local time, without a time zone, that the
+// notebooks record with datetime.now().isoformat(), taken as UTC, which Databricks clusters use.
+func parseUpdatedAt(value string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// resultOutcome classifies a scan result into one of ResultOutcomes.
+func resultOutcome(result ScanResult) string {
+	switch {
+	case result.IsDetection():
+		return ResultUnsafe
+	case result.IsScanned():
+		return ResultSafe
+	case result.Status == scanStatusFailed:
+		return ResultFailed
+	default:
+		return ResultUnscanned
+	}
+}
+
+// ListModelResults returns the scan results of the model versions that the scan trigger picks, like
+// ListScanResults, and of the other versions in the monitored schemas that Model Serving endpoints serve, e.g. older
+// versions with the new_version trigger, joined with their models' owners and aliases, their MLflow tags, and the
+// endpoints that serve them, and selected by the filter. The scan results come from source, one of ResultSources.
+func ListModelResults(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, filter ResultFilter,
+	source string) ([]ModelResult, error) {
+	if !slices.Contains(ResultSources, source) {
+		return nil, fmt.Errorf("invalid source %q, expected one of %s", source, strings.Join(ResultSources, ", "))
+	}
+	var lookup *hlScanLookup
+	if source == ResultSourceHiddenLayer {
+		tlsConfig, err := hl.TLSConfig(config.HlTlsMinVersion, config.HlTlsPins)
+		if err != nil {
+			return nil, err
+		}
+		lookup = &hlScanLookup{ctx: ctx, config: config, tlsConfig: tlsConfig, httpClient: hl.NewHttpClient(tlsConfig),
+			scanners: map[string]*scannerClient{}}
+		defer func() {
+			for _, scanner := range lookup.scanners {
+				scanner.revoke()
+			}
+		}()
+	}
+	endpoints, err := client.ServingEndpoints.ListAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list Model Serving endpoints: %w", err)
+	}
+	servedBy := map[string][]string{}
+	for _, endpoint := range endpoints {
+		for _, model := range servedModelVersions(endpoint) {
+			servedBy[model] = append(servedBy[model], endpoint.Name)
+		}
+	}
+
+	// Aliases are only returned by getting each model
+	aliases := map[string][]catalog.RegisteredModelAlias{}
+	getModel := func(fullName string) (*catalog.RegisteredModelInfo, error) {
+		info, err := client.RegisteredModels.Get(ctx, catalog.GetRegisteredModelRequest{FullName: fullName, IncludeAliases: true})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get aliases of model %s: %w", fullName, err)
+		}
+		aliases[fullName] = info.Aliases
+		return info, nil
+	}
+	var results []ModelResult
+	visited := map[string]bool{}
+	visit := func(model catalog.RegisteredModelInfo, version int, tags map[string]string) error {
+		visited[fmt.Sprintf("%s@%d", model.FullName, version)] = true
+		if _, ok := aliases[model.FullName]; !ok {
+			if _, err := getModel(model.FullName); err != nil {
+				return err
+			}
+		}
+		modelAliases := aliases[model.FullName]
+		scanResult := scanResultFromTags(model.FullName, version, tags)
+		if lookup != nil {
+			if scanResult, err = lookup.scanResult(scanResult); err != nil {
+				return err
+			}
+		}
+		result := ModelResult{
+			ScanResult: scanResult,
+			Result:     resultOutcome(scanResult),
+			Owner:      model.Owner,
+			ServedBy:   servedBy[fmt.Sprintf("%s@%d", model.FullName, version)],
+		}
+		for _, alias := range modelAliases {
+			if alias.VersionNum == version {
+				result.Aliases = append(result.Aliases, alias.AliasName)
+			}
+		}
+		for key, value := range tags {
+			if !strings.HasPrefix(key, "hl_scan_") {
+				if result.Tags == nil {
+					result.Tags = map[string]string{}
+				}
+				result.Tags[key] = value
+			}
+		}
+		if filter.matches(result) {
+			results = append(results, result)
+		}
+		return nil
+	}
+	if err := walkCandidateVersions(ctx, client, config, visit); err != nil {
+		return nil, err
+	}
+	monitored := map[string]bool{}
+	for _, schema := range config.DbxSchemas {
+		monitored[strings.ToLower(schema.Catalog+"."+schema.Schema)] = true
+	}
+	for _, served := range slices.Sorted(maps.Keys(servedBy)) {
+		fullName, versionText, _ := strings.Cut(served, "@")
+		version, err := strconv.Atoi(versionText)
+		if err != nil || visited[served] || !monitored[strings.ToLower(fullName[:strings.LastIndex(fullName, ".")])] {
+			continue
+		}
+		// An endpoint may serve a version that was deleted since
+		model, err := getModel(fullName)
+		if isNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		tags, err := getModelVersionTags(ctx, client, fullName, version)
+		if isNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if err := visit(*model, version, tags); err != nil {
+			return nil, err
+		}
+	}
+	slices.SortFunc(results, func(a, b ModelResult) int {
+		if c := strings.Compare(a.Model, b.Model); c != 0 {
+			return c
+		}
+		return b.Version - a.Version
+	})
+	return results, nil
+}
+
+// VersionLabel returns the version of the result, with its aliases, e.g. 3 (@prod).
+func (r ModelResult) VersionLabel() string {
+	label := strconv.Itoa(r.Version)
+	if len(r.Aliases) > 0 {
+		label += " (@" + strings.Join(r.Aliases, ", @") + ")"
+	}
+	return label
+}
+
+// hlScanLookup looks up the scan results of model versions in the HiddenLayer API, in the scanner of each model.
+type hlScanLookup struct {
+	ctx        context.Context
+	config     *utils.Config
+	tlsConfig  *tls.Config
+	httpClient *http.Client
+	scanners   map[string]*scannerClient
+}
+
+// scanResult replaces the scan of a model version's tags with its latest finished scan in the HiddenLayer API, found
+// like ImportScanResults finds it. Its triage is kept, since it's only in the tags. A model version without one is
+// unscanned, unless its scan is pending or failed.
+func (l *hlScanLookup) scanResult(tagged ScanResult) (ScanResult, error) {
+	scanner, err := scannerClientFor(l.ctx, l.config, l.scanners, tagged.Model, l.tlsConfig)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	result := ScanResult{Model: tagged.Model, Version: tagged.Version, AckBy: tagged.AckBy, AckReason: tagged.AckReason,
+		Suppressed: tagged.Suppressed}
+	for _, name := range hlModelNames(tagged.Model) {
+		found, ok, err := hl.FindLatestScan(l.httpClient, scanner.scanner.ApiUrl, scanner.accessToken, name,
+			strconv.Itoa(tagged.Version))
+		if err != nil {
+			return ScanResult{}, err
+		}
+		if ok {
+			result.Status, result.ThreatLevel, result.UpdatedAt = scanStatusDone, found.ThreatLevel, found.UpdatedAt
+			result.ScanId, result.Scanner, result.Rules = found.ScanId, found.ScannerVersion, found.Rules
+			result.ScanUrl = foundScanUrl(scanner.scanner, found)
+			return result, nil
+		}
+	}
+	if tagged.Status == scanStatusPending || tagged.Status == scanStatusFailed {
+		result.Status, result.UpdatedAt, result.Message = tagged.Status, tagged.UpdatedAt, tagged.Message
+	}
+	return result, nil
+}
+
+// ModelSummary counts the outcomes of the scan results of a model's versions.
+type ModelSummary struct {
+	Model     string `json:"model"`
+	Unsafe    int    `json:"unsafe"`
+	Safe      int    `json:"safe"`
+	Unscanned int    `json:"unscanned"`
+	Failed    int    `json:"failed"`
+}
+
+// SummarizeResults counts the outcomes of the results of each model, sorted by model.
+func SummarizeResults(results []ModelResult) []ModelSummary {
+	var summaries []ModelSummary
+	for _, result := range results {
+		if len(summaries) == 0 || summaries[len(summaries)-1].Model != result.Model {
+			summaries = append(summaries, ModelSummary{Model: result.Model})
+		}
+		summary := &summaries[len(summaries)-1]
+		switch result.Result {
+		case ResultUnsafe:
+			summary.Unsafe++
+		case ResultSafe:
+			summary.Safe++
+		case ResultFailed:
+			summary.Failed++
+		default:
+			summary.Unscanned++
+		}
+	}
+	return summaries
+}
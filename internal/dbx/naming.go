@@ -0,0 +1,128 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Tag that identifies the jobs that hldbx creates, whatever they are named. Its values are stable keys of the jobs,
+// so that a job can be renamed to match a naming convention without losing track of it.
+const hlJobTag = "hl_job"
+
+// Keys of the jobs that hldbx creates, as values of the hlJobTag tag
+const (
+	monitorJobKey   = "monitor"
+	guardrailJobKey = "guardrail"
+	verifyJobKey    = "verify"
+	onDemandJobKey  = "on_demand"
+)
+
+// Default names of the jobs, and of the workspace directory of the notebooks
+const (
+	defaultMonitorJobName   = "hl_find_new_model_versions"
+	defaultGuardrailJobName = "hl_check_model_version"
+	defaultVerifyJobName    = "hl_verify_install"
+	defaultOnDemandJobName  = "hl_on_demand_scan" // outside scanJobNamePrefix, so its runs don't count as scan jobs
+	defaultWorkspaceDir     = "/Shared/HiddenLayer"
+)
+
+// Default names of the jobs by key. Jobs created before they were tagged are found by their default names.
+var defaultJobNames = map[string]string{
+	monitorJobKey:   defaultMonitorJobName,
+	guardrailJobKey: defaultGuardrailJobName,
+	verifyJobKey:    defaultVerifyJobName,
+	onDemandJobKey:  defaultOnDemandJobName,
+}
+
+// Databricks limits job names to 4096 characters
+const maxJobNameLength = 4096
+
+// jobNameSettings returns the configured names of the jobs by key, with the settings that override their defaults.
+// The on-demand scan job is named by its default only, so that its runs stay outside the scan jobs.
+func jobNameSettings(config *utils.Config) map[string]struct{ setting, name string } {
+	return map[string]struct{ setting, name string }{
+		monitorJobKey:   {"dbx_monitor_job_name", config.DbxMonitorJobName},
+		guardrailJobKey: {"dbx_guardrail_job_name", config.DbxGuardrailJobName},
+		verifyJobKey:    {"dbx_verify_job_name", config.DbxVerifyJobName},
+	}
+}
+
+// ValidateNaming checks the names and description that override the defaults of the jobs and workspace directory.
+func ValidateNaming(config *utils.Config) error {
+	settings := jobNameSettings(config)
+	keys := []string{monitorJobKey, guardrailJobKey, verifyJobKey}
+	for i, key := range keys {
+		setting, name := settings[key].setting, settings[key].name
+		if len(name) > maxJobNameLength {
+			return fmt.Errorf("%s is longer than %d characters", setting, maxJobNameLength)
+		}
+		if strings.TrimSpace(name) != name {
+			return fmt.Errorf("invalid %s %q, it can't start or end with spaces", setting, name)
+		}
+		for _, other := range keys[:i] {
+			if jobName(config, key) == jobName(config, other) {
+				return fmt.Errorf("%s and %s must be different", settings[other].setting, setting)
+			}
+		}
+	}
+	dir := config.DbxWorkspaceDir
+	if dir != "" && (!strings.HasPrefix(dir, "/") || strings.HasSuffix(dir, "/") || strings.Contains(dir, "//")) {
+		return fmt.Errorf("invalid dbx_workspace_dir %q, expected a workspace path such as /Shared/SEC-ML-SCAN-prod", dir)
+	}
+	return nil
+}
+
+// jobName returns the name of the job with the key: the configured one, or else its default.
+func jobName(config *utils.Config, key string) string {
+	if name := jobNameSettings(config)[key].name; name != "" {
+		return name
+	}
+	return defaultJobNames[key]
+}
+
+// jobTags returns the tags of a job that hldbx creates, which identify it by its key.
+func jobTags(key string) map[string]string {
+	return map[string]string{hlJobTag: key}
+}
+
+// jobDescription returns the description of the jobs that hldbx creates, if one is configured.
+func jobDescription(config *utils.Config) string {
+	return config.DbxJobDescription
+}
+
+// findJobs returns the jobs that hldbx created with the key, whatever they are named now, and those created before
+// jobs were tagged, which still have the default name. The jobs API can't filter by tag, so it lists the jobs without
+// their tasks, and only gets the full settings of those that match.
+func findJobs(ctx context.Context, client *databricks.WorkspaceClient, key string) ([]jobs.BaseJob, error) {
+	all, err := client.Jobs.ListAll(ctx, jobs.ListJobsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list jobs: %w", err)
+	}
+	var found []jobs.BaseJob
+	for _, job := range all {
+		if job.Settings == nil {
+			continue
+		}
+		tag, tagged := job.Settings.Tags[hlJobTag]
+		if tag != key && (tagged || job.Settings.Name != defaultJobNames[key]) {
+			continue
+		}
+		full, err := client.Jobs.GetByJobId(ctx, job.JobId)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get job %d: %w", job.JobId, err)
+		}
+		found = append(found, jobs.BaseJob{CreatedTime: full.CreatedTime, CreatorUserName: full.CreatorUserName,
+			EffectiveBudgetPolicyId: full.EffectiveBudgetPolicyId, JobId: full.JobId, Settings: full.Settings})
+	}
+	return found, nil
+}
+
+// findMonitorJobs returns the model monitoring jobs, of which there is normally one.
+func findMonitorJobs(ctx context.Context, client *databricks.WorkspaceClient) ([]jobs.BaseJob, error) {
+	return findJobs(ctx, client, monitorJobKey)
+}
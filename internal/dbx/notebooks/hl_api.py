@@ -0,0 +1,277 @@
+# This file has the code for authenticating to the HiddenLayer (HL) API, shared across the HL notebooks that scan.
+# It needs the HiddenLayer SDK, which those notebooks install.
+
+import base64
+import hashlib
+import inspect
+import json
+import os
+import ssl
+from collections import defaultdict
+from dataclasses import dataclass
+from typing import Dict, List, Optional, Tuple
+
+import certifi
+import httpx
+from databricks.sdk import WorkspaceClient
+from hiddenlayer import HiddenLayer
+
+from hl_common import DEFAULT_SCAN_ORIGIN, HL_AUTH_ERROR_STATUSES, HL_TLS_MIN_VERSION_ENV, HL_TLS_PINS_ENV, \
+    HL_USER_AGENT_SUFFIX_ENV, SCANNER_AUTH_CLIENT_CREDENTIALS, get_schema_secret, is_enterprise_scanner, secrets_scope
+
+# For testing purposes, you can run these commands in a Linux shell to set up credentials for testing:
+# databricks auth login --host <https URL for your Databricks cluster>
+# databricks secrets create-scope yourscope
+# databricks secrets put-secret yourscope <key_name> --string-value "<client_id>:<client_secret>"
+
+@dataclass
+class HLCredentials:
+    client_id: str
+    client_secret: str
+    access_token: str = ""  # a Databricks token, for scanners with databricks_token auth
+    def __repr__(self):
+        """Return a string representation of the credentials.
+        Include only part of the client secret and access token to avoid leaking them."""
+        return (f"HLCredentials(client_id={self.client_id}, client_secret={self.client_secret[0:4]}..., "
+                f"access_token={self.access_token[0:4]}...)")
+
+class BadHLCredentials(Exception):
+    """Custom exception for bad HiddenLayer credentials."""
+    def __init__(self, message):
+        super().__init__(message)
+
+_hl_api_creds = defaultdict(dict)       # Each entry is a scope dict
+def get_hl_api_creds(catalog: str, schema: str, hl_api_key_name: str):
+    """Return the credentials for the given catalog and schema. Cache them."""
+    scope = secrets_scope(catalog, schema)
+    global _hl_api_creds
+    scope_dict = _hl_api_creds[scope]   # will be non-empty because of defaultdict
+    creds: HLCredentials = scope_dict.get(hl_api_key_name)
+    if not creds:
+        secret, scope = get_schema_secret(catalog, schema, hl_api_key_name)
+        if not secret:
+            raise BadHLCredentials(f"No secret found for {hl_api_key_name} in scope {scope}")
+        if not ":" in secret:
+            raise BadHLCredentials(f"Invalid secret for {hl_api_key_name} in scope {scope}: must be a colon-separated client_id:client_secret string")
+        client_id, client_secret = secret.split(":")
+        creds = HLCredentials(client_id=client_id, client_secret=client_secret)
+        scope_dict[hl_api_key_name] = creds
+    return creds
+
+# Lifetime of the Databricks tokens that scan jobs mint for scanners with databricks_token auth, long enough for a scan.
+# They are revoked once the scan ends, however it ends, and expire on their own if the job is killed first.
+DATABRICKS_TOKEN_LIFETIME_SECS = 3600
+
+def mint_databricks_token() -> Tuple[HLCredentials, str]:
+    """Mint a short-lived Databricks token of the job's identity, the run-as service principal, with the Databricks SDK.
+    Return the credentials that carry it, and the token's ID to revoke it with."""
+    created = WorkspaceClient().tokens.create(lifetime_seconds=DATABRICKS_TOKEN_LIFETIME_SECS, comment="HiddenLayer scan")
+    return HLCredentials(client_id="", client_secret="", access_token=created.token_value), created.token_info.token_id
+
+def revoke_databricks_token(token_id: str) -> None:
+    """Revoke a token minted by mint_databricks_token(). If that fails, the token still expires on its own."""
+    try:
+        WorkspaceClient().tokens.delete(token_id)
+    except Exception as e:
+        print(f"Warning: unable to revoke Databricks token {token_id}, it expires on its own: {e}")
+
+# Manual test
+# creds = get_hl_api_creds("integrations_sandbox", "default", "hiddenlayer-key")
+# print(creds)  # only a few chars of the client secret will be printed out, so this is OK
+
+def get_hl_environment(hl_api_url: str, hl_environment: str, hl_auth: Optional[str] = None) -> str:
+    """Return the HL SaaS environment for the API URL, or None for an Enterprise self-hosted scanner, or another
+    scanner that is addressed by its URL, such as a staging HL instance."""
+    if hl_environment is None and hl_api_url is None:
+        # default to prod-us environment
+        hl_environment = "prod-us"
+    elif hl_environment is None:
+        # an api url was provided
+        # determine if api url is pointing at a HL Saas API or an on prem scanner
+        if is_enterprise_scanner(hl_api_url):
+            hl_environment = None
+        elif hl_api_url == "https://api.eu.hiddenlayer.ai":
+            hl_environment = "prod-eu"
+        elif hl_api_url == "https://api.us.hiddenlayer.ai":
+            hl_environment = "prod-us"
+        elif hl_auth == SCANNER_AUTH_CLIENT_CREDENTIALS:
+            hl_environment = None
+        else:
+            raise ValueError("Invalid hl_api_url")
+    return hl_environment
+
+# Prefix of certificate pins, as in curl's --pinnedpubkey. This must match the Go code.
+PIN_PREFIX = "sha256//"
+
+# Minimum TLS versions that can be required for the HL endpoints
+TLS_VERSIONS = {"1.2": ssl.TLSVersion.TLSv1_2, "1.3": ssl.TLSVersion.TLSv1_3}
+
+class CertificatePinMismatch(ssl.SSLError):
+    """The certificate of an HL endpoint doesn't match any of the configured pins."""
+
+def public_key_pin(der_certificate: bytes) -> str:
+    """Return the pin of the public key of a DER-encoded certificate."""
+    from cryptography import x509
+    from cryptography.hazmat.primitives import serialization
+    public_key = x509.load_der_x509_certificate(der_certificate).public_key()
+    spki = public_key.public_bytes(serialization.Encoding.DER, serialization.PublicFormat.SubjectPublicKeyInfo)
+    return PIN_PREFIX + base64.b64encode(hashlib.sha256(spki).digest()).decode()
+
+def check_pin(tls_connection, pins: List[str]) -> None:
+    """Raise CertificatePinMismatch unless the peer certificate of the TLS connection matches one of the pins."""
+    pin = public_key_pin(tls_connection.getpeercert(binary_form=True))
+    if pin not in pins:
+        raise CertificatePinMismatch(f"The certificate of {tls_connection.server_hostname} has public key pin {pin}, "
+                                     "which isn't one of the configured pins")
+
+class PinnedSSLSocket(ssl.SSLSocket):
+    """TLS socket that checks the certificate pins as part of the handshake, before any request is sent."""
+    def do_handshake(self, *args, **kwargs):
+        super().do_handshake(*args, **kwargs)
+        check_pin(self, self.context.pins)
+
+class PinnedSSLObject(ssl.SSLObject):
+    """Like PinnedSSLSocket, for TLS connections tunneled through an HTTPS proxy."""
+    def do_handshake(self, *args, **kwargs):
+        super().do_handshake(*args, **kwargs)
+        check_pin(self, self.context.pins)
+
+def hl_ssl_context() -> Optional[ssl.SSLContext]:
+    """Return the SSL context for the TLS settings configured by configure_egress(), or None if there are none."""
+    min_version = os.environ.get(HL_TLS_MIN_VERSION_ENV)
+    pins = [p.strip() for p in os.environ.get(HL_TLS_PINS_ENV, "").split(",") if p.strip()]
+    if not min_version and not pins:
+        return None
+    if min_version and min_version not in TLS_VERSIONS:
+        raise ValueError(f"Invalid hl_tls_min_version {min_version}, expected 1.2 or 1.3")
+    # Honor a CA bundle from the egress settings, as the HL SDK's default HTTP client would
+    context = ssl.create_default_context(cafile=os.environ.get("SSL_CERT_FILE") or certifi.where())
+    context.minimum_version = TLS_VERSIONS[min_version or "1.2"]
+    if pins:
+        context.pins = pins
+        context.sslsocket_class = PinnedSSLSocket
+        context.sslobject_class = PinnedSSLObject
+    return context
+
+# Auth URLs of the HL SaaS environments, where the HiddenLayer SDK authenticates by itself. This must match the Go code.
+HL_ENVIRONMENT_AUTH_URLS = {"prod-us": "https://auth.hiddenlayer.ai", "prod-eu": "https://auth.eu.hiddenlayer.ai"}
+
+def hl_access_token(hl_creds: HLCredentials, hl_auth_url: str) -> str:
+    """Return an access token for the client credentials from the auth URL, as GetJwt() in hl_client.go does.
+    Its connection follows the TLS settings configured by configure_egress(), if any."""
+    response = httpx.post(f"{hl_auth_url.rstrip('/')}/oauth2/token", params={"grant_type": "client_credentials"},
+                          auth=(hl_creds.client_id, hl_creds.client_secret),
+                          headers={"Content-Type": "application/x-www-form-urlencoded"},
+                          verify=hl_ssl_context() or True)
+    if response.status_code in HL_AUTH_ERROR_STATUSES:
+        raise BadHLCredentials(f"Unable to authenticate at {hl_auth_url}: {response.status_code}")
+    response.raise_for_status()
+    access_token = response.json().get("access_token")
+    if not access_token:
+        raise BadHLCredentials(f"Unable to authenticate at {hl_auth_url}: no access token in the response")
+    return access_token
+
+def hl_auth(hl_creds: HLCredentials, hl_api_url: str, environment: str, hl_auth_url: Optional[str] = None) -> HiddenLayer:
+    """Return a HiddenLayer authenticated with the given credentials.
+    Client credentials are exchanged for a token at hl_auth_url, unless it is empty or the auth URL of the SaaS
+    environment, which the SDK authenticates at by itself.
+    Its connections follow the TLS settings configured by configure_egress(), if any, and its user agent
+    identifies the deployment of user_agent_suffix."""
+    kwargs = {}
+    ssl_context = hl_ssl_context()
+    if ssl_context:
+        kwargs["http_client"] = httpx.Client(verify=ssl_context)
+    if (hl_creds.client_id and not hl_creds.access_token and hl_auth_url
+            and hl_auth_url.rstrip("/") != HL_ENVIRONMENT_AUTH_URLS.get(environment)):
+        # a custom auth URL, e.g. of a staging HL instance or another region, which the SDK doesn't know of
+        hl_creds = HLCredentials(client_id="", client_secret="", access_token=hl_access_token(hl_creds, hl_auth_url))
+    if hl_creds.access_token:
+        # a scanner behind the same SSO as Databricks, which accepts Databricks tokens, or a token from hl_auth_url
+        hl_client = HiddenLayer(
+            base_url=hl_api_url,
+            default_headers={"Authorization": f"Bearer {hl_creds.access_token}"},
+            **kwargs)
+    elif environment is None and hl_creds.client_id:
+        # another scanner with the HL API, e.g. a staging instance: use the api url directly, with credentials
+        hl_client = HiddenLayer(
+            base_url=hl_api_url,
+            client_id=hl_creds.client_id,
+            client_secret=hl_creds.client_secret,
+            **kwargs)
+    elif environment is None:
+        # on prem scanner, use the api url directly
+        hl_client = HiddenLayer(base_url=hl_api_url, **kwargs)
+    else:
+        # saas scanner, pass environment and credentials to authenticate
+        hl_client = HiddenLayer(
+            environment=environment,
+            client_id=hl_creds.client_id,
+            client_secret=hl_creds.client_secret,
+            **kwargs)
+    user_agent_suffix = os.environ.get(HL_USER_AGENT_SUFFIX_ENV)
+    if user_agent_suffix:
+        # Keep the SDK's user agent, as hldbx keeps its own, see UserAgent() in userAgent.go
+        hl_client = hl_client.with_options(
+            default_headers={"User-Agent": f"{hl_client.user_agent} deployment/{user_agent_suffix}"})
+    return hl_client
+
+# HTTP statuses of the HL API that mean it is down or overloaded, rather than that the request was bad
+HL_OUTAGE_STATUSES = [429, 502, 503, 504]
+
+def is_hl_outage(e: BaseException) -> bool:
+    """Return true if the exception, or one that caused it, shows that the HL API is unreachable or unavailable.
+    Certificate pin mismatches and other TLS failures aren't outages, they need someone to look at them."""
+    chain = []
+    while e is not None and e not in chain:
+        chain.append(e)
+        e = e.__cause__ or e.__context__
+    if any(isinstance(e, ssl.SSLError) for e in chain):
+        return False
+    for e in chain:
+        if isinstance(e, (httpx.ConnectError, httpx.TimeoutException, ConnectionError, TimeoutError)):
+            return True
+        # The HL SDK's connection errors wrap the httpx ones, and its status errors carry the HTTP status
+        if type(e).__name__ in ("APIConnectionError", "APITimeoutError"):
+            return True
+        status = getattr(e, "status_code", None) or getattr(e, "status", None)
+        if isinstance(status, int) and status in HL_OUTAGE_STATUSES:
+            return True
+    return False
+
+def is_hl_error(e: BaseException) -> bool:
+    """Return true if the exception shows that the HL API can't scan anything right now: an outage, or credentials that
+    it rejects or that are missing, e.g. because they were revoked. These trip the circuit breaker, see hl_common.py."""
+    if is_hl_outage(e):
+        return True
+    chain = []
+    while e is not None and e not in chain:
+        chain.append(e)
+        e = e.__cause__ or e.__context__
+    for e in chain:
+        if isinstance(e, BadHLCredentials):
+            return True
+        status = getattr(e, "status_code", None) or getattr(e, "status", None)
+        if isinstance(status, int) and status in HL_AUTH_ERROR_STATUSES:
+            return True
+    return False
+
+def get_scan_metadata(widgets_to_values: Dict[str, str]) -> Dict[str, str]:
+    """Return the metadata to send with scans: the configured metadata, and the ID of the job run that requested it."""
+    metadata = json.loads(widgets_to_values.get("scan_metadata") or "{}")
+    if widgets_to_values.get("requesting_job_run_id"):
+        metadata["requesting_job_run_id"] = widgets_to_values["requesting_job_run_id"]
+    return metadata
+
+def scan_request_kwargs(hl_client: HiddenLayer, origin: str, metadata: Optional[Dict[str, str]]) -> Dict:
+    """Return the keyword arguments of scan_folder() that describe where the scan comes from.
+    If the installed HL SDK doesn't accept metadata, add it to the origin instead, so it still shows in the HL console."""
+    origin = origin or DEFAULT_SCAN_ORIGIN
+    kwargs = {"request_source": "Integration", "origin": origin}
+    if not metadata:
+        return kwargs
+    if "metadata" in inspect.signature(hl_client.model_scanner.scan_folder).parameters:
+        kwargs["metadata"] = metadata
+    else:
+        labels = ", ".join(f"{k}={v}" for k, v in sorted(metadata.items()))
+        kwargs["origin"] = f"{origin} ({labels})"
+    return kwargs
@@ -0,0 +1,79 @@
+# Databricks notebook source
+# This HiddenLayer (HL) notebook keeps the HL state tables small while keeping their records for audits. It runs as a
+# task of the model monitoring job, after the heartbeat task, when dbx_retention_days is set. It exports the records
+# older than the retention to Parquet files in the archive location, then deletes them from the tables. The latest
+# record of each artifact, of each schema's coverage, and the latest heartbeat are kept whatever their age, since the
+# scans and checks compare with them.
+# Python version: 3.11+
+
+# Job parameters:
+# * state_table (string) - full name of the HL state table: <catalog>.<schema>.<table>
+# * retention_days (string) - how many days of records the tables keep
+# * archive_location (string) - directory to export the records to: the URL of an external location, or a Volume path.
+#   The records of each table are written to <archive_location>/<table>/archive_run_id=<job_run_id>/archived_at=<time>,
+#   a new directory for each attempt, so that repairing a run doesn't overwrite what an earlier attempt archived.
+# * job_run_id (string) - ID of the job run, set from {{job.run_id}}
+
+# COMMAND ----------
+
+import json
+from datetime import datetime, timedelta, timezone
+
+from databricks.sdk.runtime import dbutils, spark
+from pyspark.sql import Window
+from pyspark.sql import functions as F
+
+# COMMAND ----------
+
+# Tables that are archived: the suffix of their name after the state table's, the name of their archive directory, the
+# column of their records' time, and the columns of the records of which the latest is kept. These must match the
+# notebooks that write them: hl_heartbeat.py, hl_scan_artifacts.py, and hl_verify_install.py.
+ARCHIVED_TABLES = [
+    ("", "heartbeats", "heartbeat_at", []),
+    ("_artifacts", "artifact_scans", "scanned_at", ["source", "uri"]),
+    ("_coverage", "coverage", "verified_at", ["catalog", "schema"]),
+]
+
+def archive_table(table: str, directory: str, time_column: str, key_columns: list, cutoff: datetime) -> int:
+    """Export the records of the table older than the cutoff, other than the latest of each key, to the directory, and
+    delete them. Return the number of records archived."""
+    if not spark.catalog.tableExists(table):
+        return 0
+    records = spark.table(table)
+    recorded_at = F.col(time_column).cast("timestamp")
+    latest_first = Window.partitionBy(*(key_columns or [F.lit(1)])).orderBy(recorded_at.desc())
+    aged = (records.withColumn("_hl_rank", F.row_number().over(latest_first))
+            .where((recorded_at < F.lit(cutoff)) & (F.col("_hl_rank") > 1))
+            .drop("_hl_rank"))
+    if aged.isEmpty():
+        return 0
+    aged.write.mode("errorifexists").parquet(directory)
+    # Delete what was written, rather than what is aged now: a record that became superseded since isn't archived yet
+    archived = spark.read.parquet(directory)
+    count = archived.count()
+    archived.createOrReplaceTempView("hl_archived")
+    matches = " AND ".join(f"t.`{column}` <=> a.`{column}`" for column in records.columns)
+    spark.sql(f"MERGE INTO {table} AS t USING hl_archived AS a ON {matches} WHEN MATCHED THEN DELETE")
+    return count
+
+# COMMAND ----------
+
+# *** MAIN CELL THAT DRIVES EVERYTHING ***
+
+state_table = dbutils.widgets.get("state_table")
+assert state_table, "state_table is a required job parameter"
+retention_days = int(dbutils.widgets.get("retention_days"))
+assert retention_days > 0, "retention_days must be at least 1"
+archive_location = dbutils.widgets.get("archive_location").rstrip("/")
+assert archive_location, "archive_location is a required job parameter"
+job_run_id = dbutils.widgets.get("job_run_id")
+
+now = datetime.now(timezone.utc)
+cutoff = now - timedelta(days=retention_days)
+archived = {}
+for suffix, name, time_column, key_columns in ARCHIVED_TABLES:
+    directory = f"{archive_location}/{name}/archive_run_id={job_run_id}/archived_at={now:%Y%m%dT%H%M%SZ}"
+    archived[name] = archive_table(state_table + suffix, directory, time_column, key_columns, cutoff)
+    print(f"Archived {archived[name]} record(s) of {state_table + suffix} older than {cutoff.isoformat()} to {directory}")
+
+dbutils.notebook.exit(json.dumps({"cutoff": cutoff.isoformat(), "archived": archived}))
@@ -0,0 +1,42 @@
+# Databricks notebook source
+# This HiddenLayer (HL) notebook is a pre-deployment guardrail for Model Serving endpoints.
+# It fails if the given model version has not been scanned by HL, or if the scan found threats.
+# The exception is a version that couldn't be scanned because the HL API was unreachable, under the fail_open outage
+# policy: it passes with a warning. Under the fail_closed policy it is quarantined, and fails until it is scanned.
+# Deployment pipelines run it (as the hl_check_model_version job) before updating an endpoint to serve a model version,
+# and stop the deployment if the run fails.
+# Python version: 3.11+
+
+# Job parameters:
+# * full_model_name (string) - fully qualified name of the model to check: <catalog>.<schema>.<model_name>
+# * model_version_num (int) - MLflow version to check
+
+# COMMAND ----------
+
+# Import HL code that is shared across notebooks
+
+from hl_common import *
+
+# COMMAND ----------
+
+# *** MAIN CELL THAT DRIVES EVERYTHING ***
+
+full_model_name = dbutils.widgets.get("full_model_name")
+assert full_model_name, "full_model_name is a required job parameter"
+model_version_num = dbutils.widgets.get("model_version_num")
+assert model_version_num, "model_version_num is a required job parameter"
+
+mv = get_model_version(full_model_name, int(model_version_num))
+tags = mv.tags or {}
+if is_outage_exempt(tags):
+    print(f"Warning: model {mv.name} version {mv.version} has not been scanned because the HiddenLayer API was "
+          "unreachable. It passes under the fail_open outage policy, and will be scanned when the API is back.")
+    dbutils.notebook.exit("scan_pending")
+if not is_scan_safe(tags):
+    status = tags.get(HL_SCAN_STATUS, STATUS_UNSCANNED)
+    threat_level = tags.get(HL_SCAN_THREAT_LEVEL, "unknown")
+    # Raise an exception, rather than calling dbutils.notebook.exit(), so that the job will show as failed.
+    raise Exception(f"Model {mv.name} version {mv.version} must not be served: "
+                    f"HiddenLayer scan status is '{status}', threat level is '{threat_level}'")
+
+print(f"Model {mv.name} version {mv.version} passed the HiddenLayer guardrail")
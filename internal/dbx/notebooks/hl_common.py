@@ -1,11 +1,18 @@
 # This file has code that is shared across HiddenLayer notebooks.
 
+from databricks.sdk import WorkspaceClient
+from databricks.sdk.errors.platform import ResourceDoesNotExist
 from databricks.sdk.runtime import dbutils
+from databricks.sdk.service.workspace import ImportFormat
+import hashlib
+import io
 import json
+import os
+from datetime import datetime, timezone
 from mlflow import MlflowClient, set_registry_uri
 from mlflow.entities.model_registry import ModelVersion
 from mlflow.exceptions import RestException
-from typing import List, Tuple
+from typing import Dict, List, Optional, Tuple
 
 # Constants
 
@@ -16,6 +23,12 @@ STATUS_DONE = "done"
 STATUS_FAILED = "failed"
 STATUS_CANCELED = "canceled"
 STATUS_SKIPPED = "skipped"
+STATUS_SCAN_PENDING = "scan_pending"    # the HL API was unreachable, the monitor job retries the scan on each run
+
+# Authentication modes of scanners. These must match the Go code.
+SCANNER_AUTH_CLIENT_CREDENTIALS = "client_credentials"  # HL client ID and secret, from the schema's secrets scope
+SCANNER_AUTH_NONE = "none"                              # no authentication, e.g. the enterprise scanner
+SCANNER_AUTH_DATABRICKS_TOKEN = "databricks_token"      # a short-lived Databricks token of the job's identity
 
 # MLflow model version status. We only care about "READY".
 # See https://mlflow.org/docs/2.9.1/java_api/org/mlflow/api/proto/ModelRegistry.ModelVersionStatus.html
@@ -29,6 +42,75 @@ HL_SCAN_SCANNER_VERSION="hl_scan_scanner_version"
 HL_SCAN_URL="hl_scan_url"           # console URL for the scan
 HL_SCAN_MESSAGE="hl_scan_message"   # use this tag to record an error message
 HL_SCAN_RUN_ID="hl_scan_run_id"     # temporary tag to track the DBx scan job
+HL_SCAN_ID="hl_scan_id"             # HL scan ID, to look the verdict up again without rescanning
+HL_SCAN_ARTIFACT_DIGEST="hl_scan_artifact_digest"   # digest of the scanned artifacts, see artifact_digest()
+HL_SCAN_QUARANTINE="hl_scan_quarantine"     # "true" while a version waits for the HL API under the fail_closed policy
+HL_SCAN_RULES="hl_scan_rules"       # comma-separated IDs of the rules that the detections matched
+
+# Triage tag names, set by "hldbx detections" and kept across rescans. These must match the Go code.
+HL_SCAN_ACK_BY="hl_scan_ack_by"     # who acknowledged the detections
+HL_SCAN_ACK_REASON="hl_scan_ack_reason"
+HL_SCAN_ACK_AT="hl_scan_ack_at"
+HL_SCAN_SUPPRESS_PREFIX="hl_scan_suppress_"     # followed by a rule ID, holds {"by", "reason", "expires_at"} as JSON
+
+# Model version descriptions start HL scan summary lines with this prefix, so they can be replaced on the next scan
+HL_COMMENT_PREFIX = "HiddenLayer scan:"
+
+# Threat levels that let a model version pass the serving guardrail, once its scan is done
+SAFE_THREAT_LEVELS = ["none", "low"]
+
+# Optional job parameters for reaching the HL API from clusters whose egress goes through a proxy, for hardening the
+# TLS connections to it, and for identifying the deployment to proxies. The monitor job passes them along to the scan jobs.
+EGRESS_PARAMS = ["hl_https_proxy", "hl_no_proxy", "hl_ca_bundle_path", "hl_tls_min_version", "hl_tls_pins",
+                 "user_agent_suffix"]
+
+# Environment variables that hold the TLS and user agent settings for hl_auth() in hl_api.py
+HL_TLS_MIN_VERSION_ENV = "HL_TLS_MIN_VERSION"
+HL_TLS_PINS_ENV = "HL_TLS_PINS"
+HL_USER_AGENT_SUFFIX_ENV = "HL_USER_AGENT_SUFFIX"
+
+# Optional job parameters describing where scans come from, sent with each scan so that HL console results can be
+# filtered by origin workspace or team. The monitor job passes them along to the scan jobs.
+SCAN_METADATA_PARAMS = ["scan_origin", "scan_metadata"]
+DEFAULT_SCAN_ORIGIN = "Databricks"
+
+# Optional job parameters that choose the compute of the scan jobs: serverless ("true" or "false"), the budget
+# policy that serverless jobs are attributed to, and the spec of the cluster that each scan job creates, as JSON, when
+# the jobs run on job clusters. The monitor job passes them along to the scan jobs. These must match the Go code.
+COMPUTE_PARAMS = ["serverless", "budget_policy_id", "job_cluster"]
+
+# Policies for model versions that can't be scanned because the HL API is unreachable. These must match the Go code.
+# fail_open (the default) marks them scan_pending and lets the serving guardrail pass them with a warning;
+# fail_closed also quarantines them, so the guardrail blocks them until they are scanned.
+OUTAGE_POLICY_FAIL_OPEN = "fail_open"
+OUTAGE_POLICY_FAIL_CLOSED = "fail_closed"
+
+# Circuit breaker of the HL API: once the scan jobs of a monitor run hit this many HL API errors with a scanner, such as
+# rejected credentials or an outage, the run stops submitting scans to it, and its remaining scan jobs defer their
+# versions without calling the HL API. 0 turns it off, which hl_circuit_breaker_threshold -1 in the hldbx configuration
+# passes as. The default must match the Go code.
+DEFAULT_CIRCUIT_BREAKER_THRESHOLD = 5
+
+# Name of the folder in the HL workspace folder where scan jobs record their HL API errors, in a subfolder per monitor
+# run, with a file per model version named "<scanner>.<model>.<version>.json", and a file per scanner whose breaker is
+# open named "<scanner>.open"
+CIRCUIT_BREAKER_DIRNAME = "hl_circuit_breaker"
+CIRCUIT_OPEN_SUFFIX = ".open"
+
+# HTTP statuses of the HL API that mean the credentials were rejected, e.g. because they were revoked
+HL_AUTH_ERROR_STATUSES = [401, 403]
+
+# Bounds and default of the number of scan jobs that the monitor job runs at once. These must match the Go code.
+MIN_MAX_ACTIVE_SCAN_JOBS = 1
+MAX_MAX_ACTIVE_SCAN_JOBS = 100
+DEFAULT_MAX_ACTIVE_SCAN_JOBS = 10
+
+# Secrets scope that holds the secrets of the schemas that have no scope of their own, because the workspace had no
+# secret scopes left when they were installed. Its keys are "<catalog>.<schema>.<key>". This must match the Go code.
+CONSOLIDATED_SECRETS_SCOPE = "hl_scan"
+
+# Scan metadata key that holds the artifact digest, so HL scan results can be matched to identical artifacts
+ARTIFACT_DIGEST_METADATA_KEY = "artifact_digest"
 
 # Custom exception classes
 
@@ -50,6 +132,118 @@ def is_enterprise_scanner(hl_api_url: str) -> bool:
     """Return true if the HL API URL points to an enterprise scanner, false otherwise."""
     return not hl_api_url.endswith(".hiddenlayer.ai")
 
+def scanner_auth(hl_api_url: str, hl_auth: Optional[str]) -> str:
+    """Return how to authenticate to the scanner at the HL API URL. Scanners configured in hl_scanners say so
+    explicitly, otherwise only the HL SaaS scanner needs credentials."""
+    if hl_auth:
+        assert hl_auth in [SCANNER_AUTH_CLIENT_CREDENTIALS, SCANNER_AUTH_NONE, SCANNER_AUTH_DATABRICKS_TOKEN], \
+            f"invalid hl_auth {hl_auth}"
+        return hl_auth
+    return SCANNER_AUTH_NONE if is_enterprise_scanner(hl_api_url) else SCANNER_AUTH_CLIENT_CREDENTIALS
+
+def secrets_scope(catalog: str, schema: str) -> str:
+    """Given the Unity Catalog catalog and schema, use that to create and return a secrets scope name
+    that is unique within the workspace. This must match the Go code."""
+    return f"hl_scan.{catalog}.{schema}"
+
+def get_schema_secret(catalog: str, schema: str, key: str) -> Tuple[str, str]:
+    """Return a schema's HL secret, and the scope it is in: the schema's own scope, or else the consolidated scope.
+    Raise the error of reading the schema's own scope if neither has it."""
+    scope = secrets_scope(catalog, schema)
+    try:
+        return dbutils.secrets.get(scope, key), scope
+    except Exception as e:
+        try:
+            return dbutils.secrets.get(CONSOLIDATED_SECRETS_SCOPE, f"{catalog}.{schema}.{key}"), CONSOLIDATED_SECRETS_SCOPE
+        except Exception:
+            raise e
+
+def is_scan_safe(tags: Dict[str, str]) -> bool:
+    """Return true if the model version tags show a finished HL scan with a safe threat level."""
+    return tags.get(HL_SCAN_STATUS) == STATUS_DONE and \
+        tags.get(HL_SCAN_THREAT_LEVEL, "").lower() in SAFE_THREAT_LEVELS
+
+def is_outage_exempt(tags: Dict[str, str]) -> bool:
+    """Return true if the model version is only unscanned because the HL API was unreachable, and the fail_open
+    outage policy lets it through the serving guardrail."""
+    return tags.get(HL_SCAN_STATUS) == STATUS_SCAN_PENDING and tags.get(HL_SCAN_QUARANTINE) != "true"
+
+def defer_model_version(model_version: ModelVersion, message: str, outage_policy: str) -> None:
+    """Mark the model version scan_pending, so the monitor job retries it, and under the fail_closed outage policy
+    quarantine it until then."""
+    clear_tags(model_version, [HL_SCAN_RUN_ID, HL_SCAN_QUARANTINE])
+
+    set_model_version_tag(model_version, HL_SCAN_STATUS, STATUS_SCAN_PENDING)
+    set_model_version_tag(model_version, HL_SCAN_MESSAGE, message)
+    set_model_version_tag(model_version, HL_SCAN_UPDATED_AT, datetime.now().isoformat())
+    if outage_policy == OUTAGE_POLICY_FAIL_CLOSED:
+        set_model_version_tag(model_version, HL_SCAN_QUARANTINE, "true")
+
+def is_triage_tag(key: str) -> bool:
+    """Return true if the tag records a reviewer's triage decision, rather than scan state."""
+    return key in [HL_SCAN_ACK_BY, HL_SCAN_ACK_REASON, HL_SCAN_ACK_AT] or key.startswith(HL_SCAN_SUPPRESS_PREFIX)
+
+def suppressed_rules(tags: Dict[str, str]) -> List[str]:
+    """Return the IDs of the rules whose suppressions in the model version tags haven't expired."""
+    now = datetime.now(timezone.utc)
+    rules = []
+    for key, value in tags.items():
+        if not key.startswith(HL_SCAN_SUPPRESS_PREFIX):
+            continue
+        try:
+            expires_at = datetime.fromisoformat(json.loads(value)["expires_at"].replace("Z", "+00:00"))
+        except (ValueError, KeyError, TypeError):
+            continue
+        if expires_at > now:
+            rules.append(key[len(HL_SCAN_SUPPRESS_PREFIX):])
+    return rules
+
+def is_triaged(tags: Dict[str, str], rules: List[str]) -> bool:
+    """Return true if the detections were acknowledged, or every rule they matched is suppressed,
+    so they shouldn't be alerted on again."""
+    if tags.get(HL_SCAN_ACK_BY):
+        return True
+    return bool(rules) and set(rules) <= set(suppressed_rules(tags))
+
+def get_egress_params(widgets_to_values: Dict[str, str]) -> Dict[str, str]:
+    """Return the egress job parameters that have values, out of all the job parameters."""
+    return {name: widgets_to_values[name] for name in EGRESS_PARAMS if widgets_to_values.get(name)}
+
+def get_scan_metadata_params(widgets_to_values: Dict[str, str]) -> Dict[str, str]:
+    """Return the scan metadata job parameters that are set."""
+    return {k: widgets_to_values[k] for k in SCAN_METADATA_PARAMS if widgets_to_values.get(k)}
+
+def get_compute_params(widgets_to_values: Dict[str, str]) -> Dict[str, str]:
+    """Return the compute job parameters that are set."""
+    return {k: widgets_to_values[k] for k in COMPUTE_PARAMS if widgets_to_values.get(k)}
+
+def configure_egress(egress_params: Dict[str, str]) -> None:
+    """Set the proxy and CA bundle environment variables honored by the HTTP client in the HL SDK,
+    and the TLS and user agent settings used by hl_auth()."""
+    https_proxy = egress_params.get("hl_https_proxy")
+    if https_proxy:
+        os.environ["HTTPS_PROXY"] = https_proxy
+        os.environ["https_proxy"] = https_proxy
+    no_proxy = egress_params.get("hl_no_proxy")
+    if no_proxy:
+        os.environ["NO_PROXY"] = no_proxy
+        os.environ["no_proxy"] = no_proxy
+    ca_bundle_path = egress_params.get("hl_ca_bundle_path")
+    if ca_bundle_path:
+        # Typically a file on a Unity Catalog Volume, e.g. /Volumes/<catalog>/<schema>/<volume>/ca.pem
+        os.environ["SSL_CERT_FILE"] = ca_bundle_path
+        os.environ["REQUESTS_CA_BUNDLE"] = ca_bundle_path
+    tls_min_version = egress_params.get("hl_tls_min_version")
+    if tls_min_version:
+        os.environ[HL_TLS_MIN_VERSION_ENV] = tls_min_version
+    tls_pins = egress_params.get("hl_tls_pins")
+    if tls_pins:
+        # Comma-separated sha256//<base64> pins of the public keys of the HL endpoints' certificates
+        os.environ[HL_TLS_PINS_ENV] = tls_pins
+    user_agent_suffix = egress_params.get("user_agent_suffix")
+    if user_agent_suffix:
+        os.environ[HL_USER_AGENT_SUFFIX_ENV] = user_agent_suffix
+
 # Good for performance to create the MlflowClient just once.
 # Avoid using a global variable, which makes testing harder.
 
@@ -70,8 +264,19 @@ def set_model_version_tag(model_version: ModelVersion, key: str, value: str) ->
         key=key,
         value=value)
 
-def clear_tags(model_version: ModelVersion, keep_tags: List[str] = []) -> None:
-    """Clear all tags on the model version, except for any tags in the optional keep_tags list."""
+def set_scan_comment(model_version: ModelVersion, summary: str) -> None:
+    """Replace the HL scan summary in the model version's description (comment), keeping the rest of it,
+    so that reviewers see scan evidence in the Catalog Explorer."""
+    client = mlflow_client()
+    # Refresh the ModelVersion to get the current description
+    mv = get_model_version(full_model_name=model_version.name, mv_num=model_version.version)
+    kept = [line for line in (mv.description or "").splitlines() if not line.startswith(HL_COMMENT_PREFIX)]
+    description = "\n".join(kept + [f"{HL_COMMENT_PREFIX} {summary}"]).strip()
+    client.update_model_version(name=mv.name, version=mv.version, description=description)
+
+def clear_tags(model_version: ModelVersion, keep_tags: Optional[List[str]] = None) -> None:
+    """Clear all tags on the model version, except for triage tags and any tags in the optional keep_tags list."""
+    keep_tags = keep_tags or []
     client = mlflow_client()
     # Refresh the ModelVersion to ensure we have fresh data, otherwise this won't work
     mv = get_model_version(full_model_name=model_version.name, mv_num=model_version.version)
@@ -79,7 +284,7 @@ def clear_tags(model_version: ModelVersion, keep_tags: List[str] = []) -> None:
     
     # Delete each tag
     for tag_key in tags:
-        if not tag_key in keep_tags:
+        if not tag_key in keep_tags and not is_triage_tag(tag_key):
             client.delete_model_version_tag(
                 name=mv.name,
                 version=mv.version,
@@ -122,3 +327,93 @@ def get_model_version(
             raise ModelVersionNotFound(mv) from e
         else:
             raise ModelVersionError(mv, f"Failed to get model version {str(mv)}: {str(e)}") from e
+
+
+# External model versions are registered in the metastore from outside this workspace, by an MLflow client outside
+# Databricks or in another workspace on the metastore. Their runs aren't in this workspace's tracking server, so their
+# files are downloaded from their Unity Catalog storage location, which the models:/ URI resolves to.
+
+def is_external_model_version(run_workspace_id: Optional[int], workspace_id: int) -> bool:
+    """Return True if the model version was registered from outside the workspace: without a run in a Databricks
+    workspace, or with a run in another one. This must match isExternalVersion() in backfill.go."""
+    return not run_workspace_id or int(run_workspace_id) != int(workspace_id)
+
+def model_version_uri(mv: ModelVersion) -> str:
+    """Return the models:/ URI of the model version, to download its files from its Unity Catalog storage location."""
+    return f"models:/{mv.name}/{mv.version}"
+
+def artifact_digest(local_dir: str) -> str:
+    """Return a digest of the model artifacts in the directory, which is the same for identical artifacts wherever
+    they are registered: sha256:<hex> of the sorted relative paths and SHA-256 digests of the files."""
+    files = []
+    for root, _, names in os.walk(local_dir):
+        for name in names:
+            path = os.path.join(root, name)
+            file_hash = hashlib.sha256()
+            with open(path, "rb") as f:
+                for chunk in iter(lambda: f.read(1024 * 1024), b""):
+                    file_hash.update(chunk)
+            files.append((os.path.relpath(path, local_dir).replace(os.sep, "/"), file_hash.hexdigest()))
+    digest = hashlib.sha256()
+    for relative_path, file_digest in sorted(files):
+        digest.update(f"{relative_path}\0{file_digest}\n".encode())
+    return f"sha256:{digest.hexdigest()}"
+
+
+# The circuit breaker of the HL API. Scan jobs run apart from the monitor run that submitted them, so they share the
+# errors they hit through files in the HL workspace folder, which the monitor run and its other scan jobs count.
+
+def get_circuit_breaker_threshold(widgets_to_values: Dict[str, str]) -> int:
+    """Return the circuit breaker threshold of the job parameters, or 0 if it is off."""
+    threshold = widgets_to_values.get("circuit_breaker_threshold") or str(DEFAULT_CIRCUIT_BREAKER_THRESHOLD)
+    try:
+        threshold = int(threshold)
+    except ValueError:
+        raise ValueError(f"circuit_breaker_threshold job parameter must be an integer, got '{threshold}'")
+    return max(threshold, 0)
+
+def record_hl_error(breaker_dir: str, scanner: str, model_version: ModelVersion, message: str, threshold: int) -> int:
+    """Record an HL API error of a scan job in the folder of its monitor run, and open the breaker of the scanner once
+    the run's scan jobs reach the threshold. Return how many errors they have recorded with it, this one included."""
+    work = WorkspaceClient()
+    work.workspace.mkdirs(breaker_dir)
+    error = {"scanner": scanner, "model": model_version.name, "version": str(model_version.version),
+             "message": message, "at": datetime.now(timezone.utc).isoformat()}
+    path = f"{breaker_dir}/{scanner}.{model_version.name}.{model_version.version}.json"
+    work.workspace.upload(path, io.BytesIO(json.dumps(error).encode()), format=ImportFormat.AUTO, overwrite=True)
+    num_errors = len(read_hl_errors(breaker_dir).get(scanner, []))
+    if threshold > 0 and num_errors >= threshold:
+        # The other scan jobs check for this file alone, rather than counting the errors
+        work.workspace.upload(f"{breaker_dir}/{scanner}{CIRCUIT_OPEN_SUFFIX}", io.BytesIO(b""),
+                              format=ImportFormat.AUTO, overwrite=True)
+    return num_errors
+
+def read_hl_errors(breaker_dir: str) -> Dict[str, List[Dict]]:
+    """Return the HL API errors that the scan jobs of a monitor run recorded, by scanner."""
+    work = WorkspaceClient()
+    errors = {}
+    try:
+        entries = list(work.workspace.list(breaker_dir))
+    except ResourceDoesNotExist:
+        return errors
+    for entry in entries:
+        if not entry.path.endswith(".json"):
+            continue
+        try:
+            with work.workspace.download(entry.path) as f:
+                error = json.loads(f.read())
+        except (ResourceDoesNotExist, ValueError):
+            continue
+        errors.setdefault(error.get("scanner", ""), []).append(error)
+    return errors
+
+def is_circuit_open(breaker_dir: str, scanner: str) -> bool:
+    """Return true if the scan jobs of a monitor run hit the threshold of HL API errors with the scanner, which
+    record_hl_error() marks, so that checking costs one call however many errors there are."""
+    if not breaker_dir:
+        return False
+    try:
+        WorkspaceClient().workspace.get_status(f"{breaker_dir}/{scanner}{CIRCUIT_OPEN_SUFFIX}")
+    except ResourceDoesNotExist:
+        return False
+    return True
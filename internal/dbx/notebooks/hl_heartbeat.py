@@ -0,0 +1,61 @@
+# Databricks notebook source
+# This HiddenLayer (HL) notebook records a heartbeat for each run of the model monitoring job.
+# It runs as the job's final task, whether or not the monitoring task succeeded, and appends a row with the run ID,
+# duration, and counts to the HL state table. hldbx reads the heartbeats to alert when the job has silently stopped
+# running, e.g. because its schedule was paused or its cluster was deleted.
+# Python version: 3.11+
+
+# Job parameters:
+# * state_table (string) - full name of the Delta table to append heartbeats to: <catalog>.<schema>.<table>
+# * job_run_id (string) - ID of the job run, set from {{job.run_id}}
+
+# COMMAND ----------
+
+import json
+from datetime import datetime, timezone
+
+from databricks.sdk.runtime import dbutils, spark
+
+# COMMAND ----------
+
+# Task key and task value names of the monitoring task. These must match hl_monitor_models.py and the Go code.
+MONITOR_TASK_KEY = "monitor"
+TASK_VALUE_STARTED_AT = "started_at"
+TASK_VALUE_VERSIONS_FOUND = "versions_found"
+TASK_VALUE_SCANS_STARTED = "scans_started"
+TASK_VALUE_STATUS = "status"
+
+def get_monitor_value(key: str, default):
+    """Return a task value set by the monitoring task, or the default if the task failed before setting it."""
+    return dbutils.jobs.taskValues.get(taskKey=MONITOR_TASK_KEY, key=key, default=default, debugValue=default)
+
+# COMMAND ----------
+
+# *** MAIN CELL THAT DRIVES EVERYTHING ***
+
+state_table = dbutils.widgets.get("state_table")
+assert state_table, "state_table is a required job parameter"
+job_run_id = dbutils.widgets.get("job_run_id")
+
+now = datetime.now(timezone.utc)
+started_at = get_monitor_value(TASK_VALUE_STARTED_AT, now.isoformat())
+heartbeat = {
+    "run_id": job_run_id,
+    "heartbeat_at": now.isoformat(),
+    "duration_seconds": int((now - datetime.fromisoformat(started_at)).total_seconds()),
+    "versions_found": get_monitor_value(TASK_VALUE_VERSIONS_FOUND, 0),
+    "scans_started": get_monitor_value(TASK_VALUE_SCANS_STARTED, 0),
+    "monitor_status": get_monitor_value(TASK_VALUE_STATUS, "failed"),
+}
+
+try:
+    spark.sql(f"""CREATE TABLE IF NOT EXISTS {state_table} (
+        run_id STRING, heartbeat_at STRING, duration_seconds BIGINT, versions_found BIGINT, scans_started BIGINT,
+        monitor_status STRING)""")
+    spark.createDataFrame([heartbeat], schema=spark.table(state_table).schema).write.mode("append").saveAsTable(state_table)
+except Exception as e:
+    # The heartbeat is also in the run output, which is where hldbx reads it, so don't fail the run
+    print(f"Warning: unable to write heartbeat to {state_table}: {e}")
+
+print(f"Heartbeat: {heartbeat}")
+dbutils.notebook.exit(json.dumps(heartbeat))
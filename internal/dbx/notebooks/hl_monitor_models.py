@@ -8,9 +8,38 @@
 # Status of this notebook: WIP
 
 # Job parameters:
-# * catalog (string) - name of catalog to monitor, within UC
-# * schema (string) - name of schema to monitor, within the UC catalog
+# * schemas (string) - JSON list of the schemas to monitor, each with its "catalog", "schema", and optional "scanner"
+# * scanners (string) - optional JSON list of the scanners that schemas select instead of the one of hl_api_url, each
+#   with its "name", "api_url", "auth_url", "console_url", and "auth" ("client_credentials" or "none")
 # * hl_api_key_name (string) - name of the HL API key, used to get credentials from the Databricks (DBx) secrets store
+# * hl_https_proxy, hl_no_proxy, hl_ca_bundle_path (string) - optional egress settings, passed along to the scan jobs
+# * hl_tls_min_version, hl_tls_pins (string) - optional TLS settings, passed along to the scan jobs
+# * user_agent_suffix (string) - optional deployment ID for the user agent of HL API requests, passed along to the scan jobs
+# * findings_sink (string) - optional URI to export detections to in OCSF format, passed along to the scan jobs
+# * scan_comments (string) - optional, "true" to have the scan jobs write a scan summary into model version comments
+# * serving_guardrail (string) - optional, "true" to warn about serving endpoints that serve unscanned or unsafe model versions
+# * scan_origin, scan_metadata (string) - optional origin and metadata sent with each scan, passed along to the scan jobs
+# * monitor_run_id (string) - ID of this job run, set from {{job.run_id}}, passed to the scan jobs as the requesting job run
+# * scan_trigger (string) - optional, "new_version" (default) to scan the latest version of each model when it's
+#   registered, or "alias" to scan versions only when they are given an alias
+# * scan_aliases (string) - optional, comma-separated aliases that trigger scans with the "alias" trigger, e.g. "staging,prod".
+#   If empty, any alias triggers a scan.
+# * discovery_source (string) - optional, "list" (default) to list every model in the schemas on each run, or "audit" to
+#   query the system.access.audit table for the models changed since the previous run, which scales to large registries
+# * skip_external_versions (string) - optional, "true" to skip the versions registered from outside this workspace, by
+#   MLflow clients outside Databricks or in other workspaces on the metastore, rather than scanning them
+# * max_active_scan_jobs (int) - optional maximum number of scan jobs to run at once, 1 to 100, defaults to 10
+# * outage_policy (string) - optional, "fail_open" (default) or "fail_closed", for versions that can't be scanned because
+#   the HL API is unreachable; passed along to the scan jobs
+# * circuit_breaker_threshold (int) - optional number of HL API errors, such as rejected credentials or an outage, that
+#   the scan jobs of a run may hit with a scanner before the run stops submitting scans to it, defaults to 5, 0 turns the
+#   breaker off; passed along to the scan jobs
+# * coordination_table (string) - optional full name of a Delta table, <catalog>.<schema>.<table>, shared by the installs
+#   in workspaces that share this Unity Catalog metastore. Each claims the versions it scans there, so only one scans each.
+# * model_map_table (string) - optional full name of the Delta table that maps model versions to their HL model and scan
+#   IDs; passed along to the scan jobs, which maintain it
+# * full_model_name, model_version_num (string) - optional, set by the on-demand scan job: scan this model version now,
+#   instead of polling, wait for the scan to finish, and exit with its result as JSON
 
 # Steps:
 #
@@ -57,30 +86,69 @@ from hl_common import *
 # Give this string value a name to make it less confusing, or at least easier to track
 STATUS_NONE = ""
 
+# Scan trigger policies. These must match the Go code.
+SCAN_TRIGGER_NEW_VERSION = "new_version"   # scan the latest version of each model
+SCAN_TRIGGER_ALIAS = "alias"               # scan the versions that have an alias, e.g. @staging or @prod
+
 # Name of the file that we create to mark that one-time initialization has been done.
 INIT_MARKER_FILENAME = "hl_init_marker.txt"
 
+# Discovery sources, for finding new model versions. These must match the Go code.
+DISCOVERY_SOURCE_LIST = "list"      # list every model in the schemas
+DISCOVERY_SOURCE_AUDIT = "audit"    # query the audit system table for the models changed since the previous run
+
+# Name of the file that records where audit discovery left off, in the HL workspace folder
+DISCOVERY_CHECKPOINT_FILENAME = "hl_discovery_checkpoint.json"
+
+# Audit events reach the system table with a delay, so each audit query overlaps the previous one by this much
+DISCOVERY_AUDIT_OVERLAP_HOURS = 2
+
+# Unity Catalog audit actions that can make a model version a candidate for scanning
+DISCOVERY_AUDIT_ACTIONS = ["createModelVersion", "setRegisteredModelAlias"]
+
+# Name of the scanner of the hl_api_url job parameters, which schemas use unless they select another. This must match
+# the Go code.
+DEFAULT_SCANNER_NAME = "default"
+
 # Name of the notebook to run to trigger HL scans.
 HL_SCAN_NOTEBOOK="hl_scan_model"
+# Prefix of the names of the scan jobs, and of their runs. This must match the Go code.
+HL_SCAN_JOB_PREFIX="hl_scan_"
+# Tag of the scan jobs, by which hldbx tells them from other jobs whose names start with hl_scan_.
+# This convention must match between the Go and Python code.
+HL_SCAN_JOB_TAGS={"hl_job": "scan"}
+# How often the on-demand scan job checks for a free scan job slot
+SCAN_SLOT_POLL_SECS = 30
 
 # Timeout for HL scan jobs, including queuing. Make it very generous in case of system load.
 # Also, model files are often big, so uploads can take a while.
 HL_SCAN_NOTEBOOK_TIMEOUT_MINS=4800
 
-# Maximum number of scan jobs that we'll allow to run at once.
-# HL modscan has a queueing system so can handle receiving lots of jobs, but active jobs burn disk space
-# and network bandwith.
-MAX_ACTIVE_SCAN_JOBS =  int(dbutils.widgets.get("MAX_ACTIVE_SCAN_JOBS")) or 10
-
 # COMMAND ----------
 
+class ScannerConfiguration:
+    """A scanner that implements the HL API, and how to authenticate to it"""
+    name: str
+    api_url: str
+    auth_url: str
+    console_url: str
+    auth: str
+    def __init__(self, name, api_url, auth_url, console_url, auth):
+        self.name = name
+        self.api_url = api_url
+        self.auth_url = auth_url
+        self.console_url = console_url
+        self.auth = auth
+
 class CatalogSchemaConfiguration:
     """Configuration for this job"""
     catalog: str
     schema: str
-    def __init__(self, catalog, schema):
+    scanner: ScannerConfiguration
+    def __init__(self, catalog, schema, scanner):
         self.catalog = catalog
         self.schema = schema
+        self.scanner = scanner
 
 class Configuration:
     """Configuration for this job"""
@@ -89,12 +157,72 @@ class Configuration:
     hl_api_url: str
     hl_console_url: str
     hl_environment: str
-    def __init__(self, catalogs_and_schemas, hl_api_key_name, hl_api_url, hl_console_url, hl_environment):
+    egress_params: Dict[str, str]
+    serving_guardrail: bool
+    scan_comments: bool
+    findings_sink: str
+    scan_trigger: str
+    scan_aliases: List[str]
+    scan_metadata_params: Dict[str, str]
+    compute_params: Dict[str, str]
+    outage_policy: str
+    max_active_scan_jobs: int
+    discovery_source: str
+    skip_external_versions: bool
+    coordination_table: str
+    model_map_table: str
+    breaker_threshold: int
+    monitor_run_id: str
+    def __init__(self, catalogs_and_schemas, hl_api_key_name, hl_api_url, hl_console_url, hl_environment, egress_params,
+                 serving_guardrail, scan_comments, findings_sink, scan_trigger, scan_aliases, scan_metadata_params,
+                 compute_params, outage_policy, max_active_scan_jobs, discovery_source, skip_external_versions,
+                 coordination_table, model_map_table, breaker_threshold, monitor_run_id):
         self.catalogs_and_schemas = catalogs_and_schemas
         self.hl_api_key_name = hl_api_key_name
         self.hl_console_url = hl_console_url
         self.hl_api_url = hl_api_url
         self.hl_environment = hl_environment
+        self.egress_params = egress_params
+        self.serving_guardrail = serving_guardrail
+        self.scan_comments = scan_comments
+        self.findings_sink = findings_sink
+        self.scan_trigger = scan_trigger
+        self.scan_aliases = scan_aliases
+        self.scan_metadata_params = scan_metadata_params
+        self.compute_params = compute_params
+        self.outage_policy = outage_policy
+        self.max_active_scan_jobs = max_active_scan_jobs
+        self.discovery_source = discovery_source
+        self.skip_external_versions = skip_external_versions
+        self.coordination_table = coordination_table
+        self.model_map_table = model_map_table
+        self.breaker_threshold = breaker_threshold
+        self.monitor_run_id = monitor_run_id
+
+def get_scanners(hl_api_url: str, widgets_to_values: Dict[str, str]) -> Dict[str, ScannerConfiguration]:
+    """Return the scanners that schemas can select, by name: the scanner of the hl_api_url parameters, and those
+    of the scanners parameter."""
+    scanners = {DEFAULT_SCANNER_NAME: ScannerConfiguration(DEFAULT_SCANNER_NAME, hl_api_url,
+                                                           widgets_to_values.get("hl_auth_url"),
+                                                           widgets_to_values.get("hl_console_url"),
+                                                           scanner_auth(hl_api_url, None))}
+    scanners_list = json.loads(widgets_to_values.get("scanners") or "[]")
+    assert isinstance(scanners_list, list), "scanners must be a json list"
+    for item in scanners_list:
+        name = item.get("name")
+        api_url = item.get("api_url")
+        assert name and api_url, "each scanner needs a name and an api_url"
+        scanners[name] = ScannerConfiguration(name, api_url, item.get("auth_url"), item.get("console_url"),
+                                              scanner_auth(api_url, item.get("auth")))
+    return scanners
+
+def scanner_for_model(catalogs_and_schemas: List[CatalogSchemaConfiguration], full_name: str) -> ScannerConfiguration:
+    """Return the scanner of the schema that holds the model."""
+    catalog, schema, _ = full_name.split(".", 2)
+    for cs in catalogs_and_schemas:
+        if cs.catalog.lower() == catalog.lower() and cs.schema.lower() == schema.lower():
+            return cs.scanner
+    raise ValueError(f"model {full_name} isn't in a monitored schema")
 
 def get_job_params() -> Configuration:
     """Return catalog, schema, and HL API key name"""
@@ -105,15 +233,19 @@ def get_job_params() -> Configuration:
     catalogs_and_schemas_list = json.loads(catalogs_and_schemas_json)
     assert isinstance(catalogs_and_schemas_list, list), "schemas must be a json list"
 
+    hl_api_url = dbutils.widgets.get("hl_api_url")
+    scanners = get_scanners(hl_api_url, dbutils.widgets.getAll())
+
     catalogs_and_schemas = []
     for item in catalogs_and_schemas_list:
         catalog = item.get("catalog")
         assert catalog is not None, "catalog is a required job parameter"
         schema = item.get("schema")
         assert schema is not None, "schema is a required job parameter"
-        catalogs_and_schemas.append(CatalogSchemaConfiguration(catalog, schema))
+        scanner = item.get("scanner") or DEFAULT_SCANNER_NAME
+        assert scanner in scanners, f"schema {catalog}.{schema} selects unknown scanner {scanner}"
+        catalogs_and_schemas.append(CatalogSchemaConfiguration(catalog, schema, scanners[scanner]))
 
-    hl_api_url = dbutils.widgets.get("hl_api_url")
     hl_environment = dbutils.widgets.get("hl_environment")
     # if neither an environment nor an api url is provided, default to prod-us env
     if hl_environment is None and hl_api_url is None:
@@ -131,14 +263,49 @@ def get_job_params() -> Configuration:
     # else case here indicates that an HL environment was passed explicitly
 
     # Saas scanner, API credentials should be encoded in a key and a console url should be provided
+    widgets_to_values = dbutils.widgets.getAll()
+    hl_api_key_name = widgets_to_values.get("hl_api_key_name")
+    hl_console_url = widgets_to_values.get("hl_console_url")
+    if any(cs.scanner.auth == SCANNER_AUTH_CLIENT_CREDENTIALS for cs in catalogs_and_schemas):
+        assert hl_api_key_name, "hl_api_key_name is a required job parameter"
     if not is_enterprise_scanner(hl_api_url):
-        hl_api_key_name = dbutils.widgets.get("hl_api_key_name")
-        assert hl_api_key_name is not None, "hl_api_key_name is a required job parameter"
-
-        hl_console_url = dbutils.widgets.get("hl_console_url")
         assert hl_console_url is not None, "hl_console_url is a required job parameter"
 
-    return Configuration(catalogs_and_schemas, hl_api_key_name, hl_api_url, hl_console_url, hl_environment)
+    egress_params = get_egress_params(widgets_to_values)
+    serving_guardrail = widgets_to_values.get("serving_guardrail") == "true"
+    scan_comments = widgets_to_values.get("scan_comments") == "true"
+    findings_sink = widgets_to_values.get("findings_sink", "")
+    scan_trigger = widgets_to_values.get("scan_trigger") or SCAN_TRIGGER_NEW_VERSION
+    assert scan_trigger in [SCAN_TRIGGER_NEW_VERSION, SCAN_TRIGGER_ALIAS], f"invalid scan_trigger {scan_trigger}"
+    scan_aliases = [alias.strip() for alias in widgets_to_values.get("scan_aliases", "").split(",") if alias.strip()]
+    scan_metadata_params = get_scan_metadata_params(widgets_to_values)
+    if widgets_to_values.get("monitor_run_id"):
+        scan_metadata_params["requesting_job_run_id"] = widgets_to_values["monitor_run_id"]
+    compute_params = get_compute_params(widgets_to_values)
+    outage_policy = widgets_to_values.get("outage_policy") or OUTAGE_POLICY_FAIL_OPEN
+    assert outage_policy in [OUTAGE_POLICY_FAIL_OPEN, OUTAGE_POLICY_FAIL_CLOSED], f"invalid outage_policy {outage_policy}"
+    # Maximum number of scan jobs that we'll allow to run at once.
+    # HL modscan has a queueing system so can handle receiving lots of jobs, but active jobs burn disk space
+    # and network bandwith.
+    max_active_scan_jobs = widgets_to_values.get("max_active_scan_jobs") or str(DEFAULT_MAX_ACTIVE_SCAN_JOBS)
+    try:
+        max_active_scan_jobs = int(max_active_scan_jobs)
+    except ValueError:
+        raise ValueError(f"max_active_scan_jobs job parameter must be an integer, got '{max_active_scan_jobs}'")
+    assert MIN_MAX_ACTIVE_SCAN_JOBS <= max_active_scan_jobs <= MAX_MAX_ACTIVE_SCAN_JOBS, \
+        f"max_active_scan_jobs must be {MIN_MAX_ACTIVE_SCAN_JOBS} to {MAX_MAX_ACTIVE_SCAN_JOBS}, got {max_active_scan_jobs}"
+    discovery_source = widgets_to_values.get("discovery_source") or DISCOVERY_SOURCE_LIST
+    assert discovery_source in [DISCOVERY_SOURCE_LIST, DISCOVERY_SOURCE_AUDIT], f"invalid discovery_source {discovery_source}"
+    skip_external_versions = widgets_to_values.get("skip_external_versions") == "true"
+    coordination_table = widgets_to_values.get("coordination_table", "")
+    model_map_table = widgets_to_values.get("model_map_table", "")
+    breaker_threshold = get_circuit_breaker_threshold(widgets_to_values)
+    monitor_run_id = widgets_to_values.get("monitor_run_id", "")
+
+    return Configuration(catalogs_and_schemas, hl_api_key_name, hl_api_url, hl_console_url, hl_environment, egress_params,
+                         serving_guardrail, scan_comments, findings_sink, scan_trigger, scan_aliases, scan_metadata_params,
+                         compute_params, outage_policy, max_active_scan_jobs, discovery_source, skip_external_versions,
+                         coordination_table, model_map_table, breaker_threshold, monitor_run_id)
 
 
 # COMMAND ----------
@@ -176,42 +343,155 @@ def getcwd() -> str:
 # COMMAND ----------
 
 from collections import defaultdict
-from typing import Dict, Iterator
+from typing import Dict, Iterator, Optional
 from databricks.sdk.service.catalog import RegisteredModelInfo
 from mlflow.entities.model_registry import ModelVersion
 
-def get_model_versions_by_status(catalog: str, schema: str, statuses: List[str]) -> Dict[str, List[ModelVersion]]:
-    """Return a dict of the latest model versions in the UC schema with the given HL statuses.
+def get_candidate_versions(model: RegisteredModelInfo, scan_trigger: str, scan_aliases: List[str]) -> List[str]:
+    """Return the versions of the model that the scan trigger policy makes candidates for scanning:
+    the latest version, or the versions that have one of the aliases (any alias, if no aliases are given).
+    Aliases are Unity Catalog's replacement for the stages of the Workspace Model Registry."""
+    if scan_trigger == SCAN_TRIGGER_ALIAS:
+        model_info = workspace_client().registered_models.get(model.full_name, include_aliases=True)
+        return sorted({str(alias.version_num) for alias in model_info.aliases or []
+                       if not scan_aliases or alias.alias_name in scan_aliases})
+
+    # Get the latest version of each model from MLflow.
+    # Ideally we would get the most recent version in one step using the args order_by=["version DESC"], max_dikts=1.
+    # But that's not supported in Unity Catalog, so we have to crawl through *all* of the versions.
+    max_version = -1
+    for version in mlflow_client().search_model_versions(filter_string=f"name='{model.full_name}'"):
+        max_version = max(max_version, int(version.version))
+    return [str(max_version)] if max_version >= 0 else []
+
+# Keys of get_model_versions_by_status() for the candidate versions that aren't returned by their HL status
+VERSIONS_REGISTERING = "registering"    # not READY yet, e.g. an MLflow client is still uploading the files
+VERSIONS_EXTERNAL = "external"          # unscanned, registered from outside this workspace, and skip_external is set
+
+def get_model_versions_by_status(catalog: str, schema: str, statuses: List[str],
+                                 scan_trigger: str = SCAN_TRIGGER_NEW_VERSION,
+                                 scan_aliases: Optional[List[str]] = None,
+                                 models: Optional[List[RegisteredModelInfo]] = None,
+                                 skip_external: bool = False) -> Dict[str, List[ModelVersion]]:
+    """Return a dict of the candidate model versions in the UC schema with the given HL statuses.
+    Candidates are chosen by the scan trigger policy, see get_candidate_versions().
     If no statuses are given, then ignore the status value.
-    Keys are statuses, values are lists of model versions with that status.
+    If models are given, only look at those, rather than listing every model in the schema.
+    Keys are statuses, values are lists of model versions with that status. The versions that aren't READY yet are
+    under VERSIONS_REGISTERING instead, and if skip_external is set, the unscanned versions registered from outside
+    this workspace are under VERSIONS_EXTERNAL.
     The returned dict is a defaultdict(list) so you can always look up all statuses in the dict."""
     dikt: Dict[str, List[ModelVersion]] = defaultdict(list)
-    models: Iterator[RegisteredModelInfo] = workspace_client().registered_models.list(catalog_name=catalog, schema_name=schema)
+    if models is None:
+        models = workspace_client().registered_models.list(catalog_name=catalog, schema_name=schema)
     client = mlflow_client()
     for model in models:
-        # Get the latest version of each model from MLflow.
-        # Note that ModelVersion includes a tags field, but search_model_versions doesn't fill it in, at least not with Unity Catalog.
-        # Ideally we would get the most recent version in one step using the args order_by=["version DESC"], max_dikts=1.
-        # But that's not supported in Unity Catalog, so we have to crawl through *all* of the versions.
-        latest_version = None
-        max_version = -1
-        for version in client.search_model_versions(filter_string=f"name='{model.full_name}'"):
-            if int(version.version) > max_version:
-                max_version = int(version.version)
-                latest_version = version
-        if latest_version:
-            mv = client.get_model_version(latest_version.name, latest_version.version)   # get the tags
+        for version in get_candidate_versions(model, scan_trigger, scan_aliases):
+            # Note that ModelVersion includes a tags field, but search_model_versions doesn't fill it in, at least not with Unity Catalog.
+            mv = client.get_model_version(model.full_name, version)   # get the tags
+            if mv.status != MODEL_VERSION_STATUS_READY:
+                # Its files may be incomplete, check it again on the next run
+                dikt[VERSIONS_REGISTERING].append(mv)
+                continue
             tags = mv.tags
             status = tags.get(HL_SCAN_STATUS, STATUS_NONE)
             if status in statuses or not statuses:
+                if skip_external and status == STATUS_NONE and is_external_version(mv):
+                    dikt[VERSIONS_EXTERNAL].append(mv)
+                    continue
                 dikt[status].append(mv)
     return dikt
 
+def is_external_version(mv: ModelVersion) -> bool:
+    """Return True if the model version was registered from outside this workspace, see is_external_model_version().
+    MLflow doesn't return the workspace of the version's run, so get it from Unity Catalog."""
+    info = workspace_client().model_versions.get(mv.name, int(mv.version))
+    return is_external_model_version(info.run_workspace_id, current_workspace_id())
+
+_workspace_id = None   # private cache, for use only by this function
+def current_workspace_id() -> int:
+    """Get the ID of this workspace, which doesn't change during a run, so it's only asked for once."""
+    global _workspace_id
+    if _workspace_id is None:
+        _workspace_id = workspace_client().get_workspace_id()
+    return _workspace_id
+
 # Manual testing
 # print(get_model_versions_by_status("integrations_sandbox", "default", []))
 
 # COMMAND ----------
 
+# Tests of get_model_versions_by_status() and is_external_version(), with the Databricks and MLflow clients mocked,
+# so they have no side effects. Run them manually, when desired, with run_monitor_tests().
+
+from unittest import mock
+
+def fake_model_version(name: str, version: str, status: str = MODEL_VERSION_STATUS_READY,
+                       hl_status: Optional[str] = None) -> mock.Mock:
+    mv = mock.Mock(spec=["name", "version", "status", "tags"], version=version, status=status,
+                   tags={HL_SCAN_STATUS: hl_status} if hl_status else {})
+    mv.name = name   # the name argument of Mock names the mock itself
+    return mv
+
+def test_get_model_versions_by_status() -> None:
+    versions = {
+        "main.models.new": fake_model_version("main.models.new", "1"),
+        "main.models.scanned": fake_model_version("main.models.scanned", "2", hl_status=STATUS_DONE),
+        "main.models.uploading": fake_model_version("main.models.uploading", "3", status="PENDING_REGISTRATION"),
+        "main.models.external": fake_model_version("main.models.external", "1"),
+        "main.models.external_scanned": fake_model_version("main.models.external_scanned", "4", hl_status=STATUS_DONE),
+    }
+    client = mock.Mock()
+    client.get_model_version.side_effect = lambda name, version: versions[name]
+    models = [mock.Mock(full_name=name) for name in versions]
+    fakes = {
+        "mlflow_client": lambda: client,
+        "get_candidate_versions": lambda model, scan_trigger, scan_aliases: [versions[model.full_name].version],
+        "is_external_version": lambda mv: mv.name.startswith("main.models.external"),
+    }
+    with mock.patch.dict(globals(), fakes):
+        dikt = get_model_versions_by_status("main", "models", [], models=models)
+        assert [mv.name for mv in dikt[STATUS_NONE]] == ["main.models.new", "main.models.external"], \
+            "Unscanned versions are returned whatever their origin without skip_external."
+        assert [mv.name for mv in dikt[VERSIONS_REGISTERING]] == ["main.models.uploading"], \
+            "Versions that aren't READY are returned as registering."
+        assert not dikt[VERSIONS_EXTERNAL], "No version is external without skip_external."
+
+        dikt = get_model_versions_by_status("main", "models", [], models=models, skip_external=True)
+        assert [mv.name for mv in dikt[STATUS_NONE]] == ["main.models.new"], "External versions are skipped."
+        assert [mv.name for mv in dikt[VERSIONS_EXTERNAL]] == ["main.models.external"], \
+            "Unscanned external versions are returned as external."
+        assert [mv.name for mv in dikt[STATUS_DONE]] == ["main.models.scanned", "main.models.external_scanned"], \
+            "Scanned versions keep their status, even external ones."
+
+        dikt = get_model_versions_by_status("main", "models", [STATUS_DONE], models=models)
+        assert [mv.name for mv in dikt[STATUS_DONE]] == ["main.models.scanned", "main.models.external_scanned"], \
+            "Only the versions with the given statuses are returned."
+        assert not dikt[STATUS_NONE] and dikt[VERSIONS_REGISTERING], "Registering versions are returned regardless."
+
+def test_is_external_version() -> None:
+    client = mock.Mock()
+    client.get_workspace_id.return_value = 1234567890
+    client.model_versions.get.side_effect = lambda name, version: mock.Mock(
+        run_workspace_id={"main.models.local": 1234567890, "main.models.other": 42}.get(name))
+    with mock.patch.dict(globals(), {"workspace_client": lambda: client, "_workspace_id": None}):
+        assert not is_external_version(fake_model_version("main.models.local", "1")), "A local version isn't external."
+        assert is_external_version(fake_model_version("main.models.other", "1")), \
+            "A version with a run in another workspace is external."
+        assert is_external_version(fake_model_version("main.models.no_run", "1")), "A version without a run is external."
+        assert client.get_workspace_id.call_count == 1, "The workspace ID is only asked for once."
+
+def run_monitor_tests() -> None:
+    for test in [test_get_model_versions_by_status, test_is_external_version]:
+        print(test.__name__)
+        test()
+    print("All tests passed.")
+
+# Run the tests manually, when desired.
+#run_monitor_tests()
+
+# COMMAND ----------
+
 # Utilities for managing HL initialization status
 
 from databricks.sdk.errors.platform import ResourceDoesNotExist
@@ -254,9 +534,111 @@ def is_init_done() -> bool:
 
 # COMMAND ----------
 
-def init(catalog: str, schema: str) -> None:
+# Audit discovery: rather than listing every model on each run, which scales poorly past a few thousand models and
+# uses up API quota, look at the models with audit events since the previous run, and the models that still had
+# versions to scan then.
+
+from datetime import datetime, timedelta, timezone
+import json
+from typing import List, Tuple
+
+def get_discovery_checkpoint_path() -> Path:
+    """Return the path to the audit discovery checkpoint file in the HL workspace folder."""
+    return Path(getcwd()) / DISCOVERY_CHECKPOINT_FILENAME
+
+def read_discovery_checkpoint() -> Optional[Dict]:
+    """Return the audit discovery checkpoint: the time up to which audit events were seen ("since"), and the models
+    that still had versions to scan ("tracked"). Return None if there is none yet, e.g. on the first run."""
+    try:
+        with workspace_client().workspace.download(str(get_discovery_checkpoint_path())) as f:
+            return json.loads(f.read())
+    except ResourceDoesNotExist:
+        return None
+
+def write_discovery_checkpoint(since: datetime, tracked: List[str]) -> None:
+    """Record where audit discovery left off, for the next run."""
+    checkpoint = {"since": since.isoformat(), "tracked": sorted(tracked)}
+    workspace_client().workspace.upload(str(get_discovery_checkpoint_path()), io.BytesIO(json.dumps(checkpoint).encode()),
+                                        format=ImportFormat.AUTO, overwrite=True)
+
+def get_changed_models(catalogs_and_schemas: List[CatalogSchemaConfiguration],
+                       since: datetime) -> Tuple[List[str], Optional[datetime]]:
+    """Return the full names of the models in the schemas with audit events since the time that can make versions
+    candidates for scanning, and the time of the latest such event, or None if there are none.
+    The identity that the job runs as needs SELECT on system.access.audit."""
+    actions = ", ".join(f"'{action}'" for action in DISCOVERY_AUDIT_ACTIONS)
+    rows = spark.sql(f"""SELECT lower(coalesce(request_params['name'], request_params['full_name'])) AS name,
+            max(event_time) AS latest
+        FROM system.access.audit
+        WHERE service_name = 'unityCatalog'
+          AND action_name IN ({actions})
+          AND response.status_code = 200
+          AND event_date >= to_date(:since)
+          AND event_time > :since
+        GROUP BY 1""", args={"since": since}).collect()
+    prefixes = tuple(f"{cs.catalog}.{cs.schema}.".lower() for cs in catalogs_and_schemas)
+    names = [row["name"] for row in rows if row["name"] and row["name"].startswith(prefixes)]
+    latest = max((row["latest"] for row in rows), default=None)
+    return names, latest
+
+def get_registered_models(catalog: str, schema: str, full_names: List[str]) -> List[RegisteredModelInfo]:
+    """Return the models with the full names that are in the schema, skipping models that were deleted."""
+    prefix = f"{catalog}.{schema}.".lower()
+    models = []
+    for full_name in full_names:
+        if not full_name.lower().startswith(prefix):
+            continue
+        try:
+            models.append(workspace_client().registered_models.get(full_name))
+        except ResourceDoesNotExist:
+            pass
+    return models
+
+# COMMAND ----------
+
+# Coordination between the installs in workspaces that share a Unity Catalog metastore. They share the model version
+# tags, but an install only sees another's scan of a version once that scan job tags it, so both could start scans of
+# it. With a coordination table, an install claims each version before it scans it, and skips the versions that
+# another install claimed, until the claim is older than a scan job may take. Claims are keyed by metastore ID.
+
+def create_coordination_table(table: str) -> None:
+    """Create the coordination table if it doesn't exist yet, e.g. on the first run of the first install."""
+    spark.sql(f"""CREATE TABLE IF NOT EXISTS {table} (
+            metastore_id STRING, model_name STRING, model_version STRING, workspace_id STRING, claimed_at TIMESTAMP)
+        COMMENT 'Model versions claimed for scanning by the HiddenLayer installs that share a metastore'""")
+
+def get_metastore_id() -> str:
+    """Return the ID of the Unity Catalog metastore that this workspace is attached to."""
+    return spark.sql("SELECT current_metastore()").first()[0]
+
+def claim_model_version(table: str, metastore_id: str, workspace_id: str, mv: ModelVersion,
+                        expiry_minutes: int) -> bool:
+    """Claim a model version for this workspace's install to scan. Return False if another install holds the claim.
+    A claim that this install already holds, or that expired, is renewed."""
+    key = {"metastore_id": metastore_id, "model_name": mv.name.lower(), "model_version": str(mv.version)}
+    try:
+        spark.sql(f"""MERGE INTO {table} AS t
+            USING (SELECT :metastore_id AS metastore_id, :model_name AS model_name, :model_version AS model_version,
+                          :workspace_id AS workspace_id, current_timestamp() AS claimed_at) AS s
+            ON t.metastore_id = s.metastore_id AND t.model_name = s.model_name AND t.model_version = s.model_version
+            WHEN MATCHED AND (t.workspace_id = s.workspace_id OR t.claimed_at < :expired_before) THEN UPDATE SET *
+            WHEN NOT MATCHED THEN INSERT *""",
+            args={**key, "workspace_id": workspace_id,
+                  "expired_before": datetime.now(timezone.utc) - timedelta(minutes=expiry_minutes)})
+    except Exception as e:
+        # Most likely a concurrent claim by another install, which Delta rejects; the next run sees who won
+        print(f"Warning: unable to claim model {mv.name} version {mv.version} in {table}: {e}")
+        return False
+    row = spark.sql(f"""SELECT workspace_id FROM {table}
+        WHERE metastore_id = :metastore_id AND model_name = :model_name AND model_version = :model_version""",
+        args=key).first()
+    return row is not None and row["workspace_id"] == workspace_id
+
+# COMMAND ----------
+
+def init(catalog: str, schema: str, scan_trigger: str, scan_aliases: List[str]) -> None:
     """Do one-time state initialization by marking all untagged models in the UC catalog/schema as unscanned."""
-    mv_dict: Dict[str, List[ModelVersion]] = get_model_versions_by_status(catalog, schema, [])
+    mv_dict: Dict[str, List[ModelVersion]] = get_model_versions_by_status(catalog, schema, [], scan_trigger, scan_aliases)
     versions = mv_dict[STATUS_NONE]
     for mv in versions:
         set_model_version_tag(mv, HL_SCAN_STATUS, STATUS_UNSCANNED)
@@ -277,9 +659,10 @@ def init(catalog: str, schema: str) -> None:
 
 # COMMAND ----------
 
-def get_cluster_id() -> str:
-    """Return the cluster ID for the current cluster. This is useful for running compute jobs."""
-    return spark.conf.get("spark.databricks.clusterUsageTags.clusterId")
+def get_cluster_id() -> Optional[str]:
+    """Return the cluster ID for the current cluster. This is useful for running compute jobs.
+    Return None on serverless compute, which has no cluster."""
+    return spark.conf.get("spark.databricks.clusterUsageTags.clusterId", None)
 
 # Manual test
 print(get_cluster_id())
@@ -287,23 +670,27 @@ print(get_cluster_id())
 # COMMAND ----------
 
 from databricks.sdk import WorkspaceClient
+from databricks.sdk.service.compute import ClusterSpec
 from databricks.sdk.service.jobs import NotebookTask, RunNowResponse, Task,\
     JobSettings, RunLifeCycleState, RunResultState
 import time
-from typing import Dict
+from typing import Dict, Optional
 import uuid
 
-def run_notebook(job_name: str, notebook_path: str, cluster_id: str,
-                 parameters: Dict[str, str]=None, timeout_minutes: int=60) -> int:
+def run_notebook(job_name: str, notebook_path: str, cluster_id: Optional[str],
+                 parameters: Dict[str, str]=None, timeout_minutes: int=60, budget_policy_id: Optional[str]=None,
+                 new_cluster: Optional[ClusterSpec]=None) -> int:
     """
     Run a Databricks notebook. Don't wait for it to finish.
     
     Args:
         job_name (str): Name of the job running the notebook
         notebook_path (str): Path to the notebook in Databricks workspace
-        cluster_id (str): Existing cluster ID to run the notebook
+        cluster_id (str): Existing cluster ID to run the notebook, or None for serverless compute and new clusters
         parameters (Dict[str, str]): Notebook parameters
         timeout_minutes (int): Maximum time to wait for completion in minutes
+        budget_policy_id (str): Budget policy to attribute serverless compute to, if any
+        new_cluster (ClusterSpec): Cluster that the job creates to run the notebook, if any
         
     Returns:
         int: Run ID
@@ -314,10 +701,11 @@ def run_notebook(job_name: str, notebook_path: str, cluster_id: str,
         notebook_task = NotebookTask(notebook_path=notebook_path, base_parameters=parameters)
         task = Task(description=job_name,
                     existing_cluster_id=cluster_id,
+                    new_cluster=new_cluster,
                     notebook_task=notebook_task,
                     task_key=str(uuid.uuid4()),                 # task key must be unique
                     timeout_seconds=timeout_minutes * 60)
-        job = work.jobs.create(name=job_name, tasks=[task])
+        job = work.jobs.create(name=job_name, tasks=[task], budget_policy_id=budget_policy_id, tags=HL_SCAN_JOB_TAGS)
         job_id = job.job_id
         
         # Run the job
@@ -346,23 +734,51 @@ from datetime import datetime
 from mlflow.entities.model_registry import ModelVersion
 from pathlib import Path
 
-def scan_model(mv: ModelVersion, hl_api_key_name: str, hl_api_url: str, hl_auth_url: str, hl_console_url: str, timeout_minutes: int) -> int:
+def scan_model(mv: ModelVersion, hl_api_key_name: str, hl_api_url: str, hl_auth_url: str, hl_console_url: str, timeout_minutes: int,
+               egress_params: Optional[Dict[str, str]] = None, scan_comments: bool = False, findings_sink: str = "",
+               scan_metadata_params: Optional[Dict[str, str]] = None, compute_params: Optional[Dict[str, str]] = None,
+               outage_policy: str = OUTAGE_POLICY_FAIL_OPEN, hl_auth: str = "", model_map_table: str = "",
+               scanner_name: str = "", breaker_dir: str = "", breaker_threshold: int = 0) -> int:
     """Run a scan job on a model version. Don't wait for it to finish. Return the run_id."""
-    job_name = f"hl_scan_{mv.name}.{mv.version}"
+    job_name = f"{HL_SCAN_JOB_PREFIX}{mv.name}.{mv.version}"
     notebook_path = Path(getcwd()) / HL_SCAN_NOTEBOOK
-    cluster_id = get_cluster_id()
+    # Scan jobs run on the same compute as this job: its cluster, serverless compute, or a job cluster of their own,
+    # since this job's job cluster terminates when it ends
+    compute_params = compute_params or {}
+    serverless = compute_params.get("serverless") == "true"
+    job_cluster = compute_params.get("job_cluster") if not serverless else None
+    new_cluster = ClusterSpec.from_dict(json.loads(job_cluster)) if job_cluster else None
+    cluster_id = None if serverless or new_cluster else get_cluster_id()
+    budget_policy_id = compute_params.get("budget_policy_id") if serverless else None
     # For a ModelVersion in Unity Catalog, the name is the full name, including catalog and schema
     parameters={"full_model_name": mv.name,
                 "model_version_num": str(mv.version),
                 "hl_api_url": hl_api_url,
-                "hl_auth_url": hl_auth_url,
+                "hl_auth_url": hl_auth_url or "",
                 }
+    if hl_auth:
+        parameters["hl_auth"] = hl_auth
     # optional parameters only needed by Saas scanner workflows
     if hl_console_url:
         parameters["hl_console_url"] = hl_console_url
     if hl_api_key_name:
         parameters["hl_api_key_name"] = hl_api_key_name
-    run_id = run_notebook(job_name, str(notebook_path), cluster_id, parameters, timeout_minutes=timeout_minutes)
+    parameters.update(egress_params or {})
+    parameters.update(scan_metadata_params or {})
+    if scan_comments:
+        parameters["scan_comments"] = "true"
+    if findings_sink:
+        parameters["findings_sink"] = findings_sink
+    parameters["outage_policy"] = outage_policy
+    if model_map_table:
+        parameters["model_map_table"] = model_map_table
+    if scanner_name:
+        parameters["hl_scanner"] = scanner_name
+    if breaker_dir and breaker_threshold:
+        parameters["circuit_breaker_dir"] = breaker_dir
+        parameters["circuit_breaker_threshold"] = str(breaker_threshold)
+    run_id = run_notebook(job_name, str(notebook_path), cluster_id, parameters, timeout_minutes=timeout_minutes,
+                          budget_policy_id=budget_policy_id, new_cluster=new_cluster)
     # For debugging purposes, save the run_id as a temporary tag
     set_model_version_tag(mv, HL_SCAN_RUN_ID, run_id)
     return run_id
@@ -378,7 +794,7 @@ def scan_model(mv: ModelVersion, hl_api_key_name: str, hl_api_url: str, hl_auth_
 
 # COMMAND ----------
 
-from datetime import datetime
+from datetime import datetime, timezone
 
 def handle_job_timeouts(pending_model_versions: List[ModelVersion], timeout_minutes: int) -> List[ModelVersion]:
     """For model versions in the pending state (scan job unfinished), mark them as failed if the jobs have expired.
@@ -434,32 +850,269 @@ def handle_job_timeouts(pending_model_versions: List[ModelVersion], timeout_minu
 
 # COMMAND ----------
 
+def report_unsafe_served_versions(catalogs_and_schemas: List[CatalogSchemaConfiguration]) -> None:
+    """Warn about Model Serving endpoints that serve model versions from the monitored schemas
+    without a safe HL scan result."""
+    monitored = {f"{cs.catalog}.{cs.schema}" for cs in catalogs_and_schemas}
+    for endpoint in workspace_client().serving_endpoints.list():
+        if not endpoint.config or not endpoint.config.served_entities:
+            continue
+        for entity in endpoint.config.served_entities:
+            # UC model names are <catalog>.<schema>.<model_name>
+            if not entity.entity_name or entity.entity_name.rsplit(".", 1)[0] not in monitored:
+                continue
+            mv = get_model_version(entity.entity_name, int(entity.entity_version))
+            if not is_scan_safe(mv.tags or {}):
+                print(f"Warning: endpoint {endpoint.name} serves model {mv.name} version {mv.version}, "
+                      "which has not passed a HiddenLayer scan")
+
+# COMMAND ----------
+
+# The circuit breaker of the HL API, see hl_common.py. The scan jobs of a run record their HL API errors in the run's
+# folder. The run stops submitting scans to a scanner once they hit the threshold, and the next run raises one alert
+# for it, since the scan jobs finish after the run that submitted them. A scanner whose breaker tripped gets a single
+# scan on the next run, to probe whether it has recovered, and its other versions wait for a run after that.
+
+# Life cycle states of a job run that has ended
+FINISHED_LIFE_CYCLE_STATES = [RunLifeCycleState.TERMINATED, RunLifeCycleState.SKIPPED, RunLifeCycleState.INTERNAL_ERROR]
+
+def get_breaker_root() -> str:
+    """Return the folder of the circuit breaker in the HL workspace folder, which holds a folder per monitor run."""
+    return str(Path(getcwd()) / CIRCUIT_BREAKER_DIRNAME)
+
+def get_breaker_dir(monitor_run_id: str) -> str:
+    """Return the folder where the scan jobs of a monitor run record their HL API errors."""
+    return f"{get_breaker_root()}/{monitor_run_id}"
+
+def is_run_finished(run_id: str) -> bool:
+    """Return true if the job run has ended, or no longer exists."""
+    try:
+        state = workspace_client().jobs.get_run(int(run_id)).state
+    except (ResourceDoesNotExist, ValueError):
+        return True
+    return state is not None and state.life_cycle_state in FINISHED_LIFE_CYCLE_STATES
+
+def collect_tripped_breakers(monitor_run_id: str, threshold: int) -> Dict[str, List[Dict]]:
+    """Return the HL API errors of the scanners whose breakers tripped in previous runs that have finished, by
+    scanner, and delete the folders of those runs, so each trip is alerted once. The folders of runs that are still
+    going, such as an overlapping monitor run, are left for them."""
+    tripped = {}
+    try:
+        entries = list(workspace_client().workspace.list(get_breaker_root()))
+    except ResourceDoesNotExist:
+        return tripped
+    for entry in entries:
+        run_id = entry.path.rstrip("/").split("/")[-1]
+        if run_id == monitor_run_id or not is_run_finished(run_id):
+            continue
+        for scanner, errors in read_hl_errors(entry.path).items():
+            if threshold and len(errors) >= threshold:
+                tripped.setdefault(scanner, []).extend(errors)
+        workspace_client().workspace.delete(entry.path, recursive=True)
+    return tripped
+
+def circuit_breaker_alert(tripped: Dict[str, List[Dict]]) -> str:
+    """Return one alert for the scanners whose breakers tripped, with the number of versions deferred and the latest
+    error of each."""
+    lines = []
+    for scanner, errors in sorted(tripped.items()):
+        latest = max(errors, key=lambda error: error.get("at", ""))
+        lines.append(f"scanner {scanner}: {len(errors)} scan job(s) hit HiddenLayer API errors, such as: "
+                     f"{latest.get('message', 'unknown error')}")
+    return ("HiddenLayer circuit breaker tripped, scans were stopped and the affected model versions will be "
+            "retried:\n" + "\n".join(lines))
+
+# COMMAND ----------
+
+def count_active_scan_runs() -> int:
+    """Return the number of scan job runs that are active, whether the monitoring or the on-demand scan job started
+    them, by the names that scan_model() gives them."""
+    return sum(1 for run in workspace_client().jobs.list_runs(active_only=True)
+               if (run.run_name or "").startswith(HL_SCAN_JOB_PREFIX))
+
+def wait_for_scan_slot(max_active_scan_jobs: int, timeout_minutes: int) -> None:
+    """Wait until fewer scan jobs than max_active_scan_jobs are running, so that on-demand scans share that budget
+    with the monitoring job's. Raise an exception if none frees up in time."""
+    deadline = time.time() + timeout_minutes * 60
+    while (active := count_active_scan_runs()) >= max_active_scan_jobs:
+        if time.time() > deadline:
+            raise Exception(f"{active} scan jobs are still running after {timeout_minutes} minutes, at "
+                            f"max_active_scan_jobs {max_active_scan_jobs}")
+        print(f"{active} scan jobs are running, at max_active_scan_jobs {max_active_scan_jobs}, waiting for one to end")
+        time.sleep(SCAN_SLOT_POLL_SECS)
+
+def scan_on_demand(config: Configuration, full_model_name: str, model_version_num: str) -> Dict:
+    """Scan a model version now, for the on-demand scan job, and wait for the scan to finish. Return its result.
+    The version must be in a monitored schema, and not being scanned already. The scan waits for a free slot among
+    the max_active_scan_jobs scan jobs."""
+    scanner = scanner_for_model(config.catalogs_and_schemas, full_model_name)
+    mv = get_model_version(full_model_name, int(model_version_num))
+    tags = mv.tags or {}
+    if tags.get(HL_SCAN_STATUS) == STATUS_PENDING:
+        raise Exception(f"Model {mv.name} version {mv.version} is being scanned already, by job run "
+                        f"{tags.get(HL_SCAN_RUN_ID, 'unknown')}")
+    if config.coordination_table:
+        create_coordination_table(config.coordination_table)
+        if not claim_model_version(config.coordination_table, get_metastore_id(),
+                                   str(current_workspace_id()), mv, HL_SCAN_NOTEBOOK_TIMEOUT_MINS):
+            raise Exception(f"Model {mv.name} version {mv.version} is being scanned by the install of another "
+                            f"workspace on this metastore, see {config.coordination_table}")
+    wait_for_scan_slot(config.max_active_scan_jobs, HL_SCAN_NOTEBOOK_TIMEOUT_MINS)
+    run_id = scan_model(mv, config.hl_api_key_name, scanner.api_url, scanner.auth_url, scanner.console_url,
+                        HL_SCAN_NOTEBOOK_TIMEOUT_MINS, egress_params=config.egress_params,
+                        scan_comments=config.scan_comments, findings_sink=config.findings_sink,
+                        scan_metadata_params=config.scan_metadata_params, compute_params=config.compute_params,
+                        outage_policy=config.outage_policy, hl_auth=scanner.auth,
+                        model_map_table=config.model_map_table)
+    print(f"Scanning model {mv.name} version {mv.version}, job run_id is {run_id}")
+    workspace_client().jobs.wait_get_run_job_terminated_or_skipped(
+        run_id=run_id, timeout=timedelta(minutes=HL_SCAN_NOTEBOOK_TIMEOUT_MINS))
+    tags = get_model_version(full_model_name, int(model_version_num)).tags or {}
+    return {"model": mv.name,
+            "version": str(mv.version),
+            "status": tags.get(HL_SCAN_STATUS, STATUS_UNSCANNED),
+            "threat_level": tags.get(HL_SCAN_THREAT_LEVEL, ""),
+            "safe": is_scan_safe(tags),
+            "scan_url": tags.get(HL_SCAN_URL, ""),
+            "message": tags.get(HL_SCAN_MESSAGE, ""),
+            "scan_run_id": str(run_id)}
+
+# COMMAND ----------
+
 # *** MAIN CELL THAT DRIVES EVERYTHING ***
 # Poll for new model versions and scan as needed
 
 config = get_job_params()
+# The on-demand scan job runs this notebook for a single model version, which it scans instead of polling
+on_demand_model = dbutils.widgets.getAll().get("full_model_name", "")
+if on_demand_model:
+    result = scan_on_demand(config, on_demand_model, dbutils.widgets.get("model_version_num"))
+    print(f"Model {result['model']} version {result['version']}: HiddenLayer scan status is '{result['status']}', "
+          f"threat level is '{result['threat_level'] or 'unknown'}' {result['scan_url']}")
+    dbutils.notebook.exit(json.dumps(result))
+started_at = datetime.now(timezone.utc)
+# Task values are read by the heartbeat task (hl_heartbeat.py) that follows this one. The names must match it.
+dbutils.jobs.taskValues.set(key="started_at", value=started_at.isoformat())
 active_jobs = []
 models_to_scan = []
+outage_backlog = []
+registering = []
+num_skipped_external = 0
+
+# Alert once for the breakers that tripped since the previous run, and probe their scanners with a single scan each
+breaker_dir = get_breaker_dir(config.monitor_run_id) if config.monitor_run_id and config.breaker_threshold else ""
+tripped = collect_tripped_breakers(config.monitor_run_id, config.breaker_threshold) if breaker_dir else {}
+if tripped:
+    print(circuit_breaker_alert(tripped))
+probing = set(tripped)
+
+# With audit discovery, only look at the models changed since the previous run, and those it left versions to scan in.
+# The first run, without a checkpoint, lists every model.
+changed_models = None
+discovery_since = started_at
+if config.discovery_source == DISCOVERY_SOURCE_AUDIT:
+    checkpoint = read_discovery_checkpoint()
+    if checkpoint:
+        discovery_since = datetime.fromisoformat(checkpoint["since"])
+        names, latest = get_changed_models(config.catalogs_and_schemas,
+                                           discovery_since - timedelta(hours=DISCOVERY_AUDIT_OVERLAP_HOURS))
+        changed_models = sorted(set(names) | set(checkpoint.get("tracked", [])))
+        if latest is not None:
+            discovery_since = max(discovery_since, latest.replace(tzinfo=latest.tzinfo or timezone.utc))
+        print(f"Audit discovery found {len(changed_models)} model(s) to check since {discovery_since.isoformat()}")
 
 for catalog_schema in config.catalogs_and_schemas:
-    mv_dict: Dict[str, List[ModelVersion]] = get_model_versions_by_status(catalog_schema.catalog, catalog_schema.schema, [STATUS_NONE, STATUS_PENDING])
+    models = None
+    if changed_models is not None:
+        models = get_registered_models(catalog_schema.catalog, catalog_schema.schema, changed_models)
+    mv_dict: Dict[str, List[ModelVersion]] = get_model_versions_by_status(catalog_schema.catalog, catalog_schema.schema,
+                                                                          [STATUS_NONE, STATUS_PENDING, STATUS_SCAN_PENDING],
+                                                                          config.scan_trigger, config.scan_aliases,
+                                                                          models, config.skip_external_versions)
 
     # Do one-time init if needed
     if not is_init_done():
-        init(catalog_schema.catalog, catalog_schema.schema)
+        init(catalog_schema.catalog, catalog_schema.schema, config.scan_trigger, config.scan_aliases)
 
     models_to_scan.extend(mv_dict[STATUS_NONE])
+    outage_backlog.extend(mv_dict[STATUS_SCAN_PENDING])
+    registering.extend(mv_dict[VERSIONS_REGISTERING])
+    num_skipped_external += len(mv_dict[VERSIONS_EXTERNAL])
     # Mark timed-out jobs as failed.
     current_active_jobs = handle_job_timeouts(mv_dict[STATUS_PENDING], HL_SCAN_NOTEBOOK_TIMEOUT_MINS)
     active_jobs.extend(current_active_jobs)
 
+if registering:
+    print(f"{len(registering)} model version(s) are still being registered, they are scanned once they are READY")
+if num_skipped_external:
+    print(f"Skipped {num_skipped_external} model version(s) registered from outside this workspace, "
+          "see skip_external_versions")
+
+# Retry the versions that couldn't be scanned because the HL API was unreachable, after the new ones
+if outage_backlog:
+    print(f"Warning: {len(outage_backlog)} model version(s) are waiting to be scanned since the HiddenLayer API "
+          "was unreachable, retrying them")
+    models_to_scan.extend(outage_backlog)
+
+# With a coordination table, only scan the versions that this install claims
+if config.coordination_table:
+    create_coordination_table(config.coordination_table)
+    metastore_id = get_metastore_id()
+    workspace_id = str(current_workspace_id())
+
 # Light up scan jobs, up to the limit.
 # Note: our client-side scan status goes directly from pending to done. There is an intermediate "running" state
 # on the server side, but that's not exposed through the Python SDK, which we call synchronously. 
 num_active_jobs = len(active_jobs)
-max_new_jobs = max(MAX_ACTIVE_SCAN_JOBS - num_active_jobs, 0)
-num_new_jobs = min(max_new_jobs, len(models_to_scan))
-for i in range(num_new_jobs):
-    mv = models_to_scan[i]
-    run_id = scan_model(mv, config.hl_api_key_name, config.hl_api_url, config.hl_console_url, HL_SCAN_NOTEBOOK_TIMEOUT_MINS)
+max_new_jobs = max(config.max_active_scan_jobs - num_active_jobs, 0)
+num_new_jobs = 0
+num_claimed_elsewhere = 0
+held_by_breaker = defaultdict(int)
+# The breakers are checked once for the run, rather than for each version
+scanner_names = {cs.scanner.name for cs in config.catalogs_and_schemas}
+open_breakers = {name for name in scanner_names if is_circuit_open(breaker_dir, name)}
+for mv in models_to_scan:
+    if num_new_jobs >= max_new_jobs:
+        break
+    scanner = scanner_for_model(config.catalogs_and_schemas, mv.name)
+    if (scanner.name in tripped and scanner.name not in probing) or scanner.name in open_breakers:
+        # Left as they are, to be scanned on a later run once the scanner recovers
+        held_by_breaker[scanner.name] += 1
+        continue
+    if config.coordination_table and not claim_model_version(config.coordination_table, metastore_id, workspace_id,
+                                                             mv, HL_SCAN_NOTEBOOK_TIMEOUT_MINS):
+        num_claimed_elsewhere += 1
+        continue
+    num_new_jobs += 1
+    probing.discard(scanner.name)
+    run_id = scan_model(mv, config.hl_api_key_name, scanner.api_url, scanner.auth_url, scanner.console_url,
+                        HL_SCAN_NOTEBOOK_TIMEOUT_MINS, egress_params=config.egress_params,
+                        scan_comments=config.scan_comments, findings_sink=config.findings_sink,
+                        scan_metadata_params=config.scan_metadata_params, compute_params=config.compute_params,
+                        outage_policy=config.outage_policy, hl_auth=scanner.auth,
+                        model_map_table=config.model_map_table, scanner_name=scanner.name, breaker_dir=breaker_dir,
+                        breaker_threshold=config.breaker_threshold)
     print(f"Scanning model {mv.name} version {mv.version}, job run_id is {run_id}")
+for scanner_name, num_held in sorted(held_by_breaker.items()):
+    print(f"Held back {num_held} model version(s) of scanner {scanner_name}, whose circuit breaker is open, "
+          "they are scanned once it recovers")
+if num_claimed_elsewhere:
+    print(f"Skipped {num_claimed_elsewhere} model version(s) that the install of another workspace on this metastore "
+          f"is scanning, see {config.coordination_table}")
+
+if config.discovery_source == DISCOVERY_SOURCE_AUDIT:
+    # Keep checking the models with versions that are waiting to be scanned, being scanned, or being registered
+    tracked = {mv.name.lower() for mv in models_to_scan + active_jobs + registering}
+    write_discovery_checkpoint(discovery_since, list(tracked))
+
+if config.serving_guardrail:
+    report_unsafe_served_versions(config.catalogs_and_schemas)
+
+dbutils.jobs.taskValues.set(key="versions_found", value=len(models_to_scan) - len(outage_backlog))
+dbutils.jobs.taskValues.set(key="scans_started", value=num_new_jobs)
+if tripped:
+    # Fail the run once for all the versions that the breakers deferred, so that job failure notifications alert
+    # someone, rather than once per scan job
+    dbutils.jobs.taskValues.set(key="status", value="circuit_open")
+    raise Exception(circuit_breaker_alert(tripped))
+dbutils.jobs.taskValues.set(key="status", value="ok")
@@ -0,0 +1,198 @@
+# Databricks notebook source
+# MAGIC %restart_python
+
+# COMMAND ----------
+
+# This notebook scans prompt and agent artifacts for risks, using the HiddenLayer (HL) Model Scanner.
+# These are files other than registered models, such as agent and tool configurations and prompt files, stored in
+# Unity Catalog (UC) Volumes or logged as MLflow artifacts. HL picks the analyzers for each file by its type.
+# The outcome of each file's scan is recorded in the HL artifact state table, which also tells which files are new.
+# Runs as a task of the model monitoring job.
+# Python version: 3.11+
+
+# Job parameters:
+# * artifact_sources (string) - JSON list of the sources to scan, each with:
+#   * name - name of the source, used as the model name in the HL console
+#   * type - "volume" for files under a UC Volume path, or "mlflow_experiment" for the artifacts of an experiment's runs
+#   * path - for volume sources, the /Volumes/... path to scan
+#   * experiment - for mlflow_experiment sources, the experiment name, e.g. /Shared/agents
+#   * patterns - glob patterns of the file paths to scan, relative to the source, e.g. ["*.yaml", "prompts/*"]
+# * state_table (string) - full name of the HL state table; artifact scans are recorded in <state_table>_artifacts
+# * schemas (string) - JSON list of the monitored schemas; the first one's secrets scope has the HL credentials
+# * hl_api_key_name, hl_api_url, hl_console_url (string) - as for hl_scan_model.py
+# * hl_https_proxy, hl_no_proxy, hl_ca_bundle_path (string) - Optional egress settings for reaching the HL API through a proxy
+# * hl_tls_min_version (string) - Optional minimum TLS version for the HL API, 1.2 or 1.3
+# * hl_tls_pins (string) - Optional comma-separated sha256//<base64> pins of the HL endpoints' certificate public keys
+# * user_agent_suffix (string) - Optional deployment ID appended to the user agent of HL API requests
+# * scan_origin, scan_metadata (string) - Optional origin and metadata sent with each scan, as for hl_scan_model.py
+
+# COMMAND ----------
+
+# Install the HiddenLayer SDK if it's not there already.  Pin the version to avoid surprises.
+
+import importlib
+from IPython.display import display, Javascript
+
+if not importlib.util.find_spec("hiddenlayer"):
+    # same as "%pip install" but we can't do that within an if statement
+    get_ipython().run_line_magic('pip', 'install hiddenlayer-sdk==3.2.0')
+    # same as "%restart_python" but we can't do that within an if statement
+    display(Javascript('Jupyter.notebook.kernel.restart()'))
+
+# COMMAND ----------
+
+# MAGIC %restart_python
+
+# COMMAND ----------
+
+# Import HL code that is shared across notebooks
+
+from hl_common import *
+from hl_api import *
+
+# COMMAND ----------
+
+import fnmatch
+import shutil
+import tempfile
+from dataclasses import dataclass
+from datetime import datetime, timezone
+
+from databricks.sdk.runtime import spark
+
+# Artifact source types. These must match the Go code.
+SOURCE_VOLUME = "volume"
+SOURCE_MLFLOW_EXPERIMENT = "mlflow_experiment"
+
+@dataclass
+class Artifact:
+    """A file to scan, identified by its URI and last modification time (0 if it can't change)."""
+    uri: str
+    relative_path: str
+    modified: int
+
+def matches(relative_path: str, patterns: List[str]) -> bool:
+    """Return true if the path, or its file name, matches one of the glob patterns."""
+    file_name = relative_path.rsplit("/", 1)[-1]
+    return any(fnmatch.fnmatch(relative_path, p) or fnmatch.fnmatch(file_name, p) for p in patterns or ["*"])
+
+def list_volume_artifacts(path: str, patterns: List[str]) -> List[Artifact]:
+    """List the files under a UC Volume path that match the patterns."""
+    artifacts = []
+    pending = [path.rstrip("/")]
+    while pending:
+        for info in dbutils.fs.ls(pending.pop()):
+            if info.isDir():
+                pending.append(info.path.rstrip("/"))
+                continue
+            # dbutils.fs.ls returns dbfs:/Volumes/... paths for Volumes
+            file_path = info.path.removeprefix("dbfs:")
+            relative_path = file_path.removeprefix(path.rstrip("/") + "/")
+            if matches(relative_path, patterns):
+                artifacts.append(Artifact(file_path, relative_path, info.modificationTime))
+    return artifacts
+
+def list_experiment_artifacts(experiment_name: str, patterns: List[str]) -> List[Artifact]:
+    """List the artifacts of an MLflow experiment's runs that match the patterns. Logged artifacts can't change."""
+    client = mlflow_client()
+    experiment = client.get_experiment_by_name(experiment_name)
+    if experiment is None:
+        print(f"Warning: MLflow experiment {experiment_name} not found")
+        return []
+    artifacts = []
+    for run in client.search_runs([experiment.experiment_id]):
+        pending = [""]
+        while pending:
+            for info in client.list_artifacts(run.info.run_id, pending.pop()):
+                if info.is_dir:
+                    pending.append(info.path)
+                elif matches(info.path, patterns):
+                    artifacts.append(Artifact(f"runs:/{run.info.run_id}/{info.path}", f"{run.info.run_id}/{info.path}", 0))
+    return artifacts
+
+def get_scanned_artifacts(artifact_table: str, source_name: str) -> set:
+    """Return the (uri, modified) pairs already scanned for the source."""
+    if not spark.catalog.tableExists(artifact_table):
+        return set()
+    rows = spark.sql(f"SELECT uri, modified FROM {artifact_table} WHERE source = :source",
+                     args={"source": source_name}).collect()
+    return {(row.uri, row.modified) for row in rows}
+
+def download_artifacts(artifacts: List[Artifact], dest_dir: str) -> None:
+    """Copy the artifacts into the local directory, keeping their paths relative to the source."""
+    client = mlflow_client()
+    for artifact in artifacts:
+        dest = os.path.join(dest_dir, artifact.relative_path)
+        os.makedirs(os.path.dirname(dest), exist_ok=True)
+        if artifact.uri.startswith("runs:/"):
+            run_id, path = artifact.uri.removeprefix("runs:/").split("/", 1)
+            downloaded = client.download_artifacts(run_id, path, tempfile.mkdtemp(dir=dest_dir + "_downloads"))
+            shutil.move(downloaded, dest)
+        else:
+            # UC Volumes are mounted at /Volumes on UC-enabled clusters
+            shutil.copy(artifact.uri, dest)
+
+def record_scans(artifact_table: str, source_name: str, artifacts: List[Artifact], status: str, threat_level: str,
+                 scan_id: str, scan_url: str) -> None:
+    """Append the outcome of a scan to the artifact state table, one row per file."""
+    spark.sql(f"""CREATE TABLE IF NOT EXISTS {artifact_table} (
+        source STRING, uri STRING, modified BIGINT, scanned_at STRING, status STRING, threat_level STRING,
+        scan_id STRING, scan_url STRING)""")
+    scanned_at = datetime.now(timezone.utc).isoformat()
+    rows = [{"source": source_name, "uri": a.uri, "modified": a.modified, "scanned_at": scanned_at, "status": status,
+             "threat_level": threat_level, "scan_id": scan_id, "scan_url": scan_url} for a in artifacts]
+    spark.createDataFrame(rows, schema=spark.table(artifact_table).schema).write.mode("append").saveAsTable(artifact_table)
+
+# COMMAND ----------
+
+# *** MAIN CELL THAT DRIVES EVERYTHING ***
+# Scan the new and changed files of each source, as one HL scan per source.
+
+widgets_to_values = dbutils.widgets.getAll()
+sources = json.loads(widgets_to_values["artifact_sources"])
+artifact_table = widgets_to_values["state_table"] + "_artifacts"
+first_schema = json.loads(widgets_to_values["schemas"])[0]
+hl_api_url = widgets_to_values.get("hl_api_url")
+hl_console_url = widgets_to_values.get("hl_console_url")
+scan_metadata = get_scan_metadata(widgets_to_values)
+
+if is_enterprise_scanner(hl_api_url):
+    # enterprise scanner does not require creds
+    hl_creds = HLCredentials(client_id="", client_secret="")
+else:
+    hl_creds = get_hl_api_creds(first_schema["catalog"], first_schema["schema"], widgets_to_values["hl_api_key_name"])
+configure_egress(get_egress_params(widgets_to_values))
+hl_client = hl_auth(hl_creds, hl_api_url, get_hl_environment(hl_api_url, widgets_to_values.get("hl_environment")),
+                    widgets_to_values.get("hl_auth_url"))
+
+for source in sources:
+    if source["type"] == SOURCE_VOLUME:
+        artifacts = list_volume_artifacts(source["path"], source.get("patterns"))
+    elif source["type"] == SOURCE_MLFLOW_EXPERIMENT:
+        artifacts = list_experiment_artifacts(source["experiment"], source.get("patterns"))
+    else:
+        raise ValueError(f"Unknown artifact source type {source['type']}")
+    scanned = get_scanned_artifacts(artifact_table, source["name"])
+    new_artifacts = [a for a in artifacts if (a.uri, a.modified) not in scanned]
+    if not new_artifacts:
+        print(f"No new artifacts in source {source['name']}")
+        continue
+
+    print(f"Scanning {len(new_artifacts)} new artifact(s) in source {source['name']}")
+    with tempfile.TemporaryDirectory(prefix="hl_scan_artifacts_", dir="/tmp") as temp_dir:
+        local_dir = os.path.join(temp_dir, "files")
+        os.makedirs(local_dir + "_downloads")
+        download_artifacts(new_artifacts, local_dir)
+        # Each scan is a new version of the source's model in the HL console, and versions must be unique
+        version = datetime.now(timezone.utc).strftime("%Y%m%d%H%M%S")
+        scan_report = hl_client.model_scanner.scan_folder(
+            model_name=source["name"], model_version=version, path=local_dir,
+            **scan_request_kwargs(hl_client, widgets_to_values.get("scan_origin"), scan_metadata))
+
+    scan_url = None
+    if hl_console_url:
+        scan_url = f"{hl_console_url}/model-details/{scan_report.inventory.model_id}/scans/{scan_report.scan_id}"
+    record_scans(artifact_table, source["name"], new_artifacts, scan_report.status, scan_report.severity,
+                 scan_report.scan_id, scan_url)
+    if scan_report.status == STATUS_DONE and (scan_report.severity or "").lower() not in SAFE_THREAT_LEVELS:
+        print(f"Warning: HiddenLayer found {scan_report.severity} threats in source {source['name']}: {scan_url}")
@@ -13,6 +13,27 @@
 # * model_version_num (int) - MLflow version to be scanned
 # * hl_api_key_name (string) - name of the HL API key, used to get credentials from the Databricks secrets store
 # * hl_api_url (string) - Optional parameter to enable the scanner to use an Enterprise self-hosted model scanner
+# * hl_auth (string) - Optional, how to authenticate to the scanner: "client_credentials" or "none". Defaults to
+#   client_credentials for the HL SaaS scanner, and none for other API URLs
+# * hl_auth_url (string) - Optional auth URL of the scanner, where client credentials are exchanged for a token
+# * hl_https_proxy, hl_no_proxy, hl_ca_bundle_path (string) - Optional egress settings for reaching the HL API through a proxy
+# * hl_tls_min_version (string) - Optional minimum TLS version for the HL API, 1.2 or 1.3
+# * hl_tls_pins (string) - Optional comma-separated sha256//<base64> pins of the HL endpoints' certificate public keys
+# * user_agent_suffix (string) - Optional deployment ID appended to the user agent of HL API requests
+# * scan_comments (string) - Optional, "true" to write a scan summary into the model version comment
+# * findings_sink (string) - Optional URI of a sink to export detections to, in OCSF format (see hl_sinks.py)
+# * scan_origin (string) - Optional origin of the scan shown in the HL console, defaults to "Databricks"
+# * scan_metadata (string) - Optional JSON object of metadata sent with the scan, e.g. workspace, environment, team
+# * requesting_job_run_id (string) - Optional ID of the monitoring job run that requested the scan, added to the metadata
+# * outage_policy (string) - Optional, what to do if the HL API is unreachable: "fail_open" (default) marks the version
+#   scan_pending, "fail_closed" also quarantines it so the serving guardrail blocks it until it is scanned
+# * model_map_table (string) - Optional full name of the Delta table that maps model versions to their HL model and
+#   scan IDs, which a finished scan updates
+# * circuit_breaker_dir (string) - Optional workspace folder where the scan jobs of the requesting monitor run record their
+#   HL API errors, for the circuit breaker (see hl_common.py)
+# * circuit_breaker_threshold (int) - Optional number of HL API errors with the scanner after which the scan jobs of the
+#   monitor run defer their versions without calling the HL API, 0 turns the breaker off
+# * hl_scanner (string) - Optional name of the scanner, which the circuit breaker counts errors by
 
 # Steps:
 # Retrieve the job parameters
@@ -50,6 +71,8 @@ if not importlib.util.find_spec("hiddenlayer"):
 # Import HL code that is shared across notebooks
 
 from hl_common import *
+from hl_api import *
+from hl_sinks import *
 
 # COMMAND ----------
 
@@ -59,8 +82,20 @@ class Configuration:
     model_version_num: str
     hl_api_key_name: str
     hl_api_url: str
+    hl_auth_url: str
+    hl_auth: str
     hl_environment: str
     hl_console_url: str
+    egress_params: Dict[str, str]
+    scan_comments: bool
+    findings_sink: str
+    scan_origin: str
+    scan_metadata: Dict[str, str]
+    outage_policy: str
+    model_map_table: str
+    breaker_dir: str
+    breaker_threshold: int
+    scanner: str
 
     def __init__(
         self,
@@ -68,15 +103,38 @@ class Configuration:
         model_version_num,
         hl_api_key_name,
         hl_api_url,
+        hl_auth_url,
+        hl_auth,
         hl_console_url,
         hl_environment,
+        egress_params, scan_comments,
+        findings_sink,
+        scan_origin,
+        scan_metadata,
+        outage_policy,
+        model_map_table,
+        breaker_dir,
+        breaker_threshold,
+        scanner,
     ):
         self.full_model_name = full_model_name
         self.model_version_num = model_version_num
         self.hl_api_key_name = hl_api_key_name
         self.hl_api_url = hl_api_url
+        self.hl_auth_url = hl_auth_url
+        self.hl_auth = hl_auth
         self.hl_environment = hl_environment
         self.hl_console_url = hl_console_url
+        self.egress_params = egress_params
+        self.scan_comments = scan_comments
+        self.findings_sink = findings_sink
+        self.scan_origin = scan_origin
+        self.scan_metadata = scan_metadata
+        self.outage_policy = outage_policy
+        self.model_map_table = model_map_table
+        self.breaker_dir = breaker_dir
+        self.breaker_threshold = breaker_threshold
+        self.scanner = scanner
 
 # In production, parameters are passed in.
 # For interactive debugging, set parameters here to whatever you need.
@@ -97,29 +155,14 @@ def get_job_params() -> Configuration:
     ), "model_version_num is a required job parameter"
 
     hl_api_url = widgets_to_values["hl_api_url"]
-    hl_environment = None
-    if "hl_environment" in widgets_to_values.keys():
-        hl_environment = widgets_to_values["hl_environment"]
-
-    if hl_environment is None and hl_api_url is None:
-        # default to prod-us environment
-        hl_environment = "prod-us"
-    elif hl_environment is None:
-        # an api url was provided
-        # determine if api url is pointing at a HL Saas API or an on prem scanner
-        if is_enterprise_scanner(hl_api_url):
-            hl_environment = None
-        elif hl_api_url == "https://api.eu.hiddenlayer.ai":
-            hl_environment = "prod-eu"
-        elif hl_api_url == "https://api.us.hiddenlayer.ai":
-            hl_environment = "prod-us"
-        else:
-            raise ValueError("Invalid hl_api_url")
+    hl_auth_url = widgets_to_values.get("hl_auth_url") or None
+    hl_auth = scanner_auth(hl_api_url, widgets_to_values.get("hl_auth"))
+    hl_environment = get_hl_environment(hl_api_url, widgets_to_values.get("hl_environment"), hl_auth)
 
     hl_console_url = None
     hl_api_key_name = None
 
-    if not is_enterprise_scanner(hl_api_url):
+    if hl_auth == SCANNER_AUTH_CLIENT_CREDENTIALS:
         hl_api_key_name = widgets_to_values["hl_api_key_name"]
         assert hl_api_key_name is not None, "hl_api_key_name is a required job parameter"
 
@@ -134,8 +177,23 @@ def get_job_params() -> Configuration:
             f"model_version_num job parameter must be an integer, got '{model_version_num}'"
         )
 
+    egress_params = get_egress_params(widgets_to_values)
+    scan_comments = widgets_to_values.get("scan_comments") == "true"
+    findings_sink = widgets_to_values.get("findings_sink", "")
+    scan_origin = widgets_to_values.get("scan_origin") or DEFAULT_SCAN_ORIGIN
+    scan_metadata = get_scan_metadata(widgets_to_values)
+    outage_policy = widgets_to_values.get("outage_policy") or OUTAGE_POLICY_FAIL_OPEN
+    assert outage_policy in [OUTAGE_POLICY_FAIL_OPEN, OUTAGE_POLICY_FAIL_CLOSED], f"invalid outage_policy {outage_policy}"
+    model_map_table = widgets_to_values.get("model_map_table", "")
+    breaker_dir = widgets_to_values.get("circuit_breaker_dir", "")
+    breaker_threshold = get_circuit_breaker_threshold(widgets_to_values) if breaker_dir else 0
+    scanner = widgets_to_values.get("hl_scanner") or hl_api_url
+
     return Configuration(
-        full_model_name, model_version_num, hl_api_key_name, hl_api_url, hl_console_url, hl_environment
+        full_model_name, model_version_num, hl_api_key_name, hl_api_url, hl_auth_url, hl_auth, hl_console_url, hl_environment,
+        egress_params,
+        scan_comments, findings_sink, scan_origin, scan_metadata, outage_policy, model_map_table, breaker_dir,
+        breaker_threshold, scanner
     )
 
 # COMMAND ----------
@@ -182,12 +240,25 @@ def tag_for_scanning(model_version: ModelVersion) -> None:
 
 # COMMAND ----------
 
+# Model versions registered from outside this workspace are downloaded from Unity Catalog, see hl_common.py
+
+from databricks.sdk import WorkspaceClient
+
+def is_external(model_version: ModelVersion) -> bool:
+  """Return True if the model version was registered from outside this workspace. MLflow doesn't return the workspace
+  of the version's run, so get it from Unity Catalog."""
+  client = WorkspaceClient()
+  info = client.model_versions.get(model_version.name, int(model_version.version))
+  return is_external_model_version(info.run_workspace_id, client.get_workspace_id())
+
+# COMMAND ----------
+
 import sys
 from mlflow.entities.model_registry import ModelVersion
 
 def fail_and_exit_with_message(model_version: ModelVersion, message: str) -> None:
-    # Erase all previous tags, except keep the run_id for debugging
-    clear_tags(model_version, [HL_SCAN_RUN_ID])
+    # Erase all previous tags, except keep the run_id for debugging, and a quarantine until a scan succeeds
+    clear_tags(model_version, [HL_SCAN_RUN_ID, HL_SCAN_QUARANTINE])
 
     set_model_version_tag(model_version, HL_SCAN_STATUS, STATUS_FAILED)
     set_model_version_tag(model_version, HL_SCAN_MESSAGE, message)
@@ -196,83 +267,36 @@ def fail_and_exit_with_message(model_version: ModelVersion, message: str) -> Non
     # Raise an exception, rather than calling dbutils.notebook.exit(), so that the job will show as failed.
     raise Exception(f"Scanning model {model_version.name}, version {model_version.version} failed: {message}")
 
+def defer_and_exit_with_message(model_version: ModelVersion, message: str, outage_policy: str) -> None:
+    """The HL API is unreachable. Mark the model version scan_pending, so the monitor job retries it, and under the
+    fail_closed policy quarantine it until then."""
+    defer_model_version(model_version, message, outage_policy)
+
+    # Fail the job, so that job failure notifications alert someone to the outage
+    raise Exception(f"Scanning model {model_version.name}, version {model_version.version} is deferred: {message}")
+
+def defer_quietly_and_exit(model_version: ModelVersion, message: str, outage_policy: str) -> None:
+    """The circuit breaker is open. Mark the model version scan_pending like defer_and_exit_with_message(), but let
+    the job succeed, since the monitor run raises one alert for all the versions it deferred."""
+    defer_model_version(model_version, message, outage_policy)
+    print(f"Scanning model {model_version.name}, version {model_version.version} is deferred: {message}")
+    dbutils.notebook.exit(message)
+
 # COMMAND ----------
 
-# Fetch and cache HiddenLayer API credentials
+# Fetch and cache HiddenLayer API credentials, with get_hl_api_creds() in hl_api.py
 
 # Prerequisite: HiddenLayer credentials must be stored in the Databricks secrets store.
 # The installer should take care of that.
-# The secrets scope is "hl_scan.<catalog>.<schema>", allowing each schema to have its own credentials.
-
-# For testing purposes, you can run these commands in a Linux shell to set up credentials for testing:
-# databricks auth login --host <https URL for your Databricks cluster>
-# databricks secrets create-scope yourscope
-# databricks secrets put-secret yourscope <key_name> --string-value "<client_id>:<client_secret>"
-
-from collections import defaultdict
-from dataclasses import dataclass
-
-def secrets_scope(catalog: str, schema: str) -> str:
-    """Given the Unity Catalog catalog and schema, use that to create and return a secrets scope name
-    that is unique within the workspace."""
-    return f"hl_scan.{catalog}.{schema}"
-
-@dataclass
-class HLCredentials:
-    client_id: str
-    client_secret: str
-    def __repr__(self):
-        """Return a string representation of the credentials.
-        Include only part of the client secret to avoid leaking it."""
-        return f"HLCredentials(client_id={self.client_id}, client_secret={self.client_secret[0:4]}...)"
-
-class BadHLCredentials(Exception):
-    """Custom exception for bad HiddenLayer credentials."""
-    def __init__(self, message):
-        super().__init__(message)
-
-_hl_api_creds = defaultdict(dict)       # Each entry is a scope dict
-def get_hl_api_creds(catalog: str, schema: str, hl_api_key_name: str):
-    """Return the credentials for the given catalog and schema. Cache them."""
-    scope = secrets_scope(catalog, schema)
-    global _hl_api_creds
-    scope_dict = _hl_api_creds[scope]   # will be non-empty because of defaultdict
-    creds: HLCredentials = scope_dict.get(hl_api_key_name)
-    if not creds:
-        secret = dbutils.secrets.get(scope, hl_api_key_name)
-        if not secret:
-            raise BadHLCredentials(f"No secret found for {hl_api_key_name} in scope {scope}")
-        if not ":" in secret:
-            raise BadHLCredentials(f"Invalid secret for {hl_api_key_name} in scope {scope}: must be a colon-separated client_id:client_secret string")
-        client_id, client_secret = secret.split(":")
-        creds = HLCredentials(client_id=client_id, client_secret=client_secret)
-        scope_dict[hl_api_key_name] = creds
-    return creds
-
-# Manual test
-# creds = get_hl_api_creds("integrations_sandbox", "default", "hiddenlayer-key")
-# print(creds)  # only a few chars of the client secret will be printed out, so this is OK
+# The secrets scope is "hl_scan.<catalog>.<schema>", allowing each schema to have its own credentials. If the workspace had
+# no secret scopes left, they are in the consolidated "hl_scan" scope instead, under keys prefixed with "<catalog>.<schema>.".
 
 # COMMAND ----------
 
 # Scan the model folder using the HiddenLayer API
 
-from hiddenlayer import HiddenLayer
 from hiddenlayer.types.scans import ScanReport
 
-def hl_auth(hl_creds: HLCredentials, hl_api_url: str, environment: str) -> HiddenLayer:
-    """Return a HiddenLayer authenticated with the given credentials."""
-    if environment is None:
-        # on prem scanner, use the api url directly
-        hl_client = HiddenLayer(base_url=hl_api_url)
-    else:
-        # saas scanner, pass environment and credentials to authenticate
-        hl_client = HiddenLayer(
-            environment=environment,
-            client_id=hl_creds.client_id,
-            client_secret=hl_creds.client_secret)
-    return hl_client
-
 def _reverse_full_model_name(full_model_name: str) -> str:
     """Reverse the order of the full model name, so that the model name goes first, ahead of schema and catalog.
     The model name is the most important info and we want that visible in the HL console UI."""
@@ -280,11 +304,13 @@ def _reverse_full_model_name(full_model_name: str) -> str:
     return f"{parts[2]}.{parts[1]}.{parts[0]}"
 
 def hl_scan_folder(hl_client: HiddenLayer,
-                   full_model_name: str, model_version_num: int, local_dir: str) -> ScanReport:
+                   full_model_name: str, model_version_num: int, local_dir: str,
+                   origin: str = DEFAULT_SCAN_ORIGIN, metadata: Optional[Dict[str, str]] = None) -> ScanReport:
     """Scan model artifacts in the local directory using the credentials. Return the scan results."""
     hl_model_name = _reverse_full_model_name(full_model_name)
     return hl_client.model_scanner.scan_folder(
-        model_name=hl_model_name, model_version=str(model_version_num), path=local_dir, request_source="Integration", origin="Databricks")
+        model_name=hl_model_name, model_version=str(model_version_num), path=local_dir,
+        **scan_request_kwargs(hl_client, origin, metadata))
 
 # Manual test
 # import tempfile
@@ -303,20 +329,100 @@ def hl_scan_folder(hl_client: HiddenLayer,
 
 # After scanning, set model version tags in the registry
 
-def tag_model_version_with_scan_results(model_version: ModelVersion, scan_report: ScanReport, hl_console_url: str):
+def detected_rules(scan_report: ScanReport) -> List[str]:
+    """Return the sorted IDs of the rules that the detections in the scan report matched."""
+    rules = set()
+    for file_result in getattr(scan_report, "file_results", None) or []:
+        for detection in getattr(file_result, "detections", None) or []:
+            rule_id = getattr(detection, "rule_id", None)
+            if rule_id:
+                rules.add(rule_id)
+    return sorted(rules)
+
+def tag_model_version_with_scan_results(model_version: ModelVersion, scan_report: ScanReport, hl_console_url: str,
+                                        digest: str):
     """Tag the model version in the MLflow model registry with the scan results."""
     clear_tags(model_version)   # erase any stale tags
     status = scan_report.status
     set_model_version_tag(model_version, HL_SCAN_STATUS, status)
     if status == "done":
+        # Record the scan ID and artifact digest, so the verdict can be re-applied if the tags are lost
+        set_model_version_tag(model_version, HL_SCAN_ID, scan_report.scan_id)
+        set_model_version_tag(model_version, HL_SCAN_ARTIFACT_DIGEST, digest)
         set_model_version_tag(model_version, HL_SCAN_THREAT_LEVEL, scan_report.severity)
         set_model_version_tag(model_version, HL_SCAN_UPDATED_AT, scan_report.end_time)
         set_model_version_tag(model_version, HL_SCAN_SCANNER_VERSION, scan_report.version)
+        rules = detected_rules(scan_report)
+        if rules:
+            set_model_version_tag(model_version, HL_SCAN_RULES, ",".join(rules))
         if hl_console_url is not None:
             # scan_result.inventory sub object is populated only when using Saas scanner
             hl_scan_url = f"{hl_console_url}/model-details/{scan_report.inventory.model_id}/scans/{scan_report.scan_id}"
             set_model_version_tag(model_version, HL_SCAN_URL, hl_scan_url)
 
+def record_model_mapping(table: str, model_version: ModelVersion, scan_report: ScanReport, hl_api_url: str,
+                         hl_console_url: str) -> None:
+    """Record the HL model and scan IDs of a finished scan in the model map table, replacing those of earlier scans of
+    the model version, so downstream systems can join Databricks lineage with HL data. The columns must match
+    modelMap.go. Failures are reported but don't fail the scan."""
+    if scan_report.status != "done":
+        return
+    hl_model_id = getattr(scan_report.inventory, "model_id", None) if scan_report.inventory else None
+    scan_url = None
+    if hl_console_url is not None and hl_model_id:
+        scan_url = f"{hl_console_url}/model-details/{hl_model_id}/scans/{scan_report.scan_id}"
+    try:
+        spark.sql(f"""CREATE TABLE IF NOT EXISTS {table} (
+            model_name STRING, model_version BIGINT, hl_model_id STRING, scan_id STRING, hl_api_url STRING,
+            scan_url STRING, threat_level STRING, scanned_at STRING, updated_at TIMESTAMP)""")
+        spark.sql(f"""MERGE INTO {table} AS m
+            USING (SELECT :model_name AS model_name, CAST(:model_version AS BIGINT) AS model_version,
+                          :hl_model_id AS hl_model_id, :scan_id AS scan_id, :hl_api_url AS hl_api_url,
+                          :scan_url AS scan_url, :threat_level AS threat_level, :scanned_at AS scanned_at) AS s
+            ON m.model_name = s.model_name AND m.model_version = s.model_version
+            WHEN MATCHED THEN UPDATE SET m.hl_model_id = s.hl_model_id, m.scan_id = s.scan_id,
+                m.hl_api_url = s.hl_api_url, m.scan_url = s.scan_url, m.threat_level = s.threat_level,
+                m.scanned_at = s.scanned_at, m.updated_at = current_timestamp()
+            WHEN NOT MATCHED THEN INSERT (model_name, model_version, hl_model_id, scan_id, hl_api_url, scan_url,
+                threat_level, scanned_at, updated_at)
+                VALUES (s.model_name, s.model_version, s.hl_model_id, s.scan_id, s.hl_api_url, s.scan_url,
+                    s.threat_level, s.scanned_at, current_timestamp())""",
+            args={"model_name": model_version.name, "model_version": str(model_version.version),
+                  "hl_model_id": hl_model_id, "scan_id": scan_report.scan_id, "hl_api_url": hl_api_url,
+                  "scan_url": scan_url, "threat_level": scan_report.severity, "scanned_at": scan_report.end_time})
+    except Exception as e:
+        print(f"Warning: unable to record the HL model and scan IDs in {table}: {e}")
+
+def comment_model_version_with_scan_results(model_version: ModelVersion, scan_report: ScanReport, hl_console_url: str):
+    """Write a short scan summary (verdict, date, report URL) into the model version comment."""
+    summary = f"status {scan_report.status}"
+    if scan_report.status == "done":
+        summary += f", threat level {scan_report.severity}, scanned {scan_report.end_time}"
+        if hl_console_url is not None:
+            summary += f", report {hl_console_url}/model-details/{scan_report.inventory.model_id}/scans/{scan_report.scan_id}"
+    set_scan_comment(model_version, summary)
+
+def export_detections(model_version: ModelVersion, scan_report: ScanReport, hl_console_url: str, findings_sink: str):
+    """If the scan found threats that weren't triaged, export them to the findings sink as an OCSF Detection Finding.
+    Export failures are reported but don't fail the scan."""
+    if scan_report.status != "done" or (scan_report.severity or "").lower() in SAFE_THREAT_LEVELS:
+        return
+    # Findings that a reviewer triaged with "hldbx detections" aren't exported again when the version is rescanned
+    tags = get_model_version(full_model_name=model_version.name, mv_num=model_version.version).tags
+    if is_triaged(tags, detected_rules(scan_report)):
+        print("Not exporting detections, they were triaged")
+        return
+    scan_url = None
+    if hl_console_url is not None:
+        scan_url = f"{hl_console_url}/model-details/{scan_report.inventory.model_id}/scans/{scan_report.scan_id}"
+    finding = to_ocsf_detection_finding(model_version.name, model_version.version, scan_report.scan_id,
+                                        scan_report.severity, scan_report.end_time, scan_url)
+    catalog, schema, _ = parse_full_model_name(model_version.name)
+    try:
+        get_findings_sink(findings_sink, catalog, schema).write(finding)
+    except Exception as e:
+        print(f"Warning: unable to export detections to {findings_sink}: {e}")
+
 # COMMAND ----------
 
 # *** MAIN CELL THAT DRIVES EVERYTHING ***
@@ -333,19 +439,33 @@ print(f"Processing model: {config.full_model_name}, version {config.model_versio
 mv = get_model_version(config.full_model_name, config.model_version_num)
 run_id = mv.run_id
 source = mv.source
+external = is_external(mv)
 
 # Now that we have the model, we can record status going forward by using tags on the model
 # Errors above will cause the notebook to blow out and fail the job.
 # That shouldn't happen. (However, noting that it's possible if unlikely that the model gets deleted before this job runs.)
-if not run_id and not source:
+if mv.status != MODEL_VERSION_STATUS_READY:
+    fail_and_exit_with_message(mv, f"Model version is {mv.status}, not {MODEL_VERSION_STATUS_READY}, so its files may "
+                                   "be incomplete. Scan it again once it's registered")
+if not run_id and not source and not external:
     fail_and_exit_with_message(mv, "Model version has no run_id or source, so we can't scan it")
+# Don't call the HL API once other scan jobs of the monitor run have hit enough errors with it
+if config.breaker_threshold and is_circuit_open(config.breaker_dir, config.scanner):
+    defer_quietly_and_exit(mv, f"Circuit breaker open: scan jobs hit {config.breaker_threshold} or more HiddenLayer API "
+                               f"errors with scanner {config.scanner}, the scan will be retried", config.outage_policy)
 
+# The ID of the Databricks token minted for a scanner with databricks_token auth, revoked however the scan ends
+token_id = None
 try:
     # Download model artifacts to a temporary location for scanning. Prefix the directory name to identify it as holding
     # scan data. Suffix the directory name for uniqueness and to link it to the model version.
     with tempfile.TemporaryDirectory(suffix=config.full_model_name, prefix="hl_scan_", dir="/tmp") as temp_dir:
         client = mlflow_client()
-        if run_id:
+        if external:
+            # The run isn't in this workspace, if there is one, but the files were copied to Unity Catalog on registration
+            print(f"Downloading model artifacts of external model version from {model_version_uri(mv)}")
+            local_path = mlflow.artifacts.download_artifacts(artifact_uri=model_version_uri(mv), dst_path=temp_dir)
+        elif run_id:
             # See https://mlflow.org/docs/latest/python_api/mlflow.client.html?highlight=download_artifacts#mlflow.client.MlflowClient.download_artifacts
             print(f"Downloading model artifacts from run {run_id}")
             local_path = client.download_artifacts(run_id=run_id, path="", dst_path=temp_dir)
@@ -355,25 +475,48 @@ try:
             local_path = mlflow.artifacts.download_artifacts(artifact_uri=source, dst_path=temp_dir)
         #local_path="/tmp/hl_debug"     # for debugging
         catalog, schema, _ = parse_full_model_name(config.full_model_name)
-        if is_enterprise_scanner(config.hl_api_url):
+        if config.hl_auth == SCANNER_AUTH_NONE:
             # enterprise scanner does not require creds
             hl_creds = HLCredentials(client_id="", client_secret="")
+        elif config.hl_auth == SCANNER_AUTH_DATABRICKS_TOKEN:
+            # a scanner behind the same SSO, authenticate with a short-lived token rather than stored credentials
+            hl_creds, token_id = mint_databricks_token()
         else:
             hl_creds = get_hl_api_creds(catalog, schema, config.hl_api_key_name)
-        hl_client = hl_auth(hl_creds, config.hl_api_url, config.hl_environment)
+        configure_egress(config.egress_params)
+        hl_client = hl_auth(hl_creds, config.hl_api_url, config.hl_environment, config.hl_auth_url)
         print(f"Scanning model artifacts in {local_path}")
         # For testing, bump the version number to simulate a new version: or delete the model card in the console UI
         #model_version_num += 2
         tag_for_scanning(mv)
-        scan_report = hl_scan_folder(hl_client, config.full_model_name, config.model_version_num, local_path)
-        tag_model_version_with_scan_results(mv, scan_report, config.hl_console_url)
+        digest = artifact_digest(local_path)
+        scan_metadata = {**config.scan_metadata, ARTIFACT_DIGEST_METADATA_KEY: digest}
+        scan_report = hl_scan_folder(hl_client, config.full_model_name, config.model_version_num, local_path,
+                                     config.scan_origin, scan_metadata)
+        tag_model_version_with_scan_results(mv, scan_report, config.hl_console_url, digest)
+        if config.model_map_table:
+            record_model_mapping(config.model_map_table, mv, scan_report, config.hl_api_url, config.hl_console_url)
+        if config.scan_comments:
+            comment_model_version_with_scan_results(mv, scan_report, config.hl_console_url)
+        if config.findings_sink:
+            export_detections(mv, scan_report, config.hl_console_url, config.findings_sink)
 except Exception as e:
+    if config.breaker_threshold and is_hl_error(e):
+        # The next monitor run alerts once for all the errors, if they reach the threshold, and retries the version
+        record_hl_error(config.breaker_dir, config.scanner, mv, str(e), config.breaker_threshold)
+        defer_quietly_and_exit(mv, f"HiddenLayer API error, the scan will be retried: {e}", config.outage_policy)
+    if is_hl_outage(e):
+        defer_and_exit_with_message(mv, f"HiddenLayer API is unreachable, the scan will be retried: {e}",
+                                    config.outage_policy)
     message = f"Unexpected error scanning model: {e}"
     if hasattr(e, 'status') and e.status == 400:
         # Bad HTTP request
         if e.body == '{"detail":"sensor with name/ version already exists"}':   # string matching here is brittle
             message = "A given model version can only be scanned once by HiddenLayer."
     fail_and_exit_with_message(mv, message)
+finally:
+    if token_id:
+        revoke_databricks_token(token_id)
 
 
 # COMMAND ----------
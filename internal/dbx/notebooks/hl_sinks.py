@@ -0,0 +1,158 @@
+# This file has the sinks that HiddenLayer notebooks export scan findings to, and the OCSF mapping of findings.
+# A sink is configured by a URI:
+# * s3://..., abfss://..., gs://..., /Volumes/... - cloud storage or a Unity Catalog Volume; one JSON file per finding
+# * eventhub://<namespace>.servicebus.windows.net/<event hub> - an Azure Event Hub; needs a SAS policy name and key
+# * https://... - a webhook; each finding is POSTed as JSON, signed with a signing secret
+
+import base64
+import hashlib
+import hmac
+import json
+import time
+import urllib.parse
+import urllib.request
+import uuid
+from datetime import datetime, timezone
+from typing import Dict
+
+from databricks.sdk.runtime import dbutils
+
+from hl_common import get_schema_secret
+
+# Name of the secret, in the schema's HL secrets scope, that holds "<SAS policy name>:<SAS key>" for Event Hub sinks,
+# or the signing secret for webhook sinks. This convention must match between the Go and Python code.
+FINDINGS_SINK_KEY_NAME = "hl_findings_sink_key"
+
+# Headers of the findings posted to webhook sinks, and the version of the signature scheme. These must match webhook.go.
+WEBHOOK_SIGNATURE_HEADER = "X-HL-Signature"
+WEBHOOK_TIMESTAMP_HEADER = "X-HL-Timestamp"
+WEBHOOK_EVENT_ID_HEADER = "X-HL-Event-Id"
+WEBHOOK_SIGNATURE_VERSION = "v1"
+
+# OCSF Detection Finding class. See https://schema.ocsf.io/1.1.0/classes/detection_finding
+OCSF_VERSION = "1.1.0"
+OCSF_DETECTION_FINDING_CLASS_UID = 2004
+OCSF_FINDINGS_CATEGORY_UID = 2
+OCSF_ACTIVITY_CREATE = 1
+OCSF_SEVERITY_IDS = {"none": 1, "low": 2, "medium": 3, "high": 4, "critical": 5}   # anything else is 0, unknown
+
+
+class FindingSink:
+    """Base class for destinations of exported findings."""
+    def write(self, finding: Dict) -> None:
+        raise NotImplementedError
+
+
+class StorageSink(FindingSink):
+    """Writes each finding as a JSON file under a cloud storage or Volume path, partitioned by date."""
+    def __init__(self, uri: str):
+        self.uri = uri.rstrip("/")
+
+    def write(self, finding: Dict) -> None:
+        date = datetime.now(timezone.utc).strftime("%Y/%m/%d")
+        path = f"{self.uri}/{date}/{uuid.uuid4()}.json"
+        dbutils.fs.put(path, json.dumps(finding), overwrite=False)
+
+
+class EventHubSink(FindingSink):
+    """Sends each finding as an event to an Azure Event Hub, using its REST API and a SAS token."""
+    def __init__(self, uri: str, sas_policy_name: str, sas_key: str):
+        parsed = urllib.parse.urlparse(uri)
+        self.resource = f"https://{parsed.netloc}{parsed.path}"
+        self.sas_policy_name = sas_policy_name
+        self.sas_key = sas_key
+
+    def _sas_token(self) -> str:
+        expiry = str(int(time.time()) + 3600)
+        encoded_resource = urllib.parse.quote_plus(self.resource)
+        signature = base64.b64encode(hmac.new(self.sas_key.encode(), f"{encoded_resource}\n{expiry}".encode(), hashlib.sha256).digest())
+        return (f"SharedAccessSignature sr={encoded_resource}&sig={urllib.parse.quote_plus(signature)}"
+                f"&se={expiry}&skn={self.sas_policy_name}")
+
+    def write(self, finding: Dict) -> None:
+        request = urllib.request.Request(
+            f"{self.resource}/messages",
+            data=json.dumps(finding).encode(),
+            headers={"Authorization": self._sas_token(), "Content-Type": "application/json"},
+            method="POST")
+        with urllib.request.urlopen(request, timeout=30):
+            pass
+
+
+def sign_webhook_payload(secret: str, timestamp: int, body: bytes) -> str:
+    """Return the signature of a webhook payload: the hex HMAC-SHA256 of "<timestamp>.<body>" with the signing secret,
+    prefixed by the scheme version. This must match SignWebhookPayload() in webhook.go."""
+    digest = hmac.new(secret.encode(), f"{timestamp}.".encode() + body, hashlib.sha256).hexdigest()
+    return f"{WEBHOOK_SIGNATURE_VERSION}={digest}"
+
+
+class WebhookSink(FindingSink):
+    """POSTs each finding as JSON to a webhook, signed so that the receiver can check that it came from HL scans."""
+    def __init__(self, uri: str, signing_secret: str):
+        self.uri = uri
+        self.signing_secret = signing_secret
+
+    def write(self, finding: Dict) -> None:
+        body = json.dumps(finding).encode()
+        timestamp = int(time.time())
+        request = urllib.request.Request(
+            self.uri,
+            data=body,
+            headers={
+                "Content-Type": "application/json",
+                WEBHOOK_TIMESTAMP_HEADER: str(timestamp),
+                WEBHOOK_SIGNATURE_HEADER: sign_webhook_payload(self.signing_secret, timestamp, body),
+                WEBHOOK_EVENT_ID_HEADER: str(uuid.uuid4()),
+            },
+            method="POST")
+        with urllib.request.urlopen(request, timeout=30):
+            pass
+
+
+def get_findings_sink(uri: str, catalog: str, schema: str) -> FindingSink:
+    """Return the sink for the URI. Event Hub credentials and webhook signing secrets are read from the schema's
+    HL secrets."""
+    if uri.startswith("eventhub://"):
+        secret, _ = get_schema_secret(catalog, schema, FINDINGS_SINK_KEY_NAME)
+        sas_policy_name, sas_key = secret.split(":", 1)
+        return EventHubSink(uri, sas_policy_name, sas_key)
+    if uri.startswith("https://"):
+        secret, _ = get_schema_secret(catalog, schema, FINDINGS_SINK_KEY_NAME)
+        return WebhookSink(uri, secret)
+    return StorageSink(uri)
+
+
+def to_ocsf_detection_finding(full_model_name: str, model_version_num: int, scan_id: str, threat_level: str,
+                              scanned_at: str, scan_url: str) -> Dict:
+    """Map the outcome of an HL scan with detections to an OCSF Detection Finding."""
+    severity_id = OCSF_SEVERITY_IDS.get((threat_level or "").lower(), 0)
+    return {
+        "class_uid": OCSF_DETECTION_FINDING_CLASS_UID,
+        "class_name": "Detection Finding",
+        "category_uid": OCSF_FINDINGS_CATEGORY_UID,
+        "category_name": "Findings",
+        "activity_id": OCSF_ACTIVITY_CREATE,
+        "activity_name": "Create",
+        "type_uid": OCSF_DETECTION_FINDING_CLASS_UID * 100 + OCSF_ACTIVITY_CREATE,
+        "time": int(time.time() * 1000),
+        "severity_id": severity_id,
+        "severity": threat_level,
+        "status_id": 1,     # New
+        "metadata": {
+            "version": OCSF_VERSION,
+            "product": {"name": "HiddenLayer Model Scanner", "vendor_name": "HiddenLayer"},
+        },
+        "finding_info": {
+            "uid": scan_id,
+            "title": f"HiddenLayer detected {threat_level} threats in model {full_model_name} version {model_version_num}",
+            "src_url": scan_url,
+            "types": ["AI Model Security"],
+            "first_seen_time_dt": scanned_at,
+        },
+        "resources": [{
+            "uid": f"{full_model_name}/{model_version_num}",
+            "name": full_model_name,
+            "type": "Unity Catalog Model Version",
+            "version": str(model_version_num),
+        }],
+    }
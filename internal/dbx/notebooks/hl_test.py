@@ -50,8 +50,29 @@ def test_get_bad_model_version() -> None:
     except ModelVersionNotFound as e:
         pass
 
+def test_is_external_model_version() -> None:
+    workspace_id = 1234567890
+    assert not is_external_model_version(workspace_id, workspace_id), "A version with a run in this workspace isn't external."
+    assert not is_external_model_version(str(workspace_id), workspace_id), "The run's workspace ID may be a string."
+    assert is_external_model_version(None, workspace_id), "A version without a run in a workspace is external."
+    assert is_external_model_version(0, workspace_id), "A version without a run in a workspace is external."
+    assert is_external_model_version(987654321, workspace_id), "A version with a run in another workspace is external."
+
+def test_model_version_uri() -> None:
+    mv = ModelVersion("main.models.external_model", "3", 0, 0, source="s3://bucket/mlruns/artifacts/model")
+    assert model_version_uri(mv) == "models:/main.models.external_model/3", "External versions are downloaded from Unity Catalog."
+
+def test_get_circuit_breaker_threshold() -> None:
+    assert get_circuit_breaker_threshold({}) == DEFAULT_CIRCUIT_BREAKER_THRESHOLD, "The threshold defaults to 5."
+    assert get_circuit_breaker_threshold({"circuit_breaker_threshold": "3"}) == 3, "The threshold is an integer."
+    assert get_circuit_breaker_threshold({"circuit_breaker_threshold": "0"}) == 0, "0 turns the breaker off."
+    assert get_circuit_breaker_threshold({"circuit_breaker_threshold": "-1"}) == 0, "A negative threshold turns it off."
+
 register_test("test_get_model_version", test_get_model_version)
 register_test("test_get_bad_model_version", test_get_bad_model_version)
+register_test("test_is_external_model_version", test_is_external_model_version)
+register_test("test_model_version_uri", test_model_version_uri)
+register_test("test_get_circuit_breaker_threshold", test_get_circuit_breaker_threshold)
 
 # Tests
 
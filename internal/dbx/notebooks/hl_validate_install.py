@@ -0,0 +1,133 @@
+# Databricks notebook source
+# This HiddenLayer (HL) notebook validates an installation from the compute that the jobs run on, before hldbx autoscan
+# creates the model monitoring job. It checks that the HL secret scopes can be read, that the HL endpoints can be
+# reached and authenticated to, and that the monitored schemas can be listed, which catches networking and permission
+# problems on the cluster that hldbx can't see from where it runs.
+# It never fails: it returns the outcome of each check as its notebook output, which hldbx reads.
+# Python version: 3.11+
+
+# Job parameters: the same as the model monitoring job's (see hl_monitor_models.py), of which it uses:
+# * schemas (string) - JSON list of the schemas to monitor, each with its "catalog", "schema", and optional "scanner"
+# * scanners (string) - optional JSON list of the scanners that schemas select instead of the one of hl_api_url
+# * hl_api_key_name, hl_api_url, hl_auth_url (string) - the default scanner, and the name of the secret holding its credentials
+# * hl_https_proxy, hl_no_proxy, hl_ca_bundle_path (string) - optional egress settings for reaching the HL API
+
+# COMMAND ----------
+
+from hl_common import *
+
+# COMMAND ----------
+
+import requests
+from databricks.sdk import WorkspaceClient
+
+# Name of the scanner of the hl_api_url job parameters. This must match hl_monitor_models.py and the Go code.
+DEFAULT_SCANNER_NAME = "default"
+
+# Timeout of each request to an HL endpoint, in seconds
+HL_REQUEST_TIMEOUT_SECS = 15
+
+# Lifetime of the Databricks token minted to check scanners with databricks_token auth. It is revoked right away.
+CHECK_TOKEN_LIFETIME_SECS = 300
+
+checks = []
+
+def check(name: str, ok: bool, message: str, unreachable: bool = False) -> None:
+    """Record the outcome of a check. Set unreachable if it failed to connect to an endpoint."""
+    checks.append({"name": name, "ok": ok, "message": message, "unreachable": unreachable})
+    print(f"{'OK' if ok else 'FAIL'}: {name}: {message}")
+
+def check_secret(catalog: str, schema: str, hl_api_key_name: str) -> Optional[Tuple[str, str]]:
+    """Check that the schema's HL credentials can be read. Return them, or None if they can't."""
+    scope = secrets_scope(catalog, schema)
+    try:
+        secret, scope = get_schema_secret(catalog, schema, hl_api_key_name)
+    except Exception as e:
+        check(f"secret {scope}", False, f"unable to read secret {hl_api_key_name}: {e}")
+        return None
+    if ":" not in secret:
+        check(f"secret {scope}", False, f"secret {hl_api_key_name} isn't a client_id:client_secret string")
+        return None
+    check(f"secret {scope}", True, f"secret {hl_api_key_name} is readable")
+    client_id, client_secret = secret.split(":", 1)
+    return client_id, client_secret
+
+def check_auth(scanner: str, hl_auth_url: str, credentials: Tuple[str, str]) -> None:
+    """Check that the scanner's auth endpoint is reachable, and accepts the credentials."""
+    try:
+        response = requests.post(f"{hl_auth_url.rstrip('/')}/oauth2/token?grant_type=client_credentials",
+                                 auth=credentials, timeout=HL_REQUEST_TIMEOUT_SECS)
+    except requests.RequestException as e:
+        check(f"auth {scanner}", False, f"unable to reach {hl_auth_url}: {e}", unreachable=True)
+        return
+    if response.status_code != 200:
+        check(f"auth {scanner}", False, f"{hl_auth_url} rejected the credentials with HTTP {response.status_code}")
+        return
+    check(f"auth {scanner}", True, f"authenticated to {hl_auth_url}")
+
+def check_token(client: WorkspaceClient, scanner: str) -> None:
+    """Check that the job's identity can mint the short-lived Databricks tokens that scan jobs authenticate with."""
+    try:
+        created = client.tokens.create(lifetime_seconds=CHECK_TOKEN_LIFETIME_SECS, comment="HiddenLayer install check")
+    except Exception as e:
+        check(f"token {scanner}", False,
+              f"unable to mint a Databricks token, grant the run-as service principal CAN_USE on tokens: {e}")
+        return
+    try:
+        client.tokens.delete(created.token_info.token_id)
+    except Exception as e:
+        print(f"Warning: unable to revoke the check's token, it expires on its own: {e}")
+    check(f"token {scanner}", True, "a short-lived Databricks token can be minted")
+
+def check_api(scanner: str, hl_api_url: str) -> None:
+    """Check that the scanner's API endpoint is reachable. Any HTTP response will do."""
+    try:
+        response = requests.get(hl_api_url, timeout=HL_REQUEST_TIMEOUT_SECS)
+    except requests.RequestException as e:
+        check(f"api {scanner}", False, f"unable to reach {hl_api_url}: {e}", unreachable=True)
+        return
+    check(f"api {scanner}", True, f"{hl_api_url} answered with HTTP {response.status_code}")
+
+def check_schema(client: WorkspaceClient, catalog: str, schema: str) -> None:
+    """Check that the models of the schema can be listed."""
+    try:
+        models = list(client.registered_models.list(catalog_name=catalog, schema_name=schema, max_results=1))
+    except Exception as e:
+        check(f"schema {catalog}.{schema}", False, f"unable to list models: {e}")
+        return
+    check(f"schema {catalog}.{schema}", True, "models can be listed" if models else "no models yet, but the schema can be listed")
+
+# COMMAND ----------
+
+# *** MAIN CELL THAT DRIVES EVERYTHING ***
+
+widgets_to_values = dbutils.widgets.getAll()
+configure_egress(get_egress_params(widgets_to_values))
+hl_api_key_name = widgets_to_values.get("hl_api_key_name")
+hl_api_url = widgets_to_values.get("hl_api_url")
+scanners = {DEFAULT_SCANNER_NAME: {"name": DEFAULT_SCANNER_NAME, "api_url": hl_api_url,
+                                   "auth_url": widgets_to_values.get("hl_auth_url"), "auth": scanner_auth(hl_api_url, None)}}
+for item in json.loads(widgets_to_values.get("scanners") or "[]"):
+    scanners[item["name"]] = {**item, "auth": scanner_auth(item["api_url"], item.get("auth"))}
+
+client = WorkspaceClient()
+# Each scanner's endpoints only need checking once, though each schema has its own secret
+checked_auth = set()
+checked_api = set()
+for item in json.loads(widgets_to_values["schemas"]):
+    catalog, schema = item["catalog"], item["schema"]
+    check_schema(client, catalog, schema)
+    scanner = scanners[item.get("scanner") or DEFAULT_SCANNER_NAME]
+    if scanner["auth"] == SCANNER_AUTH_CLIENT_CREDENTIALS:
+        credentials = check_secret(catalog, schema, hl_api_key_name)
+        if credentials and scanner["name"] not in checked_auth:
+            check_auth(scanner["name"], scanner["auth_url"], credentials)
+            checked_auth.add(scanner["name"])
+    elif scanner["auth"] == SCANNER_AUTH_DATABRICKS_TOKEN and scanner["name"] not in checked_auth:
+        check_token(client, scanner["name"])
+        checked_auth.add(scanner["name"])
+    if scanner["name"] not in checked_api:
+        check_api(scanner["name"], scanner["api_url"])
+        checked_api.add(scanner["name"])
+
+dbutils.notebook.exit(json.dumps({"checks": checks}))
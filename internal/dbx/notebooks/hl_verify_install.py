@@ -0,0 +1,133 @@
+# Databricks notebook source
+# This HiddenLayer (HL) notebook verifies an installation on a schedule, from within the workspace, so that problems are
+# noticed without an operator running hldbx. It runs the checks of hl_validate_install (the HL secret scopes can be
+# read, the HL endpoints can be reached and authenticated to, the monitored schemas can be listed), then checks that
+# the monitoring job is recording heartbeats, and that the share of models whose latest version was scanned isn't
+# falling. It fails if any check fails, so that the job's failure notifications reach the configured channels.
+# Python version: 3.11+
+
+# Job parameters: the same as the model monitoring job's (see hl_monitor_models.py), which are passed along to
+# hl_validate_install, and:
+# * state_table (string) - full name of the Delta table that the monitoring job records its heartbeats in. The scan
+#   coverage of each verification is recorded in <state_table>_coverage, to compare the next one with.
+# * heartbeat_max_age_hours (string) - optional, how old the latest heartbeat may be, in hours; not checked if empty
+
+# COMMAND ----------
+
+from hl_common import *
+
+# COMMAND ----------
+
+import json
+from datetime import datetime, timedelta, timezone
+
+# Name of the notebook with the installation checks, in the same directory
+VALIDATE_NOTEBOOK_NAME = "hl_validate_install"
+
+# How long the installation checks may take, including starting the cluster
+VALIDATE_NOTEBOOK_TIMEOUT_SECS = 1800
+
+# Fail when the scan coverage of a schema falls by more than this many percentage points since the previous
+# verification. New models lower it until they are scanned, which the monitoring job does on its next runs.
+COVERAGE_MAX_DROP_POINTS = 10
+
+checks = []
+
+def check(name: str, ok: bool, message: str) -> None:
+    """Record the outcome of a check."""
+    checks.append({"name": name, "ok": ok, "message": message})
+    print(f"{'OK' if ok else 'FAIL'}: {name}: {message}")
+
+def run_installation_checks(widgets_to_values: Dict[str, str]) -> None:
+    """Run the checks of the validation notebook, with this job's parameters."""
+    try:
+        output = dbutils.notebook.run(VALIDATE_NOTEBOOK_NAME, VALIDATE_NOTEBOOK_TIMEOUT_SECS, widgets_to_values)
+    except Exception as e:
+        check("installation", False, f"unable to run {VALIDATE_NOTEBOOK_NAME}: {e}")
+        return
+    for item in json.loads(output)["checks"]:
+        check(item["name"], item["ok"], item["message"])
+
+def check_heartbeat(state_table: str, max_age_hours: str) -> None:
+    """Check that the monitoring job recorded a heartbeat recently, and that its monitoring task succeeded then."""
+    try:
+        row = spark.sql(f"""SELECT run_id, heartbeat_at, monitor_status FROM {state_table}
+            ORDER BY heartbeat_at DESC LIMIT 1""").first()
+    except Exception as e:
+        check("heartbeat", False, f"unable to read {state_table}: {e}")
+        return
+    if row is None:
+        check("heartbeat", False, f"the monitoring job has never recorded a heartbeat in {state_table}")
+        return
+    age = datetime.now(timezone.utc) - datetime.fromisoformat(row["heartbeat_at"])
+    if max_age_hours and age > timedelta(hours=float(max_age_hours)):
+        check("heartbeat", False, f"the latest heartbeat is {age} old, more than {max_age_hours} hours; "
+                                  "check that the monitoring job is running and its schedule isn't paused")
+    elif row["monitor_status"] != "ok":
+        check("heartbeat", False, f"the monitoring task failed in run {row['run_id']}")
+    else:
+        check("heartbeat", True, f"the monitoring job recorded a heartbeat {age} ago")
+
+def schema_coverage(catalog: str, schema: str) -> Tuple[int, int]:
+    """Return the number of models in the schema, and how many of them have a scanned latest version."""
+    models, scanned = 0, 0
+    client = mlflow_client()
+    for model in workspace_client.registered_models.list(catalog_name=catalog, schema_name=schema):
+        latest = max((int(version.version) for version in
+                      client.search_model_versions(filter_string=f"name='{model.full_name}'")), default=None)
+        if latest is None:
+            continue
+        models += 1
+        tags = client.get_model_version(model.full_name, str(latest)).tags or {}
+        if tags.get(HL_SCAN_STATUS) == STATUS_DONE:
+            scanned += 1
+    return models, scanned
+
+def check_coverage(schemas: List[Dict[str, str]], coverage_table: str) -> None:
+    """Check that the scan coverage of each schema hasn't fallen since the previous verification, and record it."""
+    spark.sql(f"""CREATE TABLE IF NOT EXISTS {coverage_table} (
+        verified_at STRING, catalog STRING, schema STRING, models BIGINT, scanned BIGINT)""")
+    verified_at = datetime.now(timezone.utc).isoformat()
+    rows = []
+    for item in schemas:
+        catalog, schema = item["catalog"], item["schema"]
+        name = f"coverage {catalog}.{schema}"
+        try:
+            models, scanned = schema_coverage(catalog, schema)
+        except Exception as e:
+            check(name, False, f"unable to count the scanned models: {e}")
+            continue
+        rows.append({"verified_at": verified_at, "catalog": catalog, "schema": schema, "models": models, "scanned": scanned})
+        percent = 100 * scanned / models if models else 100
+        previous = spark.sql(f"""SELECT models, scanned FROM {coverage_table}
+            WHERE catalog = :catalog AND schema = :schema ORDER BY verified_at DESC LIMIT 1""",
+            args={"catalog": catalog, "schema": schema}).first()
+        previous_percent = 100 * previous["scanned"] / previous["models"] if previous and previous["models"] else None
+        if previous_percent is not None and previous_percent - percent > COVERAGE_MAX_DROP_POINTS:
+            check(name, False, f"{scanned} of {models} model(s) scanned, {percent:.0f}%, down from "
+                               f"{previous_percent:.0f}% at the previous verification")
+        else:
+            check(name, True, f"{scanned} of {models} model(s) scanned, {percent:.0f}%")
+    if rows:
+        spark.createDataFrame(rows, schema=spark.table(coverage_table).schema).write.mode("append").saveAsTable(coverage_table)
+
+# COMMAND ----------
+
+# *** MAIN CELL THAT DRIVES EVERYTHING ***
+
+from databricks.sdk import WorkspaceClient
+
+widgets_to_values = dbutils.widgets.getAll()
+state_table = widgets_to_values.get("state_table")
+assert state_table, "state_table is a required job parameter"
+workspace_client = WorkspaceClient()
+
+run_installation_checks(widgets_to_values)
+check_heartbeat(state_table, widgets_to_values.get("heartbeat_max_age_hours", ""))
+check_coverage(json.loads(widgets_to_values["schemas"]), f"{state_table}_coverage")
+
+failed = [c for c in checks if not c["ok"]]
+if failed:
+    raise RuntimeError(f"{len(failed)} of {len(checks)} verification check(s) failed: " +
+                       "; ".join(f"{c['name']}: {c['message']}" for c in failed))
+print(f"All {len(checks)} verification checks passed")
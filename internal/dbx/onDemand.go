@@ -0,0 +1,89 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Task key of the on-demand scan job
+const onDemandTaskKey = "on_demand"
+
+// ValidateOnDemandJob checks the settings of the on-demand scan job. Its group is granted the right to run it.
+func ValidateOnDemandJob(config *utils.Config) error {
+	if config.DbxOnDemandGroup != "" && strings.TrimSpace(config.DbxOnDemandGroup) != config.DbxOnDemandGroup {
+		return fmt.Errorf("invalid dbx_on_demand_group %q, it can't start or end with spaces", config.DbxOnDemandGroup)
+	}
+	if config.DbxOnDemandGroup != "" && !config.DbxOnDemandJob {
+		return fmt.Errorf("dbx_on_demand_group only applies with dbx_on_demand_job")
+	}
+	return nil
+}
+
+// onDemandJobSettings returns the settings of the on-demand scan job, which workspace users run for a model version
+// they registered, to have it scanned now rather than on the next monitoring run. It runs the monitor notebook for that
+// version, on the monitoring job's compute and as its identity. The monitoring job's parameters are base parameters of
+// its task, rather than job parameters, so that those who run it can only choose the model version: a run can't point
+// the scan at another HL API, with the credentials of the schema.
+func onDemandJobSettings(config *utils.Config) jobs.CreateJob {
+	monitorJob := monitorJobSettings(config)
+	baseParams := map[string]string{"monitor_run_id": "{{job.run_id}}"}
+	for _, param := range monitorJob.Parameters {
+		baseParams[param.Name] = param.Default
+	}
+	return jobs.CreateJob{Name: jobName(config, onDemandJobKey),
+		Description: jobDescription(config),
+		Tags:        jobTags(onDemandJobKey),
+		Tasks: []jobs.Task{{
+			Description:       "Scan a model version now, and report the HiddenLayer scan result",
+			ExistingClusterId: taskClusterId(config),
+			JobClusterKey:     taskJobClusterKey(config),
+			TaskKey:           onDemandTaskKey,
+			NotebookTask: &jobs.NotebookTask{
+				NotebookPath:   fmt.Sprintf("%s/%s", getHLWorkspaceDirectory(config), modelMonitorNotebookName),
+				BaseParameters: baseParams,
+			},
+		}},
+		Parameters: []jobs.JobParameterDefinition{
+			{Name: "full_model_name", Default: ""},
+			{Name: "model_version_num", Default: ""},
+		},
+		// Requests beyond the limit of scan jobs wait in the queue, rather than being skipped. The runs also wait for
+		// the monitoring job's scan jobs, see wait_for_scan_slot() in hl_monitor_models.py, so both share the limit.
+		MaxConcurrentRuns: config.MaxActiveScanJobs(),
+		Queue:             &jobs.QueueSettings{Enabled: true},
+		JobClusters:       jobClusters(config),
+		BudgetPolicyId:    taskBudgetPolicyId(config),
+		RunAs:             monitorJob.RunAs,
+	}
+}
+
+// setUpOnDemandJob creates the on-demand scan job, or updates it if it exists, and lets dbx_on_demand_group run it.
+// Return the job ID, or an error if the job can't be created or shared.
+func setUpOnDemandJob(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) (int64, error) {
+	jobId, _, err := createOrResetJob(ctx, client, onDemandJobSettings(config))
+	if err != nil {
+		return 0, fmt.Errorf("error creating on-demand scan job: %w", err)
+	}
+	if config.DbxOnDemandGroup != "" {
+		_, err := client.Jobs.UpdatePermissions(ctx, jobs.JobPermissionsRequest{
+			JobId: strconv.FormatInt(jobId, 10),
+			AccessControlList: []jobs.JobAccessControlRequest{{
+				GroupName:       config.DbxOnDemandGroup,
+				PermissionLevel: jobs.JobPermissionLevelCanManageRun,
+			}},
+		})
+		if err != nil {
+			return jobId, fmt.Errorf("unable to let group %s run the on-demand scan job %d: %w", config.DbxOnDemandGroup, jobId, err)
+		}
+	}
+	fmt.Printf("On-demand scan job ID: %d\n", jobId)
+	fmt.Println("Workspace users request a scan of a model version with, e.g.")
+	fmt.Printf("  databricks jobs run-now %d --json '{\"job_parameters\": {\"full_model_name\": \"<catalog>.<schema>.<model>\", \"model_version_num\": \"<version>\"}}'\n", jobId)
+	return jobId, nil
+}
@@ -0,0 +1,212 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/iam"
+	"github.com/databricks/databricks-sdk-go/service/sql"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Schemas owned by the principals, or in catalogs that they own. The principals are bound as :owner_0, :owner_1, and
+// so on.
+const ownedSchemasQuery = `SELECT catalog_name, schema_name
+FROM system.information_schema.schemata
+WHERE (schema_owner IN (%[1]s)
+    OR catalog_name IN (SELECT catalog_name FROM system.information_schema.catalogs WHERE catalog_owner IN (%[1]s)))
+  AND schema_name <> 'information_schema'
+  AND catalog_name <> 'system'
+ORDER BY catalog_name, schema_name`
+
+// OwnerSync is the change to the monitored schemas that keeps them in sync with dbx_schemas and the schemas that
+// dbx_owner_groups own.
+type OwnerSync struct {
+	Owned   []utils.CatalogSchemaConfig `json:"owned"`   // schemas that the groups own
+	Added   []utils.CatalogSchemaConfig `json:"added"`   // not monitored yet
+	Removed []utils.CatalogSchemaConfig `json:"removed"` // neither in dbx_schemas nor owned by the groups any more
+}
+
+// ValidateOwnerGroups checks the groups whose schemas are monitored.
+func ValidateOwnerGroups(config *utils.Config) error {
+	for i, group := range config.DbxOwnerGroups {
+		if strings.TrimSpace(group) == "" {
+			return fmt.Errorf("dbx_owner_groups entry %d is empty", i+1)
+		}
+		if slices.Index(config.DbxOwnerGroups, group) != i {
+			return fmt.Errorf("dbx_owner_groups lists group %q more than once", group)
+		}
+	}
+	return nil
+}
+
+// OwnedSchemas queries information_schema, through a SQL warehouse, for the schemas that dbx_owner_groups or their
+// members own, directly or through the catalog that they're in. Models are monitored by schema, so the schemas that a
+// group owns cover the models in them.
+func OwnedSchemas(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config,
+	warehouseId string) ([]utils.CatalogSchemaConfig, error) {
+	owners, err := groupOwners(ctx, client, config.DbxOwnerGroups)
+	if err != nil {
+		return nil, err
+	}
+	markers := make([]string, len(owners))
+	params := make([]sql.StatementParameterListItem, len(owners))
+	for i, owner := range owners {
+		markers[i] = fmt.Sprintf(":owner_%d", i)
+		params[i] = sql.StatementParameterListItem{Name: fmt.Sprintf("owner_%d", i), Value: owner}
+	}
+	response, err := client.StatementExecution.ExecuteAndWait(ctx, sql.ExecuteStatementRequest{
+		WarehouseId: warehouseId,
+		Statement:   fmt.Sprintf(ownedSchemasQuery, strings.Join(markers, ", ")),
+		Parameters:  params,
+		Disposition: sql.DispositionInline,
+		Format:      sql.FormatJsonArray,
+		WaitTimeout: "30s",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to query information_schema for the schemas of %s: %w",
+			strings.Join(config.DbxOwnerGroups, ", "), err)
+	}
+	var schemas []utils.CatalogSchemaConfig
+	result := response.Result
+	for result != nil {
+		for _, row := range result.DataArray {
+			if len(row) < 2 {
+				continue
+			}
+			schemas = append(schemas, utils.CatalogSchemaConfig{Catalog: row[0], Schema: row[1]})
+		}
+		if result.NextChunkIndex == 0 {
+			break
+		}
+		result, err = client.StatementExecution.GetStatementResultChunkNByStatementIdAndChunkIndex(ctx, response.StatementId, result.NextChunkIndex)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get results of the information_schema query: %w", err)
+		}
+	}
+	return schemas, nil
+}
+
+// groupOwners returns the names that Unity Catalog records as the owner of what the groups or their members own: the
+// names of the groups and of the groups nested in them, the user names of their users, and the application IDs of
+// their service principals.
+func groupOwners(ctx context.Context, client *databricks.WorkspaceClient, groups []string) ([]string, error) {
+	var owners []string
+	seen := map[string]bool{}
+	var addGroup func(group *iam.Group) error
+	addGroup = func(group *iam.Group) error {
+		if seen[group.Id] {
+			return nil
+		}
+		seen[group.Id] = true
+		owners = append(owners, group.DisplayName)
+		for _, member := range group.Members {
+			switch {
+			case strings.HasPrefix(member.Ref, "Groups/"):
+				nested, err := client.Groups.GetById(ctx, member.Value)
+				if err != nil {
+					return fmt.Errorf("unable to get group %s, a member of %s: %w", member.Display, group.DisplayName, err)
+				}
+				if err := addGroup(nested); err != nil {
+					return err
+				}
+			case strings.HasPrefix(member.Ref, "Users/"):
+				user, err := client.Users.GetById(ctx, member.Value)
+				if err != nil {
+					return fmt.Errorf("unable to get user %s, a member of %s: %w", member.Display, group.DisplayName, err)
+				}
+				owners = append(owners, user.UserName)
+			case strings.HasPrefix(member.Ref, "ServicePrincipals/"):
+				servicePrincipal, err := client.ServicePrincipals.GetById(ctx, member.Value)
+				if err != nil {
+					return fmt.Errorf("unable to get service principal %s, a member of %s: %w", member.Display,
+						group.DisplayName, err)
+				}
+				owners = append(owners, servicePrincipal.ApplicationId)
+			}
+		}
+		return nil
+	}
+	for _, name := range groups {
+		found, err := client.Groups.ListAll(ctx, iam.ListGroupsRequest{
+			Filter:     scimEqFilter("displayName", name),
+			Attributes: "id,displayName,members",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to look up group %s: %w", name, err)
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("group %s not found", name)
+		}
+		if err := addGroup(&found[0]); err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(owners)
+	return slices.Compact(owners), nil
+}
+
+// SyncOwnedSchemas makes the schemas that the installed monitoring job monitors those of dbx_schemas and those that
+// dbx_owner_groups own, so that schemas are monitored as soon as a group owns them, and no longer once it doesn't.
+// Schemas are added before any are removed, so the job always monitors at least one. If dryRun is set, the job
+// is left as is.
+func SyncOwnedSchemas(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, warehouseId string,
+	dryRun bool) (OwnerSync, error) {
+	var ownerSync OwnerSync
+	owned, err := OwnedSchemas(ctx, client, config, warehouseId)
+	if err != nil {
+		return ownerSync, err
+	}
+	ownerSync.Owned = owned
+	monitored, err := MonitoredSchemas(ctx, client)
+	if err != nil {
+		return ownerSync, err
+	}
+
+	// dbx_schemas entries come first, so that their scanner is kept for schemas that are also owned
+	var desired []utils.CatalogSchemaConfig
+	for _, schema := range slices.Concat(config.DbxSchemas, owned) {
+		if indexSchema(desired, schema) < 0 {
+			desired = append(desired, schema)
+		}
+	}
+	if len(desired) == 0 {
+		return ownerSync, fmt.Errorf("no schemas to monitor: dbx_schemas is empty and %s own no schemas",
+			strings.Join(config.DbxOwnerGroups, ", "))
+	}
+	for _, schema := range desired {
+		if indexSchema(monitored, schema) < 0 {
+			ownerSync.Added = append(ownerSync.Added, schema)
+		}
+	}
+	for _, schema := range monitored {
+		if indexSchema(desired, schema) < 0 {
+			ownerSync.Removed = append(ownerSync.Removed, schema)
+		}
+	}
+	if dryRun {
+		return ownerSync, nil
+	}
+
+	for _, schema := range ownerSync.Added {
+		if err := AddMonitoredSchema(ctx, client, config, schema); err != nil {
+			return ownerSync, fmt.Errorf("unable to add schema %s.%s: %w", schema.Catalog, schema.Schema, err)
+		}
+	}
+	for _, schema := range ownerSync.Removed {
+		if err := RemoveMonitoredSchema(ctx, client, schema); err != nil {
+			return ownerSync, fmt.Errorf("unable to remove schema %s.%s: %w", schema.Catalog, schema.Schema, err)
+		}
+	}
+	return ownerSync, nil
+}
+
+// indexSchema returns the index of a schema in a list by its catalog and schema names, ignoring its scanner, or -1.
+func indexSchema(schemas []utils.CatalogSchemaConfig, schema utils.CatalogSchemaConfig) int {
+	return slices.IndexFunc(schemas, func(s utils.CatalogSchemaConfig) bool {
+		return s.Catalog == schema.Catalog && s.Schema == schema.Schema
+	})
+}
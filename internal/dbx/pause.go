@@ -0,0 +1,78 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Commands that pause and resume the monitoring job's schedule, as the install audit log records them
+const (
+	pauseCommand  = "hldbx pause"
+	resumeCommand = "hldbx resume"
+)
+
+// PauseChange is the pause status of the monitoring job's schedule after SetMonitorJobPaused.
+type PauseChange struct {
+	JobId    int64  `json:"job_id"`
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"` // Quartz cron, in UTC
+	Paused   bool   `json:"paused"`
+	Changed  bool   `json:"changed"` // false if the schedule was already paused, or resumed
+}
+
+// SetMonitorJobPaused pauses or resumes the schedule of the monitoring job, without changing anything else, so that
+// no new model versions are scanned while it's paused. Runs in progress aren't canceled. The install audit log
+// records the change, if the pause status changed.
+func SetMonitorJobPaused(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, paused bool) (*PauseChange, error) {
+	job, err := monitorJob(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if job.Settings == nil || job.Settings.Schedule == nil {
+		return nil, fmt.Errorf("job %d has no schedule, it only runs when started; re-run hldbx autoscan to schedule it",
+			job.JobId)
+	}
+	change := &PauseChange{JobId: job.JobId, Name: job.Settings.Name, Schedule: job.Settings.Schedule.QuartzCronExpression,
+		Paused: paused}
+	status, command := jobs.PauseStatusUnpaused, resumeCommand
+	if paused {
+		status, command = jobs.PauseStatusPaused, pauseCommand
+	}
+	if job.Settings.Schedule.PauseStatus == status {
+		return change, nil
+	}
+	// The schedule is replaced as a whole, so send it with only its pause status changed
+	schedule := *job.Settings.Schedule
+	schedule.PauseStatus = status
+	if err := client.Jobs.Update(ctx, jobs.UpdateJob{JobId: job.JobId, NewSettings: &jobs.JobSettings{Schedule: &schedule}}); err != nil {
+		return nil, fmt.Errorf("unable to update the schedule of job %d: %w", job.JobId, err)
+	}
+	change.Changed = true
+	if err := AppendAuditRecord(ctx, client, config, command); err != nil {
+		return nil, fmt.Errorf("unable to record the change in the install audit log: %w", err)
+	}
+	return change, nil
+}
+
+// lastPause returns the install audit log's record of the pause that the monitoring job's schedule is paused by,
+// i.e. its latest pause or resume if that's a pause, or nil if it was paused otherwise, e.g. in the Databricks UI.
+func lastPause(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) (*InstallRecord, error) {
+	records, err := readAuditRecords(ctx, client, config)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		switch {
+		case strings.HasPrefix(records[i].Command, pauseCommand):
+			return &records[i], nil
+		case strings.HasPrefix(records[i].Command, resumeCommand):
+			return nil, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,84 @@
+package dbx
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Phases of an install that progress events report on
+const (
+	phaseInstall   = "install" // the whole install, reported once it finishes
+	phaseAuth      = "auth"
+	phaseSecrets   = "secrets"
+	phaseUpload    = "upload"
+	phaseValidate  = "validate"
+	phaseJob       = "job"
+	phaseGuardrail = "guardrail"
+	phaseVerify    = "verify"
+	phaseOnDemand  = "on_demand"
+)
+
+// Statuses of a phase in progress events
+const (
+	phaseStarted  = "started"
+	phaseFinished = "finished"
+	phaseSkipped  = "skipped" // for lack of permission, see reportSkippedSteps
+)
+
+// ProgressEvent reports that a phase of an install started or ended, with how long it took and the IDs of the
+// Databricks resources it created or updated, such as secret scopes and job IDs.
+type ProgressEvent struct {
+	Time            time.Time `json:"time"`
+	Phase           string    `json:"phase"`
+	Status          string    `json:"status"`
+	DurationSeconds float64   `json:"duration_seconds,omitempty"`
+	ResourceIds     []string  `json:"resource_ids,omitempty"`
+	Message         string    `json:"message,omitempty"`
+}
+
+// ProgressFunc receives the progress events of an install. It may be nil, to ignore them.
+type ProgressFunc func(event ProgressEvent)
+
+// phaseProgress reports the progress of one phase.
+type phaseProgress struct {
+	progress ProgressFunc
+	phase    string
+	start    time.Time
+}
+
+// startPhase reports that a phase started, and returns what reports its end.
+func startPhase(progress ProgressFunc, phase string) *phaseProgress {
+	p := &phaseProgress{progress: progress, phase: phase, start: time.Now()}
+	p.report(phaseStarted, nil, "")
+	return p
+}
+
+// finish reports that the phase finished, with the IDs of the resources it created or updated.
+func (p *phaseProgress) finish(resourceIds ...string) {
+	p.report(phaseFinished, resourceIds, "")
+}
+
+// skip reports that the phase was skipped, with the reason.
+func (p *phaseProgress) skip(reason string) {
+	p.report(phaseSkipped, nil, reason)
+}
+
+// report sends an event for the phase, if anything receives them, and logs it at info level.
+func (p *phaseProgress) report(status string, resourceIds []string, message string) {
+	event := ProgressEvent{Time: time.Now(), Phase: p.phase, Status: status, ResourceIds: resourceIds, Message: message}
+	attrs := []any{"phase", p.phase, "status", status}
+	if status != phaseStarted {
+		event.DurationSeconds = time.Since(p.start).Seconds()
+		attrs = append(attrs, "duration", time.Since(p.start).Round(time.Millisecond))
+	}
+	if len(resourceIds) > 0 {
+		attrs = append(attrs, "resource_ids", resourceIds)
+	}
+	if message != "" {
+		attrs = append(attrs, "message", message)
+	}
+	slog.Info("Install phase "+status, attrs...)
+	if p.progress != nil {
+		p.progress(event)
+	}
+}
@@ -0,0 +1,162 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/compute"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// How often to check the cluster state while waiting for it to start
+const clusterPollInterval = 10 * time.Second
+
+// WaitForCluster waits until the cluster is running, so that an immediate job run doesn't fail to attach to it.
+// A terminated cluster is started if autostart is true, otherwise an error explains how to start it.
+// onProgress is called with the cluster state each time it is checked.
+// Returns an error with the next steps if the cluster can't run, or isn't running before the timeout.
+func WaitForCluster(ctx context.Context, client *databricks.WorkspaceClient, clusterId string, autostart bool,
+	timeout time.Duration, onProgress func(state compute.State, elapsed time.Duration)) error {
+	start := time.Now()
+	started := false
+	eventsUrl := fmt.Sprintf("%s/#setting/clusters/%s/events", strings.TrimSuffix(client.Config.Host, "/"), clusterId)
+	for {
+		cluster, err := client.Clusters.Get(ctx, compute.GetClusterRequest{ClusterId: clusterId})
+		if err != nil {
+			return fmt.Errorf("unable to get the state of cluster %s: %w", clusterId, err)
+		}
+		elapsed := time.Since(start)
+		onProgress(cluster.State, elapsed)
+
+		switch cluster.State {
+		case compute.StateRunning, compute.StateResizing:
+			return nil
+		case compute.StateTerminated:
+			if !autostart {
+				return fmt.Errorf("cluster %s is terminated. Start it in the Databricks UI or with "+
+					"'databricks clusters start %s', or re-run with --start-cluster", clusterId, clusterId)
+			}
+			if !started {
+				if err := startCluster(ctx, client, clusterId); err != nil {
+					return err
+				}
+				started = true
+			}
+		case compute.StateError, compute.StateUnknown:
+			return fmt.Errorf("cluster %s is in state %s: %s. Check its event log at %s",
+				clusterId, cluster.State, cluster.StateMessage, eventsUrl)
+		}
+		// PENDING, RESTARTING, and TERMINATING clusters are left to settle
+
+		if elapsed >= timeout {
+			return fmt.Errorf("cluster %s is still %s after %s, it may be waiting for cloud capacity. "+
+				"Check its event log at %s, or re-run with a longer --cluster-timeout", clusterId, cluster.State,
+				elapsed.Round(time.Second), eventsUrl)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(clusterPollInterval):
+		}
+	}
+}
+
+// startCluster starts a terminated cluster, without waiting for it to be running.
+func startCluster(ctx context.Context, client *databricks.WorkspaceClient, clusterId string) error {
+	_, err := client.Clusters.Start(ctx, compute.StartCluster{ClusterId: clusterId})
+	if err != nil {
+		if errors.Is(err, databricks.ErrPermissionDenied) {
+			return fmt.Errorf("no permission to start cluster %s, ask its owner to start it or grant you CAN RESTART: %w",
+				clusterId, err)
+		}
+		return fmt.Errorf("unable to start cluster %s: %w", clusterId, err)
+	}
+	return nil
+}
+
+// MonitorRun is an immediate run of the monitoring job.
+type MonitorRun struct {
+	JobId int64  `json:"job_id"`
+	RunId int64  `json:"run_id"`
+	Url   string `json:"url"`
+}
+
+// RunMonitorJobNow triggers an immediate run of the model monitoring job.
+// Call WaitForCluster first, so the run doesn't fail to attach to a cluster that isn't running.
+func RunMonitorJobNow(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) (*MonitorRun, error) {
+	monitorJobs, err := findMonitorJobs(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if len(monitorJobs) == 0 {
+		return nil, fmt.Errorf("no model monitoring job, run hldbx autoscan to create it")
+	}
+	jobId := monitorJobs[0].JobId
+	run, err := client.Jobs.RunNow(ctx, jobs.RunNow{JobId: jobId})
+	if err != nil {
+		return nil, fmt.Errorf("unable to run job %d: %w", jobId, err)
+	}
+	return &MonitorRun{
+		JobId: jobId,
+		RunId: run.RunId,
+		Url:   fmt.Sprintf("%s/#job/%d/run/%d", strings.TrimSuffix(config.DbxHost, "/"), jobId, run.RunId),
+	}, nil
+}
+
+// How often to check the state of a run while waiting for it to end
+const runPollInterval = 10 * time.Second
+
+// WaitForRun waits until a run ends, and summarizes it, classifying why it failed as GetRunHistory does.
+// onProgress is called with the state of the run each time it changes, e.g. from PENDING to RUNNING.
+// Returns an error if the run hasn't ended before the timeout, which leaves it running.
+func WaitForRun(ctx context.Context, client *databricks.WorkspaceClient, run *MonitorRun, timeout time.Duration,
+	onProgress func(state string, elapsed time.Duration)) (*RunSummary, error) {
+	start := time.Now()
+	lastState := ""
+	for {
+		details, err := client.Jobs.GetRun(ctx, jobs.GetRunRequest{RunId: run.RunId})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get the state of run %d: %w", run.RunId, err)
+		}
+		elapsed := time.Since(start)
+		state := runState(details.State)
+		if state != lastState {
+			onProgress(state, elapsed)
+			lastState = state
+		}
+
+		if details.State != nil && slices.Contains(endedLifeCycleStates, details.State.LifeCycleState) {
+			baseRun := jobs.BaseRun{RunId: details.RunId, State: details.State, Status: details.Status, Tasks: details.Tasks,
+				StartTime: details.StartTime, EndTime: details.EndTime, RunDuration: details.RunDuration,
+				SetupDuration: details.SetupDuration, ExecutionDuration: details.ExecutionDuration,
+				CleanupDuration: details.CleanupDuration}
+			summary := &RunSummary{
+				RunId:           details.RunId,
+				StartTime:       time.UnixMilli(details.StartTime).UTC(),
+				DurationSeconds: runDuration(baseRun) / 1000,
+				State:           state,
+				Url:             run.Url,
+			}
+			if details.State.ResultState != jobs.RunResultStateSuccess {
+				summary.Failure, summary.Message = classifyFailure(ctx, client, baseRun)
+			}
+			return summary, nil
+		}
+
+		if elapsed >= timeout {
+			return nil, fmt.Errorf("run %d is still %s after %s, it keeps running. Follow it at %s", run.RunId, state,
+				elapsed.Round(time.Second), run.Url)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(runPollInterval):
+		}
+	}
+}
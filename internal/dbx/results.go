@@ -0,0 +1,294 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/client"
+	"github.com/databricks/databricks-sdk-go/service/catalog"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/hl"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+)
+
+// Model version tag names, and scan status values, written by the Python notebooks.
+// These must match hl_common.py.
+const (
+	hlScanStatusTag      = "hl_scan_status"
+	hlScanThreatLevelTag = "hl_scan_threat_level"
+	hlScanUpdatedAtTag   = "hl_scan_updated_at"
+	hlScanUrlTag         = "hl_scan_url"
+	hlScanMessageTag     = "hl_scan_message"
+	hlScanVersionTag     = "hl_scan_scanner_version"
+	hlScanIdTag          = "hl_scan_id"
+	hlScanDigestTag      = "hl_scan_artifact_digest"
+	hlScanQuarantineTag  = "hl_scan_quarantine"
+	hlScanRulesTag       = "hl_scan_rules"
+	hlScanRunIdTag       = "hl_scan_run_id"
+
+	// Triage tags, set by hldbx detections and kept across rescans
+	hlScanAckByTag          = "hl_scan_ack_by"
+	hlScanAckReasonTag      = "hl_scan_ack_reason"
+	hlScanAckAtTag          = "hl_scan_ack_at"
+	hlScanSuppressTagPrefix = "hl_scan_suppress_" // followed by the rule ID
+
+	scanStatusDone        = "done"
+	scanStatusPending     = "pending"
+	scanStatusScanPending = "scan_pending"
+	scanStatusUnscanned   = "unscanned"
+	scanStatusFailed      = "failed"
+)
+
+// Threat levels that let a model version pass the serving guardrail. This must match hl_common.py.
+var safeThreatLevels = []string{"none", "low"}
+
+// Prefix of the names of the jobs that the monitor notebook creates to scan model versions.
+// This must match hl_monitor_models.py.
+const scanJobNamePrefix = "hl_scan_"
+
+// ValidateScanTrigger checks the scan trigger policy, and its aliases.
+func ValidateScanTrigger(config *utils.Config) error {
+	if err := config.DbxScanTrigger.Validate(); err != nil {
+		return err
+	}
+	if config.DbxScanTrigger != hlconfig.ScanTriggerAlias {
+		if len(config.DbxScanAliases) > 0 {
+			return fmt.Errorf("dbx_scan_aliases only applies with dbx_scan_trigger: %s", hlconfig.ScanTriggerAlias)
+		}
+		return nil
+	}
+	for _, alias := range config.DbxScanAliases {
+		if alias == "" || strings.ContainsAny(alias, ", @") {
+			return fmt.Errorf("invalid alias %q in dbx_scan_aliases, give alias names without @", alias)
+		}
+	}
+	return nil
+}
+
+// ScanResult is the HiddenLayer scan outcome recorded in the tags of a model version.
+type ScanResult struct {
+	Model       string        `json:"model"` // <catalog>.<schema>.<model_name>
+	Version     int           `json:"version"`
+	Status      string        `json:"status,omitempty"`
+	ThreatLevel string        `json:"threat_level,omitempty"`
+	UpdatedAt   string        `json:"updated_at,omitempty"`
+	ScanUrl     string        `json:"scan_url,omitempty"`
+	Message     string        `json:"message,omitempty"`
+	Scanner     string        `json:"scanner_version,omitempty"`
+	ScanId      string        `json:"scan_id,omitempty"`
+	Digest      string        `json:"artifact_digest,omitempty"` // digest of the scanned artifacts, see artifact_digest() in hl_common.py
+	Quarantined bool          `json:"quarantined,omitempty"`     // held back from serving until scanned, by the fail_closed outage policy
+	Rules       []string      `json:"rules,omitempty"`           // IDs of the rules that the detections matched
+	AckBy       string        `json:"acknowledged_by,omitempty"` // who acknowledged the detections, if anyone did
+	AckReason   string        `json:"acknowledged_reason,omitempty"`
+	Suppressed  []Suppression `json:"suppressed,omitempty"` // unexpired rule suppressions
+}
+
+// IsScanned returns true if the scan finished, whatever it found.
+func (r ScanResult) IsScanned() bool {
+	return r.Status == scanStatusDone
+}
+
+// IsDetection returns true if the scan finished and found threats above the safe threat levels.
+func (r ScanResult) IsDetection() bool {
+	return r.Status == scanStatusDone && !slices.Contains(safeThreatLevels, strings.ToLower(r.ThreatLevel))
+}
+
+// IsTriaged returns true if the detections were acknowledged, or every rule they matched is suppressed,
+// so they shouldn't be alerted on again.
+func (r ScanResult) IsTriaged() bool {
+	if r.AckBy != "" {
+		return true
+	}
+	if len(r.Rules) == 0 {
+		return false
+	}
+	for _, rule := range r.Rules {
+		if !slices.ContainsFunc(r.Suppressed, func(s Suppression) bool { return s.Rule == rule }) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsOutageBacklog returns true if the model version is waiting to be scanned because the HiddenLayer API was
+// unreachable when its scan job ran. The monitoring job retries it on every run.
+func (r ScanResult) IsOutageBacklog() bool {
+	return r.Status == scanStatusScanPending
+}
+
+// outageBacklog returns how many model versions are waiting to be scanned until the HiddenLayer API is reachable,
+// and how many of those are quarantined.
+func outageBacklog(results []ScanResult) (int, int) {
+	backlog, quarantined := 0, 0
+	for _, result := range results {
+		if result.IsOutageBacklog() {
+			backlog++
+			if result.Quarantined {
+				quarantined++
+			}
+		}
+	}
+	return backlog, quarantined
+}
+
+// CacheVerdicts records the verdicts of the finished scans in the verdict cache, keyed by the digest of the
+// scanned artifacts.
+func CacheVerdicts(results []ScanResult) error {
+	cache, err := hl.LoadVerdictCache()
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		if result.Status != scanStatusDone {
+			continue
+		}
+		cache.Put(result.Digest, hl.Verdict{
+			ScanId:         result.ScanId,
+			Status:         result.Status,
+			ThreatLevel:    result.ThreatLevel,
+			UpdatedAt:      result.UpdatedAt,
+			ScannerVersion: result.Scanner,
+			ScanUrl:        result.ScanUrl,
+		})
+	}
+	return cache.Save()
+}
+
+// modelVersionTags is the part of the MLflow Unity Catalog model version response that holds the tags.
+type modelVersionTags struct {
+	ModelVersion struct {
+		Tags []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"tags"`
+	} `json:"model_version"`
+}
+
+// getModelVersionTags returns the tags of a Unity Catalog model version.
+// The Go SDK doesn't expose model version tags, so call the MLflow Unity Catalog REST API that the Python
+// notebooks use, through the SDK's client to get its authentication, retries, and user agent.
+func getModelVersionTags(ctx context.Context, dbxClient *databricks.WorkspaceClient, fullName string, version int) (map[string]string, error) {
+	apiClient, err := client.New(dbxClient.Config)
+	if err != nil {
+		return nil, err
+	}
+	var response modelVersionTags
+	err = apiClient.Do(ctx, http.MethodGet, "/api/2.0/mlflow/unity-catalog/model-versions/get", nil,
+		map[string]any{"name": fullName, "version": strconv.Itoa(version)}, nil, &response)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get tags of model %s version %d: %w", fullName, version, err)
+	}
+	tags := map[string]string{}
+	for _, tag := range response.ModelVersion.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
+
+// ListScanResults returns the scan results of the model versions in the schemas that the scan trigger policy
+// makes candidates for scanning: the latest version of each model, or the versions with a scan-triggering alias.
+func ListScanResults(ctx context.Context, dbxClient *databricks.WorkspaceClient, config *utils.Config) ([]ScanResult, error) {
+	var results []ScanResult
+	err := walkCandidateVersions(ctx, dbxClient, config, func(model catalog.RegisteredModelInfo, version int, tags map[string]string) error {
+		results = append(results, scanResultFromTags(model.FullName, version, tags))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// walkCandidateVersions calls visit with each model version in the monitored schemas that the scan trigger policy
+// makes a candidate for scanning, and its tags. Stops at the first error.
+func walkCandidateVersions(ctx context.Context, dbxClient *databricks.WorkspaceClient, config *utils.Config,
+	visit func(model catalog.RegisteredModelInfo, version int, tags map[string]string) error) error {
+	for _, schema := range config.DbxSchemas {
+		models, err := dbxClient.RegisteredModels.ListAll(ctx, catalog.ListRegisteredModelsRequest{
+			CatalogName: schema.Catalog,
+			SchemaName:  schema.Schema,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to list models in %s.%s: %w", schema.Catalog, schema.Schema, err)
+		}
+		for _, model := range models {
+			versions, err := candidateVersions(ctx, dbxClient, config, model.FullName)
+			if err != nil {
+				return err
+			}
+			for _, version := range versions {
+				tags, err := getModelVersionTags(ctx, dbxClient, model.FullName, version)
+				if err != nil {
+					return err
+				}
+				if err := visit(model, version, tags); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// scanResultFromTags returns the scan result recorded in the tags of a model version.
+func scanResultFromTags(fullName string, version int, tags map[string]string) ScanResult {
+	result := ScanResult{
+		Model:       fullName,
+		Version:     version,
+		Status:      tags[hlScanStatusTag],
+		ThreatLevel: tags[hlScanThreatLevelTag],
+		UpdatedAt:   tags[hlScanUpdatedAtTag],
+		ScanUrl:     tags[hlScanUrlTag],
+		Message:     tags[hlScanMessageTag],
+		Scanner:     tags[hlScanVersionTag],
+		ScanId:      tags[hlScanIdTag],
+		Digest:      tags[hlScanDigestTag],
+		Quarantined: tags[hlScanQuarantineTag] == "true",
+		AckBy:       tags[hlScanAckByTag],
+		AckReason:   tags[hlScanAckReasonTag],
+		Suppressed:  activeSuppressions(tags, time.Now()),
+	}
+	if rules := tags[hlScanRulesTag]; rules != "" {
+		result.Rules = strings.Split(rules, ",")
+	}
+	return result
+}
+
+// candidateVersions returns the versions of a model that the scan trigger policy makes candidates for scanning.
+// This must match get_candidate_versions() in hl_monitor_models.py.
+func candidateVersions(ctx context.Context, dbxClient *databricks.WorkspaceClient, config *utils.Config, fullName string) ([]int, error) {
+	if config.DbxScanTrigger == hlconfig.ScanTriggerAlias {
+		model, err := dbxClient.RegisteredModels.Get(ctx, catalog.GetRegisteredModelRequest{FullName: fullName, IncludeAliases: true})
+		if err != nil {
+			return nil, fmt.Errorf("unable to get aliases of model %s: %w", fullName, err)
+		}
+		var versions []int
+		for _, alias := range model.Aliases {
+			if len(config.DbxScanAliases) == 0 || slices.Contains(config.DbxScanAliases, alias.AliasName) {
+				versions = append(versions, alias.VersionNum)
+			}
+		}
+		slices.Sort(versions)
+		return slices.Compact(versions), nil
+	}
+
+	versions, err := dbxClient.ModelVersions.ListAll(ctx, catalog.ListModelVersionsRequest{FullName: fullName})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list versions of model %s: %w", fullName, err)
+	}
+	latest := 0
+	for _, version := range versions {
+		latest = max(latest, version.Version)
+	}
+	if latest == 0 {
+		return nil, nil
+	}
+	return []int{latest}, nil
+}
@@ -0,0 +1,176 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+)
+
+// Classes of failed monitoring job runs
+const (
+	FailureClusterStart = "cluster_start" // the cluster or its libraries didn't start
+	FailureHLAuth       = "hl_auth"       // HiddenLayer rejected the credentials, or its auth endpoint was unreachable
+	FailurePermissions  = "permissions"   // the job's identity lacks a Databricks permission
+	FailureScanErrors   = "scan_errors"   // scanning a model version failed
+	FailureOther        = "other"
+)
+
+// Termination codes of runs whose cluster didn't start
+var clusterStartTerminationCodes = []jobs.TerminationCodeCode{
+	jobs.TerminationCodeCodeClusterError,
+	jobs.TerminationCodeCodeDriverError,
+	jobs.TerminationCodeCodeCloudFailure,
+	jobs.TerminationCodeCodeInvalidClusterRequest,
+	jobs.TerminationCodeCodeClusterRequestLimitExceeded,
+	jobs.TerminationCodeCodeLibraryInstallationError,
+	jobs.TerminationCodeCodeMaxSparkContextsExceeded,
+}
+
+// Lower-case text in the error of a failed run that tells its class, checked in order. HL auth errors come first,
+// because they also mention "unauthorized". The HL errors must match hl_api.py.
+var failurePatterns = []struct {
+	class    string
+	patterns []string
+}{
+	{FailureHLAuth, []string{"badhlcredentials", "oauth2/token", "invalid_client", "hl_scan."}},
+	{FailurePermissions, []string{"permission_denied", "permission denied", "does not have", "not authorized", "unauthorized", "forbidden"}},
+	{FailureClusterStart, []string{"cluster", "library installation"}},
+	{FailureScanErrors, []string{"scan", "hiddenlayer", "model version"}},
+}
+
+// RunSummary is a run of the monitoring job, with the class of its failure, if it failed.
+type RunSummary struct {
+	RunId           int64     `json:"run_id"`
+	StartTime       time.Time `json:"start_time"`
+	DurationSeconds int64     `json:"duration_seconds"`
+	State           string    `json:"state"`
+	Failure         string    `json:"failure,omitempty"`
+	Message         string    `json:"message,omitempty"`
+	Url             string    `json:"url,omitempty"`
+}
+
+// Failed returns true if the run ended without succeeding.
+func (r RunSummary) Failed() bool {
+	return r.Failure != ""
+}
+
+// RunTrend counts the runs started on a day, in UTC, and their failures by class.
+type RunTrend struct {
+	Date     string         `json:"date"`
+	Runs     int            `json:"runs"`
+	Failed   int            `json:"failed"`
+	Failures map[string]int `json:"failures,omitempty"`
+}
+
+// RunHistory summarizes the recent runs of the monitoring job, latest first.
+type RunHistory struct {
+	JobId    int64          `json:"job_id"`
+	Runs     []RunSummary   `json:"runs"`
+	Failures map[string]int `json:"failures"` // by class
+	Trend    []RunTrend     `json:"trend"`    // by day, latest first
+}
+
+// GetRunHistory summarizes up to limit of the latest completed runs of the monitoring job, classifying why the failed
+// runs failed from their termination codes and the errors of their failed tasks.
+func GetRunHistory(ctx context.Context, client *databricks.WorkspaceClient, limit int) (*RunHistory, error) {
+	job, err := monitorJob(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	history := &RunHistory{JobId: job.JobId, Runs: []RunSummary{}, Failures: map[string]int{}, Trend: []RunTrend{}}
+	// The Jobs API lists at most 25 runs a page
+	runs := client.Jobs.ListRuns(ctx, jobs.ListRunsRequest{JobId: job.JobId, CompletedOnly: true, ExpandTasks: true, Limit: min(limit, 25)})
+	for len(history.Runs) < limit && runs.HasNext(ctx) {
+		run, err := runs.Next(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list runs of job %d: %w", job.JobId, err)
+		}
+		summary := RunSummary{
+			RunId:           run.RunId,
+			StartTime:       time.UnixMilli(run.StartTime).UTC(),
+			DurationSeconds: runDuration(run) / 1000,
+			State:           runState(run.State),
+			Url:             run.RunPageUrl,
+		}
+		if run.State != nil && run.State.ResultState != "" && run.State.ResultState != jobs.RunResultStateSuccess {
+			summary.Failure, summary.Message = classifyFailure(ctx, client, run)
+			history.Failures[summary.Failure]++
+		}
+		history.Runs = append(history.Runs, summary)
+		history.addToTrend(summary)
+	}
+	return history, nil
+}
+
+// runDuration returns how long a run took, in milliseconds. Multitask runs only have a run duration.
+func runDuration(run jobs.BaseRun) int64 {
+	if run.RunDuration > 0 {
+		return run.RunDuration
+	}
+	if run.EndTime > run.StartTime {
+		return run.EndTime - run.StartTime
+	}
+	return run.SetupDuration + run.ExecutionDuration + run.CleanupDuration
+}
+
+// addToTrend counts a run in the trend of the day it started. Runs are added latest first.
+func (h *RunHistory) addToTrend(run RunSummary) {
+	date := run.StartTime.Format(time.DateOnly)
+	if len(h.Trend) == 0 || h.Trend[len(h.Trend)-1].Date != date {
+		h.Trend = append(h.Trend, RunTrend{Date: date, Failures: map[string]int{}})
+	}
+	trend := &h.Trend[len(h.Trend)-1]
+	trend.Runs++
+	if run.Failed() {
+		trend.Failed++
+		trend.Failures[run.Failure]++
+	}
+}
+
+// classifyFailure returns the class of a failed run's failure, and the message that tells it. The termination code
+// tells cluster and permission failures apart; otherwise the error of the first failed task does.
+func classifyFailure(ctx context.Context, client *databricks.WorkspaceClient, run jobs.BaseRun) (string, string) {
+	message := ""
+	if run.State != nil {
+		message = run.State.StateMessage
+	}
+	if run.Status != nil && run.Status.TerminationDetails != nil {
+		details := run.Status.TerminationDetails
+		if details.Message != "" {
+			message = details.Message
+		}
+		if slices.Contains(clusterStartTerminationCodes, details.Code) {
+			return FailureClusterStart, message
+		}
+		if details.Code == jobs.TerminationCodeCodeUnauthorizedError {
+			return FailurePermissions, message
+		}
+	}
+	for _, task := range run.Tasks {
+		if task.State == nil || task.State.ResultState == "" || task.State.ResultState == jobs.RunResultStateSuccess {
+			continue
+		}
+		if task.State.StateMessage != "" {
+			message = task.State.StateMessage
+		}
+		output, err := client.Jobs.GetRunOutput(ctx, jobs.GetRunOutputRequest{RunId: task.RunId})
+		if err == nil && output.Error != "" {
+			message = output.Error
+		}
+		break
+	}
+	lower := strings.ToLower(message)
+	for _, class := range failurePatterns {
+		for _, pattern := range class.patterns {
+			if strings.Contains(lower, pattern) {
+				return class.class, message
+			}
+		}
+	}
+	return FailureOther, message
+}
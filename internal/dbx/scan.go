@@ -0,0 +1,60 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// ScanModelVersion scans a model version on demand, with a one-time run of the scan notebook, which submits it to the
+// HiddenLayer API of the scanner that the model's schema selects. The notebooks are uploaded and the scanner's
+// credentials stored for the model's schema first, so no monitoring job is needed. Returns the scan result that the
+// run recorded in the model version's tags, and the run ID, 0 if the run couldn't be submitted.
+// Call WaitForCluster first, unless jobs run on serverless compute.
+func ScanModelVersion(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, fullName string,
+	version int) (ScanResult, int64, error) {
+	if _, err := client.ModelVersions.GetByFullNameAndVersion(ctx, fullName, version); err != nil {
+		return ScanResult{}, 0, fmt.Errorf("unable to get model %s version %d: %w", fullName, version, err)
+	}
+	result, err := getScanResult(ctx, client, fullName, version)
+	if err != nil {
+		return ScanResult{}, 0, err
+	}
+	if result.Status == scanStatusPending {
+		return result, 0, fmt.Errorf("model %s version %d is being scanned already", fullName, version)
+	}
+
+	// The scan notebook reads the credentials from the scope of the model's schema
+	parts := strings.SplitN(fullName, ".", 3)
+	schema := utils.CatalogSchemaConfig{Catalog: parts[0], Schema: parts[1]}
+	if i := indexSchema(config.DbxSchemas, schema); i >= 0 {
+		schema = config.DbxSchemas[i]
+	}
+	scanConfig := *config
+	scanConfig.DbxSchemas = []utils.CatalogSchemaConfig{schema}
+	if _, err := storeHLCreds(ctx, client, &scanConfig); err != nil {
+		return ScanResult{}, 0, err
+	}
+	if err := uploadPythonFiles(client, config); err != nil {
+		return ScanResult{}, 0, err
+	}
+
+	wait, err := client.Jobs.Submit(ctx, backfillScanRun(config, backfillVersion{Model: fullName, Version: version}))
+	if err != nil {
+		return ScanResult{}, 0, fmt.Errorf("unable to submit the scan run: %w", err)
+	}
+	run, err := wait.GetWithTimeout(backfillScanTimeout)
+	if err != nil {
+		return ScanResult{}, wait.RunId, fmt.Errorf("scan run %d: %w", wait.RunId, err)
+	}
+	if run.State != nil && run.State.ResultState != jobs.RunResultStateSuccess {
+		return ScanResult{}, wait.RunId, fmt.Errorf("scan run %d is %s: %s", wait.RunId, runState(run.State),
+			run.State.StateMessage)
+	}
+	result, err = getScanResult(ctx, client, fullName, version)
+	return result, wait.RunId, err
+}
@@ -0,0 +1,69 @@
+package dbx
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Limits on the scan metadata, to keep it readable in the HiddenLayer console
+const (
+	maxScanMetadataEntries = 20
+	maxScanMetadataValue   = 256
+)
+
+// Scan metadata keys are lowercase identifiers, e.g. workspace, environment, team
+var scanMetadataKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// Scan metadata keys that the notebooks set: the ID of the job run that requested the scan, which must match
+// hl_api.py, and the digest of the scanned artifacts, which must match hl_common.py.
+const (
+	requestingJobRunIdKey = "requesting_job_run_id"
+	artifactDigestKey     = "artifact_digest"
+)
+
+// ValidateScanMetadata checks the origin and metadata that are sent with each HiddenLayer scan.
+func ValidateScanMetadata(config *utils.Config) error {
+	if strings.ContainsAny(config.HlScanOrigin, "\r\n") || len(config.HlScanOrigin) > maxScanMetadataValue {
+		return fmt.Errorf("hl_scan_origin must be a single line of at most %d characters", maxScanMetadataValue)
+	}
+	if len(config.HlScanMetadata) > maxScanMetadataEntries {
+		return fmt.Errorf("hl_scan_metadata has %d entries, the most allowed is %d", len(config.HlScanMetadata), maxScanMetadataEntries)
+	}
+	for key, value := range config.HlScanMetadata {
+		if !scanMetadataKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid hl_scan_metadata key %q, expected a lowercase identifier such as team", key)
+		}
+		if key == requestingJobRunIdKey || key == artifactDigestKey {
+			return fmt.Errorf("hl_scan_metadata key %s is set by the scan jobs", key)
+		}
+		if strings.ContainsAny(value, "\r\n") || len(value) > maxScanMetadataValue {
+			return fmt.Errorf("hl_scan_metadata value of %s must be a single line of at most %d characters", key, maxScanMetadataValue)
+		}
+	}
+	return nil
+}
+
+// scanMetadataParam returns the scan metadata job parameter: the configured metadata, with the workspace
+// defaulting to the Databricks host name so that results can always be told apart by workspace.
+func scanMetadataParam(config *utils.Config) string {
+	metadata := maps.Clone(config.HlScanMetadata)
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	if _, ok := metadata["workspace"]; !ok {
+		if host, err := url.Parse(config.DbxHost); err == nil && host.Hostname() != "" {
+			metadata["workspace"] = host.Hostname()
+		}
+	}
+	param, err := json.Marshal(metadata)
+	if err != nil {
+		utils.Fatalf("Error marshalling scan metadata: %v", err)
+	}
+	return string(param)
+}
@@ -0,0 +1,142 @@
+package dbx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/hl"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Scanner names are passed to the notebooks in job parameters, so keep them simple
+var scannerNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// scannerParam is a scanner as the monitoring job receives it, without its credentials, which the scan jobs read
+// from the schema's secrets scope. The JSON names must match hl_monitor_models.py.
+type scannerParam struct {
+	Name       string `json:"name"`
+	ApiUrl     string `json:"api_url"`
+	AuthUrl    string `json:"auth_url,omitempty"`
+	ConsoleUrl string `json:"console_url,omitempty"`
+	Auth       string `json:"auth"`
+}
+
+// ValidateScanners checks the alternative scanners, and the scanners that the monitored schemas select.
+func ValidateScanners(config *utils.Config) error {
+	names := map[string]bool{}
+	for i, scanner := range config.HlScanners {
+		if scanner.Name == "" {
+			return fmt.Errorf("scanner %d has no name", i+1)
+		}
+		if !scannerNamePattern.MatchString(scanner.Name) {
+			return fmt.Errorf("invalid scanner name %q, expected letters, digits, underscores, and dashes", scanner.Name)
+		}
+		if scanner.Name == utils.DefaultScannerName {
+			return fmt.Errorf("scanner name %q is reserved for the scanner of hl_api_url", scanner.Name)
+		}
+		if names[scanner.Name] {
+			return fmt.Errorf("scanner name %q is used more than once", scanner.Name)
+		}
+		names[scanner.Name] = true
+		for setting, value := range map[string]string{"api_url": scanner.ApiUrl, "auth_url": scanner.AuthUrl, "console_url": scanner.ConsoleUrl} {
+			if value == "" && setting != "api_url" {
+				continue
+			}
+			if parsed, err := url.Parse(value); err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+				return fmt.Errorf("scanner %q has invalid %s %q, expected an https:// URL", scanner.Name, setting, value)
+			}
+		}
+		if err := scanner.Auth.Validate(); err != nil {
+			return fmt.Errorf("scanner %q has %w", scanner.Name, err)
+		}
+		if scanner.Auth == utils.ScannerAuthDatabricksToken {
+			if !scanner.IsEnterprise() {
+				return fmt.Errorf("scanner %q can't use %s auth, HiddenLayer SaaS doesn't accept Databricks tokens", scanner.Name, scanner.Auth)
+			}
+			if config.DbxRunAs == "" {
+				return fmt.Errorf("scanner %q uses %s auth, which mints tokens of the run-as service principal, so it needs dbx_run_as",
+					scanner.Name, scanner.Auth)
+			}
+		}
+	}
+	for _, schema := range config.DbxSchemas {
+		scanner, ok := config.Scanner(schema)
+		if !ok {
+			return fmt.Errorf("schema %s.%s selects scanner %q, which isn't in hl_scanners", schema.Catalog, schema.Schema, schema.Scanner)
+		}
+		if scanner.Name != utils.DefaultScannerName && scanner.UsesClientCredentials() && scanner.AuthUrl == "" {
+			return fmt.Errorf("scanner %q authenticates with client credentials, so it needs an auth_url", scanner.Name)
+		}
+	}
+	return nil
+}
+
+// scannersParam returns the monitoring job parameter that lists the scanners the monitored schemas select,
+// other than the default scanner, which the hl_api_url parameters describe.
+func scannersParam(config *utils.Config) string {
+	scanners := []scannerParam{}
+	seen := map[string]bool{}
+	for _, schema := range config.DbxSchemas {
+		scanner, ok := config.Scanner(schema)
+		if !ok || scanner.Name == utils.DefaultScannerName || seen[scanner.Name] {
+			continue
+		}
+		seen[scanner.Name] = true
+		scanners = append(scanners, scannerParam{
+			Name:       scanner.Name,
+			ApiUrl:     scanner.ApiUrl,
+			AuthUrl:    scanner.AuthUrl,
+			ConsoleUrl: scanner.ConsoleUrl,
+			Auth:       string(scanner.Auth),
+		})
+	}
+	param, err := json.Marshal(scanners)
+	if err != nil {
+		utils.Fatalf("Error marshalling scanners: %v", err)
+	}
+	return string(param)
+}
+
+// PingScanner checks a scanner end to end from this machine, see hl.PingScanner, after authenticating to it as
+// hldbx calls its API: with its client credentials, a short-lived Databricks token, or not at all.
+func PingScanner(config *utils.Config, scanner utils.ScannerConfig, wait time.Duration) (*hl.PingResult, error) {
+	tlsConfig, err := hl.TLSConfig(config.HlTlsMinVersion, config.HlTlsPins)
+	if err != nil {
+		return nil, err
+	}
+	result := &hl.PingResult{ApiUrl: scanner.ApiUrl}
+	var accessToken string
+	revokeToken := func() {}
+	defer func() { revokeToken() }()
+	if scanner.Auth == utils.ScannerAuthClientCredentials || scanner.Auth == utils.ScannerAuthDatabricksToken {
+		if !result.AddStep("auth", func() (string, error) {
+			switch {
+			case scanner.UsesClientCredentials() && (scanner.ClientID == "" || scanner.ClientSecret == ""):
+				return "", errors.New("the client ID and secret are not set")
+			case scanner.Auth == utils.ScannerAuthDatabricksToken && config.DbxToken == "":
+				return "", errors.New("dbx_token is not set, the scanner accepts Databricks tokens")
+			}
+			var revoke func()
+			if accessToken, revoke, err = scannerAccessToken(context.Background(), config, scanner, tlsConfig); err != nil {
+				return "", err
+			}
+			revokeToken = revoke
+			if scanner.UsesClientCredentials() {
+				return "authenticated at " + scanner.AuthUrl, nil
+			}
+			if config.DbxRunAs != "" {
+				return "minted a short-lived Databricks token of service principal " + config.DbxRunAs, nil
+			}
+			return "minted a short-lived Databricks token of yours", nil
+		}) {
+			return result, nil
+		}
+	}
+	hl.PingScanner(hl.NewHttpClient(tlsConfig), result, accessToken, wait)
+	return result, nil
+}
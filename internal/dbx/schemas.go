@@ -0,0 +1,246 @@
+package dbx
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/databricks/databricks-sdk-go/service/workspace"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Name of the monitoring job parameter that lists the schemas to monitor. This must match hl_monitor_models.py.
+const schemasParamName = "schemas"
+
+// ParseSchemaName parses a "<catalog>.<schema>" name.
+func ParseSchemaName(name string) (utils.CatalogSchemaConfig, error) {
+	catalogName, schemaName, found := strings.Cut(name, ".")
+	if !found || catalogName == "" || schemaName == "" || strings.Contains(schemaName, ".") {
+		return utils.CatalogSchemaConfig{}, fmt.Errorf("invalid schema %q, expected <catalog>.<schema>", name)
+	}
+	return utils.CatalogSchemaConfig{Catalog: catalogName, Schema: schemaName}, nil
+}
+
+// ReadSchemaList reads a list of schemas, one per line, as "<catalog>.<schema>" or as CSV "<catalog>,<schema>".
+// Blank lines, lines starting with #, a "catalog,schema" header, and repeated schemas are skipped.
+func ReadSchemaList(r io.Reader) ([]utils.CatalogSchemaConfig, error) {
+	var schemas []utils.CatalogSchemaConfig
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || strings.EqualFold(strings.ReplaceAll(text, " ", ""), "catalog,schema") {
+			continue
+		}
+		if catalogName, schemaName, found := strings.Cut(text, ","); found {
+			text = strings.TrimSpace(catalogName) + "." + strings.TrimSpace(schemaName)
+		}
+		schema, err := ParseSchemaName(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if !slices.Contains(schemas, schema) {
+			schemas = append(schemas, schema)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read the schema list: %w", err)
+	}
+	return schemas, nil
+}
+
+// monitorJob returns the installed model monitoring job.
+func monitorJob(ctx context.Context, client *databricks.WorkspaceClient) (*jobs.Job, error) {
+	monitorJobs, err := findMonitorJobs(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	if len(monitorJobs) == 0 {
+		return nil, fmt.Errorf("no model monitoring job, run hldbx autoscan to create it")
+	}
+	job, err := client.Jobs.GetByJobId(ctx, monitorJobs[0].JobId)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get job %d: %w", monitorJobs[0].JobId, err)
+	}
+	return job, nil
+}
+
+// MonitoredSchemas returns the schemas that the installed model monitoring job monitors.
+func MonitoredSchemas(ctx context.Context, client *databricks.WorkspaceClient) ([]utils.CatalogSchemaConfig, error) {
+	job, err := monitorJob(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	return jobSchemas(job)
+}
+
+// jobSchemas returns the schemas in the parameters of the model monitoring job.
+func jobSchemas(job *jobs.Job) ([]utils.CatalogSchemaConfig, error) {
+	for _, param := range job.Settings.Parameters {
+		if param.Name == schemasParamName {
+			var schemas []utils.CatalogSchemaConfig
+			if err := json.Unmarshal([]byte(param.Default), &schemas); err != nil {
+				return nil, fmt.Errorf("unable to parse the %s parameter of job %d: %w", schemasParamName, job.JobId, err)
+			}
+			return schemas, nil
+		}
+	}
+	return nil, fmt.Errorf("job %d has no %s parameter", job.JobId, schemasParamName)
+}
+
+// setJobSchemas replaces the schemas in the parameters of the model monitoring job, keeping its other parameters.
+func setJobSchemas(ctx context.Context, client *databricks.WorkspaceClient, job *jobs.Job, schemas []utils.CatalogSchemaConfig) error {
+	schemasParam, err := json.Marshal(schemas)
+	if err != nil {
+		return err
+	}
+	params := slices.Clone(job.Settings.Parameters)
+	for i := range params {
+		if params[i].Name == schemasParamName {
+			params[i].Default = string(schemasParam)
+		}
+	}
+	// Top-level settings are replaced as a whole, so send the full parameter list
+	err = client.Jobs.Update(ctx, jobs.UpdateJob{JobId: job.JobId, NewSettings: &jobs.JobSettings{Parameters: params}})
+	if err != nil {
+		return fmt.Errorf("unable to update the parameters of job %d: %w", job.JobId, err)
+	}
+	return nil
+}
+
+// AddMonitoredSchema adds a schema to the installed model monitoring job, and gives it the credentials of the scanner
+// it selects, so the scan jobs of its models can reach HiddenLayer: those of a monitored schema that selects the same
+// scanner, or else those in the configuration.
+func AddMonitoredSchema(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config,
+	schema utils.CatalogSchemaConfig) error {
+	job, err := monitorJob(ctx, client)
+	if err != nil {
+		return err
+	}
+	schemas, err := jobSchemas(job)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(schemas, schema) {
+		return fmt.Errorf("schema %s.%s is already monitored", schema.Catalog, schema.Schema)
+	}
+	if err := storeAddedSchemaHLCreds(ctx, client, config, schemas, schema); err != nil {
+		return err
+	}
+	return setJobSchemas(ctx, client, job, append(schemas, schema))
+}
+
+// storeAddedSchemaHLCreds gives a schema added to the monitored schemas the credentials of the scanner it selects.
+// Each schema's scope holds the credentials of its own scanner, so they're copied from a monitored schema that selects
+// the same one, or else stored from the configuration. Returns an error if neither has them.
+func storeAddedSchemaHLCreds(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config,
+	monitored []utils.CatalogSchemaConfig, schema utils.CatalogSchemaConfig) error {
+	scanner, ok := config.Scanner(schema)
+	if !ok {
+		return fmt.Errorf("schema %s.%s selects scanner %q, which isn't in hl_scanners", schema.Catalog, schema.Schema,
+			schema.Scanner)
+	}
+	if !scanner.UsesClientCredentials() {
+		return nil
+	}
+	for _, other := range monitored {
+		if otherScanner, ok := config.Scanner(other); ok && otherScanner.Name == scanner.Name {
+			return copySecretsScope(ctx, client, other, schema)
+		}
+	}
+	if scanner.ClientID == "" || scanner.ClientSecret == "" {
+		return fmt.Errorf("no monitored schema selects scanner %s, and the configuration has no client ID and secret "+
+			"for it", scanner.Name)
+	}
+	_, err := storeSchemaHLCreds(ctx, client, config, schema, scanner)
+	return err
+}
+
+// RemoveMonitoredSchema removes a schema from the installed model monitoring job, and deletes its secrets.
+func RemoveMonitoredSchema(ctx context.Context, client *databricks.WorkspaceClient, schema utils.CatalogSchemaConfig) error {
+	job, err := monitorJob(ctx, client)
+	if err != nil {
+		return err
+	}
+	schemas, err := jobSchemas(job)
+	if err != nil {
+		return err
+	}
+	i := slices.Index(schemas, schema)
+	if i < 0 {
+		return fmt.Errorf("schema %s.%s is not monitored", schema.Catalog, schema.Schema)
+	}
+	if len(schemas) == 1 {
+		return fmt.Errorf("schema %s.%s is the only monitored schema, the monitoring job needs at least one",
+			schema.Catalog, schema.Schema)
+	}
+	if err := setJobSchemas(ctx, client, job, slices.Delete(schemas, i, i+1)); err != nil {
+		return err
+	}
+	location, secrets, err := listSchemaSecrets(ctx, client, schema)
+	if err != nil {
+		return err
+	}
+	if !location.consolidated() {
+		err = client.Secrets.DeleteScopeByScope(ctx, location.Scope)
+		if err != nil && !errors.Is(err, databricks.ErrResourceDoesNotExist) && !errors.Is(err, databricks.ErrNotFound) {
+			return fmt.Errorf("unable to delete secret scope %s: %w", location.Scope, err)
+		}
+		return nil
+	}
+	// The consolidated scope holds other schemas' secrets, so only delete this schema's
+	for _, secret := range secrets {
+		err = client.Secrets.DeleteSecret(ctx, workspace.DeleteSecret{Scope: location.Scope, Key: location.key(secret.Key)})
+		if err != nil && !errors.Is(err, databricks.ErrResourceDoesNotExist) && !errors.Is(err, databricks.ErrNotFound) {
+			return fmt.Errorf("unable to delete secret %s from scope %s: %w", location.key(secret.Key), location.Scope, err)
+		}
+	}
+	return nil
+}
+
+// copySecretsScope copies the HL secrets of one schema, and the ACLs of its scope, to another's, creating its scope if
+// needed. Nothing is copied if the source schema has no secrets, as with the Enterprise model scanner.
+// Either schema's secrets may be in the consolidated scope, if the workspace has no secret scopes left.
+func copySecretsScope(ctx context.Context, client *databricks.WorkspaceClient, from utils.CatalogSchemaConfig, to utils.CatalogSchemaConfig) error {
+	fromLocation, secrets, err := listSchemaSecrets(ctx, client, from)
+	if err != nil {
+		return err
+	}
+	if len(secrets) == 0 {
+		return nil
+	}
+	toLocation, err := createSchemaSecretsScope(ctx, client, to)
+	if err != nil {
+		return err
+	}
+	// Keep the grants, e.g. to the dbx_secrets_group, so the new scope's credentials can be rotated the same way.
+	// The consolidated scope holds other schemas' secrets, so its grants aren't copied to or from it.
+	if !fromLocation.consolidated() && !toLocation.consolidated() {
+		if err := copySecretsScopeAcls(ctx, client, fromLocation.Scope, toLocation.Scope); err != nil {
+			return err
+		}
+	}
+	for _, secret := range secrets {
+		fromKey, toKey := fromLocation.key(secret.Key), toLocation.key(secret.Key)
+		value, err := client.Secrets.GetSecret(ctx, workspace.GetSecretRequest{Scope: fromLocation.Scope, Key: fromKey})
+		if err != nil {
+			return fmt.Errorf("error fetching secret %s from scope %s: %w", fromKey, fromLocation.Scope, err)
+		}
+		decodedBytes, err := base64.StdEncoding.DecodeString(value.Value)
+		if err != nil {
+			return fmt.Errorf("failed to decode secret %s from scope %s: %w", fromKey, fromLocation.Scope, err)
+		}
+		err = client.Secrets.PutSecret(ctx, workspace.PutSecret{Scope: toLocation.Scope, Key: toKey, StringValue: string(decodedBytes)})
+		if err != nil {
+			return fmt.Errorf("error creating secret %s in scope %s: %w", toKey, toLocation.Scope, err)
+		}
+	}
+	return nil
+}
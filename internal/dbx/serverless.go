@@ -0,0 +1,135 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/google/uuid"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// ValidateBudgetPolicy checks the budget policy that serverless jobs are attributed to.
+// Whether the policy exists is checked when the jobs are written, see checkBudgetPolicy.
+func ValidateBudgetPolicy(config *utils.Config) error {
+	if config.DbxBudgetPolicyId == "" {
+		return nil
+	}
+	if !config.DbxServerless {
+		return errors.New("dbx_budget_policy_id only applies with dbx_serverless: true, budget policies are for serverless compute")
+	}
+	if _, err := uuid.Parse(config.DbxBudgetPolicyId); err != nil {
+		return fmt.Errorf("invalid dbx_budget_policy_id %q, expected a budget policy ID such as 01234567-89ab-cdef-0123-456789abcdef", config.DbxBudgetPolicyId)
+	}
+	return nil
+}
+
+// taskClusterId returns the existing cluster that job tasks run on, or "" for serverless compute and job clusters.
+func taskClusterId(config *utils.Config) string {
+	if !config.UsesExistingCluster() {
+		return ""
+	}
+	return config.DbxClusterId
+}
+
+// taskBudgetPolicyId returns the budget policy that jobs are attributed to, which only applies to serverless compute.
+func taskBudgetPolicyId(config *utils.Config) string {
+	if !config.DbxServerless {
+		return ""
+	}
+	return config.DbxBudgetPolicyId
+}
+
+// checkBudgetPolicy checks that Databricks applied the budget policy to the job that was just written. It doesn't if
+// the policy doesn't exist, or the identity that wrote the job isn't allowed to use it. Databricks has no workspace
+// API to look up budget policies, so this is only known once a job is written: if the policy wasn't applied, restore
+// puts the job back as it was, so that an invalid policy doesn't leave it changed.
+func checkBudgetPolicy(ctx context.Context, client *databricks.WorkspaceClient, policyId string, jobId int64, restore func() error) error {
+	if policyId == "" {
+		return nil
+	}
+	job, err := client.Jobs.GetByJobId(ctx, jobId)
+	if err != nil {
+		return fmt.Errorf("unable to get job %d to check its budget policy: %w", jobId, err)
+	}
+	if job.EffectiveBudgetPolicyId == policyId {
+		return nil
+	}
+	err = fmt.Errorf("budget policy %s was not applied to job %d, check that it exists and that you are allowed to use it",
+		policyId, jobId)
+	if restoreErr := restore(); restoreErr != nil {
+		return errors.Join(err, fmt.Errorf("unable to restore job %d: %w", jobId, restoreErr))
+	}
+	return err
+}
+
+// EgressDestination is a host that serverless jobs connect to outside Databricks, and what for.
+type EgressDestination struct {
+	Host    string
+	Purpose string
+}
+
+// ServerlessEgressDestinations returns the hosts that the workspace's serverless network policy must allow the jobs
+// to reach: the API and auth endpoints of the scanners of the monitored schemas, or the proxy of hl_https_proxy
+// for those it proxies, and the Event Hub or webhook of dbx_findings_sink. Databricks enforces the policy, which is
+// an account-level setting that hldbx can't read with workspace credentials, so a blocked host only shows as a
+// request that fails or times out from the jobs' compute.
+func ServerlessEgressDestinations(config *utils.Config) []EgressDestination {
+	var destinations []EgressDestination
+	seen := map[string]bool{}
+	add := func(rawUrl string, purpose string) {
+		u, err := url.Parse(rawUrl)
+		if err != nil || u.Hostname() == "" || seen[u.Hostname()] {
+			return
+		}
+		seen[u.Hostname()] = true
+		destinations = append(destinations, EgressDestination{Host: u.Hostname(), Purpose: purpose})
+	}
+	// Like the validation notebook, the default scanner is always checked, and the others when a schema selects them
+	for _, scanner := range config.ScannersInUse() {
+		endpoints := []string{scanner.ApiUrl}
+		if scanner.UsesClientCredentials() {
+			endpoints = append(endpoints, scanner.AuthUrl)
+		}
+		for _, endpoint := range endpoints {
+			if config.HlHttpsProxy != "" && !noProxy(config.HlNoProxy, endpoint) {
+				add(config.HlHttpsProxy, "proxy of hl_https_proxy")
+			} else {
+				add(endpoint, "scanner "+scanner.Name)
+			}
+		}
+	}
+	if config.UsesEventHubFindingsSink() || config.UsesWebhookFindingsSink() {
+		add(config.DbxFindingsSink, "findings sink")
+	}
+	return destinations
+}
+
+// noProxy returns true if a URL's host matches the comma-separated hosts and domains of hl_no_proxy, like the
+// NO_PROXY environment variable that the notebooks set from it.
+func noProxy(hosts string, rawUrl string) bool {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, entry := range strings.Split(hosts, ",") {
+		entry = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(entry), "."))
+		if entry == "*" || entry != "" && (host == entry || strings.HasSuffix(host, "."+entry)) {
+			return true
+		}
+	}
+	return false
+}
+
+// EgressHosts returns the hosts of the destinations, comma-separated, for messages.
+func EgressHosts(destinations []EgressDestination) string {
+	hosts := make([]string, len(destinations))
+	for i, destination := range destinations {
+		hosts[i] = destination.Host
+	}
+	return strings.Join(hosts, ", ")
+}
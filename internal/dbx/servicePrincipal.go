@@ -0,0 +1,50 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go/service/iam"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// scimEqFilter returns a SCIM filter that matches an attribute equal to the value. The value is quoted as a SCIM string,
+// with its backslashes and double quotes escaped, so that it can't change the filter.
+func scimEqFilter(attribute, value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+	return fmt.Sprintf(`%s eq "%s"`, attribute, escaped)
+}
+
+// ServicePrincipalExists checks if a service principal with the specified application ID exists in the
+// Databricks workspace. Pass the ServicePrincipals service of a WorkspaceClient.
+// Log a fatal error and exit if the Databricks call fails.
+func ServicePrincipalExists(servicePrincipals iam.ServicePrincipalsInterface, applicationId string) bool {
+	// Filter on the server side rather than paging through every service principal in the workspace
+	found, err := servicePrincipals.ListAll(context.Background(), iam.ListServicePrincipalsRequest{
+		Filter:     scimEqFilter("applicationId", applicationId),
+		Attributes: "id,applicationId",
+	})
+	if err != nil {
+		utils.Fatalf("Error listing Databricks service principals: %v", err)
+	}
+	for _, sp := range found {
+		if sp.ApplicationId == applicationId {
+			return true
+		}
+	}
+	return false
+}
+
+// ListServicePrincipals returns the active service principals of the Databricks workspace, with their application IDs
+// and display names. Pass the ServicePrincipals service of a WorkspaceClient.
+func ListServicePrincipals(ctx context.Context, servicePrincipals iam.ServicePrincipalsInterface) ([]iam.ServicePrincipal, error) {
+	found, err := servicePrincipals.ListAll(ctx, iam.ListServicePrincipalsRequest{
+		Filter:     "active eq true",
+		Attributes: "id,applicationId,displayName",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list service principals: %w", err)
+	}
+	return found, nil
+}
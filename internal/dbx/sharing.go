@@ -0,0 +1,188 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/sharing"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlmodelmap"
+)
+
+// Name of the Delta Sharing share of the scan results when dbx_results_share isn't set
+const defaultResultsShareName = "hl_scan_results"
+
+// Schema that the results tables are shared under, so that every workspace's share has the same table names, and the
+// central workspace can union the catalogs that it mounts them as
+const sharedResultsSchema = "hiddenlayer"
+
+// Sharing identifier of a Databricks recipient's metastore: <cloud>:<region>:<metastore UUID>
+var sharingIdentifierPattern = regexp.MustCompile(`^[a-z]+:[a-z0-9-]+:[0-9a-fA-F-]{36}$`)
+
+// Names of shares and recipients: no dots, spaces, or quotes
+var sharingNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ResultsShare reports what ShareResults set up.
+type ResultsShare struct {
+	Share             string   `json:"share"`
+	Recipient         string   `json:"recipient"`
+	Tables            []string `json:"tables"`              // shared, as <schema>.<table> in the share
+	Added             []string `json:"added"`               // of the tables, the ones added by this run
+	Pending           []string `json:"pending"`             // not created yet by the monitoring and scan jobs
+	ProviderSharingId string   `json:"provider_sharing_id"` // of this workspace's metastore
+	RecipientCreated  bool     `json:"recipient_created"`   // the recipient didn't exist yet
+}
+
+// resultsShareName returns the name of the share of the scan results.
+func resultsShareName(config *utils.Config) string {
+	if config.DbxResultsShare != "" {
+		return config.DbxResultsShare
+	}
+	return defaultResultsShareName
+}
+
+// ValidateResultsShare checks the settings of the Delta Sharing share that sends the scan results to a central
+// workspace. The recipient and its sharing identifier go together.
+func ValidateResultsShare(config *utils.Config) error {
+	if config.DbxResultsShare != "" && !sharingNamePattern.MatchString(config.DbxResultsShare) {
+		return fmt.Errorf("invalid dbx_results_share %q, expected letters, digits, underscores, and dashes", config.DbxResultsShare)
+	}
+	if config.DbxResultsRecipient != "" && !sharingNamePattern.MatchString(config.DbxResultsRecipient) {
+		return fmt.Errorf("invalid dbx_results_recipient %q, expected letters, digits, underscores, and dashes", config.DbxResultsRecipient)
+	}
+	if config.DbxResultsRecipientId != "" && !sharingIdentifierPattern.MatchString(config.DbxResultsRecipientId) {
+		return fmt.Errorf("invalid dbx_results_recipient_id %q, expected the sharing identifier of the central "+
+			"workspace's metastore, <cloud>:<region>:<metastore UUID>", config.DbxResultsRecipientId)
+	}
+	if (config.DbxResultsRecipient == "") != (config.DbxResultsRecipientId == "") {
+		return errors.New("dbx_results_recipient and dbx_results_recipient_id must be set together")
+	}
+	return nil
+}
+
+// sharedResultsTables returns the tables of scan results to share, by the name they are shared as: the scans of
+// model versions and of artifacts, and the heartbeats of the monitoring job.
+func sharedResultsTables(config *utils.Config) map[string]string {
+	state := config.StateTable()
+	return map[string]string{
+		sharedResultsSchema + ".model_scans":    hlmodelmap.Table(config),
+		sharedResultsSchema + ".artifact_scans": state + "_artifacts",
+		sharedResultsSchema + ".heartbeats":     state,
+	}
+}
+
+// isNotFound returns true if a Databricks call failed because the object doesn't exist.
+func isNotFound(err error) bool {
+	return errors.Is(err, databricks.ErrResourceDoesNotExist) || errors.Is(err, databricks.ErrNotFound)
+}
+
+// ShareResults shares the scan results tables of this workspace with a central workspace through Delta Sharing. It
+// creates the share and the Databricks-to-Databricks recipient if they don't exist, adds the results tables that
+// exist to the share, and grants the recipient SELECT on it. It's idempotent: re-run it to add the tables that the
+// jobs create later.
+func ShareResults(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) (*ResultsShare, error) {
+	if config.DbxResultsRecipient == "" {
+		return nil, errors.New("no recipient, set dbx_results_recipient and dbx_results_recipient_id")
+	}
+	if config.StateTable() == "" {
+		return nil, errors.New("no results tables, set dbx_state_table or dbx_schemas")
+	}
+	result := &ResultsShare{Share: resultsShareName(config), Recipient: config.DbxResultsRecipient,
+		Tables: []string{}, Added: []string{}, Pending: []string{}}
+	summary, err := client.Metastores.Summary(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the metastore of the workspace: %w", err)
+	}
+	result.ProviderSharingId = summary.GlobalMetastoreId
+
+	share, err := client.Shares.Get(ctx, sharing.GetShareRequest{Name: result.Share, IncludeSharedData: true})
+	if isNotFound(err) {
+		share, err = client.Shares.Create(ctx, sharing.CreateShare{Name: result.Share,
+			Comment: fmt.Sprintf("HiddenLayer scan results of %s", client.Config.Host)})
+		if err != nil {
+			return nil, fmt.Errorf("unable to create share %s: %w", result.Share, err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get share %s: %w", result.Share, err)
+	}
+	shared := map[string]bool{}
+	for _, object := range share.Objects {
+		shared[strings.ToLower(object.Name)] = true
+	}
+	var updates []sharing.SharedDataObjectUpdate
+	tables := sharedResultsTables(config)
+	for _, sharedAs := range slices.Sorted(maps.Keys(tables)) {
+		table := tables[sharedAs]
+		if shared[strings.ToLower(table)] {
+			result.Tables = append(result.Tables, sharedAs)
+			continue
+		}
+		exists, err := client.Tables.ExistsByFullName(ctx, table)
+		if err != nil {
+			return nil, fmt.Errorf("unable to check that table %s exists: %w", table, err)
+		}
+		if !exists.TableExists {
+			result.Pending = append(result.Pending, table)
+			continue
+		}
+		updates = append(updates, sharing.SharedDataObjectUpdate{
+			Action: sharing.SharedDataObjectUpdateActionAdd,
+			DataObject: &sharing.SharedDataObject{Name: table, SharedAs: sharedAs,
+				DataObjectType: sharing.SharedDataObjectDataObjectTypeTable},
+		})
+		result.Tables = append(result.Tables, sharedAs)
+		result.Added = append(result.Added, sharedAs)
+	}
+	if len(updates) > 0 {
+		if _, err := client.Shares.Update(ctx, sharing.UpdateShare{Name: result.Share, Updates: updates}); err != nil {
+			return nil, fmt.Errorf("unable to add the results tables to share %s: %w", result.Share, err)
+		}
+	}
+
+	recipient, err := client.Recipients.GetByName(ctx, result.Recipient)
+	if isNotFound(err) {
+		recipient, err = client.Recipients.Create(ctx, sharing.CreateRecipient{
+			Name:                           result.Recipient,
+			AuthenticationType:             sharing.AuthenticationTypeDatabricks,
+			DataRecipientGlobalMetastoreId: config.DbxResultsRecipientId,
+			Comment:                        "Central workspace that aggregates HiddenLayer scan results",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to create recipient %s: %w", result.Recipient, err)
+		}
+		result.RecipientCreated = true
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get recipient %s: %w", result.Recipient, err)
+	} else if !strings.EqualFold(recipient.DataRecipientGlobalMetastoreId, config.DbxResultsRecipientId) {
+		return nil, fmt.Errorf("recipient %s exists for metastore %s, not dbx_results_recipient_id %s; choose another "+
+			"dbx_results_recipient", result.Recipient, recipient.DataRecipientGlobalMetastoreId, config.DbxResultsRecipientId)
+	}
+
+	_, err = client.Shares.UpdatePermissions(ctx, sharing.UpdateSharePermissions{Name: result.Share,
+		Changes: []sharing.PermissionsChange{{Principal: result.Recipient, Add: []string{"SELECT"}}}})
+	if err != nil {
+		return nil, fmt.Errorf("unable to grant recipient %s access to share %s: %w", result.Recipient, result.Share, err)
+	}
+	return result, nil
+}
+
+// UnshareResults deletes the recipient and the share that ShareResults created. The results tables are kept. Objects
+// that no longer exist are not an error.
+func UnshareResults(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) error {
+	if config.DbxResultsRecipient != "" {
+		if err := client.Recipients.DeleteByName(ctx, config.DbxResultsRecipient); err != nil && !isNotFound(err) {
+			return fmt.Errorf("unable to delete recipient %s: %w", config.DbxResultsRecipient, err)
+		}
+	}
+	share := resultsShareName(config)
+	if err := client.Shares.DeleteByName(ctx, share); err != nil && !isNotFound(err) {
+		return fmt.Errorf("unable to delete share %s: %w", share, err)
+	}
+	return nil
+}
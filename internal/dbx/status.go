@@ -0,0 +1,253 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/databricks/databricks-sdk-go/service/workspace"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Recent workspace runs to look through for scan job runs, per scan run asked for, since other jobs run too
+const scanRunsLookahead = 20
+
+// ScanStatus summarizes the scanning of the monitored schemas: the model versions waiting to be scanned, how long
+// recent scans took, and the detections found, to tune dbx_max_active_scan_jobs and the schedule. Each candidate model
+// version is in one of Backlog, Scanning, OutageBacklog, Scanned, Failed, and Skipped, which add up to Candidates.
+type ScanStatus struct {
+	Candidates          int          `json:"candidates"`           // model versions that the scan trigger picks
+	Backlog             int          `json:"backlog"`              // found, but not scanned yet
+	Scanning            int          `json:"scanning"`             // their scan jobs are running
+	OutageBacklog       int          `json:"outage_backlog"`       // waiting for the HiddenLayer API to be reachable
+	Quarantined         int          `json:"quarantined"`          // of the outage backlog
+	Scanned             int          `json:"scanned"`              // finished scans
+	Failed              int          `json:"failed"`               // failed scans
+	Skipped             int          `json:"skipped"`              // skipped or canceled scans, and any other status
+	Detections          int          `json:"detections"`           // finished scans that found threats
+	UntriagedDetections int          `json:"untriaged_detections"` // of the detections
+	ScanRuns            int          `json:"scan_runs"`            // recent scan runs that the latency is computed over
+	MeanScanSeconds     float64      `json:"mean_scan_seconds"`
+	MaxScanSeconds      float64      `json:"max_scan_seconds"`
+	ScansPerHour        float64      `json:"scans_per_hour"`              // of the recent scan runs, from the first start to the last end
+	CapacityPerHour     float64      `json:"capacity_per_hour"`           // scans an hour at the concurrency limit and the mean latency
+	MaxActiveScanJobs   int          `json:"max_active_scan_jobs"`        // dbx_max_active_scan_jobs
+	LastHeartbeat       *Heartbeat   `json:"last_heartbeat,omitempty"`    // of the latest monitoring job run
+	HeartbeatProblem    string       `json:"heartbeat_problem,omitempty"` // see HeartbeatStatus.Problem
+	Credentials         []HLCredsAge `json:"credentials,omitempty"`       // age of the HiddenLayer credentials of each secrets scope
+	Deployment          Deployment   `json:"deployment"`
+}
+
+// Deployment reports whether the resources that autoscan deployed are in place: the monitoring job, its schedule and
+// latest run, the notebooks it runs, the secrets scopes of the monitored schemas, and the clusters that the jobs run on.
+type Deployment struct {
+	MonitorJobId        int64          `json:"monitor_job_id,omitempty"` // 0 if there is no monitoring job
+	MonitorJobName      string         `json:"monitor_job_name,omitempty"`
+	Schedule            string         `json:"schedule,omitempty"` // Quartz cron, in UTC
+	Paused              bool           `json:"paused"`
+	PausedBy            string         `json:"paused_by,omitempty"` // identity that paused the schedule with hldbx pause
+	PausedAt            string         `json:"paused_at,omitempty"` // RFC 3339
+	LastRun             *RunSummary    `json:"last_run,omitempty"`  // running or completed
+	NotebooksDir        string         `json:"notebooks_dir"`
+	MissingNotebooks    []string       `json:"missing_notebooks"`
+	SecretsScopes       []string       `json:"secrets_scopes"`
+	MissingSecretScopes []string       `json:"missing_secrets_scopes"` // <catalog>.<schema> of the schemas without one
+	Compute             *ComputeStatus `json:"compute"`
+}
+
+// GetScanStatus counts the scan results of the model versions that the scan trigger picks, and computes the
+// latency and throughput of the latest scanRuns completed scan job runs.
+func GetScanStatus(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, scanRuns int) (*ScanStatus, error) {
+	status := &ScanStatus{MaxActiveScanJobs: config.MaxActiveScanJobs()}
+	if err := status.Deployment.check(ctx, client, config); err != nil {
+		return nil, err
+	}
+	results, err := ListScanResults(ctx, client, config)
+	if err != nil {
+		return nil, err
+	}
+	status.Candidates = len(results)
+	for _, result := range results {
+		switch result.Status {
+		case scanStatusDone:
+			status.Scanned++
+			if result.IsDetection() {
+				status.Detections++
+				if !result.IsTriaged() {
+					status.UntriagedDetections++
+				}
+			}
+		case scanStatusPending:
+			status.Scanning++
+		case scanStatusScanPending:
+			status.OutageBacklog++
+			if result.Quarantined {
+				status.Quarantined++
+			}
+		case scanStatusFailed:
+			status.Failed++
+		case "", scanStatusUnscanned:
+			status.Backlog++
+		default:
+			status.Skipped++
+		}
+	}
+
+	if err := status.addScanLatency(ctx, client, scanRuns); err != nil {
+		return nil, err
+	}
+	if config.UsesClientCredentials() {
+		if status.Credentials, err = CheckHLCredsAge(ctx, client, config); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case status.Deployment.Schedule != "":
+		heartbeat, err := CheckHeartbeat(ctx, client, config)
+		if err != nil {
+			return nil, err
+		}
+		status.LastHeartbeat, status.HeartbeatProblem = heartbeat.Last, heartbeat.Problem()
+	case status.Deployment.MonitorJobId != 0:
+		if status.LastHeartbeat, err = latestHeartbeat(ctx, client, status.Deployment.MonitorJobId); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// check finds the monitoring job, its schedule and latest run, the notebooks and secrets scopes that are missing, and
+// the deleted clusters that the jobs run on.
+// The notebooks are looked for in the directory that the job runs them from, or that autoscan would upload them to if
+// there is no job.
+func (d *Deployment) check(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) error {
+	d.NotebooksDir = getHLWorkspaceDirectory(config)
+	d.MissingNotebooks = []string{}
+	d.SecretsScopes = []string{}
+	d.MissingSecretScopes = []string{}
+	monitorJobs, err := findMonitorJobs(ctx, client)
+	if err != nil {
+		return err
+	}
+	if len(monitorJobs) > 0 {
+		job := monitorJobs[0]
+		d.MonitorJobId = job.JobId
+		d.MonitorJobName = job.Settings.Name
+		if job.Settings.Schedule != nil {
+			d.Schedule = job.Settings.Schedule.QuartzCronExpression
+			d.Paused = job.Settings.Schedule.PauseStatus == jobs.PauseStatusPaused
+			if d.Paused {
+				// Best effort, the audit log only tells who paused the schedule with hldbx pause
+				if pause, err := lastPause(ctx, client, config); err == nil && pause != nil {
+					d.PausedBy, d.PausedAt = pause.Operator.Identity, pause.Time
+				}
+			}
+		} else {
+			d.Paused = true
+		}
+		for _, task := range job.Settings.Tasks {
+			if task.NotebookTask != nil && task.TaskKey == monitorTaskKey {
+				d.NotebooksDir = path.Dir(task.NotebookTask.NotebookPath)
+			}
+		}
+		if d.LastRun, err = latestRun(ctx, client, job.JobId); err != nil {
+			return err
+		}
+	}
+
+	objects, err := client.Workspace.ListAll(ctx, workspace.ListWorkspaceRequest{Path: d.NotebooksDir})
+	if err != nil && !errors.Is(err, databricks.ErrResourceDoesNotExist) && !errors.Is(err, databricks.ErrNotFound) {
+		return fmt.Errorf("unable to list workspace directory %s: %w", d.NotebooksDir, err)
+	}
+	existing := map[string]bool{}
+	for _, object := range objects {
+		existing[path.Base(object.Path)] = true
+	}
+	entries, err := sourceFiles.ReadDir("notebooks")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		// Notebooks are imported without the .py extension, other files keep it
+		if !entry.IsDir() && !existing[entry.Name()] && !existing[strings.TrimSuffix(entry.Name(), ".py")] {
+			d.MissingNotebooks = append(d.MissingNotebooks, entry.Name())
+		}
+	}
+
+	scopes, err := client.Secrets.ListScopesAll(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to list secrets scopes: %w", err)
+	}
+	for _, scope := range scopes {
+		if scope.Name == consolidatedSecretsScope || strings.HasPrefix(scope.Name, consolidatedSecretsScope+".") {
+			d.SecretsScopes = append(d.SecretsScopes, scope.Name)
+		}
+	}
+	for _, schema := range config.DbxSchemas {
+		// A schema's secrets are in its own scope, or in the consolidated scope if the workspace had no scopes left
+		if !slices.Contains(d.SecretsScopes, ownSecretsLocation(schema).Scope) && !slices.Contains(d.SecretsScopes, consolidatedSecretsScope) {
+			d.MissingSecretScopes = append(d.MissingSecretScopes, fmt.Sprintf("%s.%s", schema.Catalog, schema.Schema))
+		}
+	}
+	d.Compute, err = CheckJobCompute(ctx, client, config)
+	return err
+}
+
+// latestRun returns the latest run of a job, with the class of its failure if it failed, or nil if it has no runs.
+func latestRun(ctx context.Context, client *databricks.WorkspaceClient, jobId int64) (*RunSummary, error) {
+	runs := client.Jobs.ListRuns(ctx, jobs.ListRunsRequest{JobId: jobId, ExpandTasks: true, Limit: 1})
+	if !runs.HasNext(ctx) {
+		return nil, nil
+	}
+	run, err := runs.Next(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list runs of job %d: %w", jobId, err)
+	}
+	summary := &RunSummary{RunId: run.RunId, StartTime: time.UnixMilli(run.StartTime).UTC(),
+		DurationSeconds: runDuration(run) / 1000, State: runState(run.State), Url: run.RunPageUrl}
+	if run.State != nil && run.State.ResultState != "" && run.State.ResultState != jobs.RunResultStateSuccess {
+		summary.Failure, summary.Message = classifyFailure(ctx, client, run)
+	}
+	return summary, nil
+}
+
+// addScanLatency computes the mean and longest durations of the latest completed scan job runs, and their throughput.
+func (s *ScanStatus) addScanLatency(ctx context.Context, client *databricks.WorkspaceClient, scanRuns int) error {
+	// Scan jobs are created on the fly by the monitor notebook, so look for them by name among all recent runs
+	runs := client.Jobs.ListRuns(ctx, jobs.ListRunsRequest{CompletedOnly: true, Limit: 25})
+	var total, first, last int64
+	for i := 0; i < scanRuns*scanRunsLookahead && s.ScanRuns < scanRuns && runs.HasNext(ctx); i++ {
+		run, err := runs.Next(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to list job runs: %w", err)
+		}
+		if !strings.HasPrefix(run.RunName, scanJobNamePrefix) {
+			continue
+		}
+		duration := runDuration(run)
+		s.ScanRuns++
+		total += duration
+		s.MaxScanSeconds = max(s.MaxScanSeconds, float64(duration)/1000)
+		if first == 0 || run.StartTime < first {
+			first = run.StartTime
+		}
+		last = max(last, run.StartTime+duration)
+	}
+	if s.ScanRuns == 0 {
+		return nil
+	}
+	s.MeanScanSeconds = float64(total) / float64(s.ScanRuns) / 1000
+	if window := time.Duration(last-first) * time.Millisecond; window > 0 {
+		s.ScansPerHour = float64(s.ScanRuns) / window.Hours()
+	}
+	if s.MeanScanSeconds > 0 {
+		s.CapacityPerHour = float64(s.MaxActiveScanJobs) * time.Hour.Seconds() / s.MeanScanSeconds
+	}
+	return nil
+}
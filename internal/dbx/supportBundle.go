@@ -0,0 +1,208 @@
+package dbx
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Number of recent monitor job runs to include in a support bundle
+const supportBundleRecentRuns = 5
+
+// supportBundle accumulates the files that make up a support bundle, in the order they were added.
+type supportBundle struct {
+	names    []string
+	contents map[string][]byte
+	checks   []string
+}
+
+// addJSON adds a value to the bundle as an indented JSON file.
+func (b *supportBundle) addJSON(name string, value any) {
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		b.check(fmt.Sprintf("Unable to encode %s: %v", name, err))
+		return
+	}
+	b.addFile(name, content)
+}
+
+// addFile adds raw content to the bundle.
+func (b *supportBundle) addFile(name string, content []byte) {
+	b.names = append(b.names, name)
+	b.contents[name] = content
+}
+
+// check records the outcome of one diagnostic check.
+func (b *supportBundle) check(message string) {
+	utils.Printf("%s\n", message)
+	b.checks = append(b.checks, message)
+}
+
+// SupportBundle collects diagnostic information about the HiddenLayer scanning setup in the Databricks workspace
+// and writes it to a zip file at the given path, for sharing with HiddenLayer support.
+// Databricks SDK calls are traced while collecting, down to their requests and responses, and the trace is included
+// in the bundle.
+// Secret values from the configuration are redacted from everything that is written.
+func SupportBundle(ctx context.Context, config *utils.Config, path string) error {
+	bundle := &supportBundle{contents: map[string][]byte{}}
+
+	// Capture the log messages down to the Databricks SDK's dumps of its requests and responses
+	var trace bytes.Buffer
+	previousOutput, previousLevel := utils.Logging()
+	utils.ConfigureLogging(&trace, utils.LevelTrace)
+	defer func() {
+		utils.ConfigureLogging(previousOutput, previousLevel)
+	}()
+
+	bundle.addJSON("environment.json", map[string]string{
+		"hldbx_version":  utils.Version,
+		"os":             runtime.GOOS,
+		"arch":           runtime.GOARCH,
+		"go_version":     runtime.Version(),
+		"collected_at":   time.Now().UTC().Format(time.RFC3339),
+		"hldbx_home":     utils.HomeDir(),
+		"hldbx_profile":  utils.ProfileName(),
+		"correlation_id": utils.CorrelationId(),
+	})
+	bundle.addJSON("config.json", config.Redacted())
+
+	client, err := Auth(config.DbxHost, config.DbxToken)
+	if err != nil {
+		bundle.check(fmt.Sprintf("FAIL: unable to authenticate to Databricks at %s: %v", config.DbxHost, err))
+	} else {
+		bundle.check(fmt.Sprintf("OK: authenticated to Databricks at %s", config.DbxHost))
+		collectWorkspaceDiagnostics(ctx, client, config, bundle)
+	}
+
+	bundle.addFile("checks.txt", []byte(strings.Join(bundle.checks, "\n")+"\n"))
+	bundle.addFile("trace.log", trace.Bytes())
+	return writeSupportBundle(path, bundle, config)
+}
+
+// collectWorkspaceDiagnostics checks the Databricks resources used for scanning, and adds the monitor job
+// definitions and recent run outputs to the bundle. Failures are recorded in the bundle rather than being fatal.
+func collectWorkspaceDiagnostics(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, bundle *supportBundle) {
+	if config.DbxClusterId != "" {
+		cluster, err := client.Clusters.GetByClusterId(ctx, config.DbxClusterId)
+		if err != nil {
+			bundle.check(fmt.Sprintf("FAIL: unable to get cluster %s: %v", config.DbxClusterId, err))
+		} else {
+			bundle.check(fmt.Sprintf("OK: cluster %s found, state %s", config.DbxClusterId, cluster.State))
+		}
+	}
+
+	for _, schema := range config.DbxSchemas {
+		schemaFullName := fmt.Sprintf("%s.%s", schema.Catalog, schema.Schema)
+		if _, err := client.Schemas.GetByFullName(ctx, schemaFullName); err != nil {
+			bundle.check(fmt.Sprintf("FAIL: unable to get schema %s: %v", schemaFullName, err))
+		} else {
+			bundle.check(fmt.Sprintf("OK: schema %s found", schemaFullName))
+		}
+	}
+
+	if config.UsesClientCredentials() {
+		ages, err := CheckHLCredsAge(ctx, client, config)
+		if err != nil {
+			bundle.check(fmt.Sprintf("FAIL: %v", err))
+		}
+		for _, age := range ages {
+			if age.Expired {
+				bundle.check(fmt.Sprintf("WARN: HiddenLayer credentials in scope %s were last rotated %s, rotate them", age.Scope, age.RotatedAt.Format(time.DateOnly)))
+			} else {
+				bundle.check(fmt.Sprintf("OK: HiddenLayer credentials in scope %s were last rotated %s", age.Scope, age.RotatedAt.Format(time.DateOnly)))
+			}
+		}
+	}
+
+	heartbeat, err := CheckHeartbeat(ctx, client, config)
+	switch {
+	case err != nil:
+		bundle.check(fmt.Sprintf("FAIL: unable to check monitoring job heartbeats: %v", err))
+	case heartbeat.Problem() != "":
+		bundle.check(fmt.Sprintf("WARN: %s", heartbeat.Problem()))
+	case heartbeat.Last != nil:
+		bundle.check(fmt.Sprintf("OK: monitoring job's last heartbeat was at %s", heartbeat.Last.HeartbeatAt.Format(time.RFC3339)))
+	default:
+		bundle.check("OK: monitoring job has no heartbeats yet")
+	}
+
+	results, err := ListScanResults(ctx, client, config)
+	if err != nil {
+		bundle.check(fmt.Sprintf("FAIL: unable to list scan results: %v", err))
+	} else if backlog, quarantined := outageBacklog(results); backlog > 0 {
+		bundle.check(fmt.Sprintf("WARN: %d model version(s) are waiting for the HiddenLayer API to be reachable, "+
+			"%d of them quarantined", backlog, quarantined))
+	} else {
+		bundle.check("OK: no model versions are waiting for the HiddenLayer API")
+	}
+
+	workspaceDir := getHLWorkspaceDirectory(config)
+	if _, err := client.Workspace.GetStatusByPath(ctx, workspaceDir); err != nil {
+		bundle.check(fmt.Sprintf("FAIL: unable to get workspace directory %s: %v", workspaceDir, err))
+	} else {
+		bundle.check(fmt.Sprintf("OK: workspace directory %s found", workspaceDir))
+	}
+
+	monitorJobs, err := findMonitorJobs(ctx, client)
+	if err != nil {
+		bundle.check(fmt.Sprintf("FAIL: %v", err))
+		return
+	}
+	bundle.check(fmt.Sprintf("OK: found %d model monitoring job(s)", len(monitorJobs)))
+
+	for _, job := range monitorJobs {
+		bundle.addJSON(fmt.Sprintf("jobs/%d.json", job.JobId), job)
+
+		runs := client.Jobs.ListRuns(ctx, jobs.ListRunsRequest{JobId: job.JobId, ExpandTasks: true, Limit: supportBundleRecentRuns})
+		for i := 0; i < supportBundleRecentRuns && runs.HasNext(ctx); i++ {
+			run, err := runs.Next(ctx)
+			if err != nil {
+				bundle.check(fmt.Sprintf("FAIL: unable to list runs for job %d: %v", job.JobId, err))
+				break
+			}
+			bundle.addJSON(fmt.Sprintf("runs/%d/run.json", run.RunId), run)
+			for _, task := range run.Tasks {
+				output, err := client.Jobs.GetRunOutputByRunId(ctx, task.RunId)
+				if err != nil {
+					bundle.check(fmt.Sprintf("FAIL: unable to get output of task run %d: %v", task.RunId, err))
+					continue
+				}
+				bundle.addJSON(fmt.Sprintf("runs/%d/output_%d.json", run.RunId, task.RunId), output)
+			}
+		}
+	}
+}
+
+// writeSupportBundle writes the bundle contents to a zip file, redacting any secrets from the configuration.
+func writeSupportBundle(path string, bundle *supportBundle, config *utils.Config) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("unable to create support bundle %s: %w", path, err)
+	}
+	defer file.Close()
+
+	zipWriter := zip.NewWriter(file)
+	for _, name := range bundle.names {
+		entry, err := zipWriter.Create(name)
+		if err != nil {
+			return fmt.Errorf("unable to add %s to support bundle: %w", name, err)
+		}
+		if _, err := entry.Write([]byte(utils.RedactConfigSecrets(config, string(bundle.contents[name])))); err != nil {
+			return fmt.Errorf("unable to write %s to support bundle: %w", name, err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("unable to finish support bundle %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package dbx
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/client"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/hl"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Longest triage reason, so that the reason fits in a model version tag value
+const maxTriageReasonLength = 200
+
+// Longest time that a rule may be suppressed for, so that suppressions are revisited
+const MaxSuppression = 365 * 24 * time.Hour
+
+// Rule IDs, as reported by the HiddenLayer API, that can be used in a tag name
+var ruleIdPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Suppression stops alerts on the detections of a rule in a model version until it expires.
+// It is recorded as JSON in the model version's hl_scan_suppress_<rule> tag. This must match hl_common.py.
+type Suppression struct {
+	Rule      string    `json:"-"`
+	By        string    `json:"by"`
+	Reason    string    `json:"reason"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// activeSuppressions returns the suppressions in the tags of a model version that haven't expired, sorted by rule.
+// Suppression tags that can't be parsed are ignored.
+func activeSuppressions(tags map[string]string, now time.Time) []Suppression {
+	var suppressions []Suppression
+	for key, value := range tags {
+		rule, found := strings.CutPrefix(key, hlScanSuppressTagPrefix)
+		if !found {
+			continue
+		}
+		var suppression Suppression
+		if err := json.Unmarshal([]byte(value), &suppression); err != nil || !suppression.ExpiresAt.After(now) {
+			continue
+		}
+		suppression.Rule = rule
+		suppressions = append(suppressions, suppression)
+	}
+	sort.Slice(suppressions, func(i, j int) bool { return suppressions[i].Rule < suppressions[j].Rule })
+	return suppressions
+}
+
+// validateTriageReason checks the reason given for a triage decision.
+func validateTriageReason(reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("a reason is required, so that others know why the detections were triaged")
+	}
+	if len(reason) > maxTriageReasonLength {
+		return fmt.Errorf("the reason is %d characters long, the maximum is %d", len(reason), maxTriageReasonLength)
+	}
+	return nil
+}
+
+// AcknowledgeDetection records in the scan tags of a model version that its detections were reviewed, by the
+// current user and for the reason given, so that they are no longer alerted on. Rescans keep the acknowledgement.
+// Returns the updated scan result, or an error if the model version has no detections.
+func AcknowledgeDetection(ctx context.Context, dbxClient *databricks.WorkspaceClient, fullName string, version int,
+	reason string) (ScanResult, error) {
+	if err := validateTriageReason(reason); err != nil {
+		return ScanResult{}, err
+	}
+	result, err := getScanResult(ctx, dbxClient, fullName, version)
+	if err != nil {
+		return result, err
+	}
+	if !result.IsDetection() {
+		return result, fmt.Errorf("model %s version %d has no detections to acknowledge", fullName, version)
+	}
+	me, err := dbxClient.CurrentUser.Me(ctx)
+	if err != nil {
+		return result, fmt.Errorf("unable to get the current user: %w", err)
+	}
+	tags := [][2]string{
+		{hlScanAckByTag, me.UserName},
+		{hlScanAckReasonTag, reason},
+		{hlScanAckAtTag, time.Now().UTC().Format(time.RFC3339)},
+	}
+	for _, tag := range tags {
+		if err := setModelVersionTag(ctx, dbxClient, fullName, version, tag[0], tag[1]); err != nil {
+			return result, err
+		}
+	}
+	result.AckBy = me.UserName
+	result.AckReason = reason
+	return result, nil
+}
+
+// SuppressRule records in the scan tags of a model version that the detections of a rule are not to be alerted on
+// until the expiry, by the current user and for the reason given. Returns the updated scan result, or an error if the
+// model version's detections didn't match the rule.
+func SuppressRule(ctx context.Context, dbxClient *databricks.WorkspaceClient, fullName string, version int,
+	rule string, reason string, expiresAt time.Time) (ScanResult, error) {
+	if !ruleIdPattern.MatchString(rule) {
+		return ScanResult{}, fmt.Errorf("invalid rule ID %q", rule)
+	}
+	if err := validateTriageReason(reason); err != nil {
+		return ScanResult{}, err
+	}
+	if !expiresAt.After(time.Now()) {
+		return ScanResult{}, fmt.Errorf("the suppression must expire in the future")
+	}
+	if time.Until(expiresAt) > MaxSuppression {
+		return ScanResult{}, fmt.Errorf("a rule may be suppressed for at most %d days", int(MaxSuppression.Hours()/24))
+	}
+	result, err := getScanResult(ctx, dbxClient, fullName, version)
+	if err != nil {
+		return result, err
+	}
+	if !result.IsDetection() {
+		return result, fmt.Errorf("model %s version %d has no detections to suppress", fullName, version)
+	}
+	if len(result.Rules) == 0 {
+		return result, fmt.Errorf("the rules that the detections of model %s version %d matched aren't recorded, "+
+			"acknowledge them instead, or rescan the version", fullName, version)
+	}
+	if !slices.Contains(result.Rules, rule) {
+		return result, fmt.Errorf("the detections of model %s version %d didn't match rule %s, they matched %s",
+			fullName, version, rule, strings.Join(result.Rules, ", "))
+	}
+	me, err := dbxClient.CurrentUser.Me(ctx)
+	if err != nil {
+		return result, fmt.Errorf("unable to get the current user: %w", err)
+	}
+	suppression := Suppression{Rule: rule, By: me.UserName, Reason: reason, ExpiresAt: expiresAt.UTC().Truncate(time.Second)}
+	value, err := json.Marshal(suppression)
+	if err != nil {
+		return result, err
+	}
+	if err := setModelVersionTag(ctx, dbxClient, fullName, version, hlScanSuppressTagPrefix+rule, string(value)); err != nil {
+		return result, err
+	}
+	result.Suppressed = append(result.Suppressed, suppression)
+	return result, nil
+}
+
+// scannerAccessToken returns the access token that hldbx calls a scanner's API with, or "" if it needs none, and a
+// function that revokes it once the caller is done with it.
+func scannerAccessToken(ctx context.Context, config *utils.Config, scanner utils.ScannerConfig,
+	tlsConfig *tls.Config) (string, func(), error) {
+	switch {
+	case scanner.UsesClientCredentials():
+		token, err := hl.Auth(scanner.AuthUrl, scanner.ClientID, scanner.ClientSecret, tlsConfig)
+		return token, func() {}, err
+	case scanner.Auth == utils.ScannerAuthDatabricksToken:
+		// A scanner behind the same SSO accepts a short-lived Databricks token, as the scan jobs mint
+		return mintScannerToken(ctx, config)
+	}
+	// The enterprise scanner doesn't require credentials
+	return "", func() {}, nil
+}
+
+// getScanResult returns the scan result recorded in the tags of a model version.
+func getScanResult(ctx context.Context, dbxClient *databricks.WorkspaceClient, fullName string, version int) (ScanResult, error) {
+	tags, err := getModelVersionTags(ctx, dbxClient, fullName, version)
+	if err != nil {
+		return ScanResult{}, err
+	}
+	return scanResultFromTags(fullName, version, tags), nil
+}
+
+// setModelVersionTag sets a tag of a Unity Catalog model version, through the MLflow Unity Catalog REST API,
+// like getModelVersionTags.
+func setModelVersionTag(ctx context.Context, dbxClient *databricks.WorkspaceClient, fullName string, version int,
+	key string, value string) error {
+	apiClient, err := client.New(dbxClient.Config)
+	if err != nil {
+		return err
+	}
+	err = apiClient.Do(ctx, http.MethodPost, "/api/2.0/mlflow/unity-catalog/model-versions/set-tag", nil, nil,
+		map[string]any{"name": fullName, "version": strconv.Itoa(version), "key": key, "value": value}, nil)
+	if err != nil {
+		return fmt.Errorf("unable to set tag %s of model %s version %d: %w", key, fullName, version, err)
+	}
+	return nil
+}
+
+// deleteModelVersionTag deletes a tag of a Unity Catalog model version, through the MLflow Unity Catalog REST API,
+// like setModelVersionTag.
+func deleteModelVersionTag(ctx context.Context, dbxClient *databricks.WorkspaceClient, fullName string, version int,
+	key string) error {
+	apiClient, err := client.New(dbxClient.Config)
+	if err != nil {
+		return err
+	}
+	err = apiClient.Do(ctx, http.MethodDelete, "/api/2.0/mlflow/unity-catalog/model-versions/delete-tag", nil, nil,
+		map[string]any{"name": fullName, "version": strconv.Itoa(version), "key": key}, nil)
+	if err != nil {
+		return fmt.Errorf("unable to delete tag %s of model %s version %d: %w", key, fullName, version, err)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package dbx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/databricks/databricks-sdk-go/service/workspace"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Kinds of the resources that autoscan creates, in the order that FindInstalledResources returns them for deletion:
+// the scheduled jobs first, so that the monitoring job doesn't create scan jobs while they are deleted
+const (
+	ResourceJob          = "job"
+	ResourceScanJob      = "scan job"
+	ResourceNotebooks    = "notebooks"
+	ResourceManifest     = "manifest"
+	ResourceSecretsScope = "secrets scope"
+)
+
+// InstalledResource is a resource in the Databricks workspace that autoscan, or the monitoring job, created.
+// Unverified resources are only named like those, without the hl_job tag or a listing in the installation manifest,
+// so they may belong to another installation or a user; uninstall lists them for an operator to check, and doesn't
+// delete them.
+type InstalledResource struct {
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	JobId      int64  `json:"job_id,omitempty"`
+	Unverified bool   `json:"unverified,omitempty"`
+}
+
+// String returns a readable description of the resource.
+func (r InstalledResource) String() string {
+	if r.JobId != 0 {
+		return fmt.Sprintf("%s %s (ID %d)", r.Kind, r.Name, r.JobId)
+	}
+	return fmt.Sprintf("%s %s", r.Kind, r.Name)
+}
+
+// FindInstalledResources discovers the resources that autoscan created: the jobs tagged by hldbx, including the scan
+// jobs that the monitoring job created, the notebooks of each hldbx version and the manifest in the workspace
+// directory, and the HL secrets scopes of the schemas in the manifest. Jobs and secrets scopes that are only named
+// like those, and untagged jobs that the manifest doesn't list, are returned unverified. Tables, model and model
+// version tags, and the grants that hldbx abac revoked aren't included, since they hold scan results.
+func FindInstalledResources(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) ([]InstalledResource, error) {
+	manifest, err := ReadManifest(ctx, client, config)
+	if err != nil {
+		return nil, err
+	}
+	var manifestJobIds []int64
+	var manifestSchemas []utils.CatalogSchemaConfig
+	if manifest != nil {
+		manifestJobIds = []int64{manifest.MonitorJobId, manifest.GuardrailJobId, manifest.VerifyJobId, manifest.OnDemandJobId}
+		manifestSchemas = manifest.Schemas
+	}
+
+	var resources []InstalledResource
+	// The monitoring job first, since it creates the scan jobs
+	for _, key := range []string{monitorJobKey, guardrailJobKey, verifyJobKey, onDemandJobKey} {
+		found, err := findJobs(ctx, client, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, job := range found {
+			_, tagged := job.Settings.Tags[hlJobTag]
+			resources = append(resources, InstalledResource{Kind: ResourceJob, Name: job.Settings.Name, JobId: job.JobId,
+				Unverified: !tagged && !slices.Contains(manifestJobIds, job.JobId)})
+		}
+	}
+
+	all, err := client.Jobs.ListAll(ctx, jobs.ListJobsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list jobs: %w", err)
+	}
+	for _, job := range all {
+		if job.Settings == nil {
+			continue
+		}
+		tag, tagged := job.Settings.Tags[hlJobTag]
+		switch {
+		case tag == scanJobKind:
+			resources = append(resources, InstalledResource{Kind: ResourceScanJob, Name: job.Settings.Name, JobId: job.JobId})
+		case !tagged && strings.HasPrefix(job.Settings.Name, scanJobNamePrefix):
+			// Created by a monitoring job from before scan jobs were tagged, or by someone else
+			resources = append(resources, InstalledResource{Kind: ResourceScanJob, Name: job.Settings.Name, JobId: job.JobId,
+				Unverified: true})
+		}
+	}
+
+	dir := config.DbxWorkspaceDir
+	if dir == "" {
+		dir = defaultWorkspaceDir
+	}
+	objects, err := client.Workspace.ListAll(ctx, workspace.ListWorkspaceRequest{Path: dir})
+	if err != nil && !errors.Is(err, databricks.ErrResourceDoesNotExist) && !errors.Is(err, databricks.ErrNotFound) {
+		return nil, fmt.Errorf("unable to list workspace directory %s: %w", dir, err)
+	}
+	for _, object := range objects {
+		switch {
+		case object.ObjectType == workspace.ObjectTypeDirectory:
+			// Only the directories of hldbx versions, which hold its notebooks
+			notebooks, err := client.Workspace.ListAll(ctx, workspace.ListWorkspaceRequest{Path: object.Path})
+			if err != nil {
+				return nil, fmt.Errorf("unable to list workspace directory %s: %w", object.Path, err)
+			}
+			if slices.ContainsFunc(notebooks, func(o workspace.ObjectInfo) bool { return path.Base(o.Path) == modelMonitorNotebookName }) {
+				resources = append(resources, InstalledResource{Kind: ResourceNotebooks, Name: object.Path})
+			}
+		case path.Base(object.Path) == manifestFileName:
+			resources = append(resources, InstalledResource{Kind: ResourceManifest, Name: object.Path})
+		}
+	}
+
+	scopes, err := client.Secrets.ListScopesAll(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list secrets scopes: %w", err)
+	}
+	for _, scope := range scopes {
+		if scope.Name != consolidatedSecretsScope && !strings.HasPrefix(scope.Name, consolidatedSecretsScope+".") {
+			continue
+		}
+		verified := slices.ContainsFunc(manifestSchemas, func(schema utils.CatalogSchemaConfig) bool {
+			return ownSecretsLocation(schema).Scope == scope.Name
+		})
+		if scope.Name == consolidatedSecretsScope {
+			if verified, err = onlyManifestSecrets(ctx, client, manifestSchemas); err != nil {
+				return nil, err
+			}
+		}
+		resources = append(resources, InstalledResource{Kind: ResourceSecretsScope, Name: scope.Name, Unverified: !verified})
+	}
+	return resources, nil
+}
+
+// onlyManifestSecrets returns true if the consolidated secrets scope only holds the secrets of the schemas in the
+// manifest, so that deleting it doesn't delete another installation's.
+func onlyManifestSecrets(ctx context.Context, client *databricks.WorkspaceClient, schemas []utils.CatalogSchemaConfig) (bool, error) {
+	if len(schemas) == 0 {
+		return false, nil
+	}
+	secrets, err := client.Secrets.ListSecretsAll(ctx, workspace.ListSecretsRequest{Scope: consolidatedSecretsScope})
+	if err != nil {
+		return false, fmt.Errorf("unable to list the secrets of scope %s: %w", consolidatedSecretsScope, err)
+	}
+	for _, secret := range secrets {
+		if !slices.ContainsFunc(schemas, func(schema utils.CatalogSchemaConfig) bool {
+			return strings.HasPrefix(secret.Key, consolidatedSecretsLocation(schema).prefix)
+		}) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// DeleteInstalledResource deletes a resource that FindInstalledResources found, unless it's unverified. A resource that
+// no longer exists is not an error.
+func DeleteInstalledResource(ctx context.Context, client *databricks.WorkspaceClient, resource InstalledResource) error {
+	if resource.Unverified {
+		return fmt.Errorf("%s may not have been created by hldbx, verify it and delete it yourself", resource)
+	}
+	var err error
+	switch resource.Kind {
+	case ResourceJob, ResourceScanJob:
+		err = client.Jobs.DeleteByJobId(ctx, resource.JobId)
+	case ResourceNotebooks:
+		err = client.Workspace.Delete(ctx, workspace.Delete{Path: resource.Name, Recursive: true})
+	case ResourceManifest:
+		err = client.Workspace.Delete(ctx, workspace.Delete{Path: resource.Name})
+	case ResourceSecretsScope:
+		err = client.Secrets.DeleteScopeByScope(ctx, resource.Name)
+	default:
+		return fmt.Errorf("unknown resource kind %q", resource.Kind)
+	}
+	if err != nil && !errors.Is(err, databricks.ErrResourceDoesNotExist) && !errors.Is(err, databricks.ErrNotFound) {
+		return fmt.Errorf("unable to delete %s: %w", resource, err)
+	}
+	return nil
+}
+
+// RemoveWorkspaceDir deletes the workspace directory of the notebooks if nothing else is left in it, e.g. after
+// uninstalling. A directory with other files in it is left alone.
+func RemoveWorkspaceDir(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) {
+	dir := config.DbxWorkspaceDir
+	if dir == "" {
+		dir = defaultWorkspaceDir
+	}
+	objects, err := client.Workspace.ListAll(ctx, workspace.ListWorkspaceRequest{Path: dir})
+	if err == nil && len(objects) == 0 {
+		_ = client.Workspace.Delete(ctx, workspace.Delete{Path: dir})
+	}
+}
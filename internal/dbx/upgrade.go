@@ -0,0 +1,213 @@
+package dbx
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"path"
+	"slices"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/databricks/databricks-sdk-go/service/workspace"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// RepointedTask is a notebook task of an installed job that UpgradeInstallation moved to this hldbx version's notebooks.
+type RepointedTask struct {
+	JobName string `json:"job_name"`
+	JobId   int64  `json:"job_id"`
+	TaskKey string `json:"task_key"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+// Upgrade reports what UpgradeInstallation changed, or would change.
+type Upgrade struct {
+	NotebooksDir string          `json:"notebooks_dir"` // of this hldbx version
+	Repointed    []RepointedTask `json:"repointed"`
+	Removed      []string        `json:"removed"` // notebook directories of older versions
+	Kept         []string        `json:"kept"`    // older notebook directories kept by dbx_keep_notebook_versions
+	InUse        []string        `json:"in_use"`  // older notebook directories kept, since runs or other jobs use them
+}
+
+// jobTasksUpdate is the update of the notebook tasks of a job, and the tasks before it, to roll it back.
+type jobTasksUpdate struct {
+	jobId    int64
+	tasks    []jobs.Task
+	original []jobs.Task
+}
+
+// versionDir is the notebook directory of an hldbx version, and when its notebooks were last uploaded.
+type versionDir struct {
+	path       string
+	modifiedAt int64
+}
+
+// UpgradeInstallation moves the installed jobs to the notebooks of this hldbx version, in place, so they keep their
+// IDs, run history, and permissions. It uploads the notebooks, and points the notebook tasks of the monitoring, serving
+// guardrail, verification, and on-demand scan jobs at them; if a job can't be updated, the jobs updated before it are
+// rolled back, so they don't run notebooks of different versions. It then deletes the notebook directories of older versions, except
+// the latest dbx_keep_notebook_versions, including this one, and those that a job or an active run still uses. The
+// job parameters are left to ApplyConfig. If dryRun is set, nothing is changed.
+func UpgradeInstallation(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config, dryRun bool) (*Upgrade, error) {
+	baseDir := config.DbxWorkspaceDir
+	if baseDir == "" {
+		baseDir = defaultWorkspaceDir
+	}
+	upgrade := &Upgrade{NotebooksDir: getHLWorkspaceDirectory(config), Repointed: []RepointedTask{}, Removed: []string{},
+		Kept: []string{}, InUse: []string{}}
+	if !dryRun {
+		if err := uploadPythonFiles(client, config); err != nil {
+			return nil, err
+		}
+	}
+
+	var updates []jobTasksUpdate
+	for _, key := range []string{monitorJobKey, guardrailJobKey, verifyJobKey, onDemandJobKey} {
+		found, err := findJobs(ctx, client, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, job := range found {
+			update := jobTasksUpdate{jobId: job.JobId}
+			for _, task := range job.Settings.Tasks {
+				if task.NotebookTask == nil {
+					continue
+				}
+				from := task.NotebookTask.NotebookPath
+				// Only the notebooks of other hldbx versions, in the version directories of the workspace directory
+				if path.Dir(path.Dir(from)) != baseDir || path.Dir(from) == upgrade.NotebooksDir {
+					continue
+				}
+				to := fmt.Sprintf("%s/%s", upgrade.NotebooksDir, path.Base(from))
+				upgrade.Repointed = append(upgrade.Repointed, RepointedTask{JobName: job.Settings.Name, JobId: job.JobId,
+					TaskKey: task.TaskKey, From: from, To: to})
+				update.original = append(update.original, task)
+				notebookTask := *task.NotebookTask
+				notebookTask.NotebookPath = to
+				task.NotebookTask = &notebookTask
+				update.tasks = append(update.tasks, task)
+			}
+			if len(update.tasks) > 0 {
+				updates = append(updates, update)
+			}
+		}
+	}
+	if !dryRun {
+		if err := updateJobTasks(ctx, client, updates); err != nil {
+			return nil, err
+		}
+	}
+
+	inUse, err := notebookDirsInUse(ctx, client, upgrade)
+	if err != nil {
+		return nil, err
+	}
+	older, err := olderVersionDirs(ctx, client, baseDir, upgrade.NotebooksDir)
+	if err != nil {
+		return nil, err
+	}
+	for i, dir := range older {
+		switch {
+		case inUse[dir.path]:
+			upgrade.InUse = append(upgrade.InUse, dir.path)
+		case i < config.KeepNotebookVersions()-1:
+			upgrade.Kept = append(upgrade.Kept, dir.path)
+		default:
+			if !dryRun {
+				if err := client.Workspace.Delete(ctx, workspace.Delete{Path: dir.path, Recursive: true}); err != nil && !isNotFound(err) {
+					return nil, fmt.Errorf("unable to delete workspace directory %s: %w", dir.path, err)
+				}
+			}
+			upgrade.Removed = append(upgrade.Removed, dir.path)
+		}
+	}
+	return upgrade, nil
+}
+
+// updateJobTasks updates the notebook tasks of each job. If a job can't be updated, the jobs updated before it are
+// rolled back to their tasks before the update.
+func updateJobTasks(ctx context.Context, client *databricks.WorkspaceClient, updates []jobTasksUpdate) error {
+	for i, update := range updates {
+		// Tasks are merged by task key, so only the repointed ones are sent
+		err := client.Jobs.Update(ctx, jobs.UpdateJob{JobId: update.jobId, NewSettings: &jobs.JobSettings{Tasks: update.tasks}})
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("unable to update job %d: %w", update.jobId, err)
+		for _, done := range updates[:i] {
+			if rollbackErr := client.Jobs.Update(ctx, jobs.UpdateJob{JobId: done.jobId,
+				NewSettings: &jobs.JobSettings{Tasks: done.original}}); rollbackErr != nil {
+				return fmt.Errorf("%w; unable to roll back job %d: %v", err, done.jobId, rollbackErr)
+			}
+		}
+		return fmt.Errorf("%w; rolled back the %d job(s) updated before it", err, i)
+	}
+	return nil
+}
+
+// olderVersionDirs returns the notebook directories of the hldbx versions other than the current one in the workspace
+// directory, latest uploaded first.
+func olderVersionDirs(ctx context.Context, client *databricks.WorkspaceClient, baseDir string, currentDir string) ([]versionDir, error) {
+	objects, err := client.Workspace.ListAll(ctx, workspace.ListWorkspaceRequest{Path: baseDir})
+	if err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("unable to list workspace directory %s: %w", baseDir, err)
+	}
+	var dirs []versionDir
+	for _, object := range objects {
+		if object.ObjectType != workspace.ObjectTypeDirectory || object.Path == currentDir {
+			continue
+		}
+		// Only the directories of hldbx versions, which hold its notebooks
+		notebooks, err := client.Workspace.ListAll(ctx, workspace.ListWorkspaceRequest{Path: object.Path})
+		if err != nil {
+			return nil, fmt.Errorf("unable to list workspace directory %s: %w", object.Path, err)
+		}
+		if !slices.ContainsFunc(notebooks, func(o workspace.ObjectInfo) bool { return path.Base(o.Path) == modelMonitorNotebookName }) {
+			continue
+		}
+		dir := versionDir{path: object.Path}
+		for _, notebook := range notebooks {
+			dir.modifiedAt = max(dir.modifiedAt, notebook.ModifiedAt)
+		}
+		dirs = append(dirs, dir)
+	}
+	slices.SortFunc(dirs, func(a, b versionDir) int { return cmp.Compare(b.modifiedAt, a.modifiedAt) })
+	return dirs, nil
+}
+
+// notebookDirsInUse returns the directories of the notebooks that the installed jobs run, once repointed, and that
+// active runs, such as scan jobs started by the monitoring job before the upgrade, are running.
+func notebookDirsInUse(ctx context.Context, client *databricks.WorkspaceClient, upgrade *Upgrade) (map[string]bool, error) {
+	repointed := map[string]bool{}
+	for _, task := range upgrade.Repointed {
+		repointed[task.From] = true
+	}
+	inUse := map[string]bool{}
+	for _, key := range []string{monitorJobKey, guardrailJobKey, verifyJobKey, onDemandJobKey} {
+		found, err := findJobs(ctx, client, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, job := range found {
+			for _, task := range job.Settings.Tasks {
+				if task.NotebookTask != nil && !repointed[task.NotebookTask.NotebookPath] {
+					inUse[path.Dir(task.NotebookTask.NotebookPath)] = true
+				}
+			}
+		}
+	}
+	runs, err := client.Jobs.ListRunsAll(ctx, jobs.ListRunsRequest{ActiveOnly: true, ExpandTasks: true})
+	if err != nil {
+		return nil, fmt.Errorf("unable to list active job runs: %w", err)
+	}
+	for _, run := range runs {
+		for _, task := range run.Tasks {
+			if task.NotebookTask != nil {
+				inUse[path.Dir(task.NotebookTask.NotebookPath)] = true
+			}
+		}
+	}
+	return inUse, nil
+}
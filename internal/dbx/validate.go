@@ -1,39 +1,210 @@
 package dbx
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"strings"
+	"sync"
+
 	"github.com/databricks/databricks-sdk-go"
 	"github.com/databricks/databricks-sdk-go/service/compute"
-	"log"
-	"strings"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
 )
 
-// SchemaExists checks if the specified schema exists in the specified catalog in the Databricks Unity Catalog.
-// Log a fatal error and exit if the Databricks call fails in an unexpected way.
-func SchemaExists(dbxClient *databricks.WorkspaceClient, catalogName string, schemaName string) bool {
+// SchemaStatus is the outcome of looking up a schema in Unity Catalog.
+type SchemaStatus int
+
+const (
+	SchemaFound     SchemaStatus = iota // the schema exists and the caller can use it
+	SchemaMissing                       // the schema does not exist
+	SchemaForbidden                     // the caller lacks permission to use the schema, so it may or may not exist
+)
+
+func (s SchemaStatus) String() string {
+	switch s {
+	case SchemaFound:
+		return "found"
+	case SchemaMissing:
+		return "not found"
+	case SchemaForbidden:
+		return "permission denied"
+	default:
+		return fmt.Sprintf("SchemaStatus(%d)", int(s))
+	}
+}
+
+// CheckUnityCatalog returns true if a Unity Catalog metastore is assigned to the workspace.
+// Returns an error if that can't be determined.
+func CheckUnityCatalog(dbxClient *databricks.WorkspaceClient) (bool, error) {
+	_, err := dbxClient.Metastores.Current(context.Background())
+	if err != nil {
+		if errors.Is(err, databricks.ErrNotFound) || errors.Is(err, databricks.ErrResourceDoesNotExist) ||
+			strings.Contains(err.Error(), "METASTORE_DOES_NOT_EXIST") {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// CheckSchema checks whether the specified schema exists in the specified catalog in the Databricks Unity Catalog,
+// distinguishing a missing schema from one that the caller doesn't have permission to use.
+// Returns an error if the Databricks call fails in an unexpected way.
+func CheckSchema(ctx context.Context, dbxClient *databricks.WorkspaceClient, catalogName string, schemaName string) (SchemaStatus, error) {
 	schemaFullName := fmt.Sprintf("%s.%s", catalogName, schemaName)
-	_, err := dbxClient.Schemas.GetByFullName(context.Background(), schemaFullName)
+	_, err := dbxClient.Schemas.GetByFullName(ctx, schemaFullName)
 	if err != nil {
-		if strings.Contains(err.Error(), "does not exist") {
-			return false
-		} else {
-			log.Fatalf("Error fetching schema: %v", err)
+		// Check for permission errors first, their messages can mention resources that "do not exist"
+		if errors.Is(err, databricks.ErrPermissionDenied) {
+			return SchemaForbidden, nil
 		}
+		if errors.Is(err, databricks.ErrNotFound) || strings.Contains(err.Error(), "does not exist") {
+			return SchemaMissing, nil
+		}
+		return SchemaMissing, fmt.Errorf("unable to get schema %s: %w", schemaFullName, err)
 	}
-	return true
+	return SchemaFound, nil
+}
+
+// SchemaValidation is the outcome of checking that a configured schema exists in Unity Catalog.
+type SchemaValidation struct {
+	Schema utils.CatalogSchemaConfig
+	Status SchemaStatus
+	Err    error // the check failed, so Status is meaningless
 }
 
-// ClusterExists checks if the specified cluster exists in the Databricks workspace.
+// Maximum number of schemas that ValidateSchemas checks at once, to stay clear of Databricks API rate limits
+// when there are hundreds of them
+const schemaValidationConcurrency = 16
+
+// ValidateSchemas checks concurrently that each of the schemas exists in Unity Catalog.
+// The results are in the same order as the schemas. If onResult is not nil, it is called as each check finishes,
+// with the number of checks finished so far; calls to onResult are never concurrent.
+// Returns an error joining those of the checks that failed, which also have them in their results.
+func ValidateSchemas(ctx context.Context, dbxClient *databricks.WorkspaceClient, schemas []utils.CatalogSchemaConfig,
+	onResult func(done int, result SchemaValidation)) ([]SchemaValidation, error) {
+	results := make([]SchemaValidation, len(schemas))
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+	limit := make(chan struct{}, schemaValidationConcurrency)
+	for i, schema := range schemas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			result := SchemaValidation{Schema: schema}
+			result.Status, result.Err = CheckSchema(ctx, dbxClient, schema.Catalog, schema.Schema)
+			results[i] = result
+			if onResult != nil {
+				mu.Lock()
+				done++
+				onResult(done, result)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+	return results, errors.Join(errs...)
+}
+
+// ClusterStatus describes a cluster in the Databricks workspace, for checking that the monitoring job can run on it.
+type ClusterStatus struct {
+	Exists              bool
+	State               compute.State
+	DataSecurityMode    compute.DataSecurityMode
+	SingleUserName      string
+	UnityCatalogEnabled bool
+	Tags                map[string]string // default tags, and custom tags, which override them
+}
+
+// AccessMode returns a readable name for the cluster's access mode.
+func (c ClusterStatus) AccessMode() string {
+	switch c.DataSecurityMode {
+	case compute.DataSecurityModeSingleUser, compute.DataSecurityModeDataSecurityModeDedicated:
+		return "single user"
+	case compute.DataSecurityModeUserIsolation, compute.DataSecurityModeDataSecurityModeStandard:
+		return "shared"
+	case compute.DataSecurityModeNone, "":
+		return "no isolation"
+	case compute.DataSecurityModeDataSecurityModeAuto:
+		return "auto"
+	default:
+		return fmt.Sprintf("legacy (%s)", c.DataSecurityMode)
+	}
+}
+
+// Warnings returns the reasons, if any, that the monitoring job, which runs as runAs, may fail to attach to the
+// cluster or to access Unity Catalog from it.
+func (c ClusterStatus) Warnings(runAs string) []string {
+	var warnings []string
+	switch c.State {
+	case compute.StateRunning, compute.StatePending, compute.StateResizing, compute.StateRestarting:
+		// the job can attach
+	case compute.StateTerminated, compute.StateTerminating:
+		warnings = append(warnings, "the cluster is terminated; each job run will have to start it, which adds several minutes")
+	default:
+		warnings = append(warnings, fmt.Sprintf("the cluster is in state %s; the job may fail to attach to it", c.State))
+	}
+	if !c.UnityCatalogEnabled {
+		warnings = append(warnings, fmt.Sprintf("the %s access mode does not support Unity Catalog; the job will not be able to read models", c.AccessMode()))
+	}
+	if c.AccessMode() == "single user" && c.SingleUserName != "" && !strings.EqualFold(c.SingleUserName, runAs) {
+		warnings = append(warnings, fmt.Sprintf("the cluster is assigned to %s, but the job runs as %s; it must run as "+
+			"the cluster's user to attach to it", c.SingleUserName, cmp.Or(runAs, "another identity")))
+	}
+	return warnings
+}
+
+// JobsRunAs returns the identity that the installed jobs run as: the service principal of dbx_run_as, or else the
+// client's, which creates them.
+func JobsRunAs(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) (string, error) {
+	if config.DbxRunAs != "" {
+		return config.DbxRunAs, nil
+	}
+	me, err := client.CurrentUser.Me(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to get the current user: %w", err)
+	}
+	return me.UserName, nil
+}
+
+// CheckCluster checks if the specified cluster exists in the Databricks workspace, and if so returns its
+// state, access mode, Unity Catalog enablement, and tags.
 // Log a fatal error and exit if the Databricks call fails in an unexpected way.
-func ClusterExists(dbxClient *databricks.WorkspaceClient, clusterID string) bool {
-	_, err := dbxClient.Clusters.Get(context.Background(), compute.GetClusterRequest{ClusterId: clusterID})
+func CheckCluster(dbxClient *databricks.WorkspaceClient, clusterID string) ClusterStatus {
+	cluster, err := dbxClient.Clusters.Get(context.Background(), compute.GetClusterRequest{ClusterId: clusterID})
 	if err != nil {
 		if strings.Contains(err.Error(), "does not exist") {
-			return false
+			return ClusterStatus{}
 		} else {
-			log.Fatalf("Error fetching cluster: %v", err)
+			utils.Fatalf("Error fetching cluster: %v", err)
 		}
 	}
-	return true
+	status := ClusterStatus{
+		Exists:           true,
+		State:            cluster.State,
+		DataSecurityMode: cluster.DataSecurityMode,
+		SingleUserName:   cluster.SingleUserName,
+		Tags:             map[string]string{},
+	}
+	maps.Copy(status.Tags, cluster.DefaultTags)
+	maps.Copy(status.Tags, cluster.CustomTags)
+	switch cluster.DataSecurityMode {
+	case compute.DataSecurityModeSingleUser, compute.DataSecurityModeUserIsolation,
+		compute.DataSecurityModeDataSecurityModeDedicated, compute.DataSecurityModeDataSecurityModeStandard,
+		compute.DataSecurityModeDataSecurityModeAuto:
+		status.UnityCatalogEnabled = true
+	}
+	return status
 }
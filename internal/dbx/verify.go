@@ -0,0 +1,120 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Name of the notebook that verifies an installation on a schedule, from within the workspace. It runs the checks of
+// the validation notebook, and checks that the monitoring job is running and that scan coverage isn't degrading.
+const verifyNotebookName = "hl_verify_install"
+
+// Task key of the verification job
+const verifyTaskKey = "verify"
+
+// Schedule of the verification job when dbx_verify_quartz_cron isn't set: Mondays at 06:00 UTC
+const defaultVerifyQuartzCron = "0 0 6 ? * MON"
+
+// ValidateVerifyJob checks the settings of the scheduled verification job. Its failures must reach someone, so it
+// needs a notification channel.
+func ValidateVerifyJob(config *utils.Config) error {
+	if config.DbxVerifyQuartzCron != "" {
+		if err := ValidateQuartzCron(config.DbxVerifyQuartzCron); err != nil {
+			return fmt.Errorf("invalid dbx_verify_quartz_cron: %w", err)
+		}
+	}
+	for _, email := range config.DbxNotifyEmails {
+		if !strings.Contains(email, "@") || strings.TrimSpace(email) != email {
+			return fmt.Errorf("invalid dbx_notify_emails entry %q, expected an email address", email)
+		}
+	}
+	for _, destination := range config.DbxNotifyDestinations {
+		if strings.TrimSpace(destination) == "" {
+			return fmt.Errorf("dbx_notify_destinations has an empty entry, expected notification destination IDs")
+		}
+	}
+	if config.DbxVerifyJob && len(config.DbxNotifyEmails) == 0 && len(config.DbxNotifyDestinations) == 0 {
+		return fmt.Errorf("dbx_verify_job needs dbx_notify_emails or dbx_notify_destinations, to notify of failures")
+	}
+	return nil
+}
+
+// verifyQuartzCron returns the schedule of the verification job.
+func verifyQuartzCron(config *utils.Config) string {
+	if config.DbxVerifyQuartzCron != "" {
+		return config.DbxVerifyQuartzCron
+	}
+	return defaultVerifyQuartzCron
+}
+
+// heartbeatMaxAgeHours returns how old the monitoring job's latest heartbeat may be before the verification job fails,
+// the same allowance as the heartbeat diagnostics, or "" if the monitoring schedule has no regular interval.
+func heartbeatMaxAgeHours(config *utils.Config) string {
+	interval, err := scheduleInterval(config.DbxPollingQuartzCron)
+	if err != nil || interval <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat((time.Duration(config.HeartbeatMaxMissed()) * interval).Hours(), 'f', -1, 64)
+}
+
+// verifyJobSettings returns the settings of the verification job. It gets the monitoring job's parameters and runs on
+// its compute, as its identity, like the validation run of autoscan, and notifies the configured channels when it
+// fails.
+func verifyJobSettings(config *utils.Config) jobs.CreateJob {
+	monitorJob := monitorJobSettings(config)
+	params := append(slices.Clone(monitorJob.Parameters),
+		jobs.JobParameterDefinition{Name: "state_table", Default: config.StateTable()},
+		jobs.JobParameterDefinition{Name: "heartbeat_max_age_hours", Default: heartbeatMaxAgeHours(config)})
+	createJob := jobs.CreateJob{Name: jobName(config, verifyJobKey),
+		Description: jobDescription(config),
+		Tags:        jobTags(verifyJobKey),
+		Tasks: []jobs.Task{{
+			Description:       "Verify the HiddenLayer installation: secrets, endpoints, monitoring runs, and scan coverage",
+			ExistingClusterId: taskClusterId(config),
+			JobClusterKey:     taskJobClusterKey(config),
+			TaskKey:           verifyTaskKey,
+			TimeoutSeconds:    int(canaryTimeout.Seconds()),
+			NotebookTask: &jobs.NotebookTask{
+				NotebookPath: fmt.Sprintf("%s/%s", getHLWorkspaceDirectory(config), verifyNotebookName),
+			},
+		}},
+		JobClusters: jobClusters(config),
+		Parameters:  params,
+		Schedule: &jobs.CronSchedule{
+			QuartzCronExpression: verifyQuartzCron(config),
+			TimezoneId:           "UTC",
+		},
+		BudgetPolicyId: taskBudgetPolicyId(config),
+		RunAs:          monitorJob.RunAs,
+	}
+	if len(config.DbxNotifyEmails) > 0 {
+		createJob.EmailNotifications = &jobs.JobEmailNotifications{OnFailure: config.DbxNotifyEmails}
+	}
+	if len(config.DbxNotifyDestinations) > 0 {
+		var destinations []jobs.Webhook
+		for _, id := range config.DbxNotifyDestinations {
+			destinations = append(destinations, jobs.Webhook{Id: id})
+		}
+		createJob.WebhookNotifications = &jobs.WebhookNotifications{OnFailure: destinations}
+	}
+	return createJob
+}
+
+// setUpVerifyJob creates the verification job, or updates it if it exists. Return the job ID, or an error if the job
+// can't be created.
+func setUpVerifyJob(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) (int64, error) {
+	jobId, _, err := createOrResetJob(ctx, client, verifyJobSettings(config))
+	if err != nil {
+		return 0, fmt.Errorf("error creating verification job: %w", err)
+	}
+	fmt.Printf("Verification job ID: %d, scheduled %s\n", jobId, verifyQuartzCron(config))
+	return jobId, nil
+}
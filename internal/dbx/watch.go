@@ -0,0 +1,199 @@
+package dbx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/jobs"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Number of recent job runs to check on each poll, for the monitoring job and for all scan jobs
+const watchRecentRuns = 25
+
+// Kinds of watch events
+const (
+	WatchEventMonitorRun = "monitor_run" // a monitoring job run changed state
+	WatchEventScanRun    = "scan_run"    // a scan job run changed state
+	WatchEventScanResult = "scan_result" // a model version's scan status changed
+	WatchEventDetection  = "detection"   // a scan finished and found threats
+)
+
+// WatchEvent is a change in the scanning setup, seen since the previous poll.
+type WatchEvent struct {
+	Time        time.Time `json:"time"`
+	Kind        string    `json:"kind"`
+	Message     string    `json:"message"`
+	RunId       int64     `json:"run_id,omitempty"`
+	Model       string    `json:"model,omitempty"`
+	Version     int       `json:"version,omitempty"`
+	Status      string    `json:"status,omitempty"`
+	ThreatLevel string    `json:"threat_level,omitempty"`
+}
+
+// watcher remembers what it saw on the previous poll, so it can report what changed.
+type watcher struct {
+	client     *databricks.WorkspaceClient
+	config     func() *utils.Config
+	lastConfig *utils.Config // of the previous poll
+	runStates  map[int64]string
+	results    map[string]ScanResult
+}
+
+// Watch polls the monitoring job runs, the scan job runs, and the scan results of the monitored schemas
+// at the given interval, calling onEvent for everything that changes, until the context is canceled.
+// The first poll only records the current state, so onEvent sees only changes made while watching.
+// Each poll uses the configuration that config returns then, which may be a reloaded one. When it changes, the
+// scan results of the model versions that weren't watched before, e.g. of newly monitored schemas, are recorded
+// like on the first poll.
+func Watch(ctx context.Context, client *databricks.WorkspaceClient, config func() *utils.Config, interval time.Duration,
+	onEvent func(WatchEvent)) error {
+	w := &watcher{client: client, config: config, runStates: map[int64]string{}, results: map[string]ScanResult{}}
+	first := true
+	for {
+		events, err := w.poll(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if !first {
+			for _, event := range events {
+				onEvent(event)
+			}
+		}
+		first = false
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// poll returns the events since the previous poll.
+func (w *watcher) poll(ctx context.Context) ([]WatchEvent, error) {
+	var events []WatchEvent
+
+	monitorJobs, err := findMonitorJobs(ctx, w.client)
+	if err != nil {
+		return nil, err
+	}
+	for _, job := range monitorJobs {
+		runEvents, err := w.pollRuns(ctx, jobs.ListRunsRequest{JobId: job.JobId, Limit: watchRecentRuns}, WatchEventMonitorRun)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, runEvents...)
+	}
+	// Scan jobs are created on the fly by the monitor notebook, so look for them by name among all recent runs
+	runEvents, err := w.pollRuns(ctx, jobs.ListRunsRequest{Limit: watchRecentRuns}, WatchEventScanRun)
+	if err != nil {
+		return nil, err
+	}
+	events = append(events, runEvents...)
+
+	config := w.config()
+	// After a reload, the results of newly watched schemas are a baseline, like those of the first poll, rather
+	// than events; those of the schemas watched already are still reported
+	var addedSchemas map[string]bool
+	if w.lastConfig != nil && config != w.lastConfig {
+		addedSchemas = map[string]bool{}
+		for _, schema := range config.DbxSchemas {
+			addedSchemas[strings.ToLower(schema.Catalog+"."+schema.Schema)] = true
+		}
+		for _, schema := range w.lastConfig.DbxSchemas {
+			delete(addedSchemas, strings.ToLower(schema.Catalog+"."+schema.Schema))
+		}
+	}
+	w.lastConfig = config
+	results, err := ListScanResults(ctx, w.client, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := CacheVerdicts(results); err != nil {
+		slog.Warn("Unable to cache scan verdicts", "error", err)
+	}
+	for _, result := range results {
+		key := fmt.Sprintf("%s@%d", result.Model, result.Version)
+		previous, seen := w.results[key]
+		w.results[key] = result
+		schema := strings.ToLower(result.Model[:max(strings.LastIndex(result.Model, "."), 0)])
+		if !seen && addedSchemas[schema] || seen && previous.Status == result.Status && previous.ThreatLevel == result.ThreatLevel &&
+			previous.IsTriaged() == result.IsTriaged() {
+			continue
+		}
+		event := WatchEvent{
+			Time:        time.Now(),
+			Kind:        WatchEventScanResult,
+			Message:     fmt.Sprintf("Model %s version %d scan status is %s", result.Model, result.Version, result.Status),
+			Model:       result.Model,
+			Version:     result.Version,
+			Status:      result.Status,
+			ThreatLevel: result.ThreatLevel,
+		}
+		if result.IsDetection() && result.IsTriaged() {
+			// Triaged detections are reported once, as a status change, rather than alerted on again
+			event.Message = fmt.Sprintf("Model %s version %d has %s threat level detections, triaged", result.Model,
+				result.Version, result.ThreatLevel)
+		} else if result.IsDetection() {
+			event.Kind = WatchEventDetection
+			event.Message = fmt.Sprintf("Model %s version %d has %s threat level detections: %s",
+				result.Model, result.Version, result.ThreatLevel, result.ScanUrl)
+		} else if result.IsOutageBacklog() {
+			event.Message = fmt.Sprintf("Model %s version %d is waiting to be scanned, the HiddenLayer API is unreachable",
+				result.Model, result.Version)
+			if result.Quarantined {
+				event.Message += "; it is quarantined until then"
+			}
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// pollRuns returns an event for each of the listed runs whose state changed since the previous poll.
+// For scan job events, only the runs of scan jobs are considered.
+func (w *watcher) pollRuns(ctx context.Context, request jobs.ListRunsRequest, kind string) ([]WatchEvent, error) {
+	var events []WatchEvent
+	runs := w.client.Jobs.ListRuns(ctx, request)
+	for i := 0; i < watchRecentRuns && runs.HasNext(ctx); i++ {
+		run, err := runs.Next(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list job runs: %w", err)
+		}
+		if kind == WatchEventScanRun && !strings.HasPrefix(run.RunName, scanJobNamePrefix) {
+			continue
+		}
+		state := runState(run.State)
+		if w.runStates[run.RunId] == state {
+			continue
+		}
+		w.runStates[run.RunId] = state
+		events = append(events, WatchEvent{
+			Time:    time.Now(),
+			Kind:    kind,
+			Message: fmt.Sprintf("Run %d of %s is %s", run.RunId, run.RunName, state),
+			RunId:   run.RunId,
+			Status:  state,
+		})
+	}
+	return events, nil
+}
+
+// runState returns a readable summary of a job run's state, e.g. "RUNNING" or "TERMINATED SUCCESS".
+func runState(state *jobs.RunState) string {
+	if state == nil {
+		return "UNKNOWN"
+	}
+	if state.ResultState != "" {
+		return fmt.Sprintf("%s %s", state.LifeCycleState, state.ResultState)
+	}
+	return string(state.LifeCycleState)
+}
@@ -0,0 +1,162 @@
+package dbx
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/google/uuid"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Headers of the findings that are posted to a webhook sink. These must match hl_sinks.py.
+const (
+	webhookSignatureHeader = "X-HL-Signature" // v1=<hex HMAC-SHA256 of "<timestamp>.<body>" with the signing secret>
+	webhookTimestampHeader = "X-HL-Timestamp" // Unix time in seconds when the finding was signed
+	webhookEventIdHeader   = "X-HL-Event-Id"  // unique per finding, for receivers to drop duplicates
+)
+
+// Version of the webhook signature scheme, the prefix of the signature
+const webhookSignatureVersion = "v1"
+
+// Receivers should reject findings signed longer ago than this, to stop replays. VerifyWebhook checks that they do.
+const WebhookMaxSkew = 5 * time.Minute
+
+// How long to wait for the webhook to answer each request
+const webhookTimeout = 30 * time.Second
+
+// WebhookCheck is the outcome of one check of VerifyWebhook.
+type WebhookCheck struct {
+	Name    string `json:"name"`
+	Ok      bool   `json:"ok"`
+	Message string `json:"message"`
+}
+
+// SignWebhookPayload returns the signature of a webhook payload: the hex HMAC-SHA256 of "<timestamp>.<body>" with the
+// signing secret, prefixed by the scheme version. This must match sign_webhook_payload() in hl_sinks.py.
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return webhookSignatureVersion + "=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// testFinding returns the body of a test finding, an OCSF Detection Finding that receivers can tell from real ones
+// by its title and its test flag.
+func testFinding() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"class_uid":   2004,
+		"class_name":  "Detection Finding",
+		"activity_id": 1,
+		"time":        time.Now().UnixMilli(),
+		"severity_id": 1,
+		"severity":    "none",
+		"metadata": map[string]any{
+			"version": "1.1.0",
+			"product": map[string]string{"name": "HiddenLayer Model Scanner", "vendor_name": "HiddenLayer"},
+		},
+		"finding_info": map[string]any{
+			"uid":   uuid.NewString(),
+			"title": "Test finding from hldbx notify verify, not a detection",
+			"types": []string{"AI Model Security"},
+		},
+		"unmapped": map[string]bool{"test": true},
+	})
+}
+
+// postWebhook posts a finding to the webhook with the given timestamp and signature, and returns the response status.
+func postWebhook(ctx context.Context, httpClient *http.Client, webhookUrl string, body []byte, timestamp int64,
+	signature string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookUrl, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(webhookSignatureHeader, signature)
+	req.Header.Set(webhookEventIdHeader, uuid.NewString())
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// VerifyWebhook tests the signing of the findings posted to the webhook sink end to end. It reads the signing secret
+// that the scan notebook uses from the Databricks secrets of each schema, then posts test findings to the webhook:
+// one signed as the notebook signs them, which it must accept, and ones with a forged signature and with a stale
+// timestamp, which it must reject. Returns an error if the checks can't run.
+func VerifyWebhook(ctx context.Context, client *databricks.WorkspaceClient, config *utils.Config) ([]WebhookCheck, error) {
+	if !config.UsesWebhookFindingsSink() {
+		return nil, fmt.Errorf("dbx_findings_sink isn't a webhook, expected an https:// URL")
+	}
+	if len(config.DbxSchemas) == 0 {
+		return nil, fmt.Errorf("no schemas, add dbx_schemas to the configuration file")
+	}
+	var checks []WebhookCheck
+	secret := ""
+	for _, schema := range config.DbxSchemas {
+		name := fmt.Sprintf("secret %s.%s", schema.Catalog, schema.Schema)
+		value, scope, err := getSchemaSecret(ctx, client, schema, findingsSinkKeyName)
+		switch {
+		case err != nil:
+			checks = append(checks, WebhookCheck{name, false, fmt.Sprintf("%v; run hldbx autoscan to store it", err)})
+		case value != config.DbxFindingsSinkKey:
+			checks = append(checks, WebhookCheck{name, false, fmt.Sprintf("the signing secret in scope %s isn't "+
+				"dbx_findings_sink_key; run hldbx autoscan to store the current one", scope)})
+		default:
+			checks = append(checks, WebhookCheck{name, true, fmt.Sprintf("the signing secret is in scope %s", scope)})
+			secret = value
+		}
+	}
+	if secret == "" {
+		return checks, nil
+	}
+
+	body, err := testFinding()
+	if err != nil {
+		return checks, err
+	}
+	httpClient := &http.Client{Timeout: webhookTimeout}
+	now := time.Now().Unix()
+	stale := now - int64((2 * WebhookMaxSkew).Seconds())
+	posts := []struct {
+		name      string
+		accept    bool
+		timestamp int64
+		signature string
+	}{
+		{"signed finding", true, now, SignWebhookPayload(secret, now, body)},
+		{"forged signature", false, now, SignWebhookPayload(uuid.NewString(), now, body)},
+		{"stale timestamp", false, stale, SignWebhookPayload(secret, stale, body)},
+	}
+	for _, post := range posts {
+		status, err := postWebhook(ctx, httpClient, config.DbxFindingsSink, body, post.timestamp, post.signature)
+		accepted := status >= 200 && status < 300
+		switch {
+		case err != nil:
+			checks = append(checks, WebhookCheck{post.name, false, fmt.Sprintf("unable to post to the webhook: %v", err)})
+		case post.accept && !accepted:
+			checks = append(checks, WebhookCheck{post.name, false, fmt.Sprintf("the webhook rejected it with status %d", status)})
+		case post.accept:
+			checks = append(checks, WebhookCheck{post.name, true, fmt.Sprintf("accepted with status %d", status)})
+		case accepted:
+			checks = append(checks, WebhookCheck{post.name, false, fmt.Sprintf("the webhook accepted it with status %d; "+
+				"it must verify %s and %s", status, webhookSignatureHeader, webhookTimestampHeader)})
+		case status >= 500:
+			checks = append(checks, WebhookCheck{post.name, false, fmt.Sprintf("the webhook failed with status %d", status)})
+		default:
+			checks = append(checks, WebhookCheck{post.name, true, fmt.Sprintf("rejected with status %d", status)})
+		}
+	}
+	return checks, nil
+}
@@ -1,19 +1,42 @@
 package hl
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
 )
 
 // Auth authenticates with the HiddenLayer API and returns an access token.
-func Auth(authUrl string, apiId string, apiKey string) (string, error) {
+// The TLS configuration sets the minimum TLS version and certificate pins, see TLSConfig.
+func Auth(authUrl string, apiId string, apiKey string, tlsConfig *tls.Config) (string, error) {
+	accessToken, err := GetJwt(NewHttpClient(tlsConfig), authUrl, apiId, apiKey)
+	if err != nil {
+		return "", err
+	}
+	return accessToken, nil
+}
+
+// DialContext dials the connections of the HTTP clients for the HiddenLayer API, or is nil for the default dialer.
+// The sandbox sets it, so that its HiddenLayer API host is answered by the mock.
+var DialContext func(ctx context.Context, network string, addr string) (net.Conn, error)
+
+// NewHttpClient returns an HTTP client for the HiddenLayer API, with the TLS configuration, see TLSConfig.
+// It goes through the proxy of the HTTPS_PROXY and NO_PROXY environment variables, if any.
+func NewHttpClient(tlsConfig *tls.Config) *http.Client {
 	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+		DialContext:     DialContext,
 		// Set the maximum number of idle connections
 		MaxIdleConns: 10,
 		// Set the maximum number of idle connections per host
@@ -23,16 +46,22 @@ func Auth(authUrl string, apiId string, apiKey string) (string, error) {
 	}
 
 	// Create an HTTP client with the custom transport
-	httpClient := &http.Client{
-		Transport: transport,
+	return &http.Client{
+		Transport: userAgentTransport{utils.CorrelationTransport(transport)},
 		Timeout:   15 * time.Minute,
 	}
+}
 
-	accessToken, err := GetJwt(httpClient, authUrl, apiId, apiKey)
-	if err != nil {
-		return "", err
-	}
-	return accessToken, nil
+// userAgentTransport identifies hldbx, and the deployment of user_agent_suffix, in the requests to HiddenLayer.
+type userAgentTransport struct {
+	base http.RoundTripper
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", utils.UserAgent())
+	return t.base.RoundTrip(req)
 }
 
 // GetJwt authenticates with the HiddenLayer API and returns a JWT token.
@@ -76,11 +105,10 @@ func GetJwt(httpClient *http.Client, authUrl string, apiId string, apiKey string
 	return accessToken, nil
 }
 
-// CloseBody closes the io.ReadCloser. If there is an error, it logs the error and exits the program.
-// Should never happen.
+// CloseBody closes the io.ReadCloser. If there is an error, it logs it: the response has been read by then, so a
+// failure to close its body doesn't fail the request.
 func CloseBody(body io.ReadCloser) {
-	err := body.Close()
-	if err != nil {
-		log.Fatalf("Error closing response body: %v", err)
+	if err := body.Close(); err != nil {
+		slog.Warn("Unable to close the response body", "error", err)
 	}
 }
@@ -0,0 +1,278 @@
+package hl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Name of the model and of the file of the scans that PingScanner submits, so they can be told apart in the console
+const (
+	pingModelName = "hldbx-ping"
+	pingFileName  = "hldbx_ping.txt"
+)
+
+// pingFile is the content of the file that PingScanner scans: a few harmless bytes, which any scanner finishes
+// scanning in moments.
+var pingFile = []byte("hldbx scanner ping\n")
+
+// PingStep is a step of a scanner ping: whether it succeeded, and how long its requests took.
+type PingStep struct {
+	Name       string `json:"name"`
+	Ok         bool   `json:"ok"`
+	DurationMs int64  `json:"duration_ms"`
+	Message    string `json:"message"`
+}
+
+// PingResult is what a scanner ping found out about a scanner. Version is that of the scanner that scanned the
+// ping's model, if it finished within the wait, and otherwise that of the API, if it tells it in its responses.
+type PingResult struct {
+	ApiUrl  string     `json:"api_url"`
+	Version string     `json:"version,omitempty"`
+	ScanId  string     `json:"scan_id,omitempty"`
+	Status  string     `json:"status,omitempty"` // of the ping's scan, when the wait ended
+	Steps   []PingStep `json:"steps"`
+}
+
+// Ok returns true if every step of the ping succeeded.
+func (r *PingResult) Ok() bool {
+	for _, step := range r.Steps {
+		if !step.Ok {
+			return false
+		}
+	}
+	return true
+}
+
+// AddStep times a step of the ping and records its outcome. Returns false if it failed, and the ping should stop.
+func (r *PingResult) AddStep(name string, step func() (string, error)) bool {
+	start := time.Now()
+	message, err := step()
+	result := PingStep{Name: name, Ok: err == nil, DurationMs: time.Since(start).Milliseconds(), Message: message}
+	if err != nil {
+		result.Message = err.Error()
+	}
+	r.Steps = append(r.Steps, result)
+	return err == nil
+}
+
+// PingScanner checks a scanner end to end, the way the scan jobs use it: that its API answers, and that it accepts
+// a scan of a tiny file and finishes it, through the multi-file upload API that the HiddenLayer SDK uses. The access
+// token is sent as a bearer token, unless it's empty. It waits up to wait for the scan to finish, or doesn't if wait
+// is 0. The steps are added to the result, which can already hold, e.g., an authentication step.
+func PingScanner(httpClient *http.Client, result *PingResult, accessToken string, wait time.Duration) {
+	apiUrl := result.ApiUrl
+	if !result.AddStep("reach", func() (string, error) {
+		if _, err := probe(httpClient, apiUrl); err != nil {
+			return "", err
+		}
+		return "the API answered", nil
+	}) {
+		return
+	}
+
+	client := pingClient{httpClient: httpClient, apiUrl: apiUrl, accessToken: accessToken, result: result}
+	if !result.AddStep("submit", func() (string, error) {
+		scanId, err := client.submit()
+		result.ScanId = scanId
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("submitted scan %s of %s version 1", scanId, pingModelName), nil
+	}) {
+		return
+	}
+	if wait <= 0 {
+		return
+	}
+	result.AddStep("scan", func() (string, error) {
+		deadline := time.Now().Add(wait)
+		for {
+			report, err := getScanReport(httpClient, apiUrl, accessToken, result.ScanId)
+			if err != nil {
+				// The results of a scan may only be listed once it started
+				if time.Now().After(deadline) {
+					return "", err
+				}
+				time.Sleep(time.Second)
+				continue
+			}
+			result.Status = report.Status
+			if report.Version != "" {
+				result.Version = report.Version
+			}
+			switch report.Status {
+			case "done":
+				return "the scan finished", nil
+			case "failed", "canceled":
+				return "", fmt.Errorf("the scan %s", report.Status)
+			}
+			if time.Now().After(deadline) {
+				return "", fmt.Errorf("the scan didn't finish within %s, it's %s", wait, report.Status)
+			}
+			time.Sleep(time.Second)
+		}
+	})
+}
+
+// pingClient submits the scan of a scanner ping.
+type pingClient struct {
+	httpClient  *http.Client
+	apiUrl      string
+	accessToken string
+	result      *PingResult
+}
+
+// uploadPart is a part of a file to upload to a scan, and where to.
+type uploadPart struct {
+	PartNumber  int    `json:"part_number"`
+	StartOffset int    `json:"start_offset"`
+	EndOffset   int    `json:"end_offset"`
+	UploadUrl   string `json:"upload_url"`
+}
+
+// submit starts a scan, uploads the ping's file to it, and completes it. Returns the scan ID.
+func (c pingClient) submit() (string, error) {
+	var started struct {
+		ScanId string `json:"scan_id"`
+	}
+	err := c.call(http.MethodPost, []string{"scan/v3/upload"}, nil, map[string]string{
+		"model_name":        pingModelName,
+		"model_version":     "1",
+		"requesting_entity": "hldbx scanner ping",
+	}, &started)
+	if err != nil {
+		return "", fmt.Errorf("unable to start a scan: %w", err)
+	}
+	if started.ScanId == "" {
+		return "", errors.New("unable to start a scan: the response has no scan ID")
+	}
+
+	var file struct {
+		UploadId string       `json:"upload_id"`
+		Parts    []uploadPart `json:"parts"`
+	}
+	headers := map[string]string{"file-name": pingFileName, "file-content-length": strconv.Itoa(len(pingFile))}
+	if err := c.call(http.MethodPost, []string{"scan/v3/upload", started.ScanId, "file"}, headers, nil, &file); err != nil {
+		return started.ScanId, fmt.Errorf("unable to add a file to scan %s: %w", started.ScanId, err)
+	}
+	for _, part := range file.Parts {
+		if err := c.uploadPart(part); err != nil {
+			return started.ScanId, fmt.Errorf("unable to upload part %d of the file of scan %s: %w", part.PartNumber, started.ScanId, err)
+		}
+	}
+	if err := c.call(http.MethodPatch, []string{"scan/v3/upload", started.ScanId, "file", file.UploadId}, nil, nil, nil); err != nil {
+		return started.ScanId, fmt.Errorf("unable to complete the file of scan %s: %w", started.ScanId, err)
+	}
+	if err := c.call(http.MethodPatch, []string{"scan/v3/upload", started.ScanId}, nil, nil, nil); err != nil {
+		return started.ScanId, fmt.Errorf("unable to complete scan %s: %w", started.ScanId, err)
+	}
+	return started.ScanId, nil
+}
+
+// call sends a request to the path of the API, with the JSON body if not nil, and decodes the JSON response into
+// response if not nil. The API's version is recorded from the response headers, if it tells it.
+func (c pingClient) call(method string, path []string, headers map[string]string, body any, response any) error {
+	callUrl, err := url.JoinPath(c.apiUrl, path...)
+	if err != nil {
+		return err
+	}
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, callUrl, &reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if c.accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer CloseBody(resp.Body)
+	c.recordVersion(resp)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s %s: %s", method, callUrl, resp.Status)
+	}
+	if response == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return fmt.Errorf("unable to parse the response of %s %s: %w", method, callUrl, err)
+	}
+	return nil
+}
+
+// uploadPart uploads a part of the ping's file to its upload URL, which is relative to the API for an enterprise
+// scanner that stores uploads itself.
+func (c pingClient) uploadPart(part uploadPart) error {
+	if part.StartOffset < 0 || part.EndOffset > len(pingFile) || part.StartOffset > part.EndOffset {
+		return fmt.Errorf("invalid offsets %d-%d", part.StartOffset, part.EndOffset)
+	}
+	base, err := url.Parse(c.apiUrl)
+	if err != nil {
+		return err
+	}
+	uploadUrl, err := base.Parse(part.UploadUrl)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, uploadUrl.String(), bytes.NewReader(pingFile[part.StartOffset:part.EndOffset]))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	// Presigned URLs of another host carry their own authorization
+	if c.accessToken != "" && uploadUrl.Host == base.Host {
+		req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	}
+	// A presigned URL's query holds its signature, so it's left out of errors
+	target := uploadUrl.Scheme + "://" + uploadUrl.Host + uploadUrl.Path
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("PUT %s: %w", target, err)
+	}
+	defer CloseBody(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("PUT %s: %s", target, resp.Status)
+	}
+	return nil
+}
+
+// Response headers that scanners may tell their version in
+var versionHeaders = []string{"X-Api-Version", "X-Version", "X-HiddenLayer-Version"}
+
+// recordVersion records the API's version from the response headers, unless the scan's report told it already.
+func (c pingClient) recordVersion(resp *http.Response) {
+	if c.result.Version != "" {
+		return
+	}
+	for _, header := range versionHeaders {
+		if version := resp.Header.Get(header); version != "" {
+			c.result.Version = version
+			return
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package hl
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Region is a HiddenLayer SaaS region and its endpoints. Configurations name the region rather than its URLs
+// where they can, so that endpoint changes in later hldbx releases are picked up.
+type Region struct {
+	Name       string
+	ApiUrl     string
+	AuthUrl    string
+	ConsoleUrl string
+}
+
+// CustomRegion is the region name for endpoints given as URLs, such as an Enterprise Model Scanner.
+const CustomRegion = "custom"
+
+// Regions are the HiddenLayer SaaS regions. The first is the default.
+var Regions = []Region{
+	{Name: "us", ApiUrl: "https://api.us.hiddenlayer.ai", AuthUrl: "https://auth.hiddenlayer.ai", ConsoleUrl: "https://console.us.hiddenlayer.ai"},
+	{Name: "eu", ApiUrl: "https://api.eu.hiddenlayer.ai", AuthUrl: "https://auth.eu.hiddenlayer.ai", ConsoleUrl: "https://console.eu.hiddenlayer.ai"},
+}
+
+// Time allowed for a region to answer a probe
+const regionProbeTimeout = 5 * time.Second
+
+// LookupRegion returns the SaaS region with the name, ignoring case, and whether there is one.
+func LookupRegion(name string) (Region, bool) {
+	i := slices.IndexFunc(Regions, func(region Region) bool { return strings.EqualFold(region.Name, name) })
+	if i < 0 {
+		return Region{}, false
+	}
+	return Regions[i], true
+}
+
+// RegionProbe is how quickly a region's API answered, or why it didn't.
+type RegionProbe struct {
+	Region  Region
+	Latency time.Duration
+	Err     error
+}
+
+// ProbeRegions measures the latency of each region's API from this machine, in parallel.
+// Any HTTP response counts as available, since the probe isn't authenticated.
+func ProbeRegions(tlsConfig *tls.Config) []RegionProbe {
+	httpClient := NewHttpClient(tlsConfig)
+	probes := make([]RegionProbe, len(Regions))
+	var wg sync.WaitGroup
+	for i, region := range Regions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			latency, err := probe(httpClient, region.ApiUrl)
+			probes[i] = RegionProbe{Region: region, Latency: latency, Err: err}
+		}()
+	}
+	wg.Wait()
+	return probes
+}
+
+// Probe measures the latency of an API from this machine. Any HTTP response counts as reachable, since the probe
+// isn't authenticated.
+func Probe(apiUrl string, tlsConfig *tls.Config) (time.Duration, error) {
+	return probe(NewHttpClient(tlsConfig), apiUrl)
+}
+
+// probe sends a HEAD request to the URL, and returns how long the response took.
+func probe(httpClient *http.Client, apiUrl string) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), regionProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, apiUrl, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	CloseBody(resp.Body)
+	return time.Since(start), nil
+}
+
+// FastestRegion returns the available region with the lowest latency, and whether any region was available.
+func FastestRegion(probes []RegionProbe) (Region, bool) {
+	var fastest *RegionProbe
+	for i, probe := range probes {
+		if probe.Err == nil && (fastest == nil || probe.Latency < fastest.Latency) {
+			fastest = &probes[i]
+		}
+	}
+	if fastest == nil {
+		return Region{}, false
+	}
+	return fastest.Region, true
+}
@@ -0,0 +1,65 @@
+package hl
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Prefix of certificate pins, which are the base64-encoded SHA-256 hash of a certificate's public key,
+// in the same format as curl's --pinnedpubkey. This must match hl_api.py.
+const pinPrefix = "sha256//"
+
+// Minimum TLS versions that can be required for the HiddenLayer endpoints
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSConfig returns the TLS configuration for connecting to the HiddenLayer endpoints: the minimum TLS version,
+// "1.2" by default, and the certificate public key pins that the endpoint must match, if any.
+// Returns an error if the settings are invalid.
+func TLSConfig(minVersion string, pins []string) (*tls.Config, error) {
+	if minVersion == "" {
+		minVersion = "1.2"
+	}
+	version, ok := tlsVersions[minVersion]
+	if !ok {
+		return nil, fmt.Errorf("invalid minimum TLS version %q, expected 1.2 or 1.3", minVersion)
+	}
+	config := &tls.Config{MinVersion: version}
+	if len(pins) == 0 {
+		return config, nil
+	}
+
+	for _, pin := range pins {
+		hash, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, pinPrefix))
+		if !strings.HasPrefix(pin, pinPrefix) || err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("invalid certificate pin %q, expected %s<base64 SHA-256 hash of the public key>", pin, pinPrefix)
+		}
+	}
+	// The usual certificate verification still applies, the pins are checked on top of it
+	config.VerifyConnection = func(state tls.ConnectionState) error {
+		if len(state.PeerCertificates) == 0 {
+			return errors.New("the HiddenLayer endpoint presented no certificate")
+		}
+		pin := PublicKeyPin(state.PeerCertificates[0])
+		if !slices.Contains(pins, pin) {
+			return fmt.Errorf("the certificate of %s has public key pin %s, which isn't one of the configured pins",
+				state.ServerName, pin)
+		}
+		return nil
+	}
+	return config, nil
+}
+
+// PublicKeyPin returns the pin of a certificate's public key.
+func PublicKeyPin(certificate *x509.Certificate) string {
+	hash := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return pinPrefix + base64.StdEncoding.EncodeToString(hash[:])
+}
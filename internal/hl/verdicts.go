@@ -0,0 +1,230 @@
+package hl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// File in the profile's state directory that caches scan verdicts
+const verdictCacheFileName = "verdicts.json"
+
+// Verdict is the outcome of a HiddenLayer scan, as recorded in the scan tags of a model version.
+type Verdict struct {
+	ScanId         string `json:"scan_id"`
+	Status         string `json:"status"`
+	ThreatLevel    string `json:"threat_level,omitempty"`
+	UpdatedAt      string `json:"updated_at,omitempty"`
+	ScannerVersion string `json:"scanner_version,omitempty"`
+	ScanUrl        string `json:"scan_url,omitempty"` // HL console URL of the scan
+}
+
+// VerdictCache remembers the verdicts of scanned artifacts, keyed by artifact digest, so that the verdicts outlive
+// their tags, e.g. when a schema is recreated, and move with the installation, see utils.ExportInstall.
+type VerdictCache struct {
+	path     string
+	Verdicts map[string]Verdict `json:"verdicts"`
+}
+
+// LoadVerdictCache reads the selected profile's verdict cache, which is empty if it doesn't exist yet.
+func LoadVerdictCache() (*VerdictCache, error) {
+	stateDir, err := utils.StateDir()
+	if err != nil {
+		return nil, err
+	}
+	cache := &VerdictCache{path: filepath.Join(stateDir, verdictCacheFileName), Verdicts: map[string]Verdict{}}
+	data, err := os.ReadFile(cache.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read the verdict cache: %w", err)
+	}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, fmt.Errorf("unable to parse the verdict cache %s: %w", cache.path, err)
+	}
+	return cache, nil
+}
+
+// Put caches the verdict of the artifacts with the digest. Verdicts without a digest or scan ID are ignored.
+func (c *VerdictCache) Put(digest string, verdict Verdict) {
+	if digest == "" || verdict.ScanId == "" {
+		return
+	}
+	c.Verdicts[digest] = verdict
+}
+
+// Save writes the verdict cache.
+func (c *VerdictCache) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.path, data, 0600); err != nil {
+		return fmt.Errorf("unable to write the verdict cache: %w", err)
+	}
+	return nil
+}
+
+// scanReport is the part of a HiddenLayer scan report that makes up the verdict.
+type scanReport struct {
+	ScanId    string `json:"scan_id"`
+	Status    string `json:"status"`
+	Severity  string `json:"severity"`
+	EndTime   string `json:"end_time"`
+	Version   string `json:"version"`
+	Inventory struct {
+		ModelId      string `json:"model_id"` // only reported by the SaaS scanner
+		ModelName    string `json:"model_name"`
+		ModelVersion string `json:"model_version"`
+	} `json:"inventory"`
+	FileResults []struct {
+		Detections []struct {
+			RuleId string `json:"rule_id"`
+		} `json:"detections"`
+	} `json:"file_results"`
+}
+
+// verdict returns the verdict of the scan report.
+func (r scanReport) verdict() Verdict {
+	return Verdict{
+		ScanId:         r.ScanId,
+		Status:         r.Status,
+		ThreatLevel:    r.Severity,
+		UpdatedAt:      r.EndTime,
+		ScannerVersion: r.Version,
+	}
+}
+
+// rules returns the sorted IDs of the rules that the detections in the scan report matched.
+// This must match detected_rules() in hl_scan_model.py.
+func (r scanReport) rules() []string {
+	var rules []string
+	for _, file := range r.FileResults {
+		for _, detection := range file.Detections {
+			if detection.RuleId != "" {
+				rules = append(rules, detection.RuleId)
+			}
+		}
+	}
+	slices.Sort(rules)
+	return slices.Compact(rules)
+}
+
+// scanned returns true if the report is of a scan of the model version. With partial, as for a listed scan, which may
+// leave out the inventory, a report that doesn't name a model version is assumed to be of it.
+func (r scanReport) scanned(modelName string, modelVersion string, partial bool) bool {
+	if partial && r.Inventory.ModelName == "" && r.Inventory.ModelVersion == "" {
+		return true
+	}
+	return r.Inventory.ModelName == modelName && r.Inventory.ModelVersion == modelVersion
+}
+
+// getScanReport gets the report of a scan from the HiddenLayer API, by its scan ID.
+func getScanReport(httpClient *http.Client, apiUrl string, accessToken string, scanId string) (scanReport, error) {
+	resultUrl, err := url.JoinPath(apiUrl, "scan/v3/results", url.PathEscape(scanId))
+	if err != nil {
+		return scanReport{}, err
+	}
+	req, err := http.NewRequest(http.MethodGet, resultUrl, nil)
+	if err != nil {
+		return scanReport{}, err
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return scanReport{}, err
+	}
+	defer CloseBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return scanReport{}, fmt.Errorf("unable to get the results of scan %s from the HiddenLayer API: %s", scanId, resp.Status)
+	}
+	var report scanReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return scanReport{}, fmt.Errorf("unable to parse the results of scan %s: %w", scanId, err)
+	}
+	return report, nil
+}
+
+// FoundScan is a finished scan of a model version that FindLatestScan found in the HiddenLayer API.
+type FoundScan struct {
+	Verdict
+	ModelId string   // HiddenLayer model ID, for the console URL of the scan, if the scanner reports it
+	Rules   []string // IDs of the rules that the detections matched
+}
+
+// scanResultsPage is a page of the scan results that the HiddenLayer API lists.
+type scanResultsPage struct {
+	Items []scanReport `json:"items"`
+}
+
+// FindLatestScan finds the latest finished scan of a model version in the HiddenLayer API, by the model name and
+// version that the scan was submitted with, whichever integration submitted it. A scan is only returned if its report
+// names that model version, in case the API ignores the filters.
+// Returns false if the model version has no finished scan, or the API doesn't support listing scans by them, e.g. an
+// older enterprise scanner that rejects the method. An API that doesn't have the endpoint at all is an error, since a
+// wrong API URL would otherwise look like a model version that was never scanned.
+func FindLatestScan(httpClient *http.Client, apiUrl string, accessToken string, modelName string, modelVersion string) (FoundScan, bool, error) {
+	resultsUrl, err := url.JoinPath(apiUrl, "scan/v3/results")
+	if err != nil {
+		return FoundScan{}, false, err
+	}
+	query := url.Values{
+		"model_name":                    {modelName},
+		"model_version":                 {modelVersion},
+		"status":                        {"done"},
+		"latest_per_model_version_only": {"true"},
+		"limit":                         {"1"},
+	}
+	req, err := http.NewRequest(http.MethodGet, resultsUrl+"?"+query.Encode(), nil)
+	if err != nil {
+		return FoundScan{}, false, err
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return FoundScan{}, false, err
+	}
+	defer CloseBody(resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return FoundScan{}, false, nil
+	default:
+		return FoundScan{}, false, fmt.Errorf("unable to list the scans of model %s version %s from the HiddenLayer API: %s",
+			modelName, modelVersion, resp.Status)
+	}
+	var page scanResultsPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return FoundScan{}, false, fmt.Errorf("unable to parse the scans of model %s version %s: %w", modelName, modelVersion, err)
+	}
+	if len(page.Items) == 0 || page.Items[0].ScanId == "" || !page.Items[0].scanned(modelName, modelVersion, true) {
+		return FoundScan{}, false, nil
+	}
+	// Listed scans may leave out the detections, so get the full report
+	report, err := getScanReport(httpClient, apiUrl, accessToken, page.Items[0].ScanId)
+	if err != nil {
+		return FoundScan{}, false, err
+	}
+	if report.Status != "done" || !report.scanned(modelName, modelVersion, false) {
+		return FoundScan{}, false, nil
+	}
+	report.ScanId = page.Items[0].ScanId
+	return FoundScan{Verdict: report.verdict(), ModelId: report.Inventory.ModelId, Rules: report.rules()}, true, nil
+}
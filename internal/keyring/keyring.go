@@ -0,0 +1,94 @@
+// Package keyring stores the Databricks tokens entered at the prompt in the operating system's keyring: the macOS
+// Keychain, the Secret Service on Linux (through secret-tool), or the Windows Credential Manager, so that they
+// aren't asked for again, nor written to disk in plain text.
+package keyring
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Service that the tokens are stored under, each under the URL of its workspace as the account
+const service = "hldbx"
+
+var (
+	// ErrNotFound is returned when the keyring has no token for the workspace.
+	ErrNotFound = errors.New("not found in the keyring")
+	// ErrUnavailable is returned when the operating system has no keyring that hldbx can use.
+	ErrUnavailable = errors.New("no keyring available")
+)
+
+// Tokens are dropped this long before they expire, as the broker's tokens are refreshed, so they don't expire mid-command
+const expiryMargin = 5 * time.Minute
+
+// storedToken is what the keyring holds for a workspace.
+type storedToken struct {
+	AccessToken string    `json:"access_token"`
+	Expiry      time.Time `json:"expiry,omitempty"` // zero if unknown, e.g. for personal access tokens
+}
+
+// Available returns true if the operating system has a keyring that hldbx can use.
+func Available() bool {
+	return available()
+}
+
+// LoadDbxToken returns the token stored for the Databricks workspace at dbxHost. An expired token is deleted, and
+// ErrNotFound returned.
+func LoadDbxToken(dbxHost string) (string, error) {
+	secret, err := get(service, dbxHost)
+	if err != nil {
+		return "", err
+	}
+	var token storedToken
+	if err := json.Unmarshal([]byte(secret), &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("unable to parse the token stored for %s in the keyring", dbxHost)
+	}
+	if !token.Expiry.IsZero() && time.Until(token.Expiry) < expiryMargin {
+		_ = DeleteDbxToken(dbxHost)
+		return "", ErrNotFound
+	}
+	return token.AccessToken, nil
+}
+
+// StoreDbxToken stores the token for the Databricks workspace at dbxHost, replacing any stored before. Returns when
+// the token expires, or the zero time if it isn't known.
+func StoreDbxToken(dbxHost string, accessToken string) (time.Time, error) {
+	token := storedToken{AccessToken: accessToken, Expiry: jwtExpiry(accessToken)}
+	secret, err := json.Marshal(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return token.Expiry, set(service, dbxHost, string(secret))
+}
+
+// DeleteDbxToken deletes the token stored for the Databricks workspace at dbxHost, if there is one.
+func DeleteDbxToken(dbxHost string) error {
+	if err := remove(service, dbxHost); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// jwtExpiry returns the expiry of an OAuth access token, which Databricks issues as a JWT, or the zero time for
+// tokens that aren't JWTs, such as personal access tokens.
+func jwtExpiry(accessToken string) time.Time {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
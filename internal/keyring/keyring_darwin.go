@@ -0,0 +1,55 @@
+package keyring
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Exit status of the security tool when the keychain has no such item
+const securityNotFound = 44
+
+func available() bool {
+	_, err := exec.LookPath("security")
+	return err == nil
+}
+
+func get(service string, account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		return "", securityError(err)
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func set(service string, account string, secret string) error {
+	// Pass the secret on stdin in hex, rather than as an argument that other processes could see
+	command := exec.Command("security", "-i")
+	command.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s '%s' -a '%s' -X %s\n",
+		service, account, hex.EncodeToString([]byte(secret))))
+	if out, err := command.CombinedOutput(); err != nil {
+		return fmt.Errorf("unable to store in the keychain: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func remove(service string, account string) error {
+	if err := exec.Command("security", "delete-generic-password", "-s", service, "-a", account).Run(); err != nil {
+		return securityError(err)
+	}
+	return nil
+}
+
+// securityError returns ErrNotFound if the security tool didn't find the item, or the error otherwise.
+func securityError(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == securityNotFound {
+		return ErrNotFound
+	}
+	if errors.Is(err, exec.ErrNotFound) {
+		return ErrUnavailable
+	}
+	return fmt.Errorf("unable to use the keychain: %w", err)
+}
@@ -0,0 +1,57 @@
+package keyring
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secret-tool stores in the Secret Service, e.g. GNOME Keyring or KWallet, which needs a desktop session's D-Bus
+func available() bool {
+	_, err := exec.LookPath("secret-tool")
+	return err == nil
+}
+
+func get(service string, account string) (string, error) {
+	if !available() {
+		return "", ErrUnavailable
+	}
+	var stdout bytes.Buffer
+	command := exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	command.Stdout = &stdout
+	if err := command.Run(); err != nil || stdout.Len() == 0 {
+		// secret-tool exits with status 1 and no output when there is no such secret
+		var exitErr *exec.ExitError
+		if err == nil || (errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+			return "", ErrNotFound
+		}
+		return "", fmt.Errorf("unable to use the Secret Service: %w", err)
+	}
+	return strings.TrimSuffix(stdout.String(), "\n"), nil
+}
+
+func set(service string, account string, secret string) error {
+	if !available() {
+		return ErrUnavailable
+	}
+	// secret-tool reads the secret from stdin, rather than as an argument that other processes could see
+	command := exec.Command("secret-tool", "store", "--label", fmt.Sprintf("%s token for %s", service, account),
+		"service", service, "account", account)
+	command.Stdin = strings.NewReader(secret)
+	if out, err := command.CombinedOutput(); err != nil {
+		return fmt.Errorf("unable to store in the Secret Service: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func remove(service string, account string) error {
+	if !available() {
+		return ErrUnavailable
+	}
+	if err := exec.Command("secret-tool", "clear", "service", service, "account", account).Run(); err != nil {
+		return fmt.Errorf("unable to delete from the Secret Service: %w", err)
+	}
+	return nil
+}
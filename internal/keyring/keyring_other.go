@@ -0,0 +1,19 @@
+//go:build !darwin && !linux && !windows
+
+package keyring
+
+func available() bool {
+	return false
+}
+
+func get(service string, account string) (string, error) {
+	return "", ErrUnavailable
+}
+
+func set(service string, account string, secret string) error {
+	return ErrUnavailable
+}
+
+func remove(service string, account string) error {
+	return ErrUnavailable
+}
@@ -0,0 +1,240 @@
+// Package mock serves an in-process imitation of the Databricks REST APIs that hldbx uses,
+// for benchmarks and for running commands without a real workspace.
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go/service/catalog"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Page size of the mock list APIs, when the request doesn't give one
+const defaultPageSize = 100
+
+// Registry describes the Unity Catalog model registry that the mock server simulates.
+// Every schema has the same number of models, and every model the same number of versions.
+type Registry struct {
+	Schemas          []utils.CatalogSchemaConfig
+	ModelsPerSchema  int
+	VersionsPerModel int
+	Aliases          []string      // aliases given to the latest versions of each model, e.g. prod
+	Owners           []string      // owners of the models, in turn, e.g. the groups of the teams that train them
+	DetectionEvery   int           // every n-th version of a schema's models has a detection in its scan tags, 0 for none
+	Latency          time.Duration // added to every response, to simulate the network
+}
+
+// Server is a running mock Databricks server.
+type Server struct {
+	URL      string
+	registry Registry
+	server   *httptest.Server
+	requests atomic.Int64
+}
+
+// NewServer starts a mock Databricks server that simulates the registry.
+func NewServer(registry Registry) *Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/2.1/clusters/list", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"clusters": []any{}})
+	})
+	return newServer(registry, mux)
+}
+
+// newServer starts a mock server that simulates the registry, and serves the other handlers of the mux.
+func newServer(registry Registry, mux *http.ServeMux) *Server {
+	s := &Server{registry: registry}
+	mux.HandleFunc("GET /api/2.1/unity-catalog/models", s.listModels)
+	mux.HandleFunc("GET /api/2.1/unity-catalog/models/{name}", s.getModel)
+	mux.HandleFunc("GET /api/2.1/unity-catalog/models/{name}/versions", s.listVersions)
+	mux.HandleFunc("GET /api/2.0/mlflow/unity-catalog/model-versions/get", s.getVersionTags)
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		if registry.Latency > 0 {
+			time.Sleep(registry.Latency)
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	s.URL = s.server.URL
+	return s
+}
+
+// Requests returns the number of requests the server has handled.
+func (s *Server) Requests() int64 {
+	return s.requests.Load()
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// writeJSON writes a JSON response, or a Databricks-style error if the value can't be marshalled.
+func writeJSON(w http.ResponseWriter, value any) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "INTERNAL_ERROR", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
+
+// writeError writes an error response in the format of the Databricks APIs, which the SDK parses into its errors.
+func writeError(w http.ResponseWriter, status int, errorCode string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error_code": errorCode, "message": message})
+}
+
+// page returns the bounds of the requested page of a list of n items, and the token of the next page.
+func page(r *http.Request, n int) (int, int, string) {
+	start, _ := strconv.Atoi(r.URL.Query().Get("page_token"))
+	size, _ := strconv.Atoi(r.URL.Query().Get("max_results"))
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	start = min(max(start, 0), n)
+	end := min(start+size, n)
+	if end < n {
+		return start, end, strconv.Itoa(end)
+	}
+	return start, end, ""
+}
+
+// modelName returns the name of the i-th model of every schema.
+func modelName(i int) string {
+	return fmt.Sprintf("model_%05d", i)
+}
+
+// registrationTime returns when the n-th model version of a schema was registered: on one of the past 30 days,
+// mostly in the morning, UTC, like a team that trains models overnight.
+func registrationTime(n int) time.Time {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	return today.Add(-time.Duration(n%30+1)*24*time.Hour + 9*time.Hour + time.Duration(n*37%150)*time.Minute)
+}
+
+// findModel parses a model's full name into its schema and index, and returns whether the registry has it.
+func (s *Server) findModel(fullName string) (utils.CatalogSchemaConfig, int, bool) {
+	parts := strings.Split(fullName, ".")
+	if len(parts) != 3 {
+		return utils.CatalogSchemaConfig{}, 0, false
+	}
+	schema := utils.CatalogSchemaConfig{Catalog: parts[0], Schema: parts[1]}
+	var i int
+	if _, err := fmt.Sscanf(parts[2], "model_%d", &i); err != nil || modelName(i) != parts[2] {
+		return schema, 0, false
+	}
+	return schema, i, s.hasSchema(schema) && i < s.registry.ModelsPerSchema
+}
+
+// owner returns the owner of the i-th model of every schema, or "" if the registry has no owners.
+func (s *Server) owner(i int) string {
+	if len(s.registry.Owners) == 0 {
+		return ""
+	}
+	return s.registry.Owners[i%len(s.registry.Owners)]
+}
+
+// hasSchema returns whether the registry has the schema.
+func (s *Server) hasSchema(schema utils.CatalogSchemaConfig) bool {
+	return slices.Contains(s.registry.Schemas, schema)
+}
+
+func (s *Server) listModels(w http.ResponseWriter, r *http.Request) {
+	schemas := []utils.CatalogSchemaConfig{{Catalog: r.URL.Query().Get("catalog_name"), Schema: r.URL.Query().Get("schema_name")}}
+	if schemas[0] == (utils.CatalogSchemaConfig{}) {
+		// Without a catalog, the models of every schema of the metastore
+		schemas = s.registry.Schemas
+	} else if !s.hasSchema(schemas[0]) {
+		schemas = nil
+	}
+	start, end, next := page(r, len(schemas)*s.registry.ModelsPerSchema)
+	response := catalog.ListRegisteredModelsResponse{NextPageToken: next}
+	for n := start; n < end; n++ {
+		schema, i := schemas[n/s.registry.ModelsPerSchema], n%s.registry.ModelsPerSchema
+		response.RegisteredModels = append(response.RegisteredModels, catalog.RegisteredModelInfo{
+			CatalogName: schema.Catalog,
+			SchemaName:  schema.Schema,
+			Name:        modelName(i),
+			FullName:    fmt.Sprintf("%s.%s.%s", schema.Catalog, schema.Schema, modelName(i)),
+			Owner:       s.owner(i),
+		})
+	}
+	writeJSON(w, response)
+}
+
+func (s *Server) getModel(w http.ResponseWriter, r *http.Request) {
+	fullName := r.PathValue("name")
+	schema, i, ok := s.findModel(fullName)
+	if !ok {
+		writeError(w, http.StatusNotFound, "RESOURCE_DOES_NOT_EXIST", fmt.Sprintf("Registered model '%s' does not exist.", fullName))
+		return
+	}
+	model := catalog.RegisteredModelInfo{CatalogName: schema.Catalog, SchemaName: schema.Schema, FullName: fullName,
+		Name: fullName[strings.LastIndex(fullName, ".")+1:], Owner: s.owner(i)}
+	for _, alias := range s.registry.Aliases {
+		model.Aliases = append(model.Aliases, catalog.RegisteredModelAlias{AliasName: alias, VersionNum: s.registry.VersionsPerModel})
+	}
+	writeJSON(w, model)
+}
+
+func (s *Server) listVersions(w http.ResponseWriter, r *http.Request) {
+	fullName := r.PathValue("name")
+	schema, model, ok := s.findModel(fullName)
+	if !ok {
+		writeError(w, http.StatusNotFound, "RESOURCE_DOES_NOT_EXIST", fmt.Sprintf("Registered model '%s' does not exist.", fullName))
+		return
+	}
+	start, end, next := page(r, s.registry.VersionsPerModel)
+	response := catalog.ListModelVersionsResponse{NextPageToken: next}
+	for i := start; i < end; i++ {
+		response.ModelVersions = append(response.ModelVersions, catalog.ModelVersionInfo{
+			CatalogName: schema.Catalog,
+			SchemaName:  schema.Schema,
+			ModelName:   fullName[strings.LastIndex(fullName, ".")+1:],
+			Version:     i + 1,
+			Status:      catalog.ModelVersionInfoStatusReady,
+			CreatedAt:   registrationTime(model*s.registry.VersionsPerModel + i).UnixMilli(),
+		})
+	}
+	writeJSON(w, response)
+}
+
+func (s *Server) getVersionTags(w http.ResponseWriter, r *http.Request) {
+	fullName := r.URL.Query().Get("name")
+	version, _ := strconv.Atoi(r.URL.Query().Get("version"))
+	_, i, ok := s.findModel(fullName)
+	if !ok || version < 1 || version > s.registry.VersionsPerModel {
+		writeError(w, http.StatusNotFound, "RESOURCE_DOES_NOT_EXIST", fmt.Sprintf("Model version %s/%d does not exist.", fullName, version))
+		return
+	}
+	n := i*s.registry.VersionsPerModel + version
+	threatLevel := "none"
+	if s.registry.DetectionEvery > 0 && n%s.registry.DetectionEvery == 0 {
+		threatLevel = "high"
+	}
+	type tag struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+	writeJSON(w, map[string]any{"model_version": map[string]any{
+		"name":    fullName,
+		"version": strconv.Itoa(version),
+		"tags": []tag{
+			{Key: "hl_scan_status", Value: "done"},
+			{Key: "hl_scan_threat_level", Value: threatLevel},
+			{Key: "hl_scan_updated_at", Value: registrationTime(n).Add(40 * time.Minute).Format(time.RFC3339)},
+			{Key: "hl_scan_id", Value: fmt.Sprintf("00000000-0000-4000-8000-%012d", n)},
+			{Key: "hl_scan_artifact_digest", Value: fmt.Sprintf("sha256:%064x", n)},
+		},
+	}})
+}
@@ -0,0 +1,151 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// Responses recorded from the Databricks and HiddenLayer APIs, with identifiers and secrets replaced
+//
+//go:embed fixtures/*.json
+var fixtureFiles embed.FS
+
+// Fixture is a recorded API response. Times in responses are relative to now, so that they stay realistic:
+// "{{hours_ago_ms N}}" and "{{days_ago_ms N}}", including the quotes, are replaced with epoch milliseconds,
+// and {{hours_ago N}} and {{days_ago N}} within strings with RFC 3339 times. {{hl_api_url}} is replaced with the URL
+// of the sandbox's HiddenLayer API, see SandboxHlHost.
+type Fixture struct {
+	Request       string          `json:"request"`          // ServeMux pattern of the request, e.g. GET /api/2.1/clusters/get
+	Status        int             `json:"status,omitempty"` // defaults to 200
+	Response      json.RawMessage `json:"response"`
+	FirstPageOnly bool            `json:"first_page_only,omitempty"` // later pages of the list are empty
+}
+
+// Placeholders of relative times in fixture responses
+var (
+	epochMillisPlaceholder = regexp.MustCompile(`"\{\{(hours|days)_ago_ms (\d+)\}\}"`)
+	timePlaceholder        = regexp.MustCompile(`\{\{(hours|days)_ago (\d+)\}\}`)
+	hlApiUrlPlaceholder    = []byte("{{hl_api_url}}")
+)
+
+// SandboxRegistry is the model registry of the sandbox workspace.
+var SandboxRegistry = Registry{
+	Schemas:          []utils.CatalogSchemaConfig{{Catalog: "main", Schema: "ml_models"}, {Catalog: "main", Schema: "fraud"}},
+	ModelsPerSchema:  4,
+	VersionsPerModel: 3,
+	Aliases:          []string{"prod"},
+	Owners:           []string{"ml-platform", "fraud-analytics"},
+	DetectionEvery:   5,
+}
+
+// Identifiers of resources in the sandbox fixtures
+const (
+	SandboxClusterId        = "0101-120000-sandbox1"
+	SandboxServicePrincipal = "11111111-2222-3333-4444-555555555555"
+	SandboxToken            = "dapi-sandbox"
+)
+
+// Host of the sandbox's HiddenLayer API. It is a hiddenlayer.ai host, so that hldbx treats it as the SaaS API, which
+// needs credentials, but the server's DialContext dials it to the server itself.
+const SandboxHlHost = "api.sandbox.hiddenlayer.ai"
+
+// HlApiUrl returns the URL of the sandbox's HiddenLayer API, which the server answers.
+func (s *Server) HlApiUrl() string {
+	_, port, _ := net.SplitHostPort(s.server.Listener.Addr().String())
+	return sandboxHlApiUrl(port)
+}
+
+// DialContext dials the sandbox's HiddenLayer API host to the server, and other addresses as they are.
+func (s *Server) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	if host, _, err := net.SplitHostPort(addr); err == nil && host == SandboxHlHost {
+		addr = s.server.Listener.Addr().String()
+	}
+	return (&net.Dialer{}).DialContext(ctx, network, addr)
+}
+
+// sandboxHlApiUrl returns the URL of the sandbox's HiddenLayer API, on the port of the server.
+func sandboxHlApiUrl(port string) string {
+	return "http://" + net.JoinHostPort(SandboxHlHost, port)
+}
+
+// NewSandboxServer starts a mock server that answers the Databricks and HiddenLayer API calls of every hldbx
+// command with recorded fixtures, and simulates the sandbox registry, so commands can run without a workspace or
+// HiddenLayer account. Set hl.DialContext to the server's DialContext, so that it answers the HiddenLayer API.
+func NewSandboxServer() (*Server, error) {
+	mux := http.NewServeMux()
+	files, err := fs.Glob(fixtureFiles, "fixtures/*.json")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := fixtureFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		var fixtures []Fixture
+		if err := json.Unmarshal(data, &fixtures); err != nil {
+			return nil, fmt.Errorf("invalid sandbox fixtures in %s: %w", file, err)
+		}
+		for _, fixture := range fixtures {
+			mux.HandleFunc(fixture.Request, fixture.serve)
+		}
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "ENDPOINT_NOT_FOUND",
+			fmt.Sprintf("the hldbx sandbox has no fixture for %s %s", r.Method, r.URL.Path))
+	})
+	return newServer(SandboxRegistry, mux), nil
+}
+
+// serve writes the recorded response, with its relative times filled in.
+func (f Fixture) serve(w http.ResponseWriter, r *http.Request) {
+	if f.FirstPageOnly && (r.URL.Query().Get("page_token") != "" || startIndex(r) > 1) {
+		writeJSON(w, map[string]any{})
+		return
+	}
+	now := time.Now().UTC()
+	response := epochMillisPlaceholder.ReplaceAllFunc(f.Response, func(match []byte) []byte {
+		return []byte(strconv.FormatInt(relativeTime(now, epochMillisPlaceholder, match).UnixMilli(), 10))
+	})
+	response = timePlaceholder.ReplaceAllFunc(response, func(match []byte) []byte {
+		return []byte(relativeTime(now, timePlaceholder, match).Format(time.RFC3339))
+	})
+	_, port, _ := net.SplitHostPort(r.Host)
+	response = bytes.ReplaceAll(response, hlApiUrlPlaceholder, []byte(sandboxHlApiUrl(port)))
+	w.Header().Set("Content-Type", "application/json")
+	if f.Status != 0 {
+		w.WriteHeader(f.Status)
+	}
+	_, _ = w.Write(response)
+}
+
+// relativeTime returns the time that a placeholder stands for.
+func relativeTime(now time.Time, placeholder *regexp.Regexp, match []byte) time.Time {
+	groups := placeholder.FindSubmatch(match)
+	n, _ := strconv.Atoi(string(groups[2]))
+	unit := time.Hour
+	if string(groups[1]) == "days" {
+		unit = 24 * time.Hour
+	}
+	return now.Add(-time.Duration(n) * unit)
+}
+
+// startIndex returns the SCIM startIndex of a list request, which is 1 for the first page.
+func startIndex(r *http.Request) int {
+	index, err := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	if err != nil {
+		return 1
+	}
+	return index
+}
@@ -0,0 +1,74 @@
+package mock
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSandboxServer(t *testing.T) {
+	server, err := NewSandboxServer()
+	if err != nil {
+		t.Fatalf("NewSandboxServer() error: %v", err)
+	}
+	defer server.Close()
+
+	// Dial as the sandbox's HiddenLayer API clients do, so that the mock answers the HiddenLayer API host
+	client := &http.Client{Transport: &http.Transport{DialContext: server.DialContext}}
+	hlApiUrl := server.HlApiUrl()
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantStatus int
+		wantBody   []string
+	}{
+		{name: "monitoring job is scheduled", method: http.MethodGet, url: server.URL + "/api/2.2/jobs/list",
+			wantStatus: http.StatusOK, wantBody: []string{`"quartz_cron_expression":"0 0 */12 * * ?"`}},
+		{name: "monitoring job scans with the stub", method: http.MethodGet, url: server.URL + "/api/2.2/jobs/get?job_id=1",
+			wantStatus: http.StatusOK, wantBody: []string{`"default":"` + hlApiUrl + `"`}},
+		{name: "HiddenLayer authentication", method: http.MethodPost, url: hlApiUrl + "/oauth2/token",
+			wantStatus: http.StatusOK, wantBody: []string{`"access_token"`}},
+		{name: "HiddenLayer scan results", method: http.MethodGet, url: hlApiUrl + "/scan/v3/results?model_name=m&model_version=1",
+			wantStatus: http.StatusOK, wantBody: []string{`"items":[]`}},
+		{name: "no fixture", method: http.MethodGet, url: server.URL + "/api/2.0/unknown",
+			wantStatus: http.StatusNotFound, wantBody: []string{"ENDPOINT_NOT_FOUND"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, test.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("%s %s error: %v", test.method, test.url, err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != test.wantStatus {
+				t.Errorf("%s %s status = %d, want %d", test.method, test.url, resp.StatusCode, test.wantStatus)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Compact the body, so that the expected fragments don't depend on the fixture's formatting
+			var value any
+			if err := json.Unmarshal(body, &value); err != nil {
+				t.Fatalf("%s %s returned invalid JSON: %v", test.method, test.url, err)
+			}
+			compact, _ := json.Marshal(value)
+			for _, want := range test.wantBody {
+				if !strings.Contains(string(compact), want) {
+					t.Errorf("%s %s body = %s, want it to contain %s", test.method, test.url, compact, want)
+				}
+			}
+			if strings.Contains(string(compact), "{{") {
+				t.Errorf("%s %s body = %s, has a placeholder left", test.method, test.url, compact)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+// Package telemetry reports anonymous hldbx usage to HiddenLayer, if the user has opted in.
+// Only the command name, its outcome and duration, the hldbx version, the OS, a random installation ID, and the random
+// correlation ID of the invocation are sent, never workspace URLs, schema names, model names, or credentials.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hiddenlayer-engineering/hl-databricks/internal/utils"
+)
+
+// DefaultEndpoint is where usage events are sent, unless another endpoint is configured.
+const DefaultEndpoint = "https://telemetry.hiddenlayer.ai/v1/hldbx/events"
+
+// Environment variables that override the telemetry settings
+const (
+	DisableEnv  = "HLDBX_TELEMETRY"          // set to "off", "false" or "0" to disable telemetry
+	EndpointEnv = "HLDBX_TELEMETRY_ENDPOINT" // URL to send usage events to
+	doNotTrack  = "DO_NOT_TRACK"             // the cross-tool convention, set to "1" to disable telemetry
+)
+
+// Files in the hldbx home directory. Telemetry consent is per user, so it's shared by all profiles.
+const (
+	settingsFileName = "telemetry.json"
+	pendingFileName  = "telemetry-pending.json"
+)
+
+// Event outcomes
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+// Timeout for sending an event, so that telemetry never holds up a command for long
+const sendTimeout = 2 * time.Second
+
+// Settings are the user's telemetry choices.
+type Settings struct {
+	Enabled   bool   `json:"enabled"`
+	InstallId string `json:"install_id,omitempty"` // random, identifies the installation but not the user
+	Endpoint  string `json:"endpoint,omitempty"`   // defaults to DefaultEndpoint
+}
+
+// Event is the usage report of one command run.
+type Event struct {
+	InstallId  string `json:"install_id"`
+	Command    string `json:"command"`
+	Status     string `json:"status"`
+	DurationMs int64  `json:"duration_ms,omitempty"` // omitted for failures detected on the next run
+	Version    string `json:"version"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+	StartedAt  string `json:"started_at"`
+	// Random, matches the requests of the invocation in proxy and Databricks audit logs, see utils.CorrelationId
+	CorrelationId string `json:"correlation_id"`
+}
+
+// LoadSettings reads the telemetry settings. Telemetry is disabled until the user enables it.
+func LoadSettings() (Settings, error) {
+	var settings Settings
+	data, err := os.ReadFile(filepath.Join(utils.HomeDir(), settingsFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return settings, nil
+	} else if err != nil {
+		return settings, fmt.Errorf("unable to read the telemetry settings: %w", err)
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return settings, fmt.Errorf("unable to parse the telemetry settings: %w", err)
+	}
+	return settings, nil
+}
+
+// Save writes the telemetry settings, giving them an installation ID if they don't have one yet.
+func (s *Settings) Save() error {
+	if s.InstallId == "" {
+		s.InstallId = uuid.NewString()
+	}
+	return writeFile(settingsFileName, s)
+}
+
+// EffectiveEndpoint returns the endpoint that usage events are sent to.
+func (s Settings) EffectiveEndpoint() string {
+	if endpoint := os.Getenv(EndpointEnv); endpoint != "" {
+		return endpoint
+	}
+	if s.Endpoint != "" {
+		return s.Endpoint
+	}
+	return DefaultEndpoint
+}
+
+// DisabledByEnv returns the environment variable that disables telemetry, or "" if none does.
+func DisabledByEnv() string {
+	switch strings.ToLower(os.Getenv(DisableEnv)) {
+	case "off", "false", "0", "no":
+		return DisableEnv
+	}
+	if os.Getenv(doNotTrack) == "1" {
+		return doNotTrack
+	}
+	return ""
+}
+
+// Active returns true if usage events should be sent.
+func (s Settings) Active() bool {
+	return s.Enabled && DisabledByEnv() == ""
+}
+
+// Start records that a command has started. The event is kept in a pending file until Finish sends it,
+// so that a command that exits abruptly is reported as a failure by the next one.
+// Does nothing unless telemetry is active.
+func Start(command string) *Event {
+	settings, err := LoadSettings()
+	if err != nil || !settings.Active() {
+		return nil
+	}
+	// Report the previous command, if it never finished
+	var pending Event
+	if data, err := os.ReadFile(filepath.Join(utils.HomeDir(), pendingFileName)); err == nil && json.Unmarshal(data, &pending) == nil {
+		pending.Status = StatusFailure
+		send(settings.EffectiveEndpoint(), pending)
+	}
+	event := &Event{
+		InstallId:     settings.InstallId,
+		Command:       command,
+		Version:       utils.Version,
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		StartedAt:     time.Now().UTC().Format(time.RFC3339),
+		CorrelationId: utils.CorrelationId(),
+	}
+	_ = writeFile(pendingFileName, event)
+	return event
+}
+
+// Finish sends the event of a command that has finished. Does nothing if the event is nil.
+func (e *Event) Finish(status string) {
+	if e == nil {
+		return
+	}
+	_ = os.Remove(filepath.Join(utils.HomeDir(), pendingFileName))
+	settings, err := LoadSettings()
+	if err != nil || !settings.Active() {
+		return
+	}
+	e.Status = status
+	if started, err := time.Parse(time.RFC3339, e.StartedAt); err == nil {
+		e.DurationMs = time.Since(started).Milliseconds()
+	}
+	send(settings.EffectiveEndpoint(), *e)
+}
+
+// send posts the event to the endpoint. Telemetry is best effort, so errors are ignored.
+func send(endpoint string, event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if response, err := http.DefaultClient.Do(request); err == nil {
+		response.Body.Close()
+	}
+}
+
+// writeFile writes a value as JSON to a file in the hldbx home directory, readable only by its owner.
+func writeFile(name string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(utils.HomeDir(), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(utils.HomeDir(), name), data, 0600)
+}
@@ -1,36 +1,42 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
-	"log"
-	"net/url"
 	"os"
-	"runtime"
 	"strings"
 
-	"github.com/spf13/viper"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
 )
 
-type CatalogSchemaConfig struct {
-	Catalog string `mapstructure:"dbx_catalog" json:"catalog,omitempty"`
-	Schema  string `mapstructure:"dbx_schema" json:"schema,omitempty"`
-}
+// The configuration format is the public hlconfig package, so that other HiddenLayer tooling can share it
+type (
+	Config               = hlconfig.Config
+	CatalogSchemaConfig  = hlconfig.CatalogSchemaConfig
+	ScannerConfig        = hlconfig.ScannerConfig
+	ScannerAuth          = hlconfig.ScannerAuth
+	ArtifactSourceConfig = hlconfig.ArtifactSourceConfig
+)
 
-type Config struct {
-	DbxHost              string                `mapstructure:"dbx_host"`
-	DbxToken             string                `mapstructure:"dbx_token"`
-	DbxClusterId         string                `mapstructure:"dbx_cluster_id"`
-	DbxRunAs             string                `mapstructure:"dbx_run_as"`
-	DbxSchemas           []CatalogSchemaConfig `mapstructure:"dbx_schemas"`
-	DbxMaxActiveScanJobs string                `mapstructure:"dbx_max_active_scan_jobs"`
-	DbxPollingQuartzCron string                `mapstructure:"dbx_polling_quartz_cron"`
-	HlApiKeyName         string                `mapstructure:"hl_api_key_name"`
-	HlClientID           string                `mapstructure:"hl_client_id"`
-	HlClientSecret       string                `mapstructure:"hl_client_secret"`
-	HlApiUrl             string                `mapstructure:"hl_api_url"`
-	HlAuthUrl            string                `mapstructure:"hl_auth_url"`
-	HlConsoleUrl         string                `mapstructure:"hl_console_url"`
-}
+// Authentication modes of scanners
+const (
+	ScannerAuthClientCredentials = hlconfig.ScannerAuthClientCredentials
+	ScannerAuthNone              = hlconfig.ScannerAuthNone
+	ScannerAuthDatabricksToken   = hlconfig.ScannerAuthDatabricksToken
+)
+
+// Name of the scanner of the hl_api_url settings, which schemas use unless they select another
+const DefaultScannerName = hlconfig.DefaultScannerName
+
+// Bounds and default of the number of scan jobs that the monitoring job runs at once
+const (
+	MinMaxActiveScanJobs     = hlconfig.MinMaxActiveScanJobs
+	MaxMaxActiveScanJobs     = hlconfig.MaxMaxActiveScanJobs
+	DefaultMaxActiveScanJobs = hlconfig.DefaultMaxActiveScanJobs
+)
+
+// redactedValue replaces secret values when a configuration is displayed or exported
+const redactedValue = hlconfig.RedactedValue
 
 // ConfigNotFound is a custom error type for configuration not found errors
 type ConfigNotFound struct {
@@ -41,39 +47,81 @@ func (e *ConfigNotFound) Error() string {
 	return e.Message
 }
 
-// InitConfig reads in the configuration file and returns a Config object
+// InitConfig reads the selected profile's configuration file, with the settings that HLDBX_ environment variables
+// override, and returns a Config object. Without a configuration file, only the environment's settings are set.
 func InitConfig() (*Config, error) {
-	viper.SetConfigName("hldbx") // Config file name (without extension)
-	viper.SetConfigType("yaml")  // Config file format
-
-	// Determine the home directory based on the operating system
-	homeDir := os.Getenv("HOME")
-	if runtime.GOOS == "windows" {
-		homeDir = os.Getenv("USERPROFILE")
+	loader, err := NewConfigLoader()
+	if err != nil {
+		return nil, err
+	}
+	config, err := loader.Load()
+	if err != nil {
+		return nil, err
 	}
+	RegisterConfigSecrets(config)
 
-	// Look for the config file in the .hl directory under the home directory
-	viper.AddConfigPath(fmt.Sprintf("%s/.hl", homeDir))
+	return config, nil
+}
 
-	// Read and unmarshal the config file
-	if err := viper.ReadInConfig(); err != nil {
-		return nil, &ConfigNotFound{Message: "no config file found"}
+// NewConfigLoader returns a loader of the selected profile's configuration file, and of the profile of it selected
+// with --config-profile, with the settings that HLDBX_ environment variables override, as of now. Changing the
+// selection later, or the environment, doesn't change what it loads. Register the secrets of what it loads, see
+// RegisterConfigSecrets.
+func NewConfigLoader() (*hlconfig.Loader, error) {
+	configPath, err := ConfigFilePath()
+	if err != nil {
+		return nil, err
 	}
-	var config Config
-	if err := viper.Unmarshal(&config); err != nil {
-		return nil, fmt.Errorf("unable to decode into struct, %v", err)
+	configProfile, err := ConfigProfile()
+	if err != nil {
+		return nil, err
 	}
+	return &hlconfig.Loader{Path: configPath, Profile: configProfile, Env: hlconfig.Env(os.Environ())}, nil
+}
 
-	return &config, nil
+// ConfigSources returns the settings of the selected profile's configuration file, which are empty without one, with
+// those of the selected profile within it merged over them, and those of the HLDBX_ environment variables, which
+// InitConfig merges in that order.
+func ConfigSources() (file hlconfig.Source, env hlconfig.Source, err error) {
+	loader, err := NewConfigLoader()
+	if err != nil {
+		return nil, nil, err
+	}
+	return loader.Sources()
 }
 
-func (c *Config) UsesEnterpriseModelScanner() bool {
-	// determine if user is configuring for an enterprise scanner i.e. not a hiddenlayer.ai API url
-	hlApi, err := url.Parse(c.HlApiUrl)
+// ReadConfigFile returns the settings of the configuration file at path, profiles included, which are empty if
+// there is no such file.
+func ReadConfigFile(configPath string) (hlconfig.Source, error) {
+	in, err := os.Open(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return hlconfig.Source{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	file, err := hlconfig.ReadFile(in)
 	if err != nil {
-		log.Fatalf("Error parsing HiddenLayer API URL: %v", err)
+		return nil, fmt.Errorf("invalid configuration file %s: %w", configPath, err)
+	}
+	return file, nil
+}
+
+// RedactConfigSecrets replaces any secret values from the configuration that appear in the given text,
+// and anything else that Redact recognizes as a secret.
+func RedactConfigSecrets(c *Config, text string) string {
+	for _, secret := range c.SecretValues() {
+		if secret != "" {
+			text = strings.ReplaceAll(text, secret, redactedValue)
+		}
 	}
-	return !strings.HasSuffix(hlApi.Hostname(), ".hiddenlayer.ai")
+	return Redact(text)
+}
+
+// RegisterConfigSecrets registers the secret values of the configuration for redaction, see RegisterSecret.
+// Call it again whenever a secret value changes.
+func RegisterConfigSecrets(c *Config) {
+	RegisterSecret(c.SecretValues()...)
 }
 
 // For testing only. Requires switching the file to the main package.
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go/useragent"
+	"github.com/google/uuid"
+)
+
+// CorrelationIdHeader carries the correlation ID of the invocation in the requests to Databricks and HiddenLayer,
+// for proxies to log.
+const CorrelationIdHeader = "X-Correlation-ID"
+
+// Response header that APIs, including Databricks', tell the ID they gave a request in
+const requestIdHeader = "X-Request-Id"
+
+// Key of the user agent part that holds the correlation ID, which Databricks audit logs record with each request
+const userAgentInvocationKey = "invocation"
+
+// Random ID of this invocation of hldbx, see CorrelationId
+var correlationId = uuid.NewString()
+
+// CorrelationId returns the random ID of this invocation of hldbx. It is sent with every request to Databricks and
+// HiddenLayer and prefixes log messages, so a failure can be traced across proxy logs, the Databricks audit log,
+// and HiddenLayer support.
+func CorrelationId() string {
+	return correlationId
+}
+
+// ConfigureCorrelationId adds the correlation ID to the user agent of the requests to Databricks.
+func ConfigureCorrelationId() {
+	useragent.WithUserAgentExtra(userAgentInvocationKey, correlationId)
+}
+
+// CorrelationTransport adds the correlation ID header to the requests that base sends, or that
+// http.DefaultTransport sends if base is nil, and logs a summary of each at debug level.
+func CorrelationTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return correlationTransport{base}
+}
+
+type correlationTransport struct {
+	base http.RoundTripper
+}
+
+func (t correlationTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	req.Header.Set(CorrelationIdHeader, correlationId)
+	if !slog.Default().Enabled(req.Context(), slog.LevelDebug) {
+		return t.base.RoundTrip(req)
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	// The query is left out, since a presigned URL's holds its signature
+	attrs := []any{"method", req.Method, "url", req.URL.Scheme + "://" + req.URL.Host + req.URL.Path,
+		"duration", time.Since(start).Round(time.Millisecond)}
+	if err != nil {
+		slog.DebugContext(req.Context(), "HTTP request failed", append(attrs, "error", err)...)
+		return resp, err
+	}
+	attrs = append(attrs, "status", resp.StatusCode)
+	// The ID that the API gave the request, for its support to find it
+	if id := resp.Header.Get(requestIdHeader); id != "" {
+		attrs = append(attrs, "request_id", id)
+	}
+	slog.DebugContext(req.Context(), "HTTP request", attrs...)
+	return resp, err
+}
@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Names of the entries in an installation archive
+const (
+	installManifestName = "manifest.json"
+	installConfigName   = configFileName
+	installStatePrefix  = stateDirName + "/"
+)
+
+// Configuration keys that hold secrets, which are left out of installation archives
+var installSecretKeys = []string{"dbx_token", "hl_client_secret", "dbx_findings_sink_key"}
+
+// Configuration keys that only make sense in the workspace they were set for, which are dropped when an
+// installation is imported into another workspace, so that autoscan asks for them again
+var installWorkspaceKeys = []string{"dbx_cluster_id", "dbx_run_as"}
+
+// InstallManifest describes an installation archive.
+type InstallManifest struct {
+	Version    string    `json:"hldbx_version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Profile    string    `json:"profile,omitempty"`
+	DbxHost    string    `json:"dbx_host,omitempty"`
+	Files      []string  `json:"files"`
+}
+
+// ExportInstall writes the selected profile's installation, its configuration file and the files in its state
+// directory, to a tar archive. Secrets are left out of the configuration, so the archive is safe to store
+// alongside other disaster recovery material; autoscan asks for them again after an import.
+func ExportInstall(w io.Writer) (*InstallManifest, error) {
+	configPath, err := ConfigFilePath()
+	if err != nil {
+		return nil, err
+	}
+	config, err := ReadConfigMap(configPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range installSecretKeys {
+		delete(config, key)
+	}
+	configData, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal the configuration: %w", err)
+	}
+
+	manifest := &InstallManifest{Version: Version, ExportedAt: time.Now().UTC(), Profile: ProfileName()}
+	if host, ok := config["dbx_host"].(string); ok {
+		manifest.DbxHost = host
+	}
+	files := map[string][]byte{installConfigName: configData}
+	manifest.Files = append(manifest.Files, installConfigName)
+	stateDir, err := StateDir()
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.WalkDir(stateDir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relativePath, err := filepath.Rel(stateDir, filePath)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return err
+		}
+		name := installStatePrefix + filepath.ToSlash(relativePath)
+		files[name] = data
+		manifest.Files = append(manifest.Files, name)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the state directory: %w", err)
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	archive := tar.NewWriter(w)
+	if err := writeTarFile(archive, installManifestName, manifestData, manifest.ExportedAt); err != nil {
+		return nil, err
+	}
+	for _, name := range manifest.Files {
+		if err := writeTarFile(archive, name, files[name], manifest.ExportedAt); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("unable to write the installation archive: %w", err)
+	}
+	return manifest, nil
+}
+
+// ImportInstall restores an installation archive written by ExportInstall into the selected profile.
+// If dbxHost is given, and differs from the exported workspace, the configuration is pointed at that workspace
+// and its workspace-specific settings are dropped. An existing configuration is only replaced if force is set.
+func ImportInstall(r io.Reader, dbxHost string, force bool) (*InstallManifest, error) {
+	configPath, err := ConfigFilePath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(configPath); err == nil && !force {
+		return nil, fmt.Errorf("%s already exists, use another profile or --force to replace it", configPath)
+	}
+
+	var manifest *InstallManifest
+	files := map[string][]byte{}
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to read the installation archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(archive)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s from the installation archive: %w", header.Name, err)
+		}
+		if header.Name == installManifestName {
+			manifest = &InstallManifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, fmt.Errorf("invalid installation archive manifest: %w", err)
+			}
+			continue
+		}
+		files[header.Name] = data
+	}
+	if manifest == nil || files[installConfigName] == nil {
+		return nil, errors.New("not an hldbx installation archive, it has no manifest or configuration")
+	}
+
+	configData := files[installConfigName]
+	if dbxHost != "" && dbxHost != manifest.DbxHost {
+		config := map[string]any{}
+		if err := yaml.Unmarshal(configData, &config); err != nil {
+			return nil, fmt.Errorf("invalid configuration in the installation archive: %w", err)
+		}
+		config["dbx_host"] = dbxHost
+		for _, key := range installWorkspaceKeys {
+			delete(config, key)
+		}
+		if configData, err = yaml.Marshal(config); err != nil {
+			return nil, fmt.Errorf("unable to marshal the configuration: %w", err)
+		}
+	}
+
+	stateDir, err := StateDir()
+	if err != nil {
+		return nil, err
+	}
+	for name, data := range files {
+		if !strings.HasPrefix(name, installStatePrefix) {
+			continue
+		}
+		// Reject paths that would escape the state directory
+		relativePath := path.Clean(strings.TrimPrefix(name, installStatePrefix))
+		if !fs.ValidPath(relativePath) || relativePath == "." {
+			return nil, fmt.Errorf("invalid file %s in the installation archive", name)
+		}
+		filePath := filepath.Join(stateDir, filepath.FromSlash(relativePath))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0o700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filePath, data, 0o600); err != nil {
+			return nil, fmt.Errorf("unable to restore %s: %w", filePath, err)
+		}
+	}
+	if err := os.WriteFile(configPath, configData, 0o600); err != nil {
+		return nil, fmt.Errorf("unable to write %s: %w", configPath, err)
+	}
+	return manifest, nil
+}
+
+// ReadConfigMap reads a configuration file as generic YAML, keeping keys that Config doesn't know about.
+func ReadConfigMap(configPath string) (map[string]any, error) {
+	data, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, &ConfigNotFound{Message: fmt.Sprintf("no config file found at %s", configPath)}
+	} else if err != nil {
+		return nil, err
+	}
+	config := map[string]any{}
+	if err := yaml.Unmarshal(bytes.TrimSpace(data), &config); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", configPath, err)
+	}
+	return config, nil
+}
+
+// writeTarFile adds a file, readable only by its owner, to a tar archive.
+func writeTarFile(archive *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: modTime, Typeflag: tar.TypeReg}
+	if err := archive.WriteHeader(header); err != nil {
+		return fmt.Errorf("unable to write %s to the installation archive: %w", name, err)
+	}
+	if _, err := archive.Write(data); err != nil {
+		return fmt.Errorf("unable to write %s to the installation archive: %w", name, err)
+	}
+	return nil
+}
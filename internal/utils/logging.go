@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/databricks/databricks-sdk-go/logger"
+)
+
+// LevelTrace is the level of the Databricks SDK's dumps of its requests and responses, which repeat the request
+// summaries of the debug level at length. Support bundles include them.
+const LevelTrace = slog.LevelDebug - 4
+
+// Where leveled log messages are written, and the lowest level that is, see ConfigureLogging
+var logging struct {
+	sync.Mutex
+	output io.Writer
+	level  slog.Level
+}
+
+func init() {
+	// The public packages, which can't use Fatalf, still log through the log package
+	log.SetOutput(NewRedactingWriter(os.Stderr))
+	ConfigureLogging(os.Stderr, slog.LevelWarn)
+}
+
+// ConfigureLogging sets where the leveled log messages of slog are written, with secrets redacted, and the lowest
+// level that is written: warnings by default, info with --verbose, and debug with --debug, which adds the Databricks
+// and HiddenLayer API requests and where messages are logged from. By default, messages are written alone, as hldbx
+// printed them, with "Warning: " before warnings; with --verbose and --debug, they're written with their time, level,
+// and the correlation ID of the invocation. Fatal errors, see Fatalf, are written whatever the level.
+func ConfigureLogging(w io.Writer, level slog.Level) {
+	logging.Lock()
+	defer logging.Unlock()
+	logging.output, logging.level = w, level
+	var logger *slog.Logger
+	if level >= slog.LevelWarn {
+		logger = slog.New(plainHandler{w: NewRedactingWriter(w), level: level})
+	} else {
+		handler := slog.NewTextHandler(NewRedactingWriter(w), &slog.HandlerOptions{
+			Level:       level,
+			AddSource:   level <= slog.LevelDebug,
+			ReplaceAttr: replaceLogAttr,
+		})
+		logger = slog.New(handler).With("correlation_id", correlationId)
+	}
+	// slog.SetDefault routes the log package through the handler, where the fatal errors of the public packages
+	// would be hidden below the level, so the log package keeps writing where it did
+	writer, flags := log.Writer(), log.Flags()
+	slog.SetDefault(logger)
+	log.SetOutput(writer)
+	log.SetFlags(flags)
+}
+
+// Fatalf logs a fatal error, formatted as fmt.Sprintf does, and exits with status 1.
+func Fatalf(format string, v ...any) {
+	slog.Error(fmt.Sprintf(format, v...))
+	os.Exit(1)
+}
+
+// Fatal logs a fatal error, formatted as fmt.Sprint does, and exits with status 1.
+func Fatal(v ...any) {
+	slog.Error(fmt.Sprint(v...))
+	os.Exit(1)
+}
+
+// plainHandler writes the message of each record alone, followed by its attributes, with "Warning: " before those
+// of warnings.
+type plainHandler struct {
+	w     io.Writer
+	level slog.Level
+}
+
+func (h plainHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h plainHandler) Handle(_ context.Context, record slog.Record) error {
+	var b strings.Builder
+	if record.Level >= slog.LevelWarn && record.Level < slog.LevelError {
+		b.WriteString("Warning: ")
+	}
+	b.WriteString(record.Message)
+	separator := ": "
+	record.Attrs(func(attr slog.Attr) bool {
+		fmt.Fprintf(&b, "%s%s=%v", separator, attr.Key, attr.Value.Resolve())
+		separator = ", "
+		return true
+	})
+	b.WriteString("\n")
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+// WithAttrs leaves out the attributes that apply to every record, such as the correlation ID.
+func (h plainHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+func (h plainHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// Logging returns where leveled log messages are written, and the lowest level that is, to restore them after
+// changing them with ConfigureLogging.
+func Logging() (io.Writer, slog.Level) {
+	logging.Lock()
+	defer logging.Unlock()
+	return logging.output, logging.level
+}
+
+// replaceLogAttr names LevelTrace, which slog would call DEBUG-4, and shortens sources to the file name and line,
+// as the log package's are, leaving out empty ones.
+func replaceLogAttr(_ []string, attr slog.Attr) slog.Attr {
+	switch {
+	case attr.Key == slog.LevelKey && attr.Value.Any() == LevelTrace:
+		attr.Value = slog.StringValue("TRACE")
+	case attr.Key == slog.SourceKey:
+		source, ok := attr.Value.Any().(*slog.Source)
+		if ok && source.File == "" {
+			// Records without a program counter, such as the Databricks SDK's, have no source
+			return slog.Attr{}
+		} else if ok {
+			attr.Value = slog.StringValue(fmt.Sprintf("%s:%d", filepath.Base(source.File), source.Line))
+		}
+	}
+	return attr
+}
+
+// DatabricksLogger logs the messages of the Databricks SDK through slog, at the same levels, except that its debug
+// messages, which dump requests and responses, are logged at LevelTrace.
+type DatabricksLogger struct{}
+
+func (DatabricksLogger) Enabled(ctx context.Context, level logger.Level) bool {
+	return slog.Default().Enabled(ctx, databricksLogLevel(level))
+}
+
+func (l DatabricksLogger) Tracef(ctx context.Context, format string, v ...any) {
+	l.log(ctx, logger.LevelTrace, format, v...)
+}
+
+func (l DatabricksLogger) Debugf(ctx context.Context, format string, v ...any) {
+	l.log(ctx, logger.LevelDebug, format, v...)
+}
+
+func (l DatabricksLogger) Infof(ctx context.Context, format string, v ...any) {
+	l.log(ctx, logger.LevelInfo, format, v...)
+}
+
+func (l DatabricksLogger) Warnf(ctx context.Context, format string, v ...any) {
+	l.log(ctx, logger.LevelWarn, format, v...)
+}
+
+func (l DatabricksLogger) Errorf(ctx context.Context, format string, v ...any) {
+	l.log(ctx, logger.LevelError, format, v...)
+}
+
+func (DatabricksLogger) log(ctx context.Context, level logger.Level, format string, v ...any) {
+	handler := slog.Default().Handler()
+	if !handler.Enabled(ctx, databricksLogLevel(level)) {
+		return
+	}
+	// Without a program counter, the record has no source, which would otherwise be this function rather than the
+	// SDK's
+	record := slog.NewRecord(time.Now(), databricksLogLevel(level), fmt.Sprintf(format, v...), 0)
+	record.AddAttrs(slog.String("logger", "databricks-sdk"))
+	_ = handler.Handle(ctx, record)
+}
+
+// databricksLogLevel returns the slog level of a Databricks SDK level, whose levels are numbered as slog's.
+func databricksLogLevel(level logger.Level) slog.Level {
+	if level <= logger.LevelDebug {
+		return LevelTrace
+	}
+	return slog.Level(level)
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Environment variables that separate the files of operators, or tenants, sharing a machine
+const (
+	HomeEnv    = "HLDBX_HOME"    // directory holding all hldbx files, instead of ~/.hl
+	ProfileEnv = "HLDBX_PROFILE" // name of a profile, whose files are kept in their own subdirectory
+	ConfigEnv  = "HLDBX_CONFIG"  // path of the configuration file, instead of the profile's hldbx.yaml
+	// Name of a profile within the configuration file, whose settings replace those at its top, e.g. of a deployment
+	ConfigProfileEnv = "HLDBX_CONFIG_PROFILE"
+)
+
+// configFileFlag is the path of the configuration file given with --config, see SetConfigFile
+var configFileFlag string
+
+// configProfileFlag is the name of the profile of the configuration file given with --config-profile, see
+// SetConfigProfile
+var configProfileFlag string
+
+// Files and subdirectories within a profile directory
+const (
+	configFileName     = "hldbx.yaml"
+	stateDirName       = "state"
+	logsDirName        = "logs"
+	tokenCacheFileName = "token-cache.json"
+	profilesDirName    = "profiles"
+)
+
+// HomeDir returns the directory holding all hldbx files: $HLDBX_HOME if set, otherwise ~/.hl.
+func HomeDir() string {
+	if home := os.Getenv(HomeEnv); home != "" {
+		return home
+	}
+	// Determine the home directory based on the operating system
+	homeDir := os.Getenv("HOME")
+	if runtime.GOOS == "windows" {
+		homeDir = os.Getenv("USERPROFILE")
+	}
+	return filepath.Join(homeDir, ".hl")
+}
+
+// ProfileName returns the name of the selected profile, or "" for the default profile.
+func ProfileName() string {
+	return os.Getenv(ProfileEnv)
+}
+
+// ProfileDir returns the directory holding the config, state, logs, and token cache of the selected profile.
+// The default profile uses the home directory itself, so existing ~/.hl/hldbx.yaml files keep working,
+// and each named profile uses <home>/profiles/<name>.
+func ProfileDir() (string, error) {
+	name := ProfileName()
+	if name == "" {
+		return HomeDir(), nil
+	}
+	if !validProfileName(name) {
+		return "", fmt.Errorf("invalid profile name %q in %s", name, ProfileEnv)
+	}
+	return filepath.Join(HomeDir(), profilesDirName, name), nil
+}
+
+// validProfileName returns true if a profile name can name a directory.
+func validProfileName(name string) bool {
+	return name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+// SetConfigProfile selects the profile of the configuration file given with --config-profile, instead of
+// $HLDBX_CONFIG_PROFILE.
+func SetConfigProfile(name string) {
+	configProfileFlag = name
+}
+
+// ConfigProfile returns the name of the selected profile of the configuration file: that given with
+// --config-profile, otherwise $HLDBX_CONFIG_PROFILE, or "" for the settings at the top of the file. Names are lower
+// case, like the keys of the file.
+func ConfigProfile() (string, error) {
+	name := configProfileFlag
+	if name == "" {
+		name = os.Getenv(ConfigProfileEnv)
+	}
+	if name != "" && !validProfileName(name) {
+		return "", fmt.Errorf("invalid profile name %q", name)
+	}
+	return strings.ToLower(name), nil
+}
+
+// SetConfigFile makes hldbx use the configuration file at path, given with --config, instead of $HLDBX_CONFIG or
+// the selected profile's hldbx.yaml.
+func SetConfigFile(path string) {
+	configFileFlag = path
+}
+
+// ConfigFilePath returns the path of the configuration file: that given with --config, otherwise $HLDBX_CONFIG if
+// set, otherwise the selected profile's hldbx.yaml. The profile's other files stay in its directory either way, so
+// several configuration files, e.g. one per workspace, can share a token cache.
+func ConfigFilePath() (string, error) {
+	path := configFileFlag
+	if path == "" {
+		path = os.Getenv(ConfigEnv)
+	}
+	if path != "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return "", fmt.Errorf("invalid configuration file path %q: %w", path, err)
+		}
+		return abs, nil
+	}
+	dir, err := ProfileDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, configFileName), nil
+}
+
+// TokenCachePath returns the path of the selected profile's Databricks token cache.
+func TokenCachePath() (string, error) {
+	dir, err := ProfileDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, tokenCacheFileName), nil
+}
+
+// StateDir returns the selected profile's directory for files that hldbx generates, creating it if needed. Each
+// profile of the configuration file has a subdirectory of its own, since it's another deployment.
+func StateDir() (string, error) {
+	configProfile, err := ConfigProfile()
+	if err != nil {
+		return "", err
+	}
+	if configProfile != "" {
+		return profileSubdir(filepath.Join(stateDirName, configProfile))
+	}
+	return profileSubdir(stateDirName)
+}
+
+// LogsDir returns the selected profile's directory for logs and diagnostics, creating it if needed.
+func LogsDir() (string, error) {
+	return profileSubdir(logsDirName)
+}
+
+// profileSubdir returns a subdirectory of the selected profile's directory, creating it if needed.
+// Only the owner can access it, since other operators may share the machine.
+func profileSubdir(name string) (string, error) {
+	dir, err := ProfileDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, name)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("unable to create directory %s: %w", dir, err)
+	}
+	return dir, nil
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Shortest secret value that is redacted, so that short values don't garble unrelated output
+const minSecretLength = 6
+
+// Secret values known to this process, such as the Databricks token and HiddenLayer client secret,
+// which are redacted wherever they appear
+var secrets struct {
+	sync.RWMutex
+	values []string
+}
+
+// Patterns of secrets that aren't known in advance, e.g. tokens in HTTP traces and in error messages that
+// embed request or response bodies, with their replacements
+var secretPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	// Databricks personal access tokens
+	{regexp.MustCompile(`\bdapi[0-9a-f]{32}(-\d+)?\b`), redactedValue},
+	// JWTs, such as OAuth access tokens
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), redactedValue},
+	// Authorization headers
+	{regexp.MustCompile(`(?i)(authorization:\s*(?:bearer|basic)\s+)\S+`), "${1}" + redactedValue},
+	// JSON fields, e.g. in secret and token request and response bodies
+	{regexp.MustCompile(`("(?:access_token|refresh_token|id_token|client_secret|device_code|string_value|bytes_value|token_value|password)"\s*:\s*")[^"]*"`),
+		"${1}" + redactedValue + `"`},
+	// Passwords in URLs, e.g. of an HTTPS_PROXY that authenticates, which connection errors print
+	{regexp.MustCompile(`(\b[A-Za-z][A-Za-z0-9+.-]*://[^:/@\s]+:)[^@/\s]+@`), "${1}" + redactedValue + "@"},
+	// Form fields, e.g. in OAuth token requests
+	{regexp.MustCompile(`(\b(?:access_token|refresh_token|client_secret|device_code|password)=)[^&\s"]+`), "${1}" + redactedValue},
+}
+
+// RegisterSecret adds secret values to be redacted from everything that is printed or logged from now on.
+func RegisterSecret(values ...string) {
+	secrets.Lock()
+	defer secrets.Unlock()
+	for _, value := range values {
+		if len(value) >= minSecretLength && !strings.Contains(value, redactedValue) {
+			secrets.values = append(secrets.values, value)
+		}
+	}
+}
+
+// Redact replaces the registered secret values, and anything that looks like a token or secret, in the text.
+func Redact(text string) string {
+	secrets.RLock()
+	for _, secret := range secrets.values {
+		text = strings.ReplaceAll(text, secret, redactedValue)
+	}
+	secrets.RUnlock()
+	for _, secret := range secretPatterns {
+		text = secret.pattern.ReplaceAllString(text, secret.replacement)
+	}
+	return text
+}
+
+// Printf is fmt.Printf with the output redacted. Use it to print anything that comes from outside hldbx,
+// such as errors, which can embed request bodies.
+func Printf(format string, a ...any) {
+	fmt.Print(Redact(fmt.Sprintf(format, a...)))
+}
+
+// redactingWriter redacts everything written through it.
+type redactingWriter struct {
+	w io.Writer
+}
+
+// NewRedactingWriter returns a writer that redacts secrets before writing to w.
+// Secrets split across writes aren't redacted, so use it where each write is a whole message, as with the log package.
+func NewRedactingWriter(w io.Writer) io.Writer {
+	return &redactingWriter{w: w}
+}
+
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(r.w, Redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,18 @@
+package utils
+
+import "os"
+
+// Environment variables that the bootstrap notebook passes the Databricks workspace and the token of its context in.
+// These must match hldbx_bootstrap.py.
+const (
+	DatabricksHostEnv  = "DATABRICKS_HOST"
+	DatabricksTokenEnv = "DATABRICKS_TOKEN"
+)
+
+// Set by the Databricks Runtime on its clusters and serverless compute
+const databricksRuntimeEnv = "DATABRICKS_RUNTIME_VERSION"
+
+// InDatabricks returns true if hldbx runs within Databricks, e.g. from the bootstrap notebook, where it can't prompt.
+func InDatabricks() bool {
+	return os.Getenv(databricksRuntimeEnv) != ""
+}
@@ -0,0 +1,233 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+	"gopkg.in/yaml.v3"
+)
+
+// SettingKeys returns the configuration keys that SetConfigValue can set: those with a single string, number,
+// or boolean value.
+func SettingKeys() []string {
+	var keys []string
+	for _, setting := range hlconfig.Settings() {
+		if _, ok := scalarTag(setting.Kind); ok {
+			keys = append(keys, setting.Key)
+		}
+	}
+	return keys
+}
+
+// SetConfigValue sets a setting in the selected profile's configuration file, creating the file if needed, and
+// keeping its comments and other settings. If a profile of the file is selected, the setting is set within it. The
+// updated configuration is checked with validate, if given, before the file is written. Returns the updated
+// configuration.
+func SetConfigValue(key string, value string, validate func(*Config) error) (*Config, error) {
+	var kind reflect.Kind
+	for _, setting := range hlconfig.Settings() {
+		if setting.Key == key {
+			kind = setting.Kind
+		}
+	}
+	tag, ok := scalarTag(kind)
+	if kind == reflect.Invalid {
+		return nil, fmt.Errorf("unknown setting %s, expected one of %s", key, strings.Join(SettingKeys(), ", "))
+	} else if !ok {
+		return nil, fmt.Errorf("%s holds a list or map, edit the configuration file to change it", key)
+	}
+	switch kind {
+	case reflect.Int:
+		if _, err := strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("%s must be an integer, got %q", key, value)
+		}
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false, got %q", key, value)
+		}
+		value = strconv.FormatBool(parsed)
+	}
+	return setConfigNodes([]string{key}, []*yaml.Node{{Kind: yaml.ScalarNode, Tag: tag, Value: value}}, validate)
+}
+
+// ConfigValue is a setting for SetConfigValues to set.
+type ConfigValue struct {
+	Key   string
+	Value any // written as YAML, so it can be a list or a map too
+}
+
+// SetConfigValues sets settings in the selected profile's configuration file, like SetConfigValue, but all at once,
+// including those that hold lists and maps. Settings that aren't in the file yet are added in the order given.
+func SetConfigValues(values []ConfigValue, validate func(*Config) error) (*Config, error) {
+	keys := SettingKeys()
+	var names []string
+	var nodes []*yaml.Node
+	for _, value := range values {
+		if !slices.ContainsFunc(hlconfig.Settings(), func(setting hlconfig.Setting) bool { return setting.Key == value.Key }) {
+			return nil, fmt.Errorf("unknown setting %s, expected one of %s", value.Key, strings.Join(keys, ", "))
+		}
+		node := &yaml.Node{}
+		if err := node.Encode(value.Value); err != nil {
+			return nil, fmt.Errorf("unable to marshal %s: %w", value.Key, err)
+		}
+		names = append(names, value.Key)
+		nodes = append(nodes, node)
+	}
+	return setConfigNodes(names, nodes, validate)
+}
+
+// AddConfigProfile adds the selected profile to the configuration file, without settings, unless it has it already
+// or no profile is selected, so that its settings can be prompted for. Returns true if it was added.
+func AddConfigProfile() (bool, error) {
+	configProfile, err := ConfigProfile()
+	if err != nil || configProfile == "" {
+		return false, err
+	}
+	configPath, err := ConfigFilePath()
+	if err != nil {
+		return false, err
+	}
+	file, err := ReadConfigFile(configPath)
+	if err != nil {
+		return false, err
+	}
+	if slices.Contains(file.ProfileNames(), configProfile) {
+		return false, nil
+	}
+	if _, err := setConfigNodes(nil, nil, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// setConfigNodes sets the values of the keys in the configuration file, checks the resulting configuration, and
+// writes the file.
+func setConfigNodes(keys []string, valueNodes []*yaml.Node, validate func(*Config) error) (*Config, error) {
+	configPath, err := ConfigFilePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", configPath, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid configuration file %s: expected a mapping of settings", configPath)
+	}
+	configProfile, err := ConfigProfile()
+	if err != nil {
+		return nil, err
+	}
+	if configProfile != "" {
+		// The settings of the selected profile are set within it, and those at the top of the file are kept
+		profiles := mappingValue(root, hlconfig.ProfilesKey)
+		if profiles == nil {
+			return nil, fmt.Errorf("invalid configuration file %s: %s is not a mapping of profiles", configPath,
+				hlconfig.ProfilesKey)
+		}
+		if root = mappingValue(profiles, configProfile); root == nil {
+			return nil, fmt.Errorf("invalid configuration file %s: profile %s is not a mapping of settings", configPath,
+				configProfile)
+		}
+	}
+	for j, key := range keys {
+		valueNode := valueNodes[j]
+		found := false
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value == key {
+				// Keep the comment on the line of the old value
+				valueNode.LineComment = root.Content[i+1].LineComment
+				root.Content[i+1] = valueNode
+				found = true
+			}
+		}
+		if !found {
+			root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, valueNode)
+		}
+	}
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("unable to marshal the configuration: %w", err)
+	}
+
+	// Decode the updated file the same way InitConfig does, to catch values that it would reject
+	file, err := hlconfig.ReadFile(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if file, err = file.Profile(configProfile); err != nil {
+		return nil, err
+	}
+	config, err := hlconfig.Load(file)
+	if err != nil {
+		return nil, err
+	}
+	if validate != nil {
+		if err := validate(config); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(configPath, out.Bytes(), 0o600); err != nil {
+		return nil, fmt.Errorf("unable to write %s: %w", configPath, err)
+	}
+	RegisterConfigSecrets(config)
+	return config, nil
+}
+
+// mappingValue returns the mapping that a key of a mapping holds, adding an empty one if the mapping doesn't have the
+// key, which is matched case-insensitively, like the keys of a configuration file are read. Returns nil if the key
+// holds something else.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if !strings.EqualFold(mapping.Content[i].Value, key) {
+			continue
+		}
+		value := mapping.Content[i+1]
+		if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+			// An empty profile, e.g. "staging:" with nothing under it yet
+			*value = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", LineComment: value.LineComment}
+		}
+		if value.Kind != yaml.MappingNode {
+			return nil
+		}
+		return value
+	}
+	value := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+	return value
+}
+
+// scalarTag returns the YAML tag of a configuration field's kind, and whether the kind is a single value.
+func scalarTag(kind reflect.Kind) (string, bool) {
+	switch kind {
+	case reflect.String:
+		return "!!str", true
+	case reflect.Int:
+		return "!!int", true
+	case reflect.Bool:
+		return "!!bool", true
+	}
+	return "", false
+}
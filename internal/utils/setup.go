@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Permissions of the profile directory and of the files in it that hold secrets, which only their owner may access
+const (
+	privateDirMode  fs.FileMode = 0o700
+	privateFileMode fs.FileMode = 0o600
+)
+
+// Lines of the example configuration file that only make sense in the repository
+const configTemplateHeaderLines = 2
+
+// SetupResult is what Setup did to the selected profile's directory.
+type SetupResult struct {
+	ProfileDir    string
+	ConfigPath    string
+	ConfigCreated bool     // false if there already was a configuration file, which is kept
+	Secured       []string // paths whose permissions were tightened
+}
+
+// Setup creates the selected profile's directory and a configuration file in it, from the example configuration
+// file with every setting commented out, so that hldbx prompts for those that aren't uncommented. An existing
+// configuration file is kept. The directory, the configuration file, and the Databricks token cache are made
+// accessible only to their owner, since they may hold secrets. Windows has no such permissions, and files in the
+// user's profile are only accessible to the user by default, so they are left alone there.
+func Setup(configTemplate []byte) (*SetupResult, error) {
+	dir, err := ProfileDir()
+	if err != nil {
+		return nil, err
+	}
+	result := &SetupResult{ProfileDir: dir}
+	if err := os.MkdirAll(dir, privateDirMode); err != nil {
+		return nil, fmt.Errorf("unable to create directory %s: %w", dir, err)
+	}
+	if result.ConfigPath, err = ConfigFilePath(); err != nil {
+		return nil, err
+	}
+	// A configuration file given with --config or HLDBX_CONFIG may be elsewhere
+	if configDir := filepath.Dir(result.ConfigPath); configDir != dir {
+		if err := os.MkdirAll(configDir, privateDirMode); err != nil {
+			return nil, fmt.Errorf("unable to create directory %s: %w", configDir, err)
+		}
+	}
+	if _, err := os.Stat(result.ConfigPath); errors.Is(err, fs.ErrNotExist) {
+		if err := os.WriteFile(result.ConfigPath, commentedConfigTemplate(configTemplate), privateFileMode); err != nil {
+			return nil, fmt.Errorf("unable to write %s: %w", result.ConfigPath, err)
+		}
+		result.ConfigCreated = true
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to check %s: %w", result.ConfigPath, err)
+	}
+
+	if runtime.GOOS == "windows" {
+		return result, nil
+	}
+	tokenCachePath, err := TokenCachePath()
+	if err != nil {
+		return nil, err
+	}
+	for path, mode := range map[string]fs.FileMode{
+		dir: privateDirMode, result.ConfigPath: privateFileMode, tokenCachePath: privateFileMode,
+	} {
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to check %s: %w", path, err)
+		}
+		if info.Mode().Perm()&^mode == 0 {
+			continue
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			return nil, fmt.Errorf("unable to restrict the permissions of %s: %w", path, err)
+		}
+		result.Secured = append(result.Secured, path)
+	}
+	return result, nil
+}
+
+// commentedConfigTemplate returns the example configuration file with every setting commented out.
+func commentedConfigTemplate(configTemplate []byte) []byte {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# Configuration file of the hldbx command line tool, written by hldbx setup.\n")
+	fmt.Fprintf(&out, "# Uncomment and edit the settings to use; hldbx prompts for the required settings that are left out.\n")
+	fmt.Fprintf(&out, "# Run hldbx config set <key> <value> to change a setting without editing this file.\n")
+	scanner := bufio.NewScanner(bytes.NewReader(configTemplate))
+	for line := 0; scanner.Scan(); line++ {
+		text := scanner.Text()
+		switch {
+		case line < configTemplateHeaderLines:
+			continue
+		case text == "" || strings.HasPrefix(strings.TrimSpace(text), "#"):
+			out.WriteString(text + "\n")
+		default:
+			out.WriteString("# " + text + "\n")
+		}
+	}
+	return out.Bytes()
+}
@@ -1,10 +1,56 @@
 package utils
 
 import (
+	"fmt"
+	"regexp"
+
 	"github.com/databricks/databricks-sdk-go/useragent"
 )
 
+// Product that hldbx identifies itself as, to Databricks and HiddenLayer
+const userAgentProduct = "hiddenlayer-model-scanner"
+
+// Key of the user agent part that identifies the deployment, followed by user_agent_suffix
+const userAgentDeploymentKey = "deployment"
+
+// The characters that the Databricks SDK allows in a user agent value, and a length that proxy logs keep whole
+var userAgentSuffixPattern = regexp.MustCompile(`^[0-9A-Za-z_.+-]{1,64}$`)
+
+// Deployment that the user agent identifies, see ConfigureUserAgentSuffix
+var userAgentSuffix string
+
 func ConfigureHLUserAgent() {
-	useragent.WithProduct("hiddenlayer-model-scanner", Version)
+	useragent.WithProduct(userAgentProduct, Version)
 	useragent.WithPartner("HiddenLayer")
 }
+
+// ValidateUserAgentSuffix checks user_agent_suffix, which goes into the user agent of every request as is.
+func ValidateUserAgentSuffix(config *Config) error {
+	if config.UserAgentSuffix != "" && !userAgentSuffixPattern.MatchString(config.UserAgentSuffix) {
+		return fmt.Errorf("user_agent_suffix %q must be up to 64 letters, digits, '.', '_', '+' or '-', e.g. acme-prod",
+			config.UserAgentSuffix)
+	}
+	return nil
+}
+
+// ConfigureUserAgentSuffix adds the deployment of the configuration to the user agent of the requests to Databricks
+// and HiddenLayer, so that enterprise proxies and HiddenLayer support can attribute traffic to it.
+func ConfigureUserAgentSuffix(config *Config) error {
+	if err := ValidateUserAgentSuffix(config); err != nil {
+		return err
+	}
+	if config.UserAgentSuffix != "" {
+		useragent.WithUserAgentExtra(userAgentDeploymentKey, config.UserAgentSuffix)
+	}
+	userAgentSuffix = config.UserAgentSuffix
+	return nil
+}
+
+// UserAgent returns the user agent of the requests to HiddenLayer, e.g. hiddenlayer-model-scanner/0.2.0 deployment/acme-prod.
+func UserAgent() string {
+	agent := fmt.Sprintf("%s/%s", userAgentProduct, Version)
+	if userAgentSuffix != "" {
+		agent += fmt.Sprintf(" %s/%s", userAgentDeploymentKey, userAgentSuffix)
+	}
+	return agent
+}
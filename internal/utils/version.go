@@ -1,4 +1,28 @@
 package utils
 
+import "runtime/debug"
+
 // Version of the hldbx tool
 const Version = "0.2.0"
+
+// GitCommit returns the git commit that the hldbx binary was built from, with a -dirty suffix if the working tree
+// had uncommitted changes, or "" if the build didn't record it, e.g. with -buildvcs=false.
+func GitCommit() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if revision != "" && modified == "true" {
+		return revision + "-dirty"
+	}
+	return revision
+}
@@ -0,0 +1,100 @@
+package hlconfig
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Cloud is the cloud provider that the Databricks workspace runs on, which decides the defaults and validation of
+// what differs between clouds, such as the node types of job clusters.
+type Cloud string
+
+const (
+	CloudAzure Cloud = "azure"
+	CloudAWS   Cloud = "aws"
+	CloudGCP   Cloud = "gcp"
+)
+
+// Validate checks that the cloud is known, or empty to detect it from dbx_host.
+func (c Cloud) Validate() error {
+	switch c {
+	case "", CloudAzure, CloudAWS, CloudGCP:
+		return nil
+	}
+	return fmt.Errorf("invalid dbx_cloud %q, expected %s, %s, or %s", c, CloudAzure, CloudAWS, CloudGCP)
+}
+
+// Hostname suffixes of the workspaces of each cloud, which cover their Private Link front ends, e.g.
+// adb-1234567890123456.7.privatelink.azuredatabricks.net, and the government and sovereign clouds
+var cloudHostSuffixes = []struct {
+	cloud    Cloud
+	suffixes []string
+}{
+	{CloudAzure, []string{".azuredatabricks.net", ".databricks.azure.us", ".databricks.azure.cn"}},
+	{CloudGCP, []string{".gcp.databricks.com"}},
+	{CloudAWS, []string{".cloud.databricks.com", ".cloud.databricks.us", ".cloud.databricks.mil"}},
+}
+
+// Labels of the hostnames of Private Link, and of Private Service Connect on GCP, front ends
+var privateLinkHostLabels = []string{"privatelink", "psc"}
+
+// hostname returns the lower-case hostname of a workspace URL, or of a bare hostname.
+func hostname(host string) string {
+	if !strings.Contains(host, "://") {
+		host = "https://" + host
+	}
+	parsed, err := url.Parse(host)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// DetectCloud returns the cloud of a workspace from its URL, or "" if the URL doesn't tell, e.g. a vanity or private
+// DNS hostname.
+func DetectCloud(host string) Cloud {
+	name := hostname(host)
+	for _, cloud := range cloudHostSuffixes {
+		for _, suffix := range cloud.suffixes {
+			if strings.HasSuffix(name, suffix) {
+				return cloud.cloud
+			}
+		}
+	}
+	return ""
+}
+
+// IsPrivateLinkHost returns true if a workspace URL is that of a Private Link front end, which only resolves from
+// networks connected to it.
+func IsPrivateLinkHost(host string) bool {
+	for _, label := range strings.Split(hostname(host), ".") {
+		for _, privateLabel := range privateLinkHostLabels {
+			if label == privateLabel {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Cloud returns the cloud of the workspace: dbx_cloud if set, otherwise the one that dbx_host tells, or "" if
+// neither does.
+func (c *Config) Cloud() Cloud {
+	if c.DbxCloud != "" {
+		return c.DbxCloud
+	}
+	return DetectCloud(c.DbxHost)
+}
+
+// ValidateCloud checks that dbx_cloud is known, and that it's the cloud that dbx_host tells, if it tells one.
+func (c *Config) ValidateCloud() error {
+	if err := c.DbxCloud.Validate(); err != nil {
+		return err
+	}
+	if detected := DetectCloud(c.DbxHost); c.DbxCloud != "" && detected != "" && detected != c.DbxCloud {
+		return fmt.Errorf("dbx_cloud is %s, but dbx_host %s is a workspace on %s; remove dbx_cloud to detect it",
+			c.DbxCloud, c.DbxHost, detected)
+	}
+	return nil
+}
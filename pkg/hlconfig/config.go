@@ -0,0 +1,431 @@
+// Package hlconfig is the configuration format of hldbx: the settings of the hldbx.yaml file of a profile, their
+// defaults and validation, and how settings from the file, the environment, and flags are merged. Other HiddenLayer
+// tooling imports it to read and write the same files.
+package hlconfig
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CatalogSchemaConfig is a monitored schema.
+type CatalogSchemaConfig struct {
+	Catalog string `mapstructure:"dbx_catalog" json:"catalog,omitempty"`
+	Schema  string `mapstructure:"dbx_schema" json:"schema,omitempty"`
+	Scanner string `mapstructure:"scanner" json:"scanner,omitempty"` // name of an hl_scanners entry, empty for the default
+}
+
+// ScannerConfig is a scanning endpoint that implements the HiddenLayer model scanner REST API, such as a staging
+// HiddenLayer instance, which schemas can select instead of the scanner of the hl_api_url settings.
+type ScannerConfig struct {
+	Name         string      `mapstructure:"name" json:"name"`
+	ApiUrl       string      `mapstructure:"api_url" json:"api_url"`
+	AuthUrl      string      `mapstructure:"auth_url" json:"auth_url,omitempty"`
+	ConsoleUrl   string      `mapstructure:"console_url" json:"console_url,omitempty"`
+	Auth         ScannerAuth `mapstructure:"auth" json:"auth,omitempty"`                   // client_credentials, none, or databricks_token
+	ClientID     string      `mapstructure:"client_id" json:"client_id,omitempty"`         // defaults to hl_client_id
+	ClientSecret string      `mapstructure:"client_secret" json:"client_secret,omitempty"` // defaults to hl_client_secret
+}
+
+// Name of the scanner of the hl_api_url settings, which schemas use unless they select another
+const DefaultScannerName = "default"
+
+// ArtifactSourceConfig is a source of prompt and agent artifacts to scan, other than registered models.
+type ArtifactSourceConfig struct {
+	Name       string   `mapstructure:"name" json:"name"`
+	Type       string   `mapstructure:"type" json:"type"`                       // volume or mlflow_experiment
+	Path       string   `mapstructure:"path" json:"path,omitempty"`             // for volume sources
+	Experiment string   `mapstructure:"experiment" json:"experiment,omitempty"` // for mlflow_experiment sources
+	Patterns   []string `mapstructure:"patterns" json:"patterns,omitempty"`     // glob patterns of the files to scan
+}
+
+// Config is the configuration of hldbx, read from the hldbx.yaml file of a profile. Its settings are grouped in
+// sections, which are squashed, so the file and its JSON form are a flat mapping of settings.
+type Config struct {
+	WorkspaceConfig    `mapstructure:",squash"`
+	ComputeConfig      `mapstructure:",squash"`
+	JobsConfig         `mapstructure:",squash"`
+	ScanPolicyConfig   `mapstructure:",squash"`
+	NotificationConfig `mapstructure:",squash"`
+	AccessConfig       `mapstructure:",squash"`
+	ResultsConfig      `mapstructure:",squash"`
+	HiddenLayerConfig  `mapstructure:",squash"`
+	EgressConfig       `mapstructure:",squash"`
+}
+
+// WorkspaceConfig holds the settings of the Databricks workspace, and how hldbx authenticates to it.
+type WorkspaceConfig struct {
+	DbxHost         string `mapstructure:"dbx_host" json:"dbx_host,omitempty"`
+	DbxCloud        Cloud  `mapstructure:"dbx_cloud" json:"dbx_cloud,omitempty"` // detected from dbx_host if not set
+	DbxToken        string `mapstructure:"dbx_token" json:"dbx_token,omitempty"`
+	DbxOidcIssuer   string `mapstructure:"dbx_oidc_issuer" json:"dbx_oidc_issuer,omitempty"`
+	DbxOidcClientId string `mapstructure:"dbx_oidc_client_id" json:"dbx_oidc_client_id,omitempty"`
+	DbxOidcScopes   string `mapstructure:"dbx_oidc_scopes" json:"dbx_oidc_scopes,omitempty"`
+}
+
+// ComputeConfig holds the settings of the compute that the jobs run on.
+type ComputeConfig struct {
+	DbxClusterId          string            `mapstructure:"dbx_cluster_id" json:"dbx_cluster_id,omitempty"`
+	DbxClusterTags        map[string]string `mapstructure:"dbx_required_cluster_tags" json:"dbx_required_cluster_tags,omitempty"`
+	DbxClusterTagPolicy   ClusterTagPolicy  `mapstructure:"dbx_cluster_tag_policy" json:"dbx_cluster_tag_policy,omitempty"`
+	DbxServerless         bool              `mapstructure:"dbx_serverless" json:"dbx_serverless,omitempty"`
+	DbxBudgetPolicyId     string            `mapstructure:"dbx_budget_policy_id" json:"dbx_budget_policy_id,omitempty"`
+	DbxJobClusterNodeType string            `mapstructure:"dbx_job_cluster_node_type" json:"dbx_job_cluster_node_type,omitempty"`
+	DbxJobClusterSpark    string            `mapstructure:"dbx_job_cluster_spark_version" json:"dbx_job_cluster_spark_version,omitempty"`
+	DbxJobClusterWorkers  int               `mapstructure:"dbx_job_cluster_workers" json:"dbx_job_cluster_workers,omitempty"`
+}
+
+// JobsConfig holds the settings of the jobs that hldbx installs, and their notebooks.
+type JobsConfig struct {
+	DbxRunAs              string `mapstructure:"dbx_run_as" json:"dbx_run_as,omitempty"`
+	DbxMonitorJobName     string `mapstructure:"dbx_monitor_job_name" json:"dbx_monitor_job_name,omitempty"`
+	DbxGuardrailJobName   string `mapstructure:"dbx_guardrail_job_name" json:"dbx_guardrail_job_name,omitempty"`
+	DbxVerifyJobName      string `mapstructure:"dbx_verify_job_name" json:"dbx_verify_job_name,omitempty"`
+	DbxJobDescription     string `mapstructure:"dbx_job_description" json:"dbx_job_description,omitempty"`
+	DbxWorkspaceDir       string `mapstructure:"dbx_workspace_dir" json:"dbx_workspace_dir,omitempty"`
+	DbxKeepVersions       int    `mapstructure:"dbx_keep_notebook_versions" json:"dbx_keep_notebook_versions,omitempty"`
+	DbxMaxActiveScanJobs  int    `mapstructure:"dbx_max_active_scan_jobs" json:"dbx_max_active_scan_jobs,omitempty"`
+	DbxPollingQuartzCron  string `mapstructure:"dbx_polling_quartz_cron" json:"dbx_polling_quartz_cron,omitempty"`
+	DbxServingGuardrail   bool   `mapstructure:"dbx_serving_guardrail" json:"dbx_serving_guardrail,omitempty"`
+	DbxVerifyJob          bool   `mapstructure:"dbx_verify_job" json:"dbx_verify_job,omitempty"`
+	DbxVerifyQuartzCron   string `mapstructure:"dbx_verify_quartz_cron" json:"dbx_verify_quartz_cron,omitempty"`
+	DbxOnDemandJob        bool   `mapstructure:"dbx_on_demand_job" json:"dbx_on_demand_job,omitempty"`
+	DbxOnDemandGroup      string `mapstructure:"dbx_on_demand_group" json:"dbx_on_demand_group,omitempty"`
+	DbxHeartbeatMaxMissed int    `mapstructure:"dbx_heartbeat_max_missed" json:"dbx_heartbeat_max_missed,omitempty"`
+}
+
+// ScanPolicyConfig holds the settings of what is scanned, when, and what happens when it can't be.
+type ScanPolicyConfig struct {
+	DbxSchemas         []CatalogSchemaConfig  `mapstructure:"dbx_schemas" json:"dbx_schemas,omitempty"`
+	DbxArtifactSources []ArtifactSourceConfig `mapstructure:"dbx_artifact_sources" json:"dbx_artifact_sources,omitempty"`
+	DbxScanComments    bool                   `mapstructure:"dbx_scan_comments" json:"dbx_scan_comments,omitempty"`
+	DbxScanTrigger     ScanTrigger            `mapstructure:"dbx_scan_trigger" json:"dbx_scan_trigger,omitempty"`
+	DbxDiscoverySource DiscoverySource        `mapstructure:"dbx_discovery_source" json:"dbx_discovery_source,omitempty"`
+	DbxScanAliases     []string               `mapstructure:"dbx_scan_aliases" json:"dbx_scan_aliases,omitempty"`
+	DbxSkipExternal    bool                   `mapstructure:"dbx_skip_external_versions" json:"dbx_skip_external_versions,omitempty"`
+	HlOutagePolicy     OutagePolicy           `mapstructure:"hl_outage_policy" json:"hl_outage_policy,omitempty"`
+	HlCircuitBreaker   int                    `mapstructure:"hl_circuit_breaker_threshold" json:"hl_circuit_breaker_threshold,omitempty"`
+	HlScanOrigin       string                 `mapstructure:"hl_scan_origin" json:"hl_scan_origin,omitempty"`
+	HlScanMetadata     map[string]string      `mapstructure:"hl_scan_metadata" json:"hl_scan_metadata,omitempty"`
+}
+
+// NotificationConfig holds the settings of who is alerted, and where detections are exported to.
+type NotificationConfig struct {
+	DbxNotifyEmails       []string `mapstructure:"dbx_notify_emails" json:"dbx_notify_emails,omitempty"`
+	DbxNotifyDestinations []string `mapstructure:"dbx_notify_destinations" json:"dbx_notify_destinations,omitempty"`
+	DbxFindingsSink       string   `mapstructure:"dbx_findings_sink" json:"dbx_findings_sink,omitempty"`
+	DbxFindingsSinkKey    string   `mapstructure:"dbx_findings_sink_key" json:"dbx_findings_sink_key,omitempty"`
+}
+
+// AccessConfig holds the settings of who may manage the jobs, read the secrets, and see the scan results.
+type AccessConfig struct {
+	DbxOwnerGroups       []string `mapstructure:"dbx_owner_groups" json:"dbx_owner_groups,omitempty"`
+	DbxSecretsGroup      string   `mapstructure:"dbx_secrets_group" json:"dbx_secrets_group,omitempty"`
+	DbxSecretsPermission string   `mapstructure:"dbx_secrets_permission" json:"dbx_secrets_permission,omitempty"`
+	DbxAbacGroup         string   `mapstructure:"dbx_abac_group" json:"dbx_abac_group,omitempty"`
+	OwnerContact         string   `mapstructure:"owner_contact" json:"owner_contact,omitempty"`
+}
+
+// ResultsConfig holds the settings of the tables that hold the scan state, how long they keep their records, and the
+// share that exports the results.
+type ResultsConfig struct {
+	DbxStateTable         string `mapstructure:"dbx_state_table" json:"dbx_state_table,omitempty"`
+	DbxCoordinationTable  string `mapstructure:"dbx_coordination_table" json:"dbx_coordination_table,omitempty"`
+	DbxResultsShare       string `mapstructure:"dbx_results_share" json:"dbx_results_share,omitempty"`
+	DbxResultsRecipient   string `mapstructure:"dbx_results_recipient" json:"dbx_results_recipient,omitempty"`
+	DbxResultsRecipientId string `mapstructure:"dbx_results_recipient_id" json:"dbx_results_recipient_id,omitempty"`
+	DbxRetentionDays      Days   `mapstructure:"dbx_retention_days" json:"dbx_retention_days,omitempty"`
+	DbxArchiveLocation    string `mapstructure:"dbx_archive_location" json:"dbx_archive_location,omitempty"`
+}
+
+// HiddenLayerConfig holds the settings of the HiddenLayer API, its credentials, and the alternative scanners.
+type HiddenLayerConfig struct {
+	HlApiKeyName      string          `mapstructure:"hl_api_key_name" json:"hl_api_key_name,omitempty"`
+	HlClientID        string          `mapstructure:"hl_client_id" json:"hl_client_id,omitempty"`
+	HlClientSecret    string          `mapstructure:"hl_client_secret" json:"hl_client_secret,omitempty"`
+	HlRegion          string          `mapstructure:"hl_region" json:"hl_region,omitempty"`
+	HlApiUrl          string          `mapstructure:"hl_api_url" json:"hl_api_url,omitempty"`
+	HlAuthUrl         string          `mapstructure:"hl_auth_url" json:"hl_auth_url,omitempty"`
+	HlConsoleUrl      string          `mapstructure:"hl_console_url" json:"hl_console_url,omitempty"`
+	HlCredsMaxAgeDays Days            `mapstructure:"hl_credentials_max_age_days" json:"hl_credentials_max_age_days,omitempty"`
+	HlScanners        []ScannerConfig `mapstructure:"hl_scanners" json:"hl_scanners,omitempty"`
+	UserAgentSuffix   string          `mapstructure:"user_agent_suffix" json:"user_agent_suffix,omitempty"`
+}
+
+// EgressConfig holds the settings of how hldbx reaches the HiddenLayer API through the network.
+type EgressConfig struct {
+	HlHttpsProxy    string   `mapstructure:"hl_https_proxy" json:"hl_https_proxy,omitempty"`
+	HlNoProxy       string   `mapstructure:"hl_no_proxy" json:"hl_no_proxy,omitempty"`
+	HlCaBundlePath  string   `mapstructure:"hl_ca_bundle_path" json:"hl_ca_bundle_path,omitempty"`
+	HlTlsMinVersion string   `mapstructure:"hl_tls_min_version" json:"hl_tls_min_version,omitempty"`
+	HlTlsPins       []string `mapstructure:"hl_tls_pins" json:"hl_tls_pins,omitempty"`
+}
+
+// Days is a number of days, as the settings that are durations hold them.
+type Days int
+
+// Duration returns the number of days as a duration.
+func (d Days) Duration() time.Duration {
+	return time.Duration(d) * 24 * time.Hour
+}
+
+// Default maximum age of the HiddenLayer API credentials, after which hldbx reminds you to rotate them
+const defaultHlCredsMaxAgeDays Days = 90
+
+// Bounds and default of the number of scan jobs that the monitoring job runs at once. These must match hl_common.py.
+const (
+	MinMaxActiveScanJobs     = 1
+	MaxMaxActiveScanJobs     = 100
+	DefaultMaxActiveScanJobs = 10
+)
+
+// Default number of scheduled runs of the monitoring job that may pass without a heartbeat before hldbx alerts
+const defaultHeartbeatMaxMissed = 3
+
+// Default number of hldbx versions whose notebooks hldbx upgrade keeps in the workspace directory: the current one,
+// and the previous one to roll back to
+const defaultKeepNotebookVersions = 2
+
+// Default number of HiddenLayer API errors in the scan jobs of a monitoring run after which the run stops submitting
+// scans to that scanner. This must match hl_common.py.
+const defaultCircuitBreakerThreshold = 5
+
+// Name of the state table created in the first monitored schema when dbx_state_table isn't set
+const defaultStateTableName = "hl_scan_state"
+
+// RedactedValue replaces secret values when a configuration is displayed or exported
+const RedactedValue = "<redacted>"
+
+func (c *Config) UsesEnterpriseModelScanner() bool {
+	return isEnterpriseApiUrl(c.HlApiUrl)
+}
+
+// isEnterpriseApiUrl returns true if the API URL is of an enterprise scanner, i.e. not a hiddenlayer.ai API URL.
+func isEnterpriseApiUrl(apiUrl string) bool {
+	hlApi, err := url.Parse(apiUrl)
+	if err != nil {
+		log.Fatalf("Error parsing HiddenLayer API URL: %v", err)
+	}
+	return !strings.HasSuffix(hlApi.Hostname(), ".hiddenlayer.ai")
+}
+
+// DefaultScanner returns the scanner of the hl_api_url settings.
+func (c *Config) DefaultScanner() ScannerConfig {
+	auth := ScannerAuthClientCredentials
+	if c.UsesEnterpriseModelScanner() {
+		auth = ScannerAuthNone
+	}
+	return ScannerConfig{
+		Name:         DefaultScannerName,
+		ApiUrl:       c.HlApiUrl,
+		AuthUrl:      c.HlAuthUrl,
+		ConsoleUrl:   c.HlConsoleUrl,
+		Auth:         auth,
+		ClientID:     c.HlClientID,
+		ClientSecret: c.HlClientSecret,
+	}
+}
+
+// Scanner returns the scanner that scans the model versions of a schema, with its defaults filled in.
+// Returns false if the schema selects a scanner that isn't configured.
+func (c *Config) Scanner(schema CatalogSchemaConfig) (ScannerConfig, bool) {
+	if schema.Scanner == "" || schema.Scanner == DefaultScannerName {
+		return c.DefaultScanner(), true
+	}
+	for _, scanner := range c.HlScanners {
+		if scanner.Name != schema.Scanner {
+			continue
+		}
+		if scanner.Auth == "" {
+			// Like the default scanner, only hiddenlayer.ai scanners need credentials unless told otherwise
+			scanner.Auth = ScannerAuthClientCredentials
+			if isEnterpriseApiUrl(scanner.ApiUrl) {
+				scanner.Auth = ScannerAuthNone
+			}
+		}
+		if scanner.ClientID == "" && scanner.ClientSecret == "" {
+			scanner.ClientID, scanner.ClientSecret = c.HlClientID, c.HlClientSecret
+		}
+		return scanner, true
+	}
+	return ScannerConfig{}, false
+}
+
+// ScannersInUse returns the default scanner, and the other scanners that monitored schemas select, once each.
+func (c *Config) ScannersInUse() []ScannerConfig {
+	scanners := []ScannerConfig{c.DefaultScanner()}
+	for _, schema := range c.DbxSchemas {
+		scanner, ok := c.Scanner(schema)
+		if ok && !slices.ContainsFunc(scanners, func(s ScannerConfig) bool { return s.Name == scanner.Name }) {
+			scanners = append(scanners, scanner)
+		}
+	}
+	return scanners
+}
+
+// ModelScanner returns the scanner of the monitored schema that holds a model, given its full name.
+// Models outside the monitored schemas get the default scanner.
+func (c *Config) ModelScanner(fullName string) ScannerConfig {
+	for _, schema := range c.DbxSchemas {
+		if strings.HasPrefix(strings.ToLower(fullName), strings.ToLower(schema.Catalog+"."+schema.Schema+".")) {
+			if scanner, ok := c.Scanner(schema); ok {
+				return scanner
+			}
+		}
+	}
+	return c.DefaultScanner()
+}
+
+// UsesClientCredentials returns true if the scanner of any monitored schema authenticates with client credentials,
+// which are stored in the schemas' secrets scopes.
+func (c *Config) UsesClientCredentials() bool {
+	for _, schema := range c.DbxSchemas {
+		if scanner, ok := c.Scanner(schema); ok && scanner.UsesClientCredentials() {
+			return true
+		}
+	}
+	return false
+}
+
+// UsesDatabricksTokens returns true if the scanner of any monitored schema authenticates with Databricks tokens
+// that the scan jobs mint at runtime.
+func (c *Config) UsesDatabricksTokens() bool {
+	for _, schema := range c.DbxSchemas {
+		if scanner, ok := c.Scanner(schema); ok && scanner.Auth == ScannerAuthDatabricksToken {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEnterprise returns true if the scanner is an enterprise scanner, i.e. not a hiddenlayer.ai API.
+func (s ScannerConfig) IsEnterprise() bool {
+	return isEnterpriseApiUrl(s.ApiUrl)
+}
+
+// UsesClientCredentials returns true if the scanner authenticates with a HiddenLayer client ID and secret.
+func (s ScannerConfig) UsesClientCredentials() bool {
+	return s.Auth == ScannerAuthClientCredentials
+}
+
+// UsesJobCluster returns true if the jobs run on a cluster that each run creates, rather than on dbx_cluster_id.
+func (c *Config) UsesJobCluster() bool {
+	return !c.DbxServerless && c.DbxJobClusterNodeType != ""
+}
+
+// UsesExistingCluster returns true if the jobs run on the cluster of dbx_cluster_id.
+func (c *Config) UsesExistingCluster() bool {
+	return !c.DbxServerless && !c.UsesJobCluster()
+}
+
+// UsesEventHubFindingsSink returns true if detections are exported to an Azure Event Hub, which needs a key.
+func (c *Config) UsesEventHubFindingsSink() bool {
+	return strings.HasPrefix(c.DbxFindingsSink, "eventhub://")
+}
+
+// UsesWebhookFindingsSink returns true if detections are posted to a webhook, which needs a signing secret.
+func (c *Config) UsesWebhookFindingsSink() bool {
+	return strings.HasPrefix(c.DbxFindingsSink, "https://")
+}
+
+// FindingsSinkNeedsKey returns true if the findings sink needs dbx_findings_sink_key stored in Databricks secrets.
+func (c *Config) FindingsSinkNeedsKey() bool {
+	return c.UsesEventHubFindingsSink() || c.UsesWebhookFindingsSink()
+}
+
+// HlCredsMaxAge returns how long the HiddenLayer API credentials may go without being rotated.
+func (c *Config) HlCredsMaxAge() time.Duration {
+	if c.HlCredsMaxAgeDays <= 0 {
+		return defaultHlCredsMaxAgeDays.Duration()
+	}
+	return c.HlCredsMaxAgeDays.Duration()
+}
+
+// StateTable returns the full name of the Delta table that the monitoring job appends its heartbeats to.
+func (c *Config) StateTable() string {
+	if c.DbxStateTable != "" || len(c.DbxSchemas) == 0 {
+		return c.DbxStateTable
+	}
+	return fmt.Sprintf("%s.%s.%s", c.DbxSchemas[0].Catalog, c.DbxSchemas[0].Schema, defaultStateTableName)
+}
+
+// MaxActiveScanJobs returns how many scan jobs the monitoring job may run at once.
+func (c *Config) MaxActiveScanJobs() int {
+	if c.DbxMaxActiveScanJobs == 0 {
+		return DefaultMaxActiveScanJobs
+	}
+	return c.DbxMaxActiveScanJobs
+}
+
+// HeartbeatMaxMissed returns how many scheduled runs may pass without a heartbeat before hldbx alerts.
+func (c *Config) HeartbeatMaxMissed() int {
+	if c.DbxHeartbeatMaxMissed <= 0 {
+		return defaultHeartbeatMaxMissed
+	}
+	return c.DbxHeartbeatMaxMissed
+}
+
+// CircuitBreakerThreshold returns how many HiddenLayer API errors, such as rejected credentials or an outage, the scan
+// jobs of a monitoring run may hit before the run stops submitting scans to that scanner: the default if
+// hl_circuit_breaker_threshold is unset or 0, and 0, which turns the breaker off in the jobs, if it is -1.
+func (c *Config) CircuitBreakerThreshold() int {
+	switch {
+	case c.HlCircuitBreaker < 0:
+		return 0
+	case c.HlCircuitBreaker == 0:
+		return defaultCircuitBreakerThreshold
+	}
+	return c.HlCircuitBreaker
+}
+
+// KeepNotebookVersions returns how many hldbx versions' notebooks hldbx upgrade keeps, the current one included.
+func (c *Config) KeepNotebookVersions() int {
+	if c.DbxKeepVersions <= 0 {
+		return defaultKeepNotebookVersions
+	}
+	return c.DbxKeepVersions
+}
+
+// Redacted returns a copy of the configuration with the secret values replaced, safe to display or share.
+func (c *Config) Redacted() Config {
+	redacted := *c
+	redacted.DbxSchemas = slices.Clone(c.DbxSchemas)
+	redacted.DbxArtifactSources = slices.Clone(c.DbxArtifactSources)
+	redacted.DbxScanAliases = slices.Clone(c.DbxScanAliases)
+	redacted.HlScanMetadata = maps.Clone(c.HlScanMetadata)
+	redacted.HlTlsPins = slices.Clone(c.HlTlsPins)
+	redacted.HlScanners = slices.Clone(c.HlScanners)
+	for i := range redacted.HlScanners {
+		if redacted.HlScanners[i].ClientSecret != "" {
+			redacted.HlScanners[i].ClientSecret = RedactedValue
+		}
+	}
+	if redacted.DbxToken != "" {
+		redacted.DbxToken = RedactedValue
+	}
+	if redacted.HlClientSecret != "" {
+		redacted.HlClientSecret = RedactedValue
+	}
+	if redacted.DbxFindingsSinkKey != "" {
+		redacted.DbxFindingsSinkKey = RedactedValue
+	}
+	// A proxy URL may hold the password of the proxy, which url.URL.Redacted masks
+	if proxyUrl, err := url.Parse(redacted.HlHttpsProxy); err == nil && proxyUrl.User != nil {
+		redacted.HlHttpsProxy = proxyUrl.Redacted()
+	}
+	return redacted
+}
+
+// SecretValues returns the secret values of the configuration, some of which may be empty.
+func (c *Config) SecretValues() []string {
+	secrets := []string{c.DbxToken, c.HlClientSecret, c.DbxFindingsSinkKey}
+	for _, scanner := range c.HlScanners {
+		secrets = append(secrets, scanner.ClientSecret)
+	}
+	return secrets
+}
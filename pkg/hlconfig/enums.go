@@ -0,0 +1,93 @@
+package hlconfig
+
+import "fmt"
+
+// ClusterTagPolicy is the policy for a cluster that lacks the tags of dbx_required_cluster_tags.
+type ClusterTagPolicy string
+
+const (
+	ClusterTagPolicyEnforce ClusterTagPolicy = "enforce" // refuse the cluster, the default
+	ClusterTagPolicyWarn    ClusterTagPolicy = "warn"    // use it, with a warning
+)
+
+// Validate checks that the policy is known, or empty for the default.
+func (p ClusterTagPolicy) Validate() error {
+	switch p {
+	case "", ClusterTagPolicyEnforce, ClusterTagPolicyWarn:
+		return nil
+	}
+	return fmt.Errorf("invalid dbx_cluster_tag_policy %q, expected %s or %s", p, ClusterTagPolicyEnforce, ClusterTagPolicyWarn)
+}
+
+// ScanTrigger is the policy for which model versions the monitoring job scans. These must match hl_common.py.
+type ScanTrigger string
+
+const (
+	ScanTriggerNewVersion ScanTrigger = "new_version" // scan the latest version of each model, the default
+	ScanTriggerAlias      ScanTrigger = "alias"       // scan the versions that have an alias, e.g. @staging or @prod
+)
+
+// Validate checks that the trigger is known, or empty for the default.
+func (t ScanTrigger) Validate() error {
+	switch t {
+	case "", ScanTriggerNewVersion, ScanTriggerAlias:
+		return nil
+	}
+	return fmt.Errorf("invalid dbx_scan_trigger %q, expected %s or %s", t, ScanTriggerNewVersion, ScanTriggerAlias)
+}
+
+// DiscoverySource is where the monitoring job discovers new model versions. These must match hl_common.py.
+type DiscoverySource string
+
+const (
+	DiscoverySourceList  DiscoverySource = "list"  // list every model in the monitored schemas on each run, the default
+	DiscoverySourceAudit DiscoverySource = "audit" // query the audit system table for the models changed since the previous run
+)
+
+// Validate checks that the source is known, or empty for the default.
+func (s DiscoverySource) Validate() error {
+	switch s {
+	case "", DiscoverySourceList, DiscoverySourceAudit:
+		return nil
+	}
+	return fmt.Errorf("invalid dbx_discovery_source %q, expected %s or %s", s, DiscoverySourceList, DiscoverySourceAudit)
+}
+
+// OutagePolicy is the policy for model versions that can't be scanned because the HiddenLayer API is unreachable.
+// These must match hl_common.py.
+type OutagePolicy string
+
+const (
+	OutagePolicyFailOpen   OutagePolicy = "fail_open"   // mark them scan_pending and alert, but let them be served, the default
+	OutagePolicyFailClosed OutagePolicy = "fail_closed" // also quarantine them, so the serving guardrail blocks them until scanned
+)
+
+// Validate checks that the policy is known, or empty for the default.
+func (p OutagePolicy) Validate() error {
+	switch p {
+	case "", OutagePolicyFailOpen, OutagePolicyFailClosed:
+		return nil
+	}
+	return fmt.Errorf("invalid hl_outage_policy %q, expected %s or %s", p, OutagePolicyFailOpen, OutagePolicyFailClosed)
+}
+
+// ScannerAuth is how a scanner authenticates. These must match hl_common.py.
+type ScannerAuth string
+
+const (
+	ScannerAuthClientCredentials ScannerAuth = "client_credentials" // OAuth client credentials, stored in the schema's secrets scope
+	ScannerAuthNone              ScannerAuth = "none"               // no authentication, like the enterprise scanner
+	// A short-lived Databricks token of the run-as service principal, which each scan job mints at runtime, for an
+	// enterprise scanner that accepts Databricks tokens because it is behind the same SSO
+	ScannerAuthDatabricksToken ScannerAuth = "databricks_token"
+)
+
+// Validate checks that the authentication mode is known, or empty for the default of the scanner's API URL.
+func (a ScannerAuth) Validate() error {
+	switch a {
+	case "", ScannerAuthClientCredentials, ScannerAuthNone, ScannerAuthDatabricksToken:
+		return nil
+	}
+	return fmt.Errorf("unknown auth %q, expected %s, %s, or %s", a, ScannerAuthClientCredentials, ScannerAuthNone,
+		ScannerAuthDatabricksToken)
+}
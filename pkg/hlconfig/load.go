@@ -0,0 +1,282 @@
+package hlconfig
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix prefixes the environment variables that override settings, e.g. HLDBX_DBX_CLUSTER_ID for dbx_cluster_id.
+const EnvPrefix = "HLDBX_"
+
+// Source is a set of settings, by configuration key, such as those of a configuration file, the environment, or
+// command line flags. Values are as they would appear in the YAML file.
+type Source map[string]any
+
+// ProfilesKey is the key of the section of a configuration file that holds its named profiles, e.g. one per
+// deployment. Each is a set of settings that replace those at the top of the file when the profile is selected.
+const ProfilesKey = "profiles"
+
+// Setting is a key of the configuration file, and the kind of its value.
+type Setting struct {
+	Key  string
+	Kind reflect.Kind
+}
+
+// Settings returns the keys of the configuration file, in the order of the fields of Config.
+func Settings() []Setting {
+	var settings []Setting
+	var walk func(reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := range t.NumField() {
+			field := t.Field(i)
+			key := field.Tag.Get("mapstructure")
+			if field.Anonymous && key == ",squash" {
+				walk(field.Type)
+				continue
+			}
+			settings = append(settings, Setting{Key: key, Kind: field.Type.Kind()})
+		}
+	}
+	walk(reflect.TypeOf(Config{}))
+	return settings
+}
+
+// ReadFile reads the settings of a YAML configuration file.
+func ReadFile(r io.Reader) (Source, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(r); err != nil {
+		return nil, err
+	}
+	return v.AllSettings(), nil
+}
+
+// ProfileNames returns the names of the profiles of the settings of a configuration file, sorted. They are lower
+// case, since the keys of a YAML file are read case-insensitively.
+func (s Source) ProfileNames() []string {
+	profiles, _ := s[ProfilesKey].(map[string]any)
+	return slices.Sorted(maps.Keys(profiles))
+}
+
+// Profile returns the settings of a configuration file with those of its named profile merged over them, as Apply
+// merges sources, or its top-level settings if name is "". Returns an error if the file has no such profile.
+func (s Source) Profile(name string) (Source, error) {
+	merged := Source{}
+	for key, value := range s {
+		if key != ProfilesKey {
+			merged[key] = value
+		}
+	}
+	if name == "" {
+		return merged, nil
+	}
+	profiles, _ := s[ProfilesKey].(map[string]any)
+	profile, ok := profiles[strings.ToLower(name)]
+	if !ok {
+		if len(profiles) == 0 {
+			return nil, fmt.Errorf("no profile %s, the configuration file has no %s section", name, ProfilesKey)
+		}
+		return nil, fmt.Errorf("no profile %s in the configuration file, expected one of %s", name,
+			strings.Join(s.ProfileNames(), ", "))
+	}
+	settings, ok := profile.(map[string]any)
+	if !ok && profile != nil {
+		return nil, fmt.Errorf("profile %s is not a mapping of settings", name)
+	}
+	for key, value := range settings {
+		merged[key] = value
+	}
+	return merged, nil
+}
+
+// Env returns the settings that environment variables override, given as KEY=value pairs like those of os.Environ.
+// Only settings with a single value, or a list of strings, which is given comma-separated, can be overridden.
+func Env(environ []string) Source {
+	source := Source{}
+	for _, setting := range Settings() {
+		if setting.Kind == reflect.Map || setting.Kind == reflect.Slice && !isStringList(setting.Key) {
+			continue
+		}
+		name := EnvPrefix + strings.ToUpper(setting.Key)
+		for _, entry := range environ {
+			value, ok := strings.CutPrefix(entry, name+"=")
+			if !ok {
+				continue
+			}
+			if setting.Kind == reflect.Slice {
+				var values []string
+				for _, item := range strings.Split(value, ",") {
+					if item = strings.TrimSpace(item); item != "" {
+						values = append(values, item)
+					}
+				}
+				source[setting.Key] = values
+			} else {
+				source[setting.Key] = value
+			}
+		}
+	}
+	return source
+}
+
+// Load returns the configuration of the given sources, merged by Apply.
+func Load(sources ...Source) (*Config, error) {
+	var config Config
+	if err := config.Apply(sources...); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Apply merges sources into the configuration, in order, so a later source wins: typically the configuration file,
+// then the environment, then flags. A setting that a source holds replaces the previous value whole, lists and maps
+// included, and the settings it doesn't hold are kept. Keys that aren't settings are ignored.
+func (c *Config) Apply(sources ...Source) error {
+	for _, source := range sources {
+		if len(source) == 0 {
+			continue
+		}
+		for key := range source {
+			// Cleared first, since decoding would otherwise merge into the previous lists and maps
+			if field := c.field(strings.ToLower(key)); field.IsValid() {
+				field.SetZero()
+			}
+		}
+		v := viper.New()
+		if err := v.MergeConfigMap(source); err != nil {
+			return err
+		}
+		if err := v.Unmarshal(c); err != nil {
+			return fmt.Errorf("unable to decode into struct, %v", err)
+		}
+	}
+	return nil
+}
+
+// Source returns the settings of the configuration that are set, as they would appear in a configuration file, so
+// that they can be displayed, written back, or merged into another configuration with Apply.
+func (c *Config) Source() Source {
+	source := Source{}
+	settingValues(reflect.ValueOf(c).Elem(), source)
+	return source
+}
+
+// settingValues adds the fields of the struct v that aren't zero to values, by their configuration keys.
+func settingValues(v reflect.Value, values map[string]any) {
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		key := field.Tag.Get("mapstructure")
+		if field.Anonymous && key == ",squash" {
+			settingValues(v.Field(i), values)
+		} else if !v.Field(i).IsZero() {
+			values[key] = settingValue(v.Field(i))
+		}
+	}
+}
+
+// settingValue returns a value as it would appear in a configuration file, with the sections of lists, such as the
+// entries of dbx_schemas, as mappings of their keys.
+func settingValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Struct:
+		values := map[string]any{}
+		settingValues(v, values)
+		return values
+	case reflect.Slice:
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = settingValue(v.Index(i))
+		}
+		return items
+	}
+	return v.Interface()
+}
+
+// field returns the field of the configuration that holds a setting, or the zero Value if the key isn't a setting.
+func (c *Config) field(key string) reflect.Value {
+	var find func(reflect.Value) reflect.Value
+	find = func(v reflect.Value) reflect.Value {
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			tag := field.Tag.Get("mapstructure")
+			if field.Anonymous && tag == ",squash" {
+				if found := find(v.Field(i)); found.IsValid() {
+					return found
+				}
+			} else if tag == key {
+				return v.Field(i)
+			}
+		}
+		return reflect.Value{}
+	}
+	return find(reflect.ValueOf(c).Elem())
+}
+
+// isStringList returns true if the setting is a list of strings, rather than of sections like dbx_schemas.
+func isStringList(key string) bool {
+	var config Config
+	field := config.field(key)
+	return field.IsValid() && field.Type().Elem().Kind() == reflect.String
+}
+
+// UnknownKeys returns the keys of the source that aren't settings, and those of the sections of its lists, such as
+// the entries of dbx_schemas, that their fields don't have, e.g. misspelled ones, which Load ignores. Keys of
+// sections are given with their path, e.g. hl_scanners[1].api_ur, and those of profiles too, e.g.
+// profiles.staging.dbx_hots.
+func UnknownKeys(source Source) []string {
+	profiles, isProfiles := source[ProfilesKey].(map[string]any)
+	if !isProfiles {
+		return unknownKeys(reflect.TypeOf(Config{}), source, "")
+	}
+	settings, _ := source.Profile("")
+	unknown := unknownKeys(reflect.TypeOf(Config{}), settings, "")
+	for _, name := range source.ProfileNames() {
+		if profile, ok := profiles[name].(map[string]any); ok {
+			unknown = append(unknown, unknownKeys(reflect.TypeOf(Config{}), profile, ProfilesKey+"."+name+".")...)
+		}
+	}
+	return unknown
+}
+
+// unknownKeys returns the keys of values that aren't fields of the struct type t, prefixed with prefix.
+func unknownKeys(t reflect.Type, values map[string]any, prefix string) []string {
+	fields := map[string]reflect.Type{}
+	var collect func(reflect.Type)
+	collect = func(t reflect.Type) {
+		for i := range t.NumField() {
+			field := t.Field(i)
+			key := field.Tag.Get("mapstructure")
+			if field.Anonymous && key == ",squash" {
+				collect(field.Type)
+			} else {
+				fields[key] = field.Type
+			}
+		}
+	}
+	collect(t)
+
+	var unknown []string
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		fieldType, ok := fields[strings.ToLower(key)]
+		if !ok {
+			unknown = append(unknown, prefix+key)
+			continue
+		}
+		items, ok := values[key].([]any)
+		if !ok || fieldType.Kind() != reflect.Slice || fieldType.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		for i, item := range items {
+			if section, ok := item.(map[string]any); ok {
+				unknown = append(unknown, unknownKeys(fieldType.Elem(), section, fmt.Sprintf("%s%s[%d].", prefix, key, i))...)
+			}
+		}
+	}
+	return unknown
+}
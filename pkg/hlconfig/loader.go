@@ -0,0 +1,127 @@
+package hlconfig
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Loader loads the configuration of a configuration file, or of a profile of it, overridden by the settings of
+// the environment, and reloads it when the file changes, e.g. for long-running commands. Each Loader reads its own
+// file, profile, and environment, so that several, e.g. of one profile each, can be used in one process. It's safe
+// for concurrent use.
+type Loader struct {
+	Path    string // of the configuration file; without one, the environment's settings are used alone
+	Profile string // of the file, or "" for the settings at its top
+	Env     Source // settings that override those of the file, e.g. Env(os.Environ())
+
+	reload  sync.Mutex             // serializes loads, so that the configurations they make current are in order
+	current atomic.Pointer[Config] // the configuration that was loaded last
+	modTime time.Time              // of the file when it was loaded last, zero without one
+	size    int64
+}
+
+// Sources returns the settings of the configuration file, with those of the profile merged over them, and those
+// of the environment, which Load merges in that order.
+func (l *Loader) Sources() (file Source, env Source, err error) {
+	file, _, _, err = l.readFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, l.Env, nil
+}
+
+// Load reads the configuration file, and returns its configuration, which becomes the current one. The
+// configuration is shared with the callers of Current, so copy it before changing it.
+func (l *Loader) Load() (*Config, error) {
+	l.reload.Lock()
+	defer l.reload.Unlock()
+	file, modTime, size, err := l.readFile()
+	if err != nil {
+		return nil, err
+	}
+	config, err := Load(file, l.Env)
+	if err != nil {
+		return nil, err
+	}
+	l.current.Store(config)
+	l.modTime, l.size = modTime, size
+	return config, nil
+}
+
+// Current returns the configuration that was loaded last, or nil if none was.
+func (l *Loader) Current() *Config {
+	return l.current.Load()
+}
+
+// Reload loads the configuration again if the configuration file changed since it was loaded last, e.g. because
+// it was edited, created, or deleted. Returns the configuration, and whether it was reloaded. If the file can't be
+// loaded, e.g. because it's invalid, the current configuration is kept, and the error returned, once per change of
+// the file.
+func (l *Loader) Reload() (*Config, bool, error) {
+	if !l.changed() {
+		return l.Current(), false, nil
+	}
+	config, err := l.Load()
+	if err != nil {
+		l.reload.Lock()
+		l.modTime, l.size, _ = fileStat(l.Path)
+		l.reload.Unlock()
+		return l.Current(), false, err
+	}
+	return config, true, nil
+}
+
+// changed returns true if the configuration file changed since it was loaded last, or none was.
+func (l *Loader) changed() bool {
+	l.reload.Lock()
+	defer l.reload.Unlock()
+	if l.current.Load() == nil {
+		return true
+	}
+	modTime, size, _ := fileStat(l.Path)
+	return !modTime.Equal(l.modTime) || size != l.size
+}
+
+// readFile returns the settings of the configuration file, with those of the profile merged over them, and its
+// modification time and size, which are zero without a file.
+func (l *Loader) readFile() (Source, time.Time, int64, error) {
+	file := Source{}
+	modTime, size, err := fileStat(l.Path)
+	if err != nil {
+		return nil, time.Time{}, 0, err
+	}
+	if l.Path != "" {
+		in, err := os.Open(l.Path)
+		if err == nil {
+			defer in.Close()
+			if file, err = ReadFile(in); err != nil {
+				return nil, time.Time{}, 0, fmt.Errorf("invalid configuration file %s: %w", l.Path, err)
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, time.Time{}, 0, err
+		}
+	}
+	file, err = file.Profile(l.Profile)
+	if err != nil {
+		return nil, time.Time{}, 0, fmt.Errorf("invalid configuration file %s: %w", l.Path, err)
+	}
+	return file, modTime, size, nil
+}
+
+// fileStat returns the modification time and size of a file, which are zero if it doesn't exist.
+func fileStat(path string) (time.Time, int64, error) {
+	if path == "" {
+		return time.Time{}, 0, nil
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, 0, nil
+	} else if err != nil {
+		return time.Time{}, 0, err
+	}
+	return info.ModTime(), info.Size(), nil
+}
@@ -0,0 +1,134 @@
+package hlconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const loaderTestFile = `dbx_host: https://top.cloud.databricks.com
+dbx_cluster_id: top-cluster
+dbx_polling_quartz_cron: "0 0 * * * ?"
+profiles:
+  staging:
+    dbx_host: https://staging.cloud.databricks.com
+    dbx_schemas:
+      - dbx_catalog: staging
+        dbx_schema: models
+`
+
+func writeLoaderTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoaderLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hldbx.yaml")
+	writeLoaderTestFile(t, path, loaderTestFile)
+
+	tests := []struct {
+		name        string
+		profile     string
+		env         Source
+		wantHost    string
+		wantCluster string
+		wantSchemas int
+		wantErr     bool
+	}{
+		{name: "top of the file", wantHost: "https://top.cloud.databricks.com", wantCluster: "top-cluster"},
+		{name: "profile over the top", profile: "staging", wantHost: "https://staging.cloud.databricks.com",
+			wantCluster: "top-cluster", wantSchemas: 1},
+		{name: "profile names are case-insensitive", profile: "Staging",
+			wantHost: "https://staging.cloud.databricks.com", wantCluster: "top-cluster", wantSchemas: 1},
+		{name: "environment over the profile", profile: "staging", env: Env([]string{"HLDBX_DBX_CLUSTER_ID=env-cluster"}),
+			wantHost: "https://staging.cloud.databricks.com", wantCluster: "env-cluster", wantSchemas: 1},
+		{name: "unknown profile", profile: "prod", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loader := &Loader{Path: path, Profile: test.profile, Env: test.env}
+			config, err := loader.Load()
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Load() = %+v, want an error", config)
+				}
+				if loader.Current() != nil {
+					t.Errorf("Current() = %+v after a failed Load(), want nil", loader.Current())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error: %v", err)
+			}
+			if config.DbxHost != test.wantHost || config.DbxClusterId != test.wantCluster ||
+				len(config.DbxSchemas) != test.wantSchemas {
+				t.Errorf("Load() = dbx_host %q, dbx_cluster_id %q, %d schemas, want %q, %q, %d", config.DbxHost,
+					config.DbxClusterId, len(config.DbxSchemas), test.wantHost, test.wantCluster, test.wantSchemas)
+			}
+			if loader.Current() != config {
+				t.Errorf("Current() isn't the configuration that Load() returned")
+			}
+		})
+	}
+}
+
+func TestLoaderWithoutFile(t *testing.T) {
+	loader := &Loader{Path: filepath.Join(t.TempDir(), "missing.yaml"),
+		Env: Env([]string{"HLDBX_DBX_HOST=https://env.cloud.databricks.com"})}
+	config, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+	if config.DbxHost != "https://env.cloud.databricks.com" {
+		t.Errorf("Load() dbx_host = %q, want that of the environment", config.DbxHost)
+	}
+}
+
+func TestLoaderReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hldbx.yaml")
+	writeLoaderTestFile(t, path, "dbx_cluster_id: first\n")
+	loader := &Loader{Path: path}
+	first, err := loader.Load()
+	if err != nil {
+		t.Fatalf("Load() error: %v", err)
+	}
+
+	config, reloaded, err := loader.Reload()
+	if err != nil || reloaded || config != first {
+		t.Fatalf("Reload() of an unchanged file = %+v, %t, %v, want the current configuration, false, nil", config,
+			reloaded, err)
+	}
+
+	// The sizes differ, so the change is seen whatever the resolution of modification times
+	writeLoaderTestFile(t, path, "dbx_cluster_id: second-cluster\n")
+	config, reloaded, err = loader.Reload()
+	if err != nil || !reloaded || config.DbxClusterId != "second-cluster" {
+		t.Fatalf("Reload() of a changed file = %+v, %t, %v, want dbx_cluster_id second-cluster, true, nil", config,
+			reloaded, err)
+	}
+	second := config
+
+	writeLoaderTestFile(t, path, "dbx_cluster_id: [invalid\n")
+	config, reloaded, err = loader.Reload()
+	if err == nil || reloaded || config != second {
+		t.Fatalf("Reload() of an invalid file = %+v, %t, %v, want the previous configuration, false, an error",
+			config, reloaded, err)
+	}
+	// The error is returned once per change of the file
+	config, reloaded, err = loader.Reload()
+	if err != nil || reloaded || config != second {
+		t.Fatalf("Reload() of the same invalid file = %+v, %t, %v, want the previous configuration, false, nil",
+			config, reloaded, err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	config, reloaded, err = loader.Reload()
+	if err != nil || !reloaded || config.DbxClusterId != "" {
+		t.Fatalf("Reload() of a deleted file = %+v, %t, %v, want an empty configuration, true, nil", config,
+			reloaded, err)
+	}
+}
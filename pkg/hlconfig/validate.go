@@ -0,0 +1,51 @@
+package hlconfig
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Validate checks the settings that the format itself constrains: the values of the enumerated settings, and the
+// ranges of the numeric ones. Settings that depend on the workspace, such as the monitored schemas or the cluster,
+// are left to the tools that use them.
+func (c *Config) Validate() error {
+	validators := []func() error{
+		c.DbxClusterTagPolicy.Validate, c.DbxScanTrigger.Validate, c.DbxDiscoverySource.Validate,
+		c.HlOutagePolicy.Validate, c.ValidateMaxActiveScanJobs, c.ValidateCloud,
+	}
+	for _, validate := range validators {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	for _, scanner := range c.HlScanners {
+		if err := scanner.Auth.Validate(); err != nil {
+			return fmt.Errorf("scanner %q has %w", scanner.Name, err)
+		}
+	}
+	if c.DbxKeepVersions < 0 {
+		return fmt.Errorf("invalid dbx_keep_notebook_versions %d, expected 0 for the default of %d, or more",
+			c.DbxKeepVersions, defaultKeepNotebookVersions)
+	}
+	if c.DbxRetentionDays < 0 {
+		return fmt.Errorf("invalid dbx_retention_days %d, expected 0 to keep records forever, or more", c.DbxRetentionDays)
+	}
+	// The scan jobs get the proxy as a job parameter, which anyone who can view the jobs can read
+	if proxyUrl, err := url.Parse(c.HlHttpsProxy); err == nil && proxyUrl.User != nil {
+		return fmt.Errorf("invalid hl_https_proxy %s, it can't hold credentials, which would be visible in the jobs' "+
+			"parameters: allow the clusters through the proxy without them", proxyUrl.Redacted())
+	}
+	if c.HlCredsMaxAgeDays < 0 {
+		return fmt.Errorf("invalid hl_credentials_max_age_days %d, expected 0 for the default of %d, or more",
+			c.HlCredsMaxAgeDays, defaultHlCredsMaxAgeDays)
+	}
+	return nil
+}
+
+// ValidateMaxActiveScanJobs checks the number of scan jobs that the monitoring job may run at once.
+func (c *Config) ValidateMaxActiveScanJobs() error {
+	if n := c.MaxActiveScanJobs(); n < MinMaxActiveScanJobs || n > MaxMaxActiveScanJobs {
+		return fmt.Errorf("invalid dbx_max_active_scan_jobs %d, expected %d to %d", n, MinMaxActiveScanJobs, MaxMaxActiveScanJobs)
+	}
+	return nil
+}
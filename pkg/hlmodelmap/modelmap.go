@@ -0,0 +1,129 @@
+// Package hlmodelmap looks up the model map table, in which the hldbx scan jobs record the HiddenLayer model and scan
+// IDs of each scanned Unity Catalog model version. Other HiddenLayer tooling imports it to go from a model version to
+// its HiddenLayer scan, or back.
+package hlmodelmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/databricks/databricks-sdk-go"
+	"github.com/databricks/databricks-sdk-go/service/sql"
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+)
+
+// Suffix of the state table's name that gives the name of the model map table
+const tableSuffix = "_models"
+
+// Columns of the model map table, in the order they are selected. These must match record_model_mapping() in
+// hl_scan_model.py.
+const columns = "model_name, model_version, hl_model_id, scan_id, hl_api_url, scan_url, threat_level, scanned_at, " +
+	"CAST(updated_at AS STRING)"
+
+// Mapping maps a Unity Catalog model version to the HiddenLayer model and scan of its latest finished scan.
+type Mapping struct {
+	Model       string `json:"model"` // <catalog>.<schema>.<model_name>
+	Version     int    `json:"version"`
+	HlModelId   string `json:"hl_model_id,omitempty"` // only reported by the SaaS scanner
+	ScanId      string `json:"scan_id"`
+	HlApiUrl    string `json:"hl_api_url"` // API URL of the scanner that scanned it
+	ScanUrl     string `json:"scan_url,omitempty"`
+	ThreatLevel string `json:"threat_level,omitempty"`
+	ScannedAt   string `json:"scanned_at,omitempty"`
+	UpdatedAt   string `json:"updated_at,omitempty"` // when the mapping was last recorded
+}
+
+// Query selects mappings by the Unity Catalog model, and optionally its version, or by HiddenLayer model ID,
+// or by scan ID. Exactly one of Model, HlModelId, and ScanId must be set.
+type Query struct {
+	Model     string
+	Version   int // 0 for every version of Model
+	HlModelId string
+	ScanId    string
+}
+
+// Table returns the full name of the Delta table that the scan jobs record the HiddenLayer model and scan IDs
+// of model versions in, next to the state table.
+func Table(config *hlconfig.Config) string {
+	if config.StateTable() == "" {
+		return ""
+	}
+	return config.StateTable() + tableSuffix
+}
+
+// where returns the condition and parameters of the query.
+func (q Query) where() (string, []sql.StatementParameterListItem, error) {
+	set := 0
+	for _, value := range []string{q.Model, q.HlModelId, q.ScanId} {
+		if value != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return "", nil, errors.New("look up by exactly one of model, HiddenLayer model ID, or scan ID")
+	}
+	switch {
+	case q.Model != "" && q.Version > 0:
+		return "model_name = :model_name AND model_version = :model_version", []sql.StatementParameterListItem{
+			{Name: "model_name", Value: q.Model},
+			{Name: "model_version", Value: strconv.Itoa(q.Version), Type: "BIGINT"},
+		}, nil
+	case q.Model != "":
+		return "model_name = :model_name", []sql.StatementParameterListItem{{Name: "model_name", Value: q.Model}}, nil
+	case q.HlModelId != "":
+		return "hl_model_id = :hl_model_id", []sql.StatementParameterListItem{{Name: "hl_model_id", Value: q.HlModelId}}, nil
+	}
+	return "scan_id = :scan_id", []sql.StatementParameterListItem{{Name: "scan_id", Value: q.ScanId}}, nil
+}
+
+// Lookup queries the model map table, through a SQL warehouse, for the mappings that the query selects,
+// ordered by model name and version. Returns none if the table doesn't exist yet, i.e. no scan has finished since the
+// scan jobs started recording mappings.
+func Lookup(ctx context.Context, client *databricks.WorkspaceClient, config *hlconfig.Config, warehouseId string,
+	query Query) ([]Mapping, error) {
+	table := Table(config)
+	if table == "" {
+		return nil, errors.New("no model map table, set dbx_state_table or dbx_schemas")
+	}
+	where, params, err := query.where()
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.StatementExecution.ExecuteAndWait(ctx, sql.ExecuteStatementRequest{
+		WarehouseId: warehouseId,
+		Statement:   fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY model_name, model_version", columns, table, where),
+		Parameters:  params,
+		Disposition: sql.DispositionInline,
+		Format:      sql.FormatJsonArray,
+		WaitTimeout: "30s",
+	})
+	if err != nil {
+		if strings.Contains(err.Error(), "TABLE_OR_VIEW_NOT_FOUND") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to query %s: %w", table, err)
+	}
+	var mappings []Mapping
+	result := response.Result
+	for result != nil {
+		for _, row := range result.DataArray {
+			if len(row) < 9 {
+				continue
+			}
+			version, _ := strconv.Atoi(row[1])
+			mappings = append(mappings, Mapping{Model: row[0], Version: version, HlModelId: row[2], ScanId: row[3],
+				HlApiUrl: row[4], ScanUrl: row[5], ThreatLevel: row[6], ScannedAt: row[7], UpdatedAt: row[8]})
+		}
+		if result.NextChunkIndex == 0 {
+			break
+		}
+		result, err = client.StatementExecution.GetStatementResultChunkNByStatementIdAndChunkIndex(ctx, response.StatementId, result.NextChunkIndex)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get results of the %s query: %w", table, err)
+		}
+	}
+	return mappings, nil
+}
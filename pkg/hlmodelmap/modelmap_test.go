@@ -0,0 +1,49 @@
+package hlmodelmap
+
+import (
+	"testing"
+
+	"github.com/hiddenlayer-engineering/hl-databricks/pkg/hlconfig"
+)
+
+func TestTable(t *testing.T) {
+	config := &hlconfig.Config{}
+	if table := Table(config); table != "" {
+		t.Errorf("Table() = %q without a state table, want none", table)
+	}
+	config.DbxStateTable = "main.hiddenlayer.hl_scan_state"
+	if table := Table(config); table != "main.hiddenlayer.hl_scan_state_models" {
+		t.Errorf("Table() = %q, want the state table with the _models suffix", table)
+	}
+}
+
+func TestQueryWhere(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      Query
+		wantWhere  string
+		wantParams int
+		wantErr    bool
+	}{
+		{name: "model version", query: Query{Model: "main.ml.fraud", Version: 3},
+			wantWhere: "model_name = :model_name AND model_version = :model_version", wantParams: 2},
+		{name: "every version of a model", query: Query{Model: "main.ml.fraud"},
+			wantWhere: "model_name = :model_name", wantParams: 1},
+		{name: "HiddenLayer model", query: Query{HlModelId: "hl-model"}, wantWhere: "hl_model_id = :hl_model_id", wantParams: 1},
+		{name: "scan", query: Query{ScanId: "scan"}, wantWhere: "scan_id = :scan_id", wantParams: 1},
+		{name: "nothing selected", query: Query{}, wantErr: true},
+		{name: "model and scan", query: Query{Model: "main.ml.fraud", ScanId: "scan"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			where, params, err := test.query.where()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("where() error = %v, want error %t", err, test.wantErr)
+			}
+			if where != test.wantWhere || len(params) != test.wantParams {
+				t.Errorf("where() = %q with %d parameter(s), want %q with %d", where, len(params), test.wantWhere,
+					test.wantParams)
+			}
+		})
+	}
+}